@@ -8,21 +8,32 @@ DROP TABLE IF EXISTS `user_order`;
 DROP TABLE IF EXISTS `user_order_info`;
 DROP TABLE IF EXISTS `order_summary`;
 DROP TABLE IF EXISTS `job_order`;
+DROP TABLE IF EXISTS `job_order_backfill`;
 DROP TABLE IF EXISTS `job_product`;
+DROP TABLE IF EXISTS `webhook_inbox`;
+DROP TABLE IF EXISTS `webhook_drift`;
 DROP TABLE IF EXISTS `user_setting`;
 DROP TABLE IF EXISTS `app_definition`;
 DROP TABLE IF EXISTS `app_config`;
 DROP TABLE IF EXISTS `user_app_auth`;
 DROP TABLE IF EXISTS `commission_bill`;
+DROP TABLE IF EXISTS `commission_adjustment`;
 DROP TABLE IF EXISTS `user_subscription`;
 DROP TABLE IF EXISTS `billing_period_summary`;
 DROP TABLE IF EXISTS `protectify_statistics`;
+DROP TABLE IF EXISTS `exchange_rate`;
+DROP TABLE IF EXISTS `billing_reconciliation`;
+DROP TABLE IF EXISTS `app_plan`;
+DROP TABLE IF EXISTS `order_claim`;
+DROP TABLE IF EXISTS `order_claim_item`;
+DROP TABLE IF EXISTS `order_claim_evidence`;
 
 -- 用户订阅信息表
 CREATE TABLE `user_subscription`
 (
     `id`                        bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
     `user_id`                   bigint unsigned NOT NULL COMMENT '用户ID',
+    `plan_id`                   bigint unsigned NOT NULL DEFAULT 0 COMMENT '套餐ID',
     `shop_domain`               varchar(100)    NOT NULL DEFAULT '' COMMENT '店铺域名',
     `charge_id`                 bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify订阅ID',
     `subscription_name`         varchar(100)    NOT NULL DEFAULT '' COMMENT '订阅名称',
@@ -65,6 +76,7 @@ CREATE TABLE `commission_bill`
     `billing_period_end`      bigint unsigned NOT NULL DEFAULT 0 COMMENT '账单周期结束时间',
     `bill_cycle`              varchar(20)     NOT NULL COMMENT '账单周期标识（YYYY-MM-DD）',
     `commission_amount`       decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '抽成金额',
+    `deducted_amount`         decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '退款冲减金额',
     `commission_rate`         decimal(5, 2)   NOT NULL DEFAULT 0.00 COMMENT '抽成比例（百分比）',
     `protectify_type`         varchar(30)     NOT NULL DEFAULT 'general' COMMENT '保险类型：general-通用保险，product-产品保险，shipping-运输保险',
     `subscription_id`         bigint unsigned NOT NULL DEFAULT 0 COMMENT '关联的订阅ID',
@@ -72,9 +84,16 @@ CREATE TABLE `commission_bill`
     `order_total_amount`      decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '订单总金额',
     `commission_items`        text COMMENT '抽成明细项（JSON格式，包含保险项目等）',
     `currency`                varchar(10)     NOT NULL DEFAULT '' COMMENT '货币类型',
+    `charge_currency`         varchar(10)     NOT NULL DEFAULT '' COMMENT '扣费币种（订阅上限的币种）',
+    `original_amount`         decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '换算前的扣费金额（账单币种）',
+    `converted_amount`        decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '换算后的扣费金额（扣费币种）',
+    `exchange_rate`           decimal(18, 8)  NOT NULL DEFAULT 0.00000000 COMMENT '换算汇率（1单位账单币种兑换的扣费币种）',
     `shopify_usage_record_id` varchar(100)    NOT NULL DEFAULT '' COMMENT 'Shopify用量记录ID',
     `charge_status`           tinyint         NOT NULL DEFAULT 0 COMMENT '扣费状态：0-待提交, 1-已提交, 2-提交失败',
     `error_message`           text COMMENT '错误信息',
+    `retry_count`             int             NOT NULL DEFAULT 0 COMMENT '扣费重试次数',
+    `recognition_status`      tinyint         NOT NULL DEFAULT 0 COMMENT '抽成确认状态：0-待确认, 1-已确认',
+    `recognized_at`           bigint unsigned NOT NULL DEFAULT 0 COMMENT '抽成确认时间',
     `charged_at`              bigint unsigned NOT NULL DEFAULT 0 COMMENT '扣费时间',
     `create_time`             bigint unsigned NOT NULL COMMENT '创建时间',
     `update_time`             bigint unsigned NOT NULL COMMENT '修改时间',
@@ -82,6 +101,7 @@ CREATE TABLE `commission_bill`
     UNIQUE KEY `uk_charge_id` (`charge_id`),
     UNIQUE KEY `uk_user_order` (`user_id`, `user_order_id`),
     KEY `idx_user_id_status` (`user_id`, `charge_status`),
+    KEY `idx_user_recognition` (`user_id`, `recognition_status`),
     KEY `idx_bill_cycle` (`bill_cycle`),
     KEY `idx_charge_status` (`charge_status`),
     KEY `idx_shopify_usage_record_id` (`shopify_usage_record_id`),
@@ -92,6 +112,77 @@ CREATE TABLE `commission_bill`
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='抽成收费记录表';
 
+-- 账单对账差异表
+CREATE TABLE `billing_reconciliation`
+(
+    `id`                      bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `user_id`                 bigint unsigned NOT NULL COMMENT '用户ID',
+    `subscription_id`         bigint unsigned NOT NULL DEFAULT 0 COMMENT '对账的订阅ID',
+    `bill_id`                 bigint unsigned NOT NULL DEFAULT 0 COMMENT '抽成账单ID',
+    `shopify_usage_record_id` varchar(100)    NOT NULL DEFAULT '' COMMENT 'Shopify用量记录ID',
+    `issue_type`              varchar(30)     NOT NULL DEFAULT '' COMMENT '差异类型：missing_local-本地缺失, missing_remote-Shopify缺失, amount_mismatch-金额不一致',
+    `local_amount`            decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '本地扣费金额',
+    `local_currency`          varchar(10)     NOT NULL DEFAULT '' COMMENT '本地扣费币种',
+    `remote_amount`           decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT 'Shopify用量记录金额',
+    `remote_currency`         varchar(10)     NOT NULL DEFAULT '' COMMENT 'Shopify用量记录币种',
+    `detail`                  varchar(500)    NOT NULL DEFAULT '' COMMENT '差异说明',
+    `reconciled_at`           bigint unsigned NOT NULL DEFAULT 0 COMMENT '对账时间',
+    `create_time`             bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`             bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_issue_type` (`issue_type`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='账单对账差异表';
+
+-- 汇率表
+CREATE TABLE `exchange_rate`
+(
+    `id`             bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `base_currency`  varchar(10)     NOT NULL DEFAULT '' COMMENT '基准币种',
+    `quote_currency` varchar(10)     NOT NULL DEFAULT '' COMMENT '报价币种',
+    `rate`           decimal(18, 8)  NOT NULL DEFAULT 0.00000000 COMMENT '汇率（1单位基准币种兑换的报价币种）',
+    `source`         varchar(30)     NOT NULL DEFAULT '' COMMENT '汇率来源：static-固定汇率, file-汇率文件',
+    `create_time`    bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`    bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_currency_pair` (`base_currency`, `quote_currency`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='汇率表';
+
+-- 抽成调整流水表
+CREATE TABLE `commission_adjustment`
+(
+    `id`                 bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `user_id`            bigint unsigned NOT NULL COMMENT '用户ID',
+    `bill_id`            bigint unsigned NOT NULL COMMENT '关联的抽成账单ID',
+    `user_order_id`      bigint unsigned NOT NULL COMMENT '关联的订单ID',
+    `order_name`         varchar(50)     NOT NULL DEFAULT '' COMMENT 'Shopify订单编号',
+    `adjustment_type`    varchar(20)     NOT NULL DEFAULT 'refund' COMMENT '调整类型：refund-退款冲减',
+    `amount`             decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '调整金额（负数为冲减）',
+    `absorbed_amount`    decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '账期内冲减的金额',
+    `credit_amount`      decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '需要通过app credit返还的金额',
+    `currency`           varchar(10)     NOT NULL DEFAULT '' COMMENT '货币类型',
+    `billing_period_end` bigint unsigned NOT NULL DEFAULT 0 COMMENT '冲减所在账单周期结束时间',
+    `subscription_id`    bigint unsigned NOT NULL DEFAULT 0 COMMENT '冲减所在账期的订阅ID',
+    `bill_cycle`         varchar(20)     NOT NULL DEFAULT '' COMMENT '冲减所在账期',
+    `status`             tinyint         NOT NULL DEFAULT 0 COMMENT '状态：0-待处理, 1-已冲减, 2-已返还, 3-返还失败, 4-返还中',
+    `shopify_credit_id`  varchar(100)    NOT NULL DEFAULT '' COMMENT 'Shopify app credit ID',
+    `retry_count`        int             NOT NULL DEFAULT 0 COMMENT '返还重试次数',
+    `error_message`      text COMMENT '错误信息',
+    `create_time`        bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`        bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_order` (`user_id`, `user_order_id`),
+    KEY `idx_bill_id` (`bill_id`),
+    KEY `idx_status` (`status`),
+    KEY `idx_billing_period_end` (`billing_period_end`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='抽成调整流水表';
+
 -- 新增账单周期汇总表
 CREATE TABLE `billing_period_summary`
 (
@@ -106,6 +197,8 @@ CREATE TABLE `billing_period_summary`
     `pending_amount`          decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '待付金额',
     `paid_amount`             decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '已付金额',
     `error_amount`            decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '失败金额',
+    `adjustment_amount`       decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '调整金额（退款冲减为负数）',
+    `credit_amount`           decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '已通过app credit返还金额',
     `bill_count`              int             NOT NULL DEFAULT 0 COMMENT '账单数量',
     `order_count`             int             NOT NULL DEFAULT 0 COMMENT '订单数量',
     `currency`                varchar(10)     NOT NULL DEFAULT '' COMMENT '货币类型',
@@ -177,22 +270,34 @@ CREATE TABLE `protectify_statistics`
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='保险业务统计报表';
 
--- Redact 历史记录表 (仅记录最小信息，用于防重复)
+-- Redact 历史记录表 (仅记录最小信息，用于防重复和核对合规请求的处理期限)
 CREATE TABLE `redact_history`
 (
     `id`          bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
     `app_id`      varchar(50)     NOT NULL COMMENT 'App标识',
     `shop`        varchar(100)    NOT NULL COMMENT 'Shop域名',
+    `user_id`     bigint unsigned NOT NULL DEFAULT 0 COMMENT '用户ID',
+    `inbox_id`    bigint unsigned NOT NULL DEFAULT 0 COMMENT 'webhook 收件箱ID',
+    `topic`       varchar(50)     NOT NULL DEFAULT '' COMMENT '合规请求类型',
+    `customer_id` bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify顾客ID',
+    `request_id`  bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify数据请求ID',
+    `status`      tinyint         NOT NULL DEFAULT 0 COMMENT '状态 0处理中 1已完成',
+    `records`     int             NOT NULL DEFAULT 0 COMMENT '导出或清除的记录数',
+    `file_key`    varchar(255)    NOT NULL DEFAULT '' COMMENT '数据导出文件',
+    `deadline`    bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify要求的完成期限',
     `redact_time` bigint unsigned NOT NULL COMMENT 'Redact处理时间',
     `create_time` bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time` bigint unsigned NOT NULL COMMENT '修改时间',
     PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_inbox_id` (`inbox_id`),
     KEY `idx_app_shop` (`app_id`, `shop`),
+    KEY `idx_status_deadline` (`status`, `deadline`),
     KEY `idx_redact_time` (`redact_time`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='Redact历史记录表(最小化记录)';
 
--- 用户表 (移除 redact 字段，因为 redact 的用户会被物理删除)
+-- 用户表 (shop/redact 后清除店铺个人信息，保留行供账单记录关联)
 CREATE TABLE `user`
 (
     `id`                bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
@@ -202,7 +307,7 @@ CREATE TABLE `user`
     `shop_id`           bigint unsigned NOT NULL DEFAULT 0 COMMENT 'shopify 店铺id',
     `real_domain`       varchar(100)    NOT NULL DEFAULT '' COMMENT '网站真实域名',
     `plan_display_name` varchar(40)     NOT NULL DEFAULT '' COMMENT 'shopify套餐版本',
-    `access_token`      varchar(512)    NOT NULL DEFAULT '' COMMENT 'shopify-token',
+    `access_token`      varchar(512)    NOT NULL DEFAULT '' COMMENT 'shopify-token，信封加密',
     `password`          varchar(255)    NOT NULL DEFAULT '' COMMENT '密码',
     `plans`             int             NOT NULL DEFAULT 0 COMMENT 'app套餐id',
     `email`             varchar(100)    NOT NULL DEFAULT '' COMMENT '邮箱',
@@ -214,6 +319,7 @@ CREATE TABLE `user`
     `trial_time`        bigint unsigned NOT NULL DEFAULT 0 COMMENT '试用时间',
     `currency_code`     varchar(10)     NOT NULL DEFAULT '' COMMENT '货币简码',
     `timezone`          int             NOT NULL DEFAULT 0 COMMENT '时区偏转分钟',
+    `iana_timezone`     varchar(64)     NOT NULL DEFAULT '' COMMENT '店铺IANA时区',
     `money_format`      varchar(20)     NOT NULL DEFAULT '' COMMENT '货币单位符号',
     `last_login`        bigint unsigned NOT NULL DEFAULT 0 COMMENT '最后登录时间',
     `is_del`            tinyint         NOT NULL DEFAULT 0 COMMENT '删除状态 0正常 1已删除',
@@ -258,6 +364,7 @@ CREATE TABLE `user_product`
     `status`       tinyint         NOT NULL DEFAULT 0 COMMENT '发布Shopify：0:未发布 1:已发布 2:正在发布中 3:shopify平台已删除',
     `publish_time` bigint unsigned NOT NULL DEFAULT 0 COMMENT '发布时间',
     `is_del`       tinyint         NOT NULL DEFAULT 0 COMMENT '删除状态 0 正常 1 已删除',
+    `ladder_version` tinyint       NOT NULL DEFAULT 0 COMMENT '变体阶梯版本 0 固定变体 1 按阶梯生成',
     `create_time`  bigint unsigned NOT NULL COMMENT '创建时间',
     `update_time`  bigint unsigned NOT NULL COMMENT '修改时间',
     PRIMARY KEY (`id`),
@@ -289,10 +396,11 @@ CREATE TABLE `user_variant`
     `sku_2`           varchar(150)    NOT NULL DEFAULT '' COMMENT '变体属性2',
     `sku_3`           varchar(150)    NOT NULL DEFAULT '' COMMENT '变体属性3',
     `price`           decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '价格设定',
+    `is_del`          tinyint         NOT NULL DEFAULT 0 COMMENT '删除状态 0 正常 1 已被阶梯替换',
     `create_time`     bigint unsigned NOT NULL COMMENT '创建时间',
     `update_time`     bigint unsigned NOT NULL COMMENT '修改时间',
     PRIMARY KEY (`id`),
-    KEY `idx_user_id` (`user_id`),
+    KEY `idx_user_id_del` (`user_id`, `is_del`),
     KEY `idx_user_product_id` (`user_product_id`),
     KEY `idx_product_id` (`product_id`),
     KEY `idx_variant_id` (`variant_id`),
@@ -331,6 +439,12 @@ CREATE TABLE `user_cart_setting`
     `all_tiers_set`      decimal(12, 2)           default 0.00 not null comment '所有订单适用固定百分比',
     `fulfillment_rule`   tinyint         not null default 0 comment '在订单处于哪个发货阶段才计算保险佣金(0,1,2 分别代表第一个发货完成，全都发货完成，付费后就算)',
     `css`                text comment 'css样式自定义',
+    `capped_paused`      tinyint         NOT NULL DEFAULT 0 COMMENT '是否因订阅用量达到上限自动关闭购物车 0 否 1 是',
+    `ladder_min`         decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '变体阶梯最低价格',
+    `ladder_max`         decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '变体阶梯最高价格',
+    `ladder_step`        decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '变体阶梯步长，0 表示使用默认阶梯',
+    `ladder_points`      text COMMENT '变体阶梯价格点(json)，不为空时忽略最低、最高价格和步长',
+    `ladder_rounding`    varchar(10)     NOT NULL DEFAULT 'up' COMMENT '报价取整到变体的方式 up 向上 down 向下 nearest 最接近',
     `create_time`        bigint unsigned NOT NULL COMMENT '创建时间',
     `update_time`        bigint unsigned NOT NULL COMMENT '修改时间',
     PRIMARY KEY (`id`),
@@ -346,6 +460,7 @@ CREATE TABLE `user_order`
     `user_id`             bigint unsigned NOT NULL COMMENT '用户id',
     `order_id`            bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify订单ID',
     `order_name`          varchar(50)     NOT NULL DEFAULT '' COMMENT '订单编号（#xxx）',
+    `email`               varchar(255)    NOT NULL DEFAULT '' COMMENT '客户邮箱',
     `order_created_at`    bigint unsigned NOT NULL DEFAULT 0 COMMENT '订单创建时间',
     `order_completion_at` bigint unsigned NOT NULL DEFAULT 0 COMMENT '订单完成时间',
     `financial_status`    varchar(50)     NOT NULL DEFAULT '' COMMENT '支付状态',
@@ -355,11 +470,13 @@ CREATE TABLE `user_order`
     `currency`            varchar(10)     NOT NULL DEFAULT '' COMMENT '货币类型',
     `sku_num`             int             NOT NULL DEFAULT 0 COMMENT 'sku购买数量',
     `is_del`              tinyint         NOT NULL DEFAULT 0 COMMENT '删除状态 0 正常 1 已删除',
+    `backfilled`          tinyint         NOT NULL DEFAULT 0 COMMENT '历史订单回填 0 否 1 是，回填的订单不产生抽成',
     `create_time`         bigint unsigned NOT NULL COMMENT '创建时间',
     `update_time`         bigint unsigned NOT NULL COMMENT '修改时间',
     PRIMARY KEY (`id`),
     UNIQUE KEY `uk_order_id` (`order_id`),
     KEY `idx_user_id_del` (`user_id`, `is_del`),
+    KEY `idx_user_order_name` (`user_id`, `order_name`),
     KEY `idx_user_financial_del` (`user_id`, `financial_status`, `is_del`),
     KEY `idx_order_created_at` (`order_created_at`),
     KEY `idx_financial_status` (`financial_status`),
@@ -400,7 +517,7 @@ CREATE TABLE `order_summary`
 (
     `id`          bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
     `user_id`     bigint unsigned NOT NULL COMMENT '用户ID',
-    `today`       bigint unsigned NOT NULL DEFAULT 0 COMMENT '当天0点时间戳',
+    `today`       bigint unsigned NOT NULL DEFAULT 0 COMMENT '店铺时区当天0点时间戳',
     `orders`      int             NOT NULL DEFAULT 0 COMMENT '订单数',
     `sales`       decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '销售金额',
     `refund`      decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '退款金额',
@@ -433,6 +550,76 @@ CREATE TABLE `job_order`
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='用户订单同步记录表';
 
+-- 历史订单回填任务表
+CREATE TABLE `job_order_backfill`
+(
+    `id`                bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `user_id`           bigint unsigned NOT NULL DEFAULT 0 COMMENT '用户 id',
+    `created_from`      bigint unsigned NOT NULL DEFAULT 0 COMMENT '订单创建时间起（含）',
+    `created_to`        bigint unsigned NOT NULL DEFAULT 0 COMMENT '订单创建时间止（不含）',
+    `status`            tinyint         NOT NULL DEFAULT 0 COMMENT '状态 0 待提交 1 批量查询中 2 导入中 3 完成 4 失败',
+    `bulk_operation_id` varchar(100)    NOT NULL DEFAULT '' COMMENT 'Shopify批量操作ID',
+    `result_url`        text COMMENT '批量查询结果下载链接',
+    `object_count`      bigint unsigned NOT NULL DEFAULT 0 COMMENT '批量查询结果对象数（含订单商品）',
+    `processed_count`   bigint unsigned NOT NULL DEFAULT 0 COMMENT '已处理订单数，也是断点续传的位置',
+    `imported_count`    bigint unsigned NOT NULL DEFAULT 0 COMMENT '导入订单数',
+    `skipped_count`     bigint unsigned NOT NULL DEFAULT 0 COMMENT '跳过订单数（未支付或已存在）',
+    `protectify_count`  bigint unsigned NOT NULL DEFAULT 0 COMMENT '包含保险的订单数',
+    `error_message`     varchar(500)    NOT NULL DEFAULT '' COMMENT '失败原因',
+    `finish_time`       bigint unsigned NOT NULL DEFAULT 0 COMMENT '完成时间',
+    `create_time`       bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`       bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id_status` (`user_id`, `status`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='历史订单回填任务表';
+
+-- Shopify webhook 收件箱
+CREATE TABLE `webhook_inbox`
+(
+    `id`             bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `app_id`         varchar(64)     NOT NULL DEFAULT '' COMMENT '应用ID',
+    `webhook_id`     varchar(100)    NOT NULL DEFAULT '' COMMENT 'X-Shopify-Webhook-Id',
+    `topic`          varchar(100)    NOT NULL DEFAULT '' COMMENT 'webhook topic',
+    `shop`           varchar(255)    NOT NULL DEFAULT '' COMMENT '店铺域名',
+    `api_version`    varchar(20)     NOT NULL DEFAULT '' COMMENT 'webhook API 版本',
+    `triggered_at`   varchar(40)     NOT NULL DEFAULT '' COMMENT 'Shopify 触发时间',
+    `payload`        mediumtext COMMENT 'webhook 原始内容',
+    `customer_id`    bigint unsigned NOT NULL DEFAULT 0 COMMENT 'webhook 内容中的Shopify顾客ID',
+    `customer_email` varchar(255)    NOT NULL DEFAULT '' COMMENT 'webhook 内容中的顾客邮箱',
+    `status`         tinyint         NOT NULL DEFAULT 0 COMMENT '状态 0 待处理 1 已处理 2 处理失败',
+    `attempts`       int unsigned    NOT NULL DEFAULT 0 COMMENT '处理次数',
+    `error_message`  varchar(500)    NOT NULL DEFAULT '' COMMENT '最近一次失败原因',
+    `processed_time` bigint unsigned NOT NULL DEFAULT 0 COMMENT '处理完成时间',
+    `create_time`    bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`    bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_webhook_id` (`webhook_id`),
+    KEY `idx_shop_topic` (`shop`, `topic`),
+    KEY `idx_shop_customer_id` (`shop`, `customer_id`),
+    KEY `idx_shop_customer_email` (`shop`, `customer_email`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='Shopify webhook 收件箱';
+
+-- webhook 订阅对账差异表
+CREATE TABLE `webhook_drift`
+(
+    `id`              bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `user_id`         bigint unsigned NOT NULL DEFAULT 0 COMMENT '店铺',
+    `topic`           varchar(100)    NOT NULL DEFAULT '' COMMENT 'webhook topic',
+    `action`          varchar(20)     NOT NULL DEFAULT '' COMMENT '修正方式 create update delete',
+    `subscription_id` varchar(100)    NOT NULL DEFAULT '' COMMENT 'Shopify订阅ID',
+    `callback_url`    varchar(500)    NOT NULL DEFAULT '' COMMENT 'Shopify上原来的回调地址',
+    `error_message`   varchar(500)    NOT NULL DEFAULT '' COMMENT '修正失败原因',
+    `create_time`     bigint unsigned NOT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='webhook订阅对账差异表';
+
 -- 用户上传记录表
 CREATE TABLE `job_product`
 (
@@ -478,8 +665,8 @@ CREATE TABLE `user_app_auth`
     `app_id`           varchar(50)     NOT NULL COMMENT 'App标识',
     `installation_id`  bigint unsigned not null default 0 comment '用户安装app id',
     `shop`             varchar(100)    NOT NULL DEFAULT '' COMMENT 'my shopify Domain网站域名',
-    `auth_token`       varchar(255)    NOT NULL DEFAULT '' COMMENT '授权token',
-    `refresh_token`    varchar(255)    NOT NULL DEFAULT '' COMMENT '刷新token',
+    `auth_token`       varchar(1024)   NOT NULL DEFAULT '' COMMENT '授权token，信封加密',
+    `refresh_token`    varchar(1024)   NOT NULL DEFAULT '' COMMENT '刷新token，信封加密',
     `token_expires_at` bigint unsigned NOT NULL DEFAULT 0 COMMENT 'token过期时间',
     `scopes`           text            NOT NULL COMMENT '授权域',
     `status`           tinyint         NOT NULL DEFAULT 1 COMMENT '状态 1:有效 0:已撤销',
@@ -525,6 +712,7 @@ CREATE TABLE `app_definition`
     `app_link`     varchar(100)    NOT NULL COMMENT 'APP Link',
     `api_key`      varchar(100)    NOT NULL COMMENT 'API Key',
     `api_secret`   varchar(100)    NOT NULL COMMENT 'API Secret',
+    `previous_secrets` text COMMENT '轮换中仍有效的旧 Secret，逗号分隔',
     `scopes`       text            NOT NULL COMMENT '授权域',
     `status`       tinyint         NOT NULL DEFAULT 1 COMMENT '状态 1:启用 0:禁用',
     `create_time`  bigint unsigned NOT NULL COMMENT '创建时间',
@@ -535,3 +723,111 @@ CREATE TABLE `app_definition`
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='App定义表';
 
+
+-- App 套餐表
+CREATE TABLE `app_plan`
+(
+    `id`               bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `code`             varchar(50)     NOT NULL COMMENT '套餐标识',
+    `name`             varchar(100)    NOT NULL COMMENT '套餐名称（Shopify订阅名称）',
+    `level`            int             NOT NULL DEFAULT 0 COMMENT '套餐等级，数值越大套餐越高',
+    `price`            decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '周期固定费用，0 表示没有固定费用',
+    `billing_interval` varchar(20)     NOT NULL DEFAULT 'EVERY_30_DAYS' COMMENT '收费周期：EVERY_30_DAYS, ANNUAL',
+    `capped_amount`    decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '用量上限，0 表示不收抽成',
+    `currency`         varchar(10)     NOT NULL DEFAULT 'USD' COMMENT '货币类型',
+    `terms`            varchar(255)    NOT NULL DEFAULT '' COMMENT '用量计费条款',
+    `trial_days`       int             NOT NULL DEFAULT 0 COMMENT '试用天数',
+    `commission_rates` text COMMENT '抽成费率表（JSON）',
+    `features`         text COMMENT '包含的功能（JSON数组）',
+    `is_default`       tinyint         NOT NULL DEFAULT 0 COMMENT '是否为没有订阅时的默认套餐：0-否，1-是',
+    `status`           tinyint         NOT NULL DEFAULT 1 COMMENT '状态：0-停用，1-启用',
+    `create_time`      bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`      bigint unsigned NOT NULL COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_code` (`code`),
+    KEY `idx_status_level` (`status`, `level`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='App套餐表';
+
+INSERT INTO `app_plan` (`code`, `name`, `level`, `price`, `billing_interval`, `capped_amount`, `currency`, `terms`,
+                        `trial_days`, `commission_rates`, `features`, `is_default`, `status`, `create_time`,
+                        `update_time`)
+VALUES ('basic', 'Protectify Basic', 1, 0.00, 'EVERY_30_DAYS', 200.00, 'USD',
+        'every paid order with insurance product will be taxed', 0, '[{"min":0,"max":0,"rate":5}]', '["order_insights"]', 1, 1,
+        UNIX_TIMESTAMP(), UNIX_TIMESTAMP()),
+       ('growth', 'Protectify Growth', 2, 9.99, 'EVERY_30_DAYS', 500.00, 'USD',
+        'every paid order with insurance product will be taxed', 7,
+        '[{"min":0,"max":100,"rate":4},{"min":100,"max":0,"rate":3}]', '["order_insights"]', 0, 1,
+        UNIX_TIMESTAMP(), UNIX_TIMESTAMP()),
+       ('pro', 'Protectify Pro', 3, 29.99, 'EVERY_30_DAYS', 1000.00, 'USD',
+        'every paid order with insurance product will be taxed', 14, '[{"min":0,"max":0,"rate":2.5}]',
+        '["order_insights","statement_export"]', 0, 1, UNIX_TIMESTAMP(), UNIX_TIMESTAMP());
+
+-- 保险理赔表
+CREATE TABLE `order_claim`
+(
+    `id`              bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `user_id`         bigint unsigned NOT NULL COMMENT '用户ID',
+    `user_order_id`   bigint unsigned NOT NULL COMMENT '订单主表ID',
+    `order_id`        bigint unsigned NOT NULL DEFAULT 0 COMMENT 'Shopify订单ID',
+    `order_name`      varchar(50)     NOT NULL DEFAULT '' COMMENT '订单编号（#xxx）',
+    `reason`          varchar(20)     NOT NULL DEFAULT '' COMMENT '理赔原因：lost, stolen, damaged',
+    `description`     varchar(1000)   NOT NULL DEFAULT '' COMMENT '问题描述',
+    `status`          varchar(20)     NOT NULL DEFAULT 'submitted' COMMENT '状态：submitted, reviewing, approved, denied, resolved',
+    `resolution_type` varchar(20)     NOT NULL DEFAULT '' COMMENT '处理方式：refund, store_credit, reship',
+    `claim_amount`    decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '理赔商品金额',
+    `currency`        varchar(10)     NOT NULL DEFAULT '' COMMENT '货币类型',
+    `source`          varchar(20)     NOT NULL DEFAULT '' COMMENT '提交来源',
+    `review_note`     varchar(500)    NOT NULL DEFAULT '' COMMENT '审核备注，拒绝时为拒绝原因',
+    `resolution_note` varchar(500)    NOT NULL DEFAULT '' COMMENT '处理备注',
+    `resolution_status` varchar(20)   NOT NULL DEFAULT '' COMMENT '自动处理状态：pending, succeeded, failed',
+    `resolution_error` varchar(500)   NOT NULL DEFAULT '' COMMENT '自动处理失败原因',
+    `shopify_refund_id` varchar(100)  NOT NULL DEFAULT '' COMMENT 'Shopify退款ID',
+    `shopify_gift_card_id` varchar(100) NOT NULL DEFAULT '' COMMENT 'Shopify礼品卡ID',
+    `gift_card_code` varchar(20)     NOT NULL DEFAULT '' COMMENT '礼品卡代码，创建前保存',
+    `shopify_draft_order_id` varchar(100) NOT NULL DEFAULT '' COMMENT '补发草稿订单ID',
+    `shopify_reship_order_id` varchar(100) NOT NULL DEFAULT '' COMMENT '补发订单ID',
+    `reviewed_at`     bigint unsigned NOT NULL DEFAULT 0 COMMENT '审核完成时间',
+    `resolved_at`     bigint unsigned NOT NULL DEFAULT 0 COMMENT '处理完成时间',
+    `create_time`     bigint unsigned NOT NULL COMMENT '创建时间',
+    `update_time`     bigint unsigned NOT NULL COMMENT '修改时间',
+    `open_order_id`   bigint unsigned GENERATED ALWAYS AS (IF(`status` IN ('denied', 'resolved'), NULL, `user_order_id`)) VIRTUAL COMMENT '处理中的理赔对应的订单ID，结束后为NULL，保证同一订单只有一个处理中的理赔',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_status` (`user_id`, `status`),
+    KEY `idx_user_order` (`user_id`, `user_order_id`),
+    UNIQUE KEY `uk_open_order` (`open_order_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='保险理赔表';
+
+-- 理赔商品表
+CREATE TABLE `order_claim_item`
+(
+    `id`                 bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `claim_id`           bigint unsigned NOT NULL COMMENT '理赔ID',
+    `user_order_info_id` bigint unsigned NOT NULL COMMENT '订单详情ID',
+    `variant_id`         bigint unsigned NOT NULL DEFAULT 0 COMMENT '变体ID',
+    `sku`                varchar(100)    NOT NULL DEFAULT '' COMMENT 'SKU',
+    `variant_title`      varchar(255)    NOT NULL DEFAULT '' COMMENT '变体标题',
+    `quantity`           int             NOT NULL DEFAULT 0 COMMENT '理赔数量',
+    `unit_price_amount`  decimal(12, 2)  NOT NULL DEFAULT 0.00 COMMENT '单价金额',
+    `create_time`        bigint unsigned NOT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_claim_id` (`claim_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='理赔商品表';
+
+-- 理赔凭证表
+CREATE TABLE `order_claim_evidence`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',
+    `claim_id`    bigint unsigned NOT NULL COMMENT '理赔ID',
+    `url`         varchar(500)    NOT NULL DEFAULT '' COMMENT '文件地址',
+    `create_time` bigint unsigned NOT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_claim_id` (`claim_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='理赔凭证表';
@@ -70,6 +70,8 @@ func main() {
 		AppMiddleware:      middleware.NewAppMiddleware(services.AppService, repos.JwtRepo, appConf.JWT),
 		AuthWare:           middleware.NewAuthWare(services.UserService, services.AppService, repos),
 		ShopifyGraphqlWare: middleware.NewShopifyGraphqlWare(repos, services.UserService),
+		PlanWare:           middleware.NewPlanWare(services.PlanService),
+		RateLimitWare:      middleware.NewRateLimitWare(repos.RateLimitRepo),
 	}
 	// 初始化路由规则
 	router := gin.New()
@@ -0,0 +1,61 @@
+package main
+
+// 一次性命令：按活跃订阅回填 user.plans，套餐目录上线前订阅的商家落到对应的套餐。
+// 没有活跃订阅或匹配不到套餐的商家保持默认套餐。
+//
+//	go run ./cmd/backfillplans -dry-run
+//	go run ./cmd/backfillplans -user 123
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"backend/internal/application/users"
+	"backend/internal/infras/config"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只统计需要更新的商家，不写入数据库")
+	userID := flag.Int64("user", 0, "只处理指定用户，默认处理所有有活跃订阅的用户")
+	flag.Parse()
+
+	// 初始化配置
+	appConf := config.InitAppConfig()
+
+	// 日志初始化
+	logger.Default(
+		logger.WriteToFile(true),
+		logger.WithStdout(true),
+		logger.WithAddCaller(true),
+		logger.WithLogLevel(appConf.GetLogLevel()),
+		logger.WithLogFilename("backfillplans.log"),
+	)
+
+	// 初始化依赖
+	db, err := config.NewDB("db_conf")
+	if err != nil {
+		log.Fatalf("db init error:%v", err)
+	}
+
+	redisClient, err := config.NewRedis("redis_conf")
+	if err != nil {
+		log.Fatalf("redis init error:%v", err)
+	}
+
+	repos := providers.NewRepositories(db, redisClient, appConf)
+	service := users.NewSubscriptionService(repos)
+
+	updated, err := service.BackfillPlans(context.Background(), *userID, *dryRun)
+	if err != nil {
+		log.Fatalf("backfill plans error:%v", err)
+	}
+	if *dryRun {
+		fmt.Printf("dry run finished, %d users need update\n", updated)
+		return
+	}
+	fmt.Printf("backfill plans finished, %d users updated\n", updated)
+}
@@ -14,6 +14,7 @@ import (
 
 	"backend/internal/application"
 	"backend/internal/infras/config"
+	"backend/internal/infras/task"
 	"backend/internal/interfaces/job/handler"
 	"backend/internal/interfaces/job/tasks"
 	"backend/internal/providers"
@@ -54,8 +55,15 @@ func main() {
 		log.Fatalf("asynq server init error:%v", err)
 	}
 
+	// 任务处理过程中需要继续推送任务（如抽成结算）
+	asynqClient, err := config.NewAsynqClient("redis_conf")
+	if err != nil {
+		log.Fatalf("asynq client init error:%v", err)
+	}
+	defer asynqClient.Close()
+
 	// 初始化业务组件
-	repos := providers.NewRepositories(db, redisClient, appConf)
+	repos := providers.NewRepositories(db, redisClient, appConf, providers.WithAsynqRepo(asynqClient))
 	services := application.NewServices(repos)
 	handlers := handler.InitHanders(services)
 
@@ -63,6 +71,45 @@ func main() {
 	mux := asynq.NewServeMux()
 	tasks.InitTask(mux, handlers)
 
+	// 定时任务：多个 job 实例通过 redis 选出一个 leader 运行调度器
+	schedules, err := task.Schedules(&appConf.Scheduler)
+	if err != nil {
+		log.Fatalf("scheduler config error:%v", err)
+	}
+	scheduleLoc, err := task.ScheduleLocation(&appConf.Scheduler)
+	if err != nil {
+		log.Fatalf("scheduler location error:%v", err)
+	}
+	leader := task.NewLeader(redisClient, appConf.Scheduler.LeaderTTL)
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		leader.Run(leaderCtx, func(ctx context.Context) error {
+			scheduler, err := config.NewAsynqScheduler("redis_conf", &asynq.SchedulerOpts{
+				Location:        scheduleLoc,
+				PostEnqueueFunc: task.RecordScheduleRun(redisClient),
+			})
+			if err != nil {
+				return err
+			}
+			if err := task.RegisterSchedules(scheduler, schedules); err != nil {
+				return err
+			}
+			if err := scheduler.Start(); err != nil {
+				return err
+			}
+			log.Printf("⏰ Scheduler started on %s", leader.ID())
+			<-ctx.Done()
+			scheduler.Shutdown()
+			return nil
+		})
+	}()
+	defer func() {
+		stopLeader()
+		<-leaderDone
+	}()
+
 	// 设置信号处理
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,66 @@
+package main
+
+// 一次性命令：按订阅计费周期重新划分历史的 commission_bill 和 billing_period_summary。
+// 执行前先停掉 job 进程，避免和正在处理的订单同时修改账期汇总。
+//
+//	go run ./cmd/rebucket -dry-run
+//	go run ./cmd/rebucket -user 123
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"backend/internal/application/jobs"
+	"backend/internal/infras/config"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只计算新的账期，不写入数据库")
+	userID := flag.Int64("user", 0, "只处理指定用户，默认处理所有有账单的用户")
+	flag.Parse()
+
+	// 初始化配置
+	appConf := config.InitAppConfig()
+
+	// 日志初始化
+	logger.Default(
+		logger.WriteToFile(true),
+		logger.WithStdout(true),
+		logger.WithAddCaller(true),
+		logger.WithLogLevel(appConf.GetLogLevel()),
+		logger.WithLogFilename("rebucket.log"),
+	)
+
+	// 初始化依赖
+	db, err := config.NewDB("db_conf")
+	if err != nil {
+		log.Fatalf("db init error:%v", err)
+	}
+
+	redisClient, err := config.NewRedis("redis_conf")
+	if err != nil {
+		log.Fatalf("redis init error:%v", err)
+	}
+
+	repos := providers.NewRepositories(db, redisClient, appConf)
+	service := jobs.NewBillingPeriodService(repos)
+
+	results, err := service.RebucketAll(context.Background(), *userID, *dryRun)
+	for _, result := range results {
+		fmt.Printf("user %d: bills=%d adjustments=%d periods=[%s]\n",
+			result.UserID, result.Bills, result.Adjustments, strings.Join(result.Periods, ", "))
+	}
+	if err != nil {
+		log.Fatalf("rebucket error:%v", err)
+	}
+	if *dryRun {
+		fmt.Printf("dry run finished, %d users checked\n", len(results))
+		return
+	}
+	fmt.Printf("rebucket finished, %d users updated\n", len(results))
+}
@@ -0,0 +1,60 @@
+package main
+
+// 一次性命令：按店铺时区重新计算已有的每日订单统计（order_summary）。
+// 只推送迁移任务，由 job 进程逐批执行，先部署同步 iana_timezone 的版本再执行。
+//
+//	go run ./cmd/resummary
+//	go run ./cmd/resummary -user 123
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"backend/internal/infras/config"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+)
+
+func main() {
+	userID := flag.Int64("user", 0, "只处理指定用户，默认处理所有用户")
+	flag.Parse()
+
+	// 初始化配置
+	appConf := config.InitAppConfig()
+
+	// 日志初始化
+	logger.Default(
+		logger.WriteToFile(true),
+		logger.WithStdout(true),
+		logger.WithAddCaller(true),
+		logger.WithLogLevel(appConf.GetLogLevel()),
+		logger.WithLogFilename("resummary.log"),
+	)
+
+	// 初始化依赖
+	db, err := config.NewDB("db_conf")
+	if err != nil {
+		log.Fatalf("db init error:%v", err)
+	}
+
+	redisClient, err := config.NewRedis("redis_conf")
+	if err != nil {
+		log.Fatalf("redis init error:%v", err)
+	}
+
+	asynqClient, err := config.NewAsynqClient("redis_conf")
+	if err != nil {
+		log.Fatalf("asynq client init error:%v", err)
+	}
+	defer asynqClient.Close()
+
+	repos := providers.NewRepositories(db, redisClient, appConf, providers.WithAsynqRepo(asynqClient))
+
+	info, err := repos.AsyncRepo.SummaryMigrateTask(context.Background(), *userID, 0)
+	if err != nil {
+		log.Fatalf("resummary enqueue error:%v", err)
+	}
+	fmt.Printf("summary migrate task %s enqueued\n", info.ID)
+}
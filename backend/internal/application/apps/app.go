@@ -2,13 +2,13 @@ package apps
 
 import (
 	"context"
-	"fmt"
 
 	appEntity "backend/internal/domain/entity/apps"
 	appRepo "backend/internal/domain/repo/apps"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/providers"
 	"backend/pkg/ctxkeys"
+	"backend/pkg/logger"
 )
 
 type AppService struct {
@@ -28,14 +28,17 @@ func (a *AppService) GetAppID(ctx context.Context) string {
 	return ctx.Value(ctxkeys.AppData).(*appEntity.AppData).AppID
 }
 
+// VerifyWebhook 验证 webhook 签名，应用没有配置 Secret 时验证失败
 func (a *AppService) VerifyWebhook(ctx context.Context, signature string, body []byte) bool {
 	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
-	appSecret := appData.AppSecret
-	// 从配置中获取 webhook secret
-	if appSecret == "" {
-		fmt.Println("警告: Webhook secret 未配置，跳过签名验证")
-		return true // 开发环境可以跳过验证
+	if len(appData.AppSecrets) == 0 {
+		logger.Error(ctx, "应用未配置 Secret，拒绝 webhook", appData.AppID)
 	}
+	return a.shopifyRepo.VerifyWebhook(ctx, appData.AppSecrets, signature, body)
+}
 
-	return a.shopifyRepo.VerifyWebhook(ctx, appSecret, signature, body)
+// VerifyReturnUrl 验证支付回调地址中的签名
+func (a *AppService) VerifyReturnUrl(ctx context.Context, userID int64, sign string) bool {
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	return a.shopifyRepo.VerifyReturnUrl(ctx, appData.AppSecrets, appData.AppID, userID, sign)
 }
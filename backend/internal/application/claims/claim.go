@@ -0,0 +1,303 @@
+package claims
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	claimEntity "backend/internal/domain/entity/claims"
+	orderEntity "backend/internal/domain/entity/orders"
+	"backend/internal/domain/repo"
+	claimRepo "backend/internal/domain/repo/claims"
+	"backend/internal/domain/repo/jobs"
+	"backend/internal/domain/repo/orders"
+	"backend/internal/domain/repo/shopifys"
+	"backend/internal/domain/repo/users"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+)
+
+type ClaimService struct {
+	claimRepo        claimRepo.ClaimRepository
+	orderRepo        orders.OrderRepository
+	orderInfoRepo    orders.OrderInfoRepository
+	userRepo         users.UserRepository
+	orderGraphqlRepo shopifys.OrderGraphqlRepository
+	ossRepo          repo.AliyunOSSRepository
+	asynqRepo        jobs.AsynqRepository
+	txRepo           repo.TransactionRepository
+}
+
+func NewClaimService(repos *providers.Repositories) *ClaimService {
+	return &ClaimService{
+		claimRepo:        repos.ClaimRepo,
+		orderRepo:        repos.OrderRepo,
+		orderInfoRepo:    repos.OrderInfoRep,
+		userRepo:         repos.UserRepo,
+		orderGraphqlRepo: repos.OrderGraphqlRepo,
+		ossRepo:          repos.AliyunOssRepo,
+		asynqRepo:        repos.AsyncRepo,
+		txRepo:           repos.TransactionRepo,
+	}
+}
+
+// Submit 提交理赔，订单必须包含保险商品，同一订单同时只能有一个处理中的理赔
+func (s *ClaimService) Submit(ctx context.Context, userID int64, source string, req *claimEntity.ClaimSubmitReq) (*claimEntity.OrderClaim, error) {
+	order, err := s.orderRepo.First(ctx, userID, req.UserOrderId)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, claimEntity.ErrOrderNotFound
+	}
+	infos, err := s.orderInfoRepo.GetByUserOrderId(ctx, order.Id, userID)
+	if err != nil {
+		return nil, err
+	}
+	items, amount, err := claimItems(infos, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &claimEntity.OrderClaim{
+		UserId:      userID,
+		UserOrderId: order.Id,
+		OrderId:     order.OrderId,
+		OrderName:   order.OrderName,
+		Reason:      req.Reason,
+		Description: req.Description,
+		Status:      claimEntity.StatusSubmitted,
+		ClaimAmount: amount,
+		Currency:    order.Currency,
+		Source:      source,
+	}
+	evidence := make([]*claimEntity.OrderClaimEvidence, 0, len(req.Evidence))
+	for _, url := range req.Evidence {
+		evidence = append(evidence, &claimEntity.OrderClaimEvidence{Url: url})
+	}
+
+	err = s.txRepo.Transaction(ctx, func(ctx context.Context) error {
+		// 并发提交时两个事务都可能查不到处理中的理赔，由 open_order_id 唯一键拦截后写入的一个
+		open, err := s.claimRepo.HasOpen(ctx, userID, order.Id)
+		if err != nil {
+			return err
+		}
+		if open {
+			return claimEntity.ErrClaimExists
+		}
+		return s.claimRepo.Create(ctx, claim, items, evidence)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// claimableInfos 订单中还有未退款数量的非保险商品，订单没有保险商品时返回 ErrOrderNotClaimable
+func claimableInfos(infos []*orderEntity.UserOrderInfo) ([]*orderEntity.UserOrderInfo, error) {
+	protected := false
+	claimable := make([]*orderEntity.UserOrderInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.IsProtectify == 1 {
+			protected = true
+			continue
+		}
+		if info.Quantity > info.RefundNum {
+			claimable = append(claimable, info)
+		}
+	}
+	if !protected {
+		return nil, claimEntity.ErrOrderNotClaimable
+	}
+	return claimable, nil
+}
+
+// claimItems 校验理赔商品并计算理赔金额，保险商品本身不能理赔，不传商品时理赔全部未退款商品
+func claimItems(infos []*orderEntity.UserOrderInfo, reqItems []claimEntity.ClaimItemReq) ([]*claimEntity.OrderClaimItem, float64, error) {
+	claimableList, err := claimableInfos(infos)
+	if err != nil {
+		return nil, 0, err
+	}
+	claimable := make(map[int64]*orderEntity.UserOrderInfo, len(claimableList))
+	for _, info := range claimableList {
+		claimable[info.Id] = info
+	}
+
+	if len(reqItems) == 0 {
+		for _, info := range claimableList {
+			reqItems = append(reqItems, claimEntity.ClaimItemReq{OrderInfoId: info.Id, Quantity: info.Quantity - info.RefundNum})
+		}
+	}
+	if len(reqItems) == 0 {
+		return nil, 0, claimEntity.ErrNoClaimableItems
+	}
+
+	amount := decimal.Zero
+	items := make([]*claimEntity.OrderClaimItem, 0, len(reqItems))
+	for _, req := range reqItems {
+		info, ok := claimable[req.OrderInfoId]
+		if !ok || req.Quantity > info.Quantity-info.RefundNum {
+			return nil, 0, claimEntity.ErrNoClaimableItems
+		}
+		// 同一商品只能出现一次
+		delete(claimable, req.OrderInfoId)
+		items = append(items, &claimEntity.OrderClaimItem{
+			UserOrderInfoId: info.Id,
+			VariantId:       info.VariantId,
+			Sku:             info.Sku,
+			VariantTitle:    info.VariantTitle,
+			Quantity:        req.Quantity,
+			UnitPriceAmount: info.UnitPriceAmount,
+		})
+		amount = amount.Add(decimal.NewFromFloat(info.UnitPriceAmount).Mul(decimal.NewFromInt(int64(req.Quantity))))
+	}
+	return items, amount.Round(2).InexactFloat64(), nil
+}
+
+// List 商家的理赔列表
+func (s *ClaimService) List(ctx context.Context, req *claimEntity.ClaimListReq) (*claimEntity.ClaimListResponse, error) {
+	list, total, err := s.claimRepo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = make([]*claimEntity.OrderClaim, 0)
+	}
+	return &claimEntity.ClaimListResponse{List: list, Total: total}, nil
+}
+
+// Detail 理赔详情，包括订单、理赔商品和凭证
+func (s *ClaimService) Detail(ctx context.Context, userID int64, claimID int64) (*claimEntity.ClaimDetail, error) {
+	claim, err := s.claimRepo.First(ctx, userID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, claimEntity.ErrClaimNotFound
+	}
+	order, err := s.orderRepo.First(ctx, userID, claim.UserOrderId)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.claimRepo.Items(ctx, claim.Id)
+	if err != nil {
+		return nil, err
+	}
+	evidence, err := s.claimRepo.Evidence(ctx, claim.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &claimEntity.ClaimDetail{Claim: claim, Order: order, Items: items, Evidence: evidence}, nil
+}
+
+// Review 开始审核、通过或拒绝理赔，通过时记录处理方式并推送队列在 Shopify 上处理
+func (s *ClaimService) Review(ctx context.Context, userID int64, req *claimEntity.ClaimReviewReq) (*claimEntity.OrderClaim, error) {
+	claim, err := s.claimRepo.First(ctx, userID, req.ClaimId)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, claimEntity.ErrClaimNotFound
+	}
+
+	var to string
+	columns := []string{"review_note"}
+	switch req.Action {
+	case "start":
+		to = claimEntity.StatusReviewing
+	case "approve":
+		if req.ResolutionType == "" {
+			return nil, claimEntity.ErrResolutionType
+		}
+		to = claimEntity.StatusApproved
+		claim.ResolutionType = req.ResolutionType
+		claim.ResolutionStatus = claimEntity.ResolutionStatusPending
+		claim.ReviewedAt = time.Now().Unix()
+		columns = append(columns, "resolution_type", "resolution_status", "reviewed_at")
+	case "deny":
+		to = claimEntity.StatusDenied
+		claim.ReviewedAt = time.Now().Unix()
+		columns = append(columns, "reviewed_at")
+	}
+	claim.ReviewNote = req.Note
+	if err := s.transit(ctx, claim, to, columns...); err != nil {
+		return nil, err
+	}
+	if to == claimEntity.StatusApproved {
+		s.enqueueResolve(ctx, claim)
+	}
+	return claim, nil
+}
+
+// RetryResolution 重新发起 Shopify 处理，只有处理失败的理赔可以重新发起
+func (s *ClaimService) RetryResolution(ctx context.Context, userID int64, claimID int64) (*claimEntity.OrderClaim, error) {
+	claim, err := s.claimRepo.First(ctx, userID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, claimEntity.ErrClaimNotFound
+	}
+	// pending 的理赔可能还在处理中，重复推送会并发调用 Shopify
+	if claim.Status != claimEntity.StatusApproved || claim.ResolutionStatus != claimEntity.ResolutionStatusFailed {
+		return nil, claimEntity.ErrClaimStatus
+	}
+	claim.ResolutionStatus = claimEntity.ResolutionStatusPending
+	claim.ResolutionError = ""
+	updated, err := s.claimRepo.UpdateResolutionStatus(ctx, claim, claimEntity.ResolutionStatusFailed, "resolution_status", "resolution_error")
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, claimEntity.ErrClaimStatus
+	}
+	s.enqueueResolve(ctx, claim)
+	return claim, nil
+}
+
+// enqueueResolve 每个理赔同时只有一个处理任务，任务已存在时不重复推送
+func (s *ClaimService) enqueueResolve(ctx context.Context, claim *claimEntity.OrderClaim) {
+	if _, err := s.asynqRepo.ClaimResolveTask(ctx, claim.Id); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		logger.Error(ctx, "推送理赔处理任务失败", err)
+	}
+}
+
+// Resolve 手动标记已通过的理赔处理完成，Shopify 处理中或已成功时不能手动处理
+func (s *ClaimService) Resolve(ctx context.Context, userID int64, req *claimEntity.ClaimResolveReq) (*claimEntity.OrderClaim, error) {
+	claim, err := s.claimRepo.First(ctx, userID, req.ClaimId)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, claimEntity.ErrClaimNotFound
+	}
+	if claim.ResolutionStatus == claimEntity.ResolutionStatusPending || claim.ResolutionStatus == claimEntity.ResolutionStatusSucceeded {
+		return nil, claimEntity.ErrClaimStatus
+	}
+	claim.ResolutionNote = req.Note
+	claim.ResolvedAt = time.Now().Unix()
+	if err := s.transit(ctx, claim, claimEntity.StatusResolved, "resolution_note", "resolved_at"); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// transit 校验状态流转后更新理赔，更新时状态已被其他请求修改则失败
+func (s *ClaimService) transit(ctx context.Context, claim *claimEntity.OrderClaim, to string, columns ...string) error {
+	if !claim.CanTransit(to) {
+		return claimEntity.ErrClaimStatus
+	}
+	from := claim.Status
+	claim.Status = to
+	updated, err := s.claimRepo.UpdateStatus(ctx, claim, from, columns...)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return claimEntity.ErrClaimStatus
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package claims
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+
+	"github.com/google/uuid"
+
+	appEntity "backend/internal/domain/entity/apps"
+	claimEntity "backend/internal/domain/entity/claims"
+	orderEntity "backend/internal/domain/entity/orders"
+	userEntity "backend/internal/domain/entity/users"
+	"backend/internal/infras/shopify_graphql"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+const (
+	// portalMaxImageSize 理赔图片最大 5MB
+	portalMaxImageSize = 5 << 20
+	// portalEvidenceDir 理赔图片按店铺保存，提交时只接受本店铺目录下的图片
+	portalEvidenceDir = "claims/%d/"
+)
+
+// PortalLookup 客户通过订单编号和下单邮箱查询可以理赔的商品
+func (s *ClaimService) PortalLookup(ctx context.Context, appID string, req *claimEntity.PortalOrderReq) (*claimEntity.PortalOrder, error) {
+	user, err := s.portalShop(ctx, appID, req.Shop)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.portalOrder(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.orderInfoRepo.GetByUserOrderId(ctx, order.Id, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	claimable, err := claimableInfos(infos)
+	if err != nil {
+		return nil, err
+	}
+	open, err := s.claimRepo.HasOpen(ctx, user.ID, order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &claimEntity.PortalOrder{
+		OrderName: order.OrderName,
+		Currency:  order.Currency,
+		Items:     make([]*claimEntity.PortalItem, 0, len(claimable)),
+		OpenClaim: open,
+	}
+	for _, info := range claimable {
+		resp.Items = append(resp.Items, &claimEntity.PortalItem{
+			OrderInfoId:     info.Id,
+			Sku:             info.Sku,
+			VariantTitle:    info.VariantTitle,
+			Quantity:        info.Quantity - info.RefundNum,
+			UnitPriceAmount: info.UnitPriceAmount,
+		})
+	}
+	return resp, nil
+}
+
+// PortalUpload 上传理赔图片，返回提交理赔时使用的图片地址
+func (s *ClaimService) PortalUpload(ctx context.Context, appID string, shop string, file *multipart.FileHeader) (string, error) {
+	user, err := s.portalShop(ctx, appID, shop)
+	if err != nil {
+		return "", err
+	}
+	if file.Size > portalMaxImageSize {
+		return "", claimEntity.ErrInvalidEvidence
+	}
+	// 按文件内容判断类型，客户端的 Content-Type 可以随意填写
+	head, err := readFileHead(file)
+	if err != nil {
+		return "", err
+	}
+	contentType, ok := claimEntity.DetectImageType(head)
+	if !ok {
+		return "", claimEntity.ErrInvalidEvidence
+	}
+	ext := claimEntity.EvidenceImageTypes[contentType]
+	// 不使用客户的文件名，避免覆盖和路径穿越
+	objectKey := fmt.Sprintf(portalEvidenceDir, user.ID) + uuid.New().String() + ext
+	return s.ossRepo.UploadFile(ctx, objectKey, file)
+}
+
+// readFileHead 读取文件开头用于判断类型的 512 字节
+func readFileHead(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// PortalSubmit 客户提交理赔，返回查询理赔进度的凭证
+func (s *ClaimService) PortalSubmit(ctx context.Context, appData *appEntity.AppData, req *claimEntity.PortalSubmitReq) (*claimEntity.PortalSubmitResp, error) {
+	user, err := s.portalShop(ctx, appData.AppID, req.Shop)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.portalOrder(ctx, user, &req.PortalOrderReq)
+	if err != nil {
+		return nil, err
+	}
+	evidenceDir := s.ossRepo.ObjectURL(fmt.Sprintf(portalEvidenceDir, user.ID))
+	for _, url := range req.Evidence {
+		if !strings.HasPrefix(url, evidenceDir) {
+			return nil, claimEntity.ErrInvalidEvidence
+		}
+	}
+
+	claim, err := s.Submit(ctx, user.ID, claimEntity.SourcePortal, &claimEntity.ClaimSubmitReq{
+		UserOrderId: order.Id,
+		Reason:      req.Reason,
+		Description: req.Description,
+		Items:       req.Items,
+		Evidence:    req.Evidence,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claimEntity.PortalSubmitResp{
+		ClaimId: claim.Id,
+		Status:  claim.Status,
+		Token:   claim.Token(appData.SigningSecret()),
+	}, nil
+}
+
+// PortalStatus 客户凭查询凭证查看理赔进度
+func (s *ClaimService) PortalStatus(ctx context.Context, appData *appEntity.AppData, req *claimEntity.PortalStatusReq) (*claimEntity.PortalStatus, error) {
+	user, err := s.portalShop(ctx, appData.AppID, req.Shop)
+	if err != nil {
+		return nil, err
+	}
+	claimID, err := claimEntity.TokenClaimID(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := s.claimRepo.First(ctx, user.ID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil || !claim.VerifyToken(appData.AppSecrets, req.Token) {
+		return nil, claimEntity.ErrInvalidToken
+	}
+	return &claimEntity.PortalStatus{
+		OrderName:      claim.OrderName,
+		Reason:         claim.Reason,
+		Status:         claim.Status,
+		ResolutionType: claim.ResolutionType,
+		ClaimAmount:    claim.ClaimAmount,
+		Currency:       claim.Currency,
+		ReviewNote:     claim.ReviewNote,
+		CreateTime:     claim.CreateTime,
+		ReviewedAt:     claim.ReviewedAt,
+		ResolvedAt:     claim.ResolvedAt,
+	}, nil
+}
+
+// portalShop 理赔页面所属的店铺，店铺卸载后不能再提交理赔
+func (s *ClaimService) portalShop(ctx context.Context, appID string, shop string) (*userEntity.User, error) {
+	user, err := s.userRepo.GetActiveUserByShop(ctx, appID, shop)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, claimEntity.ErrShopNotFound
+	}
+	return user, nil
+}
+
+// portalOrder 按订单编号查询订单并校验下单邮箱，编号或邮箱不对都返回订单不存在
+func (s *ClaimService) portalOrder(ctx context.Context, user *userEntity.User, req *claimEntity.PortalOrderReq) (*orderEntity.UserOrder, error) {
+	orderName := strings.TrimSpace(req.OrderName)
+	if !strings.HasPrefix(orderName, "#") {
+		orderName = "#" + orderName
+	}
+	order, err := s.orderRepo.FirstByName(ctx, user.ID, orderName)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, claimEntity.ErrOrderNotFound
+	}
+
+	email := order.Email
+	if email == "" {
+		// 保存邮箱之前同步的订单，从 Shopify 拉取一次邮箱
+		shopName, _ := utils.GetShopName(user.Shop)
+		ctx = shopify_graphql.ContextWithClient(ctx, shopify_graphql.NewGraphqlClient(shopName, user.AccessToken))
+		data, err := s.orderGraphqlRepo.GetOrderInfo(ctx, order.OrderId)
+		if err != nil {
+			return nil, fmt.Errorf("拉取Shopify订单信息失败: %w", err)
+		}
+		email = data.Order.Email
+		if email != "" {
+			if err := s.orderRepo.UpdateEmail(ctx, order.Id, email); err != nil {
+				logger.Error(ctx, "claim portal 保存订单邮箱失败", err)
+			}
+		}
+	}
+	if email == "" || !strings.EqualFold(strings.TrimSpace(email), strings.TrimSpace(req.Email)) {
+		return nil, claimEntity.ErrOrderNotFound
+	}
+	return order, nil
+}
@@ -15,9 +15,7 @@ import (
 
 	shopifyEntity "backend/internal/domain/entity/shopifys"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
-	"backend/internal/infras/shopify_graphql"
 	"backend/internal/providers"
-	"backend/pkg/ctxkeys"
 	"backend/pkg/logger"
 	"backend/pkg/utils"
 )
@@ -34,8 +32,6 @@ func NewFileService(repos *providers.Repositories) *FileService {
 
 // UploadProductImageToShopify 上传文件到 Shopify
 func (s *FileService) UploadProductImageToShopify(ctx context.Context, fileHeader *multipart.FileHeader, altText string) (*shopifyEntity.ImageMedia, error) {
-	// 从上下文获取 Shopify GraphQL 客户端
-	client := ctx.Value(ctxkeys.ShopifyGraphqlClient).(*shopify_graphql.GraphqlClient)
 	// 1. 获取文件基本信息
 	file, err := fileHeader.Open()
 	if err != nil {
@@ -58,7 +54,6 @@ func (s *FileService) UploadProductImageToShopify(ctx context.Context, fileHeade
 		HttpMethod: "POST",
 	}
 	fmt.Println("stagedInput:", zap.Any("stagedInput", stagedInput))
-	s.productGraphqlRepo.WithClient(client)
 
 	stagedTargets, err := s.productGraphqlRepo.StagedUploadsCreate(ctx, stagedInput)
 	if err != nil {
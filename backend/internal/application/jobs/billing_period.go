@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/orders"
+	userEntity "backend/internal/domain/entity/users"
+	billingsRepo "backend/internal/domain/repo/billings"
+	orderRepo "backend/internal/domain/repo/orders"
+	"backend/internal/domain/repo/users"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// BillingPeriodService 按订阅计费周期重新划分历史账单和账期汇总
+type BillingPeriodService struct {
+	commissionBillRepo billingsRepo.CommissionBillRepository
+	adjustmentRepo     billingsRepo.CommissionAdjustmentRepository
+	billingPeriodRepo  billingsRepo.BillingPeriodSummaryRepository
+	subscriptionRepo   users.UserSubscriptionRepository
+	orderRepo          orderRepo.OrderRepository
+}
+
+func NewBillingPeriodService(repos *providers.Repositories) *BillingPeriodService {
+	return &BillingPeriodService{
+		commissionBillRepo: repos.CommissionBillRepo,
+		adjustmentRepo:     repos.CommissionAdjustmentRepo,
+		billingPeriodRepo:  repos.BillingPeriodSummaryRepo,
+		subscriptionRepo:   repos.UserSubscriptionRepo,
+		orderRepo:          repos.OrderRepo,
+	}
+}
+
+// RebucketResult 单个用户重新划分账期的结果
+type RebucketResult struct {
+	UserID      int64
+	Bills       int
+	Adjustments int
+	Periods     []string
+}
+
+// periodTotals 账期汇总的累加值，全部用 decimal 计算避免浮点误差
+type periodTotals struct {
+	summary    *billings.BillingPeriodSummary
+	commission decimal.Decimal
+	pending    decimal.Decimal
+	paid       decimal.Decimal
+	failed     decimal.Decimal
+	adjustment decimal.Decimal
+	credit     decimal.Decimal
+	protectify decimal.Decimal
+	order      decimal.Decimal
+	refund     decimal.Decimal
+}
+
+// RebucketAll 重新划分所有有抽成账单的用户，userID 大于0时只处理该用户
+func (b *BillingPeriodService) RebucketAll(ctx context.Context, userID int64, dryRun bool) ([]*RebucketResult, error) {
+	if userID > 0 {
+		result, err := b.Rebucket(ctx, userID, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		return []*RebucketResult{result}, nil
+	}
+
+	results := make([]*RebucketResult, 0)
+	var lastUserID int64
+	for {
+		userIDs, err := b.commissionBillRepo.BilledUserIDs(ctx, lastUserID, 100)
+		if err != nil {
+			return results, fmt.Errorf("查询账单用户失败: %w", err)
+		}
+		if len(userIDs) == 0 {
+			return results, nil
+		}
+		for _, id := range userIDs {
+			result, err := b.Rebucket(ctx, id, dryRun)
+			if err != nil {
+				// 单个用户失败不影响其它用户，失败的用户可以单独重跑
+				logger.Error(ctx, fmt.Sprintf("billing_period_rebucket: 用户 %d 重新划分账期失败", id), err)
+				continue
+			}
+			results = append(results, result)
+		}
+		lastUserID = userIDs[len(userIDs)-1]
+	}
+}
+
+// Rebucket 按账单所属订阅的计费周期重新计算已确认账单和调整流水的账期，并重建账期汇总。
+// 账单按确认时间划分，调整流水按创建时间划分，dryRun 时只计算不落库。
+func (b *BillingPeriodService) Rebucket(ctx context.Context, userID int64, dryRun bool) (*RebucketResult, error) {
+	subscriptions, err := b.subscriptionRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户订阅信息失败: %w", err)
+	}
+	subscriptionMap := make(map[int64]*userEntity.UserSubscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscriptionMap[subscription.ID] = subscription
+	}
+	bills, err := b.commissionBillRepo.RecognizedBills(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询已确认账单失败: %w", err)
+	}
+	adjustments, err := b.adjustmentRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询调整流水失败: %w", err)
+	}
+
+	orderIDs := make([]int64, 0, len(bills))
+	for _, bill := range bills {
+		orderIDs = append(orderIDs, bill.UserOrderId)
+	}
+	orderList, err := b.orderRepo.GetByIDs(ctx, userID, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("查询账单订单失败: %w", err)
+	}
+	orderMap := make(map[int64]*orders.UserOrder, len(orderList))
+	for _, order := range orderList {
+		orderMap[order.Id] = order
+	}
+
+	// 确认前就冲减掉的金额不计入账期抽成总额，和确认时的处理保持一致
+	deferredDeducted := make(map[int64]decimal.Decimal)
+	for _, adjustment := range adjustments {
+		if adjustment.BillingPeriodEnd == 0 {
+			deferredDeducted[adjustment.BillId] = deferredDeducted[adjustment.BillId].Add(decimal.NewFromFloat(adjustment.AbsorbedAmount))
+		}
+	}
+
+	now := time.Now().Unix()
+	totals := make(map[billings.PeriodKey]*periodTotals)
+	periodTotalsOf := func(period billings.BillingPeriod, subscriptionID int64, currency string) *periodTotals {
+		key := billings.PeriodKey{UserId: userID, SubscriptionId: subscriptionID, BillCycle: period.BillCycle}
+		if t, ok := totals[key]; ok {
+			return t
+		}
+		summary := &billings.BillingPeriodSummary{
+			UserId:             userID,
+			SubscriptionId:     subscriptionID,
+			BillingPeriodStart: period.Start,
+			BillingPeriodEnd:   period.End,
+			BillCycle:          period.BillCycle,
+			BusinessMonth:      period.BusinessMonth,
+			Currency:           currency,
+			SummaryStatus:      billings.SummaryStatusOpen,
+			Version:            1,
+		}
+		if period.End <= now {
+			summary.SummaryStatus = billings.SummaryStatusClosed
+		}
+		if subscription, ok := subscriptionMap[subscriptionID]; ok {
+			summary.ShopDomain = subscription.ShopDomain
+			if subscription.TestSubscription {
+				summary.IsTestPeriod = 1
+			}
+		}
+		t := &periodTotals{summary: summary}
+		totals[key] = t
+		return t
+	}
+
+	billMap := make(map[int64]*billings.CommissionBill, len(bills))
+	for _, bill := range bills {
+		billMap[bill.Id] = bill
+		recognizedAt := bill.RecognizedAt
+		if recognizedAt == 0 {
+			recognizedAt = bill.CreateTime
+		}
+		// 按创建账单时的订阅计算账期，换过套餐的用户历史账单不受新订阅周期影响
+		subscription, ok := subscriptionMap[bill.SubscriptionId]
+		if !ok {
+			subscription = subscriptionAt(subscriptions, recognizedAt)
+		}
+		period := billings.CalcBillingPeriod(subscription, time.Unix(recognizedAt, 0))
+		bill.BillingPeriodStart = period.Start
+		bill.BillingPeriodEnd = period.End
+		bill.BillCycle = period.BillCycle
+
+		t := periodTotalsOf(period, bill.SubscriptionId, bill.Currency)
+		t.summary.OrderCount += 1
+		t.summary.BillCount += 1
+		t.commission = t.commission.Add(decimal.NewFromFloat(bill.CommissionAmount)).Sub(deferredDeducted[bill.Id])
+		chargeAmount := decimal.NewFromFloat(bill.ChargeAmount())
+		switch bill.ChargeStatus {
+		case billings.ChargeStatusCharged:
+			t.paid = t.paid.Add(chargeAmount)
+		case billings.ChargeStatusFailed:
+			t.failed = t.failed.Add(chargeAmount)
+		default:
+			t.pending = t.pending.Add(chargeAmount)
+		}
+		t.protectify = t.protectify.Add(decimal.NewFromFloat(bill.OrderProtectifyAmount))
+		t.order = t.order.Add(decimal.NewFromFloat(bill.OrderTotalAmount))
+		if order, ok := orderMap[bill.UserOrderId]; ok {
+			t.refund = t.refund.Add(decimal.NewFromFloat(order.RefundPriceAmount))
+		}
+	}
+
+	rebucketed := make([]*billings.CommissionAdjustment, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		if adjustment.BillingPeriodEnd == 0 {
+			continue
+		}
+		// 调整流水计入创建时所在的账期，汇总归属和实时冲减一致使用账单的订阅
+		period := billings.CalcBillingPeriod(subscriptionAt(subscriptions, adjustment.CreateTime), time.Unix(adjustment.CreateTime, 0))
+		var subscriptionID int64
+		if bill, ok := billMap[adjustment.BillId]; ok {
+			subscriptionID = bill.SubscriptionId
+		}
+		adjustment.BillingPeriodEnd = period.End
+		adjustment.SubscriptionId = subscriptionID
+		adjustment.BillCycle = period.BillCycle
+		rebucketed = append(rebucketed, adjustment)
+
+		t := periodTotalsOf(period, subscriptionID, adjustment.Currency)
+		t.adjustment = t.adjustment.Add(decimal.NewFromFloat(adjustment.Amount))
+		if adjustment.Status == billings.AdjustmentStatusCredited {
+			t.credit = t.credit.Add(decimal.NewFromFloat(adjustment.CreditAmount))
+		}
+	}
+
+	summaries := make([]*billings.BillingPeriodSummary, 0, len(totals))
+	for _, t := range totals {
+		t.summary.TotalCommissionAmount = utils.DecimalToFloat(t.commission)
+		t.summary.PendingAmount = utils.DecimalToFloat(t.pending)
+		t.summary.PaidAmount = utils.DecimalToFloat(t.paid)
+		t.summary.ErrorAmount = utils.DecimalToFloat(t.failed)
+		t.summary.AdjustmentAmount = utils.DecimalToFloat(t.adjustment)
+		t.summary.CreditAmount = utils.DecimalToFloat(t.credit)
+		t.summary.TotalProtectifyAmount = utils.DecimalToFloat(t.protectify)
+		t.summary.TotalOrderAmount = utils.DecimalToFloat(t.order)
+		t.summary.TotalRefundAmount = utils.DecimalToFloat(t.refund)
+		t.summary.LastSyncTime = time.Now().Unix()
+		summaries = append(summaries, t.summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].BillingPeriodEnd < summaries[j].BillingPeriodEnd
+	})
+
+	result := &RebucketResult{
+		UserID:      userID,
+		Bills:       len(bills),
+		Adjustments: len(rebucketed),
+		Periods:     make([]string, 0, len(summaries)),
+	}
+	for _, summary := range summaries {
+		result.Periods = append(result.Periods, summary.BillCycle)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	if err := b.billingPeriodRepo.Rebucket(ctx, userID, bills, rebucketed, summaries); err != nil {
+		return nil, fmt.Errorf("保存重新划分的账期失败: %w", err)
+	}
+	logger.Info(ctx, "billing_period_rebucket", fmt.Sprintf("用户 %d 重新划分账期完成，账单 %d 条，账期 %d 个", userID, len(bills), len(summaries)))
+	return result, nil
+}
+
+// subscriptionAt 查询 at 时刻生效的订阅，即在此之前创建的最后一个已生效订阅，没有时返回 nil
+func subscriptionAt(subscriptions []*userEntity.UserSubscription, at int64) *userEntity.UserSubscription {
+	var found *userEntity.UserSubscription
+	for _, subscription := range subscriptions {
+		if subscription.CreateTime > at {
+			break
+		}
+		switch subscription.SubscriptionStatus {
+		case userEntity.SubscriptionStatusPending, userEntity.SubscriptionStatusDeclined:
+			continue
+		}
+		found = subscription
+	}
+	return found
+}
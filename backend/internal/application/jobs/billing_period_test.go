@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/users"
+	billingsRepo "backend/internal/domain/repo/billings"
+	"backend/pkg/logger"
+)
+
+type fakeAdjustmentRepo struct {
+	billingsRepo.CommissionAdjustmentRepository
+	adjustments []*billings.CommissionAdjustment
+}
+
+func (f *fakeAdjustmentRepo) ListByUser(context.Context, int64) ([]*billings.CommissionAdjustment, error) {
+	return f.adjustments, nil
+}
+
+type fakePeriodRepo struct {
+	billingsRepo.BillingPeriodSummaryRepository
+	summaries []*billings.BillingPeriodSummary
+}
+
+func (f *fakePeriodRepo) Rebucket(_ context.Context, _ int64, _ []*billings.CommissionBill, _ []*billings.CommissionAdjustment, summaries []*billings.BillingPeriodSummary) error {
+	f.summaries = summaries
+	return nil
+}
+
+func TestRebucketUsesBillSubscription(t *testing.T) {
+	logger.Default(logger.WriteToFile(false))
+	day := func(month time.Month, d int) int64 {
+		return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC).Unix()
+	}
+	// 先订阅30天套餐，3月换成年付套餐
+	monthly := &users.UserSubscription{
+		ID: 1, SubscriptionStatus: users.SubscriptionStatusCancelled, PricingType: users.PricingTypeRecurring,
+		CurrentPeriodStart: day(time.January, 1), CurrentPeriodEnd: day(time.January, 31), CreateTime: day(time.January, 1),
+	}
+	annual := &users.UserSubscription{
+		ID: 2, SubscriptionStatus: users.SubscriptionStatusActive, PricingType: users.PricingTypeAnnual,
+		CurrentPeriodStart: day(time.March, 1), CurrentPeriodEnd: day(time.March, 1) + int64(billings.AnnualSubscriptionCycle/time.Second),
+		CreateTime: day(time.March, 1),
+	}
+	bills := []*billings.CommissionBill{
+		{Id: 1, SubscriptionId: 1, CommissionAmount: 1, RecognizedAt: day(time.January, 10)},
+		{Id: 2, SubscriptionId: 1, CommissionAmount: 2, RecognizedAt: day(time.February, 5)},
+		{Id: 3, SubscriptionId: 2, CommissionAmount: 3, RecognizedAt: day(time.June, 1)},
+	}
+	adjustments := []*billings.CommissionAdjustment{
+		{Id: 1, UserId: 1, BillId: 1, Amount: -0.5, BillingPeriodEnd: 1, CreateTime: day(time.February, 6)},
+	}
+
+	periodRepo := &fakePeriodRepo{}
+	service := &BillingPeriodService{
+		commissionBillRepo: &fakeCommissionRepo{bills: bills},
+		adjustmentRepo:     &fakeAdjustmentRepo{adjustments: adjustments},
+		billingPeriodRepo:  periodRepo,
+		subscriptionRepo:   &fakeSubscriptionRepo{subscriptions: []*users.UserSubscription{monthly, annual}},
+		orderRepo:          &fakeOrderRepo{},
+	}
+	if _, err := service.Rebucket(context.Background(), 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		subscriptionID int64
+		billCycle      string
+		end            int64
+		commission     float64
+		adjustment     float64
+	}{
+		{1, "2025-01-01", day(time.January, 31), 1, 0},
+		{1, "2025-01-31", day(time.March, 2), 2, -0.5},
+		{2, "2025-03-01", annual.CurrentPeriodEnd, 3, 0},
+	}
+	if len(periodRepo.summaries) != len(want) {
+		t.Fatalf("got %d summaries, want %d", len(periodRepo.summaries), len(want))
+	}
+	for i, w := range want {
+		got := periodRepo.summaries[i]
+		if got.SubscriptionId != w.subscriptionID || got.BillCycle != w.billCycle || got.BillingPeriodEnd != w.end ||
+			got.TotalCommissionAmount != w.commission || got.AdjustmentAmount != w.adjustment {
+			t.Errorf("summary %d = sub %d cycle %s end %d commission %.2f adjustment %.2f, want %+v",
+				i, got.SubscriptionId, got.BillCycle, got.BillingPeriodEnd, got.TotalCommissionAmount, got.AdjustmentAmount, w)
+		}
+	}
+	if bills[2].BillingPeriodEnd != annual.CurrentPeriodEnd {
+		t.Errorf("annual bill period end = %d, want %d", bills[2].BillingPeriodEnd, annual.CurrentPeriodEnd)
+	}
+	// 调整流水记录所在账期的唯一键，返还 app credit 时按它更新汇总
+	if key := adjustments[0].PeriodKey(); key != periodRepo.summaries[1].Key() {
+		t.Errorf("adjustment period key = %+v, want %+v", key, periodRepo.summaries[1].Key())
+	}
+}
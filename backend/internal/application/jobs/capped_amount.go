@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/jobs"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	userEntity "backend/internal/domain/entity/users"
+	appRepo "backend/internal/domain/repo/apps"
+	billingsRepo "backend/internal/domain/repo/billings"
+	cartSettingRepo "backend/internal/domain/repo/carts"
+	jobRepo "backend/internal/domain/repo/jobs"
+	shopifyRepo "backend/internal/domain/repo/shopifys"
+	"backend/internal/domain/repo/users"
+	"backend/internal/infras/shopify_graphql"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// CappedAmountService 订阅用量上限检查，用量用完时关闭购物车保险，上限提高后自动恢复
+type CappedAmountService struct {
+	userRepo           users.UserRepository
+	subscriptionRepo   users.UserSubscriptionRepository
+	commissionBillRepo billingsRepo.CommissionBillRepository
+	cartSettingRepo    cartSettingRepo.CartSettingRepository
+	appAuthRepo        appRepo.AppAuthRepository
+	shopGraphqlRepo    shopifyRepo.ShopGraphqlRepository
+	asynqRepo          jobRepo.AsynqRepository
+	currencyConverter  billingsRepo.CurrencyConverter
+}
+
+func NewCappedAmountService(repos *providers.Repositories) *CappedAmountService {
+	return &CappedAmountService{
+		userRepo:           repos.UserRepo,
+		subscriptionRepo:   repos.UserSubscriptionRepo,
+		commissionBillRepo: repos.CommissionBillRepo,
+		cartSettingRepo:    repos.CartSettingRepo,
+		appAuthRepo:        repos.AppAuthRepo,
+		shopGraphqlRepo:    repos.ShopGraphqlRepo,
+		asynqRepo:          repos.AsyncRepo,
+		currencyConverter:  repos.CurrencyConverter,
+	}
+}
+
+// HandleCappedAmount 检查用户订阅的剩余用量，决定暂停还是恢复购物车保险
+func (c *CappedAmountService) HandleCappedAmount(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.CappedAmountPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "capped_amount_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	logger.Info(ctx, "capped_amount_queue", fmt.Sprintf("开始检查用户用量上限: %d", payload.UserID))
+	return c.checkCappedAmount(ctx, payload.UserID)
+}
+
+func (c *CappedAmountService) checkCappedAmount(ctx context.Context, userID int64) error {
+	user, err := c.userRepo.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return nil
+	}
+	subscription, err := c.subscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("查询用户订阅失败: %w", err)
+	}
+	// 没有用量订阅时由设置页面控制购物车，这里不处理
+	if subscription == nil || subscription.CappedAmount <= 0 {
+		return nil
+	}
+	cartSetting, err := c.cartSettingRepo.First(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("获取用户购物车设置失败: %w", err)
+	}
+	if cartSetting == nil {
+		return nil
+	}
+
+	// 已确认还没扣费的抽成也要占用额度，剩余额度不足时后续扣费会被 Shopify 拒绝
+	unchargedAmounts, err := c.commissionBillRepo.UnchargedAmounts(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("查询未扣费金额失败: %w", err)
+	}
+	uncharged, err := c.currencyConverter.ConvertSum(ctx, unchargedAmounts, subscription.ChargeCurrency())
+	if err != nil {
+		return fmt.Errorf("换算未扣费金额失败: %w", err)
+	}
+	remaining := decimal.NewFromFloat(subscription.CappedAmount).
+		Sub(decimal.NewFromFloat(subscription.BalanceUsed)).
+		Sub(uncharged)
+
+	if remaining.IsPositive() {
+		return c.restoreCart(ctx, user, cartSetting.CappedPaused)
+	}
+
+	if cartSetting.ShowCart == 1 {
+		if _, err := c.cartSettingRepo.PauseForCappedAmount(ctx, userID); err != nil {
+			return fmt.Errorf("关闭购物车失败: %w", err)
+		}
+		utils.CallWilding(fmt.Sprintf("用户 %d 订阅用量达到上限 %v，已自动关闭购物车保险", userID, subscription.CappedAmount))
+	} else if cartSetting.CappedPaused == 0 {
+		// 商家自己关闭的购物车不需要处理
+		return nil
+	}
+	// 重试时数据库已经是暂停状态，metafield 需要重新同步
+	return c.setCartEnable(ctx, user, "false")
+}
+
+// restoreCart 恢复因用量上限关闭的购物车，并让扣费失败的账单重新提交
+func (c *CappedAmountService) restoreCart(ctx context.Context, user *userEntity.User, cappedPaused int) error {
+	if cappedPaused == 0 {
+		return nil
+	}
+	// 先打开 metafield，失败时数据库仍然是暂停状态，重试时会再次恢复
+	if err := c.setCartEnable(ctx, user, "true"); err != nil {
+		return err
+	}
+	restored, err := c.cartSettingRepo.RestoreFromCappedAmount(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("恢复购物车失败: %w", err)
+	}
+	if !restored {
+		return nil
+	}
+	logger.Info(ctx, "capped_amount_queue", fmt.Sprintf("用户 %d 用量上限已提高，购物车保险已恢复", user.ID))
+
+	if err := c.commissionBillRepo.ResetRetryCount(ctx, user.ID); err != nil {
+		logger.Error(ctx, "capped_amount_queue: 重置账单重试次数失败", err)
+		return nil
+	}
+	if _, err := c.asynqRepo.CommissionRetryTask(ctx, 0); err != nil {
+		logger.Error(ctx, "capped_amount_queue: 推送账单补偿任务失败", err)
+	}
+	return nil
+}
+
+// setCartEnable 同步 app metafield 的 cart_enable，主题插件根据它决定是否展示保险
+func (c *CappedAmountService) setCartEnable(ctx context.Context, user *userEntity.User, cartEnable string) error {
+	appAuth, err := c.appAuthRepo.GetByUserAndApp(ctx, user.ID, user.AppId)
+	if err != nil {
+		return fmt.Errorf("获取app授权信息失败: %w", err)
+	}
+	if appAuth == nil || appAuth.InstallationId == 0 {
+		return fmt.Errorf("app安装ID为空")
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+	_, err = c.shopGraphqlRepo.MetafieldSet(ctx, fmt.Sprintf("gid://shopify/AppInstallation/%d", appAuth.InstallationId), shopifyEntity.MetafieldConditionalNs, shopifyEntity.MetafieldTypeBoolean, "cart_enable", cartEnable)
+	if err != nil {
+		return fmt.Errorf("更新 cart_enable 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	claimEntity "backend/internal/domain/entity/claims"
+	"backend/internal/domain/entity/jobs"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	claimRepo "backend/internal/domain/repo/claims"
+	shopifyRepo "backend/internal/domain/repo/shopifys"
+	"backend/internal/domain/repo/users"
+	"backend/internal/infras/shopify_graphql"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// ClaimService 按商家选择的处理方式在 Shopify 上退款、发礼品卡或补发
+type ClaimService struct {
+	claimRepo           claimRepo.ClaimRepository
+	userRepo            users.UserRepository
+	orderGraphqlRepo    shopifyRepo.OrderGraphqlRepository
+	claimResolutionRepo shopifyRepo.ClaimResolutionGraphqlRepository
+}
+
+func NewClaimService(repos *providers.Repositories) *ClaimService {
+	return &ClaimService{
+		claimRepo:           repos.ClaimRepo,
+		userRepo:            repos.UserRepo,
+		orderGraphqlRepo:    repos.OrderGraphqlRepo,
+		claimResolutionRepo: repos.ClaimResolutionRepo,
+	}
+}
+
+// HandleClaimResolve 处理审核通过的理赔，每一步完成后立即保存 Shopify ID，重试时跳过已完成的步骤
+func (s *ClaimService) HandleClaimResolve(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.ClaimResolvePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "claim_resolve_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	claim, err := s.claimRepo.Get(ctx, payload.ClaimId)
+	if err != nil {
+		return fmt.Errorf("查询理赔失败: %w", err)
+	}
+	if claim == nil || claim.Status != claimEntity.StatusApproved || claim.ResolutionStatus != claimEntity.ResolutionStatusPending {
+		return nil
+	}
+	logger.Info(ctx, "claim_resolve_queue", fmt.Sprintf("开始处理理赔: %d 处理方式: %s", claim.Id, claim.ResolutionType))
+
+	if err := s.resolve(ctx, claim); err != nil {
+		// 最后一次重试仍然失败时记录原因，商家可以重新发起或手动处理
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if retried >= maxRetry {
+			return s.failResolution(ctx, claim, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ClaimService) resolve(ctx context.Context, claim *claimEntity.OrderClaim) error {
+	user, err := s.userRepo.Get(ctx, claim.UserId)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.ID == 0 || user.IsDel != 0 {
+		return s.failResolution(ctx, claim, "用户不存在或已卸载")
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+
+	order, err := s.claimResolutionRepo.GetClaimOrder(ctx, claim.OrderId)
+	if err != nil {
+		return fmt.Errorf("查询Shopify订单失败: %w", err)
+	}
+	items, err := s.claimRepo.Items(ctx, claim.Id)
+	if err != nil {
+		return fmt.Errorf("查询理赔商品失败: %w", err)
+	}
+
+	switch claim.ResolutionType {
+	case claimEntity.ResolutionRefund:
+		err = s.refund(ctx, claim, order, items)
+	case claimEntity.ResolutionStoreCredit:
+		err = s.storeCredit(ctx, claim, order, items)
+	case claimEntity.ResolutionReship:
+		err = s.reship(ctx, claim, order, items)
+	default:
+		return s.failResolution(ctx, claim, fmt.Sprintf("不支持的处理方式: %s", claim.ResolutionType))
+	}
+	if err != nil {
+		return err
+	}
+
+	claim.ResolutionStatus = claimEntity.ResolutionStatusSucceeded
+	claim.ResolutionError = ""
+	claim.ResolvedAt = time.Now().Unix()
+	claim.Status = claimEntity.StatusResolved
+	if _, err := s.claimRepo.UpdateStatus(ctx, claim, claimEntity.StatusApproved, "resolution_status", "resolution_error", "resolved_at"); err != nil {
+		return fmt.Errorf("保存理赔处理结果失败: %w", err)
+	}
+	return nil
+}
+
+// refund 按理赔商品退款，金额和原支付交易由 Shopify 计算
+func (s *ClaimService) refund(ctx context.Context, claim *claimEntity.OrderClaim, order *shopifyEntity.ClaimOrder, items []*claimEntity.OrderClaimItem) error {
+	if claim.ShopifyRefundId != "" {
+		return nil
+	}
+	note := claim.ShopifyNote()
+	for _, refund := range order.Refunds {
+		if refund.Note == note {
+			return s.saveShopifyID(ctx, claim, &claim.ShopifyRefundId, refund.ID, "shopify_refund_id")
+		}
+	}
+
+	data, err := s.orderGraphqlRepo.GetOrderInfo(ctx, claim.OrderId)
+	if err != nil {
+		return fmt.Errorf("查询Shopify订单商品失败: %w", err)
+	}
+	lineItemIDs := make(map[string]string, len(data.Order.LineItems.Edges))
+	for _, edge := range data.Order.LineItems.Edges {
+		if _, ok := lineItemIDs[edge.Node.Variant.ID]; !ok {
+			lineItemIDs[edge.Node.Variant.ID] = edge.Node.ID
+		}
+	}
+	lineItems := make([]shopifyEntity.RefundLineItemInput, 0, len(items))
+	for _, item := range items {
+		lineItemID, ok := lineItemIDs[variantGid(item.VariantId)]
+		if !ok {
+			return fmt.Errorf("订单中没有找到理赔商品: %s", item.Sku)
+		}
+		lineItems = append(lineItems, shopifyEntity.RefundLineItemInput{LineItemID: lineItemID, Quantity: item.Quantity})
+	}
+
+	transactions, err := s.claimResolutionRepo.SuggestedRefund(ctx, order.ID, lineItems)
+	if err != nil {
+		return fmt.Errorf("计算退款金额失败: %w", err)
+	}
+	refundID, err := s.claimResolutionRepo.CreateRefund(ctx, order.ID, note, lineItems, transactions)
+	if err != nil {
+		return fmt.Errorf("创建退款失败: %w", err)
+	}
+	return s.saveShopifyID(ctx, claim, &claim.ShopifyRefundId, refundID, "shopify_refund_id")
+}
+
+// storeCredit 按理赔商品折扣后的金额给客户发礼品卡
+// 创建前先保存礼品卡代码，重试时按代码查找已创建的礼品卡，查不到时 Shopify 也会拒绝重复的代码
+func (s *ClaimService) storeCredit(ctx context.Context, claim *claimEntity.OrderClaim, order *shopifyEntity.ClaimOrder, items []*claimEntity.OrderClaimItem) error {
+	if claim.ShopifyGiftCardId != "" {
+		return nil
+	}
+	note := claim.ShopifyNote()
+	if claim.GiftCardCode == "" {
+		code, err := claimEntity.NewGiftCardCode()
+		if err != nil {
+			return fmt.Errorf("生成礼品卡代码失败: %w", err)
+		}
+		claim.GiftCardCode = code
+		if err := s.claimRepo.Update(ctx, claim, "gift_card_code"); err != nil {
+			return fmt.Errorf("保存礼品卡代码失败: %w", err)
+		}
+	} else {
+		// 礼品卡只会在审核通过之后创建，往前多查一段时间避免服务器时间误差
+		giftCardID, err := s.claimResolutionRepo.FindGiftCard(ctx, note, claim.GiftCardLastCharacters(), time.Unix(claim.ReviewedAt, 0).Add(-10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("查询礼品卡失败: %w", err)
+		}
+		if giftCardID != "" {
+			return s.saveShopifyID(ctx, claim, &claim.ShopifyGiftCardId, giftCardID, "shopify_gift_card_id")
+		}
+	}
+
+	lineItems, err := s.claimResolutionRepo.GetClaimLineItems(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("查询Shopify订单商品失败: %w", err)
+	}
+	prices := make(map[int64]decimal.Decimal, len(lineItems))
+	for _, lineItem := range lineItems {
+		if lineItem.Variant == nil {
+			continue
+		}
+		variantID := utils.GetIdFromShopifyGraphqlId(lineItem.Variant.ID)
+		if _, ok := prices[variantID]; !ok {
+			prices[variantID] = lineItem.DiscountedUnitPriceAfterAllDiscountsSet.ShopMoney.Amount
+		}
+	}
+	amount, err := claimEntity.CreditAmount(items, prices)
+	if err != nil {
+		return err
+	}
+	giftCardID, err := s.claimResolutionRepo.CreateGiftCard(ctx, amount, order.CustomerID(), note, claim.GiftCardCode)
+	if err != nil {
+		return fmt.Errorf("创建礼品卡失败: %w", err)
+	}
+	return s.saveShopifyID(ctx, claim, &claim.ShopifyGiftCardId, giftCardID, "shopify_gift_card_id")
+}
+
+// reship 创建免费的草稿订单补发理赔商品，完成草稿订单后生成补发订单
+func (s *ClaimService) reship(ctx context.Context, claim *claimEntity.OrderClaim, order *shopifyEntity.ClaimOrder, items []*claimEntity.OrderClaimItem) error {
+	if claim.ShopifyReshipOrderId != "" {
+		return nil
+	}
+	draftOrder, err := s.claimResolutionRepo.FindDraftOrder(ctx, claim.ShopifyTag())
+	if err != nil {
+		return fmt.Errorf("查询补发草稿订单失败: %w", err)
+	}
+	if draftOrder == nil {
+		lineItems := make([]shopifyEntity.DraftOrderLineItemInput, 0, len(items))
+		for _, item := range items {
+			if item.VariantId == 0 {
+				return fmt.Errorf("理赔商品没有变体，无法补发: %s", item.Sku)
+			}
+			lineItems = append(lineItems, shopifyEntity.DraftOrderLineItemInput{VariantID: variantGid(item.VariantId), Quantity: item.Quantity})
+		}
+		draftOrder, err = s.claimResolutionRepo.CreateReshipDraftOrder(ctx, order, lineItems, claim.ShopifyNote(), claim.ShopifyTag())
+		if err != nil {
+			return fmt.Errorf("创建补发草稿订单失败: %w", err)
+		}
+	}
+	if claim.ShopifyDraftOrderId != draftOrder.ID {
+		if err := s.saveShopifyID(ctx, claim, &claim.ShopifyDraftOrderId, draftOrder.ID, "shopify_draft_order_id"); err != nil {
+			return err
+		}
+	}
+
+	if draftOrder.Order == nil {
+		draftOrder, err = s.claimResolutionRepo.CompleteDraftOrder(ctx, draftOrder.ID)
+		if err != nil {
+			return fmt.Errorf("完成补发草稿订单失败: %w", err)
+		}
+	}
+	return s.saveShopifyID(ctx, claim, &claim.ShopifyReshipOrderId, draftOrder.Order.ID, "shopify_reship_order_id")
+}
+
+func (s *ClaimService) saveShopifyID(ctx context.Context, claim *claimEntity.OrderClaim, field *string, id string, column string) error {
+	*field = id
+	if err := s.claimRepo.Update(ctx, claim, column); err != nil {
+		return fmt.Errorf("保存%s失败: %w", column, err)
+	}
+	return nil
+}
+
+func (s *ClaimService) failResolution(ctx context.Context, claim *claimEntity.OrderClaim, reason string) error {
+	logger.Error(ctx, fmt.Sprintf("claim_resolve_queue: 理赔 %d 处理失败: %s", claim.Id, reason))
+	if runes := []rune(reason); len(runes) > 500 {
+		reason = string(runes[:500])
+	}
+	claim.ResolutionStatus = claimEntity.ResolutionStatusFailed
+	claim.ResolutionError = reason
+	return s.claimRepo.Update(ctx, claim, "resolution_status", "resolution_error")
+}
+
+func variantGid(variantId int64) string {
+	return fmt.Sprintf("gid://shopify/ProductVariant/%d", variantId)
+}
@@ -0,0 +1,405 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/jobs"
+	userEntity "backend/internal/domain/entity/users"
+	billingsRepo "backend/internal/domain/repo/billings"
+	jobRepo "backend/internal/domain/repo/jobs"
+	shopifyRepo "backend/internal/domain/repo/shopifys"
+	"backend/internal/domain/repo/users"
+	"backend/internal/infras/shopify_graphql"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// CommissionService 抽成账单结算，把待提交的账单作为 Shopify 用量扣费提交
+type CommissionService struct {
+	commissionBillRepo     billingsRepo.CommissionBillRepository
+	billingPeriodRepo      billingsRepo.BillingPeriodSummaryRepository
+	adjustmentRepo         billingsRepo.CommissionAdjustmentRepository
+	userRepo               users.UserRepository
+	subscriptionRepo       users.UserSubscriptionRepository
+	usageChargeGraphqlRepo shopifyRepo.UsageChargeGraphqlRepository
+	appCreditGraphqlRepo   shopifyRepo.AppCreditGraphqlRepository
+	asynqRepo              jobRepo.AsynqRepository
+	currencyConverter      billingsRepo.CurrencyConverter
+}
+
+func NewCommissionService(repos *providers.Repositories) *CommissionService {
+	return &CommissionService{
+		commissionBillRepo:     repos.CommissionBillRepo,
+		billingPeriodRepo:      repos.BillingPeriodSummaryRepo,
+		adjustmentRepo:         repos.CommissionAdjustmentRepo,
+		userRepo:               repos.UserRepo,
+		subscriptionRepo:       repos.UserSubscriptionRepo,
+		usageChargeGraphqlRepo: repos.UsageChargeGraphqlRepo,
+		appCreditGraphqlRepo:   repos.AppCreditGraphqlRepo,
+		asynqRepo:              repos.AsyncRepo,
+		currencyConverter:      repos.CurrencyConverter,
+	}
+}
+
+// HandleCommissionSettle 提交单个账单的用量扣费，提交失败时返回错误交给 asynq 重试
+func (c *CommissionService) HandleCommissionSettle(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.CommissionSettlePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "commission_settle_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	logger.Info(ctx, "commission_settle_queue", fmt.Sprintf("开始结算账单: %d", payload.BillID))
+	return c.settleBill(ctx, payload.BillID)
+}
+
+// HandleCommissionCredit 把账期内无法冲减的退款抽成通过 app credit 返还给商家
+func (c *CommissionService) HandleCommissionCredit(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.CommissionCreditPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "commission_credit_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	logger.Info(ctx, "commission_credit_queue", fmt.Sprintf("开始返还调整流水: %d", payload.AdjustmentID))
+	return c.creditAdjustment(ctx, payload.AdjustmentID)
+}
+
+// HandleCommissionRetry 扫描待提交和提交失败的账单以及待返还的调整流水，重新推入队列
+func (c *CommissionService) HandleCommissionRetry(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.CommissionRetryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "commission_retry_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	// 刚创建的账单已经在结算队列里，这里只处理10分钟内没有变化的账单
+	before := time.Now().Add(-10 * time.Minute).Unix()
+	lastID := payload.LastID
+	batchSize := 200
+	for {
+		bills, err := c.commissionBillRepo.RetryableBills(ctx, lastID, before, batchSize)
+		if err != nil {
+			logger.Error(ctx, "commission_retry_queue: 查询待重试账单失败", err)
+			return err
+		}
+		for _, bill := range bills {
+			lastID = bill.Id
+			if _, err := c.asynqRepo.CommissionSettleTask(ctx, bill.Id); err != nil {
+				logger.Error(ctx, fmt.Sprintf("commission_retry_queue: 账单 %d 推送失败", bill.Id), err)
+			}
+		}
+		if len(bills) < batchSize {
+			break
+		}
+	}
+
+	var lastAdjustmentID int64
+	for {
+		adjustments, err := c.adjustmentRepo.RetryableCredits(ctx, lastAdjustmentID, before, batchSize)
+		if err != nil {
+			logger.Error(ctx, "commission_retry_queue: 查询待返还调整流水失败", err)
+			return err
+		}
+		for _, adjustment := range adjustments {
+			lastAdjustmentID = adjustment.Id
+			if _, err := c.asynqRepo.CommissionCreditTask(ctx, adjustment.Id); err != nil {
+				logger.Error(ctx, fmt.Sprintf("commission_retry_queue: 调整流水 %d 推送失败", adjustment.Id), err)
+			}
+		}
+		if len(adjustments) < batchSize {
+			break
+		}
+	}
+
+	logger.Info(ctx, "commission_retry_queue", fmt.Sprintf("执行完毕, lastID: %d", lastID))
+	return nil
+}
+
+func (c *CommissionService) settleBill(ctx context.Context, billID int64) error {
+	bill, err := c.commissionBillRepo.GetCommission(ctx, billID)
+	if err != nil {
+		return fmt.Errorf("查询账单失败: %w", err)
+	}
+	if bill == nil {
+		logger.Warn(ctx, fmt.Sprintf("commission_settle_queue: 账单 %d 不存在", billID))
+		return nil
+	}
+	if bill.ChargeStatus == billings.ChargeStatusCharged {
+		return nil
+	}
+
+	user, err := c.userRepo.Get(ctx, bill.UserId)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return c.markFailed(ctx, bill, "用户不存在或已卸载")
+	}
+
+	// 金额为0（或已被退款全部冲减）的账单不需要提交到 Shopify
+	amount := decimal.NewFromFloat(bill.ChargeAmount()).Round(2)
+	if !amount.IsPositive() {
+		return c.markCharged(ctx, bill, "")
+	}
+
+	subscription, err := c.subscriptionRepo.GetActiveSubscription(ctx, bill.UserId)
+	if err != nil {
+		return fmt.Errorf("查询用户订阅失败: %w", err)
+	}
+	if subscription == nil || subscription.SubscriptionLineItemID == "" {
+		// 没有可用的订阅，等商家订阅后由补偿任务重新提交
+		return c.markFailed(ctx, bill, "未找到有效的用量订阅")
+	}
+
+	// 账单是订单的店铺币种，Shopify 只接受订阅上限币种的扣费
+	conversion, err := c.convertBill(ctx, bill, amount, subscription.ChargeCurrency())
+	if err != nil {
+		return c.markFailed(ctx, bill, fmt.Sprintf("换算扣费金额失败: %v", err))
+	}
+	chargeAmount := conversion.Result
+	if !chargeAmount.IsPositive() {
+		return c.markCharged(ctx, bill, "")
+	}
+
+	// 扣费前先原子占用额度，并发结算时不会一起超出用量上限
+	// 超出用量上限的扣费会被 Shopify 拒绝，先暂停购物车保险，商家提高上限后由补偿任务重新提交
+	delta := utils.DecimalToFloat(chargeAmount)
+	reserved, err := c.subscriptionRepo.IncrSubscriptionBalance(ctx, subscription.ID, delta)
+	if err != nil {
+		return fmt.Errorf("占用订阅额度失败: %w", err)
+	}
+	if !reserved {
+		if _, err := c.asynqRepo.CappedAmountTask(ctx, bill.UserId); err != nil {
+			logger.Error(ctx, "commission_settle_queue: 推送用量上限检查任务失败", err)
+		}
+		return c.markFailed(ctx, bill, "超出订阅用量上限")
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+
+	description := fmt.Sprintf("Protectify commission for order %s", bill.OrderName)
+	idempotencyKey := billings.UsageIdempotencyKey(bill.Id)
+	usageRecordID, err := c.usageChargeGraphqlRepo.CreateUsageCharge(ctx, subscription.SubscriptionLineItemID, chargeAmount, conversion.To, description, idempotencyKey)
+	if err != nil {
+		c.releaseBalance(ctx, subscription.ID, delta)
+		if markErr := c.markFailed(ctx, bill, err.Error()); markErr != nil {
+			logger.Error(ctx, "commission_settle_queue: 更新账单失败状态失败", markErr)
+		}
+		if bill.RetryCount+1 >= billings.MaxChargeRetry {
+			utils.CallWilding(fmt.Sprintf("抽成账单扣费多次失败 bill: %d user: %d amount: %v %s err: %v", bill.Id, bill.UserId, chargeAmount, conversion.To, err))
+		}
+		return fmt.Errorf("提交用量扣费失败: %w", err)
+	}
+
+	charged, err := c.markBillCharged(ctx, bill, usageRecordID)
+	if err != nil {
+		return err
+	}
+	// 其他任务已经记录了这笔扣费，Shopify 按幂等键返回同一条用量记录，释放这次占用的额度
+	if !charged {
+		c.releaseBalance(ctx, subscription.ID, delta)
+		return nil
+	}
+
+	// 额度已经用完，检查是否需要暂停购物车保险
+	balanceUsed := decimal.NewFromFloat(subscription.BalanceUsed).Add(chargeAmount)
+	if subscription.CappedAmount > 0 && !decimal.NewFromFloat(subscription.CappedAmount).Sub(balanceUsed).IsPositive() {
+		if _, err := c.asynqRepo.CappedAmountTask(ctx, bill.UserId); err != nil {
+			logger.Error(ctx, "commission_settle_queue: 推送用量上限检查任务失败", err)
+		}
+	}
+	return nil
+}
+
+// convertBill 把账单金额换算为扣费币种并记录在账单上，重试时沿用第一次换算的结果
+func (c *CommissionService) convertBill(ctx context.Context, bill *billings.CommissionBill, amount decimal.Decimal, chargeCurrency string) (*billings.Conversion, error) {
+	if bill.SameConversion(utils.DecimalToFloat(amount), chargeCurrency) {
+		return &billings.Conversion{
+			From:   bill.Currency,
+			To:     chargeCurrency,
+			Amount: amount,
+			Rate:   decimal.NewFromFloat(bill.ExchangeRate),
+			Result: decimal.NewFromFloat(bill.ConvertedAmount),
+		}, nil
+	}
+
+	// 早期账单没有记录币种，当时按订阅币种直接扣费
+	from := bill.Currency
+	if from == "" {
+		from = chargeCurrency
+	}
+	conversion, err := c.currencyConverter.Convert(ctx, amount, from, chargeCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.commissionBillRepo.SaveConversion(ctx, bill.Id, conversion); err != nil {
+		return nil, fmt.Errorf("保存账单换算结果失败: %w", err)
+	}
+	return conversion, nil
+}
+
+// markCharged 标记不需要提交到 Shopify 的账单扣费成功，并把金额计入周期汇总的已付金额
+func (c *CommissionService) markCharged(ctx context.Context, bill *billings.CommissionBill, usageRecordID string) error {
+	_, err := c.markBillCharged(ctx, bill, usageRecordID)
+	return err
+}
+
+// markBillCharged 标记账单扣费成功并计入周期汇总，返回这次是否更新了账单，账单已经是已扣费时返回 false
+func (c *CommissionService) markBillCharged(ctx context.Context, bill *billings.CommissionBill, usageRecordID string) (bool, error) {
+	changed, err := c.commissionBillRepo.MarkCharged(ctx, bill.Id, usageRecordID)
+	if err != nil {
+		return false, fmt.Errorf("更新账单扣费状态失败: %w", err)
+	}
+	if !changed {
+		return false, nil
+	}
+	fromFailed := bill.ChargeStatus == billings.ChargeStatusFailed
+	if err := c.billingPeriodRepo.SettleCharged(ctx, bill.PeriodKey(), bill.ChargeAmount(), fromFailed); err != nil {
+		logger.Error(ctx, fmt.Sprintf("commission_settle_queue: 账单 %d 更新周期汇总失败", bill.Id), err)
+	}
+	logger.Info(ctx, "commission_settle_queue", fmt.Sprintf("账单 %d 扣费成功: %s", bill.Id, usageRecordID))
+	return true, nil
+}
+
+// releaseBalance 扣费没有记录到账单时释放占用的订阅额度
+func (c *CommissionService) releaseBalance(ctx context.Context, subscriptionID int64, delta float64) {
+	if _, err := c.subscriptionRepo.IncrSubscriptionBalance(ctx, subscriptionID, -delta); err != nil {
+		logger.Error(ctx, fmt.Sprintf("commission_settle_queue: 订阅 %d 释放额度失败", subscriptionID), err)
+	}
+}
+
+// markFailed 标记账单扣费失败，首次失败时把金额从待付转入失败金额
+func (c *CommissionService) markFailed(ctx context.Context, bill *billings.CommissionBill, reason string) error {
+	logger.Warn(ctx, fmt.Sprintf("commission_settle_queue: 账单 %d 扣费失败: %s", bill.Id, reason))
+	if err := c.commissionBillRepo.MarkFailed(ctx, bill.Id, reason); err != nil {
+		return fmt.Errorf("更新账单扣费状态失败: %w", err)
+	}
+	if bill.ChargeStatus == billings.ChargeStatusPending {
+		if err := c.billingPeriodRepo.SettleFailed(ctx, bill.PeriodKey(), bill.ChargeAmount()); err != nil {
+			logger.Error(ctx, fmt.Sprintf("commission_settle_queue: 账单 %d 更新周期汇总失败", bill.Id), err)
+		}
+	}
+	return nil
+}
+
+func (c *CommissionService) creditAdjustment(ctx context.Context, adjustmentID int64) error {
+	adjustment, err := c.adjustmentRepo.Get(ctx, adjustmentID)
+	if err != nil {
+		return fmt.Errorf("查询调整流水失败: %w", err)
+	}
+	if adjustment == nil {
+		logger.Warn(ctx, fmt.Sprintf("commission_credit_queue: 调整流水 %d 不存在", adjustmentID))
+		return nil
+	}
+	amount := decimal.NewFromFloat(adjustment.CreditAmount).Round(2)
+	if adjustment.Status == billings.AdjustmentStatusCredited || !amount.IsPositive() {
+		return nil
+	}
+
+	user, err := c.userRepo.Get(ctx, adjustment.UserId)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return c.adjustmentRepo.MarkFailed(ctx, adjustment.Id, "用户不存在或已卸载")
+	}
+
+	subscription, err := c.subscriptionRepo.GetActiveSubscription(ctx, adjustment.UserId)
+	if err != nil {
+		return fmt.Errorf("查询用户订阅失败: %w", err)
+	}
+	test := subscription != nil && subscription.TestSubscription
+	creditCurrency := userEntity.DefaultChargeCurrency
+	if subscription != nil {
+		creditCurrency = subscription.ChargeCurrency()
+	}
+
+	// 返还的抽成是订单的店铺币种，按扣费币种发放 app credit
+	from := adjustment.Currency
+	if from == "" {
+		from = creditCurrency
+	}
+	conversion, err := c.currencyConverter.Convert(ctx, amount, from, creditCurrency)
+	if err != nil {
+		if markErr := c.adjustmentRepo.MarkFailed(ctx, adjustment.Id, err.Error()); markErr != nil {
+			logger.Error(ctx, "commission_credit_queue: 更新调整流水失败状态失败", markErr)
+		}
+		return fmt.Errorf("换算返还金额失败: %w", err)
+	}
+	if !conversion.Result.IsPositive() {
+		// 换算后不足最小金额单位，不需要发放
+		_, err := c.adjustmentRepo.MarkCredited(ctx, adjustment.Id, "")
+		return err
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+
+	// 描述带流水ID，上次提交后没有拿到结果时按描述找回已发放的 credit，避免重复返还
+	description := billings.CreditDescription(adjustment.OrderName, adjustment.Id)
+	if adjustment.Status == billings.AdjustmentStatusCrediting || adjustment.Status == billings.AdjustmentStatusFailed {
+		if adjustment.Status == billings.AdjustmentStatusCrediting && time.Now().Unix()-adjustment.UpdateTime < billings.CreditingTimeout {
+			// 其他任务正在返还，超时后由重试任务确认
+			logger.Warn(ctx, fmt.Sprintf("commission_credit_queue: 调整流水 %d 正在返还", adjustment.Id))
+			return nil
+		}
+		creditID, err := c.appCreditGraphqlRepo.FindAppCredit(ctx, description)
+		if err != nil {
+			if markErr := c.adjustmentRepo.MarkFailed(ctx, adjustment.Id, err.Error()); markErr != nil {
+				logger.Error(ctx, "commission_credit_queue: 更新调整流水失败状态失败", markErr)
+			}
+			return fmt.Errorf("查询已发放的 app credit 失败: %w", err)
+		}
+		if creditID != "" {
+			return c.markAdjustmentCredited(ctx, adjustment, creditID)
+		}
+	}
+
+	claimed, err := c.adjustmentRepo.MarkCrediting(ctx, adjustment)
+	if err != nil {
+		return fmt.Errorf("更新调整流水返还中状态失败: %w", err)
+	}
+	if !claimed {
+		logger.Warn(ctx, fmt.Sprintf("commission_credit_queue: 调整流水 %d 已被其他任务处理", adjustment.Id))
+		return nil
+	}
+
+	creditID, err := c.appCreditGraphqlRepo.CreateAppCredit(ctx, conversion.Result, conversion.To, description, test)
+	if err != nil {
+		logger.Warn(ctx, fmt.Sprintf("commission_credit_queue: 调整流水 %d 返还失败: %v", adjustment.Id, err))
+		if markErr := c.adjustmentRepo.MarkFailed(ctx, adjustment.Id, err.Error()); markErr != nil {
+			logger.Error(ctx, "commission_credit_queue: 更新调整流水失败状态失败", markErr)
+		}
+		return fmt.Errorf("发放 app credit 失败: %w", err)
+	}
+	return c.markAdjustmentCredited(ctx, adjustment, creditID)
+}
+
+// markAdjustmentCredited 记录已发放的 credit 并累加周期汇总的返还金额
+func (c *CommissionService) markAdjustmentCredited(ctx context.Context, adjustment *billings.CommissionAdjustment, creditID string) error {
+	changed, err := c.adjustmentRepo.MarkCredited(ctx, adjustment.Id, creditID)
+	if err != nil {
+		// 状态仍是返还中，超时后重试任务会按描述找回这笔 credit
+		logger.Error(ctx, fmt.Sprintf("commission_credit_queue: app credit 已发放但更新调整流水 %d 失败 credit: %s", adjustment.Id, creditID), err)
+		return nil
+	}
+	if changed {
+		if err := c.billingPeriodRepo.AddCreditAmount(ctx, adjustment.PeriodKey(), adjustment.CreditAmount); err != nil {
+			logger.Error(ctx, fmt.Sprintf("commission_credit_queue: 调整流水 %d 更新周期汇总失败", adjustment.Id), err)
+		}
+	}
+	logger.Info(ctx, "commission_credit_queue", fmt.Sprintf("调整流水 %d 返还成功: %s", adjustment.Id, creditID))
+	return nil
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/users"
+	billingsRepo "backend/internal/domain/repo/billings"
+	shopifyRepo "backend/internal/domain/repo/shopifys"
+	usersRepo "backend/internal/domain/repo/users"
+	"backend/pkg/logger"
+)
+
+type fakeCreditAdjustmentRepo struct {
+	billingsRepo.CommissionAdjustmentRepository
+	adjustment *billings.CommissionAdjustment
+	creditID   string
+}
+
+func (f *fakeCreditAdjustmentRepo) Get(context.Context, int64) (*billings.CommissionAdjustment, error) {
+	return f.adjustment, nil
+}
+
+func (f *fakeCreditAdjustmentRepo) MarkCrediting(_ context.Context, adjustment *billings.CommissionAdjustment) (bool, error) {
+	if f.adjustment.Status != adjustment.Status || f.adjustment.UpdateTime != adjustment.UpdateTime {
+		return false, nil
+	}
+	f.adjustment.Status = billings.AdjustmentStatusCrediting
+	return true, nil
+}
+
+func (f *fakeCreditAdjustmentRepo) MarkCredited(_ context.Context, _ int64, creditID string) (bool, error) {
+	f.adjustment.Status = billings.AdjustmentStatusCredited
+	f.creditID = creditID
+	return true, nil
+}
+
+type fakeUserRepo struct {
+	usersRepo.UserRepository
+}
+
+func (f *fakeUserRepo) Get(context.Context, int64, ...string) (*users.User, error) {
+	return &users.User{ID: 1, Shop: "demo.myshopify.com"}, nil
+}
+
+type fakeConverter struct {
+	billingsRepo.CurrencyConverter
+}
+
+func (f *fakeConverter) Convert(_ context.Context, amount decimal.Decimal, from string, to string) (*billings.Conversion, error) {
+	return &billings.Conversion{From: from, To: to, Amount: amount, Rate: decimal.NewFromInt(1), Result: amount}, nil
+}
+
+type fakeAppCreditRepo struct {
+	shopifyRepo.AppCreditGraphqlRepository
+	existing     map[string]string
+	created      []string
+	findRequests int
+}
+
+func (f *fakeAppCreditRepo) CreateAppCredit(_ context.Context, _ decimal.Decimal, _ string, description string, _ bool) (string, error) {
+	f.created = append(f.created, description)
+	return "gid://shopify/AppCredit/2", nil
+}
+
+func (f *fakeAppCreditRepo) FindAppCredit(_ context.Context, description string) (string, error) {
+	f.findRequests++
+	return f.existing[description], nil
+}
+
+type fakeCreditPeriodRepo struct {
+	billingsRepo.BillingPeriodSummaryRepository
+}
+
+func (f *fakeCreditPeriodRepo) AddCreditAmount(context.Context, billings.PeriodKey, float64) error {
+	return nil
+}
+
+func TestCreditAdjustmentIdempotent(t *testing.T) {
+	logger.Default(logger.WriteToFile(false))
+	description := billings.CreditDescription("#1001", 7)
+
+	// 上次提交后没有拿到结果，Shopify 已经有这笔 credit，只记录不重复发放
+	adjustmentRepo := &fakeCreditAdjustmentRepo{adjustment: &billings.CommissionAdjustment{
+		Id: 7, UserId: 1, OrderName: "#1001", CreditAmount: 2, Currency: "USD",
+		Status: billings.AdjustmentStatusCrediting, UpdateTime: time.Now().Add(-time.Hour).Unix(),
+	}}
+	creditRepo := &fakeAppCreditRepo{existing: map[string]string{description: "gid://shopify/AppCredit/1"}}
+	service := &CommissionService{
+		adjustmentRepo:       adjustmentRepo,
+		billingPeriodRepo:    &fakeCreditPeriodRepo{},
+		userRepo:             &fakeUserRepo{},
+		subscriptionRepo:     &fakeSubscriptionRepo{},
+		appCreditGraphqlRepo: creditRepo,
+		currencyConverter:    &fakeConverter{},
+	}
+	if err := service.creditAdjustment(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if len(creditRepo.created) != 0 || adjustmentRepo.creditID != "gid://shopify/AppCredit/1" {
+		t.Errorf("created = %v credit = %q, want existing credit reused", creditRepo.created, adjustmentRepo.creditID)
+	}
+
+	// 其他任务刚提交的返还不重复处理
+	adjustmentRepo.adjustment.Status = billings.AdjustmentStatusCrediting
+	adjustmentRepo.adjustment.UpdateTime = time.Now().Unix()
+	adjustmentRepo.creditID = ""
+	if err := service.creditAdjustment(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if len(creditRepo.created) != 0 || adjustmentRepo.creditID != "" {
+		t.Errorf("in-flight adjustment should be skipped, created = %v", creditRepo.created)
+	}
+
+	// 首次返还先标记返还中，不需要查询
+	adjustmentRepo.adjustment.Status = billings.AdjustmentStatusPending
+	creditRepo.findRequests = 0
+	if err := service.creditAdjustment(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if creditRepo.findRequests != 0 || len(creditRepo.created) != 1 || creditRepo.created[0] != description {
+		t.Errorf("find = %d created = %v, want one credit with %q", creditRepo.findRequests, creditRepo.created, description)
+	}
+}
@@ -2,9 +2,7 @@ package jobs
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"time"
 
@@ -15,13 +13,15 @@ import (
 	"backend/internal/domain/entity/jobs"
 	"backend/internal/domain/entity/orders"
 	"backend/internal/domain/entity/shopifys"
+	userEntity "backend/internal/domain/entity/users"
+	"backend/internal/domain/pricing"
+	"backend/internal/domain/repo"
 	billingsRepo "backend/internal/domain/repo/billings"
 	jobRepo "backend/internal/domain/repo/jobs"
 	orderRepo "backend/internal/domain/repo/orders"
 	"backend/internal/domain/repo/products"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/domain/repo/users"
-	"backend/internal/infras/config"
 	"backend/internal/infras/shopify_graphql"
 	"backend/internal/providers"
 	"backend/pkg/logger"
@@ -42,7 +42,28 @@ type OrderService struct {
 	shopifyRepo       shopifyRepo.ShopifyRepository
 	subscriptionRepo  users.UserSubscriptionRepository
 	billingPeriodRepo billingsRepo.BillingPeriodSummaryRepository
+	commissionRepo    billingsRepo.CommissionBillRepository
+	adjustmentRepo    billingsRepo.CommissionAdjustmentRepository
 	cartSettingRepo   cartSettingRepo.CartSettingRepository
+	asynqRepo         jobRepo.AsynqRepository
+	txRepo            repo.TransactionRepository
+	planRepo          billingsRepo.PlanRepository
+	backfillRepo      jobRepo.OrderBackfillRepository
+	bulkOperationRepo shopifyRepo.BulkOperationGraphqlRepository
+}
+
+// paidFinancialStatuses 已支付的订单才同步
+var paidFinancialStatuses = map[string]bool{
+	"PAID":               true,
+	"PARTIALLY_PAID":     true,
+	"PARTIALLY_REFUNDED": true,
+	"REFUNDED":           true,
+}
+
+// orderTasks 订单事务内产生的异步任务，事务提交后才推送，避免任务先于数据可见
+type orderTasks struct {
+	settleBillIDs       []int64
+	creditAdjustmentIDs []int64
 }
 
 func NewOrderService(repos *providers.Repositories) *OrderService {
@@ -56,8 +77,15 @@ func NewOrderService(repos *providers.Repositories) *OrderService {
 		shopifyRepo:       repos.ShopifyRepo,
 		subscriptionRepo:  repos.UserSubscriptionRepo,
 		billingPeriodRepo: repos.BillingPeriodSummaryRepo,
+		commissionRepo:    repos.CommissionBillRepo,
+		adjustmentRepo:    repos.CommissionAdjustmentRepo,
 		variantRepo:       repos.VariantRepo,
 		cartSettingRepo:   repos.CartSettingRepo,
+		asynqRepo:         repos.AsyncRepo,
+		txRepo:            repos.TransactionRepo,
+		planRepo:          repos.PlanRepo,
+		backfillRepo:      repos.OrderBackfillRepo,
+		bulkOperationRepo: repos.BulkOperationRepo,
 	}
 }
 
@@ -101,21 +129,14 @@ func (o *OrderService) processOrderJob(ctx context.Context, jobId int64) error {
 	// 初始化 Shopify client
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	o.orderGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	// 获取订单信息
 	data, err := o.orderGraphqlRepo.GetOrderInfo(ctx, job.OrderId)
 	if err != nil {
 		return o.fail(ctx, job.Id, "拉取Shopify订单信息失败", err)
 	}
 
-	validStatuses := map[string]bool{
-		"PAID":               true,
-		"PARTIALLY_PAID":     true,
-		"PARTIALLY_REFUNDED": true,
-		"REFUNDED":           true,
-	}
-
-	if !validStatuses[data.Order.DisplayFinancialStatus] {
+	if !paidFinancialStatuses[data.Order.DisplayFinancialStatus] {
 		return o.skip(ctx, job.Id, fmt.Sprintf("订单未支付，跳过: %s", data.Order.DisplayFinancialStatus))
 	}
 
@@ -129,22 +150,39 @@ func (o *OrderService) processOrderJob(ctx context.Context, jobId int64) error {
 
 	variantIDMap := o.sliceToMap(uploadedVariantIDs)
 
-	// 处理订单
-	dbOrderId := o.orderRepo.ExistsByOrderID(ctx, job.OrderId, userID)
-	if dbOrderId > 0 {
-		err = o.updateExistingOrder(ctx, dbOrderId, userID, data, variantIDMap)
-	} else {
-		err = o.createNewOrder(ctx, userID, data, variantIDMap)
-	}
+	// 订单、订单详情和账单记录在同一个事务内写入
+	tasks := &orderTasks{}
+	err = o.txRepo.Transaction(ctx, func(ctx context.Context) error {
+		dbOrderId := o.orderRepo.ExistsByOrderID(ctx, job.OrderId, userID)
+		if dbOrderId > 0 {
+			return o.updateExistingOrder(ctx, dbOrderId, userID, data, variantIDMap, tasks)
+		}
+		return o.createNewOrder(ctx, userID, data, variantIDMap, true, tasks)
+	})
 	if err != nil {
 		return o.fail(ctx, job.Id, "处理订单失败", err)
 	}
+	o.dispatchTasks(ctx, tasks)
 
 	return o.ok(ctx, job.Id)
 }
 
+// dispatchTasks 推送结算和返还任务，推送失败的由补偿任务重新提交
+func (o *OrderService) dispatchTasks(ctx context.Context, tasks *orderTasks) {
+	for _, billID := range tasks.settleBillIDs {
+		if _, err := o.asynqRepo.CommissionSettleTask(ctx, billID); err != nil {
+			logger.Error(ctx, fmt.Sprintf("order_queue: 账单 %d 推送结算任务失败", billID), err)
+		}
+	}
+	for _, adjustmentID := range tasks.creditAdjustmentIDs {
+		if _, err := o.asynqRepo.CommissionCreditTask(ctx, adjustmentID); err != nil {
+			logger.Error(ctx, fmt.Sprintf("order_queue: 调整流水 %d 推送返还任务失败", adjustmentID), err)
+		}
+	}
+}
+
 // order.go
-func (o *OrderService) updateExistingOrder(ctx context.Context, dbOrderId int64, userID int64, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}) error {
+func (o *OrderService) updateExistingOrder(ctx context.Context, dbOrderId int64, userID int64, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}, tasks *orderTasks) error {
 	// 获取订单详情里的已有变体
 	existingVariantIDs, err := o.orderInfoRepo.GetOrderDetailVariantIDs(ctx, dbOrderId, userID)
 	if err != nil {
@@ -155,11 +193,26 @@ func (o *OrderService) updateExistingOrder(ctx context.Context, dbOrderId int64,
 	// 解析退款
 	refundMap, refundAmount := o.parseRefundInfo(data)
 
+	// 回填的历史订单后续更新也不产生抽成
+	backfilled, err := o.orderRepo.IsBackfilled(ctx, dbOrderId)
+	if err != nil {
+		return fmt.Errorf("查询订单回填状态失败: %w", err)
+	}
+
 	// 更新主订单
 	userOrder := &orders.UserOrder{
 		Id:                dbOrderId,
+		UserID:            userID,
+		OrderId:           utils.GetIdFromShopifyGraphqlId(data.Order.ID),
+		OrderName:         data.Order.Name,
+		Email:             data.Order.Email,
 		FinancialStatus:   data.Order.DisplayFinancialStatus,
+		TotalPriceAmount:  utils.DecimalToFloat(data.Order.TotalPriceSet.ShopMoney.Amount),
 		RefundPriceAmount: refundAmount,
+		Currency:          data.Order.TotalPriceSet.ShopMoney.CurrencyCode,
+	}
+	if backfilled {
+		userOrder.Backfilled = 1
 	}
 
 	var userOrderInfos []*orders.UserOrderInfo
@@ -170,19 +223,22 @@ func (o *OrderService) updateExistingOrder(ctx context.Context, dbOrderId int64,
 	for _, lineItem := range data.Order.LineItems.Edges {
 		variantID := utils.GetIdFromShopifyGraphqlId(lineItem.Node.Variant.ID)
 		refundQuantity := refundMap[variantID]
+		price := lineItem.Node.OriginalUnitPriceSet.ShopMoney.Amount
 		skuNum++
 
+		isProtectify := 0
+		if _, ok := variantIDMap[variantID]; ok {
+			isProtectify = 1
+			insuranceAmountDecimal = insuranceAmountDecimal.Add(price)
+		}
+
 		if _, exists := existingVariantMap[variantID]; exists {
 			// 更新退款数量
-			o.orderInfoRepo.UpdateShopifyVariants(ctx, dbOrderId, variantID, &orders.UserOrderInfo{RefundNum: refundQuantity})
+			if err := o.orderInfoRepo.UpdateShopifyVariants(ctx, dbOrderId, variantID, &orders.UserOrderInfo{RefundNum: refundQuantity}); err != nil {
+				return fmt.Errorf("更新订单详情失败: %w", err)
+			}
 		} else {
 			// 新增订单详情
-			price := lineItem.Node.OriginalUnitPriceSet.ShopMoney.Amount
-			isProtectify := 0
-			if _, ok := variantIDMap[variantID]; ok {
-				isProtectify = 1
-				insuranceAmountDecimal = insuranceAmountDecimal.Add(price)
-			}
 
 			userOrderInfos = append(userOrderInfos, &orders.UserOrderInfo{
 				UserID:          userID,
@@ -201,22 +257,32 @@ func (o *OrderService) updateExistingOrder(ctx context.Context, dbOrderId int64,
 
 	userOrder.SkuNum = skuNum
 	userOrder.ProtectifyAmount = utils.DecimalToFloat(insuranceAmountDecimal)
-	o.orderRepo.UpdateShopifyOrderId(ctx, userOrder)
+	if err := o.orderRepo.UpdateShopifyOrderId(ctx, userOrder); err != nil {
+		return fmt.Errorf("更新主订单失败: %w", err)
+	}
 
 	// 插入新增的变体
 	if len(userOrderInfos) > 0 {
-		return o.orderInfoRepo.Create(ctx, userOrderInfos)
+		if err := o.orderInfoRepo.Create(ctx, userOrderInfos); err != nil {
+			return fmt.Errorf("插入订单详情失败: %w", err)
+		}
 	}
 
 	// 更新订单后更新账单相关记录
-	if err := o.updateBillingRecords(ctx, userID, userOrder, userOrderInfos); err != nil {
+	if err := o.updateBillingRecords(ctx, userID, userOrder, data, tasks); err != nil {
 		return fmt.Errorf("更新账单记录失败: %w", err)
 	}
 
+	// 保险商品退款后冲减抽成
+	if err := o.reverseRefundCommission(ctx, userID, userOrder, data, variantIDMap, tasks); err != nil {
+		return fmt.Errorf("冲减退款抽成失败: %w", err)
+	}
+
 	return nil
 }
 
-func (o *OrderService) createNewOrder(ctx context.Context, userID int64, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}) error {
+// createNewOrder 保存新订单，withBilling 为 false 时是历史订单回填，标记为回填订单并且不生成抽成账单
+func (o *OrderService) createNewOrder(ctx context.Context, userID int64, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}, withBilling bool, tasks *orderTasks) error {
 	refundMap, refundAmount := o.parseRefundInfo(data)
 
 	total := data.Order.TotalPriceSet.ShopMoney.Amount
@@ -227,6 +293,7 @@ func (o *OrderService) createNewOrder(ctx context.Context, userID int64, data *s
 		UserID:            userID,
 		OrderId:           utils.GetIdFromShopifyGraphqlId(data.Order.ID),
 		OrderName:         data.Order.Name,
+		Email:             data.Order.Email,
 		OrderCreatedAt:    createdAt,
 		OrderCompletionAt: processedAt,
 		FinancialStatus:   data.Order.DisplayFinancialStatus,
@@ -236,6 +303,9 @@ func (o *OrderService) createNewOrder(ctx context.Context, userID int64, data *s
 		Currency:          data.Order.TotalPriceSet.ShopMoney.CurrencyCode,
 		SkuNum:            0,
 	}
+	if !withBilling {
+		userOrder.Backfilled = 1
+	}
 
 	var userOrderInfos []*orders.UserOrderInfo
 	var insuranceAmount float64
@@ -276,14 +346,24 @@ func (o *OrderService) createNewOrder(ctx context.Context, userID int64, data *s
 		userOrderInfos[i].UserOrderId = dbOrderId
 	}
 
-	err = o.orderInfoRepo.Create(ctx, userOrderInfos)
+	if err = o.orderInfoRepo.Create(ctx, userOrderInfos); err != nil {
+		return fmt.Errorf("插入订单详情失败: %w", err)
+	}
+	if !withBilling {
+		return nil
+	}
 
 	// 创建订单后更新账单相关记录
-	if err := o.updateBillingRecords(ctx, userID, userOrder, userOrderInfos); err != nil {
+	if err := o.updateBillingRecords(ctx, userID, userOrder, data, tasks); err != nil {
 		return fmt.Errorf("更新账单记录失败: %w", err)
 	}
 
-	return err
+	// 首次同步时订单可能已经退款
+	if err := o.reverseRefundCommission(ctx, userID, userOrder, data, variantIDMap, tasks); err != nil {
+		return fmt.Errorf("冲减退款抽成失败: %w", err)
+	}
+
+	return nil
 }
 
 func (o *OrderService) sliceToMap(slice []int64) map[int64]struct{} {
@@ -310,250 +390,390 @@ func (o *OrderService) parseRefundInfo(data *shopifys.OrderResponse) (map[int64]
 	return refundMap, refundAmount
 }
 
-func (o *OrderService) HandleOrderStatistics(ctx context.Context, t *asynq.Task) error {
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Error(ctx, "order_statistic_queue", fmt.Sprintf("panic捕获: %v ", r))
-		}
-	}()
+func (o *OrderService) ok(ctx context.Context, jobId int64) error {
+	logger.Info(ctx, "order_queue", fmt.Sprintf("JobId: %d => 成功完成", jobId))
+	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 1) // 1 表示成功
+	return nil
+}
+
+func (o *OrderService) fail(ctx context.Context, jobId int64, msg string, err error) error {
+	logger.Error(ctx, fmt.Sprintf("order_queue: JobId: %d => %s: %v", jobId, msg, err))
+	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 3) // 3 表示失败
+	return nil
+}
 
-	var payload jobs.OrderStatisticPayload
+func (o *OrderService) skip(ctx context.Context, jobId int64, reason string) error {
+	logger.Info(ctx, fmt.Sprintf("order_queue: JobId: %d => 跳过: %s", jobId, reason))
+	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 2) // 2 表示跳过
+	return nil
+}
 
-	err := json.Unmarshal(t.Payload(), &payload)
+// calculateCommission 根据用户设置计算佣金，订单按购物车插件报价时的同一套规则匹配
+// orderSubtotal 是订单商品小计，不包含运费和税
+func (o *OrderService) calculateCommission(ctx context.Context, cartSetting *cartEntity.UserCartSetting, protectifyAmount float64, orderSubtotal decimal.Decimal) (float64, float64, error) {
+	protectifyPrice := decimal.NewFromFloat(protectifyAmount)
+	// 报价时购物车金额不包含保险商品
+	subtotal := orderSubtotal.Sub(protectifyPrice)
+	quote, err := pricing.Evaluate(cartSetting, subtotal)
 	if err != nil {
-		logger.Error(ctx, "order_statistic_queue:payload 反序列化失败", err)
-		return nil
+		logger.Error(ctx, "order_queue 匹配保险价格规则失败", "Err:", err.Error())
+		return 0, 0, fmt.Errorf("未找到匹配的价格或比例区间: %w", err)
 	}
 
-	userID := payload.UserID
-	start := payload.Start
-	end := payload.End
+	commissionRate := quote.Rate()
+	commissionAmount := protectifyPrice.Mul(commissionRate)
+	if quote.PricingType == pricing.TypePrice {
+		// 按金额计价时佣金为报价金额，费率按实际收取的保险金额折算
+		commissionAmount = quote.Price
+		if protectifyPrice.IsPositive() {
+			commissionRate = quote.Price.Div(protectifyPrice)
+		}
+	}
+	return utils.DecimalToFloat(commissionAmount), utils.DecimalToFloat(commissionRate), nil
+}
 
-	batchSize := 300
-	// 获取全部userID 一次性300
-	userList, err := o.userRepo.GetUsers(ctx, userID, batchSize)
+// planCommission 按订阅套餐的抽成费率表计算佣金，套餐没有匹配的档位时返回 false
+func (o *OrderService) planCommission(ctx context.Context, subscription *userEntity.UserSubscription, protectifyAmount float64) (float64, float64, bool, error) {
+	if subscription == nil || subscription.PlanId == 0 {
+		return 0, 0, false, nil
+	}
+	plan, err := o.planRepo.Get(ctx, subscription.PlanId)
 	if err != nil {
-		logger.Error(ctx, "order_statistic_queue:获取用户信息失败", err)
-		return nil
+		return 0, 0, false, fmt.Errorf("获取订阅套餐失败: %w", err)
 	}
+	if plan == nil {
+		return 0, 0, false, nil
+	}
+	rate, ok := plan.CommissionRate(protectifyAmount)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	commissionAmount := decimal.NewFromFloat(protectifyAmount).Mul(rate).Round(2)
+	return utils.DecimalToFloat(commissionAmount), utils.DecimalToFloat(rate), true, nil
+}
 
-	if userList == nil || len(userList) == 0 {
-		logger.Info(ctx, "order_statistic_queue:执行完毕")
+// updateBillingRecords 保存订单的抽成账单，订单到达发货规则要求的阶段后确认抽成并提交结算
+func (o *OrderService) updateBillingRecords(ctx context.Context, userID int64, order *orders.UserOrder, data *shopifys.OrderResponse, tasks *orderTasks) error {
+	// 没有购买保险的订单和回填的历史订单不产生抽成
+	if order.ProtectifyAmount <= 0 || order.Backfilled == 1 {
 		return nil
 	}
 
-	var lastUserID int64 // 用于存储最后一个处理的用户 userID
-	// 处理这一批
-	for _, user := range userList {
-		lastUserID = user.ID
-		// 获取订单数据 订单数据 日退款数据
-		statistics, err := o.orderRepo.GetOrderStatistics(ctx, start, end, user.ID)
+	cartSetting, err := o.cartSettingRepo.First(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("获取用户购物车设置失败: %w", err)
+	}
+	if cartSetting == nil {
+		return fmt.Errorf("用户购物车设置不存在")
+	}
 
+	// 每个订单只生成一条账单，订单更新时不重复计费
+	bill, err := o.commissionRepo.GetByUserOrder(ctx, userID, order.Id)
+	if err != nil {
+		return fmt.Errorf("查询订单账单失败: %w", err)
+	}
+	if bill == nil {
+		bill, err = o.createCommissionBill(ctx, userID, order, data, cartSetting)
 		if err != nil {
-			logger.Error(ctx, "order_statistic_queue:查询统计失败", err)
-			continue
+			return err
 		}
+	}
+	if bill.RecognitionStatus == billings.RecognitionStatusRecognized {
+		return nil
+	}
+
+	// 未到达发货规则要求的阶段，抽成保持待确认
+	if !o.commissionRecognizable(cartSetting.FulfillmentRule, &data.Order) {
+		logger.Info(ctx, "order_queue", fmt.Sprintf("订单 %s 发货状态 %s 未满足发货规则 %d，抽成待确认",
+			order.OrderName, data.Order.DisplayFulfillmentStatus, cartSetting.FulfillmentRule))
+		return nil
+	}
+
+	return o.recognizeBill(ctx, bill, order, tasks)
+}
+
+// createCommissionBill 计算订单抽成并保存待确认的账单
+func (o *OrderService) createCommissionBill(ctx context.Context, userID int64, order *orders.UserOrder, data *shopifys.OrderResponse, cartSetting *cartEntity.UserCartSetting) (*billings.CommissionBill, error) {
+	// 获取用户当前的订阅信息，没有订阅时账单先保存，由补偿任务在订阅后提交
+	subscription, err := o.subscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户订阅信息失败: %w", err)
+	}
+	var subscriptionID int64
+	if subscription != nil {
+		subscriptionID = subscription.ID
+	}
 
-		orderStatisticId, err := o.orderSummaryRepo.ExistOrder(ctx, user.ID, start)
+	// 订阅套餐配置了抽成费率表时按套餐费率，否则按购物车设置计算
+	commissionAmount, commissionRate, matched, err := o.planCommission(ctx, subscription, order.ProtectifyAmount)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		commissionAmount, commissionRate, err = o.calculateCommission(ctx, cartSetting, order.ProtectifyAmount, data.Order.SubtotalPriceSet.ShopMoney.Amount)
 		if err != nil {
-			logger.Error(ctx, "order_statistic_queue:查询每日记录失败", err)
-			continue
-		}
-		orderSummary := orders.OrderSummary{
-			Today:  start,
-			Orders: statistics.TotalOrders,
-			Refund: statistics.TotalRefund,
-			Sales:  statistics.TotalProtectify,
+			return nil, fmt.Errorf("计算佣金失败: %w", err)
 		}
+	}
 
-		if orderStatisticId > 0 {
-			orderSummary.Id = orderStatisticId
-			o.orderSummaryRepo.UpsertOrderStatistics(ctx, orderSummary)
-		} else {
-			orderSummary.UserID = user.ID
-			o.orderSummaryRepo.CrateOrderStatistics(ctx, orderSummary)
-		}
+	bill := &billings.CommissionBill{
+		ChargeId:              order.OrderId,
+		UserId:                userID,
+		UserOrderId:           order.Id,
+		OrderName:             order.OrderName,
+		CommissionAmount:      commissionAmount,
+		CommissionRate:        commissionRate,
+		Currency:              order.Currency,
+		SubscriptionId:        subscriptionID,
+		OrderProtectifyAmount: order.ProtectifyAmount,
+		OrderTotalAmount:      order.TotalPriceAmount,
+		ChargeStatus:          billings.ChargeStatusPending,
+		RecognitionStatus:     billings.RecognitionStatusDeferred,
 	}
+	if _, err := o.commissionRepo.CreateBill(ctx, bill); err != nil {
+		return nil, fmt.Errorf("保存抽成账单失败: %w", err)
+	}
+	return bill, nil
+}
 
-	// 递归处理下一批用户
-	if lastUserID != 0 {
-		payload := jobs.OrderStatisticPayload{UserID: userID, Start: start, End: end}
-		data, err := json.Marshal(payload)
-		if err != nil {
-			logger.Error(ctx, "order_statistic_queue 构建任务失败:", err.Error())
-			return nil
+// commissionRecognizable 根据购物车设置的发货规则判断订单抽成是否可以确认
+func (o *OrderService) commissionRecognizable(fulfillmentRule int, order *shopifys.Order) bool {
+	switch fulfillmentRule {
+	case cartEntity.FulfillmentRulePaid:
+		return true
+	case cartEntity.FulfillmentRuleAllFulfilled:
+		return order.DisplayFulfillmentStatus == "FULFILLED"
+	default:
+		if order.DisplayFulfillmentStatus == "FULFILLED" || order.DisplayFulfillmentStatus == "PARTIALLY_FULFILLED" {
+			return true
 		}
-		orderStatisticTask := asynq.NewTask(config.SendOrderStatistics, data)
-		err = o.HandleOrderStatistics(ctx, orderStatisticTask)
-		if err != nil {
-			return err
+		for _, fulfillment := range order.Fulfillments {
+			if fulfillment.Status == "SUCCESS" {
+				return true
+			}
 		}
-		return nil
+		return false
 	}
-	return nil
 }
 
-func (o *OrderService) ok(ctx context.Context, jobId int64) error {
-	logger.Info(ctx, "order_queue", fmt.Sprintf("JobId: %d => 成功完成", jobId))
-	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 1) // 1 表示成功
-	return nil
-}
+// recognizeBill 确认账单抽成，计入当前账期汇总，事务提交后推送结算任务
+func (o *OrderService) recognizeBill(ctx context.Context, bill *billings.CommissionBill, order *orders.UserOrder, tasks *orderTasks) error {
+	period, err := o.currentBillingPeriod(ctx, bill.UserId)
+	if err != nil {
+		return err
+	}
+	recognized, err := o.commissionRepo.Recognize(ctx, bill.Id, period.Start, period.End, period.BillCycle)
+	if err != nil {
+		return fmt.Errorf("确认账单抽成失败: %w", err)
+	}
+	if !recognized {
+		return nil
+	}
 
-func (o *OrderService) fail(ctx context.Context, jobId int64, msg string, err error) error {
-	logger.Error(ctx, fmt.Sprintf("order_queue: JobId: %d => %s: %v", jobId, msg, err))
-	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 3) // 3 表示失败
-	return nil
-}
+	// 更新billing_period_summary，确认前已被退款冲减的部分不计入
+	commissionAmount := bill.ChargeAmount()
+	summary, err := o.ensurePeriodSummary(ctx, bill.UserId, bill.SubscriptionId, period, bill.Currency)
+	if err != nil {
+		return err
+	}
+	// 多个订单任务可能同时更新同一个账期，直接在数据库里累加
+	err = o.billingPeriodRepo.AddRecognizedBill(ctx, summary.Key(), &billings.SummaryDelta{
+		OrderCount:            1,
+		BillCount:             1,
+		TotalCommissionAmount: commissionAmount,
+		PendingAmount:         commissionAmount,
+		TotalProtectifyAmount: order.ProtectifyAmount,
+		TotalOrderAmount:      order.TotalPriceAmount,
+		TotalRefundAmount:     order.RefundPriceAmount,
+	})
+	if err != nil {
+		return fmt.Errorf("更新账期汇总失败: %w", err)
+	}
 
-func (o *OrderService) skip(ctx context.Context, jobId int64, reason string) error {
-	logger.Info(ctx, fmt.Sprintf("order_queue: JobId: %d => 跳过: %s", jobId, reason))
-	_ = o.jobOrderRepo.UpdateStatus(ctx, jobId, 2) // 2 表示跳过
+	tasks.settleBillIDs = append(tasks.settleBillIDs, bill.Id)
 	return nil
 }
 
-// calculateCommission 根据用户设置计算佣金
-func (o *OrderService) calculateCommission(ctx context.Context, userID int64, protectifyAmount float64, orderTotalAmount float64) (float64, float64, error) {
-	// 获取用户的购物车设置
-	cartSetting, err := o.cartSettingRepo.First(ctx, userID)
+// currentBillingPeriod 按用户订阅的计费周期计算当前账单周期
+func (o *OrderService) currentBillingPeriod(ctx context.Context, userID int64) (billings.BillingPeriod, error) {
+	subscription, err := o.subscriptionRepo.GetActiveSubscription(ctx, userID)
 	if err != nil {
-		return 0, 0, fmt.Errorf("获取用户购物车设置失败: %w", err)
-	}
-	totalAmount := decimal.NewFromFloat(orderTotalAmount)
-	var commissionAmount decimal.Decimal
-	var commissionRate decimal.Decimal
-	// 解析 PricingSelect
-	var prices []cartEntity.PriceSelectReq
-	if err := json.Unmarshal([]byte(cartSetting.PricingSelect), &prices); err != nil {
-		logger.Error(ctx, "get-cart 解析 PricingSelect 失败", "Err:", err.Error())
-		return 0, 0, fmt.Errorf("解析 PricingSelect 失败: %w", err)
+		return billings.BillingPeriod{}, fmt.Errorf("获取用户订阅信息失败: %w", err)
 	}
+	return billings.CalcBillingPeriod(subscription, time.Now()), nil
+}
 
-	// 解析 TiersSelect
-	var tiers []cartEntity.TierSelectReq
-	if err := json.Unmarshal([]byte(cartSetting.TiersSelect), &tiers); err != nil {
-		logger.Error(ctx, "get-cart 解析 TiersSelect 失败", "Err:", err.Error())
-		return 0, 0, fmt.Errorf("解析 TiersSelect 失败: %w", err)
-	}
-	protectifyPrice := decimal.NewFromFloat(protectifyAmount)
-	if cartSetting.PricingRule == 0 {
-		if cartSetting.PricingType == 0 {
-			commissionRate = decimal.NewFromFloat(cartSetting.AllPriceSet).Div(protectifyPrice)
+// protectifyQuantity 统计订单中保险商品的购买数量和退款数量
+func (o *OrderService) protectifyQuantity(data *shopifys.OrderResponse, variantIDMap map[int64]struct{}) (int, int) {
+	var quantity, refundQuantity int
+	for _, lineItem := range data.Order.LineItems.Edges {
+		variantID := utils.GetIdFromShopifyGraphqlId(lineItem.Node.Variant.ID)
+		if _, ok := variantIDMap[variantID]; ok {
+			quantity += lineItem.Node.Quantity
 		}
-	} else {
-		// 按金额计算
-		if cartSetting.PricingType == 0 {
-			for _, priceRange := range prices {
-				minRange := utils.ParseMoneyDecimal(priceRange.Min)
-				maxRange := utils.ParseMoneyDecimal(priceRange.Max)
-				if totalAmount.GreaterThanOrEqual(minRange) && (totalAmount.LessThanOrEqual(maxRange) || maxRange.Equal(decimal.Zero)) {
-					commissionAmount := utils.ParseMoneyDecimal(priceRange.Price)
-					// 计算实际费率
-					if protectifyAmount > 0 {
-						commissionRate = commissionAmount.Div(protectifyPrice)
-					}
-					break
-				}
-			}
-		} else { // 按比例计算
-			for _, tier := range tiers {
-				minRange := utils.ParseMoneyDecimal(tier.Min)
-				maxRange := utils.ParseMoneyDecimal(tier.Max)
-				if totalAmount.GreaterThanOrEqual(minRange) && (totalAmount.LessThanOrEqual(maxRange) || maxRange.Equal(decimal.Zero)) {
-					percentage := utils.ParseMoneyDecimal(tier.Percentage)
-					commissionRate = percentage.Div(decimal.NewFromInt(100)) // 转换百分比为小数
-					commissionAmount = protectifyPrice.Mul(commissionRate)
-					break
-				}
+	}
+	for _, refund := range data.Order.Refunds {
+		for _, item := range refund.RefundLineItems.Edges {
+			variantID := utils.GetIdFromShopifyGraphqlId(item.Node.LineItem.Variant.ID)
+			if _, ok := variantIDMap[variantID]; ok {
+				refundQuantity += item.Node.Quantity
 			}
 		}
 	}
+	return quantity, refundQuantity
+}
 
-	// 如果没有找到匹配的价格区间或比例区间
-	if commissionAmount == decimal.Zero && commissionRate == decimal.Zero {
-		return 0, 0, fmt.Errorf("未找到匹配的价格或比例区间")
+// reverseRefundCommission 保险商品退款后按退款比例生成负数调整流水。
+// 冲减金额优先抵扣还没有扣费的账单，抵扣不完的部分通过 app credit 返还。
+func (o *OrderService) reverseRefundCommission(ctx context.Context, userID int64, order *orders.UserOrder, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}, tasks *orderTasks) error {
+	if len(data.Order.Refunds) == 0 || order.Backfilled == 1 {
+		return nil
+	}
+	bill, err := o.commissionRepo.GetByUserOrder(ctx, userID, order.Id)
+	if err != nil {
+		return fmt.Errorf("查询订单账单失败: %w", err)
+	}
+	if bill == nil {
+		return nil
 	}
 
-	return utils.DecimalToFloat(commissionAmount), utils.DecimalToFloat(commissionRate), nil
-}
+	quantity, refundQuantity := o.protectifyQuantity(data, variantIDMap)
+	if quantity == 0 || refundQuantity == 0 {
+		return nil
+	}
+	if refundQuantity > quantity {
+		refundQuantity = quantity
+	}
 
-// updateBillingRecords 更新订单相关的账单记录和周期汇总
-func (o *OrderService) updateBillingRecords(ctx context.Context, userID int64, order *orders.UserOrder, orderInfo []*orders.UserOrderInfo) error {
-	// 1. 获取用户当前的订阅信息
-	subscription, err := o.subscriptionRepo.GetActiveSubscription(ctx, userID)
+	// 按退款比例计算累计应冲减金额，减去已经记录的冲减即为本次需要冲减的金额
+	target := decimal.NewFromFloat(bill.CommissionAmount).
+		Mul(decimal.NewFromInt(int64(refundQuantity))).
+		Div(decimal.NewFromInt(int64(quantity))).
+		Round(2)
+	reversed, err := o.adjustmentRepo.SumByUserOrder(ctx, userID, order.Id)
 	if err != nil {
-		return fmt.Errorf("获取用户订阅信息失败: %w", err)
+		return fmt.Errorf("查询订单调整流水失败: %w", err)
+	}
+	delta := target.Add(decimal.NewFromFloat(reversed))
+	if !delta.IsPositive() {
+		return nil
 	}
 
-	// 2. 计算佣金金额
-	commissionAmount, commissionRate, err := o.calculateCommission(ctx, userID, order.ProtectifyAmount, order.TotalPriceAmount)
-	if err != nil {
-		return fmt.Errorf("计算佣金失败: %w", err)
+	adjustment := &billings.CommissionAdjustment{
+		UserId:         userID,
+		BillId:         bill.Id,
+		UserOrderId:    order.Id,
+		OrderName:      bill.OrderName,
+		AdjustmentType: billings.AdjustmentTypeRefund,
+		Amount:         utils.DecimalToFloat(delta.Neg()),
+		Currency:       bill.Currency,
+		Status:         billings.AdjustmentStatusAbsorbed,
 	}
 
-	// 3. 计算账单周期
-	now := time.Now().Unix()
-	billCycle := time.Unix(now, 0).Format("2006-01")
-	businessMonth := billCycle
+	// 抽成还没确认时直接冲减账单本身，确认时只会按冲减后的金额计入账期
+	if bill.RecognitionStatus == billings.RecognitionStatusDeferred {
+		if _, err := o.commissionRepo.DeductCommission(ctx, bill.Id, utils.DecimalToFloat(delta)); err != nil {
+			return fmt.Errorf("冲减账单 %d 失败: %w", bill.Id, err)
+		}
+		adjustment.AbsorbedAmount = utils.DecimalToFloat(delta)
+		if _, err := o.adjustmentRepo.Create(ctx, adjustment); err != nil {
+			return fmt.Errorf("保存调整流水失败: %w", err)
+		}
+		return nil
+	}
 
-	// 4. 创建commission_bill记录
-	_ = &billings.CommissionBill{
-		UserId:                userID,
-		UserOrderId:           order.Id,
-		OrderName:             order.OrderName,
-		BillCycle:             billCycle,
-		CommissionAmount:      commissionAmount,
-		CommissionRate:        commissionRate,
-		Currency:              order.Currency,
-		SubscriptionId:        subscription.ID,
-		OrderProtectifyAmount: order.ProtectifyAmount,
-		OrderTotalAmount:      order.TotalPriceAmount,
-		ChargeStatus:          0, // 待提交
-		CreateTime:            now,
-		UpdateTime:            now,
+	period, err := o.currentBillingPeriod(ctx, userID)
+	if err != nil {
+		return err
+	}
+	summary, err := o.ensurePeriodSummary(ctx, userID, bill.SubscriptionId, period, bill.Currency)
+	if err != nil {
+		return err
 	}
 
-	// 5. 更新billing_period_summary
-	periodEnd := time.Date(time.Now().Year(), time.Now().Month()+1, 1, 0, 0, 0, 0, time.UTC).Unix() - 1
-	summary, err := o.billingPeriodRepo.GetByCurrentPeriod(ctx, userID, periodEnd)
+	// 优先抵扣本订单还没扣费的账单，再抵扣退款所在账期内其它未扣费的账单
+	candidates := make([]*billings.CommissionBill, 0)
+	if bill.ChargeStatus != billings.ChargeStatusCharged {
+		candidates = append(candidates, bill)
+	}
+	periodBills, err := o.commissionRepo.UnchargedBills(ctx, userID, period.End)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			// 创建新的周期汇总
-			summary = &billings.BillingPeriodSummary{
-				UserId:                userID,
-				BillingPeriodEnd:      periodEnd,
-				BillingPeriodStart:    time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.UTC).Unix(),
-				BillCycle:             billCycle,
-				BusinessMonth:         businessMonth,
-				Currency:              order.Currency,
-				OrderCount:            1,
-				BillCount:             1,
-				TotalCommissionAmount: commissionAmount,
-				PendingAmount:         commissionAmount,
-				TotalProtectifyAmount: order.ProtectifyAmount,
-				TotalOrderAmount:      order.TotalPriceAmount,
-				TotalRefundAmount:     order.RefundPriceAmount,
-				Version:               1,
-			}
-			_, err = o.billingPeriodRepo.CreateBillingPeriodSummary(ctx, summary)
+		return fmt.Errorf("查询账期未扣费账单失败: %w", err)
+	}
+	for _, periodBill := range periodBills {
+		if periodBill.Id != bill.Id {
+			candidates = append(candidates, periodBill)
+		}
+	}
+
+	remaining := delta
+	for _, candidate := range candidates {
+		if !remaining.IsPositive() {
+			break
+		}
+		available := decimal.NewFromFloat(candidate.ChargeAmount())
+		if !available.IsPositive() {
+			continue
+		}
+		take := decimal.Min(available, remaining)
+		ok, err := o.commissionRepo.DeductCommission(ctx, candidate.Id, utils.DecimalToFloat(take))
+		if err != nil {
+			return fmt.Errorf("冲减账单 %d 失败: %w", candidate.Id, err)
+		}
+		if !ok {
+			continue
+		}
+		remaining = remaining.Sub(take)
+
+		var pendingAbsorbed, errorAbsorbed float64
+		if candidate.ChargeStatus == billings.ChargeStatusFailed {
+			errorAbsorbed = utils.DecimalToFloat(take)
 		} else {
-			return fmt.Errorf("查询账期汇总失败: %w", err)
+			pendingAbsorbed = utils.DecimalToFloat(take)
 		}
-	} else {
-		// 更新现有周期汇总
-		summary.OrderCount += 1
-		summary.BillCount += 1
-		summary.TotalCommissionAmount += commissionAmount
-		summary.PendingAmount += commissionAmount
-		summary.TotalProtectifyAmount += order.ProtectifyAmount
-		summary.TotalOrderAmount += order.TotalPriceAmount
-		summary.TotalRefundAmount += order.RefundPriceAmount
-		summary.Version += 1
-
-		err = o.billingPeriodRepo.UpdateBillingPeriodSummary(ctx, summary)
+		if err := o.billingPeriodRepo.ApplyAdjustment(ctx, candidate.PeriodKey(), 0, pendingAbsorbed, errorAbsorbed); err != nil {
+			return fmt.Errorf("更新账期汇总失败: %w", err)
+		}
+	}
+
+	adjustment.AbsorbedAmount = utils.DecimalToFloat(delta.Sub(remaining))
+	adjustment.CreditAmount = utils.DecimalToFloat(remaining)
+	adjustment.BillingPeriodEnd = period.End
+	adjustment.SubscriptionId = summary.SubscriptionId
+	adjustment.BillCycle = summary.BillCycle
+	if remaining.IsPositive() {
+		adjustment.Status = billings.AdjustmentStatusPending
 	}
+	adjustmentID, err := o.adjustmentRepo.Create(ctx, adjustment)
 	if err != nil {
+		return fmt.Errorf("保存调整流水失败: %w", err)
+	}
+	if err := o.billingPeriodRepo.ApplyAdjustment(ctx, summary.Key(), adjustment.Amount, 0, 0); err != nil {
 		return fmt.Errorf("更新账期汇总失败: %w", err)
 	}
 
+	// 账期内抵扣不完的部分通过 app credit 返还
+	if remaining.IsPositive() {
+		tasks.creditAdjustmentIDs = append(tasks.creditAdjustmentIDs, adjustmentID)
+	}
 	return nil
 }
+
+// ensurePeriodSummary 查询账期汇总，不存在时创建一条空的汇总
+func (o *OrderService) ensurePeriodSummary(ctx context.Context, userID int64, subscriptionID int64, period billings.BillingPeriod, currency string) (*billings.BillingPeriodSummary, error) {
+	summary := &billings.BillingPeriodSummary{
+		UserId:             userID,
+		SubscriptionId:     subscriptionID,
+		BillingPeriodStart: period.Start,
+		BillingPeriodEnd:   period.End,
+		BillCycle:          period.BillCycle,
+		BusinessMonth:      period.BusinessMonth,
+		Currency:           currency,
+		Version:            1,
+	}
+	summary, err := o.billingPeriodRepo.EnsurePeriodSummary(ctx, summary)
+	if err != nil {
+		return nil, fmt.Errorf("创建账期汇总失败: %w", err)
+	}
+	return summary, nil
+}
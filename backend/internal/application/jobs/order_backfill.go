@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"backend/internal/domain/entity/jobs"
+	"backend/internal/domain/entity/orders"
+	"backend/internal/domain/entity/shopifys"
+	"backend/internal/infras/shopify_graphql"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+const (
+	// backfillPollInterval 查询批量操作状态的间隔
+	backfillPollInterval = 5 * time.Second
+	// backfillPollTimeout 单次任务等待批量查询的时间，超时后延迟重新推送任务，不长时间占用 worker
+	backfillPollTimeout = 2 * time.Minute
+	// backfillPollDelay 批量查询未完成时下一次检查的延迟
+	backfillPollDelay = 30 * time.Second
+	// backfillProgressBatch 每导入多少个订单保存一次进度
+	backfillProgressBatch = 50
+)
+
+// HandleOrderBackfill 历史订单回填：提交 Shopify 批量查询，完成后流式读取结果导入订单
+func (o *OrderService) HandleOrderBackfill(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.OrderBackfillPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "order_backfill_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	job, err := o.backfillRepo.First(ctx, payload.JobId)
+	if err != nil {
+		return fmt.Errorf("查询回填任务失败: %w", err)
+	}
+	if job == nil || job.Finished() {
+		return nil
+	}
+	logger.Info(ctx, "order_backfill_queue", fmt.Sprintf("开始处理回填任务: %d 状态: %d", job.Id, job.Status))
+
+	if err := o.processBackfill(ctx, job); err != nil {
+		// 最后一次重试仍然失败时结束任务，商家可以重新发起
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if retried >= maxRetry {
+			return o.failBackfill(ctx, job, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (o *OrderService) processBackfill(ctx context.Context, job *jobs.JobOrderBackfill) error {
+	user, err := o.userRepo.Get(ctx, job.UserID)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.ID == 0 || user.IsDel != 0 {
+		return o.failBackfill(ctx, job, "用户不存在或已卸载")
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+
+	if job.Status == jobs.BackfillStatusPending {
+		// 店铺已有批量查询在运行时 Shopify 会拒绝，由重试再次提交
+		operation, err := o.bulkOperationRepo.RunOrdersQuery(ctx, job.CreatedFrom, job.CreatedTo)
+		if err != nil {
+			return fmt.Errorf("提交批量查询失败: %w", err)
+		}
+		job.BulkOperationId = operation.ID
+		job.Status = jobs.BackfillStatusRunning
+		if err := o.backfillRepo.Update(ctx, job, "bulk_operation_id", "status"); err != nil {
+			return fmt.Errorf("保存批量查询ID失败: %w", err)
+		}
+	}
+
+	if job.Status == jobs.BackfillStatusRunning {
+		waitCtx, cancel := context.WithTimeout(ctx, backfillPollTimeout)
+		operation, err := o.bulkOperationRepo.Wait(waitCtx, job.BulkOperationId, backfillPollInterval)
+		timeout := waitCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if timeout {
+			_, err = o.asynqRepo.OrderBackfillTask(ctx, job.Id, backfillPollDelay)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("查询批量操作状态失败: %w", err)
+		}
+		if operation.Status != shopifys.BulkOperationCompleted {
+			return o.failBackfill(ctx, job, fmt.Sprintf("批量查询未完成: %s %s", operation.Status, operation.ErrorCode))
+		}
+		job.ResultUrl = operation.URL
+		job.ObjectCount = operation.Objects()
+		job.Status = jobs.BackfillStatusImporting
+		if err := o.backfillRepo.Update(ctx, job, "result_url", "object_count", "status"); err != nil {
+			return fmt.Errorf("保存批量查询结果失败: %w", err)
+		}
+	}
+
+	return o.importBackfillOrders(ctx, job)
+}
+
+// importBackfillOrders 流式读取批量查询结果导入订单，中断后从 ProcessedCount 继续
+func (o *OrderService) importBackfillOrders(ctx context.Context, job *jobs.JobOrderBackfill) error {
+	// 没有订单时 Shopify 不生成结果文件
+	if job.ResultUrl != "" {
+		uploadedVariantIDs, err := o.variantRepo.GetUploadedVariantIDs(ctx, job.UserID)
+		if err != nil {
+			return fmt.Errorf("查询已上传变体失败: %w", err)
+		}
+		variantIDMap := o.sliceToMap(uploadedVariantIDs)
+
+		var index int64
+		reader := shopifys.NewBulkOrderReader(func(data *shopifys.OrderResponse) error {
+			index++
+			if index <= job.ProcessedCount {
+				return nil
+			}
+			imported, protectify, err := o.backfillOrder(ctx, job.UserID, data, variantIDMap)
+			if err != nil {
+				return fmt.Errorf("导入订单 %s 失败: %w", data.Order.Name, err)
+			}
+			job.ProcessedCount = index
+			if !imported {
+				job.SkippedCount++
+			} else {
+				job.ImportedCount++
+				if protectify {
+					job.ProtectifyCount++
+				}
+			}
+			if index%backfillProgressBatch == 0 {
+				return o.saveBackfillProgress(ctx, job)
+			}
+			return nil
+		})
+		err = o.bulkOperationRepo.Download(ctx, job.ResultUrl, reader.Add)
+		if err == nil {
+			err = reader.Flush()
+		}
+		if err != nil {
+			if saveErr := o.saveBackfillProgress(ctx, job); saveErr != nil {
+				logger.Error(ctx, "order_backfill_queue: 保存回填进度失败", saveErr)
+			}
+			return err
+		}
+	}
+
+	if err := o.refreshBackfillStatistics(ctx, job); err != nil {
+		logger.Error(ctx, "order_backfill_queue: 更新订单统计失败", err)
+	}
+
+	job.Status = jobs.BackfillStatusCompleted
+	job.FinishTime = time.Now().Unix()
+	if err := o.backfillRepo.Update(ctx, job, "status", "finish_time", "processed_count", "imported_count", "skipped_count", "protectify_count"); err != nil {
+		return fmt.Errorf("保存回填结果失败: %w", err)
+	}
+	logger.Info(ctx, "order_backfill_queue", fmt.Sprintf("回填任务 %d 完成，导入 %d 个订单，其中 %d 个包含保险",
+		job.Id, job.ImportedCount, job.ProtectifyCount))
+	return nil
+}
+
+// backfillOrder 和订单 webhook 使用相同的支付状态过滤和保险商品识别，历史订单不产生抽成
+func (o *OrderService) backfillOrder(ctx context.Context, userID int64, data *shopifys.OrderResponse, variantIDMap map[int64]struct{}) (bool, bool, error) {
+	if !paidFinancialStatuses[data.Order.DisplayFinancialStatus] {
+		return false, false, nil
+	}
+	orderID := utils.GetIdFromShopifyGraphqlId(data.Order.ID)
+
+	// 批量查询结果没有退款明细，有退款的订单单独拉取
+	if data.Order.DisplayFinancialStatus == "PARTIALLY_REFUNDED" || data.Order.DisplayFinancialStatus == "REFUNDED" {
+		var err error
+		data, err = o.orderGraphqlRepo.GetOrderInfo(ctx, orderID)
+		if err != nil {
+			return false, false, fmt.Errorf("拉取Shopify订单信息失败: %w", err)
+		}
+	}
+
+	imported := false
+	err := o.txRepo.Transaction(ctx, func(ctx context.Context) error {
+		// 已经通过 webhook 同步的订单保持原样，它们的抽成账单已经生成
+		if o.orderRepo.ExistsByOrderID(ctx, orderID, userID) > 0 {
+			return nil
+		}
+		imported = true
+		return o.createNewOrder(ctx, userID, data, variantIDMap, false, nil)
+	})
+	if err != nil || !imported {
+		return false, false, err
+	}
+
+	for _, lineItem := range data.Order.LineItems.Edges {
+		if _, ok := variantIDMap[utils.GetIdFromShopifyGraphqlId(lineItem.Node.Variant.ID)]; ok {
+			return true, true, nil
+		}
+	}
+	return true, false, nil
+}
+
+// refreshBackfillStatistics 重新统计回填范围内每天的订单数据，订单看板才能看到历史订单
+func (o *OrderService) refreshBackfillStatistics(ctx context.Context, job *jobs.JobOrderBackfill) error {
+	user, err := o.userRepo.Get(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	loc := user.Location()
+	for _, day := range orders.DaysBetween(time.Unix(job.CreatedFrom, 0), time.Unix(job.CreatedTo, 0), loc) {
+		if err := o.saveOrderStatistics(ctx, job.UserID, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OrderService) saveBackfillProgress(ctx context.Context, job *jobs.JobOrderBackfill) error {
+	return o.backfillRepo.Update(ctx, job, "processed_count", "imported_count", "skipped_count", "protectify_count")
+}
+
+func (o *OrderService) failBackfill(ctx context.Context, job *jobs.JobOrderBackfill, reason string) error {
+	logger.Error(ctx, fmt.Sprintf("order_backfill_queue: 回填任务 %d 失败: %s", job.Id, reason))
+	if runes := []rune(reason); len(runes) > 500 {
+		reason = string(runes[:500])
+	}
+	job.Status = jobs.BackfillStatusFailed
+	job.ErrorMessage = reason
+	job.FinishTime = time.Now().Unix()
+	return o.backfillRepo.Update(ctx, job, "status", "error_message", "finish_time", "processed_count", "imported_count", "skipped_count", "protectify_count")
+}
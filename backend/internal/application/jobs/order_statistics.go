@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"backend/internal/domain/entity/jobs"
+	"backend/internal/domain/entity/orders"
+	userEntity "backend/internal/domain/entity/users"
+	"backend/pkg/logger"
+)
+
+// statisticsBatchSize 每批统计的用户数
+const statisticsBatchSize = 300
+
+// HandleOrderStatistics 按每个店铺的时区统计最近几天的订单，定时任务每小时执行一次，前一天的数据在第二天继续修正
+func (o *OrderService) HandleOrderStatistics(ctx context.Context, t *asynq.Task) error {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(ctx, "order_statistic_queue", fmt.Sprintf("panic捕获: %v ", r))
+		}
+	}()
+
+	var payload jobs.OrderStatisticPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "order_statistic_queue:payload 反序列化失败", err)
+		return nil
+	}
+	at := time.Now()
+	if payload.At > 0 {
+		at = time.Unix(payload.At, 0)
+	}
+	days := max(payload.Days, 1)
+
+	cursor := payload.UserID
+	for {
+		userList, err := o.userRepo.GetUsers(ctx, cursor, statisticsBatchSize)
+		if err != nil {
+			logger.Error(ctx, "order_statistic_queue:获取用户信息失败", err)
+			return nil
+		}
+		if len(userList) == 0 {
+			logger.Info(ctx, "order_statistic_queue:执行完毕")
+			return nil
+		}
+		for _, user := range userList {
+			cursor = user.ID
+			loc := user.Location()
+			today := orders.DayOf(at, loc)
+			from := time.Unix(today.Start, 0).In(loc).AddDate(0, 0, 1-days)
+			for _, day := range orders.DaysBetween(from, time.Unix(today.End, 0), loc) {
+				if err := o.saveOrderStatistics(ctx, user.ID, day); err != nil {
+					logger.Error(ctx, "order_statistic_queue:", err)
+				}
+			}
+		}
+	}
+}
+
+// HandleSummaryMigrate 按店铺时区重新计算已有的每日统计，覆盖之前按美东时间划分的数据，
+// 店铺修改时区后也用它重新计算
+func (o *OrderService) HandleSummaryMigrate(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.SummaryMigratePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "summary_migrate_queue:payload 反序列化失败", err)
+		return nil
+	}
+
+	if payload.UserID > 0 {
+		user, err := o.userRepo.Get(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("查询用户信息失败: %w", err)
+		}
+		if user == nil {
+			return nil
+		}
+		return o.migrateSummary(ctx, user)
+	}
+
+	userList, err := o.userRepo.GetUsers(ctx, payload.Cursor, statisticsBatchSize)
+	if err != nil {
+		return fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if len(userList) == 0 {
+		logger.Info(ctx, "summary_migrate_queue:执行完毕")
+		return nil
+	}
+	for _, user := range userList {
+		if err := o.migrateSummary(ctx, user); err != nil {
+			return err
+		}
+	}
+	// 每批一个任务，失败重试时不会从头开始
+	if _, err := o.asynqRepo.SummaryMigrateTask(ctx, 0, userList[len(userList)-1].ID); err != nil {
+		return fmt.Errorf("推送下一批统计迁移失败: %w", err)
+	}
+	return nil
+}
+
+// migrateSummary 从最早一天的统计到今天按店铺时区逐天重新统计，再删除不在店铺时区0点的旧统计
+func (o *OrderService) migrateSummary(ctx context.Context, user *userEntity.User) error {
+	first, last, err := o.orderSummaryRepo.Span(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("查询每日统计失败: %w", err)
+	}
+	if first == 0 {
+		return nil
+	}
+	loc := user.Location()
+	to := time.Now()
+	if last > to.Unix() {
+		to = time.Unix(last, 0)
+	}
+	days := orders.DaysBetween(time.Unix(first, 0), time.Unix(orders.DayOf(to, loc).End, 0), loc)
+	keep := make([]int64, 0, len(days))
+	for _, day := range days {
+		if err := o.saveOrderStatistics(ctx, user.ID, day); err != nil {
+			return err
+		}
+		keep = append(keep, day.Start)
+	}
+	if err := o.orderSummaryRepo.DeleteExcept(ctx, user.ID, keep); err != nil {
+		return fmt.Errorf("删除旧的每日统计失败: %w", err)
+	}
+	logger.Info(ctx, "summary_migrate_queue", fmt.Sprintf("用户 %d 按时区 %s 重新统计 %d 天", user.ID, loc.String(), len(days)))
+	return nil
+}
+
+// saveOrderStatistics 统计用户在店铺时区一天内的订单数据
+func (o *OrderService) saveOrderStatistics(ctx context.Context, userID int64, day orders.SummaryDay) error {
+	// 获取订单数据 订单数据 日退款数据
+	statistics, err := o.orderRepo.GetOrderStatistics(ctx, day.Start, day.End, userID)
+	if err != nil {
+		return fmt.Errorf("查询统计失败: %w", err)
+	}
+
+	orderStatisticId, err := o.orderSummaryRepo.ExistOrder(ctx, userID, day.Start)
+	if err != nil {
+		return fmt.Errorf("查询每日记录失败: %w", err)
+	}
+	orderSummary := orders.OrderSummary{
+		Today:  day.Start,
+		Orders: statistics.TotalOrders,
+		Refund: statistics.TotalRefund,
+		Sales:  statistics.TotalProtectify,
+	}
+
+	if orderStatisticId > 0 {
+		orderSummary.Id = orderStatisticId
+		return o.orderSummaryRepo.UpsertOrderStatistics(ctx, orderSummary)
+	}
+	orderSummary.UserID = userID
+	return o.orderSummaryRepo.CrateOrderStatistics(ctx, orderSummary)
+}
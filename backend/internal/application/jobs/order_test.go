@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/orders"
+	"backend/internal/domain/entity/settings"
+	"backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/entity/users"
+	"backend/internal/domain/pricing"
+	billingsRepo "backend/internal/domain/repo/billings"
+	orderRepo "backend/internal/domain/repo/orders"
+	usersRepo "backend/internal/domain/repo/users"
+)
+
+// fakeOrderRepo 只实现订单同步用到的方法，其他方法调用时 panic
+type fakeOrderRepo struct {
+	orderRepo.OrderRepository
+	backfilled bool
+	saved      *orders.UserOrder
+}
+
+func (f *fakeOrderRepo) Create(_ context.Context, order *orders.UserOrder) (int64, error) {
+	order.Id = 1
+	f.saved = order
+	return order.Id, nil
+}
+
+func (f *fakeOrderRepo) GetByIDs(context.Context, int64, []int64) ([]*orders.UserOrder, error) {
+	return nil, nil
+}
+
+func (f *fakeOrderRepo) IsBackfilled(context.Context, int64) (bool, error) {
+	return f.backfilled, nil
+}
+
+func (f *fakeOrderRepo) UpdateShopifyOrderId(_ context.Context, order *orders.UserOrder) error {
+	f.saved = order
+	return nil
+}
+
+type fakeOrderInfoRepo struct {
+	orderRepo.OrderInfoRepository
+}
+
+func (f *fakeOrderInfoRepo) Create(context.Context, []*orders.UserOrderInfo) error {
+	return nil
+}
+
+func (f *fakeOrderInfoRepo) UpdateShopifyVariants(context.Context, int64, int64, *orders.UserOrderInfo) error {
+	return nil
+}
+
+func (f *fakeOrderInfoRepo) GetOrderDetailVariantIDs(context.Context, int64, int64) ([]int64, error) {
+	return []int64{100, 200}, nil
+}
+
+type fakeCommissionRepo struct {
+	billingsRepo.CommissionBillRepository
+	calls int
+	bills []*billings.CommissionBill
+}
+
+func (f *fakeCommissionRepo) RecognizedBills(context.Context, int64) ([]*billings.CommissionBill, error) {
+	return f.bills, nil
+}
+
+func (f *fakeCommissionRepo) GetByUserOrder(context.Context, int64, int64) (*billings.CommissionBill, error) {
+	f.calls++
+	return nil, nil
+}
+
+func (f *fakeCommissionRepo) CreateBill(context.Context, *billings.CommissionBill) (int64, error) {
+	f.calls++
+	return 1, nil
+}
+
+type fakeSubscriptionRepo struct {
+	usersRepo.UserSubscriptionRepository
+	subscriptions []*users.UserSubscription
+}
+
+func (f *fakeSubscriptionRepo) ListByUser(context.Context, int64) ([]*users.UserSubscription, error) {
+	return f.subscriptions, nil
+}
+
+func (f *fakeSubscriptionRepo) GetActiveSubscription(context.Context, int64) (*users.UserSubscription, error) {
+	return nil, nil
+}
+
+func TestBackfilledOrderSkipsBilling(t *testing.T) {
+	var data shopifys.OrderResponse
+	err := json.Unmarshal([]byte(`{"order":{
+		"id":"gid://shopify/Order/1001","name":"#1001","displayFinancialStatus":"PARTIALLY_REFUNDED",
+		"displayFulfillmentStatus":"FULFILLED",
+		"totalPriceSet":{"shopMoney":{"amount":"52.00","currencyCode":"USD"}},
+		"lineItems":{"edges":[
+			{"node":{"quantity":1,"variant":{"id":"gid://shopify/ProductVariant/100"},"originalUnitPriceSet":{"shopMoney":{"amount":"50.00"}}}},
+			{"node":{"quantity":1,"variant":{"id":"gid://shopify/ProductVariant/200"},"originalUnitPriceSet":{"shopMoney":{"amount":"2.00"}}}}
+		]},
+		"refunds":[{"refundLineItems":{"edges":[{"node":{"quantity":1,"lineItem":{"variant":{"id":"gid://shopify/ProductVariant/200"}}}}]}}]
+	}}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variantIDMap := map[int64]struct{}{200: {}}
+
+	// 回填导入的订单标记为回填订单
+	orderRepo, commissionRepo := &fakeOrderRepo{}, &fakeCommissionRepo{}
+	service := &OrderService{orderRepo: orderRepo, orderInfoRepo: &fakeOrderInfoRepo{}, commissionRepo: commissionRepo}
+	if err := service.createNewOrder(context.Background(), 1, &data, variantIDMap, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if orderRepo.saved.Backfilled != 1 {
+		t.Errorf("backfilled = %d, want 1", orderRepo.saved.Backfilled)
+	}
+
+	// 安装后的 webhook 更新回填订单时不生成抽成账单，也不冲减退款抽成
+	orderRepo.backfilled = true
+	tasks := &orderTasks{}
+	if err := service.updateExistingOrder(context.Background(), 1, 1, &data, variantIDMap, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if orderRepo.saved.ProtectifyAmount != 2 || orderRepo.saved.Backfilled != 1 {
+		t.Errorf("order = protectify %.2f backfilled %d, want protectify 2.00 backfilled 1",
+			orderRepo.saved.ProtectifyAmount, orderRepo.saved.Backfilled)
+	}
+	if commissionRepo.calls != 0 || len(tasks.settleBillIDs) != 0 || len(tasks.creditAdjustmentIDs) != 0 {
+		t.Errorf("backfilled order should not be billed, commission repo calls = %d", commissionRepo.calls)
+	}
+}
+
+func TestCommissionIgnoresShippingAndTax(t *testing.T) {
+	var data shopifys.OrderResponse
+	// 商品 96 + 保险 2，运费 15 和税 7 只计入订单总额
+	err := json.Unmarshal([]byte(`{"order":{
+		"id":"gid://shopify/Order/1002","name":"#1002",
+		"totalPriceSet":{"shopMoney":{"amount":"120.00","currencyCode":"USD"}},
+		"subtotalPriceSet":{"shopMoney":{"amount":"98.00","currencyCode":"USD"}}
+	}}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cartSetting := &settings.UserCartSetting{
+		PricingRule:   pricing.RuleRange,
+		PricingType:   pricing.TypePrice,
+		PricingSelect: `[{"min":"0","max":"100","price":"2.50"},{"min":"100","max":"0","price":"5.00"}]`,
+	}
+	order := &orders.UserOrder{Id: 1, ProtectifyAmount: 2, TotalPriceAmount: 120}
+
+	service := &OrderService{subscriptionRepo: &fakeSubscriptionRepo{}, commissionRepo: &fakeCommissionRepo{}}
+	bill, err := service.createCommissionBill(context.Background(), 1, order, &data, cartSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 报价金额为 96，命中 0-100 区间
+	if bill.CommissionAmount != 2.5 {
+		t.Errorf("commission = %.2f, want 2.50", bill.CommissionAmount)
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
 
 	"backend/internal/domain/entity/jobs"
 	productEntity "backend/internal/domain/entity/products"
 	cartEntity "backend/internal/domain/entity/settings"
 	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/pricing"
 	"backend/internal/domain/repo/carts"
 	jobRepo "backend/internal/domain/repo/jobs"
 	"backend/internal/domain/repo/products"
@@ -34,6 +36,7 @@ type ProductService struct {
 	jobProductRepo     jobRepo.ProductRepository
 	shopifyRepo        shopifyRepo.ShopifyRepository
 	productGraphqlRepo shopifyRepo.ProductGraphqlRepository
+	asynqRepo          jobRepo.AsynqRepository
 }
 
 func NewProductService(repos *providers.Repositories) *ProductService {
@@ -45,6 +48,7 @@ func NewProductService(repos *providers.Repositories) *ProductService {
 		jobProductRepo:     repos.JobProductRepo,
 		shopifyRepo:        repos.ShopifyRepo,
 		productGraphqlRepo: repos.ProductGraphqlRepo,
+		asynqRepo:          repos.AsyncRepo,
 	}
 }
 
@@ -139,7 +143,7 @@ func (p *ProductService) UploadProduct(ctx context.Context, t *asynq.Task) error
 	productId := payload.ShopifyProductId
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	p.productGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	productExist := true
 
 	if productId != 0 {
@@ -218,19 +222,7 @@ func (p *ProductService) UploadProduct(ctx context.Context, t *asynq.Task) error
 
 		for _, item := range variants {
 			variantDbId[item.SkuName] = item.Id
-			gqlVariant := &shopifyEntity.VariantCreateInput{
-				Price: strconv.FormatFloat(item.Price, 'f', 2, 64),
-				OptionValues: []shopifyEntity.VariantOptionValues{
-					{Name: item.Sku1, OptionName: "Title"},
-				},
-
-				InventoryItem: shopifyEntity.InventoryItemInput{
-					SKU:     item.SkuName,
-					Tracked: false,
-				},
-				RequiresShipping: false,
-			}
-			variantCreateInput = append(variantCreateInput, gqlVariant)
+			variantCreateInput = append(variantCreateInput, newVariantCreateInput(item))
 		}
 
 		gqlVariants, err := p.productGraphqlRepo.CreateVariants(ctx, productId, variantCreateInput) // 通过 client 调用方法
@@ -338,7 +330,7 @@ func (p *ProductService) HandleShopifyProduct(ctx context.Context, t *asynq.Task
 	}
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	p.productGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	product, err := p.productRepo.FirstProductByID(ctx, payload.UserProductId, uid)
 	if err != nil || product == nil {
 		logger.Error(ctx, "shopify_product_queue:查询产品信息失败", err)
@@ -378,7 +370,7 @@ func (p *ProductService) HandleShopifyProduct(ctx context.Context, t *asynq.Task
 
 		gqlVariant := &shopifyEntity.VariantUpdateInput{
 			// 此处使用 Shopify 的全局唯一标识符，例如 "gid://shopify/ProductVariant/<id>"
-			Id:    fmt.Sprintf("gid://shopify/Product/%d", item.VariantId),
+			Id:    variantGid(item.VariantId),
 			Price: strconv.FormatFloat(item.Price, 'f', 2, 64),
 			OptionValues: []shopifyEntity.VariantOptionValues{
 				{Name: item.Sku1, OptionName: "Title"},
@@ -406,6 +398,209 @@ func (p *ProductService) HandleShopifyProduct(ctx context.Context, t *asynq.Task
 	return nil
 }
 
+// HandleVariantSync 按变体阶梯同步保险商品的变体：删除阶梯外的变体，创建缺少的变体，修正价格不一致的变体。
+// 差异按已保存的变体计算，每一步完成后立即更新变体记录，重试时只处理剩下的变体
+func (p *ProductService) HandleVariantSync(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.VariantSyncPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "variant_sync_queue:payload 反序列化失败", err)
+		return nil
+	}
+	uid := payload.UserID
+
+	user, err := p.userRepo.Get(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return nil
+	}
+	product, err := p.productRepo.First(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("查询产品信息失败: %w", err)
+	}
+	// 保险商品还没有上传到 Shopify 时不需要同步，上传时会按最新的阶梯创建变体
+	if product == nil || product.ProductId == 0 {
+		logger.Info(ctx, "variant_sync_queue", fmt.Sprintf("用户 %d 的保险商品尚未上传，跳过同步", uid))
+		return nil
+	}
+
+	cartSetting, err := p.cartSettingRepo.First(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("查询购物车设置失败: %w", err)
+	}
+	ladder, err := pricing.ProductLadder(cartSetting, product.UsesLegacyLadder())
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("variant_sync_queue:用户 %d 变体阶梯无效: %s", uid, err.Error()))
+		return nil
+	}
+	prices, err := ladder.Prices()
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("variant_sync_queue:用户 %d 变体阶梯无效: %s", uid, err.Error()))
+		return nil
+	}
+	variants, err := p.variantRepo.FindID(ctx, product.Id)
+	if err != nil {
+		return fmt.Errorf("查询变体失败: %w", err)
+	}
+
+	// 按 SKU（价格）对比，没有 Shopify 变体的记录也重新创建
+	wanted := make(map[string]decimal.Decimal, len(prices))
+	for _, price := range prices {
+		wanted[price.StringFixed(2)] = price
+	}
+	var kept, stale, missing []*productEntity.UserVariant
+	for _, variant := range variants {
+		if _, ok := wanted[variant.SkuName]; ok && variant.VariantId != 0 {
+			kept = append(kept, variant)
+			delete(wanted, variant.SkuName)
+			continue
+		}
+		stale = append(stale, variant)
+	}
+	for _, price := range prices {
+		if _, ok := wanted[price.StringFixed(2)]; ok {
+			missing = append(missing, productEntity.NewLadderVariant(uid, product.Id, price))
+		}
+	}
+	logger.Info(ctx, "variant_sync_queue", fmt.Sprintf("用户 %d 同步变体: 保留 %d 删除 %d 创建 %d", uid, len(kept), len(stale), len(missing)))
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+
+	// Shopify 商品最多 100 个变体且至少保留一个，放不下时先删除旧变体腾出位置
+	current := len(kept) + countOnShopify(stale)
+	for len(missing) > 0 {
+		room := pricing.MaxVariants - current
+		if room <= 0 {
+			n := min(len(stale), len(missing), current-1)
+			if n <= 0 {
+				return fmt.Errorf("保险商品变体已满，无法创建新变体")
+			}
+			if err := p.deleteVariants(ctx, uid, product.ProductId, stale[:n]); err != nil {
+				return err
+			}
+			current -= countOnShopify(stale[:n])
+			stale = stale[n:]
+			continue
+		}
+		batch := missing[:min(room, len(missing))]
+		if err := p.createVariants(ctx, product.ProductId, batch); err != nil {
+			return err
+		}
+		current += len(batch)
+		missing = missing[len(batch):]
+	}
+	if err := p.deleteVariants(ctx, uid, product.ProductId, stale); err != nil {
+		return err
+	}
+
+	// 保留的变体价格和 SKU 不一致时按 SKU 修正
+	var updates []*shopifyEntity.VariantUpdateInput
+	var updated []*productEntity.UserVariant
+	for _, variant := range kept {
+		price, err := decimal.NewFromString(variant.SkuName)
+		if err != nil || decimal.NewFromFloat(variant.Price).Equal(price) {
+			continue
+		}
+		variant.Price = price.InexactFloat64()
+		updates = append(updates, &shopifyEntity.VariantUpdateInput{
+			Id:    variantGid(variant.VariantId),
+			Price: price.StringFixed(2),
+			InventoryItem: shopifyEntity.InventoryItemInput{
+				SKU:     variant.SkuName,
+				Tracked: false,
+			},
+		})
+		updated = append(updated, variant)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := p.productGraphqlRepo.UpdateVariants(ctx, product.ProductId, updates); err != nil {
+		return fmt.Errorf("修改Shopify变体价格失败: %w", err)
+	}
+	for _, variant := range updated {
+		if err := p.variantRepo.UpdateVariants(ctx, variant.Id, uid, &productEntity.UserVariant{Price: variant.Price}); err != nil {
+			return fmt.Errorf("保存变体价格失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// createVariants 在 Shopify 上创建变体后保存变体记录
+func (p *ProductService) createVariants(ctx context.Context, productId int64, variants []*productEntity.UserVariant) error {
+	input := make([]*shopifyEntity.VariantCreateInput, 0, len(variants))
+	bySku := make(map[string]*productEntity.UserVariant, len(variants))
+	for _, variant := range variants {
+		input = append(input, newVariantCreateInput(variant))
+		bySku[variant.SkuName] = variant
+	}
+	gqlVariants, err := p.productGraphqlRepo.CreateVariants(ctx, productId, input)
+	if err != nil {
+		return fmt.Errorf("创建Shopify变体失败: %w", err)
+	}
+	created := make([]*productEntity.UserVariant, 0, len(gqlVariants))
+	for _, item := range gqlVariants {
+		sku, _ := item["sku"].(string)
+		variant, ok := bySku[sku]
+		if !ok {
+			continue
+		}
+		variant.ProductId = productId
+		variant.VariantId = utils.GetIdFromShopifyGraphqlId(item["id"].(string))
+		variant.InventoryId = utils.GetIdFromShopifyGraphqlId(item["inventory_id"].(string))
+		created = append(created, variant)
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	if err := p.variantRepo.CreateVariants(ctx, created); err != nil {
+		return fmt.Errorf("保存变体失败: %w", err)
+	}
+	return nil
+}
+
+// deleteVariants 删除 Shopify 变体和变体记录，没有 Shopify 变体的记录直接删除
+func (p *ProductService) deleteVariants(ctx context.Context, uid int64, productId int64, variants []*productEntity.UserVariant) error {
+	for _, variant := range variants {
+		if variant.VariantId != 0 {
+			if err := p.productGraphqlRepo.DeleteVariant(ctx, productId, variant.VariantId); err != nil {
+				return fmt.Errorf("删除Shopify变体失败: %w", err)
+			}
+		}
+		if err := p.variantRepo.DeleteVariants(ctx, uid, []int64{variant.Id}); err != nil {
+			return fmt.Errorf("删除变体失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func countOnShopify(variants []*productEntity.UserVariant) int {
+	count := 0
+	for _, variant := range variants {
+		if variant.VariantId != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func newVariantCreateInput(variant *productEntity.UserVariant) *shopifyEntity.VariantCreateInput {
+	return &shopifyEntity.VariantCreateInput{
+		Price: strconv.FormatFloat(variant.Price, 'f', 2, 64),
+		OptionValues: []shopifyEntity.VariantOptionValues{
+			{Name: variant.Sku1, OptionName: "Title"},
+		},
+		InventoryItem: shopifyEntity.InventoryItemInput{
+			SKU:     variant.SkuName,
+			Tracked: false,
+		},
+		RequiresShipping: false,
+	}
+}
+
 // 这里要抽出来 失败和成功的逻辑 共用 解耦
 func (p *ProductService) ok(ctx context.Context, jobID int64) error {
 	_ = p.jobProductRepo.UpdateStatus(ctx, jobID, 1) // 3 表示失败
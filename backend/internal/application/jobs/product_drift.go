@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/jobs"
+	productEntity "backend/internal/domain/entity/products"
+	"backend/internal/domain/pricing"
+	"backend/internal/infras/shopify_graphql"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// HandleProductDrift 检查保险商品的变体：商家在 Shopify 后台删除或改价的变体先修正变体记录，
+// 和变体阶梯不一致时推送变体同步，由 HandleVariantSync 改回阶梯价格
+func (p *ProductService) HandleProductDrift(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.ProductDriftPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "product_drift_queue:payload 反序列化失败", err)
+		return nil
+	}
+
+	if payload.UserID > 0 {
+		product, err := p.productRepo.First(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("查询产品信息失败: %w", err)
+		}
+		if product == nil || product.ProductId == 0 {
+			return nil
+		}
+		return p.checkDrift(ctx, product)
+	}
+
+	var lastID int64
+	var checked, failed int
+	batchSize := 200
+	for {
+		list, err := p.productRepo.Uploaded(ctx, lastID, batchSize)
+		if err != nil {
+			logger.Error(ctx, "product_drift_queue:查询产品失败", err)
+			return err
+		}
+		for _, product := range list {
+			lastID = product.Id
+			if err := p.checkDrift(ctx, product); err != nil {
+				failed++
+				logger.Error(ctx, fmt.Sprintf("product_drift_queue:用户 %d 检查变体失败", product.UserID), err)
+				continue
+			}
+			checked++
+		}
+		if len(list) < batchSize {
+			break
+		}
+	}
+	logger.Info(ctx, "product_drift_queue", fmt.Sprintf("变体检查完成, 成功: %d 失败: %d", checked, failed))
+	return nil
+}
+
+// checkDrift 对比变体记录、Shopify 变体和变体阶梯，有差异时推送变体同步
+func (p *ProductService) checkDrift(ctx context.Context, product *productEntity.UserProduct) error {
+	uid := product.UserID
+	user, err := p.userRepo.Get(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return nil
+	}
+	cartSetting, err := p.cartSettingRepo.First(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("查询购物车设置失败: %w", err)
+	}
+	ladder, err := pricing.ProductLadder(cartSetting, product.UsesLegacyLadder())
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("product_drift_queue:用户 %d 变体阶梯无效: %s", uid, err.Error()))
+		return nil
+	}
+	prices, err := ladder.Prices()
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("product_drift_queue:用户 %d 变体阶梯无效: %s", uid, err.Error()))
+		return nil
+	}
+	variants, err := p.variantRepo.FindID(ctx, product.Id)
+	if err != nil {
+		return fmt.Errorf("查询变体失败: %w", err)
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+	resp, err := p.productGraphqlRepo.GetProduct(ctx, product.ProductId)
+	if err != nil {
+		return fmt.Errorf("查询Shopify产品失败: %w", err)
+	}
+	// 整个商品被删除时变体同步无法处理，需要商家重新上传
+	if resp.Product == nil {
+		logger.Warn(ctx, fmt.Sprintf("product_drift_queue:用户 %d 的保险商品 %d 在 Shopify 上不存在", uid, product.ProductId))
+		return nil
+	}
+	onShopify := make(map[int64]string, len(resp.Product.Variants.Nodes))
+	for _, node := range resp.Product.Variants.Nodes {
+		onShopify[utils.GetIdFromShopifyGraphqlId(node.ID)] = node.Price
+	}
+
+	wanted := make(map[string]bool, len(prices))
+	for _, price := range prices {
+		wanted[price.StringFixed(2)] = true
+	}
+	var removed, repriced int
+	drift := len(variants) != len(prices)
+	for _, variant := range variants {
+		if !wanted[variant.SkuName] || variant.VariantId == 0 {
+			drift = true
+		}
+		if variant.VariantId == 0 {
+			continue
+		}
+		shopifyPrice, ok := onShopify[variant.VariantId]
+		delete(onShopify, variant.VariantId)
+		// Shopify 上已经删除的变体删除记录，变体同步时重新创建
+		if !ok {
+			if err := p.variantRepo.DeleteVariants(ctx, uid, []int64{variant.Id}); err != nil {
+				return fmt.Errorf("删除变体失败: %w", err)
+			}
+			removed++
+			drift = true
+			continue
+		}
+		// 记录 Shopify 上的实际价格，变体同步时按 SKU 改回阶梯价格
+		price, err := decimal.NewFromString(shopifyPrice)
+		if err != nil || !price.IsPositive() || decimal.NewFromFloat(variant.Price).Equal(price) {
+			continue
+		}
+		if err := p.variantRepo.UpdateVariants(ctx, variant.Id, uid, &productEntity.UserVariant{Price: price.InexactFloat64()}); err != nil {
+			return fmt.Errorf("修改变体价格失败: %w", err)
+		}
+		repriced++
+		drift = true
+	}
+	// 商家手动添加的变体不在变体记录里，变体同步不会处理，只记录日志
+	if len(onShopify) > 0 {
+		logger.Warn(ctx, fmt.Sprintf("product_drift_queue:用户 %d 的保险商品有 %d 个未记录的变体", uid, len(onShopify)))
+	}
+	if !drift {
+		return nil
+	}
+
+	logger.Info(ctx, "product_drift_queue", fmt.Sprintf("用户 %d 的变体和阶梯不一致: Shopify 已删除 %d 改价 %d，推送变体同步", uid, removed, repriced))
+	if _, err := p.asynqRepo.VariantSyncTask(ctx, uid); err != nil {
+		return fmt.Errorf("推送变体同步失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/jobs"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	userEntity "backend/internal/domain/entity/users"
+	billingsRepo "backend/internal/domain/repo/billings"
+	shopifyRepo "backend/internal/domain/repo/shopifys"
+	"backend/internal/domain/repo/users"
+	"backend/internal/infras/shopify_graphql"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// ReconciliationService 把已扣费的抽成账单和 Shopify 用量记录逐条核对，差异写入对账表
+type ReconciliationService struct {
+	userRepo               users.UserRepository
+	subscriptionRepo       users.UserSubscriptionRepository
+	commissionBillRepo     billingsRepo.CommissionBillRepository
+	reconciliationRepo     billingsRepo.ReconciliationRepository
+	usageChargeGraphqlRepo shopifyRepo.UsageChargeGraphqlRepository
+}
+
+func NewReconciliationService(repos *providers.Repositories) *ReconciliationService {
+	return &ReconciliationService{
+		userRepo:               repos.UserRepo,
+		subscriptionRepo:       repos.UserSubscriptionRepo,
+		commissionBillRepo:     repos.CommissionBillRepo,
+		reconciliationRepo:     repos.ReconciliationRepo,
+		usageChargeGraphqlRepo: repos.UsageChargeGraphqlRepo,
+	}
+}
+
+// HandleBillingReconcile 对指定用户或所有活跃订阅对账，单个用户失败不影响其他用户
+func (r *ReconciliationService) HandleBillingReconcile(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.BillingReconcilePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "billing_reconcile_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	if payload.UserID > 0 {
+		subscription, err := r.subscriptionRepo.GetActiveSubscription(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("查询用户订阅失败: %w", err)
+		}
+		if subscription == nil || subscription.SubscriptionLineItemID == "" {
+			logger.Warn(ctx, fmt.Sprintf("billing_reconcile_queue: 用户 %d 没有有效的用量订阅", payload.UserID))
+			return nil
+		}
+		issues, err := r.reconcile(ctx, subscription)
+		if err != nil {
+			return err
+		}
+		logger.Info(ctx, "billing_reconcile_queue", fmt.Sprintf("用户 %d 对账完成, 差异: %d", payload.UserID, issues))
+		return nil
+	}
+
+	var lastID int64
+	var userCount, failed, issues int
+	batchSize := 200
+	for {
+		subscriptions, err := r.subscriptionRepo.ActiveUsageSubscriptions(ctx, lastID, batchSize)
+		if err != nil {
+			logger.Error(ctx, "billing_reconcile_queue: 查询活跃订阅失败", err)
+			return err
+		}
+		for _, subscription := range subscriptions {
+			lastID = subscription.ID
+			userCount++
+			count, err := r.reconcile(ctx, subscription)
+			if err != nil {
+				failed++
+				logger.Error(ctx, fmt.Sprintf("billing_reconcile_queue: 用户 %d 对账失败", subscription.UserID), err)
+				continue
+			}
+			issues += count
+		}
+		if len(subscriptions) < batchSize {
+			break
+		}
+	}
+
+	logger.Info(ctx, "billing_reconcile_queue", fmt.Sprintf("执行完毕, 用户: %d, 失败: %d, 差异: %d", userCount, failed, issues))
+	if issues > 0 {
+		utils.CallWilding(fmt.Sprintf("账单对账发现 %d 条差异，涉及 %d 个用户，请在后台查看对账报告", issues, userCount))
+	}
+	return nil
+}
+
+// reconcile 核对一个订阅的用量记录，返回差异数量
+func (r *ReconciliationService) reconcile(ctx context.Context, subscription *userEntity.UserSubscription) (int, error) {
+	user, err := r.userRepo.Get(ctx, subscription.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil || user.IsDel != 0 {
+		return 0, nil
+	}
+
+	shopName, _ := utils.GetShopName(user.Shop)
+	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+	records, err := r.usageChargeGraphqlRepo.ListUsageRecords(ctx, subscription.SubscriptionLineItemID)
+	if err != nil {
+		return 0, fmt.Errorf("拉取用量记录失败: %w", err)
+	}
+
+	// 只核对当前订阅创建之后扣费的账单，之前的账单属于已取消的订阅
+	bills, err := r.commissionBillRepo.ChargedBillsSince(ctx, subscription.UserID, subscription.CreateTime)
+	if err != nil {
+		return 0, fmt.Errorf("查询已扣费账单失败: %w", err)
+	}
+
+	issues := compareUsageRecords(subscription, bills, records)
+	reconciledAt := time.Now().Unix()
+	for _, issue := range issues {
+		issue.ReconciledAt = reconciledAt
+	}
+	if err := r.reconciliationRepo.ReplaceUserIssues(ctx, subscription.UserID, issues); err != nil {
+		return 0, fmt.Errorf("保存对账结果失败: %w", err)
+	}
+	return len(issues), nil
+}
+
+// compareUsageRecords 按用量记录ID匹配账单和 Shopify 用量记录
+func compareUsageRecords(subscription *userEntity.UserSubscription, bills []*billings.CommissionBill, records []shopifyEntity.AppUsageRecord) []*billings.BillingReconciliation {
+	issues := make([]*billings.BillingReconciliation, 0)
+	remote := make(map[string]shopifyEntity.AppUsageRecord, len(records))
+	for _, record := range records {
+		remote[record.ID] = record
+	}
+
+	local := make(map[string]struct{}, len(bills))
+	for _, bill := range bills {
+		local[bill.ShopifyUsageRecordId] = struct{}{}
+		amount, currency := chargedAmount(bill, subscription)
+		issue := &billings.BillingReconciliation{
+			UserId:               subscription.UserID,
+			SubscriptionId:       subscription.ID,
+			BillId:               bill.Id,
+			ShopifyUsageRecordId: bill.ShopifyUsageRecordId,
+			LocalAmount:          utils.DecimalToFloat(amount),
+			LocalCurrency:        currency,
+		}
+
+		record, ok := remote[bill.ShopifyUsageRecordId]
+		if !ok {
+			issue.IssueType = billings.ReconcileIssueMissingRemote
+			issue.Detail = fmt.Sprintf("账单 %d 已扣费，Shopify 没有对应的用量记录", bill.Id)
+			issues = append(issues, issue)
+			continue
+		}
+		remoteAmount, err := decimal.NewFromString(record.Price.Amount)
+		issue.RemoteAmount = utils.DecimalToFloat(remoteAmount)
+		issue.RemoteCurrency = record.Price.CurrencyCode
+		if err != nil || !remoteAmount.Equal(amount) || record.Price.CurrencyCode != currency {
+			issue.IssueType = billings.ReconcileIssueAmountMismatch
+			issue.Detail = fmt.Sprintf("账单 %d 金额 %s %s，Shopify 记录金额 %s %s", bill.Id, amount, currency, record.Price.Amount, record.Price.CurrencyCode)
+			issues = append(issues, issue)
+		}
+	}
+
+	for _, record := range records {
+		if _, ok := local[record.ID]; ok {
+			continue
+		}
+		remoteAmount, _ := decimal.NewFromString(record.Price.Amount)
+		issue := &billings.BillingReconciliation{
+			UserId:               subscription.UserID,
+			SubscriptionId:       subscription.ID,
+			ShopifyUsageRecordId: record.ID,
+			IssueType:            billings.ReconcileIssueMissingLocal,
+			RemoteAmount:         utils.DecimalToFloat(remoteAmount),
+			RemoteCurrency:       record.Price.CurrencyCode,
+			Detail:               fmt.Sprintf("Shopify 用量记录没有对应的已扣费账单: %s", record.Description),
+		}
+		// 扣费成功但没有保存用量记录ID时，可以通过幂等键找回账单
+		if billID, ok := billings.BillIDFromIdempotencyKey(record.IdempotencyKey); ok {
+			issue.BillId = billID
+			issue.Detail = fmt.Sprintf("Shopify 已按账单 %d 扣费，本地账单没有记录扣费结果", billID)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// chargedAmount 账单提交到 Shopify 的金额和币种，换算之前的账单按订阅币种直接扣费
+func chargedAmount(bill *billings.CommissionBill, subscription *userEntity.UserSubscription) (decimal.Decimal, string) {
+	if bill.ExchangeRate > 0 {
+		return decimal.NewFromFloat(bill.ConvertedAmount), bill.ChargeCurrency
+	}
+	return decimal.NewFromFloat(bill.ChargeAmount()).Round(2), subscription.ChargeCurrency()
+}
@@ -8,6 +8,10 @@ import (
 	"github.com/hibiken/asynq"
 
 	"backend/internal/domain/entity/jobs"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	userEntity "backend/internal/domain/entity/users"
+	appRepo "backend/internal/domain/repo/apps"
+	jobRepo "backend/internal/domain/repo/jobs"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/domain/repo/users"
 	"backend/internal/infras/shopify_graphql"
@@ -17,16 +21,20 @@ import (
 )
 
 type UserService struct {
-	userRepo        users.UserRepository
-	shopifyRepo     shopifyRepo.ShopifyRepository
-	shopGraphqlRepo shopifyRepo.ShopGraphqlRepository
+	userRepo         users.UserRepository
+	shopifyRepo      shopifyRepo.ShopifyRepository
+	shopGraphqlRepo  shopifyRepo.ShopGraphqlRepository
+	webhookDriftRepo jobRepo.WebhookDriftRepository
+	appAuthRepo      appRepo.AppAuthRepository
 }
 
 func NewUserService(repos *providers.Repositories) *UserService {
 	return &UserService{
-		userRepo:        repos.UserRepo,
-		shopifyRepo:     repos.ShopifyRepo,
-		shopGraphqlRepo: repos.ShopGraphqlRepo,
+		userRepo:         repos.UserRepo,
+		shopifyRepo:      repos.ShopifyRepo,
+		shopGraphqlRepo:  repos.ShopGraphqlRepo,
+		webhookDriftRepo: repos.WebhookDriftRepo,
+		appAuthRepo:      repos.AppAuthRepo,
 	}
 }
 
@@ -48,34 +56,10 @@ func (u *UserService) HandleInitUser(ctx context.Context, t *asynq.Task) error {
 	// 初始化 Shopify client
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	u.shopGraphqlRepo.WithClient(client)
-	webhooks, _ := u.shopGraphqlRepo.QueryWebhookSubscriptions(ctx, "")
-
-	topics := shopifyRepo.ShopifyWebhookTopics
-	webhookUrl := u.shopifyRepo.GetWebhookUrl(user.AppId)
-	for _, topic := range topics {
-		hasWebhook := false
-		if webhooks != nil {
-			for _, webhook := range webhooks {
-				if webhook.Topic == topic {
-					logger.Info(ctx, fmt.Sprintf("Update %s webhook for shop %s", topic, user.Shop))
-					_ = u.shopGraphqlRepo.UpdateWebhookSubscription(ctx, webhook.Id, webhookUrl)
-					hasWebhook = true
-					logger.Warn(ctx, fmt.Sprintf("Update %s webhook for shop %s", topic, user.Shop),
-						map[string]interface{}{
-							"old": webhook.Endpoint,
-							"new": webhookUrl,
-						})
-					break
-				}
-			}
-		}
-		if !hasWebhook {
-			logger.Info(ctx, fmt.Sprintf("Register %s webhook for shop %s", topic, user.Shop))
-			_ = u.shopGraphqlRepo.CreateWebhookSubscription(ctx, topic, webhookUrl)
-			logger.Warn(ctx, fmt.Sprintf("Register %s webhook for shop %s", topic, user.Shop),
-				map[string]interface{}{"webhook": webhookUrl})
-		}
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+	// 订阅失败不影响初始化，定时核对时会再补上
+	if err := u.reconcileWebhooks(ctx, user); err != nil {
+		logger.Error(ctx, fmt.Sprintf("init_user_queue:%d 核对webhook订阅失败: %s", uid, err.Error()))
 	}
 	publishId, err := u.shopGraphqlRepo.GetPublicationID(ctx)
 	if err != nil {
@@ -88,6 +72,140 @@ func (u *UserService) HandleInitUser(ctx context.Context, t *asynq.Task) error {
 	return nil
 }
 
+// HandleWebhookReconcile 核对店铺的 webhook 订阅，缺少的创建，回调地址不对的更新，多余的删除
+func (u *UserService) HandleWebhookReconcile(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.WebhookReconcilePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "webhook_reconcile_queue:payload 反序列化失败", err)
+		return nil
+	}
+
+	if payload.UserID > 0 {
+		user, err := u.userRepo.Get(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("查询用户信息失败: %w", err)
+		}
+		if user == nil || user.IsDel != 0 {
+			return nil
+		}
+		return u.reconcileWebhooks(ctx, user)
+	}
+
+	var lastID int64
+	var checked, failed int
+	batchSize := 200
+	for {
+		list, err := u.userRepo.GetUsers(ctx, lastID, batchSize)
+		if err != nil {
+			logger.Error(ctx, "webhook_reconcile_queue:查询用户失败", err)
+			return err
+		}
+		for _, item := range list {
+			lastID = item.ID
+			user, err := u.userRepo.Get(ctx, item.ID)
+			if err == nil && user != nil {
+				err = u.reconcileWebhooks(ctx, user)
+			}
+			if err != nil {
+				failed++
+				logger.Error(ctx, fmt.Sprintf("webhook_reconcile_queue:用户 %d 核对webhook订阅失败", item.ID), err)
+				continue
+			}
+			checked++
+		}
+		if len(list) < batchSize {
+			break
+		}
+	}
+	logger.Info(ctx, "webhook_reconcile_queue", fmt.Sprintf("webhook订阅核对完成, 成功: %d 失败: %d", checked, failed))
+	return nil
+}
+
+// reconcileWebhooks 对比需要的 topic、回调地址和 Shopify 上的订阅并修正，差异记录到 webhook_drift
+func (u *UserService) reconcileWebhooks(ctx context.Context, user *userEntity.User) error {
+	shopName, err := utils.GetShopName(user.Shop)
+	if err != nil {
+		return err
+	}
+	ctx = shopify_graphql.ContextWithClient(ctx, shopify_graphql.NewGraphqlClient(shopName, user.AccessToken))
+	subscriptions, err := u.shopGraphqlRepo.QueryWebhookSubscriptions(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	webhookUrl := u.shopifyRepo.GetWebhookUrl(user.AppId)
+	changes := shopifyEntity.DiffWebhookSubscriptions(shopifyRepo.ShopifyWebhookTopics, webhookUrl, subscriptions)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	drifts := make([]*jobs.WebhookDrift, 0, len(changes))
+	var failed int
+	for _, change := range changes {
+		drift := &jobs.WebhookDrift{UserID: user.ID, Topic: change.Topic, Action: change.Action}
+		if change.Subscription != nil {
+			drift.SubscriptionId = change.Subscription.Id
+			drift.CallbackUrl = change.Subscription.Endpoint.CallbackUrl
+		}
+		var err error
+		switch change.Action {
+		case shopifyEntity.WebhookActionCreate:
+			err = u.shopGraphqlRepo.CreateWebhookSubscription(ctx, change.Topic, webhookUrl)
+		case shopifyEntity.WebhookActionUpdate:
+			err = u.shopGraphqlRepo.UpdateWebhookSubscription(ctx, change.Subscription.Id, webhookUrl)
+		case shopifyEntity.WebhookActionDelete:
+			err = u.shopGraphqlRepo.DeleteWebhookSubscription(ctx, change.Subscription.Id)
+		}
+		if err != nil {
+			failed++
+			drift.ErrorMessage = err.Error()
+		}
+		drifts = append(drifts, drift)
+	}
+	logger.Warn(ctx, fmt.Sprintf("webhook_reconcile_queue:用户 %d 的webhook订阅有 %d 处差异, 修正失败 %d", user.ID, len(changes), failed))
+
+	if err := u.webhookDriftRepo.Create(ctx, drifts); err != nil {
+		return fmt.Errorf("保存webhook订阅差异失败: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个webhook订阅修正失败", failed)
+	}
+	return nil
+}
+
+// HandleTokenReencrypt 用最新密钥重新加密 users.access_token 和 user_app_auth 的 token，历史明文也一起加密
+func (u *UserService) HandleTokenReencrypt(ctx context.Context, t *asynq.Task) error {
+	userCount, err := rotateTokens(ctx, u.userRepo.RotateTokens)
+	if err != nil {
+		logger.Error(ctx, "token_reencrypt_queue:重新加密用户token失败", err)
+		return err
+	}
+	appAuthCount, err := rotateTokens(ctx, u.appAuthRepo.RotateTokens)
+	if err != nil {
+		logger.Error(ctx, "token_reencrypt_queue:重新加密应用授权token失败", err)
+		return err
+	}
+	logger.Info(ctx, "token_reencrypt_queue", fmt.Sprintf("token重新加密完成, 用户: %d 应用授权: %d", userCount, appAuthCount))
+	return nil
+}
+
+// rotateTokens 按 id 分批重新加密，返回重新加密的总数
+func rotateTokens(ctx context.Context, rotate func(ctx context.Context, cursorID int64, limit int) (int64, int, error)) (int, error) {
+	var lastID int64
+	var total int
+	for {
+		nextID, rotated, err := rotate(ctx, lastID, 200)
+		if err != nil {
+			return total, err
+		}
+		total += rotated
+		if nextID == 0 {
+			return total, nil
+		}
+		lastID = nextID
+	}
+}
+
 func (u *UserService) fail(ctx context.Context, uid int64, msg string, err error) error {
 	logger.Error(ctx, fmt.Sprintf("init_user_queue:%d %s: %v", uid, msg, err))
 	return nil
@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hibiken/asynq"
+
+	appEntity "backend/internal/domain/entity/apps"
+	"backend/internal/domain/entity/jobs"
+	appRepo "backend/internal/domain/repo/apps"
+	jobRepo "backend/internal/domain/repo/jobs"
+	"backend/internal/providers"
+	"backend/pkg/ctxkeys"
+	"backend/pkg/logger"
+)
+
+// webhookErrorLength error_message 字段长度
+const webhookErrorLength = 500
+
+// WebhookService webhook 收件箱，先保存再由 asynq 处理，处理失败可以重试和手动重放
+type WebhookService struct {
+	inboxRepo jobRepo.WebhookInboxRepository
+	driftRepo jobRepo.WebhookDriftRepository
+	appRepo   appRepo.AppRepository
+	asynqRepo jobRepo.AsynqRepository
+}
+
+func NewWebhookService(repos *providers.Repositories) *WebhookService {
+	return &WebhookService{
+		inboxRepo: repos.WebhookInboxRepo,
+		driftRepo: repos.WebhookDriftRepo,
+		appRepo:   repos.AppRepo,
+		asynqRepo: repos.AsyncRepo,
+	}
+}
+
+// Receive 保存验签通过的 webhook 并推送处理任务，返回错误时 Shopify 会重新投递
+func (w *WebhookService) Receive(ctx context.Context, inbox *jobs.WebhookInbox) error {
+	inbox.FillCustomer()
+	created, err := w.inboxRepo.Create(ctx, inbox)
+	if err != nil {
+		return fmt.Errorf("保存 webhook 失败: %w", err)
+	}
+	if !created {
+		// 重复投递：上次保存后推送失败时记录仍未处理，这里补推，队列里已有任务时忽略
+		exists, err := w.inboxRepo.FirstByWebhookId(ctx, inbox.WebhookId)
+		if err != nil {
+			return fmt.Errorf("查询 webhook 失败: %w", err)
+		}
+		if exists == nil || exists.Status == jobs.WebhookStatusProcessed {
+			logger.Info(ctx, "webhook 重复投递，已处理：", inbox.WebhookId, inbox.Topic)
+			return nil
+		}
+		inbox = exists
+	}
+
+	if _, err = w.asynqRepo.WebhookTask(ctx, inbox, true); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return nil
+}
+
+// Process 处理收件箱中的一条 webhook，handle 返回错误时记录原因并交给 asynq 重试
+func (w *WebhookService) Process(ctx context.Context, t *asynq.Task, handle func(ctx context.Context, inbox *jobs.WebhookInbox) error) error {
+	var payload jobs.WebhookPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "webhook_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	inbox, err := w.inboxRepo.First(ctx, payload.InboxId)
+	if err != nil {
+		return fmt.Errorf("查询 webhook 失败: %w", err)
+	}
+	if inbox == nil || inbox.Status == jobs.WebhookStatusProcessed {
+		return nil
+	}
+	// 顾客数据清除后 webhook 内容已经清空，不再处理
+	if inbox.Payload == "" {
+		logger.Warn(ctx, "webhook_queue: webhook 内容已清除，跳过处理", inbox.Id, inbox.Topic)
+		inbox.Status = jobs.WebhookStatusProcessed
+		inbox.ProcessedTime = time.Now().Unix()
+		if err := w.inboxRepo.Update(ctx, inbox, "status", "processed_time"); err != nil {
+			return fmt.Errorf("更新 webhook 状态失败: %w", err)
+		}
+		return nil
+	}
+
+	// 队列里没有请求上下文，按 webhook 所属应用补上 AppData
+	app, err := w.appRepo.GetByAppId(ctx, inbox.AppId)
+	if err != nil {
+		return fmt.Errorf("查询应用失败: %w", err)
+	}
+	if app == nil {
+		return w.fail(ctx, inbox, fmt.Errorf("应用不存在: %s: %w", inbox.AppId, asynq.SkipRetry))
+	}
+	ctx = context.WithValue(ctx, ctxkeys.AppData, &appEntity.AppData{
+		AppID:      app.AppId,
+		AppKey:     app.ApiKey,
+		AppSecret:  app.ApiSecret,
+		AppSecrets: app.ActiveSecrets(),
+	})
+
+	inbox.Attempts++
+	if err := handle(ctx, inbox); err != nil {
+		return w.fail(ctx, inbox, err)
+	}
+
+	inbox.Status = jobs.WebhookStatusProcessed
+	inbox.ErrorMessage = ""
+	inbox.ProcessedTime = time.Now().Unix()
+	if err := w.inboxRepo.Update(ctx, inbox, "status", "attempts", "error_message", "processed_time"); err != nil {
+		logger.Error(ctx, "webhook_queue: 更新处理状态失败", inbox.Id, err.Error())
+	}
+	return nil
+}
+
+// Replay 重新处理一条 webhook，不检查队列中是否已有任务
+func (w *WebhookService) Replay(ctx context.Context, id int64) error {
+	inbox, err := w.inboxRepo.First(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inbox == nil {
+		return jobs.ErrWebhookNotFound
+	}
+
+	inbox.Status = jobs.WebhookStatusPending
+	if err := w.inboxRepo.Update(ctx, inbox, "status"); err != nil {
+		return err
+	}
+	_, err = w.asynqRepo.WebhookTask(ctx, inbox, false)
+	return err
+}
+
+// Reconcile 推送 webhook 订阅核对任务，userID 为 0 时核对所有店铺
+func (w *WebhookService) Reconcile(ctx context.Context, userID int64) error {
+	_, err := w.asynqRepo.WebhookReconcileTask(ctx, userID)
+	return err
+}
+
+// Drifts 最近核对发现的订阅差异
+func (w *WebhookService) Drifts(ctx context.Context, userID int64, limit int) ([]*jobs.WebhookDrift, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return w.driftRepo.List(ctx, userID, limit)
+}
+
+// fail 记录失败原因后返回原错误
+func (w *WebhookService) fail(ctx context.Context, inbox *jobs.WebhookInbox, cause error) error {
+	inbox.Status = jobs.WebhookStatusFailed
+	inbox.ErrorMessage = cause.Error()
+	if utf8.RuneCountInString(inbox.ErrorMessage) > webhookErrorLength {
+		inbox.ErrorMessage = string([]rune(inbox.ErrorMessage)[:webhookErrorLength])
+	}
+	if err := w.inboxRepo.Update(ctx, inbox, "status", "attempts", "error_message"); err != nil {
+		logger.Error(ctx, "webhook_queue: 更新失败原因失败", inbox.Id, err.Error())
+	}
+	logger.Error(ctx, "webhook_queue: 处理失败", inbox.Id, inbox.Topic, cause.Error())
+	return cause
+}
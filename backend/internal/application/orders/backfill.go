@@ -0,0 +1,68 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/internal/domain/entity/jobs"
+	"backend/pkg/logger"
+)
+
+// maxBackfillDays 单次回填的最大天数
+const maxBackfillDays = 366
+
+// StartBackfill 创建历史订单回填任务，同一店铺同时只能有一个回填任务
+func (o *OrderService) StartBackfill(ctx context.Context, userID int64, req jobs.OrderBackfillReq) (*jobs.JobOrderBackfill, error) {
+	start, err := time.ParseInLocation(time.DateOnly, req.StartDate, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: %v", err)
+	}
+	end, err := time.ParseInLocation(time.DateOnly, req.EndDate, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date: %v", err)
+	}
+	// 包含结束日期当天
+	end = end.AddDate(0, 0, 1)
+	if now := time.Now().UTC(); end.After(now) {
+		end = now
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("start date must be before end date")
+	}
+	if end.Sub(start) > maxBackfillDays*24*time.Hour {
+		return nil, fmt.Errorf("date range must not exceed %d days", maxBackfillDays)
+	}
+
+	running, err := o.backfillRepo.HasUnfinished(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		return nil, fmt.Errorf("an order backfill is already running")
+	}
+
+	job := &jobs.JobOrderBackfill{
+		UserID:      userID,
+		CreatedFrom: start.Unix(),
+		CreatedTo:   end.Unix(),
+		Status:      jobs.BackfillStatusPending,
+	}
+	if _, err := o.backfillRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	if _, err := o.asynqRepo.OrderBackfillTask(ctx, job.Id, 0); err != nil {
+		logger.Error(ctx, "StartBackfill 推送回填队列失败:", err.Error())
+		job.Status = jobs.BackfillStatusFailed
+		job.ErrorMessage = "推送回填任务失败"
+		job.FinishTime = time.Now().Unix()
+		_ = o.backfillRepo.Update(ctx, job, "status", "error_message", "finish_time")
+		return nil, err
+	}
+	return job, nil
+}
+
+// LatestBackfill 最近一次回填任务的进度，没有回填过时返回空
+func (o *OrderService) LatestBackfill(ctx context.Context, userID int64) (*jobs.JobOrderBackfill, error) {
+	return o.backfillRepo.Latest(ctx, userID)
+}
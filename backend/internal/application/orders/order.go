@@ -7,6 +7,7 @@ import (
 
 	"backend/internal/domain/entity/jobs"
 	orderEntity "backend/internal/domain/entity/orders"
+	userEntity "backend/internal/domain/entity/users"
 	jobRepo "backend/internal/domain/repo/jobs"
 	"backend/internal/domain/repo/orders"
 	userRepo "backend/internal/domain/repo/users"
@@ -20,6 +21,7 @@ type OrderService struct {
 	jobOrderRepo    jobRepo.OrderRepository
 	userRepo        userRepo.UserRepository
 	asynqRepo       jobRepo.AsynqRepository
+	backfillRepo    jobRepo.OrderBackfillRepository
 }
 type OrderStatisticsTable struct {
 	Date   string  `json:"date"`
@@ -45,6 +47,7 @@ func NewOrderService(repos *providers.Repositories) *OrderService {
 		orderSummaryRep: repos.OrderSummaryRepo,
 		asynqRepo:       repos.AsyncRepo,
 		userRepo:        repos.UserRepo,
+		backfillRepo:    repos.OrderBackfillRepo,
 	}
 }
 
@@ -53,7 +56,7 @@ func (o *OrderService) Summary(ctx context.Context, userId int64, days int) (int
 	summary, err := o.orderSummaryRep.GetByDays(ctx, userId, days)
 	orderSummaryResp := &OrderSummaryResp{
 		OrderStatistics:      OrderStatistics{},
-		OrderStatisticsTable: make([]OrderStatisticsTable, len(summary)),
+		OrderStatisticsTable: make([]OrderStatisticsTable, 0, len(summary)),
 	}
 	if err != nil {
 		logger.Error(ctx, "summary-db异常:"+err.Error())
@@ -65,22 +68,20 @@ func (o *OrderService) Summary(ctx context.Context, userId int64, days int) (int
 		return orderSummaryResp, nil
 	}
 
-	// 2. 加载美国时区
-	loc, err := time.LoadLocation("America/New_York")
+	// 2. 每日统计按店铺时区的0点保存，日期也按店铺时区显示
+	user, err := o.userRepo.Get(ctx, userId)
 	if err != nil {
-		logger.Error(ctx, "summary-加载时间异常:"+err.Error())
+		logger.Error(ctx, "summary-查询用户异常:"+err.Error())
 		return nil, err
 	}
+	if user == nil {
+		user = &userEntity.User{}
+	}
+	loc := user.Location()
 
 	for _, v := range summary {
-		// 将 v.Today (时间戳) 转换为 time.Time
-		t := time.Unix(v.Today, 0)
-
-		// 转换到美国时区
-		t = t.In(loc)
-
-		// 格式化为美国时区的 Y-m-d 字符串
-		dateStr := t.Format("2006-01-02")
+		// 格式化为店铺时区的 Y-m-d 字符串
+		dateStr := time.Unix(v.Today, 0).In(loc).Format("2006-01-02")
 
 		orderSummaryResp.OrderStatisticsTable = append(orderSummaryResp.OrderStatisticsTable, OrderStatisticsTable{
 			Date:   dateStr,
@@ -104,76 +105,51 @@ func (o *OrderService) OrderList(ctx context.Context, req orderEntity.QueryOrder
 	return &orderEntity.OrderResponse{List: userOrders, Total: count}, err
 }
 
-// OrderSync 处理订单同步 WebHook
+// OrderSync 处理订单同步 WebHook，由 webhook 收件箱任务同步调用，返回错误时由队列重试
 func (o *OrderService) OrderSync(ctx context.Context, appId string, req orderEntity.OrderWebHookReq) error {
-	go func(req orderEntity.OrderWebHookReq) {
-		// 每个协程自己 new ctx，超时保护，防止协程卡死
-		newCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error(ctx, "OrderSync 协程 panic:", fmt.Sprintf("%v", r))
-			}
-		}()
-
-		row := o.jobOrderRepo.ExistsByOrderID(newCtx, req.OrderId)
-		if row != 0 {
-			logger.Info(ctx, "OrderSync 已存在订单记录，无需重复插入：", req.OrderId)
-			return
-		}
-
-		logger.Info(ctx, "OrderSync 订单日志不存在，开始插入：", req.OrderId, req.Shop)
-
-		userID, err := o.userRepo.GetUserIDByShop(ctx, appId, req.Shop)
-		if err != nil {
-			logger.Error(ctx, "get user error:"+err.Error())
-		}
-		log, err := o.jobOrderRepo.Create(newCtx, &jobs.JobOrder{
-			OrderId: req.OrderId,
-			UserID:  userID,
-		})
-		if err != nil {
-			logger.Error(ctx, "OrderSync 插入订单日志失败:", err.Error())
-			return
-		}
-
-		orderTask, err := o.asynqRepo.OrderWebhookTask(ctx, log)
-		if err != nil {
-			logger.Error(ctx, "OrderSync 推送订单队列失败:", err.Error(), orderTask)
-			return
-		}
-	}(req)
+	row := o.jobOrderRepo.ExistsByOrderID(ctx, req.OrderId)
+	if row != 0 {
+		logger.Info(ctx, "OrderSync 已存在订单记录，无需重复插入：", req.OrderId)
+		return nil
+	}
+
+	userID, err := o.userRepo.GetUserIDByShop(ctx, appId, req.Shop)
+	if err != nil {
+		return fmt.Errorf("get user error: %w", err)
+	}
+	if userID == 0 {
+		logger.Warn(ctx, "OrderSync 店铺未安装应用，忽略订单：", req.Shop, req.OrderId)
+		return nil
+	}
 
+	logger.Info(ctx, "OrderSync 订单日志不存在，开始插入：", req.OrderId, req.Shop)
+	log, err := o.jobOrderRepo.Create(ctx, &jobs.JobOrder{
+		OrderId: req.OrderId,
+		UserID:  userID,
+	})
+	if err != nil {
+		return fmt.Errorf("OrderSync 插入订单日志失败: %w", err)
+	}
+
+	if _, err = o.asynqRepo.OrderWebhookTask(ctx, log); err != nil {
+		return fmt.Errorf("OrderSync 推送订单队列失败: %w", err)
+	}
 	return nil
 }
 
 // OrderDel 处理订单删除 WebHook
 func (o *OrderService) OrderDel(ctx context.Context, req orderEntity.OrderWebHookReq) error {
-	go func(req orderEntity.OrderWebHookReq) {
-		newCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error(ctx, "OrderDel 协程 panic:", fmt.Sprintf("%v", r))
-			}
-		}()
-
-		uid, err := o.userRepo.GetUserIDByShop(newCtx, req.AppId, req.Shop)
-		if err != nil {
-			logger.Error(ctx, "OrderDel 获取UID失败:", err.Error())
-			return
-		}
-
-		if uid > 0 {
-			if err := o.orderRepo.DelOrder(newCtx, uid, req.OrderId); err != nil {
-				logger.Info(ctx, "OrderDel 删除订单失败：", req.OrderId, err.Error())
-				return
-			}
-			logger.Info(ctx, "OrderDel 成功删除订单：", req.OrderId)
-		}
-	}(req)
+	uid, err := o.userRepo.GetUserIDByShop(ctx, req.AppId, req.Shop)
+	if err != nil {
+		return fmt.Errorf("OrderDel 获取UID失败: %w", err)
+	}
+	if uid == 0 {
+		return nil
+	}
 
+	if err := o.orderRepo.DelOrder(ctx, uid, req.OrderId); err != nil {
+		return fmt.Errorf("OrderDel 删除订单失败: %w", err)
+	}
+	logger.Info(ctx, "OrderDel 成功删除订单：", req.OrderId)
 	return nil
 }
@@ -3,10 +3,10 @@ package products
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"backend/internal/domain/entity/jobs"
 	productEntity "backend/internal/domain/entity/products"
+	"backend/internal/domain/pricing"
 	cartRepo "backend/internal/domain/repo/carts"
 	jobRepo "backend/internal/domain/repo/jobs"
 	productRepo "backend/internal/domain/repo/products"
@@ -51,6 +51,22 @@ func (p *ProductService) UploadProduct(ctx context.Context, userID int64, iconUr
 	var shopifyProductId int64
 	var userProductId int64
 	if product == nil {
+		// 按购物车设置的变体阶梯生成变体价格
+		cartSetting, err := p.cartSettingRepo.First(ctx, userID)
+		if err != nil {
+			logger.Error(ctx, "upload-product 查询购物车设置异常", "Err:", err.Error())
+			return err
+		}
+		ladder, err := pricing.LadderFromSetting(cartSetting)
+		if err != nil {
+			logger.Error(ctx, "upload-product 解析变体阶梯异常", "Err:", err.Error())
+			return err
+		}
+		prices, err := ladder.Prices()
+		if err != nil {
+			logger.Error(ctx, "upload-product 生成变体阶梯异常", "Err:", err.Error())
+			return err
+		}
 		// 创建产品
 		userProductId, err = p.productRepo.CreateProduct(ctx, &productEntity.UserProduct{
 			UserID:      userID,
@@ -60,23 +76,14 @@ func (p *ProductService) UploadProduct(ctx context.Context, userID int64, iconUr
 			Description: "Protectify",
 			Option1:     "Title",
 			ImageUrl:    iconUrl,
+			// 新上传的商品按阶梯生成变体
+			LadderVersion: productEntity.LadderVersionLadder,
 		})
 
 		// 创建变体
-		var variants []*productEntity.UserVariant
-
-		price := 1.0
-		for i := 0; i < 100; i++ {
-			variant := &productEntity.UserVariant{
-				// 根据你的 productEntity.UserVariants 结构体，填充字段
-				UserID:        userID,
-				UserProductId: userProductId,
-				SkuName:       fmt.Sprintf("%.2f", price),
-				Sku1:          fmt.Sprintf("ZIK%d", i),
-				Price:         price,
-			}
-			price = price + 1.01
-			variants = append(variants, variant)
+		variants := make([]*productEntity.UserVariant, 0, len(prices))
+		for _, price := range prices {
+			variants = append(variants, productEntity.NewLadderVariant(userID, userProductId, price))
 		}
 
 		// 调用 DAO 创建方法
@@ -114,80 +121,42 @@ func (p *ProductService) UploadProduct(ctx context.Context, userID int64, iconUr
 	return nil
 }
 
+// ProductUpdate 处理产品更新 WebHook，动到我们的保险产品时触发更新
 func (p *ProductService) ProductUpdate(ctx context.Context, req productEntity.ProductWebHookReq) error {
-	// 动到我们的保险产品 触发更新产品
-	go func(req productEntity.ProductWebHookReq) {
-		// 给每个协程独立超时控制，比如10秒
-		newCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		// 保证防止panic导致进程挂掉
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error(ctx, "Product 更新协程 panic:", fmt.Sprintf("%v", r))
-			}
-		}()
-
-		uid, err := p.userRepo.GetUserIDByShop(newCtx, req.AppId, req.Shop)
-		if err != nil {
-			logger.Error(ctx, "Product 更新协程 获取UID失败:"+err.Error())
-			return
-		}
-
-		if uid > 0 {
-			productId := p.productRepo.ExistsByProductID(newCtx, uid, req.ProductId)
-			if productId > 0 {
-				_, err := p.asynqRepo.ProductWebhookUpdateTask(ctx, uid, productId)
-				if err != nil {
-					logger.Error(ctx, "ProductUpdate 推送产品队列失败:", err.Error())
-					return
-				}
-			}
-		}
-
-		if uid > 0 {
-			product, _ := p.productRepo.First(ctx, uid)
-			if product == nil {
-				return
-			}
-		}
+	uid, err := p.userRepo.GetUserIDByShop(ctx, req.AppId, req.Shop)
+	if err != nil {
+		return fmt.Errorf("ProductUpdate 获取UID失败: %w", err)
+	}
+	if uid == 0 {
+		return nil
+	}
 
-	}(req) // **参数传进来，避免闭包引用外层变量**
+	productId := p.productRepo.ExistsByProductID(ctx, uid, req.ProductId)
+	if productId == 0 {
+		return nil
+	}
+	if _, err := p.asynqRepo.ProductWebhookUpdateTask(ctx, uid, productId); err != nil {
+		return fmt.Errorf("ProductUpdate 推送产品队列失败: %w", err)
+	}
 	return nil
 }
 
+// ProductDel 处理产品删除 WebHook
 func (p *ProductService) ProductDel(ctx context.Context, req productEntity.ProductWebHookReq) error {
-	// **立刻返回**
-	go func(req productEntity.ProductWebHookReq) {
-		// 给每个协程独立超时控制，比如10秒
-		newCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		// 保证防止panic导致进程挂掉
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error(ctx, "Product 删除协程 panic:", fmt.Sprintf("%v", r))
-			}
-		}()
-
-		//查出 UID
-		uid, err := p.userRepo.GetUserIDByShop(newCtx, req.AppId, req.Shop)
-		if err != nil {
-			logger.Error(ctx, "Product 删除协程 获取UID失败:", err.Error())
-			return
-		}
-
-		if uid > 0 {
-			productId := p.productRepo.ExistsByProductID(newCtx, uid, req.ProductId)
-			if productId > 0 {
-				_, err := p.asynqRepo.DelProductTask(ctx, uid, req.ProductId, 0)
-				if err != nil {
-					logger.Error(ctx, "删除通知 del_product_queue 推送队列失败:", err.Error())
-					return
-				}
-			}
-		}
-	}(req) // **参数传进来，避免闭包引用外层变量**
+	uid, err := p.userRepo.GetUserIDByShop(ctx, req.AppId, req.Shop)
+	if err != nil {
+		return fmt.Errorf("ProductDel 获取UID失败: %w", err)
+	}
+	if uid == 0 {
+		return nil
+	}
 
+	productId := p.productRepo.ExistsByProductID(ctx, uid, req.ProductId)
+	if productId == 0 {
+		return nil
+	}
+	if _, err := p.asynqRepo.DelProductTask(ctx, uid, req.ProductId, 0); err != nil {
+		return fmt.Errorf("删除通知 del_product_queue 推送队列失败: %w", err)
+	}
 	return nil
 }
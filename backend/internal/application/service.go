@@ -2,6 +2,7 @@ package application
 
 import (
 	"backend/internal/application/apps"
+	"backend/internal/application/claims"
 	"backend/internal/application/files"
 	"backend/internal/application/jobs"
 	"backend/internal/application/orders"
@@ -12,17 +13,26 @@ import (
 )
 
 type Services struct {
-	UserService         *users.UserService
-	OrderService        *orders.OrderService
-	OrderJobService     *jobs.OrderService
-	UserJobService      *jobs.UserService
-	ProductJobService   *jobs.ProductService
-	CartSettingService  *settings.CartSettingService
-	ProductService      *products.ProductService
-	AppService          *apps.AppService
-	SubscriptionService *users.SubscriptionService
-	BillingService      *users.BillingService
-	FileService         *files.FileService
+	UserService              *users.UserService
+	OrderService             *orders.OrderService
+	OrderJobService          *jobs.OrderService
+	UserJobService           *jobs.UserService
+	ProductJobService        *jobs.ProductService
+	CommissionJobService     *jobs.CommissionService
+	BillingPeriodService     *jobs.BillingPeriodService
+	CappedAmountJobService   *jobs.CappedAmountService
+	ReconciliationJobService *jobs.ReconciliationService
+	ClaimJobService          *jobs.ClaimService
+	WebhookJobService        *jobs.WebhookService
+	CartSettingService       *settings.CartSettingService
+	ProductService           *products.ProductService
+	AppService               *apps.AppService
+	SubscriptionService      *users.SubscriptionService
+	BillingService           *users.BillingService
+	PlanService              *users.PlanService
+	FileService              *files.FileService
+	ClaimService             *claims.ClaimService
+	RedactService            *users.RedactService
 }
 
 func NewServices(repos *providers.Repositories) *Services {
@@ -31,23 +41,41 @@ func NewServices(repos *providers.Repositories) *Services {
 	orderJobService := jobs.NewOrderService(repos)
 	productJobService := jobs.NewProductService(repos)
 	userJobService := jobs.NewUserService(repos)
+	commissionJobService := jobs.NewCommissionService(repos)
+	billingPeriodService := jobs.NewBillingPeriodService(repos)
+	cappedAmountJobService := jobs.NewCappedAmountService(repos)
+	reconciliationJobService := jobs.NewReconciliationService(repos)
+	claimJobService := jobs.NewClaimService(repos)
+	webhookJobService := jobs.NewWebhookService(repos)
 	cartSettingService := settings.NewCartSettingService(repos)
 	productService := products.NewProductService(repos)
 	appService := apps.NewAppService(repos)
 	subscriptionService := users.NewSubscriptionService(repos)
 	billingService := users.NewBillingService(repos)
+	planService := users.NewPlanService(repos)
 	fileService := files.NewFileService(repos)
+	claimService := claims.NewClaimService(repos)
+	redactService := users.NewRedactService(repos)
 	return &Services{
-		SubscriptionService: subscriptionService,
-		UserService:         userService,
-		OrderService:        orderService,
-		OrderJobService:     orderJobService,
-		ProductJobService:   productJobService,
-		UserJobService:      userJobService,
-		CartSettingService:  cartSettingService,
-		ProductService:      productService,
-		AppService:          appService,
-		BillingService:      billingService,
-		FileService:         fileService,
+		SubscriptionService:      subscriptionService,
+		UserService:              userService,
+		OrderService:             orderService,
+		OrderJobService:          orderJobService,
+		ProductJobService:        productJobService,
+		UserJobService:           userJobService,
+		CommissionJobService:     commissionJobService,
+		BillingPeriodService:     billingPeriodService,
+		CappedAmountJobService:   cappedAmountJobService,
+		ReconciliationJobService: reconciliationJobService,
+		ClaimJobService:          claimJobService,
+		WebhookJobService:        webhookJobService,
+		CartSettingService:       cartSettingService,
+		ProductService:           productService,
+		AppService:               appService,
+		BillingService:           billingService,
+		PlanService:              planService,
+		FileService:              fileService,
+		ClaimService:             claimService,
+		RedactService:            redactService,
 	}
 }
@@ -8,12 +8,13 @@ import (
 	"backend/internal/domain/entity/apps"
 	cartEntity "backend/internal/domain/entity/settings"
 	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/pricing"
 	appRepo "backend/internal/domain/repo/apps"
 	cartSettingRepo "backend/internal/domain/repo/carts"
+	jobRepo "backend/internal/domain/repo/jobs"
 	"backend/internal/domain/repo/products"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	userRepo "backend/internal/domain/repo/users"
-	"backend/internal/infras/shopify_graphql"
 	"backend/internal/providers"
 	"backend/pkg/ctxkeys"
 	"backend/pkg/logger"
@@ -28,6 +29,7 @@ type CartSettingService struct {
 	subscriptionRepo userRepo.UserSubscriptionRepository
 	shopGraphqlRepo  shopifyRepo.ShopGraphqlRepository
 	appAuthRepo      appRepo.AppAuthRepository
+	asynqRepo        jobRepo.AsynqRepository
 }
 
 func NewCartSettingService(repos *providers.Repositories) *CartSettingService {
@@ -39,6 +41,7 @@ func NewCartSettingService(repos *providers.Repositories) *CartSettingService {
 		subscriptionRepo: repos.UserSubscriptionRepo,
 		shopGraphqlRepo:  repos.ShopGraphqlRepo,
 		appAuthRepo:      repos.AppAuthRepo,
+		asynqRepo:        repos.AsyncRepo,
 	}
 }
 
@@ -84,6 +87,14 @@ func (s *CartSettingService) GetCart(ctx context.Context, uid int64) (cartEntity
 		logger.Error(ctx, "get-cart 解析 TiersSelect 失败", "Err:", err.Error())
 		return cartEntity.CartSettingData{}, fmt.Errorf("解析 TiersSelect 失败: %w", err)
 	}
+	// 解析 LadderPoints
+	ladderPoints := make([]string, 0)
+	if cartSetting.LadderPoints != "" {
+		if err := json.Unmarshal([]byte(cartSetting.LadderPoints), &ladderPoints); err != nil {
+			logger.Error(ctx, "get-cart 解析 LadderPoints 失败", "Err:", err.Error())
+			return cartEntity.CartSettingData{}, fmt.Errorf("解析 LadderPoints 失败: %w", err)
+		}
+	}
 	var inCollection bool
 	if cartSetting.InCollection != 0 {
 		inCollection = true
@@ -101,6 +112,7 @@ func (s *CartSettingService) GetCart(ctx context.Context, uid int64) (cartEntity
 		InColor:           cartSetting.InColor,
 		OutColor:          cartSetting.OutColor,
 		ShowCart:          cartSetting.ShowCart,
+		CappedPaused:      cartSetting.CappedPaused,
 		ShowCartIcon:      cartSetting.ShowCartIcon,
 		Icons:             icons,
 		SelectButton:      cartSetting.SelectButton,
@@ -116,16 +128,45 @@ func (s *CartSettingService) GetCart(ctx context.Context, uid int64) (cartEntity
 		AllTiers:          cartSetting.AllTiersSet,
 		FulfillmentRule:   cartSetting.FulfillmentRule,
 		CSS:               cartSetting.CSS,
+		LadderMin:         cartSetting.LadderMin,
+		LadderMax:         cartSetting.LadderMax,
+		LadderStep:        cartSetting.LadderStep,
+		LadderPoints:      ladderPoints,
+		LadderRounding:    cartSetting.LadderRounding,
 	}, nil
 }
 
-// SetCartSetting  打开设置
-func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.SettingConfigReq) error {
+// SetCartSetting  打开设置，价格规则校验不通过时返回 *pricing.RuleError，保存成功时返回规则提醒
+func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.SettingConfigReq) (*pricing.RuleCheck, error) {
 	// 操作购物车设置
 	cartSetting, err := s.cartSettingRepo.First(ctx, req.UserID)
 	if err != nil {
 		logger.Error(ctx, "set-cart-db异常", "Err:", err.Error())
-		return err
+		return nil, err
+	}
+
+	// 没有设置阶梯时，已上传固定变体的保险商品继续使用原来的变体
+	product, err := s.productRepo.First(ctx, req.UserID)
+	if err != nil {
+		logger.Error(ctx, "set-cart 查询保险商品异常", "Err:", err.Error())
+		return nil, err
+	}
+	var base *pricing.Ladder
+	if product != nil && product.UsesLegacyLadder() {
+		base = pricing.LegacyLadder()
+	}
+
+	// 校验价格规则和变体阶梯，保存排序和格式化后的区间
+	check := pricing.CheckRules(&req, base)
+	if !check.Valid() {
+		return nil, &pricing.RuleError{Check: check}
+	}
+	req.PriceSelect = check.PriceSelect
+	req.TiersSelect = check.TiersSelect
+	ladder, err := ladderColumns(check.Ladder, len(req.LadderPoints) > 0 || req.LadderMin != "" || req.LadderMax != "" || req.LadderStep != "")
+	if err != nil {
+		logger.Error(ctx, "set-cart 变体阶梯json异常", "Err:", err.Error())
+		return nil, err
 	}
 
 	// 转成 JSON
@@ -133,7 +174,7 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 
 	if err != nil {
 		logger.Error(ctx, "set-cart 购物车图片json异常", "Err:", err.Error())
-		return err
+		return nil, err
 	}
 	iconStr := string(jsonIcon)
 
@@ -142,7 +183,7 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 
 	if err != nil {
 		logger.Error(ctx, "set-cart 价格json异常", "Err:", err.Error())
-		return err
+		return nil, err
 	}
 
 	priceStr := string(jsonPrice)
@@ -152,13 +193,13 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 
 	if err != nil {
 		logger.Error(ctx, "set-cart 百分比json异常", "Err:", err.Error())
-		return err
+		return nil, err
 	}
 
 	tiersStr := string(jsonTiers)
 	productCollection, err := json.Marshal(req.SelectedCollections)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var inCollection int
 	if req.InCollection {
@@ -170,6 +211,10 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 	if subscribe == nil {
 		req.ProtectifyVisibility = 0
 	}
+	// 用量达到上限被自动关闭时，等上限提高后自动恢复，不能手动打开
+	if cartSetting != nil && cartSetting.CappedPaused == 1 {
+		req.ProtectifyVisibility = 0
+	}
 	userCartSetting := cartEntity.UserCartSetting{
 		PlanTitle:         req.PlanTitle,
 		AddonTitle:        req.AddonTitle,
@@ -195,6 +240,11 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 		AllTiersSet:       utils.ParseMoneyFloat(req.AllTiers),
 		FulfillmentRule:   req.FulfillmentRule,
 		CSS:               req.CSS,
+		LadderMin:         ladder.LadderMin,
+		LadderMax:         ladder.LadderMax,
+		LadderStep:        ladder.LadderStep,
+		LadderPoints:      ladder.LadderPoints,
+		LadderRounding:    ladder.LadderRounding,
 	}
 	needOpenCartPlugin := 0
 	if cartSetting == nil {
@@ -218,22 +268,26 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 
 	if err != nil {
 		logger.Error(ctx, "set-cart-db(2)异常", "Err:", err.Error())
-		return err
+		return nil, err
+	}
+	// 价格规则或变体阶梯变化时按新的阶梯同步保险商品的变体
+	if cartSetting == nil || pricingChanged(cartSetting, &userCartSetting) {
+		if _, err := s.asynqRepo.VariantSyncTask(ctx, req.UserID); err != nil {
+			logger.Error(ctx, "set-cart 推送变体同步任务异常", "Err:", err.Error())
+		}
 	}
 	if needOpenCartPlugin > 0 {
 		// When needOpenCartPlugin == 1, enable cart; when == 2, disable cart via Shopify app metafield
-		client := ctx.Value(ctxkeys.ShopifyGraphqlClient).(*shopify_graphql.GraphqlClient)
 		appData := ctx.Value(ctxkeys.AppData).(*apps.AppData)
 		// Get current app installation to obtain ownerId for app metafields
-		s.shopGraphqlRepo.WithClient(client)
 		appAuth, err := s.appAuthRepo.GetByUserAndApp(ctx, req.UserID, appData.AppID)
 		if err != nil {
 			logger.Error(ctx, "appAuth fetch fail:"+err.Error())
-			return err
+			return nil, err
 		}
 		if appAuth == nil || appAuth.InstallationId == 0 {
 			logger.Error(ctx, "set-cart app安装ID为空")
-			return fmt.Errorf("app安装ID为空")
+			return nil, fmt.Errorf("app安装ID为空")
 		}
 
 		// Determine value to set based on needOpenCartPlugin
@@ -244,10 +298,10 @@ func (s *CartSettingService) SetCartSetting(ctx context.Context, req cartEntity.
 
 		_, err = s.shopGraphqlRepo.MetafieldSet(ctx, fmt.Sprintf("gid://shopify/AppInstallation/%d", appAuth.InstallationId), shopifyEntity.MetafieldConditionalNs, shopifyEntity.MetafieldTypeBoolean, "cart_enable", cartEnable)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	return nil
+	return check, nil
 }
 
 func (s *CartSettingService) GetPublicCart(ctx context.Context, appId string, shop string) (*cartEntity.CartPublicData, error) {
@@ -344,3 +398,91 @@ func (s *CartSettingService) GetPublicCart(ctx context.Context, appId string, sh
 		PricingType:    cartSetting.PricingType,
 	}, nil
 }
+
+// Quote 按购物车金额报价，购物车插件和订单抽成使用同一套价格规则
+func (s *CartSettingService) Quote(ctx context.Context, appId string, req *cartEntity.QuoteReq) (*cartEntity.QuoteResponse, error) {
+	user, err := s.userRepo.FirstByShop(ctx, appId, req.Shop)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.IsDel > 0 {
+		return nil, cartEntity.ErrShopNotFound
+	}
+	cartSetting, err := s.cartSettingRepo.First(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cartSetting == nil || cartSetting.ShowCart == 0 {
+		return nil, cartEntity.ErrCartDisabled
+	}
+
+	quote, err := pricing.Evaluate(cartSetting, req.Subtotal)
+	if err != nil {
+		return nil, err
+	}
+	variants, productID, err := s.variantRepo.GetVariantConfig(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	variant, ok := pricing.SelectVariant(quote.Price, variants, cartSetting.LadderRounding)
+	if !ok {
+		logger.Warn(ctx, "quote 没有能覆盖报价的保险变体", "uid:", user.ID, "price:", quote.Price.String())
+		return nil, cartEntity.ErrNoVariant
+	}
+
+	resp := &cartEntity.QuoteResponse{
+		Subtotal:     quote.Subtotal,
+		Price:        quote.Price,
+		PricingType:  quote.PricingType,
+		Source:       quote.Source,
+		ProductId:    productID,
+		VariantId:    variant.VariantId,
+		VariantPrice: variant.Price,
+		MoneyFormat:  user.MoneyFormat,
+	}
+	if quote.Range != nil {
+		resp.Rule = &cartEntity.QuoteRule{Min: quote.Range.Min, Max: quote.Range.Max, Value: quote.Range.Value}
+	}
+	return resp, nil
+}
+
+// ladderColumns 校验通过的变体阶梯转成保存的字段，custom 为 false 时只保存取整方式，继续使用默认阶梯
+func ladderColumns(ladder *pricing.Ladder, custom bool) (*cartEntity.UserCartSetting, error) {
+	columns := &cartEntity.UserCartSetting{LadderRounding: ladder.Rounding}
+	if !custom {
+		return columns, nil
+	}
+	if len(ladder.Points) > 0 {
+		points := make([]string, 0, len(ladder.Points))
+		for _, point := range ladder.Points {
+			points = append(points, point.StringFixed(2))
+		}
+		data, err := json.Marshal(points)
+		if err != nil {
+			return nil, err
+		}
+		columns.LadderPoints = string(data)
+		return columns, nil
+	}
+	columns.LadderMin = ladder.Min.InexactFloat64()
+	columns.LadderMax = ladder.Max.InexactFloat64()
+	columns.LadderStep = ladder.Step.InexactFloat64()
+	return columns, nil
+}
+
+// pricingChanged 价格规则或变体阶梯是否变化
+func pricingChanged(old *cartEntity.UserCartSetting, updated *cartEntity.UserCartSetting) bool {
+	return old.PricingType != updated.PricingType ||
+		old.PricingRule != updated.PricingRule ||
+		old.PricingSelect != updated.PricingSelect ||
+		old.TiersSelect != updated.TiersSelect ||
+		old.OutSelectPrice != updated.OutSelectPrice ||
+		old.OutSelectTier != updated.OutSelectTier ||
+		old.AllPriceSet != updated.AllPriceSet ||
+		old.AllTiersSet != updated.AllTiersSet ||
+		old.LadderMin != updated.LadderMin ||
+		old.LadderMax != updated.LadderMax ||
+		old.LadderStep != updated.LadderStep ||
+		old.LadderPoints != updated.LadderPoints ||
+		old.LadderRounding != updated.LadderRounding
+}
@@ -2,21 +2,43 @@ package users
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
 
 	"backend/internal/domain/entity"
 	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo"
 	"backend/internal/domain/repo/billings"
+	cartSettingRepo "backend/internal/domain/repo/carts"
+	jobRepo "backend/internal/domain/repo/jobs"
 	orderRepo "backend/internal/domain/repo/orders"
 	"backend/internal/domain/repo/users"
 	"backend/internal/providers"
 	"backend/pkg/logger"
+	"backend/pkg/utils"
 )
 
+// projectionDays 预测用量上限时参考最近几天的抽成
+const projectionDays = 7
+
+// statementLinkExpire 账单下载链接的有效期
+const statementLinkExpire = 30 * time.Minute
+
 type BillingService struct {
 	commissionBillRepo       billings.CommissionBillRepository
 	orderRepo                orderRepo.OrderRepository
 	subscriptionRepo         users.UserSubscriptionRepository
 	billingPeriodSummaryRepo billings.BillingPeriodSummaryRepository
+	cartSettingRepo          cartSettingRepo.CartSettingRepository
+	currencyConverter        billings.CurrencyConverter
+	reconciliationRepo       billings.ReconciliationRepository
+	asynqRepo                jobRepo.AsynqRepository
+	userRepo                 users.UserRepository
+	adjustmentRepo           billings.CommissionAdjustmentRepository
+	ossRepo                  repo.AliyunOSSRepository
+	statementRenderer        billings.StatementRenderer
 }
 
 func NewBillingService(repos *providers.Repositories) *BillingService {
@@ -25,6 +47,14 @@ func NewBillingService(repos *providers.Repositories) *BillingService {
 		orderRepo:                repos.OrderRepo,
 		billingPeriodSummaryRepo: repos.BillingPeriodSummaryRepo,
 		subscriptionRepo:         repos.UserSubscriptionRepo,
+		cartSettingRepo:          repos.CartSettingRepo,
+		currencyConverter:        repos.CurrencyConverter,
+		reconciliationRepo:       repos.ReconciliationRepo,
+		asynqRepo:                repos.AsyncRepo,
+		userRepo:                 repos.UserRepo,
+		adjustmentRepo:           repos.CommissionAdjustmentRepo,
+		ossRepo:                  repos.AliyunOssRepo,
+		statementRenderer:        repos.StatementRenderer,
 	}
 }
 
@@ -49,7 +79,21 @@ func (b *BillingService) BillDetails(ctx context.Context, userID int64, paginati
 		list = make([]*billingEntity.CommissionBill, 0)
 	}
 	count, _ := b.commissionBillRepo.CommissionCount(ctx, userID)
-	return &billingEntity.CommissionListResponse{List: list, Total: count}
+	response := &billingEntity.CommissionListResponse{List: list, Total: count}
+
+	// 区分待确认（未到达发货规则）和已确认的抽成金额
+	amounts, err := b.commissionBillRepo.RecognitionAmounts(ctx, userID)
+	if err != nil {
+		logger.Warn(ctx, "query commission recognition amount error: ", err)
+	}
+	for _, amount := range amounts {
+		if amount.RecognitionStatus == billingEntity.RecognitionStatusRecognized {
+			response.RecognizedAmount = amount.Amount
+		} else {
+			response.DeferredAmount = amount.Amount
+		}
+	}
+	return response
 }
 
 func (b *BillingService) CurrentBillDetail(ctx context.Context, userID int64) *billingEntity.CurrentPeriodResponse {
@@ -69,15 +113,155 @@ func (b *BillingService) CurrentBillDetail(ctx context.Context, userID int64) *b
 	if subscription.CurrentPeriodEnd == 0 {
 		return response
 	}
-	bill, err := b.billingPeriodSummaryRepo.GetByCurrentPeriod(ctx, userID, subscription.CurrentPeriodEnd)
+	// 订阅周期已经滚动但还没收到更新 webhook 时，按套餐的计费周期推算当前账期
+	period := billingEntity.CalcBillingPeriod(subscription, time.Now())
+	bill, err := b.billingPeriodSummaryRepo.GetByCurrentPeriod(ctx, userID, period.End)
 	if bill != nil {
 		response.Amount = bill.TotalCommissionAmount
 		response.PeriodStart = bill.BillingPeriodStart
 		response.PeriodEnd = bill.BillingPeriodEnd
 		return response
 	} else {
-		response.PeriodStart = subscription.CurrentPeriodStart
-		response.PeriodEnd = subscription.CurrentPeriodEnd
+		response.PeriodStart = period.Start
+		response.PeriodEnd = period.End
 	}
 	return response
 }
+
+// CappedAmountProjection 按最近7天的抽成速度预测订阅用量什么时候达到上限
+func (b *BillingService) CappedAmountProjection(ctx context.Context, userID int64) (*billingEntity.CappedAmountProjection, error) {
+	subscription, err := b.subscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	response := &billingEntity.CappedAmountProjection{}
+	if subscription == nil || subscription.CappedAmount <= 0 {
+		return response, nil
+	}
+
+	now := time.Now()
+	period := billingEntity.CalcBillingPeriod(subscription, now)
+	response.CappedAmount = subscription.CappedAmount
+	response.BalanceUsed = subscription.BalanceUsed
+	response.PeriodEnd = period.End
+	response.Currency = subscription.Currency
+
+	// 账单是店铺币种，需要换算成订阅上限的币种再比较
+	unchargedAmounts, err := b.commissionBillRepo.UnchargedAmounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	uncharged, err := b.currencyConverter.ConvertSum(ctx, unchargedAmounts, subscription.ChargeCurrency())
+	if err != nil {
+		return nil, err
+	}
+	recentAmounts, err := b.commissionBillRepo.RecognizedAmountsSince(ctx, userID, now.AddDate(0, 0, -projectionDays).Unix())
+	if err != nil {
+		return nil, err
+	}
+	recent, err := b.currencyConverter.ConvertSum(ctx, recentAmounts, subscription.ChargeCurrency())
+	if err != nil {
+		return nil, err
+	}
+	cartSetting, err := b.cartSettingRepo.First(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	response.Paused = cartSetting != nil && cartSetting.CappedPaused == 1
+
+	remaining := decimal.NewFromFloat(subscription.CappedAmount).
+		Sub(decimal.NewFromFloat(subscription.BalanceUsed)).
+		Sub(uncharged)
+	daily := recent.Div(decimal.NewFromInt(projectionDays))
+	response.UnchargedAmount = utils.DecimalToFloat(uncharged)
+	response.Remaining = utils.DecimalToFloat(decimal.Max(remaining, decimal.Zero))
+	response.DailyAmount = utils.DecimalToFloat(daily)
+
+	switch {
+	case !remaining.IsPositive():
+		response.ProjectedAt = now.Unix()
+	case daily.IsPositive():
+		seconds := remaining.Div(daily).Mul(decimal.NewFromInt(86400)).IntPart()
+		response.ProjectedAt = now.Unix() + seconds
+	}
+	response.WillReachCap = response.ProjectedAt > 0 && response.ProjectedAt < period.End
+	return response, nil
+}
+
+// ReconciliationList 后台查看账单对账差异
+func (b *BillingService) ReconciliationList(ctx context.Context, req *billingEntity.ReconciliationListReq) (*billingEntity.ReconciliationListResponse, error) {
+	list, err := b.reconciliationRepo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	count, err := b.reconciliationRepo.Count(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = make([]*billingEntity.BillingReconciliation, 0)
+	}
+	return &billingEntity.ReconciliationListResponse{List: list, Total: count}, nil
+}
+
+// RunReconciliation 后台手动触发对账，userID 为 0 时对所有活跃订阅对账
+func (b *BillingService) RunReconciliation(ctx context.Context, userID int64) error {
+	_, err := b.asynqRepo.BillingReconcileTask(ctx, userID)
+	return err
+}
+
+// Statement 生成账期账单文件并上传到 OSS，返回签名下载链接
+func (b *BillingService) Statement(ctx context.Context, userID int64, req *billingEntity.StatementReq) (*billingEntity.StatementResponse, error) {
+	summary, err := b.billingPeriodSummaryRepo.Get(ctx, userID, req.PeriodID)
+	if err != nil {
+		return nil, fmt.Errorf("查询账期汇总失败: %w", err)
+	}
+	if summary == nil {
+		return nil, fmt.Errorf("账期不存在")
+	}
+	user, err := b.userRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户信息失败: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("用户不存在")
+	}
+	bills, err := b.commissionBillRepo.PeriodBills(ctx, userID, summary.BillingPeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("查询账期账单失败: %w", err)
+	}
+	adjustments, err := b.adjustmentRepo.ListByPeriod(ctx, userID, summary.BillingPeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("查询账期调整流水失败: %w", err)
+	}
+
+	now := time.Now()
+	data, contentType, err := b.statementRenderer.Render(ctx, &billingEntity.Statement{
+		ShopName:    user.Name,
+		ShopDomain:  user.Shop,
+		Summary:     summary,
+		Bills:       bills,
+		Adjustments: adjustments,
+		GeneratedAt: now.Unix(),
+	}, req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("生成账单文件失败: %w", err)
+	}
+
+	// 汇总版本号变化说明账期数据有更新，文件名带上版本号避免下载到旧文件
+	fileName := fmt.Sprintf("statement-%s-v%d.%s", summary.BillCycle, summary.Version, req.Format)
+	objectKey := fmt.Sprintf("statements/%d/%d/%s", userID, summary.Id, fileName)
+	if err := b.ossRepo.PutObject(ctx, objectKey, data, contentType); err != nil {
+		return nil, fmt.Errorf("上传账单文件失败: %w", err)
+	}
+	url, err := b.ossRepo.SignURL(ctx, objectKey, statementLinkExpire)
+	if err != nil {
+		return nil, fmt.Errorf("生成下载链接失败: %w", err)
+	}
+	return &billingEntity.StatementResponse{
+		Url:      url,
+		Format:   req.Format,
+		FileName: fileName,
+		ExpireAt: now.Add(statementLinkExpire).Unix(),
+	}, nil
+}
@@ -0,0 +1,86 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+	"backend/internal/domain/repo/users"
+	"backend/internal/providers"
+)
+
+// PlanService 套餐目录和商家套餐权益
+type PlanService struct {
+	planRepo billings.PlanRepository
+	userRepo users.UserRepository
+}
+
+func NewPlanService(repos *providers.Repositories) *PlanService {
+	return &PlanService{
+		planRepo: repos.PlanRepo,
+		userRepo: repos.UserRepo,
+	}
+}
+
+// List 可订阅的套餐，并标记商家当前套餐
+func (p *PlanService) List(ctx context.Context, userID int64) (*billingEntity.PlanListResponse, error) {
+	plans, err := p.planRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %v", err)
+	}
+	current, err := p.CurrentPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &billingEntity.PlanListResponse{List: make([]*billingEntity.PlanItem, 0, len(plans))}
+	if current != nil {
+		resp.CurrentPlan = current.Code
+	}
+	for _, plan := range plans {
+		rates, err := plan.RateCard()
+		if err != nil {
+			return nil, fmt.Errorf("invalid commission rates of plan %s: %v", plan.Code, err)
+		}
+		resp.List = append(resp.List, &billingEntity.PlanItem{
+			AppPlan:         plan,
+			CommissionRates: rates,
+			Features:        plan.FeatureList(),
+			Current:         current != nil && current.Id == plan.Id,
+		})
+	}
+	return resp, nil
+}
+
+// CurrentPlan 商家当前套餐，没有订阅套餐时使用默认套餐
+func (p *PlanService) CurrentPlan(ctx context.Context, userID int64) (*billingEntity.AppPlan, error) {
+	user, err := p.userRepo.Get(ctx, userID, "id", "plans")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user != nil && user.Plans > 0 {
+		plan, err := p.planRepo.Get(ctx, int64(user.Plans))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get plan: %v", err)
+		}
+		// 套餐停用后商家回到默认套餐的权益
+		if plan != nil && plan.Status == billingEntity.PlanStatusEnabled {
+			return plan, nil
+		}
+	}
+	plan, err := p.planRepo.GetDefault(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default plan: %v", err)
+	}
+	return plan, nil
+}
+
+// HasFeature 商家当前套餐是否包含功能
+func (p *PlanService) HasFeature(ctx context.Context, userID int64, feature string) (bool, error) {
+	plan, err := p.CurrentPlan(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return plan != nil && plan.HasFeature(feature), nil
+}
@@ -0,0 +1,303 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/domain/entity/orders"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/entity/users"
+	"backend/internal/domain/repo"
+	claimRepo "backend/internal/domain/repo/claims"
+	jobRepo "backend/internal/domain/repo/jobs"
+	orderRepo "backend/internal/domain/repo/orders"
+	userRepo "backend/internal/domain/repo/users"
+	"backend/internal/providers"
+	"backend/pkg/logger"
+)
+
+// dataRequestLinkExpire 顾客数据导出文件下载链接的有效期
+const dataRequestLinkExpire = 30 * time.Minute
+
+// RedactService 处理 Shopify 合规 webhook：导出顾客数据、清除顾客数据和店铺数据
+type RedactService struct {
+	redactRepo    userRepo.RedactRepository
+	userRepo      userRepo.UserRepository
+	orderInfoRepo orderRepo.OrderInfoRepository
+	claimRepo     claimRepo.ClaimRepository
+	inboxRepo     jobRepo.WebhookInboxRepository
+	ossRepo       repo.AliyunOSSRepository
+}
+
+func NewRedactService(repos *providers.Repositories) *RedactService {
+	return &RedactService{
+		redactRepo:    repos.RedactRepo,
+		userRepo:      repos.UserRepo,
+		orderInfoRepo: repos.OrderInfoRep,
+		claimRepo:     repos.ClaimRepo,
+		inboxRepo:     repos.WebhookInboxRepo,
+		ossRepo:       repos.AliyunOssRepo,
+	}
+}
+
+// DataRequest 导出顾客的订单和理赔数据，上传后商家可以在后台下载
+func (s *RedactService) DataRequest(ctx context.Context, appId string, inboxID int64, req *shopifyEntity.CompliancePayload) error {
+	history, user, err := s.start(ctx, appId, inboxID, users.RedactTopicDataRequest, req)
+	if err != nil || history == nil {
+		return err
+	}
+	if user == nil {
+		return s.finish(ctx, history, 0)
+	}
+
+	userOrders, err := s.redactRepo.CustomerOrders(ctx, user.ID, req.OrdersRequested, req.Customer.Email)
+	if err != nil {
+		return fmt.Errorf("查询顾客订单失败: %w", err)
+	}
+	export := &shopifyEntity.CustomerDataExport{
+		Shop:        req.ShopDomain,
+		Customer:    req.Customer,
+		GeneratedAt: time.Now().Unix(),
+		Orders:      make([]*shopifyEntity.CustomerOrderExport, 0, len(userOrders)),
+	}
+	exports := make(map[int64]*shopifyEntity.CustomerOrderExport, len(userOrders))
+	for _, order := range userOrders {
+		items, err := s.orderInfoRepo.GetByUserOrderId(ctx, order.Id, user.ID)
+		if err != nil {
+			return fmt.Errorf("查询订单商品失败: %w", err)
+		}
+		orderExport := &shopifyEntity.CustomerOrderExport{Order: order, Items: items, Claims: make([]*shopifyEntity.CustomerClaimExport, 0)}
+		export.Orders = append(export.Orders, orderExport)
+		exports[order.Id] = orderExport
+	}
+
+	orderClaims, err := s.redactRepo.CustomerClaims(ctx, user.ID, orderIDs(userOrders))
+	if err != nil {
+		return fmt.Errorf("查询顾客理赔失败: %w", err)
+	}
+	for _, claim := range orderClaims {
+		items, err := s.claimRepo.Items(ctx, claim.Id)
+		if err != nil {
+			return fmt.Errorf("查询理赔商品失败: %w", err)
+		}
+		evidence, err := s.claimRepo.Evidence(ctx, claim.Id)
+		if err != nil {
+			return fmt.Errorf("查询理赔凭证失败: %w", err)
+		}
+		exports[claim.UserOrderId].Claims = append(exports[claim.UserOrderId].Claims, &shopifyEntity.CustomerClaimExport{
+			Claim:    claim,
+			Items:    items,
+			Evidence: evidence,
+		})
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	objectKey := fmt.Sprintf("compliance/%d/data-request-%d.json", user.ID, history.Id)
+	if err := s.ossRepo.PutObject(ctx, objectKey, data, "application/json"); err != nil {
+		return fmt.Errorf("上传顾客数据失败: %w", err)
+	}
+	history.FileKey = objectKey
+	return s.finish(ctx, history, len(userOrders)+len(orderClaims))
+}
+
+// RedactCustomer 清除顾客在订单和理赔中的个人信息，订单金额保留给账单使用
+func (s *RedactService) RedactCustomer(ctx context.Context, appId string, inboxID int64, req *shopifyEntity.CompliancePayload) error {
+	history, user, err := s.start(ctx, appId, inboxID, users.RedactTopicCustomer, req)
+	if err != nil || history == nil {
+		return err
+	}
+	if user == nil {
+		return s.finish(ctx, history, 0)
+	}
+
+	userOrders, err := s.redactRepo.CustomerOrders(ctx, user.ID, req.OrdersToRedact, req.Customer.Email)
+	if err != nil {
+		return fmt.Errorf("查询顾客订单失败: %w", err)
+	}
+	records, urls, err := s.redactRepo.RedactCustomer(ctx, user.ID, orderIDs(userOrders))
+	if err != nil {
+		return fmt.Errorf("清除顾客数据失败: %w", err)
+	}
+	s.deleteFiles(ctx, urls)
+
+	// 之前导出的顾客数据文件
+	exports, err := s.deleteExports(ctx, appId, req.ShopDomain, req.Customer.Id)
+	if err != nil {
+		return err
+	}
+
+	// 订单 webhook 原文里也有顾客信息
+	cleared, err := s.inboxRepo.ClearPayload(ctx, appId, req.ShopDomain, req.Customer.Id, req.Customer.Email, inboxID)
+	if err != nil {
+		return fmt.Errorf("清除 webhook 内容失败: %w", err)
+	}
+	return s.finish(ctx, history, records+exports+int(cleared))
+}
+
+// RedactShop 店铺卸载 48 小时后清除店铺数据，账单、订阅和统计数据按要求保留
+func (s *RedactService) RedactShop(ctx context.Context, appId string, inboxID int64, req *shopifyEntity.CompliancePayload) error {
+	history, user, err := s.start(ctx, appId, inboxID, users.RedactTopicShop, req)
+	if err != nil || history == nil {
+		return err
+	}
+	if user == nil {
+		return s.finish(ctx, history, 0)
+	}
+	// 收到 webhook 前店铺又重新安装了，数据还在使用
+	if user.IsDel == 0 {
+		logger.Warn(ctx, "shop/redact 店铺已重新安装，不清除数据：", req.ShopDomain)
+		return s.finish(ctx, history, 0)
+	}
+
+	records, urls, err := s.redactRepo.RedactShop(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("清除店铺数据失败: %w", err)
+	}
+	s.deleteFiles(ctx, urls)
+
+	exports, err := s.deleteExports(ctx, appId, req.ShopDomain, 0)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.inboxRepo.DeleteByShop(ctx, appId, req.ShopDomain, inboxID)
+	if err != nil {
+		return fmt.Errorf("删除店铺 webhook 失败: %w", err)
+	}
+	return s.finish(ctx, history, records+exports+int(deleted))
+}
+
+// DataRequests 店铺已完成的顾客数据请求
+func (s *RedactService) DataRequests(ctx context.Context, userID int64) ([]*users.RedactHistory, error) {
+	user, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return make([]*users.RedactHistory, 0), nil
+	}
+	return s.redactRepo.DataRequests(ctx, user.AppId, user.Shop)
+}
+
+// DataRequestDownload 生成顾客数据导出文件的下载链接
+func (s *RedactService) DataRequestDownload(ctx context.Context, userID int64, id int64) (*users.DataRequestResponse, error) {
+	user, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, users.ErrDataRequestNotFound
+	}
+	history, err := s.redactRepo.FirstDataRequest(ctx, user.AppId, user.Shop, id)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil || history.FileKey == "" {
+		return nil, users.ErrDataRequestNotFound
+	}
+	url, err := s.ossRepo.SignURL(ctx, history.FileKey, dataRequestLinkExpire)
+	if err != nil {
+		return nil, fmt.Errorf("生成下载链接失败: %w", err)
+	}
+	return &users.DataRequestResponse{
+		RedactHistory: history,
+		Url:           url,
+		ExpireAt:      time.Now().Add(dataRequestLinkExpire).Unix(),
+	}, nil
+}
+
+// start 创建或读取处理记录，已完成时返回 nil，队列重试不会重复处理
+func (s *RedactService) start(ctx context.Context, appId string, inboxID int64, topic string, req *shopifyEntity.CompliancePayload) (*users.RedactHistory, *users.User, error) {
+	history, err := s.redactRepo.FirstByInbox(ctx, inboxID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询处理记录失败: %w", err)
+	}
+	if history != nil && history.Status == users.RedactStatusCompleted {
+		return nil, nil, nil
+	}
+
+	user, err := s.userRepo.FirstByShop(ctx, appId, req.ShopDomain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询店铺失败: %w", err)
+	}
+	if history != nil {
+		return history, user, nil
+	}
+
+	history = &users.RedactHistory{
+		AppId:      appId,
+		Shop:       req.ShopDomain,
+		InboxId:    inboxID,
+		Topic:      topic,
+		CustomerId: req.Customer.Id,
+		RequestId:  req.DataRequest.Id,
+		Status:     users.RedactStatusPending,
+		Deadline:   time.Now().Add(users.RedactDeadline).Unix(),
+	}
+	if user != nil {
+		history.UserId = user.ID
+	}
+	if err := s.redactRepo.Create(ctx, history); err != nil {
+		return nil, nil, fmt.Errorf("创建处理记录失败: %w", err)
+	}
+	return history, user, nil
+}
+
+// finish 记录处理完成时间，超过 Shopify 期限时记录错误日志
+func (s *RedactService) finish(ctx context.Context, history *users.RedactHistory, records int) error {
+	now := time.Now().Unix()
+	history.Status = users.RedactStatusCompleted
+	history.Records = records
+	history.RedactTime = now
+	if now > history.Deadline {
+		logger.Error(ctx, "合规请求超过 Shopify 处理期限：", history.Topic, history.Shop, history.Id)
+	}
+	return s.redactRepo.Update(ctx, history, "status", "records", "file_key", "redact_time")
+}
+
+// deleteFiles 删除理赔凭证文件，数据库记录已经删除，文件删除失败只记录日志
+func (s *RedactService) deleteFiles(ctx context.Context, urls []string) {
+	prefix := s.ossRepo.ObjectURL("")
+	for _, url := range urls {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
+		if err := s.ossRepo.DeleteObject(ctx, strings.TrimPrefix(url, prefix)); err != nil {
+			logger.Error(ctx, "删除理赔凭证文件失败：", url, err.Error())
+		}
+	}
+}
+
+// deleteExports 删除顾客数据请求导出到 OSS 的文件并清空记录中的文件地址，customerID 为0时删除店铺所有导出文件。
+// 导出文件包含顾客个人信息，删除失败时返回错误由队列重试
+func (s *RedactService) deleteExports(ctx context.Context, appId string, shop string, customerID int64) (int, error) {
+	histories, err := s.redactRepo.DataRequestExports(ctx, appId, shop, customerID)
+	if err != nil {
+		return 0, fmt.Errorf("查询顾客数据导出记录失败: %w", err)
+	}
+	for _, history := range histories {
+		if err := s.ossRepo.DeleteObject(ctx, history.FileKey); err != nil {
+			return 0, fmt.Errorf("删除顾客数据导出文件失败: %w", err)
+		}
+		history.FileKey = ""
+		if err := s.redactRepo.Update(ctx, history, "file_key"); err != nil {
+			return 0, fmt.Errorf("更新顾客数据导出记录失败: %w", err)
+		}
+	}
+	return len(histories), nil
+}
+
+// orderIDs 订单主表ID
+func orderIDs(list []*orders.UserOrder) []int64 {
+	ids := make([]int64, 0, len(list))
+	for _, order := range list {
+		ids = append(ids, order.Id)
+	}
+	return ids
+}
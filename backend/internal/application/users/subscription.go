@@ -2,15 +2,21 @@ package users
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/hibiken/asynq"
 	"github.com/shopspring/decimal"
 
 	appEntity "backend/internal/domain/entity/apps"
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/entity/jobs"
 	shopifyEntity "backend/internal/domain/entity/shopifys"
 	userEntity "backend/internal/domain/entity/users"
+	"backend/internal/domain/repo/billings"
+	jobRepo "backend/internal/domain/repo/jobs"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/domain/repo/users"
 	"backend/internal/infras/shopify_graphql"
@@ -23,9 +29,13 @@ import (
 
 type SubscriptionService struct {
 	userSubscriptionRepo    users.UserSubscriptionRepository
+	billingPeriodRepo       billings.BillingPeriodSummaryRepository
 	subscriptionGraphqlRepo shopifyRepo.SubscriptionGraphqlRepository
 	usageChargeGraphqlRepo  shopifyRepo.UsageChargeGraphqlRepository
 	shopifyRepo             shopifyRepo.ShopifyRepository
+	asynqRepo               jobRepo.AsynqRepository
+	planRepo                billings.PlanRepository
+	userRepo                users.UserRepository
 }
 
 func NewSubscriptionService(
@@ -33,47 +43,37 @@ func NewSubscriptionService(
 ) *SubscriptionService {
 	return &SubscriptionService{
 		userSubscriptionRepo:    repos.UserSubscriptionRepo,
+		billingPeriodRepo:       repos.BillingPeriodSummaryRepo,
 		subscriptionGraphqlRepo: repos.SubscriptionGraphqlRepo,
 		usageChargeGraphqlRepo:  repos.UsageChargeGraphqlRepo,
 		shopifyRepo:             repos.ShopifyRepo,
+		asynqRepo:               repos.AsyncRepo,
+		planRepo:                repos.PlanRepo,
+		userRepo:                repos.UserRepo,
 	}
 }
 
-// CreateUsageSubscription 创建用量订阅
+// CreateUsageSubscription 按套餐创建用量订阅
 func (s *SubscriptionService) CreateUsageSubscription(
 	ctx context.Context,
-	planName string,
-	cappedAmount float64,
-	currency string,
-	terms string,
+	plan *billingEntity.AppPlan,
+	replacementBehavior string,
 	isTest bool,
 ) (*userEntity.UserSubscription, string, error) {
 	claims := ctx.Value(ctxkeys.BizClaims).(*jwt.BizClaims)
 	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
-	returnUrl := s.shopifyRepo.GetReturnUrl(appData.AppID, claims.UserID)
+	returnUrl := s.shopifyRepo.GetReturnUrl(appData.AppID, claims.UserID, appData.SigningSecret())
 	// 1. 构建订阅输入
 	input := shopifyEntity.AppSubscriptionCreateInput{
-		Name: planName,
-		Test: isTest,
-		LineItems: []shopifyEntity.AppSubscriptionLineItemInput{
-			{
-				Plan: shopifyEntity.AppPlanInput{
-					AppUsagePricingDetails: &shopifyEntity.AppUsagePricingDetailsInput{
-						CappedAmount: shopifyEntity.MoneyInput{
-							Amount:       cappedAmount,
-							CurrencyCode: currency,
-						},
-						Terms: terms,
-					},
-					AppRecurringPricingDetails: nil,
-				},
-			},
-		},
-		ReturnURL: returnUrl,
+		Name:                plan.Name,
+		Test:                isTest,
+		TrialDays:           plan.TrialDays,
+		LineItems:           []shopifyEntity.AppSubscriptionLineItemInput{usageLineItem(plan)},
+		ReturnURL:           returnUrl,
+		ReplacementBehavior: replacementBehavior,
 	}
 
 	// 2. 创建 Shopify 订阅
-	s.subscriptionGraphqlRepo.WithClient(ctx.Value(ctxkeys.ShopifyGraphqlClient).(*shopify_graphql.GraphqlClient))
 	subscription, confirmationURL, err := s.subscriptionGraphqlRepo.CreateSubscription(ctx, input)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create Shopify subscription: %v", err)
@@ -81,17 +81,18 @@ func (s *SubscriptionService) CreateUsageSubscription(
 	// 3. 保存到本地数据库
 	userSubscription := &userEntity.UserSubscription{
 		UserID:                 claims.UserID,
+		PlanId:                 plan.Id,
 		ShopDomain:             claims.Dest,
 		ChargeID:               utils.GetIdFromShopifyGraphqlId(subscription.ID),
 		SubscriptionName:       subscription.Name,
 		SubscriptionStatus:     subscription.Status,
-		SubscriptionLineItemID: subscription.LineItems[0].ID,
+		SubscriptionLineItemID: subscription.UsageLineItemID(),
 		PricingType:            userEntity.PricingTypeRecurring,
-		CappedAmount:           cappedAmount,
-		Currency:               currency,
+		CappedAmount:           plan.CappedAmount,
+		Currency:               plan.Currency,
 		BalanceUsed:            0,
 		Price:                  0,
-		Terms:                  terms,
+		Terms:                  plan.Terms,
 		CurrentPeriodStart:     time.Now().Unix(),
 		CurrentPeriodEnd:       utils.ParseShopifyTime(subscription.CurrentPeriodEnd),
 		TrialDays:              subscription.TrialDays,
@@ -107,60 +108,74 @@ func (s *SubscriptionService) CreateUsageSubscription(
 	return userSubscription, confirmationURL, nil
 }
 
-// CreateRecurringSubscription 创建循环订阅
+// CreateRecurringSubscription 按套餐创建循环订阅，套餐有用量上限时同时创建用量项目收取抽成
 func (s *SubscriptionService) CreateRecurringSubscription(
 	ctx context.Context,
 	userID int64,
 	shopDomain string,
-	planName string,
-	price float64,
-	currency string,
-	interval string, // EVERY_30_DAYS, ANNUAL
+	plan *billingEntity.AppPlan,
 	returnURL string,
+	replacementBehavior string,
 	isTest bool,
 ) (*userEntity.UserSubscription, string, error) {
+	// Shopify 的年付订阅不能包含用量项目
+	if plan.Interval == billingEntity.PlanIntervalAnnual && plan.CappedAmount > 0 {
+		return nil, "", fmt.Errorf("annual plan %s cannot include usage charges", plan.Code)
+	}
 
 	// 1. 构建订阅输入
-	input := shopifyEntity.AppSubscriptionCreateInput{
-		Name: planName,
-		Test: isTest,
-		LineItems: []shopifyEntity.AppSubscriptionLineItemInput{
-			{
-				Plan: shopifyEntity.AppPlanInput{
-					AppRecurringPricingDetails: &shopifyEntity.AppRecurringPricingDetailsInput{
-						Price: shopifyEntity.MoneyInput{
-							Amount:       price,
-							CurrencyCode: currency,
-						},
-						Interval: interval,
+	lineItems := []shopifyEntity.AppSubscriptionLineItemInput{
+		{
+			Plan: shopifyEntity.AppPlanInput{
+				AppRecurringPricingDetails: &shopifyEntity.AppRecurringPricingDetailsInput{
+					Price: shopifyEntity.MoneyInput{
+						Amount:       plan.Price,
+						CurrencyCode: plan.Currency,
 					},
+					Interval: plan.Interval,
 				},
 			},
 		},
-		ReturnURL: returnURL,
+	}
+	terms := fmt.Sprintf("Recurring charge - %s", plan.Interval)
+	if plan.CappedAmount > 0 {
+		lineItems = append(lineItems, usageLineItem(plan))
+		terms = plan.Terms
+	}
+	input := shopifyEntity.AppSubscriptionCreateInput{
+		Name:                plan.Name,
+		Test:                isTest,
+		TrialDays:           plan.TrialDays,
+		LineItems:           lineItems,
+		ReturnURL:           returnURL,
+		ReplacementBehavior: replacementBehavior,
 	}
 
 	// 2. 创建 Shopify 订阅
-	s.subscriptionGraphqlRepo.WithClient(ctx.Value(ctxkeys.ShopifyGraphqlClient).(*shopify_graphql.GraphqlClient))
 	subscription, confirmationURL, err := s.subscriptionGraphqlRepo.CreateSubscription(ctx, input)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create Shopify subscription: %v", err)
 	}
 
+	pricingType := userEntity.PricingTypeRecurring
+	if plan.Interval == billingEntity.PlanIntervalAnnual {
+		pricingType = userEntity.PricingTypeAnnual
+	}
 	// 3. 保存到本地数据库
 	userSubscription := &userEntity.UserSubscription{
 		UserID:                 userID,
+		PlanId:                 plan.Id,
 		ShopDomain:             shopDomain,
 		ChargeID:               utils.GetIdFromShopifyGraphqlId(subscription.ID),
 		SubscriptionName:       subscription.Name,
 		SubscriptionStatus:     subscription.Status,
-		SubscriptionLineItemID: subscription.LineItems[0].ID,
-		PricingType:            userEntity.PricingTypeRecurring,
-		Price:                  price, // 对于循环订阅，这里存储价格
-		Currency:               currency,
-		CappedAmount:           0,
+		SubscriptionLineItemID: subscription.UsageLineItemID(),
+		PricingType:            pricingType,
+		Price:                  plan.Price, // 对于循环订阅，这里存储价格
+		Currency:               plan.Currency,
+		CappedAmount:           plan.CappedAmount,
 		BalanceUsed:            0,
-		Terms:                  fmt.Sprintf("Recurring charge - %s", interval),
+		Terms:                  terms,
 		CurrentPeriodStart:     time.Now().Unix(),
 		CurrentPeriodEnd:       utils.ParseShopifyTime(subscription.CurrentPeriodEnd),
 		TrialDays:              subscription.TrialDays,
@@ -176,12 +191,204 @@ func (s *SubscriptionService) CreateRecurringSubscription(
 	return userSubscription, confirmationURL, nil
 }
 
+// usageLineItem 套餐的用量扣费项目
+func usageLineItem(plan *billingEntity.AppPlan) shopifyEntity.AppSubscriptionLineItemInput {
+	return shopifyEntity.AppSubscriptionLineItemInput{
+		Plan: shopifyEntity.AppPlanInput{
+			AppUsagePricingDetails: &shopifyEntity.AppUsagePricingDetailsInput{
+				CappedAmount: shopifyEntity.MoneyInput{
+					Amount:       plan.CappedAmount,
+					CurrencyCode: plan.Currency,
+				},
+				Terms: plan.Terms,
+			},
+		},
+	}
+}
+
+// ChangePlan 订阅或切换套餐，返回商家在 Shopify 确认的链接。
+// planCode 为空时订阅默认套餐；升级立即生效，降级在当前计费周期结束后生效，切换到免费套餐直接取消订阅。
+func (s *SubscriptionService) ChangePlan(ctx context.Context, userID int64, planCode string) (string, error) {
+	var plan *billingEntity.AppPlan
+	var err error
+	if planCode == "" {
+		plan, err = s.planRepo.GetDefault(ctx)
+	} else {
+		plan, err = s.planRepo.GetByCode(ctx, planCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get plan: %v", err)
+	}
+	if plan == nil {
+		return "", fmt.Errorf("plan %s not found", planCode)
+	}
+	user, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return "", fmt.Errorf("user not found")
+	}
+
+	current, err := s.userSubscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user subscription from database: %v", err)
+	}
+	if current != nil && current.PlanId == plan.Id {
+		return "", fmt.Errorf("already subscribed to plan %s", plan.Code)
+	}
+
+	if plan.IsFree() {
+		if err := s.cancelActiveSubscription(ctx, current); err != nil {
+			return "", err
+		}
+		if err := s.userRepo.UpdatePlan(ctx, userID, plan.Id); err != nil {
+			return "", fmt.Errorf("failed to update user plan: %v", err)
+		}
+		return "", nil
+	}
+
+	replacementBehavior := shopifyEntity.ReplacementApplyImmediately
+	if current != nil && current.PlanId > 0 {
+		currentPlan, err := s.planRepo.Get(ctx, current.PlanId)
+		if err != nil {
+			return "", fmt.Errorf("failed to get current plan: %v", err)
+		}
+		if currentPlan != nil && plan.Level < currentPlan.Level {
+			replacementBehavior = shopifyEntity.ReplacementApplyOnNextBillingCycle
+		}
+	}
+
+	// 开发店只能创建测试订阅
+	isTest := user.IsDevelopmentStore()
+	var confirmationURL string
+	if plan.Price > 0 {
+		appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+		returnURL := s.shopifyRepo.GetReturnUrl(appData.AppID, userID, appData.SigningSecret())
+		_, confirmationURL, err = s.CreateRecurringSubscription(ctx, userID, user.Shop, plan, returnURL, replacementBehavior, isTest)
+	} else {
+		_, confirmationURL, err = s.CreateUsageSubscription(ctx, plan, replacementBehavior, isTest)
+	}
+	if err != nil {
+		return "", err
+	}
+	return confirmationURL, nil
+}
+
+// CancelSubscription 取消当前订阅，商家回到默认套餐
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, userID int64) error {
+	current, err := s.userSubscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user subscription from database: %v", err)
+	}
+	if current == nil {
+		return fmt.Errorf("no active subscription")
+	}
+	if err := s.cancelActiveSubscription(ctx, current); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePlan(ctx, userID, 0); err != nil {
+		return fmt.Errorf("failed to update user plan: %v", err)
+	}
+	return nil
+}
+
+// cancelActiveSubscription 通过 appSubscriptionCancel 取消订阅并同步本地状态
+func (s *SubscriptionService) cancelActiveSubscription(ctx context.Context, subscription *userEntity.UserSubscription) error {
+	if subscription == nil {
+		return nil
+	}
+	gid := fmt.Sprintf("gid://shopify/AppSubscription/%d", subscription.ChargeID)
+	if _, err := s.subscriptionGraphqlRepo.CancelSubscription(ctx, gid, true); err != nil {
+		return fmt.Errorf("failed to cancel Shopify subscription: %v", err)
+	}
+	if err := s.userSubscriptionRepo.UpdateSubscriptionStatus(ctx, subscription.ChargeID, userEntity.SubscriptionStatusCancelled); err != nil {
+		return fmt.Errorf("failed to update subscription status: %v", err)
+	}
+	return nil
+}
+
+// activatePlan 订阅生效后把订阅的套餐设为商家当前套餐
+func (s *SubscriptionService) activatePlan(ctx context.Context, userID int64, chargeID int64) {
+	subscription, err := s.userSubscriptionRepo.GetSubscriptionByChargeID(ctx, chargeID)
+	if err != nil {
+		logger.Warn(ctx, "get subscription for plan error: ", err)
+		return
+	}
+	if subscription == nil || subscription.PlanId == 0 || subscription.SubscriptionStatus != userEntity.SubscriptionStatusActive {
+		return
+	}
+	if err := s.userRepo.UpdatePlan(ctx, userID, subscription.PlanId); err != nil {
+		logger.Warn(ctx, "update user plan error: ", err)
+	}
+}
+
+// BackfillPlans 按活跃订阅回填商家当前套餐，套餐目录上线前的订阅没有 plan_id，按订阅名称匹配套餐
+// 返回需要更新的商家数量，dryRun 时只统计不写入
+func (s *SubscriptionService) BackfillPlans(ctx context.Context, userID int64, dryRun bool) (int, error) {
+	plans, err := s.planRepo.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list plans: %v", err)
+	}
+	planByName := make(map[string]int64, len(plans))
+	for _, plan := range plans {
+		planByName[plan.Name] = plan.Id
+	}
+
+	// 同一商家有多个活跃订阅时以最新的订阅为准
+	userPlans := make(map[int64]int64)
+	var lastID int64
+	batchSize := 200
+	for {
+		subscriptions, err := s.userSubscriptionRepo.ActiveSubscriptions(ctx, lastID, batchSize)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list active subscriptions: %v", err)
+		}
+		for _, subscription := range subscriptions {
+			lastID = subscription.ID
+			if userID > 0 && subscription.UserID != userID {
+				continue
+			}
+			planID := subscription.PlanId
+			if planID == 0 {
+				planID = planByName[subscription.SubscriptionName]
+			}
+			if planID > 0 {
+				userPlans[subscription.UserID] = planID
+			}
+		}
+		if len(subscriptions) < batchSize {
+			break
+		}
+	}
+
+	updated := 0
+	for uid, planID := range userPlans {
+		user, err := s.userRepo.Get(ctx, uid, "id", "plans")
+		if err != nil {
+			return updated, fmt.Errorf("failed to get user %d: %v", uid, err)
+		}
+		if user == nil || int64(user.Plans) == planID {
+			continue
+		}
+		updated++
+		if dryRun {
+			continue
+		}
+		if err := s.userRepo.UpdatePlan(ctx, uid, planID); err != nil {
+			return updated, fmt.Errorf("failed to update plan of user %d: %v", uid, err)
+		}
+		logger.Info(ctx, "backfill_plans", fmt.Sprintf("user %d plan %d -> %d", uid, user.Plans, planID))
+	}
+	return updated, nil
+}
+
 // SyncSubscriptionStatus 同步订阅状态
 func (s *SubscriptionService) SyncSubscriptionStatus(ctx context.Context, user *userEntity.User) error {
 	// 1. 从 Shopify 获取当前订阅
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	s.subscriptionGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	currentSubscription, err := s.subscriptionGraphqlRepo.GetCurrentSubscription(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current subscription from Shopify: %v", err)
@@ -200,8 +407,18 @@ func (s *SubscriptionService) SyncSubscriptionStatus(ctx context.Context, user *
 
 	if userSubscription != nil {
 		// 更新现有订阅
+		periodEnd := utils.ParseShopifyTime(currentSubscription.CurrentPeriodEnd)
+		rollover := periodEnd > userSubscription.CurrentPeriodEnd
+		if rollover {
+			// 订阅进入新的计费周期，按套餐收费周期倒推周期开始时间
+			userSubscription.CurrentPeriodStart = periodEnd - int64(billingEntity.Cycle(userSubscription)/time.Second)
+		}
 		userSubscription.SubscriptionStatus = currentSubscription.Status
-		userSubscription.CurrentPeriodEnd = utils.ParseShopifyTime(currentSubscription.CurrentPeriodEnd)
+		userSubscription.CurrentPeriodEnd = periodEnd
+		if usagePricing, err := currentSubscription.GetUsagePricing(); err == nil {
+			userSubscription.CappedAmount, _ = strconv.ParseFloat(usagePricing.CappedAmount.Amount, 64)
+			userSubscription.BalanceUsed, _ = strconv.ParseFloat(usagePricing.BalanceUsed.Amount, 64)
+		}
 		userSubscription.LastSyncTime = time.Now().Unix()
 		userSubscription.UpdateTime = time.Now().Unix()
 
@@ -210,14 +427,21 @@ func (s *SubscriptionService) SyncSubscriptionStatus(ctx context.Context, user *
 			return fmt.Errorf("failed to update user subscription: %v", err)
 		}
 		newSubscription = userSubscription
+
+		// 关闭已经结束的账期，新的账期在订单确认抽成时创建
+		if rollover {
+			if err := s.billingPeriodRepo.CloseBillingPeriods(ctx, user.ID, userSubscription.CurrentPeriodStart); err != nil {
+				logger.Warn(ctx, "close billing period summary error: ", err)
+			}
+		}
 	} else {
 		// 创建新地订阅记录
 		if len(currentSubscription.LineItems) == 0 {
 			return fmt.Errorf("no line items found in current subscription")
 		}
 
-		// 解析订阅 ID
-		lineItemID := currentSubscription.LineItems[0].ID
+		// 解析订阅 ID，有用量项目时用量扣费需要用它的ID
+		lineItemID := currentSubscription.UsageLineItemID()
 
 		// 确定定价类型和相关信息
 		var pricingType string
@@ -236,6 +460,9 @@ func (s *SubscriptionService) SyncSubscriptionStatus(ctx context.Context, user *
 		} else if currentSubscription.IsRecurringSubscription() {
 			pricingType = userEntity.PricingTypeRecurring
 			recurringPricing, _ := currentSubscription.GetRecurringPricing()
+			if recurringPricing.Interval == billingEntity.PlanIntervalAnnual {
+				pricingType = userEntity.PricingTypeAnnual
+			}
 			cappedAmount, _ = strconv.ParseFloat(recurringPricing.Price.Amount, 64)
 			terms = fmt.Sprintf("Recurring subscription with %s interval", recurringPricing.Interval)
 			balanceUsed = 0
@@ -278,21 +505,126 @@ func (s *SubscriptionService) SyncSubscriptionStatus(ctx context.Context, user *
 			// 这里可以记录警告日志，但不返回错误，因为主要任务已经完成
 			fmt.Printf("Warning: failed to cancel other active subscriptions for user %d: %v\n", user.ID, err)
 		}
+		s.activatePlan(ctx, user.ID, currentChargeID)
+		// 周期滚动或上限提高后剩余额度可能变化，重新检查购物车保险是否需要暂停或恢复
+		s.checkCappedAmount(ctx, user.ID)
 	}
 
 	return nil
 }
 
+// HandleSubscriptionSync 定时从 Shopify 同步订阅状态，错过的订阅 webhook 和计费周期滚动在这里补上，单个用户失败不影响其他用户
+func (s *SubscriptionService) HandleSubscriptionSync(ctx context.Context, t *asynq.Task) error {
+	var payload jobs.SubscriptionSyncPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Error(ctx, "subscription_sync_queue: payload 反序列化失败", err)
+		return nil
+	}
+
+	if payload.UserID > 0 {
+		user, err := s.userRepo.Get(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("查询用户信息失败: %w", err)
+		}
+		if user == nil || user.IsDel != 0 {
+			return nil
+		}
+		return s.SyncSubscriptionStatus(ctx, user)
+	}
+
+	var lastID int64
+	var synced, failed int
+	batchSize := 200
+	for {
+		subscriptions, err := s.userSubscriptionRepo.ActiveSubscriptions(ctx, lastID, batchSize)
+		if err != nil {
+			logger.Error(ctx, "subscription_sync_queue: 查询活跃订阅失败", err)
+			return err
+		}
+		for _, subscription := range subscriptions {
+			lastID = subscription.ID
+			user, err := s.userRepo.Get(ctx, subscription.UserID)
+			if err != nil || user == nil || user.IsDel != 0 {
+				continue
+			}
+			if err := s.SyncSubscriptionStatus(ctx, user); err != nil {
+				failed++
+				logger.Error(ctx, fmt.Sprintf("subscription_sync_queue: 用户 %d 同步订阅失败", user.ID), err)
+				continue
+			}
+			synced++
+		}
+		if len(subscriptions) < batchSize {
+			break
+		}
+	}
+	logger.Info(ctx, "subscription_sync_queue", fmt.Sprintf("订阅同步完成, 成功: %d 失败: %d", synced, failed))
+	return nil
+}
+
+// HandleApproachingCappedAmount 用量接近上限时检查剩余额度，额度不足时暂停购物车保险
 func (s *SubscriptionService) HandleApproachingCappedAmount(ctx context.Context, chargeID int64) error {
 	logger.Warn(ctx, "usage capped amount approaching: ", chargeID)
+	subscription, err := s.userSubscriptionRepo.GetSubscriptionByChargeID(ctx, chargeID)
+	if err != nil {
+		return fmt.Errorf("failed to get user subscription from database: %v", err)
+	}
+	if subscription == nil {
+		return nil
+	}
+	s.checkCappedAmount(ctx, subscription.UserID)
 	return nil
 }
 
+// RequestCappedAmountIncrease 提高用量订阅上限，返回商家在 Shopify 确认的链接
+func (s *SubscriptionService) RequestCappedAmountIncrease(ctx context.Context, userID int64, cappedAmount float64) (string, error) {
+	subscription, err := s.userSubscriptionRepo.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user subscription from database: %v", err)
+	}
+	if subscription == nil || subscription.SubscriptionLineItemID == "" || subscription.CappedAmount <= 0 {
+		return "", fmt.Errorf("no active usage subscription")
+	}
+	if cappedAmount <= subscription.CappedAmount {
+		return "", fmt.Errorf("capped amount must be greater than current capped amount %.2f", subscription.CappedAmount)
+	}
+
+	confirmationURL, err := s.subscriptionGraphqlRepo.UpdateCappedAmount(ctx, subscription.SubscriptionLineItemID, shopifyEntity.MoneyInput{
+		Amount:       cappedAmount,
+		CurrencyCode: subscription.Currency,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update capped amount: %v", err)
+	}
+	return confirmationURL, nil
+}
+
+// checkCappedAmount 推送用量上限检查任务，失败只记录日志
+func (s *SubscriptionService) checkCappedAmount(ctx context.Context, userID int64) {
+	if _, err := s.asynqRepo.CappedAmountTask(ctx, userID); err != nil {
+		logger.Error(ctx, "push capped amount task error: ", err)
+	}
+}
+
+// LegacyChargeCallback 支付回调地址加签名之前创建的订阅，回调没有签名。
+// 只有本地记录的该用户订阅，并且创建时间早于配置的截止时间才放行，之后还需要 VerifyPayment 向 Shopify 核对订阅
+func (s *SubscriptionService) LegacyChargeCallback(ctx context.Context, userID int64, chargeID int64) bool {
+	subscription, err := s.userSubscriptionRepo.GetSubscriptionByChargeID(ctx, chargeID)
+	if err != nil {
+		logger.Error(ctx, "charge callback 查询订阅失败", err.Error(), "charge id is: ", chargeID)
+		return false
+	}
+	if subscription == nil || subscription.UserID != userID {
+		return false
+	}
+	return s.shopifyRepo.UnsignedReturnAllowed(subscription.CreateTime)
+}
+
 func (s *SubscriptionService) VerifyPayment(ctx context.Context, user *userEntity.User, chargeID int64) (*userEntity.UserSubscription, error) {
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
 
-	s.subscriptionGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	subscription, err := s.subscriptionGraphqlRepo.GetRecurrentChargeByID(ctx, chargeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subscription from Shopify: %v", err)
@@ -304,7 +636,6 @@ func (s *SubscriptionService) VerifyPayment(ctx context.Context, user *userEntit
 	CappedAmount, _ := strconv.ParseFloat(usagePrice.CappedAmount.Amount, 64)
 	BalanceUsed, _ := strconv.ParseFloat(usagePrice.BalanceUsed.Amount, 64)
 	Terms := usagePrice.Terms
-	fmt.Println(subscription.LineItems[0].ID)
 	// 3. 保存到本地数据库
 	userSubscription := &userEntity.UserSubscription{
 		UserID:                 user.ID,
@@ -312,7 +643,7 @@ func (s *SubscriptionService) VerifyPayment(ctx context.Context, user *userEntit
 		ChargeID:               utils.GetIdFromShopifyGraphqlId(subscription.ID),
 		SubscriptionName:       subscription.Name,
 		SubscriptionStatus:     subscription.Status,
-		SubscriptionLineItemID: subscription.LineItems[0].ID,
+		SubscriptionLineItemID: subscription.UsageLineItemID(),
 		PricingType:            userEntity.PricingTypeRecurring,
 		CappedAmount:           CappedAmount, // 对于循环订阅，这里存储价格
 		Currency:               usagePrice.CappedAmount.CurrencyCode,
@@ -328,6 +659,9 @@ func (s *SubscriptionService) VerifyPayment(ctx context.Context, user *userEntit
 	if err != nil {
 		return nil, fmt.Errorf("failed to save subscription to database: %v", err)
 	}
+	s.activatePlan(ctx, user.ID, userSubscription.ChargeID)
+	// 商家确认提高上限后同样会回到这里
+	s.checkCappedAmount(ctx, user.ID)
 	return userSubscription, nil
 }
 
@@ -342,14 +676,24 @@ func (s *SubscriptionService) UpdateSubscriptionStatus(ctx context.Context, user
 			return err
 		}
 	}
+	// 订阅取消或过期且没有其它生效的订阅时回到默认套餐
+	if user != nil && status != userEntity.SubscriptionStatusActive && status != userEntity.SubscriptionStatusPending {
+		active, err := s.userSubscriptionRepo.GetActiveSubscription(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if active == nil {
+			return s.userRepo.UpdatePlan(ctx, user.ID, 0)
+		}
+	}
 	return nil
 }
 
 func (s *SubscriptionService) CreateUsageCharge(ctx context.Context, user *userEntity.User, lineItemId string, amount decimal.Decimal, currency string) error {
 	shopName, _ := utils.GetShopName(user.Shop)
 	client := shopify_graphql.NewGraphqlClient(shopName, user.AccessToken)
-	s.usageChargeGraphqlRepo.WithClient(client)
-	usageRecordID, err := s.usageChargeGraphqlRepo.CreateUsageCharge(ctx, lineItemId, amount, currency)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
+	usageRecordID, err := s.usageChargeGraphqlRepo.CreateUsageCharge(ctx, lineItemId, amount, currency, "Protectify usage charge", "")
 	if err != nil {
 		logger.Error(ctx, "failed to create usage charge: ", err)
 		return err
@@ -104,7 +104,7 @@ func (u *UserService) AuthFromSession(ctx context.Context, sessionToken *shopify
 		return nil, err
 	}
 	client := shopify_graphql.NewGraphqlClient(shopName, sessionToken.Token)
-	u.shopGraphqlRepo.WithClient(client)
+	ctx = shopify_graphql.ContextWithClient(ctx, client)
 	shop, currentInstallation, err := u.shopGraphqlRepo.GetShopInfo(ctx)
 	if err != nil {
 		logger.Error(ctx, "shopify_graphql_repo.GetShopInfo", zap.Error(err))
@@ -115,6 +115,7 @@ func (u *UserService) AuthFromSession(ctx context.Context, sessionToken *shopify
 	if user == nil {
 		user = &users.User{}
 	}
+	previousTimezone := user.IanaTimezone
 	user.AppId = appID
 	user.AccessToken = sessionToken.Token
 	user.Shop = claims.Dest
@@ -126,6 +127,7 @@ func (u *UserService) AuthFromSession(ctx context.Context, sessionToken *shopify
 		user.Email = shop.Email
 		user.Phone = shop.BillingAddress.Phone
 		user.Timezone = shop.TimezoneOffsetMinutes
+		user.IanaTimezone = shop.IanaTimezone
 		user.PlanDisplayName = shop.Plan.DisplayName
 		user.Name = shop.Name
 		user.CurrencyCode = shop.CurrencyCode
@@ -136,6 +138,7 @@ func (u *UserService) AuthFromSession(ctx context.Context, sessionToken *shopify
 		if err != nil {
 			return nil, err
 		}
+		u.migrateSummary(ctx, user.ID, previousTimezone, user.IanaTimezone)
 	} else {
 		id, err := u.userRepo.CreateUser(ctx, user)
 		if err != nil {
@@ -269,8 +272,6 @@ func (u *UserService) GetUserConf(ctx context.Context, userID int64) (*UserConfi
 
 	// 使用 GraphQL 检测 embed block 是否启用
 	hasEmbed := false
-	graphqlClient := u.GetShopifyClient(ctx)
-	u.themeGraphqlRepo.WithClient(graphqlClient)
 	settingJson, err := u.themeGraphqlRepo.GetMainThemeSettingJson(ctx)
 	hasEmbed = u.detectAppEmbedInstalledBySettingJson(ctx, settingJson, "5fc19a33-9eee-4b3d-a5ea-5150881a50e8")
 	return &UserConfigResponse{
@@ -334,15 +335,17 @@ func (u *UserService) GetSessionData(ctx context.Context, userID int64) (*userEn
 	}, nil
 }
 
+// SyncShopifyUserInfo 处理 shop/update webhook，同步店铺基本信息，关店时清理保险产品
 func (u *UserService) SyncShopifyUserInfo(ctx context.Context, appId string, shop string, planDisplayName string) error {
-	user, err := u.userRepo.FirstName(ctx, appId, shop)
+	user, err := u.userRepo.GetActiveUserByShop(ctx, appId, shop)
 
 	if err != nil {
 		logger.Error(ctx, "sync-user-info db异常", "Err:", err.Error())
 		return err
 	}
 
-	if user == nil || user.IsDel != 1 {
+	// 未安装或已卸载的店铺不再同步
+	if user == nil {
 		return nil
 	}
 
@@ -364,7 +367,12 @@ func (u *UserService) SyncShopifyUserInfo(ctx context.Context, appId string, sho
 		return nil
 	}
 
-	u.shopGraphqlRepo.WithClient(u.GetShopifyClient(ctx))
+	// webhook 请求没有会话，使用店铺保存的 token 创建 client
+	shopName, err := utils.GetShopName(user.Shop)
+	if err != nil {
+		return err
+	}
+	ctx = shopify_graphql.ContextWithClient(ctx, shopify_graphql.NewGraphqlClient(shopName, user.AccessToken))
 
 	// 拿到Token 需要去获取用户基本信息
 	shopInfo, currentInstallation, err := u.shopGraphqlRepo.GetShopInfo(ctx) // 通过 client 调用方法
@@ -379,14 +387,20 @@ func (u *UserService) SyncShopifyUserInfo(ctx context.Context, appId string, sho
 	}))
 	var userModel = &users.User{}
 	userModel.ID = user.ID
+	userModel.Shop = user.Shop
+	userModel.AccessToken = user.AccessToken
 	userModel.City = shopInfo.BillingAddress.City
 	userModel.CountryCode = shopInfo.BillingAddress.CountryCodeV2
 	userModel.CountryName = shopInfo.BillingAddress.Country
 	userModel.CurrencyCode = shopInfo.CurrencyCode
 	userModel.MoneyFormat = u.shopifyRepo.ExtractCurrencySymbol(shopInfo.CurrencyFormats.MoneyFormat)
 	userModel.PlanDisplayName = shopInfo.Plan.DisplayName
+	userModel.Timezone = shopInfo.TimezoneOffsetMinutes
+	userModel.IanaTimezone = shopInfo.IanaTimezone
 
-	_ = u.userRepo.Update(ctx, userModel)
+	if err := u.userRepo.Update(ctx, userModel); err == nil {
+		u.migrateSummary(ctx, user.ID, user.IanaTimezone, userModel.IanaTimezone)
+	}
 	logger.Warn(ctx, "update user auth info ", zap.Any("shop", map[string]interface{}{
 		"shop":         shop,
 		"user":         user.ID,
@@ -399,6 +413,16 @@ func (u *UserService) SyncShopifyUserInfo(ctx context.Context, appId string, sho
 	return nil
 }
 
+// migrateSummary 店铺时区变化后按新时区重新计算每日统计，推送失败不影响登录和同步
+func (u *UserService) migrateSummary(ctx context.Context, userID int64, previous string, current string) {
+	if current == "" || current == previous {
+		return
+	}
+	if _, err := u.asynqRepo.SummaryMigrateTask(ctx, userID, 0); err != nil {
+		logger.Error(ctx, "summary_migrate_queue 推送队列失败:", err.Error())
+	}
+}
+
 func (u *UserService) UpsertUserAppAuth(ctx context.Context, user *users.User, currentInstallation *shopifyEntity.CurrentAppInstallation) error {
 	scopes := make([]string, 0, len(currentInstallation.AccessScopes))
 	for _, scope := range currentInstallation.AccessScopes {
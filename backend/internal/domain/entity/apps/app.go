@@ -5,4 +5,14 @@ type AppData struct {
 	AppID     string `json:"app_id"`     // 应用ID
 	AppKey    string `json:"app_key"`    // 应用Key
 	AppSecret string `json:"app_secret"` // 应用Secret
+	// AppSecrets 验签时使用的全部有效 Secret，包含轮换中的旧 Secret
+	AppSecrets []string `json:"-"`
+}
+
+// SigningSecret 签名使用的 Secret，轮换期间使用最新的 Secret
+func (a *AppData) SigningSecret() string {
+	if len(a.AppSecrets) > 0 {
+		return a.AppSecrets[0]
+	}
+	return a.AppSecret
 }
@@ -11,8 +11,8 @@ type UserAppAuth struct {
 	Shop           string `xorm:"notnull varchar(100) default '' 'shop' comment('my shopify Domain网站域名')"`
 	AppId          string `xorm:"notnull varchar(50) 'app_id' comment('App标识')"`
 	InstallationId int64  `xorm:"notnull bigint unsigned default 0 'installation_id' comment('用户安装app id')"`
-	AuthToken      string `xorm:"varchar(255) default '' 'auth_token' comment('授权token')"`
-	RefreshToken   string `xorm:"varchar(255) default '' 'refresh_token' comment('刷新token')"`
+	AuthToken      string `xorm:"varchar(1024) default '' 'auth_token' comment('授权token，信封加密')"`
+	RefreshToken   string `xorm:"varchar(1024) default '' 'refresh_token' comment('刷新token，信封加密')"`
 	TokenExpiresAt int64  `xorm:"default 0 'token_expires_at' comment('token过期时间')"`
 	Scopes         string `xorm:"notnull text 'scopes' comment('授权域')"`
 	Status         int8   `xorm:"notnull tinyint(1) default 1 'status' comment('状态 1:有效 0:已撤销')"`
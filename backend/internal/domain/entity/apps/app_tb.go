@@ -1,20 +1,23 @@
 package apps
 
+import "strings"
+
 // AppDefinition App定义表
 type AppDefinition struct {
-	Id          int64  `xorm:"pk autoincr 'id' comment('ID')"`
-	AppId       string `xorm:"notnull varchar(50) 'app_id' comment('App唯一标识')"`
-	Name        string `xorm:"notnull varchar(100) 'name' comment('App名称')"`
-	Description string `xorm:"text 'description' comment('App描述')"`
-	IconUrl     string `xorm:"varchar(255) default '' 'icon_url' comment('App图标')"`
-	CallbackUrl string `xorm:"varchar(255) default '' 'callback_url' comment('回调URL')"`
-	AppLink     string `xorm:"notnull varchar(100) 'app_link' comment('APP Link')"`
-	ApiKey      string `xorm:"notnull varchar(100) 'api_key' comment('API Key')"`
-	ApiSecret   string `xorm:"notnull varchar(100) 'api_secret' comment('API Secret')"`
-	Scopes      string `xorm:"notnull text 'scopes' comment('授权域')"`
-	Status      int8   `xorm:"notnull tinyint(1) default 1 'status' comment('状态 1:启用 0:禁用')"`
-	CreateTime  int64  `xorm:"created 'create_time' bigint(20) default 0 notnull comment('创建时间')" json:"create_time"`
-	UpdateTime  int64  `xorm:"updated 'update_time' bigint(20) default 0 notnull comment('最近修改时间')" json:"update_time"`
+	Id              int64  `xorm:"pk autoincr 'id' comment('ID')"`
+	AppId           string `xorm:"notnull varchar(50) 'app_id' comment('App唯一标识')"`
+	Name            string `xorm:"notnull varchar(100) 'name' comment('App名称')"`
+	Description     string `xorm:"text 'description' comment('App描述')"`
+	IconUrl         string `xorm:"varchar(255) default '' 'icon_url' comment('App图标')"`
+	CallbackUrl     string `xorm:"varchar(255) default '' 'callback_url' comment('回调URL')"`
+	AppLink         string `xorm:"notnull varchar(100) 'app_link' comment('APP Link')"`
+	ApiKey          string `xorm:"notnull varchar(100) 'api_key' comment('API Key')"`
+	ApiSecret       string `xorm:"notnull varchar(100) 'api_secret' comment('API Secret')"`
+	PreviousSecrets string `xorm:"text 'previous_secrets' comment('轮换中仍有效的旧 Secret，逗号分隔')"`
+	Scopes          string `xorm:"notnull text 'scopes' comment('授权域')"`
+	Status          int8   `xorm:"notnull tinyint(1) default 1 'status' comment('状态 1:启用 0:禁用')"`
+	CreateTime      int64  `xorm:"created 'create_time' bigint(20) default 0 notnull comment('创建时间')" json:"create_time"`
+	UpdateTime      int64  `xorm:"updated 'update_time' bigint(20) default 0 notnull comment('最近修改时间')" json:"update_time"`
 }
 
 // TableName 设置 AppDefinition 对应的表名
@@ -22,6 +25,20 @@ func (a *AppDefinition) TableName() string {
 	return "app_definition"
 }
 
+// ActiveSecrets 当前有效的 Secret，新 Secret 在前
+func (a *AppDefinition) ActiveSecrets() []string {
+	secrets := make([]string, 0, 2)
+	if secret := strings.TrimSpace(a.ApiSecret); secret != "" {
+		secrets = append(secrets, secret)
+	}
+	for _, secret := range strings.Split(a.PreviousSecrets, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
 // AppConfig App配置表
 type AppConfig struct {
 	Id          int64  `xorm:"pk autoincr 'id' comment('ID')"`
@@ -1,8 +1,22 @@
 package billings
 
 type CommissionListResponse struct {
-	List  []*CommissionBill `json:"list"`
-	Total int64             `json:"total"`
+	List             []*CommissionBill `json:"list"`
+	Total            int64             `json:"total"`
+	DeferredAmount   float64           `json:"deferred_amount"`   // 待确认的抽成金额
+	RecognizedAmount float64           `json:"recognized_amount"` // 已确认的抽成金额
+}
+
+// CurrencyAmount 按币种汇总的抽成金额
+type CurrencyAmount struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// RecognitionAmount 按确认状态汇总的抽成金额
+type RecognitionAmount struct {
+	RecognitionStatus int8    `xorm:"'recognition_status'"`
+	Amount            float64 `xorm:"'amount'"`
 }
 type BillingSummaryResponse struct {
 	List  []*BillingPeriodSummary `json:"list"`
@@ -13,3 +27,21 @@ type CurrentPeriodResponse struct {
 	PeriodEnd   int64   `json:"period_end"`
 	Amount      float64 `json:"amount"`
 }
+
+// CappedAmountProjection 用量上限预测，按最近的抽成速度估算额度用完的时间
+type CappedAmountProjection struct {
+	CappedAmount    float64 `json:"capped_amount"`
+	BalanceUsed     float64 `json:"balance_used"`
+	UnchargedAmount float64 `json:"uncharged_amount"` // 已确认还没扣费的抽成
+	Remaining       float64 `json:"remaining"`
+	DailyAmount     float64 `json:"daily_amount"`   // 最近7天平均每天的抽成
+	ProjectedAt     int64   `json:"projected_at"`   // 预计达到上限的时间，0 表示无法估算
+	WillReachCap    bool    `json:"will_reach_cap"` // 本计费周期内是否会达到上限
+	PeriodEnd       int64   `json:"period_end"`
+	Currency        string  `json:"currency"`
+	Paused          bool    `json:"paused"` // 购物车保险是否因达到上限被自动关闭
+}
+
+type CappedAmountReq struct {
+	CappedAmount float64 `json:"capped_amount" binding:"required,gt=0"`
+}
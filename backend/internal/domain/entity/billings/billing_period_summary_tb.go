@@ -1,9 +1,20 @@
 package billings
 
+import "errors"
+
 const (
 	BillingPeriodSummaryTableName = "billing_period_summary"
 )
 
+// 账期状态，订阅进入下一个计费周期后之前的账期关闭
+const (
+	SummaryStatusOpen   = "open"
+	SummaryStatusClosed = "closed"
+)
+
+// ErrSummaryVersionConflict 按版本号更新账期汇总时数据已经被其它任务修改
+var ErrSummaryVersionConflict = errors.New("账期汇总版本冲突")
+
 type BillingPeriodSummary struct {
 	Id                    int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                                // ID
 	UserId                int64   `xorm:"bigint UNSIGNED 'user_id' comment('用户ID') notnull " json:"user_id"`                                                // 用户ID
@@ -16,6 +27,8 @@ type BillingPeriodSummary struct {
 	PendingAmount         float64 `xorm:"decimal(12, 2) 'pending_amount' comment('待付金额') notnull default 0.00 " json:"pending_amount"`                      // 待付金额
 	PaidAmount            float64 `xorm:"decimal(12, 2) 'paid_amount' comment('已付金额') notnull default 0.00 " json:"paid_amount"`                            // 已付金额
 	ErrorAmount           float64 `xorm:"decimal(12, 2) 'error_amount' comment('失败金额') notnull default 0.00 " json:"error_amount"`                          // 失败金额
+	AdjustmentAmount      float64 `xorm:"decimal(12, 2) 'adjustment_amount' comment('调整金额（退款冲减为负数）') notnull default 0.00 " json:"adjustment_amount"`       // 调整金额（退款冲减为负数）
+	CreditAmount          float64 `xorm:"decimal(12, 2) 'credit_amount' comment('已通过app credit返还金额') notnull default 0.00 " json:"credit_amount"`           // 已通过app credit返还金额
 	BillCount             int32   `xorm:"int 'bill_count' comment('账单数量') notnull default 0 " json:"bill_count"`                                            // 账单数量
 	OrderCount            int32   `xorm:"int 'order_count' comment('订单数量') notnull default 0 " json:"order_count"`                                          // 订单数量
 	Currency              string  `xorm:"varchar(10) 'currency' comment('货币类型') notnull " json:"currency"`                                                  // 货币类型
@@ -36,3 +49,26 @@ type BillingPeriodSummary struct {
 func (s BillingPeriodSummary) TableName() string {
 	return BillingPeriodSummaryTableName
 }
+
+// SummaryDelta 账单确认时需要累加到账期汇总的增量
+type SummaryDelta struct {
+	OrderCount            int32
+	BillCount             int32
+	TotalCommissionAmount float64
+	PendingAmount         float64
+	TotalProtectifyAmount float64
+	TotalOrderAmount      float64
+	TotalRefundAmount     float64
+}
+
+// PeriodKey 账期汇总的唯一键 (user_id, subscription_id, bill_cycle)
+type PeriodKey struct {
+	UserId         int64
+	SubscriptionId int64
+	BillCycle      string
+}
+
+// Key 账期汇总的唯一键
+func (s BillingPeriodSummary) Key() PeriodKey {
+	return PeriodKey{UserId: s.UserId, SubscriptionId: s.SubscriptionId, BillCycle: s.BillCycle}
+}
@@ -0,0 +1,61 @@
+package billings
+
+import "fmt"
+
+const (
+	CommissionAdjustmentTableName = "commission_adjustment"
+)
+
+// 调整类型
+const (
+	AdjustmentTypeRefund = "refund" // 订单退款冲减
+)
+
+// 调整状态
+const (
+	AdjustmentStatusPending   = 0 // 待处理
+	AdjustmentStatusAbsorbed  = 1 // 已在账期内冲减
+	AdjustmentStatusCredited  = 2 // 已通过 Shopify app credit 返还
+	AdjustmentStatusFailed    = 3 // 返还失败
+	AdjustmentStatusCrediting = 4 // 已提交 Shopify 返还，等待确认结果
+)
+
+// CreditingTimeout 返还中的流水超过这个时间没有确认结果，按描述查询 Shopify 后再决定是否重新发放
+const CreditingTimeout = 10 * 60
+
+// CreditDescription 发放 app credit 的描述，带上流水ID，重试时按描述找回已经发放的 credit
+func CreditDescription(orderName string, adjustmentID int64) string {
+	return fmt.Sprintf("Protectify commission refund for order %s (ref %d)", orderName, adjustmentID)
+}
+
+// CommissionAdjustment 抽成调整流水，退款冲减等金额记为负数
+type CommissionAdjustment struct {
+	Id               int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                         // ID
+	UserId           int64   `xorm:"bigint UNSIGNED 'user_id' comment('用户ID') notnull " json:"user_id"`                                         // 用户ID
+	BillId           int64   `xorm:"bigint UNSIGNED 'bill_id' comment('关联的抽成账单ID') notnull " json:"bill_id"`                                    // 关联的抽成账单ID
+	UserOrderId      int64   `xorm:"bigint UNSIGNED 'user_order_id' comment('关联的订单ID') notnull " json:"user_order_id"`                          // 关联的订单ID
+	OrderName        string  `xorm:"varchar(50) 'order_name' comment('Shopify订单编号') notnull " json:"order_name"`                                // Shopify订单编号
+	AdjustmentType   string  `xorm:"varchar(20) 'adjustment_type' comment('调整类型：refund-退款冲减') notnull " json:"adjustment_type"`                 // 调整类型：refund-退款冲减
+	Amount           float64 `xorm:"decimal(12, 2) 'amount' comment('调整金额（负数为冲减）') notnull default 0.00 " json:"amount"`                        // 调整金额（负数为冲减）
+	AbsorbedAmount   float64 `xorm:"decimal(12, 2) 'absorbed_amount' comment('账期内冲减的金额') notnull default 0.00 " json:"absorbed_amount"`         // 账期内冲减的金额
+	CreditAmount     float64 `xorm:"decimal(12, 2) 'credit_amount' comment('需要通过app credit返还的金额') notnull default 0.00 " json:"credit_amount"`  // 需要通过app credit返还的金额
+	Currency         string  `xorm:"varchar(10) 'currency' comment('货币类型') notnull " json:"currency"`                                           // 货币类型
+	BillingPeriodEnd int64   `xorm:"bigint UNSIGNED 'billing_period_end' comment('冲减所在账单周期结束时间') notnull default 0 " json:"billing_period_end"` // 冲减所在账单周期结束时间
+	SubscriptionId   int64   `xorm:"bigint UNSIGNED 'subscription_id' comment('冲减所在账期的订阅ID') notnull default 0 " json:"subscription_id"`        // 冲减所在账期的订阅ID
+	BillCycle        string  `xorm:"varchar(20) 'bill_cycle' comment('冲减所在账期') notnull default '' " json:"bill_cycle"`                          // 冲减所在账期
+	Status           int8    `xorm:"tinyint 'status' comment('状态：0-待处理, 1-已冲减, 2-已返还, 3-返还失败, 4-返还中') notnull default 0 " json:"status"`        // 状态：0-待处理, 1-已冲减, 2-已返还, 3-返还失败, 4-返还中
+	ShopifyCreditId  string  `xorm:"varchar(100) 'shopify_credit_id' comment('Shopify app credit ID') notnull " json:"shopify_credit_id"`       // Shopify app credit ID
+	RetryCount       int     `xorm:"int 'retry_count' comment('返还重试次数') notnull default 0 " json:"retry_count"`                                 // 返还重试次数
+	ErrorMessage     string  `xorm:"text 'error_message' comment('错误信息') " json:"error_message"`                                                // 错误信息
+	CreateTime       int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"`                         // 创建时间
+	UpdateTime       int64   `xorm:"updated bigint UNSIGNED 'update_time' comment('修改时间') notnull " json:"update_time"`                         // 修改时间
+}
+
+func (c CommissionAdjustment) TableName() string {
+	return CommissionAdjustmentTableName
+}
+
+// PeriodKey 冲减所在账期汇总的唯一键
+func (c CommissionAdjustment) PeriodKey() PeriodKey {
+	return PeriodKey{UserId: c.UserId, SubscriptionId: c.SubscriptionId, BillCycle: c.BillCycle}
+}
@@ -1,5 +1,10 @@
 package billings
 
+import (
+	"strconv"
+	"strings"
+)
+
 // 扣费状态
 const (
 	ChargeStatusPending = 0 // 待提交
@@ -7,6 +12,35 @@ const (
 	ChargeStatusFailed  = 2 // 提交失败
 )
 
+// 抽成确认状态，按购物车设置的发货规则确认后才会提交扣费
+const (
+	RecognitionStatusDeferred   = 0 // 待确认
+	RecognitionStatusRecognized = 1 // 已确认
+)
+
+// MaxChargeRetry 扣费失败后最多重试次数
+const MaxChargeRetry = 10
+
+// usageIdempotencyPrefix 提交用量扣费时的幂等键前缀，对账时用它找回没有保存用量记录ID的账单
+const usageIdempotencyPrefix = "commission-bill-"
+
+// UsageIdempotencyKey 账单提交用量扣费的幂等键
+func UsageIdempotencyKey(billID int64) string {
+	return usageIdempotencyPrefix + strconv.FormatInt(billID, 10)
+}
+
+// BillIDFromIdempotencyKey 从用量记录的幂等键解析账单ID，不是账单提交的记录返回 false
+func BillIDFromIdempotencyKey(key string) (int64, bool) {
+	if !strings.HasPrefix(key, usageIdempotencyPrefix) {
+		return 0, false
+	}
+	billID, err := strconv.ParseInt(strings.TrimPrefix(key, usageIdempotencyPrefix), 10, 64)
+	if err != nil || billID <= 0 {
+		return 0, false
+	}
+	return billID, true
+}
+
 type CommissionBill struct {
 	Id                    int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                                                     // ID
 	ChargeId              int64   `xorm:"bigint UNSIGNED 'charge_id' comment('账单编号') notnull " json:"charge_id"`                                                                 // 账单编号
@@ -17,6 +51,7 @@ type CommissionBill struct {
 	BillingPeriodEnd      int64   `xorm:"bigint UNSIGNED 'billing_period_end' comment('账单周期结束时间') notnull default 0 " json:"billing_period_end"`                                 // 账单周期结束时间
 	BillCycle             string  `xorm:"varchar(20) 'bill_cycle' comment('账单周期标识（YYYY-MM-DD）') notnull " json:"bill_cycle"`                                                     // 账单周期标识（YYYY-MM-DD）
 	CommissionAmount      float64 `xorm:"decimal(12, 2) 'commission_amount' comment('抽成金额') notnull default 0.00 " json:"commission_amount"`                                     // 抽成金额
+	DeductedAmount        float64 `xorm:"decimal(12, 2) 'deducted_amount' comment('退款冲减金额') notnull default 0.00 " json:"deducted_amount"`                                       // 退款冲减金额
 	CommissionRate        float64 `xorm:"decimal(5, 2) 'commission_rate' comment('抽成比例（百分比）') notnull default 0.00 " json:"commission_rate"`                                     // 抽成比例（百分比）
 	ProtectifyType        string  `xorm:"varchar(30) 'protectify_type' comment('保险类型：general-通用保险，product-产品保险，shipping-运输保险') notnull default general " json:"protectify_type"` // 保险类型：general-通用保险，product-产品保险，shipping-运输保险
 	SubscriptionId        int64   `xorm:"bigint UNSIGNED 'subscription_id' comment('关联的订阅ID') notnull default 0 " json:"subscription_id"`                                        // 关联的订阅ID
@@ -24,9 +59,16 @@ type CommissionBill struct {
 	OrderTotalAmount      float64 `xorm:"decimal(12, 2) 'order_total_amount' comment('订单总金额') notnull default 0.00 " json:"order_total_amount"`                                  // 订单总金额
 	CommissionItems       string  `xorm:"text 'commission_items' comment('抽成明细项（JSON格式，包含保险项目等）') " json:"commission_items"`                                                     // 抽成明细项（JSON格式，包含保险项目等）
 	Currency              string  `xorm:"varchar(10) 'currency' comment('货币类型') notnull " json:"currency"`                                                                       // 货币类型
+	ChargeCurrency        string  `xorm:"varchar(10) 'charge_currency' comment('扣费币种（订阅上限的币种）') notnull " json:"charge_currency"`                                                // 扣费币种（订阅上限的币种）
+	OriginalAmount        float64 `xorm:"decimal(12, 2) 'original_amount' comment('换算前的扣费金额（账单币种）') notnull default 0.00 " json:"original_amount"`                               // 换算前的扣费金额（账单币种）
+	ConvertedAmount       float64 `xorm:"decimal(12, 2) 'converted_amount' comment('换算后的扣费金额（扣费币种）') notnull default 0.00 " json:"converted_amount"`                             // 换算后的扣费金额（扣费币种）
+	ExchangeRate          float64 `xorm:"decimal(18, 8) 'exchange_rate' comment('换算汇率（1单位账单币种兑换的扣费币种）') notnull default 0.00000000 " json:"exchange_rate"`                       // 换算汇率（1单位账单币种兑换的扣费币种）
 	ShopifyUsageRecordId  string  `xorm:"varchar(100) 'shopify_usage_record_id' comment('Shopify用量记录ID') notnull " json:"shopify_usage_record_id"`                               // Shopify用量记录ID
 	ChargeStatus          int8    `xorm:"tinyint 'charge_status' comment('扣费状态：0-待提交, 1-已提交, 2-提交失败') notnull default 0 " json:"charge_status"`                                  // 扣费状态：0-待提交, 1-已提交, 2-提交失败
 	ErrorMessage          string  `xorm:"text 'error_message' comment('错误信息') " json:"error_message"`                                                                            // 错误信息
+	RetryCount            int     `xorm:"int 'retry_count' comment('扣费重试次数') notnull default 0 " json:"retry_count"`                                                             // 扣费重试次数
+	RecognitionStatus     int8    `xorm:"tinyint 'recognition_status' comment('抽成确认状态：0-待确认, 1-已确认') notnull default 0 " json:"recognition_status"`                              // 抽成确认状态：0-待确认, 1-已确认
+	RecognizedAt          int64   `xorm:"bigint UNSIGNED 'recognized_at' comment('抽成确认时间') notnull default 0 " json:"recognized_at"`                                             // 抽成确认时间
 	ChargedAt             int64   `json:"charged_at" xorm:"notnull default 0 'charged_at' comment('扣费时间')"`
 	CreateTime            int64   `json:"create_time" xorm:"created notnull 'create_time' comment('创建时间')"`
 	UpdateTime            int64   `json:"update_time" xorm:"updated notnull 'update_time' comment('修改时间')"`
@@ -35,3 +77,18 @@ type CommissionBill struct {
 func (c CommissionBill) TableName() string {
 	return "commission_bill"
 }
+
+// ChargeAmount 实际需要提交扣费的金额
+func (c CommissionBill) ChargeAmount() float64 {
+	return c.CommissionAmount - c.DeductedAmount
+}
+
+// PeriodKey 账单确认后计入的账期汇总
+func (c CommissionBill) PeriodKey() PeriodKey {
+	return PeriodKey{UserId: c.UserId, SubscriptionId: c.SubscriptionId, BillCycle: c.BillCycle}
+}
+
+// SameConversion 账单已经按相同的金额和扣费币种换算过，重试时沿用之前的汇率，保证同一个幂等键提交的金额不变
+func (c CommissionBill) SameConversion(amount float64, chargeCurrency string) bool {
+	return c.ExchangeRate > 0 && c.ChargeCurrency == chargeCurrency && c.OriginalAmount == amount
+}
@@ -0,0 +1,40 @@
+package billings
+
+import "github.com/shopspring/decimal"
+
+// 汇率来源
+const (
+	RateSourceStatic = "static" // 配置文件中的固定汇率
+	RateSourceFile   = "file"   // 本地汇率文件
+)
+
+// ExchangeRate 汇率表，rate 表示 1 单位基准币种可以兑换多少报价币种
+type ExchangeRate struct {
+	Id            int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                 // ID
+	BaseCurrency  string  `xorm:"varchar(10) 'base_currency' comment('基准币种') notnull " json:"base_currency"`         // 基准币种
+	QuoteCurrency string  `xorm:"varchar(10) 'quote_currency' comment('报价币种') notnull " json:"quote_currency"`       // 报价币种
+	Rate          float64 `xorm:"decimal(18, 8) 'rate' comment('汇率') notnull default 0.00000000 " json:"rate"`       // 汇率
+	Source        string  `xorm:"varchar(30) 'source' comment('汇率来源') notnull " json:"source"`                       // 汇率来源
+	CreateTime    int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"` // 创建时间
+	UpdateTime    int64   `xorm:"updated bigint UNSIGNED 'update_time' comment('修改时间') notnull " json:"update_time"` // 修改时间
+}
+
+func (e ExchangeRate) TableName() string {
+	return "exchange_rate"
+}
+
+// RateTable 汇率来源返回的一组汇率，Rates 中的汇率都相对于 Base
+type RateTable struct {
+	Base   string
+	Source string
+	Rates  map[string]decimal.Decimal
+}
+
+// Conversion 一次币种换算的结果
+type Conversion struct {
+	From   string
+	To     string
+	Amount decimal.Decimal // 原始金额
+	Rate   decimal.Decimal // 1 单位 From 兑换多少 To
+	Result decimal.Decimal // 换算后的金额，保留两位小数
+}
@@ -0,0 +1,78 @@
+package billings
+
+import (
+	"time"
+
+	"backend/internal/domain/entity/users"
+)
+
+// SubscriptionCycle Shopify 用量订阅的计费周期固定为30天
+const SubscriptionCycle = 30 * 24 * time.Hour
+
+// AnnualSubscriptionCycle Shopify 年付订阅的计费周期为365天
+const AnnualSubscriptionCycle = 365 * 24 * time.Hour
+
+// Cycle 按订阅套餐的收费周期返回计费周期长度，年付套餐为365天，其它为30天
+func Cycle(subscription *users.UserSubscription) time.Duration {
+	if subscription != nil && subscription.PricingType == users.PricingTypeAnnual {
+		return AnnualSubscriptionCycle
+	}
+	return SubscriptionCycle
+}
+
+// BillingPeriod 账单周期，包含开始时间，不包含结束时间
+type BillingPeriod struct {
+	Start         int64
+	End           int64
+	BillCycle     string // 周期开始日期（YYYY-MM-DD）
+	BusinessMonth string // 周期开始月份（YYYY-MM）
+}
+
+// Contains 判断时间戳是否落在周期内
+func (p BillingPeriod) Contains(at int64) bool {
+	return at >= p.Start && at < p.End
+}
+
+// CalcBillingPeriod 计算 at 所在的账单周期。
+// 有订阅周期时以订阅的 CurrentPeriodStart/CurrentPeriodEnd 为基准按套餐的计费周期前后滚动，
+// 没有订阅周期信息时退回到 UTC 自然月。
+func CalcBillingPeriod(subscription *users.UserSubscription, at time.Time) BillingPeriod {
+	if subscription == nil || subscription.CurrentPeriodEnd <= 0 {
+		return calendarBillingPeriod(at)
+	}
+
+	cycle := int64(Cycle(subscription) / time.Second)
+	end := subscription.CurrentPeriodEnd
+	start := subscription.CurrentPeriodStart
+	// 创建订阅时记录的开始时间不可靠，超出一个周期的按结束时间倒推
+	if start <= 0 || start >= end || end-start > cycle {
+		start = end - cycle
+	}
+
+	ts := at.Unix()
+	for ts >= end {
+		start = end
+		end += cycle
+	}
+	for ts < start {
+		end = start
+		start -= cycle
+	}
+	return newBillingPeriod(start, end)
+}
+
+func calendarBillingPeriod(at time.Time) BillingPeriod {
+	at = at.UTC()
+	start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return newBillingPeriod(start.Unix(), start.AddDate(0, 1, 0).Unix())
+}
+
+func newBillingPeriod(start int64, end int64) BillingPeriod {
+	startAt := time.Unix(start, 0).UTC()
+	return BillingPeriod{
+		Start:         start,
+		End:           end,
+		BillCycle:     startAt.Format("2006-01-02"),
+		BusinessMonth: startAt.Format("2006-01"),
+	}
+}
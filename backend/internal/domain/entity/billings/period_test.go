@@ -0,0 +1,63 @@
+package billings
+
+import (
+	"testing"
+	"time"
+
+	"backend/internal/domain/entity/users"
+)
+
+func TestCalcBillingPeriod(t *testing.T) {
+	periodStart := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	periodEnd := periodStart.Add(SubscriptionCycle)
+	subscription := &users.UserSubscription{
+		CurrentPeriodStart: periodStart.Unix(),
+		CurrentPeriodEnd:   periodEnd.Unix(),
+	}
+
+	tests := []struct {
+		name         string
+		subscription *users.UserSubscription
+		at           time.Time
+		start        time.Time
+		end          time.Time
+		billCycle    string
+	}{
+		{"current period", subscription, periodStart.Add(48 * time.Hour), periodStart, periodEnd, "2025-03-10"},
+		{"period start is inclusive", subscription, periodStart, periodStart, periodEnd, "2025-03-10"},
+		{"period end rolls forward", subscription, periodEnd, periodEnd, periodEnd.Add(SubscriptionCycle), "2025-04-09"},
+		{"two periods later", subscription, periodEnd.Add(SubscriptionCycle + time.Hour), periodEnd.Add(SubscriptionCycle), periodEnd.Add(2 * SubscriptionCycle), "2025-05-09"},
+		{"previous period", subscription, periodStart.Add(-time.Hour), periodStart.Add(-SubscriptionCycle), periodStart, "2025-02-08"},
+		{
+			"unreliable start is derived from end",
+			&users.UserSubscription{CurrentPeriodStart: periodEnd.Unix(), CurrentPeriodEnd: periodEnd.Unix()},
+			periodStart.Add(time.Hour), periodStart, periodEnd, "2025-03-10",
+		},
+		{
+			"annual plan rolls by 365 days",
+			&users.UserSubscription{PricingType: users.PricingTypeAnnual, CurrentPeriodStart: periodStart.Unix(), CurrentPeriodEnd: periodStart.Add(AnnualSubscriptionCycle).Unix()},
+			periodStart.Add(200 * 24 * time.Hour), periodStart, periodStart.Add(AnnualSubscriptionCycle), "2025-03-10",
+		},
+		{
+			"calendar month without subscription", nil,
+			time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC),
+			time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), "2025-12-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period := CalcBillingPeriod(tt.subscription, tt.at)
+			if period.Start != tt.start.Unix() || period.End != tt.end.Unix() {
+				t.Fatalf("got [%s, %s), want [%s, %s)",
+					time.Unix(period.Start, 0).UTC(), time.Unix(period.End, 0).UTC(), tt.start, tt.end)
+			}
+			if period.BillCycle != tt.billCycle {
+				t.Fatalf("bill cycle got %s, want %s", period.BillCycle, tt.billCycle)
+			}
+			if !period.Contains(tt.at.Unix()) {
+				t.Fatalf("period should contain %s", tt.at)
+			}
+		})
+	}
+}
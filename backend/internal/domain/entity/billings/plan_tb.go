@@ -0,0 +1,122 @@
+package billings
+
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
+// 套餐功能，中间件按功能拦截商家套餐不包含的接口
+const (
+	FeatureStatementExport = "statement_export" // 账单导出
+	FeatureOrderInsights   = "order_insights"   // 订单统计看板
+)
+
+// 套餐状态
+const (
+	PlanStatusDisabled = 0
+	PlanStatusEnabled  = 1
+)
+
+// 套餐收费周期，和 Shopify AppPricingInterval 一致
+const (
+	PlanIntervalEvery30Days = "EVERY_30_DAYS"
+	PlanIntervalAnnual      = "ANNUAL"
+)
+
+// AppPlan 套餐目录，订阅时按套餐生成 Shopify 订阅项目
+type AppPlan struct {
+	Id              int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                                   // ID
+	Code            string  `xorm:"varchar(50) 'code' comment('套餐标识') notnull unique " json:"code"`                                                      // 套餐标识
+	Name            string  `xorm:"varchar(100) 'name' comment('套餐名称（Shopify订阅名称）') notnull " json:"name"`                                               // 套餐名称（Shopify订阅名称）
+	Level           int     `xorm:"int 'level' comment('套餐等级，数值越大套餐越高') notnull default 0 " json:"level"`                                                // 套餐等级，数值越大套餐越高
+	Price           float64 `xorm:"decimal(12, 2) 'price' comment('周期固定费用，0 表示没有固定费用') notnull default 0.00 " json:"price"`                              // 周期固定费用
+	Interval        string  `xorm:"varchar(20) 'billing_interval' comment('收费周期：EVERY_30_DAYS, ANNUAL') notnull default EVERY_30_DAYS " json:"interval"` // 收费周期
+	CappedAmount    float64 `xorm:"decimal(12, 2) 'capped_amount' comment('用量上限，0 表示不收抽成') notnull default 0.00 " json:"capped_amount"`                  // 用量上限
+	Currency        string  `xorm:"varchar(10) 'currency' comment('货币类型') notnull default USD " json:"currency"`                                         // 货币类型
+	Terms           string  `xorm:"varchar(255) 'terms' comment('用量计费条款') notnull " json:"terms"`                                                        // 用量计费条款
+	TrialDays       int     `xorm:"int 'trial_days' comment('试用天数') notnull default 0 " json:"trial_days"`                                               // 试用天数
+	CommissionRates string  `xorm:"text 'commission_rates' comment('抽成费率表（JSON）') " json:"-"`                                                            // 抽成费率表（JSON）
+	Features        string  `xorm:"text 'features' comment('包含的功能（JSON数组）') " json:"-"`                                                                  // 包含的功能（JSON数组）
+	IsDefault       int8    `xorm:"tinyint 'is_default' comment('是否为没有订阅时的默认套餐：0-否，1-是') notnull default 0 " json:"is_default"`                          // 是否默认套餐
+	Status          int8    `xorm:"tinyint 'status' comment('状态：0-停用，1-启用') notnull default 1 " json:"status"`                                           // 状态：0-停用，1-启用
+	CreateTime      int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"`                                   // 创建时间
+	UpdateTime      int64   `xorm:"updated bigint UNSIGNED 'update_time' comment('修改时间') notnull " json:"update_time"`                                   // 修改时间
+}
+
+func (p AppPlan) TableName() string {
+	return "app_plan"
+}
+
+// CommissionRate 按订单保险金额分档的抽成费率，Max 为 0 表示不设上限
+type CommissionRate struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Rate float64 `json:"rate"` // 百分比
+}
+
+// RateCard 解析抽成费率表，没有配置时返回空
+func (p *AppPlan) RateCard() ([]CommissionRate, error) {
+	var rates []CommissionRate
+	if p.CommissionRates == "" {
+		return rates, nil
+	}
+	err := json.Unmarshal([]byte(p.CommissionRates), &rates)
+	return rates, err
+}
+
+// CommissionRate 按订单保险金额匹配抽成费率（小数），没有匹配的档位时返回 false
+func (p *AppPlan) CommissionRate(protectifyAmount float64) (decimal.Decimal, bool) {
+	rates, err := p.RateCard()
+	if err != nil {
+		return decimal.Zero, false
+	}
+	for _, rate := range rates {
+		if protectifyAmount >= rate.Min && (rate.Max == 0 || protectifyAmount <= rate.Max) {
+			return decimal.NewFromFloat(rate.Rate).Div(decimal.NewFromInt(100)), true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// FeatureList 解析套餐包含的功能
+func (p *AppPlan) FeatureList() []string {
+	features := make([]string, 0)
+	if p.Features != "" {
+		_ = json.Unmarshal([]byte(p.Features), &features)
+	}
+	return features
+}
+
+// HasFeature 套餐是否包含功能
+func (p *AppPlan) HasFeature(feature string) bool {
+	for _, f := range p.FeatureList() {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFree 没有固定费用也不收抽成的套餐不需要 Shopify 订阅
+func (p *AppPlan) IsFree() bool {
+	return p.Price <= 0 && p.CappedAmount <= 0
+}
+
+// PlanItem 套餐目录返回给前端的数据
+type PlanItem struct {
+	*AppPlan
+	CommissionRates []CommissionRate `json:"commission_rates"`
+	Features        []string         `json:"features"`
+	Current         bool             `json:"current"`
+}
+
+type PlanListResponse struct {
+	List        []*PlanItem `json:"list"`
+	CurrentPlan string      `json:"current_plan"`
+}
+
+// PlanChangeReq 切换套餐
+type PlanChangeReq struct {
+	PlanCode string `json:"plan_code" binding:"required,max=50"`
+}
@@ -0,0 +1,36 @@
+package billings
+
+import "testing"
+
+func TestAppPlanCommissionRate(t *testing.T) {
+	plan := &AppPlan{CommissionRates: `[{"min":0,"max":50,"rate":5},{"min":50,"max":0,"rate":3}]`}
+
+	cases := []struct {
+		amount float64
+		want   string
+	}{
+		{10, "0.05"},
+		{50, "0.05"},
+		{80, "0.03"},
+	}
+	for _, c := range cases {
+		rate, ok := plan.CommissionRate(c.amount)
+		if !ok || rate.String() != c.want {
+			t.Fatalf("CommissionRate(%v) = %v, %v; want %s", c.amount, rate, ok, c.want)
+		}
+	}
+
+	if _, ok := (&AppPlan{}).CommissionRate(10); ok {
+		t.Fatalf("plan without rate card should not match")
+	}
+}
+
+func TestAppPlanHasFeature(t *testing.T) {
+	plan := &AppPlan{Features: `["statement_export"]`}
+	if !plan.HasFeature(FeatureStatementExport) {
+		t.Fatalf("expected statement_export")
+	}
+	if plan.HasFeature(FeatureOrderInsights) {
+		t.Fatalf("unexpected order_insights")
+	}
+}
@@ -0,0 +1,49 @@
+package billings
+
+import "backend/internal/domain/entity"
+
+// 对账差异类型
+const (
+	ReconcileIssueMissingLocal   = "missing_local"   // Shopify 有用量记录，本地没有对应的已扣费账单
+	ReconcileIssueMissingRemote  = "missing_remote"  // 本地账单已扣费，Shopify 没有对应的用量记录
+	ReconcileIssueAmountMismatch = "amount_mismatch" // 金额或币种不一致
+)
+
+// BillingReconciliation 抽成账单和 Shopify 用量记录的对账差异，每次对账覆盖用户上一次的结果
+type BillingReconciliation struct {
+	Id                   int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                                  // ID
+	UserId               int64   `xorm:"bigint UNSIGNED 'user_id' comment('用户ID') notnull " json:"user_id"`                                                  // 用户ID
+	SubscriptionId       int64   `xorm:"bigint UNSIGNED 'subscription_id' comment('对账的订阅ID') notnull default 0 " json:"subscription_id"`                     // 对账的订阅ID
+	BillId               int64   `xorm:"bigint UNSIGNED 'bill_id' comment('抽成账单ID') notnull default 0 " json:"bill_id"`                                      // 抽成账单ID
+	ShopifyUsageRecordId string  `xorm:"varchar(100) 'shopify_usage_record_id' comment('Shopify用量记录ID') notnull " json:"shopify_usage_record_id"`            // Shopify用量记录ID
+	IssueType            string  `xorm:"varchar(30) 'issue_type' comment('差异类型：missing_local, missing_remote, amount_mismatch') notnull " json:"issue_type"` // 差异类型
+	LocalAmount          float64 `xorm:"decimal(12, 2) 'local_amount' comment('本地扣费金额') notnull default 0.00 " json:"local_amount"`                          // 本地扣费金额
+	LocalCurrency        string  `xorm:"varchar(10) 'local_currency' comment('本地扣费币种') notnull " json:"local_currency"`                                      // 本地扣费币种
+	RemoteAmount         float64 `xorm:"decimal(12, 2) 'remote_amount' comment('Shopify用量记录金额') notnull default 0.00 " json:"remote_amount"`                 // Shopify用量记录金额
+	RemoteCurrency       string  `xorm:"varchar(10) 'remote_currency' comment('Shopify用量记录币种') notnull " json:"remote_currency"`                             // Shopify用量记录币种
+	Detail               string  `xorm:"varchar(500) 'detail' comment('差异说明') notnull " json:"detail"`                                                       // 差异说明
+	ReconciledAt         int64   `xorm:"bigint UNSIGNED 'reconciled_at' comment('对账时间') notnull default 0 " json:"reconciled_at"`                            // 对账时间
+	CreateTime           int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"`                                  // 创建时间
+	UpdateTime           int64   `xorm:"updated bigint UNSIGNED 'update_time' comment('修改时间') notnull " json:"update_time"`                                  // 修改时间
+}
+
+func (b BillingReconciliation) TableName() string {
+	return "billing_reconciliation"
+}
+
+// ReconciliationListReq 对账差异查询条件
+type ReconciliationListReq struct {
+	entity.Pagination
+	UserID    int64  `json:"user_id"`
+	IssueType string `json:"issue_type" binding:"omitempty,oneof=missing_local missing_remote amount_mismatch"`
+}
+
+type ReconciliationListResponse struct {
+	List  []*BillingReconciliation `json:"list"`
+	Total int64                    `json:"total"`
+}
+
+// ReconcileRunReq 手动触发对账，user_id 为 0 时对所有活跃订阅对账
+type ReconcileRunReq struct {
+	UserID int64 `json:"user_id"`
+}
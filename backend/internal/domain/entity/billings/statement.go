@@ -0,0 +1,31 @@
+package billings
+
+// 账单导出格式
+const (
+	StatementFormatCSV = "csv"
+	StatementFormatPDF = "pdf"
+)
+
+// StatementReq 导出账期账单
+type StatementReq struct {
+	PeriodID int64  `json:"period_id" binding:"required,gt=0"`
+	Format   string `json:"format" binding:"required,oneof=csv pdf"`
+}
+
+// StatementResponse 账单文件的签名下载链接
+type StatementResponse struct {
+	Url      string `json:"url"`
+	Format   string `json:"format"`
+	FileName string `json:"file_name"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+// Statement 生成账单文件需要的数据
+type Statement struct {
+	ShopName    string
+	ShopDomain  string
+	Summary     *BillingPeriodSummary
+	Bills       []*CommissionBill
+	Adjustments []*CommissionAdjustment
+	GeneratedAt int64
+}
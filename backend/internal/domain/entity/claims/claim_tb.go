@@ -0,0 +1,236 @@
+package claims
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity"
+	"backend/internal/domain/entity/orders"
+)
+
+// 理赔原因
+const (
+	ReasonLost    = "lost"    // 包裹丢失
+	ReasonStolen  = "stolen"  // 包裹被盗
+	ReasonDamaged = "damaged" // 包裹损坏
+)
+
+// 理赔状态：submitted → reviewing → approved/denied，approved → resolved
+const (
+	StatusSubmitted = "submitted" // 已提交
+	StatusReviewing = "reviewing" // 审核中
+	StatusApproved  = "approved"  // 已通过
+	StatusDenied    = "denied"    // 已拒绝
+	StatusResolved  = "resolved"  // 已处理
+)
+
+// 理赔处理方式，审核通过时由商家选择
+const (
+	ResolutionRefund      = "refund"       // 退款
+	ResolutionStoreCredit = "store_credit" // 礼品卡
+	ResolutionReship      = "reship"       // 补发
+)
+
+// 自动处理状态，审核通过后由队列调用 Shopify 完成退款、礼品卡或补发
+const (
+	ResolutionStatusPending   = "pending"   // 等待处理
+	ResolutionStatusSucceeded = "succeeded" // 处理成功
+	ResolutionStatusFailed    = "failed"    // 重试后仍然失败，可以重新发起或手动处理
+)
+
+// 理赔来源
+const (
+	SourceMerchant = "merchant" // 商家后台代客户提交
+	SourcePortal   = "portal"   // 客户在店铺理赔页面提交
+)
+
+var (
+	ErrClaimNotFound     = errors.New("claim not found")
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrOrderNotClaimable = errors.New("order does not contain package protection")
+	ErrClaimStatus       = errors.New("claim status does not allow this action")
+	ErrClaimExists       = errors.New("order already has an open claim")
+	ErrNoClaimableItems  = errors.New("no claimable items in order")
+	ErrResolutionType    = errors.New("resolution type is required to approve a claim")
+)
+
+// transitions 每个状态允许流转到的状态
+var transitions = map[string][]string{
+	StatusSubmitted: {StatusReviewing},
+	StatusReviewing: {StatusApproved, StatusDenied},
+	StatusApproved:  {StatusResolved},
+}
+
+// OrderClaim 保险理赔，只有包含保险商品的订单可以理赔
+type OrderClaim struct {
+	Id                   int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                              // ID
+	UserId               int64   `xorm:"bigint UNSIGNED 'user_id' comment('用户ID') notnull " json:"user_id"`                                              // 用户ID
+	UserOrderId          int64   `xorm:"bigint UNSIGNED 'user_order_id' comment('订单主表ID') notnull " json:"user_order_id"`                                // 订单主表ID
+	OrderId              int64   `xorm:"bigint UNSIGNED 'order_id' comment('Shopify订单ID') notnull default 0 " json:"order_id"`                           // Shopify订单ID
+	OrderName            string  `xorm:"varchar(50) 'order_name' comment('订单编号（#xxx）') notnull " json:"order_name"`                                      // 订单编号
+	Reason               string  `xorm:"varchar(20) 'reason' comment('理赔原因：lost, stolen, damaged') notnull " json:"reason"`                              // 理赔原因
+	Description          string  `xorm:"varchar(1000) 'description' comment('问题描述') notnull " json:"description"`                                        // 问题描述
+	Status               string  `xorm:"varchar(20) 'status' comment('状态：submitted, reviewing, approved, denied, resolved') notnull " json:"status"`     // 状态
+	ResolutionType       string  `xorm:"varchar(20) 'resolution_type' comment('处理方式：refund, store_credit, reship') notnull " json:"resolution_type"`     // 处理方式
+	ClaimAmount          float64 `xorm:"decimal(12, 2) 'claim_amount' comment('理赔商品金额') notnull default 0.00 " json:"claim_amount"`                      // 理赔商品金额
+	Currency             string  `xorm:"varchar(10) 'currency' comment('货币类型') notnull " json:"currency"`                                                // 货币类型
+	Source               string  `xorm:"varchar(20) 'source' comment('提交来源') notnull " json:"source"`                                                    // 提交来源
+	ReviewNote           string  `xorm:"varchar(500) 'review_note' comment('审核备注，拒绝时为拒绝原因') notnull " json:"review_note"`                                // 审核备注
+	ResolutionNote       string  `xorm:"varchar(500) 'resolution_note' comment('处理备注') notnull " json:"resolution_note"`                                 // 处理备注
+	ResolutionStatus     string  `xorm:"varchar(20) 'resolution_status' comment('自动处理状态：pending, succeeded, failed') notnull " json:"resolution_status"` // 自动处理状态
+	ResolutionError      string  `xorm:"varchar(500) 'resolution_error' comment('自动处理失败原因') notnull " json:"resolution_error"`                           // 自动处理失败原因
+	ShopifyRefundId      string  `xorm:"varchar(100) 'shopify_refund_id' comment('Shopify退款ID') notnull " json:"shopify_refund_id"`                      // Shopify退款ID
+	ShopifyGiftCardId    string  `xorm:"varchar(100) 'shopify_gift_card_id' comment('Shopify礼品卡ID') notnull " json:"shopify_gift_card_id"`               // Shopify礼品卡ID
+	GiftCardCode         string  `xorm:"varchar(20) 'gift_card_code' comment('礼品卡代码，创建前保存') notnull " json:"-"`                                          // 礼品卡代码
+	ShopifyDraftOrderId  string  `xorm:"varchar(100) 'shopify_draft_order_id' comment('补发草稿订单ID') notnull " json:"shopify_draft_order_id"`               // 补发草稿订单ID
+	ShopifyReshipOrderId string  `xorm:"varchar(100) 'shopify_reship_order_id' comment('补发订单ID') notnull " json:"shopify_reship_order_id"`               // 补发订单ID
+	ReviewedAt           int64   `xorm:"bigint UNSIGNED 'reviewed_at' comment('审核完成时间') notnull default 0 " json:"reviewed_at"`                          // 审核完成时间
+	ResolvedAt           int64   `xorm:"bigint UNSIGNED 'resolved_at' comment('处理完成时间') notnull default 0 " json:"resolved_at"`                          // 处理完成时间
+	CreateTime           int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"`                              // 创建时间
+	UpdateTime           int64   `xorm:"updated bigint UNSIGNED 'update_time' comment('修改时间') notnull " json:"update_time"`                              // 修改时间
+}
+
+func (c OrderClaim) TableName() string {
+	return "order_claim"
+}
+
+// ShopifyNote 写在 Shopify 退款和礼品卡上的备注，重试时用来查找已经创建的记录
+func (c *OrderClaim) ShopifyNote() string {
+	return fmt.Sprintf("Protectify claim #%d", c.Id)
+}
+
+// NewGiftCardCode 生成礼品卡代码，创建礼品卡前保存在理赔上，重试时 Shopify 会拒绝重复的代码
+func NewGiftCardCode() (string, error) {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+// GiftCardLastCharacters 礼品卡代码的后 4 位，Shopify 只返回礼品卡代码的后几位
+func (c *OrderClaim) GiftCardLastCharacters() string {
+	if len(c.GiftCardCode) < 4 {
+		return c.GiftCardCode
+	}
+	return c.GiftCardCode[len(c.GiftCardCode)-4:]
+}
+
+// CreditAmount 按订单商品折扣后的单价计算理赔商品的礼品卡金额，prices 是变体ID到折扣后单价的映射
+func CreditAmount(items []*OrderClaimItem, prices map[int64]decimal.Decimal) (decimal.Decimal, error) {
+	amount := decimal.Zero
+	for _, item := range items {
+		price, ok := prices[item.VariantId]
+		if !ok {
+			return decimal.Zero, fmt.Errorf("订单中没有找到理赔商品: %s", item.Sku)
+		}
+		amount = amount.Add(price.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+	return amount.Round(2), nil
+}
+
+// ShopifyTag 补发草稿订单的标签，重试时用来查找已经创建的草稿订单
+func (c *OrderClaim) ShopifyTag() string {
+	return fmt.Sprintf("protectify-claim-%d", c.Id)
+}
+
+// CanTransit 当前状态能否流转到 to
+func (c *OrderClaim) CanTransit(to string) bool {
+	for _, status := range transitions[c.Status] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderClaimItem 理赔的订单商品
+type OrderClaimItem struct {
+	Id              int64   `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                                 // ID
+	ClaimId         int64   `xorm:"bigint UNSIGNED 'claim_id' comment('理赔ID') notnull " json:"claim_id"`                               // 理赔ID
+	UserOrderInfoId int64   `xorm:"bigint UNSIGNED 'user_order_info_id' comment('订单详情ID') notnull " json:"user_order_info_id"`         // 订单详情ID
+	VariantId       int64   `xorm:"bigint UNSIGNED 'variant_id' comment('变体ID') notnull default 0 " json:"variant_id"`                 // 变体ID
+	Sku             string  `xorm:"varchar(100) 'sku' comment('SKU') notnull " json:"sku"`                                             // SKU
+	VariantTitle    string  `xorm:"varchar(255) 'variant_title' comment('变体标题') notnull " json:"variant_title"`                        // 变体标题
+	Quantity        int     `xorm:"int 'quantity' comment('理赔数量') notnull default 0 " json:"quantity"`                                 // 理赔数量
+	UnitPriceAmount float64 `xorm:"decimal(12, 2) 'unit_price_amount' comment('单价金额') notnull default 0.00 " json:"unit_price_amount"` // 单价金额
+	CreateTime      int64   `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"`                 // 创建时间
+}
+
+func (c OrderClaimItem) TableName() string {
+	return "order_claim_item"
+}
+
+// OrderClaimEvidence 理赔凭证，保存上传后的文件地址
+type OrderClaimEvidence struct {
+	Id         int64  `xorm:"bigint UNSIGNED 'id' comment('ID') pk autoincr notnull " json:"id"`                 // ID
+	ClaimId    int64  `xorm:"bigint UNSIGNED 'claim_id' comment('理赔ID') notnull " json:"claim_id"`               // 理赔ID
+	Url        string `xorm:"varchar(500) 'url' comment('文件地址') notnull " json:"url"`                            // 文件地址
+	CreateTime int64  `xorm:"created bigint UNSIGNED 'create_time' comment('创建时间') notnull " json:"create_time"` // 创建时间
+}
+
+func (c OrderClaimEvidence) TableName() string {
+	return "order_claim_evidence"
+}
+
+// ClaimItemReq 理赔商品，数量不能超过未退款数量
+type ClaimItemReq struct {
+	OrderInfoId int64 `json:"order_info_id" binding:"required,min=1"`
+	Quantity    int   `json:"quantity" binding:"required,min=1"`
+}
+
+// ClaimSubmitReq 提交理赔，不传商品时理赔订单内全部未退款商品
+type ClaimSubmitReq struct {
+	UserOrderId int64          `json:"user_order_id" binding:"required,min=1"`
+	Reason      string         `json:"reason" binding:"required,oneof=lost stolen damaged"`
+	Description string         `json:"description" binding:"max=1000"`
+	Items       []ClaimItemReq `json:"items" binding:"dive"`
+	Evidence    []string       `json:"evidence" binding:"max=10,dive,url,max=500"`
+}
+
+// ClaimListReq 理赔列表查询条件
+type ClaimListReq struct {
+	entity.Pagination
+	UserID int64  `json:"user_id,omitempty"`
+	Status string `json:"status" binding:"omitempty,oneof=submitted reviewing approved denied resolved"`
+	Query  string `json:"query"` // 订单编号
+}
+
+type ClaimListResponse struct {
+	List  []*OrderClaim `json:"list"`
+	Total int64         `json:"total"`
+}
+
+// ClaimDetail 理赔详情
+type ClaimDetail struct {
+	Claim    *OrderClaim           `json:"claim"`
+	Order    *orders.UserOrder     `json:"order"`
+	Items    []*OrderClaimItem     `json:"items"`
+	Evidence []*OrderClaimEvidence `json:"evidence"`
+}
+
+// ClaimReviewReq 审核理赔：start 开始审核，approve 通过时必须选择处理方式，deny 拒绝
+type ClaimReviewReq struct {
+	ClaimId        int64  `json:"claim_id" binding:"required,min=1"`
+	Action         string `json:"action" binding:"required,oneof=start approve deny"`
+	ResolutionType string `json:"resolution_type" binding:"omitempty,oneof=refund store_credit reship"`
+	Note           string `json:"note" binding:"max=500"`
+}
+
+// ClaimRetryReq 重新发起 Shopify 处理
+type ClaimRetryReq struct {
+	ClaimId int64 `json:"claim_id" binding:"required,min=1"`
+}
+
+// ClaimResolveReq 标记理赔已处理
+type ClaimResolveReq struct {
+	ClaimId int64  `json:"claim_id" binding:"required,min=1"`
+	Note    string `json:"note" binding:"max=500"`
+}
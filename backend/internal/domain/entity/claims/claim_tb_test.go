@@ -0,0 +1,62 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderClaimCanTransit(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusSubmitted, StatusReviewing, true},
+		{StatusSubmitted, StatusApproved, false},
+		{StatusReviewing, StatusApproved, true},
+		{StatusReviewing, StatusDenied, true},
+		{StatusApproved, StatusResolved, true},
+		{StatusDenied, StatusResolved, false},
+		{StatusResolved, StatusReviewing, false},
+	}
+	for _, c := range cases {
+		claim := &OrderClaim{Status: c.from}
+		if got := claim.CanTransit(c.to); got != c.want {
+			t.Errorf("CanTransit(%s -> %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestCreditAmount(t *testing.T) {
+	items := []*OrderClaimItem{
+		{VariantId: 1, Sku: "A", Quantity: 2},
+		{VariantId: 2, Sku: "B", Quantity: 1},
+	}
+	// 原价 30.00 的商品折扣后 24.99
+	prices := map[int64]decimal.Decimal{1: decimal.RequireFromString("24.99"), 2: decimal.RequireFromString("5.005")}
+	amount, err := CreditAmount(items, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount.StringFixed(2) != "54.99" {
+		t.Errorf("amount = %s, want 54.99", amount.StringFixed(2))
+	}
+	if _, err := CreditAmount(append(items, &OrderClaimItem{VariantId: 3, Sku: "C", Quantity: 1}), prices); err == nil {
+		t.Error("missing line item should fail")
+	}
+}
+
+func TestNewGiftCardCode(t *testing.T) {
+	code, err := NewGiftCardCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := NewGiftCardCode()
+	if len(code) != 16 || code == again {
+		t.Errorf("codes = %s, %s", code, again)
+	}
+	claim := &OrderClaim{GiftCardCode: code}
+	if claim.GiftCardLastCharacters() != code[12:] {
+		t.Errorf("last characters = %s", claim.GiftCardLastCharacters())
+	}
+}
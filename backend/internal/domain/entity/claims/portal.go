@@ -0,0 +1,147 @@
+package claims
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid claim token")
+	ErrShopNotFound    = errors.New("shop not found")
+	ErrInvalidEvidence = errors.New("invalid evidence image")
+)
+
+// EvidenceImageTypes 允许上传的理赔图片类型和保存的扩展名
+var EvidenceImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/heic": ".heic",
+}
+
+// heicBrands HEIC 文件 ftyp 盒子中的品牌，http.DetectContentType 不识别 HEIC
+var heicBrands = []string{"heic", "heix", "heim", "heis", "mif1", "msf1"}
+
+// DetectImageType 按文件开头的内容判断图片类型，不信任客户端的 Content-Type，不是允许的图片时返回 false
+func DetectImageType(head []byte) (string, bool) {
+	contentType := http.DetectContentType(head)
+	if _, ok := EvidenceImageTypes[contentType]; ok {
+		return contentType, true
+	}
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		for _, brand := range heicBrands {
+			if string(head[8:12]) == brand {
+				return "image/heic", true
+			}
+		}
+	}
+	return "", false
+}
+
+// Token 客户查询理赔进度的凭证，格式为 <理赔ID>.<签名>，签名绑定理赔所属店铺
+func (c *OrderClaim) Token(secret string) string {
+	return fmt.Sprintf("%d.%s", c.Id, c.tokenSignature(secret))
+}
+
+// VerifyToken 校验查询凭证是否由当前理赔签发，Secret 轮换期间旧 Secret 签发的凭证仍然有效
+func (c *OrderClaim) VerifyToken(secrets []string, token string) bool {
+	_, signature, ok := strings.Cut(token, ".")
+	if !ok || signature == "" {
+		return false
+	}
+	matched := false
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		if hmac.Equal([]byte(signature), []byte(c.tokenSignature(secret))) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+func (c *OrderClaim) tokenSignature(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("claim:%d:%d:%d", c.Id, c.UserId, c.CreateTime)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TokenClaimID 从查询凭证中取出理赔ID，凭证是否有效需要再调用 VerifyToken
+func TokenClaimID(token string) (int64, error) {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	claimID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || claimID <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return claimID, nil
+}
+
+// PortalOrderReq 客户通过订单编号和下单邮箱查询订单
+type PortalOrderReq struct {
+	Shop      string `json:"shop" binding:"required,max=100"`
+	OrderName string `json:"order_name" binding:"required,max=50"`
+	Email     string `json:"email" binding:"required,email,max=255"`
+}
+
+// PortalSubmitReq 客户提交理赔，凭证只能使用理赔页面上传的图片
+type PortalSubmitReq struct {
+	PortalOrderReq
+	Reason      string         `json:"reason" binding:"required,oneof=lost stolen damaged"`
+	Description string         `json:"description" binding:"max=1000"`
+	Items       []ClaimItemReq `json:"items" binding:"dive"`
+	Evidence    []string       `json:"evidence" binding:"max=10,dive,url,max=500"`
+}
+
+// PortalStatusReq 客户查询理赔进度
+type PortalStatusReq struct {
+	Shop  string `json:"shop" binding:"required,max=100"`
+	Token string `json:"token" binding:"required,max=100"`
+}
+
+// PortalOrder 客户可以理赔的订单商品
+type PortalOrder struct {
+	OrderName string        `json:"order_name"`
+	Currency  string        `json:"currency"`
+	Items     []*PortalItem `json:"items"`
+	OpenClaim bool          `json:"open_claim"` // 已有处理中的理赔
+}
+
+type PortalItem struct {
+	OrderInfoId     int64   `json:"order_info_id"`
+	Sku             string  `json:"sku"`
+	VariantTitle    string  `json:"variant_title"`
+	Quantity        int     `json:"quantity"` // 可理赔数量
+	UnitPriceAmount float64 `json:"unit_price_amount"`
+}
+
+// PortalSubmitResp 提交成功后返回查询凭证
+type PortalSubmitResp struct {
+	ClaimId int64  `json:"claim_id"`
+	Status  string `json:"status"`
+	Token   string `json:"token"`
+}
+
+// PortalStatus 客户可以看到的理赔进度
+type PortalStatus struct {
+	OrderName      string  `json:"order_name"`
+	Reason         string  `json:"reason"`
+	Status         string  `json:"status"`
+	ResolutionType string  `json:"resolution_type"`
+	ClaimAmount    float64 `json:"claim_amount"`
+	Currency       string  `json:"currency"`
+	ReviewNote     string  `json:"review_note"`
+	CreateTime     int64   `json:"create_time"`
+	ReviewedAt     int64   `json:"reviewed_at"`
+	ResolvedAt     int64   `json:"resolved_at"`
+}
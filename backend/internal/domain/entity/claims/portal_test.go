@@ -0,0 +1,53 @@
+package claims
+
+import "testing"
+
+func TestOrderClaimToken(t *testing.T) {
+	claim := &OrderClaim{Id: 42, UserId: 7, CreateTime: 1700000000}
+	token := claim.Token("secret")
+
+	id, err := TokenClaimID(token)
+	if err != nil || id != 42 {
+		t.Fatalf("TokenClaimID(%s) = %d, %v", token, id, err)
+	}
+	if !claim.VerifyToken([]string{"secret"}, token) {
+		t.Fatalf("token should verify")
+	}
+	if claim.VerifyToken([]string{"other"}, token) {
+		t.Fatalf("token signed with another secret should not verify")
+	}
+	// 轮换后旧 Secret 签发的凭证仍然有效
+	if !claim.VerifyToken([]string{"new", "secret"}, token) {
+		t.Fatalf("token signed with previous secret should verify")
+	}
+	if claim.VerifyToken(nil, token) {
+		t.Fatalf("token should not verify without secrets")
+	}
+	other := &OrderClaim{Id: 42, UserId: 8, CreateTime: 1700000000}
+	if other.VerifyToken([]string{"secret"}, token) {
+		t.Fatalf("token of another shop should not verify")
+	}
+	if _, err := TokenClaimID("abc"); err == nil {
+		t.Fatalf("malformed token should fail")
+	}
+}
+
+func TestDetectImageType(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg"},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), "image/heic"},
+		{"html", []byte("<html><script>alert(1)</script></html>"), ""},
+		{"mp4", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00"), ""},
+	}
+	for _, c := range cases {
+		got, ok := DetectImageType(c.head)
+		if got != c.want || ok != (c.want != "") {
+			t.Errorf("%s: DetectImageType = %q, %v; want %q", c.name, got, ok, c.want)
+		}
+	}
+}
@@ -14,15 +14,20 @@ type OrderPayload struct {
 	JobId int64 `json:"job_id"`
 }
 
+type OrderBackfillPayload struct {
+	JobId int64 `json:"job_id"`
+}
+
 type ShopifyProductPayload struct {
 	UserID        int64 `json:"user_id"`
 	UserProductId int64 `json:"user_product_id"`
 }
 
+// OrderStatisticPayload 按每个店铺的时区统计 At 所在的一天及之前共 Days 天，UserID 为分批处理的游标
 type OrderStatisticPayload struct {
 	UserID int64 `json:"user_id"`
-	Start  int64 `json:"start"`
-	End    int64 `json:"end"`
+	At     int64 `json:"at"`   // 为 0 时使用任务执行时间
+	Days   int   `json:"days"` // 小于 1 时只统计一天
 }
 
 type DelProductPayload struct {
@@ -30,3 +35,60 @@ type DelProductPayload struct {
 	ProductId int64 `json:"product_id"`
 	DelType   int   `json:"del_type"`
 }
+
+type CommissionSettlePayload struct {
+	BillID int64 `json:"bill_id"`
+}
+
+type CommissionRetryPayload struct {
+	LastID int64 `json:"last_id"`
+}
+
+type CommissionCreditPayload struct {
+	AdjustmentID int64 `json:"adjustment_id"`
+}
+
+type CappedAmountPayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// BillingReconcilePayload UserID 为 0 时对所有活跃订阅对账
+type BillingReconcilePayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// ClaimResolvePayload 审核通过的理赔，按处理方式调用 Shopify 退款、发礼品卡或补发
+type ClaimResolvePayload struct {
+	ClaimId int64 `json:"claim_id"`
+}
+
+// SummaryMigratePayload 按店铺时区重新计算已有的每日统计，UserID 为 0 时从 Cursor 开始分批处理所有用户
+type SummaryMigratePayload struct {
+	UserID int64 `json:"user_id"`
+	Cursor int64 `json:"cursor"`
+}
+
+// VariantSyncPayload 价格设置变更后按变体阶梯同步保险商品的变体
+type VariantSyncPayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// SubscriptionSyncPayload 从 Shopify 同步订阅状态，UserID 为 0 时同步所有活跃订阅
+type SubscriptionSyncPayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// ProductDriftPayload 检查保险商品变体和 Shopify、变体阶梯是否一致，UserID 为 0 时检查所有已上传的保险商品
+type ProductDriftPayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// WebhookPayload 处理 webhook 收件箱中的一条记录
+type WebhookPayload struct {
+	InboxId int64 `json:"inbox_id"`
+}
+
+// WebhookReconcilePayload 核对 webhook 订阅，UserID 为 0 时核对所有店铺
+type WebhookReconcilePayload struct {
+	UserID int64 `json:"user_id"`
+}
@@ -0,0 +1,45 @@
+package jobs
+
+// 历史订单回填状态
+const (
+	BackfillStatusPending   = 0 // 待提交批量查询
+	BackfillStatusRunning   = 1 // Shopify 批量查询中
+	BackfillStatusImporting = 2 // 导入订单中
+	BackfillStatusCompleted = 3 // 完成
+	BackfillStatusFailed    = 4 // 失败
+)
+
+// JobOrderBackfill 历史订单回填任务，通过 Shopify 批量查询导入安装前的订单
+type JobOrderBackfill struct {
+	Id              int64  `xorm:"pk autoincr 'id' bigint(20) comment('ID')" json:"id"`
+	UserID          int64  `xorm:"'user_id' bigint(20) notnull default 0 comment('店铺')" json:"user_id"`
+	CreatedFrom     int64  `xorm:"'created_from' bigint(20) notnull default 0 comment('订单创建时间起（含）')" json:"created_from"`
+	CreatedTo       int64  `xorm:"'created_to' bigint(20) notnull default 0 comment('订单创建时间止（不含）')" json:"created_to"`
+	Status          int    `xorm:"'status' tinyint(1) notnull default 0 comment('状态 0 待提交 1 批量查询中 2 导入中 3 完成 4 失败')" json:"status"`
+	BulkOperationId string `xorm:"'bulk_operation_id' varchar(100) notnull default '' comment('Shopify批量操作ID')" json:"-"`
+	ResultUrl       string `xorm:"'result_url' text comment('批量查询结果下载链接')" json:"-"`
+	ObjectCount     int64  `xorm:"'object_count' bigint(20) notnull default 0 comment('批量查询结果对象数（含订单商品）')" json:"object_count"`
+	ProcessedCount  int64  `xorm:"'processed_count' bigint(20) notnull default 0 comment('已处理订单数，也是断点续传的位置')" json:"processed_count"`
+	ImportedCount   int64  `xorm:"'imported_count' bigint(20) notnull default 0 comment('导入订单数')" json:"imported_count"`
+	SkippedCount    int64  `xorm:"'skipped_count' bigint(20) notnull default 0 comment('跳过订单数（未支付或已存在）')" json:"skipped_count"`
+	ProtectifyCount int64  `xorm:"'protectify_count' bigint(20) notnull default 0 comment('包含保险的订单数')" json:"protectify_count"`
+	ErrorMessage    string `xorm:"'error_message' varchar(500) notnull default '' comment('失败原因')" json:"error_message"`
+	FinishTime      int64  `xorm:"'finish_time' bigint(20) notnull default 0 comment('完成时间')" json:"finish_time"`
+	CreateTime      int64  `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
+	UpdateTime      int64  `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
+}
+
+func (j JobOrderBackfill) TableName() string {
+	return "job_order_backfill"
+}
+
+// Finished 回填任务是否已结束
+func (j *JobOrderBackfill) Finished() bool {
+	return j.Status == BackfillStatusCompleted || j.Status == BackfillStatusFailed
+}
+
+// OrderBackfillReq 回填订单的日期范围，按 UTC 日期，包含结束日期
+type OrderBackfillReq struct {
+	StartDate string `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" binding:"required,datetime=2006-01-02"`
+}
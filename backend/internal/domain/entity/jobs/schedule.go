@@ -0,0 +1,18 @@
+package jobs
+
+// SchedulerStatus 定时任务调度器的状态
+type SchedulerStatus struct {
+	Leader   string           `json:"leader"`   // 当前运行调度器的 job 实例，为空时没有实例在调度
+	Location string           `json:"location"` // cron 表达式使用的时区
+	Tasks    []ScheduleStatus `json:"tasks"`
+}
+
+// ScheduleStatus 单个定时任务的配置和执行时间，时间为秒级时间戳
+type ScheduleStatus struct {
+	Name     string `json:"name"`
+	TaskType string `json:"task_type"`
+	Cron     string `json:"cron"`
+	Enabled  bool   `json:"enabled"`
+	LastRun  int64  `json:"last_run"` // 上次推送任务的时间，0 表示还没有执行过
+	NextRun  int64  `json:"next_run"` // 下次推送任务的时间，停用的任务为 0
+}
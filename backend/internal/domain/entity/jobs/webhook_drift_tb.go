@@ -0,0 +1,17 @@
+package jobs
+
+// WebhookDrift webhook 订阅对账时发现的差异和修正结果
+type WebhookDrift struct {
+	Id             int64  `xorm:"pk autoincr 'id' bigint(20) comment('ID')" json:"id"`
+	UserID         int64  `xorm:"'user_id' bigint(20) notnull default 0 comment('店铺')" json:"user_id"`
+	Topic          string `xorm:"'topic' varchar(100) notnull default '' comment('webhook topic')" json:"topic"`
+	Action         string `xorm:"'action' varchar(20) notnull default '' comment('修正方式 create update delete')" json:"action"`
+	SubscriptionId string `xorm:"'subscription_id' varchar(100) notnull default '' comment('Shopify订阅ID')" json:"subscription_id"`
+	CallbackUrl    string `xorm:"'callback_url' varchar(500) notnull default '' comment('Shopify上原来的回调地址')" json:"callback_url"`
+	ErrorMessage   string `xorm:"'error_message' varchar(500) notnull default '' comment('修正失败原因')" json:"error_message"`
+	CreateTime     int64  `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
+}
+
+func (w WebhookDrift) TableName() string {
+	return "webhook_drift"
+}
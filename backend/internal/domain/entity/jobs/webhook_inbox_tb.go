@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// webhook 处理状态
+const (
+	WebhookStatusPending   = 0 // 待处理
+	WebhookStatusProcessed = 1 // 已处理
+	WebhookStatusFailed    = 2 // 处理失败，等待 asynq 重试或手动重放
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookInbox 验签通过的 Shopify webhook，按 X-Shopify-Webhook-Id 去重，由 asynq 按 topic 异步处理
+type WebhookInbox struct {
+	Id            int64  `xorm:"pk autoincr 'id' bigint(20) comment('ID')" json:"id"`
+	AppId         string `xorm:"'app_id' varchar(64) notnull default '' comment('应用ID')" json:"app_id"`
+	WebhookId     string `xorm:"'webhook_id' varchar(100) notnull default '' comment('X-Shopify-Webhook-Id')" json:"webhook_id"`
+	Topic         string `xorm:"'topic' varchar(100) notnull default '' comment('webhook topic')" json:"topic"`
+	Shop          string `xorm:"'shop' varchar(255) notnull default '' comment('店铺域名')" json:"shop"`
+	ApiVersion    string `xorm:"'api_version' varchar(20) notnull default '' comment('webhook API 版本')" json:"api_version"`
+	TriggeredAt   string `xorm:"'triggered_at' varchar(40) notnull default '' comment('Shopify 触发时间')" json:"triggered_at"`
+	Payload       string `xorm:"'payload' mediumtext comment('webhook 原始内容')" json:"payload"`
+	CustomerId    int64  `xorm:"'customer_id' bigint(20) notnull default 0 comment('webhook 内容中的Shopify顾客ID')" json:"customer_id"`
+	CustomerEmail string `xorm:"'customer_email' varchar(255) notnull default '' comment('webhook 内容中的顾客邮箱')" json:"-"`
+	Status        int    `xorm:"'status' tinyint(1) notnull default 0 comment('状态 0 待处理 1 已处理 2 处理失败')" json:"status"`
+	Attempts      int    `xorm:"'attempts' int(11) notnull default 0 comment('处理次数')" json:"attempts"`
+	ErrorMessage  string `xorm:"'error_message' varchar(500) notnull default '' comment('最近一次失败原因')" json:"error_message"`
+	ProcessedTime int64  `xorm:"'processed_time' bigint(20) notnull default 0 comment('处理完成时间')" json:"processed_time"`
+	CreateTime    int64  `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
+	UpdateTime    int64  `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
+}
+
+func (w WebhookInbox) TableName() string {
+	return "webhook_inbox"
+}
+
+// webhookCustomer webhook 内容中的顾客，订单 webhook 的下单邮箱在顶层 email
+type webhookCustomer struct {
+	Email    string `json:"email"`
+	Customer *struct {
+		Id    int64  `json:"id"`
+		Email string `json:"email"`
+	} `json:"customer"`
+}
+
+// FillCustomer 从 webhook 内容中取出顾客ID和邮箱，清除顾客数据时按这两列精确匹配
+func (w *WebhookInbox) FillCustomer() {
+	var data webhookCustomer
+	if err := json.Unmarshal([]byte(w.Payload), &data); err != nil {
+		return
+	}
+	email := data.Email
+	if data.Customer != nil {
+		w.CustomerId = data.Customer.Id
+		if data.Customer.Email != "" {
+			email = data.Customer.Email
+		}
+	}
+	w.CustomerEmail = NormalizeEmail(email)
+}
+
+// NormalizeEmail 邮箱去掉空格并转小写后比较
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import "testing"
+
+func TestWebhookInboxFillCustomer(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		id      int64
+		email   string
+	}{
+		{"order with customer", `{"id":1,"email":"Buyer@Example.com ","customer":{"id":42,"email":"buyer@example.com"}}`, 42, "buyer@example.com"},
+		{"guest order", `{"id":1,"email":" Guest@Example.com","customer":null}`, 0, "guest@example.com"},
+		{"compliance payload", `{"shop_domain":"a.myshopify.com","customer":{"id":7,"email":"c@example.com"}}`, 7, "c@example.com"},
+		{"no customer", `{"id":1}`, 0, ""},
+		{"invalid payload", `not json`, 0, ""},
+	}
+	for _, c := range cases {
+		inbox := &WebhookInbox{Payload: c.payload}
+		inbox.FillCustomer()
+		if inbox.CustomerId != c.id || inbox.CustomerEmail != c.email {
+			t.Errorf("%s: got (%d, %q), want (%d, %q)", c.name, inbox.CustomerId, inbox.CustomerEmail, c.id, c.email)
+		}
+	}
+}
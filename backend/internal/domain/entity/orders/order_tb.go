@@ -6,6 +6,7 @@ type UserOrder struct {
 	UserID            int64   `xorm:"'user_id' bigint(20) notnull comment('用户id')" json:"user_id"`
 	OrderId           int64   `xorm:"'order_id' bigint(20) notnull default '' comment('Shopify订单ID')" json:"order_id"`
 	OrderName         string  `xorm:"'order_name' varchar(50) notnull default '' comment('订单编号（#xxx）')" json:"order_name"`
+	Email             string  `xorm:"'email' varchar(255) notnull default '' comment('客户邮箱')" json:"email"`
 	OrderCreatedAt    int64   `xorm:"'order_created_at' bigint(20) notnull default 0 comment('订单创建时间')" json:"order_created_at"`
 	OrderCompletionAt int64   `xorm:"'order_completion_at' bigint(20) notnull default 0 comment('订单完成时间')" json:"order_completion_at"`
 	FinancialStatus   string  `xorm:"'financial_status' varchar(50) notnull default '' comment('支付状态')" json:"financial_status"`
@@ -15,6 +16,7 @@ type UserOrder struct {
 	Currency          string  `xorm:"'currency' varchar(10) notnull default '' comment('货币类型')" json:"currency"`
 	SkuNum            int     `xorm:"'sku_num' int(11) notnull default 0 comment('sku购买数量')" json:"sku_num"`
 	IsDel             int     `xorm:"'is_del' tinyint(1) notnull default 0 comment('删除状态 0 正常 1 已删除')" json:"is_del"`
+	Backfilled        int     `xorm:"'backfilled' tinyint(1) notnull default 0 comment('历史订单回填 0 否 1 是，回填的订单不产生抽成')" json:"backfilled"`
 	CreateTime        int64   `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
 	UpdateTime        int64   `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
 }
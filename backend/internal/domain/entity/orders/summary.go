@@ -0,0 +1,27 @@
+package orders
+
+import "time"
+
+// SummaryDay 店铺时区的一天，包含 Start，不包含 End，夏令时切换当天是 23 或 25 小时
+type SummaryDay struct {
+	Start int64
+	End   int64
+	Date  string // 店铺时区的日期（YYYY-MM-DD）
+}
+
+// DayOf at 在店铺时区所在的一天
+func DayOf(at time.Time, loc *time.Location) SummaryDay {
+	at = at.In(loc)
+	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(at.Year(), at.Month(), at.Day()+1, 0, 0, 0, 0, loc)
+	return SummaryDay{Start: start.Unix(), End: end.Unix(), Date: start.Format("2006-01-02")}
+}
+
+// DaysBetween [from, to) 覆盖的店铺时区的每一天，第一天从 from 所在的一天开始
+func DaysBetween(from time.Time, to time.Time, loc *time.Location) []SummaryDay {
+	var days []SummaryDay
+	for day := DayOf(from, loc); day.Start < to.Unix(); day = DayOf(time.Unix(day.End, 0), loc) {
+		days = append(days, day)
+	}
+	return days
+}
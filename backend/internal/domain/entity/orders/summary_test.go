@@ -0,0 +1,82 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"backend/internal/domain/entity/users"
+)
+
+func TestDayOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		at       time.Time
+		date     string
+		start    time.Time
+		hours    int64
+	}{
+		{"new york regular day", "America/New_York", time.Date(2025, 6, 1, 3, 30, 0, 0, time.UTC), "2025-05-31", time.Date(2025, 5, 31, 4, 0, 0, 0, time.UTC), 24},
+		{"new york spring forward", "America/New_York", time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC), "2025-03-09", time.Date(2025, 3, 9, 5, 0, 0, 0, time.UTC), 23},
+		{"new york fall back", "America/New_York", time.Date(2025, 11, 2, 12, 0, 0, 0, time.UTC), "2025-11-02", time.Date(2025, 11, 2, 4, 0, 0, 0, time.UTC), 25},
+		{"berlin spring forward", "Europe/Berlin", time.Date(2025, 3, 30, 1, 30, 0, 0, time.UTC), "2025-03-30", time.Date(2025, 3, 29, 23, 0, 0, 0, time.UTC), 23},
+		{"berlin fall back", "Europe/Berlin", time.Date(2025, 10, 26, 22, 59, 59, 0, time.UTC), "2025-10-26", time.Date(2025, 10, 25, 22, 0, 0, 0, time.UTC), 25},
+		{"berlin midnight belongs to new day", "Europe/Berlin", time.Date(2025, 7, 1, 22, 0, 0, 0, time.UTC), "2025-07-02", time.Date(2025, 7, 1, 22, 0, 0, 0, time.UTC), 24},
+		{"sydney fall back", "Australia/Sydney", time.Date(2025, 4, 5, 14, 0, 0, 0, time.UTC), "2025-04-06", time.Date(2025, 4, 5, 13, 0, 0, 0, time.UTC), 25},
+		{"kolkata half hour offset", "Asia/Kolkata", time.Date(2025, 1, 1, 18, 29, 0, 0, time.UTC), "2025-01-01", time.Date(2024, 12, 31, 18, 30, 0, 0, time.UTC), 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.timezone)
+			if err != nil {
+				t.Fatalf("LoadLocation(%s) error = %v", tt.timezone, err)
+			}
+			day := DayOf(tt.at, loc)
+			if day.Date != tt.date {
+				t.Errorf("Date = %s, want %s", day.Date, tt.date)
+			}
+			if day.Start != tt.start.Unix() {
+				t.Errorf("Start = %s, want %s", time.Unix(day.Start, 0).UTC(), tt.start)
+			}
+			if hours := (day.End - day.Start) / 3600; hours != tt.hours {
+				t.Errorf("day length = %dh, want %dh", hours, tt.hours)
+			}
+			if tt.at.Unix() < day.Start || tt.at.Unix() >= day.End {
+				t.Errorf("day [%d, %d) does not contain %d", day.Start, day.End, tt.at.Unix())
+			}
+		})
+	}
+}
+
+func TestDaysBetweenAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2025, 3, 8, 12, 0, 0, 0, loc)
+	to := time.Date(2025, 3, 10, 0, 0, 0, 0, loc)
+	days := DaysBetween(from, to, loc)
+	want := []string{"2025-03-08", "2025-03-09"}
+	if len(days) != len(want) {
+		t.Fatalf("days = %v, want %v", days, want)
+	}
+	for i, day := range days {
+		if day.Date != want[i] {
+			t.Errorf("days[%d] = %s, want %s", i, day.Date, want[i])
+		}
+		if i > 0 && day.Start != days[i-1].End {
+			t.Errorf("days[%d] starts at %d, previous day ends at %d", i, day.Start, days[i-1].End)
+		}
+	}
+}
+
+func TestUserLocation(t *testing.T) {
+	if loc := (&users.User{IanaTimezone: "Europe/Paris"}).Location(); loc.String() != "Europe/Paris" {
+		t.Errorf("Location() = %s, want Europe/Paris", loc)
+	}
+	for _, timezone := range []string{"", "Mars/Olympus"} {
+		if loc := (&users.User{IanaTimezone: timezone}).Location(); loc.String() != users.DefaultTimezone {
+			t.Errorf("Location() for %q = %s, want %s", timezone, loc, users.DefaultTimezone)
+		}
+	}
+}
@@ -1,5 +1,19 @@
 package products
 
+import "github.com/shopspring/decimal"
+
+// NewLadderVariant 变体阶梯中一个价格对应的保险变体，SKU 和选项值都使用价格，同一商品内不会重复
+func NewLadderVariant(userID int64, userProductId int64, price decimal.Decimal) *UserVariant {
+	value := price.StringFixed(2)
+	return &UserVariant{
+		UserID:        userID,
+		UserProductId: userProductId,
+		SkuName:       value,
+		Sku1:          value,
+		Price:         price.InexactFloat64(),
+	}
+}
+
 type ProductWebHookReq struct {
 	Shop      string `json:"shop"`
 	AppId     string `json:"app_id"`
@@ -20,8 +20,10 @@ type UserProduct struct {
 	Status      int    `xorm:"'status' tinyint(1) notnull default 0 comment('发布Shopify：0:未发布 1:已发布 2:正在发布中 3:shopify平台已删除')" json:"is_publish"`
 	PublishTime int64  `xorm:"'publish_time' bigint(20) notnull default 0 comment('发布时间')" json:"publish_time"`
 	IsDel       int    `xorm:"'is_del' tinyint(1) notnull default 0 comment('删除状态 0 正常 1 已删除')" json:"is_del"`
-	CreateTime  int64  `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
-	UpdateTime  int64  `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
+	// LadderVersion 变体阶梯版本，0 为按阶梯生成变体之前上传的固定变体
+	LadderVersion int   `xorm:"'ladder_version' tinyint(1) notnull default 0 comment('变体阶梯版本 0 固定变体 1 按阶梯生成')" json:"ladder_version"`
+	CreateTime    int64 `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
+	UpdateTime    int64 `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
 }
 
 // UserVariant 保险用户变体表
@@ -38,6 +40,18 @@ type UserVariant struct {
 	Sku2          string  `xorm:"'sku_2' varchar(150) notnull default '' comment('变体属性2')" json:"sku_2"`
 	Sku3          string  `xorm:"'sku_3' varchar(150) notnull default '' comment('变体属性3')" json:"sku_3"`
 	Price         float64 `xorm:"'price' decimal(12,2) notnull default 0.00 comment('价格设定')" json:"price"`
+	IsDel         int     `xorm:"'is_del' tinyint(1) notnull default 0 comment('删除状态 0 正常 1 已被阶梯替换')" json:"is_del"`
 	CreateTime    int64   `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
 	UpdateTime    int64   `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
 }
+
+// LadderVersion 变体阶梯版本
+const (
+	LadderVersionLegacy = 0 // 按阶梯生成变体之前上传的固定变体
+	LadderVersionLadder = 1 // 按购物车设置的阶梯生成变体
+)
+
+// UsesLegacyLadder 没有设置阶梯时是否继续使用旧的固定变体
+func (p *UserProduct) UsesLegacyLadder() bool {
+	return p.LadderVersion == LadderVersionLegacy
+}
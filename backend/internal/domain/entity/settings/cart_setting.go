@@ -1,5 +1,17 @@
 package settings
 
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrShopNotFound = errors.New("shop not found")
+	ErrCartDisabled = errors.New("protection is not enabled for this shop")
+	ErrNoVariant    = errors.New("no protection variant covers this price")
+)
+
 type CollectionItem struct {
 	Title string `json:"title"`
 	ID    int64  `json:"id"`
@@ -31,6 +43,11 @@ type SettingConfigReq struct {
 	Icons                []IconReq        `json:"icons" binding:"required,dive"`
 	FulfillmentRule      int              `json:"fulfillmentRule" binding:"oneof=0 1 2"`
 	CSS                  string           `json:"css"`
+	LadderMin            string           `json:"ladderMin"`
+	LadderMax            string           `json:"ladderMax"`
+	LadderStep           string           `json:"ladderStep"`
+	LadderPoints         []string         `json:"ladderPoints"`
+	LadderRounding       string           `json:"ladderRounding" binding:"omitempty,oneof=up down nearest"`
 }
 
 type CartSettingData struct {
@@ -52,6 +69,8 @@ type CartSettingData struct {
 	OutColor string `json:"out_color"`
 	// 购物车状态 0 关闭 1 打开
 	ShowCart int `json:"show_cart"`
+	// 是否因订阅用量达到上限自动关闭 0 否 1 是
+	CappedPaused int `json:"capped_paused"`
 	// 购物车图标 0 关闭 1 打开
 	ShowCartIcon int `json:"show_cart_icon"`
 	// 购物车图标 0 滑动 1 勾选
@@ -72,6 +91,12 @@ type CartSettingData struct {
 	PriceSelect       []PriceSelectReq `json:"price_select"`
 	TiersSelect       []TierSelectReq  `json:"tiers_select"`
 	Icons             []IconReq        `json:"icons"`
+	// 变体阶梯，有价格点时忽略最低、最高价格和步长
+	LadderMin      float64  `json:"ladder_min"`
+	LadderMax      float64  `json:"ladder_max"`
+	LadderStep     float64  `json:"ladder_step"`
+	LadderPoints   []string `json:"ladder_points"`
+	LadderRounding string   `json:"ladder_rounding"`
 }
 
 type PriceSelectReq struct {
@@ -114,3 +139,29 @@ type CartPublicData struct {
 	MoneyFormat    string           `json:"money_format"`
 	PricingType    int              `json:"pricing_type"`
 }
+
+// QuoteReq 购物车插件按购物车金额（不含保险商品）查询保险价格
+type QuoteReq struct {
+	Shop     string          `json:"shop" binding:"required"`
+	Subtotal decimal.Decimal `json:"subtotal"`
+}
+
+// QuoteRule 报价命中的金额区间，Max 为 0 表示没有上限
+type QuoteRule struct {
+	Min   decimal.Decimal `json:"min"`
+	Max   decimal.Decimal `json:"max"`
+	Value decimal.Decimal `json:"value"` // 固定金额或百分比
+}
+
+// QuoteResponse 保险报价，购物车插件按 VariantId 加购保险商品
+type QuoteResponse struct {
+	Subtotal     decimal.Decimal `json:"subtotal"`      // 购物车金额
+	Price        decimal.Decimal `json:"price"`         // 规则计算的保险价格
+	PricingType  int             `json:"pricing_type"`  // 计价方式 0 金额 1 百分比
+	Source       string          `json:"source"`        // 命中的规则来源：all, range, out_range
+	Rule         *QuoteRule      `json:"rule"`          // 命中的金额区间
+	ProductId    int64           `json:"product_id"`    // 保险商品ID
+	VariantId    int64           `json:"variant_id"`    // 加购的变体ID
+	VariantPrice decimal.Decimal `json:"variant_price"` // 变体价格，即客户实际支付的保险价格
+	MoneyFormat  string          `json:"money_format"`
+}
@@ -1,5 +1,12 @@
 package settings
 
+// 发货规则，订单到达对应阶段后才确认保险佣金
+const (
+	FulfillmentRuleFirstFulfilled = 0 // 第一个发货完成
+	FulfillmentRuleAllFulfilled   = 1 // 全部发货完成
+	FulfillmentRulePaid           = 2 // 付款后确认
+)
+
 // UserCartSetting  保险用户基础配置表
 type UserCartSetting struct {
 	Id                int64   `xorm:"pk autoincr 'id' bigint(20) comment('ID')" json:"id"`
@@ -28,6 +35,12 @@ type UserCartSetting struct {
 	AllPriceSet       float64 `xorm:"'all_price_set' decimal(12,2) notnull default 0.00 comment('所有订单适用固定金额') " json:"all_price_set"`
 	FulfillmentRule   int     `xorm:"'fulfillment_rule' tinyint(1) default 0 notnull comment('在订单处于哪个发货阶段才计算保险佣金(0,1,2 分别代表第一个发货完成，全都发货完成，付费后就算)')" json:"fulfillment_rule"`
 	CSS               string  `xorm:"'css' text comment('css样式自定义')" json:"css"`
+	CappedPaused      int     `xorm:"'capped_paused' tinyint(1) default 0 notnull comment('是否因订阅用量达到上限自动关闭购物车 0 否 1 是')" json:"capped_paused"`
+	LadderMin         float64 `xorm:"'ladder_min' decimal(12,2) notnull default 0.00 comment('变体阶梯最低价格')" json:"ladder_min"`
+	LadderMax         float64 `xorm:"'ladder_max' decimal(12,2) notnull default 0.00 comment('变体阶梯最高价格')" json:"ladder_max"`
+	LadderStep        float64 `xorm:"'ladder_step' decimal(12,2) notnull default 0.00 comment('变体阶梯步长，0 表示使用默认阶梯')" json:"ladder_step"`
+	LadderPoints      string  `xorm:"'ladder_points' text comment('变体阶梯价格点(json)，不为空时忽略最低、最高价格和步长')" json:"ladder_points"`
+	LadderRounding    string  `xorm:"'ladder_rounding' varchar(10) notnull default 'up' comment('报价取整到变体的方式 up 向上 down 向下 nearest 最接近')" json:"ladder_rounding"`
 	CreateTime        int64   `xorm:"created 'create_time' bigint(20) notnull comment('创建时间')" json:"create_time"`
 	UpdateTime        int64   `xorm:"updated 'update_time' bigint(20) notnull comment('修改时间')" json:"update_time"`
 }
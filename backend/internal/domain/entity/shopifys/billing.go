@@ -11,12 +11,28 @@ type AppUsageRecordCreateResponse struct {
 	UserErrors     []UserError     `json:"userErrors"`
 }
 
+// AppCreditCreateResponse appCreditCreate 响应结构
+type AppCreditCreateResponse struct {
+	AppCredit  *AppCredit  `json:"appCredit"`
+	UserErrors []UserError `json:"userErrors"`
+}
+
+// AppCredit 应用返还额度
+type AppCredit struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Amount      MoneyV2 `json:"amount"`
+	Test        bool    `json:"test"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
 // AppUsageRecord 用量记录
 type AppUsageRecord struct {
 	ID                   string                   `json:"id"`
 	Description          string                   `json:"description"`
 	Price                MoneyV2                  `json:"price"`
 	CreatedAt            string                   `json:"createdAt"`
+	IdempotencyKey       string                   `json:"idempotencyKey"`
 	SubscriptionLineItem *AppSubscriptionLineItem `json:"subscriptionLineItem,omitempty"`
 }
 
@@ -35,12 +51,20 @@ type UserError struct {
 
 // AppSubscriptionCreateInput 创建订阅输入
 type AppSubscriptionCreateInput struct {
-	Name      string                         `json:"name"`
-	Test      bool                           `json:"test"`
-	TrialDays int                            `json:"trialDays,omitempty"`
-	LineItems []AppSubscriptionLineItemInput `json:"lineItems"`
-	ReturnURL string                         `json:"returnUrl"`
-}
+	Name                string                         `json:"name"`
+	Test                bool                           `json:"test"`
+	TrialDays           int                            `json:"trialDays,omitempty"`
+	LineItems           []AppSubscriptionLineItemInput `json:"lineItems"`
+	ReturnURL           string                         `json:"returnUrl"`
+	ReplacementBehavior string                         `json:"replacementBehavior,omitempty"`
+}
+
+// 新订阅替换当前订阅的方式
+const (
+	ReplacementApplyImmediately        = "APPLY_IMMEDIATELY"           // 立即生效，升级套餐使用
+	ReplacementApplyOnNextBillingCycle = "APPLY_ON_NEXT_BILLING_CYCLE" // 当前周期结束后生效，降级套餐使用
+)
+
 type AppSubscriptionLineItemInput struct {
 	Plan AppPlanInput `json:"plan"`
 }
@@ -76,6 +100,19 @@ type AppSubscriptionCreateResponse struct {
 	UserErrors      []UserError      `json:"userErrors"`
 }
 
+// AppSubscriptionLineItemUpdateResponse 修改用量上限响应，商家需要打开 ConfirmationURL 确认
+type AppSubscriptionLineItemUpdateResponse struct {
+	AppSubscription *AppSubscription `json:"appSubscription"`
+	ConfirmationURL string           `json:"confirmationUrl"`
+	UserErrors      []UserError      `json:"userErrors"`
+}
+
+// AppSubscriptionCancelResponse 取消订阅响应
+type AppSubscriptionCancelResponse struct {
+	AppSubscription *AppSubscription `json:"appSubscription"`
+	UserErrors      []UserError      `json:"userErrors"`
+}
+
 // AppSubscription 订阅信息
 type AppSubscription struct {
 	ID               string                    `json:"id"`
@@ -256,9 +293,11 @@ func (a *AppSubscription) GetRecurringPricing() (*AppRecurringPricing, error) {
 		return nil, fmt.Errorf("no line items found")
 	}
 
-	pricing := a.LineItems[0].Plan.PricingDetails
-	if recurring, ok := pricing.(AppRecurringPricing); ok {
-		return &recurring, nil
+	// 套餐同时有固定费用和用量抽成时，订阅会有两个项目
+	for _, lineItem := range a.LineItems {
+		if recurring, ok := lineItem.Plan.PricingDetails.(AppRecurringPricing); ok {
+			return &recurring, nil
+		}
 	}
 
 	return nil, fmt.Errorf("not a recurring pricing subscription")
@@ -270,14 +309,28 @@ func (a *AppSubscription) GetUsagePricing() (*AppUsagePricing, error) {
 		return nil, fmt.Errorf("no line items found")
 	}
 
-	pricing := a.LineItems[0].Plan.PricingDetails
-	if usage, ok := pricing.(AppUsagePricing); ok {
-		return &usage, nil
+	for _, lineItem := range a.LineItems {
+		if usage, ok := lineItem.Plan.PricingDetails.(AppUsagePricing); ok {
+			return &usage, nil
+		}
 	}
 
 	return nil, fmt.Errorf("not a usage pricing subscription")
 }
 
+// UsageLineItemID 用量扣费使用的订阅项目ID，没有用量项目时返回第一个项目
+func (a *AppSubscription) UsageLineItemID() string {
+	for _, lineItem := range a.LineItems {
+		if _, ok := lineItem.Plan.PricingDetails.(AppUsagePricing); ok {
+			return lineItem.ID
+		}
+	}
+	if len(a.LineItems) > 0 {
+		return a.LineItems[0].ID
+	}
+	return ""
+}
+
 // IsRecurringSubscription 判断是否为循环订阅
 func (a *AppSubscription) IsRecurringSubscription() bool {
 	_, err := a.GetRecurringPricing()
@@ -0,0 +1,117 @@
+package shopifys
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// 批量操作状态
+const (
+	BulkOperationCreated   = "CREATED"
+	BulkOperationRunning   = "RUNNING"
+	BulkOperationCompleted = "COMPLETED"
+	BulkOperationCanceling = "CANCELING"
+	BulkOperationCanceled  = "CANCELED"
+	BulkOperationFailed    = "FAILED"
+	BulkOperationExpired   = "EXPIRED"
+)
+
+// BulkOperation Shopify 批量查询，完成后结果以 JSONL 文件下载
+type BulkOperation struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	ErrorCode   string `json:"errorCode"`
+	ObjectCount string `json:"objectCount"` // UnsignedInt64 以字符串返回
+	FileSize    string `json:"fileSize"`
+	// URL 结果文件下载链接，没有数据时为空，链接 7 天后过期
+	URL            string `json:"url"`
+	PartialDataURL string `json:"partialDataUrl"`
+	CreatedAt      string `json:"createdAt"`
+	CompletedAt    string `json:"completedAt"`
+}
+
+// Finished 批量操作是否已经结束
+func (b *BulkOperation) Finished() bool {
+	switch b.Status {
+	case BulkOperationCompleted, BulkOperationCanceled, BulkOperationFailed, BulkOperationExpired:
+		return true
+	}
+	return false
+}
+
+// Objects 结果包含的对象数量，包括嵌套的子对象
+func (b *BulkOperation) Objects() int64 {
+	count, _ := strconv.ParseInt(b.ObjectCount, 10, 64)
+	return count
+}
+
+// BulkOperationRunQueryResponse 创建批量查询返回
+type BulkOperationRunQueryResponse struct {
+	BulkOperationRunQuery struct {
+		BulkOperation *BulkOperation `json:"bulkOperation"`
+		UserErrors    []UserError    `json:"userErrors"`
+	} `json:"bulkOperationRunQuery"`
+}
+
+// BulkOperationResponse 查询批量操作返回
+type BulkOperationResponse struct {
+	Node *BulkOperation `json:"node"`
+}
+
+// bulkLine JSONL 每行都有 id，子对象用 __parentId 关联父对象
+type bulkLine struct {
+	ID       string `json:"id"`
+	ParentID string `json:"__parentId"`
+}
+
+// BulkOrderReader 把订单批量查询的 JSONL 行组装成订单。
+// 结果文件中订单商品紧跟在所属订单之后，读到下一个订单时上一个订单才完整。
+type BulkOrderReader struct {
+	current *OrderResponse
+	emit    func(order *OrderResponse) error
+}
+
+func NewBulkOrderReader(emit func(order *OrderResponse) error) *BulkOrderReader {
+	return &BulkOrderReader{emit: emit}
+}
+
+// Add 读入一行
+func (r *BulkOrderReader) Add(line []byte) error {
+	var head bulkLine
+	if err := json.Unmarshal(line, &head); err != nil {
+		return fmt.Errorf("invalid bulk line: %w", err)
+	}
+
+	if head.ParentID == "" {
+		if err := r.Flush(); err != nil {
+			return err
+		}
+		order := &OrderResponse{}
+		if err := json.Unmarshal(line, &order.Order); err != nil {
+			return fmt.Errorf("invalid bulk order %s: %w", head.ID, err)
+		}
+		r.current = order
+		return nil
+	}
+
+	if r.current == nil || r.current.Order.ID != head.ParentID {
+		return fmt.Errorf("bulk line %s does not follow its order %s", head.ID, head.ParentID)
+	}
+	var edge OrderLineItemEdge
+	if err := json.Unmarshal(line, &edge.Node); err != nil {
+		return fmt.Errorf("invalid bulk line item %s: %w", head.ID, err)
+	}
+	r.current.Order.LineItems.Edges = append(r.current.Order.LineItems.Edges, edge)
+	return nil
+}
+
+// Flush 输出最后一个订单，读完文件后需要调用
+func (r *BulkOrderReader) Flush() error {
+	if r.current == nil {
+		return nil
+	}
+	order := r.current
+	r.current = nil
+	return r.emit(order)
+}
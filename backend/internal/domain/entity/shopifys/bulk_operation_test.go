@@ -0,0 +1,53 @@
+package shopifys
+
+import (
+	"testing"
+)
+
+func TestBulkOrderReader(t *testing.T) {
+	lines := []string{
+		`{"id":"gid://shopify/Order/1","name":"#1001","displayFinancialStatus":"PAID","totalPriceSet":{"shopMoney":{"amount":"25.50","currencyCode":"USD"}}}`,
+		`{"id":"gid://shopify/LineItem/11","sku":"A","quantity":2,"variant":{"id":"gid://shopify/ProductVariant/100"},"originalUnitPriceSet":{"shopMoney":{"amount":"10.00","currencyCode":"USD"}},"__parentId":"gid://shopify/Order/1"}`,
+		`{"id":"gid://shopify/LineItem/12","sku":"P","quantity":1,"variant":{"id":"gid://shopify/ProductVariant/200"},"originalUnitPriceSet":{"shopMoney":{"amount":"5.50","currencyCode":"USD"}},"__parentId":"gid://shopify/Order/1"}`,
+		`{"id":"gid://shopify/Order/2","name":"#1002","displayFinancialStatus":"PENDING"}`,
+	}
+
+	var got []*OrderResponse
+	reader := NewBulkOrderReader(func(order *OrderResponse) error {
+		got = append(got, order)
+		return nil
+	})
+	for _, line := range lines {
+		if err := reader.Add([]byte(line)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := reader.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d orders, want 2", len(got))
+	}
+	first := got[0].Order
+	if first.Name != "#1001" || len(first.LineItems.Edges) != 2 {
+		t.Fatalf("first order = %s with %d line items", first.Name, len(first.LineItems.Edges))
+	}
+	if first.LineItems.Edges[1].Node.Variant.ID != "gid://shopify/ProductVariant/200" {
+		t.Errorf("line item variant = %s", first.LineItems.Edges[1].Node.Variant.ID)
+	}
+	if first.TotalPriceSet.ShopMoney.Amount.String() != "25.5" {
+		t.Errorf("total amount = %s", first.TotalPriceSet.ShopMoney.Amount)
+	}
+	if got[1].Order.Name != "#1002" || len(got[1].Order.LineItems.Edges) != 0 {
+		t.Errorf("second order = %s with %d line items", got[1].Order.Name, len(got[1].Order.LineItems.Edges))
+	}
+}
+
+func TestBulkOrderReaderOrphanLine(t *testing.T) {
+	reader := NewBulkOrderReader(func(order *OrderResponse) error { return nil })
+	err := reader.Add([]byte(`{"id":"gid://shopify/LineItem/11","__parentId":"gid://shopify/Order/9"}`))
+	if err == nil {
+		t.Fatal("Add() want error for line item without order")
+	}
+}
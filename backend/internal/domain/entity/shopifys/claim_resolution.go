@@ -0,0 +1,123 @@
+package shopifys
+
+import "github.com/shopspring/decimal"
+
+// ClaimOrder 理赔处理需要的订单信息
+type ClaimOrder struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Currency string `json:"currencyCode"`
+	Customer *struct {
+		ID string `json:"id"`
+	} `json:"customer"`
+	ShippingAddress *MailingAddress `json:"shippingAddress"`
+	Refunds         []struct {
+		ID   string `json:"id"`
+		Note string `json:"note"`
+	} `json:"refunds"`
+}
+
+// CustomerID 订单客户ID，游客下单时为空
+func (o *ClaimOrder) CustomerID() string {
+	if o.Customer == nil {
+		return ""
+	}
+	return o.Customer.ID
+}
+
+// ClaimLineItem 订单商品的变体和分摊全部折扣后的单价
+type ClaimLineItem struct {
+	ID      string `json:"id"`
+	Variant *struct {
+		ID string `json:"id"`
+	} `json:"variant"`
+	DiscountedUnitPriceAfterAllDiscountsSet struct {
+		ShopMoney struct {
+			Amount decimal.Decimal `json:"amount"`
+		} `json:"shopMoney"`
+	} `json:"discountedUnitPriceAfterAllDiscountsSet"`
+}
+
+// MailingAddress 收货地址
+type MailingAddress struct {
+	Address1     string `json:"address1"`
+	Address2     string `json:"address2"`
+	City         string `json:"city"`
+	Company      string `json:"company"`
+	CountryCode  string `json:"countryCodeV2"`
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	Phone        string `json:"phone"`
+	ProvinceCode string `json:"provinceCode"`
+	Zip          string `json:"zip"`
+}
+
+// RefundLineItemInput 退款商品
+type RefundLineItemInput struct {
+	LineItemID string `json:"lineItemId"`
+	Quantity   int    `json:"quantity"`
+}
+
+// SuggestedTransaction Shopify 按退款商品建议的退款交易
+type SuggestedTransaction struct {
+	Gateway           string `json:"gateway"`
+	Kind              string `json:"kind"`
+	ParentTransaction *struct {
+		ID string `json:"id"`
+	} `json:"parentTransaction"`
+	AmountSet struct {
+		ShopMoney struct {
+			Amount       decimal.Decimal `json:"amount"`
+			CurrencyCode string          `json:"currencyCode"`
+		} `json:"shopMoney"`
+	} `json:"amountSet"`
+}
+
+// DraftOrderLineItemInput 补发的商品
+type DraftOrderLineItemInput struct {
+	VariantID string `json:"variantId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// DraftOrder 草稿订单，完成后 Order 为生成的订单
+type DraftOrder struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Order  *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"order"`
+}
+
+type RefundCreateResponse struct {
+	RefundCreate struct {
+		Refund *struct {
+			ID string `json:"id"`
+		} `json:"refund"`
+		UserErrors []UserError `json:"userErrors"`
+	} `json:"refundCreate"`
+}
+
+type GiftCardCreateResponse struct {
+	GiftCardCreate struct {
+		GiftCard *struct {
+			ID string `json:"id"`
+		} `json:"giftCard"`
+		UserErrors []UserError `json:"userErrors"`
+	} `json:"giftCardCreate"`
+}
+
+type DraftOrderCreateResponse struct {
+	DraftOrderCreate struct {
+		DraftOrder *DraftOrder `json:"draftOrder"`
+		UserErrors []UserError `json:"userErrors"`
+	} `json:"draftOrderCreate"`
+}
+
+type DraftOrderCompleteResponse struct {
+	DraftOrderComplete struct {
+		DraftOrder *DraftOrder `json:"draftOrder"`
+		UserErrors []UserError `json:"userErrors"`
+	} `json:"draftOrderComplete"`
+}
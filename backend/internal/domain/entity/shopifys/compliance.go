@@ -0,0 +1,47 @@
+package shopifys
+
+import (
+	"backend/internal/domain/entity/claims"
+	"backend/internal/domain/entity/orders"
+)
+
+// ComplianceCustomer 合规 webhook 中的顾客
+type ComplianceCustomer struct {
+	Id    int64  `json:"id"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// CompliancePayload Shopify 合规 webhook 载荷，shop/redact 只有店铺信息
+type CompliancePayload struct {
+	ShopId          int64              `json:"shop_id"`
+	ShopDomain      string             `json:"shop_domain"`
+	Customer        ComplianceCustomer `json:"customer"`
+	OrdersRequested []int64            `json:"orders_requested"`
+	OrdersToRedact  []int64            `json:"orders_to_redact"`
+	DataRequest     struct {
+		Id int64 `json:"id"`
+	} `json:"data_request"`
+}
+
+// CustomerDataExport 顾客数据导出文件内容
+type CustomerDataExport struct {
+	Shop        string                 `json:"shop"`
+	Customer    ComplianceCustomer     `json:"customer"`
+	GeneratedAt int64                  `json:"generated_at"`
+	Orders      []*CustomerOrderExport `json:"orders"`
+}
+
+// CustomerOrderExport 顾客的订单、订单商品和理赔
+type CustomerOrderExport struct {
+	Order  *orders.UserOrder       `json:"order"`
+	Items  []*orders.UserOrderInfo `json:"items"`
+	Claims []*CustomerClaimExport  `json:"claims"`
+}
+
+// CustomerClaimExport 理赔、理赔商品和凭证
+type CustomerClaimExport struct {
+	Claim    *claims.OrderClaim           `json:"claim"`
+	Items    []*claims.OrderClaimItem     `json:"items"`
+	Evidence []*claims.OrderClaimEvidence `json:"evidence"`
+}
@@ -9,60 +9,96 @@ type Order struct {
 	CreatedAt              string `json:"createdAt"`
 	ProcessedAt            string `json:"processedAt"`
 	DisplayFinancialStatus string `json:"displayFinancialStatus"`
-	TotalPriceSet          struct {
+	// DisplayFulfillmentStatus UNFULFILLED, PARTIALLY_FULFILLED, FULFILLED 等
+	DisplayFulfillmentStatus string `json:"displayFulfillmentStatus"`
+	Fulfillments             []struct {
+		ID        string `json:"id"`
+		Status    string `json:"status"`
+		CreatedAt string `json:"createdAt"`
+	} `json:"fulfillments"`
+	TotalPriceSet struct {
 		ShopMoney struct {
 			Amount       decimal.Decimal `json:"amount"`
 			CurrencyCode string          `json:"currencyCode"`
 		} `json:"shopMoney"`
 	} `json:"totalPriceSet"`
+	// SubtotalPriceSet 商品小计（含折扣），不包含运费和税
+	SubtotalPriceSet struct {
+		ShopMoney struct {
+			Amount       decimal.Decimal `json:"amount"`
+			CurrencyCode string          `json:"currencyCode"`
+		} `json:"shopMoney"`
+	} `json:"subtotalPriceSet"`
 	LineItems struct {
-		Edges []struct {
-			Node struct {
-				VariantTitle string `json:"variantTitle"`
-				Sku          string `json:"sku"`
-				Quantity     int    `json:"quantity"`
-				Variant      struct {
-					ID string `json:"id"`
-				} `json:"variant"`
-				OriginalUnitPriceSet struct {
-					ShopMoney struct {
-						Amount       decimal.Decimal `json:"amount"`
-						CurrencyCode string          `json:"currencyCode"`
-					} `json:"shopMoney"`
-				} `json:"originalUnitPriceSet"`
-			} `json:"node"`
-		} `json:"edges"`
+		Edges    []OrderLineItemEdge `json:"edges"`
+		PageInfo PageInfo            `json:"pageInfo"`
 	} `json:"lineItems"`
-	Refunds []struct {
-		ID               string `json:"id"`
-		CreatedAt        string `json:"createdAt"`
-		TotalRefundedSet struct {
-			ShopMoney struct {
-				Amount       decimal.Decimal `json:"amount"`
-				CurrencyCode string          `json:"currencyCode"`
-			} `json:"shopMoney"`
-		} `json:"totalRefundedSet"`
-		RefundLineItems struct {
-			Edges []struct {
-				Node struct {
-					LineItem struct {
-						ID      string `json:"id"`
-						Title   string `json:"title"`
-						Variant struct {
-							ID string `json:"id"`
-						} `json:"variant"`
-					} `json:"lineItem"`
-					Quantity    int `json:"quantity"`
-					SubtotalSet struct {
-						ShopMoney struct {
-							Amount       decimal.Decimal `json:"amount"`
-							CurrencyCode string          `json:"currencyCode"`
-						} `json:"shopMoney"`
-					} `json:"subtotalSet"`
-				} `json:"node"`
-			} `json:"edges"`
-		} `json:"refundLineItems"`
-	} `json:"refunds"`
+	Refunds []Refund `json:"refunds"`
+}
+
+// PageInfo 连接的分页信息
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Refund 订单退款
+type Refund struct {
+	ID               string `json:"id"`
+	CreatedAt        string `json:"createdAt"`
+	TotalRefundedSet struct {
+		ShopMoney struct {
+			Amount       decimal.Decimal `json:"amount"`
+			CurrencyCode string          `json:"currencyCode"`
+		} `json:"shopMoney"`
+	} `json:"totalRefundedSet"`
+	RefundLineItems struct {
+		Edges    []RefundLineItemEdge `json:"edges"`
+		PageInfo PageInfo             `json:"pageInfo"`
+	} `json:"refundLineItems"`
+}
+
+type RefundLineItemEdge struct {
+	Node RefundLineItem `json:"node"`
+}
+
+// RefundLineItem 退款商品
+type RefundLineItem struct {
+	LineItem struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Variant struct {
+			ID string `json:"id"`
+		} `json:"variant"`
+	} `json:"lineItem"`
+	Quantity    int `json:"quantity"`
+	SubtotalSet struct {
+		ShopMoney struct {
+			Amount       decimal.Decimal `json:"amount"`
+			CurrencyCode string          `json:"currencyCode"`
+		} `json:"shopMoney"`
+	} `json:"subtotalSet"`
+}
+
+type OrderLineItemEdge struct {
+	Node OrderLineItem `json:"node"`
+}
+
+// OrderLineItem 订单商品
+type OrderLineItem struct {
+	ID           string `json:"id"`
+	VariantTitle string `json:"variantTitle"`
+	Sku          string `json:"sku"`
+	Quantity     int    `json:"quantity"`
+	Variant      struct {
+		ID string `json:"id"`
+	} `json:"variant"`
+	OriginalUnitPriceSet struct {
+		ShopMoney struct {
+			Amount       decimal.Decimal `json:"amount"`
+			CurrencyCode string          `json:"currencyCode"`
+		} `json:"shopMoney"`
+	} `json:"originalUnitPriceSet"`
 }
 
 type OrderResponse struct {
@@ -0,0 +1,61 @@
+package shopifys
+
+import "strings"
+
+// 订阅对账的处理方式
+const (
+	WebhookActionCreate = "create"
+	WebhookActionUpdate = "update"
+	WebhookActionDelete = "delete"
+)
+
+// WebhookTopicEnum webhook topic 转为 GraphQL 的 WebhookSubscriptionTopic，例如 orders/updated 转为 ORDERS_UPDATED
+func WebhookTopicEnum(topic string) string {
+	return strings.ToUpper(strings.ReplaceAll(topic, "/", "_"))
+}
+
+// WebhookChange 订阅需要做的修改，Subscription 为 Shopify 上已有的订阅，创建时为空
+type WebhookChange struct {
+	Action       string
+	Topic        string
+	Subscription *WebhookSubscription
+}
+
+// DiffWebhookSubscriptions 对比需要的 topic、回调地址和 Shopify 上的订阅：缺少的创建，地址不一致的更新，
+// 不需要的 topic 和同一个 topic 的重复订阅删除，重复时优先保留地址正确的订阅
+func DiffWebhookSubscriptions(topics []string, callbackUrl string, subscriptions []WebhookSubscription) []WebhookChange {
+	wanted := make(map[string]string, len(topics))
+	for _, topic := range topics {
+		wanted[WebhookTopicEnum(topic)] = topic
+	}
+
+	keep := make(map[string]*WebhookSubscription, len(topics))
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if _, ok := wanted[subscription.Topic]; !ok {
+			continue
+		}
+		current, ok := keep[subscription.Topic]
+		if !ok || (current.Endpoint.CallbackUrl != callbackUrl && subscription.Endpoint.CallbackUrl == callbackUrl) {
+			keep[subscription.Topic] = subscription
+		}
+	}
+
+	changes := make([]WebhookChange, 0)
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if keep[subscription.Topic] != subscription {
+			changes = append(changes, WebhookChange{Action: WebhookActionDelete, Topic: subscription.Topic, Subscription: subscription})
+			continue
+		}
+		if subscription.Endpoint.CallbackUrl != callbackUrl {
+			changes = append(changes, WebhookChange{Action: WebhookActionUpdate, Topic: wanted[subscription.Topic], Subscription: subscription})
+		}
+	}
+	for _, topic := range topics {
+		if keep[WebhookTopicEnum(topic)] == nil {
+			changes = append(changes, WebhookChange{Action: WebhookActionCreate, Topic: topic})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,68 @@
+package shopifys
+
+import (
+	"testing"
+)
+
+func subscription(id string, topic string, url string) WebhookSubscription {
+	s := WebhookSubscription{Id: id, Topic: topic}
+	s.Endpoint.CallbackUrl = url
+	return s
+}
+
+func TestWebhookTopicEnum(t *testing.T) {
+	cases := map[string]string{
+		"orders/updated":           "ORDERS_UPDATED",
+		"app_subscriptions/update": "APP_SUBSCRIPTIONS_UPDATE",
+		"app/uninstalled":          "APP_UNINSTALLED",
+	}
+	for topic, want := range cases {
+		if got := WebhookTopicEnum(topic); got != want {
+			t.Errorf("WebhookTopicEnum(%q) = %q, want %q", topic, got, want)
+		}
+	}
+}
+
+func TestDiffWebhookSubscriptions(t *testing.T) {
+	url := "https://example.com/app/api/v1/webhook/shopify"
+	topics := []string{"orders/updated", "orders/delete", "app/uninstalled", "shop/update"}
+	subscriptions := []WebhookSubscription{
+		subscription("1", "ORDERS_DELETE", url),
+		subscription("2", "APP_UNINSTALLED", "https://old.example.com/webhook"),
+		subscription("3", "APP_UNINSTALLED", url),
+		subscription("4", "CARTS_UPDATE", url),
+		subscription("5", "ORDERS_UPDATED", "https://old.example.com/webhook"),
+	}
+
+	changes := DiffWebhookSubscriptions(topics, url, subscriptions)
+	got := make(map[string]string, len(changes))
+	for _, change := range changes {
+		id := ""
+		if change.Subscription != nil {
+			id = change.Subscription.Id
+		}
+		got[change.Action+":"+change.Topic+":"+id] = change.Action
+	}
+	want := []string{
+		"delete:APP_UNINSTALLED:2",
+		"delete:CARTS_UPDATE:4",
+		"update:orders/updated:5",
+		"create:shop/update:",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes %v, want %d", len(changes), got, len(want))
+	}
+	for _, key := range want {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing change %s, got %v", key, got)
+		}
+	}
+}
+
+func TestDiffWebhookSubscriptionsInSync(t *testing.T) {
+	url := "https://example.com/app/api/v1/webhook/shopify"
+	subscriptions := []WebhookSubscription{subscription("1", "ORDERS_UPDATED", url)}
+	if changes := DiffWebhookSubscriptions([]string{"orders/updated"}, url, subscriptions); len(changes) != 0 {
+		t.Fatalf("got %d changes, want 0", len(changes))
+	}
+}
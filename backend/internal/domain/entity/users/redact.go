@@ -0,0 +1,12 @@
+package users
+
+import "errors"
+
+var ErrDataRequestNotFound = errors.New("data request not found")
+
+// DataRequestResponse 商家下载顾客数据导出文件
+type DataRequestResponse struct {
+	*RedactHistory
+	Url      string `json:"url"`
+	ExpireAt int64  `json:"expire_at"`
+}
@@ -1,16 +1,44 @@
 package users
 
+import "time"
+
 const (
 	RedactHistoryTable = "redact_history"
 )
 
-// RedactHistory Redact历史记录表(最小化记录)
+// 合规请求类型，和 Shopify 合规 webhook 的 topic 一致
+const (
+	RedactTopicDataRequest = "customers/data_request"
+	RedactTopicCustomer    = "customers/redact"
+	RedactTopicShop        = "shop/redact"
+)
+
+// 合规请求处理状态
+const (
+	RedactStatusPending   = 0 // 处理中
+	RedactStatusCompleted = 1 // 已完成
+)
+
+// RedactDeadline Shopify 要求收到合规 webhook 后 30 天内处理完成
+const RedactDeadline = 30 * 24 * time.Hour
+
+// RedactHistory Redact历史记录表(最小化记录)，不保存顾客邮箱等个人信息
 type RedactHistory struct {
-	Id         int64  `xorm:"pk autoincr 'id' comment('ID')"`
-	AppId      string `xorm:"notnull varchar(50) 'app_id' comment('App标识')"`
-	Shop       string `xorm:"notnull varchar(100) 'shop' comment('Shop域名')"`
-	RedactTime int64  `xorm:"notnull 'redact_time' comment('Redact处理时间')"`
-	CreateTime int64  `xorm:"created notnull 'create_time' comment('创建时间')"`
+	Id         int64  `xorm:"pk autoincr 'id' comment('ID')" json:"id"`
+	AppId      string `xorm:"notnull varchar(50) 'app_id' comment('App标识')" json:"-"`
+	Shop       string `xorm:"notnull varchar(100) 'shop' comment('Shop域名')" json:"shop"`
+	UserId     int64  `xorm:"notnull default 0 'user_id' comment('用户ID')" json:"-"`
+	InboxId    int64  `xorm:"notnull default 0 'inbox_id' comment('webhook 收件箱ID')" json:"-"`
+	Topic      string `xorm:"notnull varchar(50) default '' 'topic' comment('合规请求类型')" json:"topic"`
+	CustomerId int64  `xorm:"notnull default 0 'customer_id' comment('Shopify顾客ID')" json:"customer_id"`
+	RequestId  int64  `xorm:"notnull default 0 'request_id' comment('Shopify数据请求ID')" json:"request_id"`
+	Status     int    `xorm:"notnull tinyint(1) default 0 'status' comment('状态 0处理中 1已完成')" json:"status"`
+	Records    int    `xorm:"notnull default 0 'records' comment('导出或清除的记录数')" json:"records"`
+	FileKey    string `xorm:"notnull varchar(255) default '' 'file_key' comment('数据导出文件')" json:"-"`
+	Deadline   int64  `xorm:"notnull default 0 'deadline' comment('Shopify要求的完成期限')" json:"deadline"`
+	RedactTime int64  `xorm:"notnull 'redact_time' comment('Redact处理时间')" json:"redact_time"`
+	CreateTime int64  `xorm:"created notnull 'create_time' comment('创建时间')" json:"create_time"`
+	UpdateTime int64  `xorm:"updated notnull 'update_time' comment('修改时间')" json:"-"`
 }
 
 func (r *RedactHistory) TableName() string {
@@ -4,6 +4,7 @@ package users
 type UserSubscription struct {
 	ID                     int64   `xorm:"pk autoincr 'id'" json:"id"`
 	UserID                 int64   `xorm:"not null 'user_id'" json:"user_id"`
+	PlanId                 int64   `xorm:"not null default 0 'plan_id'" json:"plan_id"`
 	ShopDomain             string  `xorm:"varchar(100) not null default '' 'shop_domain'" json:"shop_domain"`
 	ChargeID               int64   `xorm:"not null default 0 'charge_id'" json:"charge_id"`
 	SubscriptionName       string  `xorm:"varchar(100) not null default '' 'subscription_name'" json:"subscription_name"`
@@ -29,6 +30,17 @@ func (u *UserSubscription) TableName() string {
 	return "user_subscription"
 }
 
+// DefaultChargeCurrency 订阅没有记录币种时，Shopify 默认按美元扣费
+const DefaultChargeCurrency = "USD"
+
+// ChargeCurrency 用量扣费使用的币种，和订阅上限的币种一致
+func (u *UserSubscription) ChargeCurrency() string {
+	if u.Currency == "" {
+		return DefaultChargeCurrency
+	}
+	return u.Currency
+}
+
 // 订阅状态常量
 const (
 	SubscriptionStatusActive    = "ACTIVE"
@@ -1,5 +1,14 @@
 package users
 
+import (
+	"strings"
+	"time"
+	_ "time/tzdata" // 运行环境可能没有时区数据库，按店铺时区统计时需要
+)
+
+// DefaultTimezone 还没有同步店铺时区时按美东时间统计，和之前的统计口径一致
+const DefaultTimezone = "America/New_York"
+
 // User 用户表
 type User struct {
 	ID              int64  `xorm:"pk autoincr 'id' comment('ID')"`
@@ -9,7 +18,7 @@ type User struct {
 	ShopID          int64  `xorm:"default 0 'shop_id' comment('shopify 店铺 id')"`
 	RealDomain      string `xorm:"varchar(100) default '' 'real_domain' comment('网站真实域名')"`
 	PlanDisplayName string `xorm:"varchar(40) default '' 'plan_display_name' comment('shopify套餐版本')"`
-	AccessToken     string `xorm:"varchar(512) default '' 'access_token' comment('shopify-token')"`
+	AccessToken     string `xorm:"varchar(512) default '' 'access_token' comment('shopify-token，信封加密')"`
 	Password        string `xorm:"varchar(255) default '' 'password' comment('密码')"`
 	Plans           int    `xorm:"int(11) default 0 'plans' comment('app套餐id')"`
 	Email           string `xorm:"varchar(100) default '' 'email' comment('邮箱')"`
@@ -21,6 +30,7 @@ type User struct {
 	TrialTime       int64  `xorm:"default 0 'trial_time' comment('试用时间')"`
 	CurrencyCode    string `xorm:"varchar(10) default '' 'currency_code' comment('货币简码')"`
 	Timezone        int    `xorm:"int(11) default 0 'timezone' comment('The shop's time zone offset expressed as a number of minutes.')"`
+	IanaTimezone    string `xorm:"varchar(64) default '' 'iana_timezone' comment('店铺IANA时区')"`
 	MoneyFormat     string `xorm:"varchar(20) default '' 'money_format' comment('货币单位符号')"`
 	LastLogin       int64  `xorm:"default 0 'last_login' comment('最后登录时间')"`
 	IsDel           int8   `xorm:"tinyint(1) default 0 'is_del' comment('删除状态 0正常 1已删除')"`
@@ -35,3 +45,31 @@ type User struct {
 func (u *User) TableName() string {
 	return "user"
 }
+
+// developmentPlanKeywords 开发店、合作伙伴测试店和员工店的套餐名称关键字，这些店铺只能创建测试订阅
+var developmentPlanKeywords = []string{"develop", "partner", "sandbox", "staff"}
+
+// IsDevelopmentStore 是否为不能真实扣费的开发店铺
+func (u *User) IsDevelopmentStore() bool {
+	planName := strings.ToLower(u.PlanDisplayName)
+	for _, keyword := range developmentPlanKeywords {
+		if strings.Contains(planName, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Location 店铺的时区，没有同步或无法识别时使用 DefaultTimezone
+func (u *User) Location() *time.Location {
+	if u.IanaTimezone != "" {
+		if loc, err := time.LoadLocation(u.IanaTimezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,146 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/settings"
+)
+
+// 报价没有相同价格的变体时的取整方式，对应 UserCartSetting.LadderRounding
+const (
+	RoundUp      = "up"      // 不低于报价的最便宜变体
+	RoundDown    = "down"    // 不高于报价的最贵变体
+	RoundNearest = "nearest" // 最接近报价的变体，距离相同时向上
+)
+
+// MaxVariants Shopify 单个商品最多 100 个变体
+const MaxVariants = 100
+
+var (
+	ErrInvalidLadder  = errors.New("invalid protection variant ladder")
+	ErrLadderTooLarge = fmt.Errorf("protection variant ladder exceeds %d variants", MaxVariants)
+)
+
+// DefaultLadder 没有设置阶梯时的变体价格：1.00 到 100.00，每 1.00 一个变体
+func DefaultLadder() *Ladder {
+	return &Ladder{
+		Min:      decimal.NewFromInt(1),
+		Max:      decimal.NewFromInt(100),
+		Step:     decimal.NewFromInt(1),
+		Rounding: RoundUp,
+	}
+}
+
+// LegacyLadder 按阶梯生成变体之前上传的保险商品使用的固定变体：1.00 起每 1.01 一个，共 100 个。
+// 这些店铺没有设置阶梯时继续使用原来的变体，避免同步时替换掉已经下单的变体
+func LegacyLadder() *Ladder {
+	return &Ladder{
+		Min:      decimal.NewFromInt(1),
+		Max:      decimal.RequireFromString("100.99"),
+		Step:     decimal.RequireFromString("1.01"),
+		Rounding: RoundUp,
+	}
+}
+
+// Ladder 保险商品的变体价格阶梯，设置了 Points 时只按价格点生成变体
+type Ladder struct {
+	Min      decimal.Decimal
+	Max      decimal.Decimal
+	Step     decimal.Decimal
+	Points   []decimal.Decimal
+	Rounding string
+}
+
+// LadderFromSetting 读取购物车设置中的变体阶梯，没有设置时使用 DefaultLadder
+func LadderFromSetting(setting *settings.UserCartSetting) (*Ladder, error) {
+	return applySetting(DefaultLadder(), setting)
+}
+
+// ProductLadder 读取保险商品使用的变体阶梯，没有设置时旧商品使用 LegacyLadder，新商品使用 DefaultLadder
+func ProductLadder(setting *settings.UserCartSetting, legacy bool) (*Ladder, error) {
+	if legacy {
+		return applySetting(LegacyLadder(), setting)
+	}
+	return applySetting(DefaultLadder(), setting)
+}
+
+// applySetting 用购物车设置中的阶梯覆盖 ladder
+func applySetting(ladder *Ladder, setting *settings.UserCartSetting) (*Ladder, error) {
+	if setting == nil {
+		return ladder, nil
+	}
+	if setting.LadderRounding != "" {
+		ladder.Rounding = setting.LadderRounding
+	}
+	if setting.LadderPoints != "" {
+		var points []string
+		if err := json.Unmarshal([]byte(setting.LadderPoints), &points); err != nil {
+			return nil, fmt.Errorf("解析 LadderPoints 失败: %w", err)
+		}
+		for _, point := range points {
+			price, err := decimal.NewFromString(point)
+			if err != nil {
+				return nil, fmt.Errorf("解析 LadderPoints 失败: %w", err)
+			}
+			ladder.Points = append(ladder.Points, price)
+		}
+	}
+	if len(ladder.Points) == 0 && setting.LadderStep > 0 {
+		ladder.Min = decimal.NewFromFloat(setting.LadderMin)
+		ladder.Max = decimal.NewFromFloat(setting.LadderMax)
+		ladder.Step = decimal.NewFromFloat(setting.LadderStep)
+	}
+	return ladder, nil
+}
+
+// Size 阶梯生成的变体数量，价格点重复时按去重前计算
+func (l *Ladder) Size() int64 {
+	if len(l.Points) > 0 {
+		return int64(len(l.Points))
+	}
+	if !l.Step.IsPositive() || l.Max.LessThan(l.Min) {
+		return 0
+	}
+	return l.Max.Sub(l.Min).Div(l.Step).Floor().IntPart() + 1
+}
+
+// Prices 按阶梯生成去重并从低到高排序的变体价格，超过 MaxVariants 时返回 ErrLadderTooLarge
+func (l *Ladder) Prices() ([]decimal.Decimal, error) {
+	if len(l.Points) == 0 && (!l.Min.IsPositive() || !l.Step.IsPositive() || l.Max.LessThan(l.Min)) {
+		return nil, ErrInvalidLadder
+	}
+	if l.Size() > MaxVariants {
+		return nil, ErrLadderTooLarge
+	}
+
+	prices := make([]decimal.Decimal, 0, l.Size())
+	seen := make(map[string]bool)
+	add := func(price decimal.Decimal) {
+		price = price.Round(2)
+		if key := price.StringFixed(2); price.IsPositive() && !seen[key] {
+			seen[key] = true
+			prices = append(prices, price)
+		}
+	}
+	if len(l.Points) > 0 {
+		for _, point := range l.Points {
+			add(point)
+		}
+	} else {
+		for price := l.Min; price.LessThanOrEqual(l.Max); price = price.Add(l.Step) {
+			add(price)
+		}
+	}
+	if len(prices) == 0 {
+		return nil, ErrInvalidLadder
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].LessThan(prices[j])
+	})
+	return prices, nil
+}
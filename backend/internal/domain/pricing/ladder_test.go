@@ -0,0 +1,100 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/settings"
+)
+
+func TestLadderPrices(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting *settings.UserCartSetting
+		first   string
+		last    string
+		count   int
+		err     error
+	}{
+		{name: "default ladder", setting: nil, first: "1.00", last: "100.00", count: 100},
+		{name: "unset ladder uses default", setting: &settings.UserCartSetting{LadderRounding: RoundDown}, first: "1.00", last: "100.00", count: 100},
+		{name: "range includes max", setting: &settings.UserCartSetting{LadderMin: 0.5, LadderMax: 10, LadderStep: 0.5}, first: "0.50", last: "10.00", count: 20},
+		{name: "range stops below max", setting: &settings.UserCartSetting{LadderMin: 1, LadderMax: 10, LadderStep: 4}, first: "1.00", last: "9.00", count: 3},
+		{name: "points are sorted and unique", setting: &settings.UserCartSetting{LadderPoints: `["5","1.5","5.00","2.999"]`, LadderStep: 1}, first: "1.50", last: "5.00", count: 3},
+		{name: "too many variants", setting: &settings.UserCartSetting{LadderMin: 0.01, LadderMax: 2, LadderStep: 0.01}, err: ErrLadderTooLarge},
+		{name: "max below min", setting: &settings.UserCartSetting{LadderMin: 5, LadderMax: 1, LadderStep: 1}, err: ErrInvalidLadder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ladder, err := LadderFromSetting(tt.setting)
+			if err != nil {
+				t.Fatalf("LadderFromSetting() error = %v", err)
+			}
+			prices, err := ladder.Prices()
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Prices() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prices() error = %v", err)
+			}
+			if len(prices) != tt.count {
+				t.Fatalf("len(prices) = %d, want %d", len(prices), tt.count)
+			}
+			if prices[0].StringFixed(2) != tt.first || prices[len(prices)-1].StringFixed(2) != tt.last {
+				t.Errorf("prices = %s..%s, want %s..%s", prices[0].StringFixed(2), prices[len(prices)-1].StringFixed(2), tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestLadderPointsLimit(t *testing.T) {
+	points := make([]decimal.Decimal, 0, MaxVariants+1)
+	for i := 1; i <= MaxVariants+1; i++ {
+		points = append(points, decimal.NewFromInt(int64(i)))
+	}
+	if _, err := (&Ladder{Points: points}).Prices(); !errors.Is(err, ErrLadderTooLarge) {
+		t.Fatalf("Prices() error = %v, want %v", err, ErrLadderTooLarge)
+	}
+	if _, err := LadderFromSetting(&settings.UserCartSetting{LadderPoints: `["1",`}); err == nil || !strings.Contains(err.Error(), "LadderPoints") {
+		t.Fatalf("LadderFromSetting() error = %v, want parse error", err)
+	}
+}
+
+func TestLegacyLadder(t *testing.T) {
+	// 按阶梯生成变体之前上传商品时的变体价格
+	want := make([]string, 0, MaxVariants)
+	price := 1.0
+	for i := 0; i < MaxVariants; i++ {
+		want = append(want, fmt.Sprintf("%.2f", price))
+		price += 1.01
+	}
+
+	ladder, err := ProductLadder(&settings.UserCartSetting{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices, err := ladder.Prices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("len(prices) = %d, want %d", len(prices), len(want))
+	}
+	for i, p := range prices {
+		if p.StringFixed(2) != want[i] {
+			t.Fatalf("prices[%d] = %s, want %s", i, p.StringFixed(2), want[i])
+		}
+	}
+
+	ladder, _ = ProductLadder(nil, false)
+	if prices, _ := ladder.Prices(); prices[1].StringFixed(2) != "2.00" {
+		t.Errorf("new product ladder = %s, want default ladder", prices[1].StringFixed(2))
+	}
+}
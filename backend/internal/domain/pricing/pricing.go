@@ -0,0 +1,187 @@
+// Package pricing 按购物车设置计算保险价格，购物车插件报价和订单抽成共用同一套规则
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/settings"
+	"backend/pkg/utils"
+)
+
+// 计价方式，对应 UserCartSetting.PricingType
+const (
+	TypePrice      = 0 // 固定金额
+	TypePercentage = 1 // 购物车金额的百分比
+)
+
+// 计价规则，对应 UserCartSetting.PricingRule
+const (
+	RuleAll   = 0 // 所有订单统一设置
+	RuleRange = 1 // 按购物车金额区间单独设置
+)
+
+// 报价命中的规则来源
+const (
+	SourceAll      = "all"       // 统一设置
+	SourceRange    = "range"     // 命中金额区间
+	SourceOutRange = "out_range" // 不在任何区间内，使用范围外设置
+)
+
+var ErrNoPrice = errors.New("no protection price for cart subtotal")
+
+// Range 金额区间 [Min, Max)，Max 为 0 表示没有上限
+type Range struct {
+	Min   decimal.Decimal `json:"min"`
+	Max   decimal.Decimal `json:"max"`
+	Value decimal.Decimal `json:"value"` // 固定金额或百分比
+}
+
+// Contains 区间是否包含 amount，相邻区间的边界只属于后一个区间
+func (r Range) Contains(amount decimal.Decimal) bool {
+	if amount.LessThan(r.Min) {
+		return false
+	}
+	return r.Max.IsZero() || amount.LessThan(r.Max)
+}
+
+// Quote 报价结果
+type Quote struct {
+	Subtotal    decimal.Decimal `json:"subtotal"`     // 购物车金额
+	Price       decimal.Decimal `json:"price"`        // 保险价格
+	PricingType int             `json:"pricing_type"` // 计价方式
+	Source      string          `json:"source"`       // 命中的规则来源
+	Value       decimal.Decimal `json:"value"`        // 命中规则的固定金额或百分比
+	Range       *Range          `json:"range"`        // 命中的金额区间，其他来源为空
+}
+
+// Rate 百分比计价时的比例，固定金额计价时为 0
+func (q *Quote) Rate() decimal.Decimal {
+	if q.PricingType != TypePercentage {
+		return decimal.Zero
+	}
+	return q.Value.Div(decimal.NewFromInt(100))
+}
+
+// Evaluate 按购物车设置计算购物车金额对应的保险价格，价格为 0 时返回 ErrNoPrice
+func Evaluate(setting *settings.UserCartSetting, subtotal decimal.Decimal) (*Quote, error) {
+	quote := &Quote{Subtotal: subtotal, PricingType: setting.PricingType}
+	if setting.PricingRule == RuleAll {
+		quote.Source = SourceAll
+		quote.Value = pick(setting.PricingType, setting.AllPriceSet, setting.AllTiersSet)
+	} else {
+		ranges, err := Ranges(setting)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ranges {
+			if ranges[i].Contains(subtotal) {
+				quote.Source = SourceRange
+				quote.Value = ranges[i].Value
+				quote.Range = &ranges[i]
+				break
+			}
+		}
+		if quote.Range == nil {
+			quote.Source = SourceOutRange
+			quote.Value = pick(setting.PricingType, setting.OutSelectPrice, setting.OutSelectTier)
+		}
+	}
+
+	quote.Price = quote.Value
+	if setting.PricingType == TypePercentage {
+		quote.Price = subtotal.Mul(quote.Rate())
+	}
+	quote.Price = quote.Price.Round(2)
+	if !quote.Price.IsPositive() {
+		return nil, ErrNoPrice
+	}
+	return quote, nil
+}
+
+// Ranges 解析当前计价方式的金额区间，按最小金额排序
+func Ranges(setting *settings.UserCartSetting) ([]Range, error) {
+	var ranges []Range
+	if setting.PricingType == TypePercentage {
+		var tiers []settings.TierSelectReq
+		if err := json.Unmarshal([]byte(setting.TiersSelect), &tiers); err != nil {
+			return nil, fmt.Errorf("解析 TiersSelect 失败: %w", err)
+		}
+		for _, tier := range tiers {
+			ranges = append(ranges, Range{
+				Min:   utils.ParseMoneyDecimal(tier.Min),
+				Max:   utils.ParseMoneyDecimal(tier.Max),
+				Value: utils.ParseMoneyDecimal(tier.Percentage),
+			})
+		}
+	} else {
+		var prices []settings.PriceSelectReq
+		if err := json.Unmarshal([]byte(setting.PricingSelect), &prices); err != nil {
+			return nil, fmt.Errorf("解析 PricingSelect 失败: %w", err)
+		}
+		for _, price := range prices {
+			ranges = append(ranges, Range{
+				Min:   utils.ParseMoneyDecimal(price.Min),
+				Max:   utils.ParseMoneyDecimal(price.Max),
+				Value: utils.ParseMoneyDecimal(price.Price),
+			})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Min.LessThan(ranges[j].Min)
+	})
+	return ranges, nil
+}
+
+func pick(pricingType int, price float64, percentage float64) decimal.Decimal {
+	if pricingType == TypePercentage {
+		return decimal.NewFromFloat(percentage)
+	}
+	return decimal.NewFromFloat(price)
+}
+
+// Variant 保险商品中用来收取报价的变体
+type Variant struct {
+	VariantId int64           `json:"variant_id"`
+	Price     decimal.Decimal `json:"price"`
+}
+
+// SelectVariant 按取整方式选择收取报价的变体，variants 为变体价格到变体ID的映射，
+// 向上取整时选择不低于报价的最便宜变体，向下取整时选择不高于报价的最贵变体，没有合适的变体时返回 false
+func SelectVariant(price decimal.Decimal, variants map[string]int64, rounding string) (*Variant, bool) {
+	var selected *Variant
+	for key, variantId := range variants {
+		variantPrice, err := decimal.NewFromString(key)
+		if err != nil {
+			continue
+		}
+		var better bool
+		switch rounding {
+		case RoundDown:
+			if variantPrice.GreaterThan(price) {
+				continue
+			}
+			better = selected == nil || variantPrice.GreaterThan(selected.Price)
+		case RoundNearest:
+			if selected == nil {
+				better = true
+				break
+			}
+			distance, selectedDistance := variantPrice.Sub(price).Abs(), selected.Price.Sub(price).Abs()
+			better = distance.LessThan(selectedDistance) || (distance.Equal(selectedDistance) && variantPrice.GreaterThan(selected.Price))
+		default:
+			if variantPrice.LessThan(price) {
+				continue
+			}
+			better = selected == nil || variantPrice.LessThan(selected.Price)
+		}
+		if better {
+			selected = &Variant{VariantId: variantId, Price: variantPrice}
+		}
+	}
+	return selected, selected != nil
+}
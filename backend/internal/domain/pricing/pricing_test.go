@@ -0,0 +1,163 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/settings"
+)
+
+func TestEvaluate(t *testing.T) {
+	priceRanges := `[{"min":"50","max":"100","price":"2.50"},{"min":"0","max":"50","price":"1.00"},{"min":"100","max":"0","price":"5.00"}]`
+	tierRanges := `[{"min":"0","max":"100","percentage":"2"},{"min":"100","max":"200","percentage":"1.5"}]`
+
+	tests := []struct {
+		name     string
+		setting  settings.UserCartSetting
+		subtotal string
+		price    string
+		source   string
+		err      error
+	}{
+		{
+			name:     "all price",
+			setting:  settings.UserCartSetting{PricingRule: RuleAll, PricingType: TypePrice, AllPriceSet: 1.99},
+			subtotal: "30",
+			price:    "1.99",
+			source:   SourceAll,
+		},
+		{
+			name:     "all percentage rounds to cents",
+			setting:  settings.UserCartSetting{PricingRule: RuleAll, PricingType: TypePercentage, AllTiersSet: 3},
+			subtotal: "33.33",
+			price:    "1",
+			source:   SourceAll,
+		},
+		{
+			name:     "range lower bound is inclusive",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: priceRanges},
+			subtotal: "0",
+			price:    "1",
+			source:   SourceRange,
+		},
+		{
+			name:     "range upper bound belongs to next range",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: priceRanges},
+			subtotal: "50",
+			price:    "2.5",
+			source:   SourceRange,
+		},
+		{
+			name:     "just below upper bound",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: priceRanges},
+			subtotal: "99.99",
+			price:    "2.5",
+			source:   SourceRange,
+		},
+		{
+			name:     "zero max is unbounded",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: priceRanges},
+			subtotal: "100000",
+			price:    "5",
+			source:   SourceRange,
+		},
+		{
+			name:     "tier percentage",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePercentage, TiersSelect: tierRanges},
+			subtotal: "150",
+			price:    "2.25",
+			source:   SourceRange,
+		},
+		{
+			name:     "tier out of range uses out tier",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePercentage, TiersSelect: tierRanges, OutSelectTier: 1},
+			subtotal: "200",
+			price:    "2",
+			source:   SourceOutRange,
+		},
+		{
+			name:     "price out of range uses out price",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: `[{"min":"10","max":"20","price":"1"}]`, OutSelectPrice: 3},
+			subtotal: "5",
+			price:    "3",
+			source:   SourceOutRange,
+		},
+		{
+			name:     "out of range without fallback",
+			setting:  settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: `[{"min":"10","max":"20","price":"1"}]`},
+			subtotal: "20",
+			err:      ErrNoPrice,
+		},
+		{
+			name:     "zero subtotal percentage",
+			setting:  settings.UserCartSetting{PricingRule: RuleAll, PricingType: TypePercentage, AllTiersSet: 2},
+			subtotal: "0",
+			err:      ErrNoPrice,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := Evaluate(&tt.setting, decimal.RequireFromString(tt.subtotal))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Evaluate() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if !quote.Price.Equal(decimal.RequireFromString(tt.price)) {
+				t.Errorf("price = %s, want %s", quote.Price, tt.price)
+			}
+			if quote.Source != tt.source {
+				t.Errorf("source = %s, want %s", quote.Source, tt.source)
+			}
+			if (quote.Source == SourceRange) != (quote.Range != nil) {
+				t.Errorf("range = %v for source %s", quote.Range, quote.Source)
+			}
+		})
+	}
+}
+
+func TestEvaluateInvalidRules(t *testing.T) {
+	setting := settings.UserCartSetting{PricingRule: RuleRange, PricingType: TypePrice, PricingSelect: "not json"}
+	if _, err := Evaluate(&setting, decimal.NewFromInt(10)); err == nil {
+		t.Fatal("Evaluate() error = nil, want parse error")
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	variants := map[string]int64{"1.00": 1, "2.01": 2, "3.02": 3, "bad": 4}
+	tests := []struct {
+		price     string
+		rounding  string
+		variantId int64
+		ok        bool
+	}{
+		{price: "0.50", rounding: RoundUp, variantId: 1, ok: true},
+		{price: "1.00", rounding: RoundUp, variantId: 1, ok: true},
+		{price: "1.01", rounding: RoundUp, variantId: 2, ok: true},
+		{price: "3.02", rounding: RoundUp, variantId: 3, ok: true},
+		{price: "3.03", rounding: RoundUp, ok: false},
+		{price: "1.01", rounding: "", variantId: 2, ok: true},
+		{price: "0.50", rounding: RoundDown, ok: false},
+		{price: "2.50", rounding: RoundDown, variantId: 2, ok: true},
+		{price: "9.00", rounding: RoundDown, variantId: 3, ok: true},
+		{price: "1.40", rounding: RoundNearest, variantId: 1, ok: true},
+		{price: "1.60", rounding: RoundNearest, variantId: 2, ok: true},
+		{price: "1.505", rounding: RoundNearest, variantId: 2, ok: true},
+		{price: "0.10", rounding: RoundNearest, variantId: 1, ok: true},
+	}
+	for _, tt := range tests {
+		variant, ok := SelectVariant(decimal.RequireFromString(tt.price), variants, tt.rounding)
+		if ok != tt.ok {
+			t.Fatalf("SelectVariant(%s, %q) ok = %v, want %v", tt.price, tt.rounding, ok, tt.ok)
+		}
+		if ok && variant.VariantId != tt.variantId {
+			t.Errorf("SelectVariant(%s, %q) = %d, want %d", tt.price, tt.rounding, variant.VariantId, tt.variantId)
+		}
+	}
+}
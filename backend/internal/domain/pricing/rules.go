@@ -0,0 +1,364 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"backend/internal/domain/entity/settings"
+)
+
+// 规则校验的错误和提醒原因
+const (
+	ReasonInvalidNumber  = "invalid_number"   // 不是有效金额
+	ReasonInvalidValue   = "invalid_value"    // 价格必须大于 0，百分比必须在 (0, 100] 之间
+	ReasonEmptyRange     = "empty_range"      // 最小金额不小于最大金额
+	ReasonOverlap        = "overlap"          // 与前一个区间重叠
+	ReasonGap            = "gap"              // 与前一个区间之间有空缺
+	ReasonUnboundedRange = "unbounded_range"  // 没有上限的区间不是最后一个
+	ReasonNoRanges       = "no_ranges"        // 按区间计价但没有设置区间
+	ReasonNoVariant      = "no_variant"       // 按取整方式没有能收取该价格的变体
+	ReasonVariantRounded = "variant_rounded"  // 没有相同价格的变体，客户按取整后的变体支付
+	ReasonUnboundedPrice = "unbounded_price"  // 百分比没有上限，大额购物车的价格会超过最贵的变体
+	ReasonLadderTooLarge = "ladder_too_large" // 变体阶梯超过 Shopify 的变体数量上限
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// RuleIssue 规则的字段级错误或提醒，Field 使用请求中的字段名和下标
+type RuleIssue struct {
+	Field   string `json:"field"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// RuleCheck 价格规则校验结果，PriceSelect/TiersSelect 为按最小金额排序并格式化后的规则，
+// LadderPrices 为变体阶梯生成的变体价格
+type RuleCheck struct {
+	Errors       []RuleIssue               `json:"errors"`
+	Warnings     []RuleIssue               `json:"warnings"`
+	PriceSelect  []settings.PriceSelectReq `json:"price_select"`
+	TiersSelect  []settings.TierSelectReq  `json:"tiers_select"`
+	LadderPrices []string                  `json:"ladder_prices"`
+	Ladder       *Ladder                   `json:"-"`
+}
+
+// Valid 是否没有错误，提醒不影响保存
+func (c *RuleCheck) Valid() bool {
+	return len(c.Errors) == 0
+}
+
+// RuleError 规则校验不通过，保存失败时返回给前端逐个字段提示
+type RuleError struct {
+	Check *RuleCheck
+}
+
+func (e *RuleError) Error() string {
+	messages := make([]string, 0, len(e.Check.Errors))
+	for _, issue := range e.Check.Errors {
+		messages = append(messages, issue.Field+": "+issue.Message)
+	}
+	return "invalid pricing rules: " + strings.Join(messages, "; ")
+}
+
+func (c *RuleCheck) fail(field string, reason string, format string, args ...interface{}) {
+	c.Errors = append(c.Errors, RuleIssue{Field: field, Reason: reason, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *RuleCheck) warn(field string, reason string, format string, args ...interface{}) {
+	c.Warnings = append(c.Warnings, RuleIssue{Field: field, Reason: reason, Message: fmt.Sprintf(format, args...)})
+}
+
+// indexedRange 区间和它在请求中的下标，排序后仍能定位到原字段
+type indexedRange struct {
+	Range
+	field string
+}
+
+// CheckRules 校验变体阶梯和当前计价方式的规则：金额格式、区间重叠和空缺、排序，
+// 保存后保险商品会按阶梯重新同步变体，所以按阶梯生成的变体检查价格能否收取
+// base 是请求没有设置阶梯时使用的阶梯，为 nil 时使用 DefaultLadder
+func CheckRules(req *settings.SettingConfigReq, base *Ladder) *RuleCheck {
+	check := &RuleCheck{
+		Errors:       make([]RuleIssue, 0),
+		Warnings:     make([]RuleIssue, 0),
+		PriceSelect:  req.PriceSelect,
+		TiersSelect:  req.TiersSelect,
+		LadderPrices: make([]string, 0),
+	}
+	variants := check.ladder(req, base)
+
+	if req.PricingRule == RuleAll {
+		if req.PricingType == TypePercentage {
+			if value, ok := check.number("allTiers", req.AllTiers); ok {
+				check.percentage("allTiers", value)
+			}
+		} else if value, ok := check.number("allPrice", req.AllPrice); ok && check.price("allPrice", value) {
+			check.variant("allPrice", value, variants)
+		}
+		return check
+	}
+
+	var ranges []indexedRange
+	if req.PricingType == TypePercentage {
+		ranges = check.tierRanges(req.TiersSelect)
+	} else {
+		ranges = check.priceRanges(req.PriceSelect, variants)
+	}
+	check.sequence(ranges)
+	check.outOfRange(req, variants)
+	if req.PricingType == TypePercentage {
+		check.tierVariants(ranges, variants)
+	}
+
+	if check.Valid() {
+		sort.SliceStable(ranges, func(i, j int) bool {
+			return ranges[i].Min.LessThan(ranges[j].Min)
+		})
+		if req.PricingType == TypePercentage {
+			check.TiersSelect = make([]settings.TierSelectReq, 0, len(ranges))
+			for _, r := range ranges {
+				check.TiersSelect = append(check.TiersSelect, settings.TierSelectReq{
+					Min: r.Min.StringFixed(2), Max: r.Max.StringFixed(2), Percentage: r.Value.StringFixed(2),
+				})
+			}
+		} else {
+			check.PriceSelect = make([]settings.PriceSelectReq, 0, len(ranges))
+			for _, r := range ranges {
+				check.PriceSelect = append(check.PriceSelect, settings.PriceSelectReq{
+					Min: r.Min.StringFixed(2), Max: r.Max.StringFixed(2), Price: r.Value.StringFixed(2),
+				})
+			}
+		}
+	}
+	return check
+}
+
+func (c *RuleCheck) priceRanges(prices []settings.PriceSelectReq, variants map[string]int64) []indexedRange {
+	ranges := make([]indexedRange, 0, len(prices))
+	for i, price := range prices {
+		field := fmt.Sprintf("priceSelect[%d]", i)
+		r, ok := c.bounds(field, price.Min, price.Max)
+		value, valueOk := c.number(field+".price", price.Price)
+		if valueOk {
+			valueOk = c.price(field+".price", value)
+		}
+		if !ok || !valueOk {
+			continue
+		}
+		r.Value = value
+		c.variant(field+".price", value, variants)
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+func (c *RuleCheck) tierRanges(tiers []settings.TierSelectReq) []indexedRange {
+	ranges := make([]indexedRange, 0, len(tiers))
+	for i, tier := range tiers {
+		field := fmt.Sprintf("tiersSelect[%d]", i)
+		r, ok := c.bounds(field, tier.Min, tier.Max)
+		value, valueOk := c.number(field+".percentage", tier.Percentage)
+		if valueOk {
+			valueOk = c.percentage(field+".percentage", value)
+		}
+		if !ok || !valueOk {
+			continue
+		}
+		r.Value = value
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// bounds 解析区间的最小和最大金额，最大金额为 0 表示没有上限
+func (c *RuleCheck) bounds(field string, minValue string, maxValue string) (indexedRange, bool) {
+	r := indexedRange{field: field}
+	var minOk, maxOk bool
+	r.Min, minOk = c.number(field+".min", minValue)
+	r.Max, maxOk = c.number(field+".max", maxValue)
+	if !minOk || !maxOk {
+		return r, false
+	}
+	if !r.Max.IsZero() && r.Min.GreaterThanOrEqual(r.Max) {
+		c.fail(field+".min", ReasonEmptyRange, "min %s must be less than max %s", r.Min.StringFixed(2), r.Max.StringFixed(2))
+		return r, false
+	}
+	return r, true
+}
+
+// sequence 按最小金额排序后检查相邻区间，区间必须首尾相接，只有最后一个区间可以没有上限
+func (c *RuleCheck) sequence(ranges []indexedRange) {
+	if len(ranges) == 0 {
+		if len(c.Errors) == 0 {
+			c.fail("ranges", ReasonNoRanges, "at least one range is required")
+		}
+		return
+	}
+	sorted := make([]indexedRange, len(ranges))
+	copy(sorted, ranges)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Min.LessThan(sorted[j].Min)
+	})
+
+	if sorted[0].Min.IsPositive() {
+		c.warn(sorted[0].field+".min", ReasonGap, "carts below %s use the out-of-range setting", sorted[0].Min.StringFixed(2))
+	}
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		switch {
+		case prev.Max.IsZero():
+			c.fail(prev.field+".max", ReasonUnboundedRange, "only the last range can have no max")
+		case cur.Min.LessThan(prev.Max):
+			c.fail(cur.field+".min", ReasonOverlap, "min %s overlaps %s which ends at %s", cur.Min.StringFixed(2), prev.field, prev.Max.StringFixed(2))
+		case cur.Min.GreaterThan(prev.Max):
+			c.fail(cur.field+".min", ReasonGap, "min %s leaves a gap after %s which ends at %s", cur.Min.StringFixed(2), prev.field, prev.Max.StringFixed(2))
+		}
+	}
+}
+
+// outOfRange 范围外设置可以为 0，表示不在区间内的购物车不提供保险
+func (c *RuleCheck) outOfRange(req *settings.SettingConfigReq, variants map[string]int64) {
+	if req.PricingType == TypePercentage {
+		if value, ok := c.number("outTier", req.OutTier); ok && !value.IsZero() {
+			c.percentage("outTier", value)
+		}
+		return
+	}
+	if value, ok := c.number("outPrice", req.OutPrice); ok && !value.IsZero() {
+		if c.price("outPrice", value) {
+			c.variant("outPrice", value, variants)
+		}
+	}
+}
+
+// tierVariants 百分比计价时检查每个区间的最高价格能否由阶梯中的变体收取
+func (c *RuleCheck) tierVariants(ranges []indexedRange, variants map[string]int64) {
+	if len(variants) == 0 {
+		return
+	}
+	for _, r := range ranges {
+		if r.Max.IsZero() {
+			c.warn(r.field+".max", ReasonUnboundedPrice, "prices above the most expensive protection variant can't be charged")
+			continue
+		}
+		highest := r.Max.Mul(r.Value).Div(hundred).Round(2)
+		if _, ok := SelectVariant(highest, variants, c.Ladder.Rounding); !ok {
+			c.warn(r.field+".percentage", ReasonNoVariant, "no protection variant can charge price %s at max %s", highest.StringFixed(2), r.Max.StringFixed(2))
+		}
+	}
+}
+
+func (c *RuleCheck) number(field string, value string) (decimal.Decimal, bool) {
+	number, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil || number.IsNegative() {
+		c.fail(field, ReasonInvalidNumber, "%q is not a valid amount", value)
+		return decimal.Zero, false
+	}
+	return number, true
+}
+
+func (c *RuleCheck) price(field string, value decimal.Decimal) bool {
+	if !value.IsPositive() {
+		c.fail(field, ReasonInvalidValue, "price must be greater than 0")
+		return false
+	}
+	return true
+}
+
+func (c *RuleCheck) percentage(field string, value decimal.Decimal) bool {
+	if !value.IsPositive() || value.GreaterThan(hundred) {
+		c.fail(field, ReasonInvalidValue, "percentage must be greater than 0 and at most 100")
+		return false
+	}
+	return true
+}
+
+// variant 固定价格没有对应变体时提醒，变体阶梯无效时不检查
+func (c *RuleCheck) variant(field string, price decimal.Decimal, variants map[string]int64) {
+	if len(variants) == 0 {
+		return
+	}
+	variant, ok := SelectVariant(price, variants, c.Ladder.Rounding)
+	if !ok {
+		c.warn(field, ReasonNoVariant, "no protection variant can charge price %s", price.StringFixed(2))
+		return
+	}
+	if !variant.Price.Equal(price) {
+		c.warn(field, ReasonVariantRounded, "price %s is charged as %s by the closest protection variant", price.StringFixed(2), variant.Price.StringFixed(2))
+	}
+}
+
+// ladder 校验变体阶梯，有价格点时只按价格点生成，否则按最低价格、最高价格和步长生成，都没有设置时使用默认阶梯，
+// 返回阶梯价格到变体ID的映射，阶梯无效时返回 nil
+func (c *RuleCheck) ladder(req *settings.SettingConfigReq, base *Ladder) map[string]int64 {
+	ladder := DefaultLadder()
+	if base != nil {
+		copied := *base
+		ladder = &copied
+	}
+	switch req.LadderRounding {
+	case "":
+	case RoundUp, RoundDown, RoundNearest:
+		ladder.Rounding = req.LadderRounding
+	default:
+		c.fail("ladderRounding", ReasonInvalidValue, "rounding must be one of up, down, nearest")
+	}
+
+	field := "ladderStep"
+	ok := true
+	if len(req.LadderPoints) > 0 {
+		field = "ladderPoints"
+		ladder.Points = make([]decimal.Decimal, 0, len(req.LadderPoints))
+		for i, point := range req.LadderPoints {
+			name := fmt.Sprintf("ladderPoints[%d]", i)
+			value, valueOk := c.number(name, point)
+			if valueOk {
+				valueOk = c.price(name, value.Round(2))
+			}
+			ok = ok && valueOk
+			ladder.Points = append(ladder.Points, value.Round(2))
+		}
+	} else if req.LadderMin != "" || req.LadderMax != "" || req.LadderStep != "" {
+		var minOk, maxOk, stepOk bool
+		ladder.Min, minOk = c.number("ladderMin", req.LadderMin)
+		ladder.Max, maxOk = c.number("ladderMax", req.LadderMax)
+		ladder.Step, stepOk = c.number("ladderStep", req.LadderStep)
+		ladder.Min, ladder.Max, ladder.Step = ladder.Min.Round(2), ladder.Max.Round(2), ladder.Step.Round(2)
+		if minOk {
+			minOk = c.price("ladderMin", ladder.Min)
+		}
+		if stepOk && !ladder.Step.IsPositive() {
+			c.fail("ladderStep", ReasonInvalidValue, "step must be at least 0.01")
+			stepOk = false
+		}
+		if minOk && maxOk && ladder.Max.LessThan(ladder.Min) {
+			c.fail("ladderMin", ReasonEmptyRange, "min %s must not be greater than max %s", ladder.Min.StringFixed(2), ladder.Max.StringFixed(2))
+			maxOk = false
+		}
+		ok = minOk && maxOk && stepOk
+	}
+	if !ok {
+		return nil
+	}
+
+	prices, err := ladder.Prices()
+	if errors.Is(err, ErrLadderTooLarge) {
+		c.fail(field, ReasonLadderTooLarge, "the ladder generates %d variants, Shopify allows at most %d", ladder.Size(), MaxVariants)
+		return nil
+	}
+	if err != nil {
+		c.fail(field, ReasonInvalidValue, "the ladder generates no variants")
+		return nil
+	}
+	c.Ladder = ladder
+	variants := make(map[string]int64, len(prices))
+	for _, price := range prices {
+		c.LadderPrices = append(c.LadderPrices, price.StringFixed(2))
+		variants[price.StringFixed(2)] = 0
+	}
+	return variants
+}
@@ -0,0 +1,173 @@
+package pricing
+
+import (
+	"testing"
+
+	"backend/internal/domain/entity/settings"
+)
+
+func TestCheckRules(t *testing.T) {
+	ladder := []string{"1", "2", "5"}
+
+	tests := []struct {
+		name     string
+		req      settings.SettingConfigReq
+		errors   []string // field:reason
+		warnings []string
+	}{
+		{
+			name: "sorted contiguous ranges",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "50", Max: "0", Price: "2"},
+				{Min: "0", Max: "50", Price: "1"},
+			}},
+		},
+		{
+			name: "overlap",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "0", Max: "60", Price: "1"},
+				{Min: "50", Max: "100", Price: "2"},
+			}},
+			errors: []string{"priceSelect[1].min:" + ReasonOverlap},
+		},
+		{
+			name: "gap",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "0", Max: "50", Price: "1"},
+				{Min: "60", Max: "100", Price: "2"},
+			}},
+			errors: []string{"priceSelect[1].min:" + ReasonGap},
+		},
+		{
+			name: "min greater than max",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "80", Max: "50", Price: "1"},
+			}},
+			errors: []string{"priceSelect[0].min:" + ReasonEmptyRange},
+		},
+		{
+			name: "unbounded range not last",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "0", Max: "0", Price: "1"},
+				{Min: "50", Max: "100", Price: "2"},
+			}},
+			errors: []string{"priceSelect[0].max:" + ReasonUnboundedRange},
+		},
+		{
+			name: "invalid number and zero price",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "abc", Max: "50", Price: "1"},
+				{Min: "50", Max: "0", Price: "0"},
+			}},
+			errors: []string{"priceSelect[0].min:" + ReasonInvalidNumber, "priceSelect[1].price:" + ReasonInvalidValue},
+		},
+		{
+			name: "price above variants and rounded price",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+				{Min: "0", Max: "50", Price: "1.50"},
+				{Min: "50", Max: "0", Price: "8"},
+			}},
+			warnings: []string{"priceSelect[0].price:" + ReasonVariantRounded, "priceSelect[1].price:" + ReasonNoVariant},
+		},
+		{
+			name: "first range above zero",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "1", PriceSelect: []settings.PriceSelectReq{
+				{Min: "10", Max: "0", Price: "2"},
+			}},
+			warnings: []string{"priceSelect[0].min:" + ReasonGap},
+		},
+		{
+			name: "tier percentage over 100 and unbounded",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePercentage, OutTier: "0", TiersSelect: []settings.TierSelectReq{
+				{Min: "0", Max: "100", Percentage: "2"},
+				{Min: "100", Max: "0", Percentage: "120"},
+			}},
+			errors: []string{"tiersSelect[1].percentage:" + ReasonInvalidValue},
+		},
+		{
+			name: "tier price above variants",
+			req: settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePercentage, OutTier: "0", TiersSelect: []settings.TierSelectReq{
+				{Min: "0", Max: "100", Percentage: "2"},
+				{Min: "100", Max: "0", Percentage: "2"},
+			}},
+			warnings: []string{"tiersSelect[1].max:" + ReasonUnboundedPrice},
+		},
+		{
+			name:   "no ranges",
+			req:    settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0"},
+			errors: []string{"ranges:" + ReasonNoRanges},
+		},
+		{
+			name:   "all price must be positive",
+			req:    settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "0"},
+			errors: []string{"allPrice:" + ReasonInvalidValue},
+		},
+		{
+			name:     "rounding down below cheapest variant",
+			req:      settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "0.50", LadderRounding: RoundDown},
+			warnings: []string{"allPrice:" + ReasonNoVariant},
+		},
+		{
+			name:     "ladder from range",
+			req:      settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "2.10", LadderMin: "0.5", LadderMax: "5", LadderStep: "0.25"},
+			warnings: []string{"allPrice:" + ReasonVariantRounded},
+		},
+		{
+			name:   "ladder too large",
+			req:    settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "2", LadderMin: "1", LadderMax: "200", LadderStep: "1"},
+			errors: []string{"ladderStep:" + ReasonLadderTooLarge},
+		},
+		{
+			name:   "ladder min above max and zero step",
+			req:    settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "2", LadderMin: "10", LadderMax: "5", LadderStep: "0.001"},
+			errors: []string{"ladderStep:" + ReasonInvalidValue, "ladderMin:" + ReasonEmptyRange},
+		},
+		{
+			name:   "ladder point must be positive",
+			req:    settings.SettingConfigReq{PricingRule: RuleAll, PricingType: TypePrice, AllPrice: "2", LadderPoints: []string{"2", "0"}},
+			errors: []string{"ladderPoints[1]:" + ReasonInvalidValue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.req.LadderPoints == nil && tt.req.LadderStep == "" {
+				tt.req.LadderPoints = ladder
+			}
+			check := CheckRules(&tt.req, nil)
+			assertIssues(t, "errors", check.Errors, tt.errors)
+			assertIssues(t, "warnings", check.Warnings, tt.warnings)
+		})
+	}
+}
+
+func TestCheckRulesNormalizes(t *testing.T) {
+	req := settings.SettingConfigReq{PricingRule: RuleRange, PricingType: TypePrice, OutPrice: "0", PriceSelect: []settings.PriceSelectReq{
+		{Min: "50", Max: "0", Price: "2"},
+		{Min: " 0 ", Max: "50", Price: "1"},
+	}}
+	check := CheckRules(&req, nil)
+	if !check.Valid() {
+		t.Fatalf("errors = %v", check.Errors)
+	}
+	want := []settings.PriceSelectReq{{Min: "0.00", Max: "50.00", Price: "1.00"}, {Min: "50.00", Max: "0.00", Price: "2.00"}}
+	if len(check.PriceSelect) != len(want) {
+		t.Fatalf("PriceSelect = %v", check.PriceSelect)
+	}
+	for i := range want {
+		if check.PriceSelect[i] != want[i] {
+			t.Errorf("PriceSelect[%d] = %v, want %v", i, check.PriceSelect[i], want[i])
+		}
+	}
+}
+
+func assertIssues(t *testing.T, kind string, got []RuleIssue, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", kind, got, want)
+	}
+	for i, issue := range got {
+		if issue.Field+":"+issue.Reason != want[i] {
+			t.Errorf("%s[%d] = %s:%s, want %s", kind, i, issue.Field, issue.Reason, want[i])
+		}
+	}
+}
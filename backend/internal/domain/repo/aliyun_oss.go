@@ -3,8 +3,17 @@ package repo
 import (
 	"context"
 	"mime/multipart"
+	"time"
 )
 
 type AliyunOSSRepository interface {
 	UploadFile(ctx context.Context, fileName string, file *multipart.FileHeader) (string, error)
+	// PutObject 上传生成的文件内容
+	PutObject(ctx context.Context, objectKey string, data []byte, contentType string) error
+	// SignURL 生成有时效的下载链接
+	SignURL(ctx context.Context, objectKey string, expires time.Duration) (string, error)
+	// ObjectURL 文件的公开访问地址，和 UploadFile 返回的地址一致
+	ObjectURL(objectKey string) string
+	// DeleteObject 删除文件，文件不存在时不返回错误
+	DeleteObject(ctx context.Context, objectKey string) error
 }
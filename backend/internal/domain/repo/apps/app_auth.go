@@ -11,4 +11,6 @@ type AppAuthRepository interface {
 	GetByUserAndApp(ctx context.Context, userId int64, appId string, columns ...string) (*apps.UserAppAuth, error)
 	Create(ctx context.Context, user *apps.UserAppAuth) (int64, error)
 	Update(ctx context.Context, appAuth *apps.UserAppAuth) error
+	// RotateTokens 用最新密钥重新加密 token，返回本批最后一条记录的 id 和重新加密的数量
+	RotateTokens(ctx context.Context, cursorID int64, limit int) (int64, int, error)
 }
@@ -11,6 +11,25 @@ type BillingPeriodSummaryRepository interface {
 	BillingPeriodSummary(ctx context.Context, userID int64, pagination entity.Pagination) ([]*billings.BillingPeriodSummary, error)
 	BillingPeriodCount(ctx context.Context, userID int64) (int64, error)
 	CreateBillingPeriodSummary(ctx context.Context, period *billings.BillingPeriodSummary) (int64, error)
+	// UpdateBillingPeriodSummary 按 period.Version 做乐观锁更新，版本不一致时返回 ErrSummaryVersionConflict
 	UpdateBillingPeriodSummary(ctx context.Context, period *billings.BillingPeriodSummary) error
+	// EnsurePeriodSummary 按唯一键 (user_id, subscription_id, bill_cycle) 查询账期汇总，不存在时创建，并发创建时返回已存在的那条
+	EnsurePeriodSummary(ctx context.Context, period *billings.BillingPeriodSummary) (*billings.BillingPeriodSummary, error)
+	// AddRecognizedBill 把确认的账单原子累加到账期汇总，账期汇总不存在时返回错误
+	AddRecognizedBill(ctx context.Context, key billings.PeriodKey, delta *billings.SummaryDelta) error
 	GetByCurrentPeriod(ctx context.Context, userID int64, periodEnd int64) (*billings.BillingPeriodSummary, error)
+	// Get 查询用户的账期汇总，不存在时返回 nil
+	Get(ctx context.Context, userID int64, id int64) (*billings.BillingPeriodSummary, error)
+	// SettleCharged 扣费成功后把金额从待付（重试成功时为失败）金额转入已付金额，账期汇总不存在时返回错误
+	SettleCharged(ctx context.Context, key billings.PeriodKey, amount float64, fromFailed bool) error
+	// SettleFailed 首次扣费失败后把金额从待付金额转入失败金额，账期汇总不存在时返回错误
+	SettleFailed(ctx context.Context, key billings.PeriodKey, amount float64) error
+	// ApplyAdjustment 记录账期调整金额，并扣减被冲减掉的待付和失败金额，账期汇总不存在时返回错误
+	ApplyAdjustment(ctx context.Context, key billings.PeriodKey, adjustment float64, pendingAbsorbed float64, errorAbsorbed float64) error
+	// AddCreditAmount 累加账期通过 app credit 返还的金额，账期汇总不存在时返回错误
+	AddCreditAmount(ctx context.Context, key billings.PeriodKey, amount float64) error
+	// CloseBillingPeriods 关闭结束时间不晚于 before 的开放账期
+	CloseBillingPeriods(ctx context.Context, userID int64, before int64) error
+	// Rebucket 在一个事务内改写账单和调整流水的账期，按唯一键原地更新重新汇总的账期，并删除不再有数据的旧账期
+	Rebucket(ctx context.Context, userID int64, bills []*billings.CommissionBill, adjustments []*billings.CommissionAdjustment, summaries []*billings.BillingPeriodSummary) error
 }
@@ -0,0 +1,28 @@
+package billings
+
+import (
+	"context"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+type CommissionAdjustmentRepository interface {
+	// Create 新增调整流水
+	Create(ctx context.Context, adjustment *billingEntity.CommissionAdjustment) (int64, error)
+	// Get 根据ID查询调整流水
+	Get(ctx context.Context, id int64) (*billingEntity.CommissionAdjustment, error)
+	// SumByUserOrder 汇总订单已记录的调整金额
+	SumByUserOrder(ctx context.Context, userID int64, userOrderID int64) (float64, error)
+	// RetryableCredits 查询待返还、返还失败或返还中超时且未超过重试次数的调整流水
+	RetryableCredits(ctx context.Context, lastID int64, before int64, size int) ([]*billingEntity.CommissionAdjustment, error)
+	// MarkCrediting 发放 app credit 前标记返还中，状态和修改时间与读取时不一致说明已被其他任务处理，返回 false
+	MarkCrediting(ctx context.Context, adjustment *billingEntity.CommissionAdjustment) (bool, error)
+	// MarkCredited 标记已通过 app credit 返还，已经是返还状态时返回 false
+	MarkCredited(ctx context.Context, id int64, creditID string) (bool, error)
+	// MarkFailed 标记返还失败并累加重试次数
+	MarkFailed(ctx context.Context, id int64, errMsg string) error
+	// ListByUser 查询用户所有的调整流水
+	ListByUser(ctx context.Context, userID int64) ([]*billingEntity.CommissionAdjustment, error)
+	// ListByPeriod 查询记在账期内的调整流水
+	ListByPeriod(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionAdjustment, error)
+}
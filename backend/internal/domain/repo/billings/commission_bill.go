@@ -13,4 +13,40 @@ type CommissionBillRepository interface {
 	CreateCommission(ctx context.Context, userID int64, orderID int64, amount decimal.Decimal) (int64, error)
 	CommissionList(ctx context.Context, userID int64, pagination entity.Pagination) ([]*billingEntity.CommissionBill, error)
 	CommissionCount(ctx context.Context, userID int64) (int64, error)
+	// CreateBill 保存订单抽成账单
+	CreateBill(ctx context.Context, bill *billingEntity.CommissionBill) (int64, error)
+	// GetCommission 根据ID查询账单
+	GetCommission(ctx context.Context, id int64) (*billingEntity.CommissionBill, error)
+	// GetByUserOrder 查询订单对应的账单，每个订单只有一条
+	GetByUserOrder(ctx context.Context, userID int64, userOrderID int64) (*billingEntity.CommissionBill, error)
+	// RetryableBills 查询待提交或提交失败且未超过重试次数的账单
+	RetryableBills(ctx context.Context, lastID int64, before int64, size int) ([]*billingEntity.CommissionBill, error)
+	// MarkCharged 标记账单扣费成功，账单已经是成功状态时返回 false
+	MarkCharged(ctx context.Context, id int64, usageRecordID string) (bool, error)
+	// MarkFailed 标记账单扣费失败并累加重试次数
+	MarkFailed(ctx context.Context, id int64, errMsg string) error
+	// Recognize 确认待确认账单的抽成并写入账单周期，账单已确认时返回 false
+	Recognize(ctx context.Context, id int64, periodStart int64, periodEnd int64, billCycle string) (bool, error)
+	// RecognitionAmounts 按确认状态汇总用户的抽成金额
+	RecognitionAmounts(ctx context.Context, userID int64) ([]*billingEntity.RecognitionAmount, error)
+	// UnchargedBills 查询账期内还未成功扣费的账单
+	UnchargedBills(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionBill, error)
+	// DeductCommission 冲减未扣费账单的抽成金额，账单已扣费或可冲减金额不足时返回 false
+	DeductCommission(ctx context.Context, id int64, amount float64) (bool, error)
+	// BilledUserIDs 按用户ID游标查询有抽成账单的用户
+	BilledUserIDs(ctx context.Context, lastUserID int64, size int) ([]int64, error)
+	// RecognizedBills 查询用户所有已确认的账单
+	RecognizedBills(ctx context.Context, userID int64) ([]*billingEntity.CommissionBill, error)
+	// RecognizedAmountsSince 按币种汇总 since 之后确认的抽成金额，用于估算用量增长速度
+	RecognizedAmountsSince(ctx context.Context, userID int64, since int64) ([]*billingEntity.CurrencyAmount, error)
+	// UnchargedAmounts 按币种汇总已确认但还没有扣费成功的金额
+	UnchargedAmounts(ctx context.Context, userID int64) ([]*billingEntity.CurrencyAmount, error)
+	// PeriodBills 查询账期内已确认的账单
+	PeriodBills(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionBill, error)
+	// ChargedBillsSince 查询 since 之后提交到 Shopify 的账单，用于和用量记录对账
+	ChargedBillsSince(ctx context.Context, userID int64, since int64) ([]*billingEntity.CommissionBill, error)
+	// SaveConversion 记录账单扣费时使用的汇率和换算后的金额
+	SaveConversion(ctx context.Context, id int64, conversion *billingEntity.Conversion) error
+	// ResetRetryCount 清零用户扣费失败账单的重试次数，让补偿任务重新提交
+	ResetRetryCount(ctx context.Context, userID int64) error
 }
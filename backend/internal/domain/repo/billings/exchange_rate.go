@@ -0,0 +1,34 @@
+package billings
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+type ExchangeRateRepository interface {
+	// Get 查询币种对的汇率，没有记录时返回 nil
+	Get(ctx context.Context, base string, quote string) (*billingEntity.ExchangeRate, error)
+	// Save 按币种对写入汇率，已存在的币种对覆盖汇率和来源
+	Save(ctx context.Context, rates []*billingEntity.ExchangeRate) error
+}
+
+// ExchangeRateProvider 汇率来源，可以替换成任意第三方汇率服务
+type ExchangeRateProvider interface {
+	// Name 汇率来源名称，写入汇率表的 source 字段
+	Name() string
+	// Rates 获取当前的汇率
+	Rates(ctx context.Context) (*billingEntity.RateTable, error)
+}
+
+// CurrencyConverter 基于汇率表的币种换算，汇率过期时从汇率来源刷新
+type CurrencyConverter interface {
+	// Convert 把 amount 从 from 币种换算为 to 币种
+	Convert(ctx context.Context, amount decimal.Decimal, from string, to string) (*billingEntity.Conversion, error)
+	// ConvertSum 把多个币种的金额换算为 to 币种后求和
+	ConvertSum(ctx context.Context, amounts []*billingEntity.CurrencyAmount, to string) (decimal.Decimal, error)
+	// Refresh 从汇率来源拉取最新汇率写入汇率表
+	Refresh(ctx context.Context) error
+}
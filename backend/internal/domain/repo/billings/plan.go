@@ -0,0 +1,18 @@
+package billings
+
+import (
+	"context"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+type PlanRepository interface {
+	// List 查询启用的套餐，按等级从低到高排序
+	List(ctx context.Context) ([]*billingEntity.AppPlan, error)
+	// Get 根据ID查询套餐，不存在时返回 nil
+	Get(ctx context.Context, id int64) (*billingEntity.AppPlan, error)
+	// GetByCode 根据套餐标识查询启用的套餐，不存在时返回 nil
+	GetByCode(ctx context.Context, code string) (*billingEntity.AppPlan, error)
+	// GetDefault 查询没有订阅时使用的默认套餐，不存在时返回 nil
+	GetDefault(ctx context.Context) (*billingEntity.AppPlan, error)
+}
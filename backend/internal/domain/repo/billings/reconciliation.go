@@ -0,0 +1,16 @@
+package billings
+
+import (
+	"context"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+type ReconciliationRepository interface {
+	// ReplaceUserIssues 用本次对账结果覆盖用户之前的对账差异
+	ReplaceUserIssues(ctx context.Context, userID int64, issues []*billingEntity.BillingReconciliation) error
+	// List 分页查询对账差异，userID 为 0、issueType 为空时不过滤
+	List(ctx context.Context, req *billingEntity.ReconciliationListReq) ([]*billingEntity.BillingReconciliation, error)
+	// Count 查询对账差异数量
+	Count(ctx context.Context, req *billingEntity.ReconciliationListReq) (int64, error)
+}
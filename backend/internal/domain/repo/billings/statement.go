@@ -0,0 +1,13 @@
+package billings
+
+import (
+	"context"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+// StatementRenderer 把账期账单渲染成可下载的文件
+type StatementRenderer interface {
+	// Render 按格式生成文件内容，返回内容和 Content-Type
+	Render(ctx context.Context, statement *billingEntity.Statement, format string) ([]byte, string, error)
+}
@@ -17,4 +17,8 @@ type CartSettingRepository interface {
 	ExistsByShowID(ctx context.Context, userID int64) int64
 	// CloseCart 关闭购物车
 	CloseCart(ctx context.Context, userID int64) error
+	// PauseForCappedAmount 订阅用量达到上限时关闭已打开的购物车，购物车本来就关闭时返回 false
+	PauseForCappedAmount(ctx context.Context, userID int64) (bool, error)
+	// RestoreFromCappedAmount 恢复因用量上限关闭的购物车，没有被自动关闭时返回 false
+	RestoreFromCappedAmount(ctx context.Context, userID int64) (bool, error)
 }
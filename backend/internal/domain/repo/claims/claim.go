@@ -0,0 +1,30 @@
+package claims
+
+import (
+	"context"
+
+	claimEntity "backend/internal/domain/entity/claims"
+)
+
+type ClaimRepository interface {
+	// Create 创建理赔，同时保存理赔商品和凭证，订单已有处理中的理赔时返回 ErrClaimExists
+	Create(ctx context.Context, claim *claimEntity.OrderClaim, items []*claimEntity.OrderClaimItem, evidence []*claimEntity.OrderClaimEvidence) error
+	// First 查询用户的理赔
+	First(ctx context.Context, userID int64, id int64) (*claimEntity.OrderClaim, error)
+	// Get 按ID查询理赔，供队列任务使用
+	Get(ctx context.Context, id int64) (*claimEntity.OrderClaim, error)
+	// List 分页查询理赔列表
+	List(ctx context.Context, req *claimEntity.ClaimListReq) ([]*claimEntity.OrderClaim, int64, error)
+	// Items 理赔商品
+	Items(ctx context.Context, claimID int64) ([]*claimEntity.OrderClaimItem, error)
+	// Evidence 理赔凭证
+	Evidence(ctx context.Context, claimID int64) ([]*claimEntity.OrderClaimEvidence, error)
+	// HasOpen 订单是否有处理中的理赔
+	HasOpen(ctx context.Context, userID int64, userOrderId int64) (bool, error)
+	// UpdateStatus 状态仍为 from 时更新理赔，返回是否更新成功，避免并发审核覆盖
+	UpdateStatus(ctx context.Context, claim *claimEntity.OrderClaim, from string, columns ...string) (bool, error)
+	// UpdateResolutionStatus 理赔已通过且处理状态仍为 from 时更新理赔，返回是否更新成功，避免重复发起处理
+	UpdateResolutionStatus(ctx context.Context, claim *claimEntity.OrderClaim, from string, columns ...string) (bool, error)
+	// Update 更新理赔的指定字段
+	Update(ctx context.Context, claim *claimEntity.OrderClaim, columns ...string) error
+}
@@ -2,8 +2,11 @@ package jobs
 
 import (
 	"context"
+	"time"
 
 	"github.com/hibiken/asynq"
+
+	"backend/internal/domain/entity/jobs"
 )
 
 type AsynqRepository interface {
@@ -11,6 +14,28 @@ type AsynqRepository interface {
 	InitUserTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
 	OrderWebhookTask(ctx context.Context, jobId int64) (*asynq.TaskInfo, error)
 	ProductWebhookUpdateTask(ctx context.Context, userID int64, userProductId int64) (*asynq.TaskInfo, error)
-	OrderStatisticsTask(ctx context.Context, userID int64, start int64, end int64) (*asynq.TaskInfo, error)
+	// OrderStatisticsTask 按店铺时区统计 at 所在的一天及之前共 days 天的订单
+	OrderStatisticsTask(ctx context.Context, at int64, days int) (*asynq.TaskInfo, error)
 	DelProductTask(ctx context.Context, userID int64, productId int64, delType int) (*asynq.TaskInfo, error)
+	CommissionSettleTask(ctx context.Context, billID int64) (*asynq.TaskInfo, error)
+	CommissionRetryTask(ctx context.Context, lastID int64) (*asynq.TaskInfo, error)
+	CommissionCreditTask(ctx context.Context, adjustmentID int64) (*asynq.TaskInfo, error)
+	CappedAmountTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
+	BillingReconcileTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
+	// OrderBackfillTask 历史订单回填，delay 大于 0 时延迟执行，用于等待 Shopify 批量查询完成
+	OrderBackfillTask(ctx context.Context, jobId int64, delay time.Duration) (*asynq.TaskInfo, error)
+	// ClaimResolveTask 执行审核通过的理赔处理
+	ClaimResolveTask(ctx context.Context, claimId int64) (*asynq.TaskInfo, error)
+	// VariantSyncTask 按变体阶梯增删保险商品的变体
+	VariantSyncTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
+	// SummaryMigrateTask 按店铺时区重新计算已有的每日统计，userID 为 0 时处理所有用户
+	SummaryMigrateTask(ctx context.Context, userID int64, cursor int64) (*asynq.TaskInfo, error)
+	// SubscriptionSyncTask 从 Shopify 同步订阅状态，userID 为 0 时同步所有活跃订阅
+	SubscriptionSyncTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
+	// ProductDriftTask 检查保险商品变体是否和 Shopify、变体阶梯一致，不一致时推送变体同步
+	ProductDriftTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
+	// WebhookTask 处理 webhook 收件箱记录，unique 为 true 时同一条记录在队列中只保留一个任务
+	WebhookTask(ctx context.Context, inbox *jobs.WebhookInbox, unique bool) (*asynq.TaskInfo, error)
+	// WebhookReconcileTask 核对 webhook 订阅，userID 为 0 时核对所有店铺
+	WebhookReconcileTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error)
 }
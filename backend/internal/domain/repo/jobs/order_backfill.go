@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"context"
+
+	"backend/internal/domain/entity/jobs"
+)
+
+type OrderBackfillRepository interface {
+	// Create 创建回填任务
+	Create(ctx context.Context, job *jobs.JobOrderBackfill) (int64, error)
+	// First 查询回填任务
+	First(ctx context.Context, id int64) (*jobs.JobOrderBackfill, error)
+	// Latest 用户最近一次回填任务
+	Latest(ctx context.Context, userID int64) (*jobs.JobOrderBackfill, error)
+	// HasUnfinished 用户是否有未结束的回填任务
+	HasUnfinished(ctx context.Context, userID int64) (bool, error)
+	// Update 更新回填任务的指定字段
+	Update(ctx context.Context, job *jobs.JobOrderBackfill, columns ...string) error
+}
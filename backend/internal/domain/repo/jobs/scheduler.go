@@ -0,0 +1,12 @@
+package jobs
+
+import (
+	"context"
+
+	"backend/internal/domain/entity/jobs"
+)
+
+type SchedulerRepository interface {
+	// Status 定时任务的配置、当前 leader 以及上次和下次执行时间
+	Status(ctx context.Context) (*jobs.SchedulerStatus, error)
+}
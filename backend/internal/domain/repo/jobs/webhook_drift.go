@@ -0,0 +1,14 @@
+package jobs
+
+import (
+	"context"
+
+	"backend/internal/domain/entity/jobs"
+)
+
+type WebhookDriftRepository interface {
+	// Create 保存一次对账发现的差异
+	Create(ctx context.Context, drifts []*jobs.WebhookDrift) error
+	// List 店铺最近的差异记录，userID 为 0 时查询所有店铺
+	List(ctx context.Context, userID int64, limit int) ([]*jobs.WebhookDrift, error)
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"context"
+
+	"backend/internal/domain/entity/jobs"
+)
+
+type WebhookInboxRepository interface {
+	// Create 保存 webhook，webhook_id 已存在时不保存并返回 false
+	Create(ctx context.Context, inbox *jobs.WebhookInbox) (bool, error)
+	// First 查询 webhook
+	First(ctx context.Context, id int64) (*jobs.WebhookInbox, error)
+	// FirstByWebhookId 根据 Shopify webhook id 查询 webhook
+	FirstByWebhookId(ctx context.Context, webhookId string) (*jobs.WebhookInbox, error)
+	// Update 更新 webhook 的指定字段
+	Update(ctx context.Context, inbox *jobs.WebhookInbox, columns ...string) error
+	// ClearPayload 清空店铺中属于顾客的 webhook 内容（不论处理状态），按顾客ID或邮箱列精确匹配，exceptID 是当前处理的 webhook，返回清空的条数
+	ClearPayload(ctx context.Context, appId string, shop string, customerID int64, email string, exceptID int64) (int64, error)
+	// DeleteByShop 删除店铺的 webhook，exceptID 为当前正在处理的记录
+	DeleteByShop(ctx context.Context, appId string, shop string, exceptID int64) (int64, error)
+}
@@ -15,8 +15,18 @@ type OrderRepository interface {
 	Create(ctx context.Context, order *orderEntity.UserOrder) (int64, error)
 	// ExistsByOrderID 检查订单是否存在
 	ExistsByOrderID(ctx context.Context, orderId int64, userID int64) int64
+	// IsBackfilled 订单是否是安装前回填的历史订单
+	IsBackfilled(ctx context.Context, id int64) (bool, error)
 	// UpdateShopifyOrderId 更新订单信息
 	UpdateShopifyOrderId(ctx context.Context, order *orderEntity.UserOrder) error
-	// GetOrderStatistics 获取订单统计信息
+	// GetOrderStatistics 获取 [start, end) 内创建的订单统计信息
 	GetOrderStatistics(ctx context.Context, start, end int64, userID int64) (*orderEntity.OrderStatistics, error)
+	// GetByIDs 根据ID批量查询订单
+	GetByIDs(ctx context.Context, userID int64, ids []int64) ([]*orderEntity.UserOrder, error)
+	// First 查询用户的订单，不存在或已删除时返回 nil
+	First(ctx context.Context, userID int64, id int64) (*orderEntity.UserOrder, error)
+	// FirstByName 根据订单编号查询订单，不存在或已删除时返回 nil
+	FirstByName(ctx context.Context, userID int64, orderName string) (*orderEntity.UserOrder, error)
+	// UpdateEmail 更新客户邮箱
+	UpdateEmail(ctx context.Context, id int64, email string) error
 }
@@ -13,4 +13,6 @@ type OrderInfoRepository interface {
 	UpdateShopifyVariants(ctx context.Context, userOrderId int64, variantId int64, orderInfo *orders.UserOrderInfo) error
 	// GetOrderDetailVariantIDs 获取订单详情变体ID列表
 	GetOrderDetailVariantIDs(ctx context.Context, userOrderId int64, userID int64) ([]int64, error)
+	// GetByUserOrderId 获取订单全部详情
+	GetByUserOrderId(ctx context.Context, userOrderId int64, userID int64) ([]*orders.UserOrderInfo, error)
 }
@@ -15,4 +15,8 @@ type OrderSummaryRepository interface {
 	UpsertOrderStatistics(ctx context.Context, orderSummary orders.OrderSummary) error
 	// CrateOrderStatistics 创建订单统计
 	CrateOrderStatistics(ctx context.Context, orderSummary orders.OrderSummary) error
+	// Span 查询用户最早和最晚一天的统计时间，没有统计时返回 0
+	Span(ctx context.Context, userID int64) (int64, int64, error)
+	// DeleteExcept 删除用户不在 keep 中的每日统计
+	DeleteExcept(ctx context.Context, userID int64, keep []int64) error
 }
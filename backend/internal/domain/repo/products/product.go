@@ -21,4 +21,6 @@ type ProductRepository interface {
 	DelShopifyProduct(ctx context.Context, userID int64) error
 	// ExistsByProductID 根据产品ID检查产品是否存在
 	ExistsByProductID(ctx context.Context, userID int64, productId int64) int64
+	// Uploaded 按ID游标查询已上传到 Shopify 的产品
+	Uploaded(ctx context.Context, lastID int64, size int) ([]*products.UserProduct, error)
 }
@@ -15,10 +15,12 @@ type VariantRepository interface {
 	CreateVariants(ctx context.Context, variants []*products.UserVariant) error
 	// UpdateVariants 更新产品变体
 	UpdateVariants(ctx context.Context, id int64, userID int64, variant *products.UserVariant) error
-	// GetUploadedVariantIDs 获取已上传的变体ID列表
+	// GetUploadedVariantIDs 获取已上传的变体ID列表，包括已被阶梯替换的变体，用于识别历史订单中的保险
 	GetUploadedVariantIDs(ctx context.Context, userID int64) ([]int64, error)
 	// DelShopifyVariant 删除Shopify变体
 	DelShopifyVariant(ctx context.Context, userID int64) error
+	// DeleteVariants 标记用户的变体已被阶梯替换，保留变体ID
+	DeleteVariants(ctx context.Context, userID int64, ids []int64) error
 	// GetVariantConfig 获取变体配置
 	GetVariantConfig(ctx context.Context, userID int64) (map[string]int64, int64, error)
 }
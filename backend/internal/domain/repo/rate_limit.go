@@ -0,0 +1,11 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+type RateLimitRepository interface {
+	// Allow 固定窗口计数，窗口内请求数不超过 limit 时返回 true
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error)
+}
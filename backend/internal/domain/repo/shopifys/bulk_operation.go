@@ -0,0 +1,21 @@
+package shopifys
+
+import (
+	"context"
+	"time"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+)
+
+type BulkOperationGraphqlRepository interface {
+	// RunQuery 提交批量查询，同一店铺同时只能运行一个批量查询
+	RunQuery(ctx context.Context, query string) (*shopifyEntity.BulkOperation, error)
+	// RunOrdersQuery 批量查询创建时间在 [createdFrom, createdTo) 之间的订单和订单商品
+	RunOrdersQuery(ctx context.Context, createdFrom int64, createdTo int64) (*shopifyEntity.BulkOperation, error)
+	// Get 查询批量操作状态
+	Get(ctx context.Context, id string) (*shopifyEntity.BulkOperation, error)
+	// Wait 轮询批量操作直到结束，ctx 先结束时返回最后一次查询的状态和 ctx 的错误
+	Wait(ctx context.Context, id string, interval time.Duration) (*shopifyEntity.BulkOperation, error)
+	// Download 流式下载 JSONL 结果文件，每读到一行回调一次
+	Download(ctx context.Context, url string, fn func(line []byte) error) error
+}
@@ -0,0 +1,32 @@
+package shopifys
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+)
+
+// ClaimResolutionGraphqlRepository 理赔处理：退款、礼品卡和零元补发
+type ClaimResolutionGraphqlRepository interface {
+	// GetClaimOrder 订单客户、收货地址和已有退款
+	GetClaimOrder(ctx context.Context, orderId int64) (*shopifyEntity.ClaimOrder, error)
+	// SuggestedRefund 按退款商品计算退款交易
+	SuggestedRefund(ctx context.Context, orderGid string, lineItems []shopifyEntity.RefundLineItemInput) ([]shopifyEntity.SuggestedTransaction, error)
+	// CreateRefund 退款指定商品，不退回库存
+	CreateRefund(ctx context.Context, orderGid string, note string, lineItems []shopifyEntity.RefundLineItemInput, transactions []shopifyEntity.SuggestedTransaction) (string, error)
+	// GetClaimLineItems 订单全部商品的变体和折扣后单价
+	GetClaimLineItems(ctx context.Context, orderGid string) ([]shopifyEntity.ClaimLineItem, error)
+	// FindGiftCard 查找 createdFrom 之后创建、备注为 note 并且代码后几位为 lastCharacters 的礼品卡，没有时返回空
+	FindGiftCard(ctx context.Context, note string, lastCharacters string, createdFrom time.Time) (string, error)
+	// CreateGiftCard 按指定代码创建礼品卡，代码已存在时 Shopify 拒绝创建；customerGid 不为空时绑定客户
+	CreateGiftCard(ctx context.Context, amount decimal.Decimal, customerGid string, note string, code string) (string, error)
+	// FindDraftOrder 按标签查找草稿订单，没有时返回 nil
+	FindDraftOrder(ctx context.Context, tag string) (*shopifyEntity.DraftOrder, error)
+	// CreateReshipDraftOrder 按原订单客户和地址创建全额折扣、免运费的草稿订单
+	CreateReshipDraftOrder(ctx context.Context, order *shopifyEntity.ClaimOrder, lineItems []shopifyEntity.DraftOrderLineItemInput, note string, tag string) (*shopifyEntity.DraftOrder, error)
+	// CompleteDraftOrder 完成草稿订单，生成待发货的订单
+	CompleteDraftOrder(ctx context.Context, draftOrderGid string) (*shopifyEntity.DraftOrder, error)
+}
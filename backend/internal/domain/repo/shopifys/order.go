@@ -7,7 +7,5 @@ import (
 )
 
 type OrderGraphqlRepository interface {
-	BaseGraphqlRepository
-
 	GetOrderInfo(ctx context.Context, orderId int64) (*shopifyEntity.OrderResponse, error)
 }
@@ -7,7 +7,6 @@ import (
 )
 
 type ProductGraphqlRepository interface {
-	BaseGraphqlRepository
 	CreateProductWithMedia(ctx context.Context, productInput shopifyEntity.ProductCreateInput, mediaInput []shopifyEntity.CreateMediaInput) (*shopifyEntity.ProductCreateResponse, error)
 	GetProduct(ctx context.Context, productID int64) (*shopifyEntity.ProductResponse, error)
 	DeleteVariant(ctx context.Context, productID int64, variantID int64) error
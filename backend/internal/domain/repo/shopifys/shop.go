@@ -9,9 +9,10 @@ import (
 var (
 	ShopifyWebhookTopics = []string{
 		"orders/updated",
+		"orders/fulfilled",
+		"orders/partially_fulfilled",
 		"orders/delete",
 		"app_subscriptions/update",
-		"app_subscriptions/cancel",
 		"app_subscriptions/approaching_capped_amount",
 		"app/uninstalled",
 		"products/update",
@@ -25,7 +26,6 @@ var (
 
 // ShopGraphqlRepository 店铺GraphQL仓储接口
 type ShopGraphqlRepository interface {
-	BaseGraphqlRepository
 	GetShopInfo(ctx context.Context) (*shopifys.Shop, *shopifys.CurrentAppInstallation, error)
 	UpdateShopBillingAddress(ctx context.Context, input shopifys.ShopBillingAddressInput) error
 	UpdateShopSettings(ctx context.Context, input shopifys.ShopSettingsInput) error
@@ -35,10 +35,10 @@ type ShopGraphqlRepository interface {
 	QueryWebhookSubscriptions(ctx context.Context, queryParams string) ([]shopifys.WebhookSubscription, error)
 	CreateWebhookSubscription(ctx context.Context, topic string, callbackUrl string) error
 	UpdateWebhookSubscription(ctx context.Context, id string, callbackUrl string) error
+	DeleteWebhookSubscription(ctx context.Context, id string) error
 	MetafieldSet(ctx context.Context, ownerId string, namespace string, fieldType string, key string, value string) (*[]shopifys.Metafield, error)
 }
 
 type ThemeGraphqlRepository interface {
-	BaseGraphqlRepository
 	GetMainThemeSettingJson(ctx context.Context) (string, error)
 }
@@ -0,0 +1,37 @@
+package shopifys
+
+import (
+	"testing"
+
+	"backend/internal/domain/entity/shopifys"
+)
+
+// supportedWebhookTopics Admin API 中 WebhookSubscriptionTopic 枚举的部分取值，新增订阅的 topic 时先核对文档再补充
+var supportedWebhookTopics = map[string]struct{}{
+	"APP_UNINSTALLED":                             {},
+	"APP_SUBSCRIPTIONS_UPDATE":                    {},
+	"APP_SUBSCRIPTIONS_APPROACHING_CAPPED_AMOUNT": {},
+	"APP_PURCHASES_ONE_TIME_UPDATE":               {},
+	"ORDERS_CREATE":                               {},
+	"ORDERS_UPDATED":                              {},
+	"ORDERS_PAID":                                 {},
+	"ORDERS_CANCELLED":                            {},
+	"ORDERS_FULFILLED":                            {},
+	"ORDERS_PARTIALLY_FULFILLED":                  {},
+	"ORDERS_EDITED":                               {},
+	"ORDERS_DELETE":                               {},
+	"REFUNDS_CREATE":                              {},
+	"PRODUCTS_CREATE":                             {},
+	"PRODUCTS_UPDATE":                             {},
+	"PRODUCTS_DELETE":                             {},
+	"SHOP_UPDATE":                                 {},
+	"THEMES_PUBLISH":                              {},
+}
+
+func TestShopifyWebhookTopicsSupported(t *testing.T) {
+	for _, topic := range ShopifyWebhookTopics {
+		if _, ok := supportedWebhookTopics[shopifys.WebhookTopicEnum(topic)]; !ok {
+			t.Errorf("topic %s is not a Shopify webhook subscription topic", topic)
+		}
+	}
+}
@@ -2,17 +2,17 @@ package shopifys
 
 import (
 	"context"
-
-	"backend/internal/infras/shopify_graphql"
 )
 
 type ShopifyRepository interface {
 	ExtractCurrencySymbol(moneyFormat string) string
 	GetWebhookUrl(appID string) string
-	GetReturnUrl(appID string, userID int64) string
-	VerifyWebhook(ctx context.Context, appSecret string, signature string, body []byte) bool
-}
-
-type BaseGraphqlRepository interface {
-	WithClient(client *shopify_graphql.GraphqlClient)
+	// GetReturnUrl 支付回调地址，带上用 appSecret 计算的签名
+	GetReturnUrl(appID string, userID int64, appSecret string) string
+	// VerifyWebhook 用任一有效 Secret 验证 webhook 签名，没有 Secret 时验证失败
+	VerifyWebhook(ctx context.Context, appSecrets []string, signature string, body []byte) bool
+	// VerifyReturnUrl 验证支付回调地址中的签名
+	VerifyReturnUrl(ctx context.Context, appSecrets []string, appID string, userID int64, sign string) bool
+	// UnsignedReturnAllowed 订阅是否在支付回调地址加签名之前创建，这些订阅的回调没有签名
+	UnsignedReturnAllowed(createdAt int64) bool
 }
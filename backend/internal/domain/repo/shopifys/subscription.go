@@ -9,13 +9,25 @@ import (
 )
 
 type SubscriptionGraphqlRepository interface {
-	BaseGraphqlRepository
 	CreateSubscription(ctx context.Context, input shopifyEntity.AppSubscriptionCreateInput) (*shopifyEntity.AppSubscription, string, error)
 	GetCurrentSubscription(ctx context.Context) (*shopifyEntity.AppSubscription, error)
 	GetRecurrentChargeByID(ctx context.Context, id int64) (*shopifyEntity.AppSubscription, error)
+	// UpdateCappedAmount 修改用量订阅的上限金额，返回商家确认链接
+	UpdateCappedAmount(ctx context.Context, lineItemID string, cappedAmount shopifyEntity.MoneyInput) (string, error)
+	// CancelSubscription 取消订阅，id 为订阅的 GraphQL ID
+	CancelSubscription(ctx context.Context, id string, prorate bool) (*shopifyEntity.AppSubscription, error)
 }
 
 type UsageChargeGraphqlRepository interface {
-	BaseGraphqlRepository
-	CreateUsageCharge(ctx context.Context, lineItemId string, amount decimal.Decimal, description string) (string, error)
+	// CreateUsageCharge 创建用量扣费，currency 需要和订阅上限的币种一致，idempotencyKey 相同的请求 Shopify 只会扣费一次
+	CreateUsageCharge(ctx context.Context, lineItemId string, amount decimal.Decimal, currency string, description string, idempotencyKey string) (string, error)
+	// ListUsageRecords 分页拉取订阅项目下的全部用量记录
+	ListUsageRecords(ctx context.Context, lineItemId string) ([]shopifyEntity.AppUsageRecord, error)
+}
+
+type AppCreditGraphqlRepository interface {
+	// CreateAppCredit 给商家发放 app credit，用于返还已扣费的金额
+	CreateAppCredit(ctx context.Context, amount decimal.Decimal, currency string, description string, test bool) (string, error)
+	// FindAppCredit 按描述查询已发放的 app credit，没有找到返回空字符串
+	FindAppCredit(ctx context.Context, description string) (string, error)
 }
@@ -0,0 +1,9 @@
+package repo
+
+import "context"
+
+// TransactionRepository 数据库事务，fn 里用传入的 ctx 调用仓储方法即可加入同一个事务
+type TransactionRepository interface {
+	// Transaction fn 返回错误时回滚，ctx 已经在事务中时直接复用外层事务
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
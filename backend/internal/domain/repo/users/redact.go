@@ -0,0 +1,33 @@
+package users
+
+import (
+	"context"
+
+	"backend/internal/domain/entity/claims"
+	"backend/internal/domain/entity/orders"
+	"backend/internal/domain/entity/users"
+)
+
+// RedactRepository 合规请求：记录处理历史，查询和清除顾客、店铺数据
+type RedactRepository interface {
+	// Create 创建处理记录
+	Create(ctx context.Context, history *users.RedactHistory) error
+	// FirstByInbox 根据 webhook 收件箱ID查询处理记录，重试时继续使用同一条记录
+	FirstByInbox(ctx context.Context, inboxID int64) (*users.RedactHistory, error)
+	// Update 更新处理记录的指定字段
+	Update(ctx context.Context, history *users.RedactHistory, columns ...string) error
+	// DataRequests 店铺已完成的顾客数据请求
+	DataRequests(ctx context.Context, appId string, shop string) ([]*users.RedactHistory, error)
+	// DataRequestExports 还保留导出文件的顾客数据请求，customerID 为0时查询店铺所有顾客
+	DataRequestExports(ctx context.Context, appId string, shop string, customerID int64) ([]*users.RedactHistory, error)
+	// FirstDataRequest 查询店铺的顾客数据请求
+	FirstDataRequest(ctx context.Context, appId string, shop string, id int64) (*users.RedactHistory, error)
+	// CustomerOrders 顾客的订单，按 Shopify 订单ID或顾客邮箱匹配
+	CustomerOrders(ctx context.Context, userID int64, orderIDs []int64, email string) ([]*orders.UserOrder, error)
+	// CustomerClaims 订单的理赔
+	CustomerClaims(ctx context.Context, userID int64, userOrderIDs []int64) ([]*claims.OrderClaim, error)
+	// RedactCustomer 清除订单中的顾客邮箱、理赔描述和凭证，返回清除的记录数和凭证文件地址
+	RedactCustomer(ctx context.Context, userID int64, userOrderIDs []int64) (int, []string, error)
+	// RedactShop 删除店铺的订单、理赔、设置、产品和授权，清除店铺个人信息，账单和统计数据保留，返回删除的记录数和凭证文件地址
+	RedactShop(ctx context.Context, userID int64) (int, []string, error)
+}
@@ -22,6 +22,8 @@ type UserRepository interface {
 	UpdateIsDel(ctx context.Context, userID int64, isDel int8) error
 	// UpdateIsClose 更新用户关店状态
 	UpdateIsClose(ctx context.Context, userID int64, planDisplayName string) error
+	// UpdatePlan 更新用户当前的 app 套餐
+	UpdatePlan(ctx context.Context, userID int64, planID int64) error
 	// UpdateStep 更新用户引导步骤
 	UpdateStep(ctx context.Context, userID int64, steps string) error
 	// SetToken 设置用户令牌和密码
@@ -38,6 +40,8 @@ type UserRepository interface {
 	GetActiveUserByShopID(ctx context.Context, appId string, shopID int64) (*users.User, error)
 	GetActiveUser(ctx context.Context, id int64, columns ...string) (*users.User, error)
 	GetUsers(ctx context.Context, cursorId int64, size int) ([]*users.User, error)
+	// RotateTokens 用最新密钥重新加密 access_token，返回本批最后一个用户 id 和重新加密的数量
+	RotateTokens(ctx context.Context, cursorID int64, limit int) (int64, int, error)
 }
 
 type UserCacheRepository interface {
@@ -10,12 +10,19 @@ import (
 type UserSubscriptionRepository interface {
 	GetActiveSubscription(ctx context.Context, userID int64) (*billingEntity.UserSubscription, error)
 	UpsertUserSubscription(ctx context.Context, subscription *billingEntity.UserSubscription) error
-	UpdateSubscriptionBalance(ctx context.Context, id int64, balanceUsed float64) error
+	// IncrSubscriptionBalance 原子增加已用额度，设置了用量上限时增加后不能超过上限，返回是否更新成功；delta 为负数时释放额度
+	IncrSubscriptionBalance(ctx context.Context, id int64, delta float64) (bool, error)
 	GetSubscriptionByLineItemID(ctx context.Context, lineItemID int64) (*billingEntity.UserSubscription, error)
 	GetExpiredSubscriptions(ctx context.Context) ([]*billingEntity.UserSubscription, error)
 	GetSubscriptionByChargeID(ctx context.Context, chargeID int64) (*billingEntity.UserSubscription, error)
+	// ListByUser 查询用户的所有订阅（包含已取消的），按创建时间升序
+	ListByUser(ctx context.Context, userID int64) ([]*billingEntity.UserSubscription, error)
 	UpdateSubscriptionStatus(ctx context.Context, chargeID int64, status string) error
 	CancelActiveSubscriptionsExcept(ctx context.Context, userID int64, exceptChargeID int64) error
+	// ActiveUsageSubscriptions 按ID游标查询有用量扣费项目的活跃订阅
+	ActiveUsageSubscriptions(ctx context.Context, lastID int64, size int) ([]*billingEntity.UserSubscription, error)
+	// ActiveSubscriptions 按ID游标查询所有活跃订阅
+	ActiveSubscriptions(ctx context.Context, lastID int64, size int) ([]*billingEntity.UserSubscription, error)
 	// 添加事务方法
 	SyncUserSubscriptionWithTx(ctx context.Context, userID int64, newSubscription *billingEntity.UserSubscription) error
 }
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"backend/internal/domain/repo"
+)
+
+var _ repo.RateLimitRepository = (*rateLimitRepoImpl)(nil)
+
+// incrWindow 计数和设置过期在同一个脚本里执行，避免计数 key 没有过期时间
+var incrWindow = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+type rateLimitRepoImpl struct {
+	redisClient redis.UniversalClient
+}
+
+func NewRateLimitRepository(redisClient redis.UniversalClient) repo.RateLimitRepository {
+	return &rateLimitRepoImpl{redisClient}
+}
+
+func (r *rateLimitRepoImpl) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	count, err := incrWindow.Run(ctx, r.redisClient, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return count <= limit, nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"backend/internal/domain/entity/users"
 	ur "backend/internal/domain/repo/users"
+	"backend/pkg/crypto"
 )
 
 var _ ur.UserCacheRepository = (*userCacheImpl)(nil)
@@ -18,11 +19,12 @@ var _ ur.UserCacheRepository = (*userCacheImpl)(nil)
 type userCacheImpl struct {
 	redisClient redis.UniversalClient
 	userRepo    ur.UserRepository
+	crypto      *crypto.Envelope
 }
 
-// NewUserCacheRepository NewUserCacheRepo 用户缓存资源
-func NewUserCacheRepository(redisClient redis.UniversalClient, userRepo ur.UserRepository) ur.UserCacheRepository {
-	return &userCacheImpl{redisClient: redisClient, userRepo: userRepo}
+// NewUserCacheRepository NewUserCacheRepo 用户缓存资源，access_token 加密后写入缓存
+func NewUserCacheRepository(redisClient redis.UniversalClient, userRepo ur.UserRepository, envelope *crypto.Envelope) ur.UserCacheRepository {
+	return &userCacheImpl{redisClient: redisClient, userRepo: userRepo, crypto: envelope}
 }
 
 // randomSecond 设置随机值，防止缓存雪崩
@@ -36,7 +38,7 @@ func (u *userCacheImpl) Set(ctx context.Context, id int64, user *users.User, ttl
 	// 添加前缀
 	key := fmt.Sprintf("user:%d", id)
 
-	data, err := json.Marshal(user)
+	data, err := u.marshal(user)
 	if err != nil {
 		return err
 	}
@@ -49,22 +51,14 @@ func (u *userCacheImpl) Get(ctx context.Context, id int64) (*users.User, error)
 	// 添加前缀
 	key := fmt.Sprintf("user:%d", id)
 
-	result, err := u.redisClient.Get(ctx, key).Result()
-	if err != nil {
-		return nil, err
-	}
-	user := &users.User{}
-	if err := json.Unmarshal([]byte(result), user); err != nil {
-		return nil, err
-	}
-	return user, nil
+	return u.get(ctx, key)
 }
 
 // SetByShop 将 users.User 写入缓存
 func (u *userCacheImpl) SetByShop(ctx context.Context, appId string, shop string, user *users.User, ttl time.Duration) error {
 	// 添加前缀
 	key := fmt.Sprintf("app:%s:user:%s", appId, shop)
-	data, err := json.Marshal(user)
+	data, err := u.marshal(user)
 	if err != nil {
 		return err
 	}
@@ -76,6 +70,22 @@ func (u *userCacheImpl) SetByShop(ctx context.Context, appId string, shop string
 func (u *userCacheImpl) GetByShop(ctx context.Context, appId string, shop string) (*users.User, error) {
 	// 添加前缀
 	key := fmt.Sprintf("app:%s:user:%s", appId, shop)
+	return u.get(ctx, key)
+}
+
+// marshal 序列化时加密 access_token，不修改传入的 user
+func (u *userCacheImpl) marshal(user *users.User) ([]byte, error) {
+	cached := *user
+	token, err := u.crypto.Encrypt(user.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	cached.AccessToken = token
+	return json.Marshal(&cached)
+}
+
+// get 读取缓存并解密 access_token，明文或旧密钥加密的缓存直接删除，按未命中处理
+func (u *userCacheImpl) get(ctx context.Context, key string) (*users.User, error) {
 	result, err := u.redisClient.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
@@ -84,5 +94,14 @@ func (u *userCacheImpl) GetByShop(ctx context.Context, appId string, shop string
 	if err := json.Unmarshal([]byte(result), user); err != nil {
 		return nil, err
 	}
+	if u.crypto.NeedsRotate(user.AccessToken) {
+		if err := u.redisClient.Del(ctx, key).Err(); err != nil {
+			return nil, err
+		}
+		return nil, redis.Nil
+	}
+	if user.AccessToken, err = u.crypto.Decrypt(user.AccessToken); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
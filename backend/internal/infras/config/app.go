@@ -24,13 +24,20 @@ type AppConfig struct {
 	LogLevel     string        `mapstructure:"log_level"`     // 日志等级
 	JWT          JWT           `mapstructure:"jwt"`
 	Crypto       struct {
-		AES CryptoAES
+		AES      CryptoAES
+		Envelope CryptoEnvelope `mapstructure:"envelope"` // Shopify token 落库和缓存加密
 	} `mapstructure:"crypto"` // 加密算法
-	Shopify Shopify `mapstructure:"shopify"`
+	Shopify      Shopify      `mapstructure:"shopify"`
+	ExchangeRate ExchangeRate `mapstructure:"exchange_rate"` // 抽成扣费的汇率配置
+	Scheduler    Scheduler    `mapstructure:"scheduler"`     // job 进程的定时任务
 }
 
 type Shopify struct {
 	WebhookHost string `mapstructure:"webhook_host"`
+	// SkipVerify 跳过 webhook 和支付回调的签名验证，只能在本地开发时打开
+	SkipVerify bool `mapstructure:"skip_verify"`
+	// UnsignedReturnBefore 支付回调地址加签名之前创建的订阅，回调没有签名，在这个时间（Unix 秒）之前创建的订阅允许无签名回调，0 表示不允许
+	UnsignedReturnBefore int64 `mapstructure:"unsigned_return_before"`
 }
 
 // JWT config
@@ -46,6 +53,13 @@ type CryptoAES struct {
 	Key string `mapstructure:"key"`
 }
 
+// CryptoEnvelope 信封加密的密钥环
+// 轮换时加入新 key 并改为 primary，旧 key 保留到 token_reencrypt 任务把数据重新加密完再删除
+type CryptoEnvelope struct {
+	Primary string            `mapstructure:"primary"` // 加密使用的 key id
+	Keys    map[string]string `mapstructure:"keys"`    // key id => base64 编码的 32 字节密钥
+}
+
 // 配置文件读取的接口
 var conf settings.Config
 
@@ -9,14 +9,33 @@ import (
 )
 
 const (
-	SendProduct         = "task:send_product"
-	SendInitUser        = "task:send_init_user"
-	SendOrder           = "task:send_order"
-	SendUpdateProduct   = "task:send_update_product"
-	SendOrderStatistics = "task:send_order_statistics"
-	SendDelProduct      = "task:send_delete_product"
+	SendProduct          = "task:send_product"
+	SendInitUser         = "task:send_init_user"
+	SendOrder            = "task:send_order"
+	SendUpdateProduct    = "task:send_update_product"
+	SendOrderStatistics  = "task:send_order_statistics"
+	SendDelProduct       = "task:send_delete_product"
+	SendCommissionSettle = "task:send_commission_settle"
+	SendCommissionRetry  = "task:send_commission_retry"
+	SendCommissionCredit = "task:send_commission_credit"
+	SendCappedAmount     = "task:send_capped_amount"
+	SendBillingReconcile = "task:send_billing_reconcile"
+	SendOrderBackfill    = "task:send_order_backfill"
+	SendClaimResolve     = "task:send_claim_resolve"
+	SendVariantSync      = "task:send_variant_sync"
+	SendSummaryMigrate   = "task:send_summary_migrate"
+	SendSubscriptionSync = "task:send_subscription_sync"
+	SendProductDrift     = "task:send_product_drift"
+	SendWebhook          = "task:send_webhook"
+	SendWebhookReconcile = "task:send_webhook_reconcile"
+	SendTokenReencrypt   = "task:send_token_reencrypt"
 )
 
+// WebhookTask webhook 按 topic 区分任务类型，未单独注册的 topic 由 SendWebhook 前缀兜底
+func WebhookTask(topic string) string {
+	return SendWebhook + ":" + topic
+}
+
 func NewAsynqServer(name string) (*asynq.Server, error) {
 	redisConf := gredis.RedisConf{}
 	err := conf.ReadSection(name, &redisConf)
@@ -54,3 +73,19 @@ func NewAsynqClient(name string) (*asynq.Client, error) {
 
 	return client, nil
 }
+
+// NewAsynqScheduler 定时任务调度器，和 worker 使用同一个 redis
+func NewAsynqScheduler(name string, opts *asynq.SchedulerOpts) (*asynq.Scheduler, error) {
+	redisConf := gredis.RedisConf{}
+	err := conf.ReadSection(name, &redisConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config for %s section: %s", name, err)
+	}
+
+	scheduler := asynq.NewScheduler(asynq.RedisClientOpt{
+		Addr:     redisConf.Address,
+		Password: redisConf.Password, // no password set
+		DB:       1,
+	}, opts)
+	return scheduler, nil
+}
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// ExchangeRate 汇率配置
+type ExchangeRate struct {
+	Provider string             `mapstructure:"provider"` // 汇率来源 static,file
+	Base     string             `mapstructure:"base"`     // 基准币种，汇率表中的汇率都相对于它
+	File     string             `mapstructure:"file"`     // file 来源的汇率文件路径
+	Rates    map[string]float64 `mapstructure:"rates"`    // static 来源的固定汇率
+	MaxAge   time.Duration      `mapstructure:"max_age"`  // 汇率有效期，过期后从汇率来源刷新
+}
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// Scheduler 定时任务配置，Tasks 的 key 为任务名，没有配置的任务使用默认的 cron 表达式
+type Scheduler struct {
+	LeaderTTL time.Duration           `mapstructure:"leader_ttl"` // 调度器 leader 锁的有效期，默认 30s
+	Location  string                  `mapstructure:"location"`   // cron 表达式使用的时区，默认 UTC
+	Tasks     map[string]ScheduleTask `mapstructure:"tasks"`
+}
+
+// ScheduleTask 单个定时任务的配置
+type ScheduleTask struct {
+	Cron    string `mapstructure:"cron"`    // cron 表达式，留空使用默认值
+	Enabled *bool  `mapstructure:"enabled"` // 是否启用，不配置时启用
+}
@@ -0,0 +1,180 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+	"backend/internal/infras/config"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+// rateScale 汇率保留的小数位，和汇率表的 decimal(18, 8) 一致
+const rateScale = 8
+
+var _ billings.CurrencyConverter = (*currencyConverterImpl)(nil)
+
+type currencyConverterImpl struct {
+	base     string
+	maxAge   time.Duration
+	rateRepo billings.ExchangeRateRepository
+	provider billings.ExchangeRateProvider
+}
+
+// NewCurrencyConverter 汇率表只保存相对基准币种的汇率，其他币种之间通过基准币种交叉换算
+func NewCurrencyConverter(conf *config.ExchangeRate, rateRepo billings.ExchangeRateRepository, provider billings.ExchangeRateProvider) billings.CurrencyConverter {
+	base := normalize(conf.Base)
+	if base == "" {
+		base = DefaultBase
+	}
+	maxAge := conf.MaxAge
+	if maxAge <= 0 {
+		maxAge = 24 * time.Hour
+	}
+	return &currencyConverterImpl{
+		base:     base,
+		maxAge:   maxAge,
+		rateRepo: rateRepo,
+		provider: provider,
+	}
+}
+
+func (c *currencyConverterImpl) Convert(ctx context.Context, amount decimal.Decimal, from string, to string) (*billingEntity.Conversion, error) {
+	from, to = normalize(from), normalize(to)
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("币种不能为空 from: %q to: %q", from, to)
+	}
+	conversion := &billingEntity.Conversion{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Rate:   decimal.NewFromInt(1),
+		Result: amount.Round(2),
+	}
+	if from == to {
+		return conversion, nil
+	}
+
+	rates, err := c.baseRates(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// rates 都是 1 单位基准币种兑换的数量，from -> to = to / from
+	conversion.Rate = rates[to].DivRound(rates[from], rateScale)
+	conversion.Result = amount.Mul(conversion.Rate).Round(2)
+	return conversion, nil
+}
+
+func (c *currencyConverterImpl) ConvertSum(ctx context.Context, amounts []*billingEntity.CurrencyAmount, to string) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, amount := range amounts {
+		if amount.Amount == 0 {
+			continue
+		}
+		// 早期账单没有记录币种，按扣费币种处理
+		from := amount.Currency
+		if from == "" {
+			from = to
+		}
+		conversion, err := c.Convert(ctx, decimal.NewFromFloat(amount.Amount), from, to)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(conversion.Result)
+	}
+	return total, nil
+}
+
+func (c *currencyConverterImpl) Refresh(ctx context.Context) error {
+	table, err := c.provider.Rates(ctx)
+	if err != nil {
+		return fmt.Errorf("获取汇率失败: %w", err)
+	}
+	tableBase := normalize(table.Base)
+	// 汇率来源的基准币种和配置不同时，先换算为相对配置基准币种的汇率
+	divisor := decimal.NewFromInt(1)
+	if tableBase != c.base {
+		baseRate, ok := table.Rates[c.base]
+		if !ok || !baseRate.IsPositive() {
+			return fmt.Errorf("汇率来源 %s 缺少基准币种 %s 的汇率", c.provider.Name(), c.base)
+		}
+		divisor = baseRate
+	}
+
+	rates := make([]*billingEntity.ExchangeRate, 0, len(table.Rates)+1)
+	if tableBase != c.base {
+		rates = append(rates, &billingEntity.ExchangeRate{
+			BaseCurrency:  c.base,
+			QuoteCurrency: tableBase,
+			Rate:          utils.DecimalToFloat(decimal.NewFromInt(1).DivRound(divisor, rateScale)),
+			Source:        c.provider.Name(),
+		})
+	}
+	for currency, rate := range table.Rates {
+		if currency == c.base || !rate.IsPositive() {
+			continue
+		}
+		rates = append(rates, &billingEntity.ExchangeRate{
+			BaseCurrency:  c.base,
+			QuoteCurrency: currency,
+			Rate:          utils.DecimalToFloat(rate.DivRound(divisor, rateScale)),
+			Source:        c.provider.Name(),
+		})
+	}
+	if err := c.rateRepo.Save(ctx, rates); err != nil {
+		return fmt.Errorf("保存汇率失败: %w", err)
+	}
+	logger.Info(ctx, "exchange_rate", fmt.Sprintf("汇率已刷新 source: %s count: %d", c.provider.Name(), len(rates)))
+	return nil
+}
+
+// baseRates 查询币种相对基准币种的汇率，缺少或过期时刷新一次；刷新失败时继续使用过期汇率
+func (c *currencyConverterImpl) baseRates(ctx context.Context, currencies ...string) (map[string]decimal.Decimal, error) {
+	rates, fresh, err := c.loadRates(ctx, currencies)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		if refreshErr := c.Refresh(ctx); refreshErr != nil {
+			logger.Warn(ctx, fmt.Sprintf("exchange_rate: 刷新汇率失败: %v", refreshErr))
+		} else if rates, _, err = c.loadRates(ctx, currencies); err != nil {
+			return nil, err
+		}
+	}
+	for _, currency := range currencies {
+		if _, ok := rates[currency]; !ok {
+			return nil, fmt.Errorf("缺少汇率 %s/%s", c.base, currency)
+		}
+	}
+	return rates, nil
+}
+
+func (c *currencyConverterImpl) loadRates(ctx context.Context, currencies []string) (map[string]decimal.Decimal, bool, error) {
+	rates := make(map[string]decimal.Decimal, len(currencies))
+	fresh := true
+	expiredBefore := time.Now().Add(-c.maxAge).Unix()
+	for _, currency := range currencies {
+		if currency == c.base {
+			rates[currency] = decimal.NewFromInt(1)
+			continue
+		}
+		rate, err := c.rateRepo.Get(ctx, c.base, currency)
+		if err != nil {
+			return nil, false, fmt.Errorf("查询汇率失败: %w", err)
+		}
+		if rate == nil || rate.Rate <= 0 {
+			fresh = false
+			continue
+		}
+		if rate.UpdateTime < expiredBefore {
+			fresh = false
+		}
+		rates[currency] = decimal.NewFromFloat(rate.Rate)
+	}
+	return rates, fresh, nil
+}
@@ -0,0 +1,83 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/infras/config"
+	"backend/pkg/logger"
+)
+
+// memoryRateRepo 内存汇率表
+type memoryRateRepo struct {
+	rates map[string]*billingEntity.ExchangeRate
+}
+
+func (m *memoryRateRepo) Get(ctx context.Context, base string, quote string) (*billingEntity.ExchangeRate, error) {
+	return m.rates[base+"/"+quote], nil
+}
+
+func (m *memoryRateRepo) Save(ctx context.Context, rates []*billingEntity.ExchangeRate) error {
+	for _, rate := range rates {
+		rate.UpdateTime = time.Now().Unix()
+		m.rates[rate.BaseCurrency+"/"+rate.QuoteCurrency] = rate
+	}
+	return nil
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	logger.Default(logger.WriteToFile(false))
+	conf := &config.ExchangeRate{
+		Base:  "USD",
+		Rates: map[string]float64{"eur": 0.8, "jpy": 150},
+	}
+	repo := &memoryRateRepo{rates: map[string]*billingEntity.ExchangeRate{}}
+	converter := NewCurrencyConverter(conf, repo, NewRateProvider(conf))
+
+	tests := []struct {
+		name   string
+		amount string
+		from   string
+		to     string
+		rate   string
+		result string
+	}{
+		{"same currency", "12.34", "EUR", "eur", "1", "12.34"},
+		{"base to quote", "10", "USD", "EUR", "0.8", "8"},
+		{"quote to base", "10", "EUR", "USD", "1.25", "12.5"},
+		{"cross rate", "1000", "JPY", "EUR", "0.00533333", "5.33"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversion, err := converter.Convert(context.Background(), decimal.RequireFromString(tt.amount), tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !conversion.Rate.Equal(decimal.RequireFromString(tt.rate)) {
+				t.Errorf("rate = %s, want %s", conversion.Rate, tt.rate)
+			}
+			if !conversion.Result.Equal(decimal.RequireFromString(tt.result)) {
+				t.Errorf("result = %s, want %s", conversion.Result, tt.result)
+			}
+		})
+	}
+
+	if _, err := converter.Convert(context.Background(), decimal.NewFromInt(1), "USD", "GBP"); err == nil {
+		t.Error("missing rate should return error")
+	}
+
+	total, err := converter.ConvertSum(context.Background(), []*billingEntity.CurrencyAmount{
+		{Currency: "EUR", Amount: 8},
+		{Currency: "", Amount: 2},
+	}, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !total.Equal(decimal.NewFromInt(12)) {
+		t.Errorf("total = %s, want 12", total)
+	}
+}
@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+	"backend/internal/infras/config"
+)
+
+// DefaultBase 没有配置基准币种时使用美元
+const DefaultBase = "USD"
+
+// NewRateProvider 根据配置创建汇率来源，没有配置时使用固定汇率
+func NewRateProvider(conf *config.ExchangeRate) billings.ExchangeRateProvider {
+	base := normalize(conf.Base)
+	if base == "" {
+		base = DefaultBase
+	}
+	switch conf.Provider {
+	case billingEntity.RateSourceFile:
+		return &fileProvider{path: conf.File}
+	default:
+		return newStaticProvider(base, conf.Rates)
+	}
+}
+
+var _ billings.ExchangeRateProvider = (*staticProvider)(nil)
+
+// staticProvider 配置文件中的固定汇率，用于离线环境和测试
+type staticProvider struct {
+	table *billingEntity.RateTable
+}
+
+func newStaticProvider(base string, rates map[string]float64) *staticProvider {
+	table := &billingEntity.RateTable{
+		Base:   base,
+		Source: billingEntity.RateSourceStatic,
+		Rates:  make(map[string]decimal.Decimal, len(rates)),
+	}
+	// viper 会把 map 的 key 转成小写，这里统一转回大写币种代码
+	for currency, rate := range rates {
+		table.Rates[normalize(currency)] = decimal.NewFromFloat(rate)
+	}
+	return &staticProvider{table: table}
+}
+
+func (s *staticProvider) Name() string {
+	return billingEntity.RateSourceStatic
+}
+
+func (s *staticProvider) Rates(ctx context.Context) (*billingEntity.RateTable, error) {
+	return s.table, nil
+}
+
+var _ billings.ExchangeRateProvider = (*fileProvider)(nil)
+
+// fileProvider 从本地 JSON 文件读取汇率，每次刷新都重新读取，更新文件后不需要重启
+type fileProvider struct {
+	path string
+}
+
+type rateFile struct {
+	Base  string                     `json:"base"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+func (f *fileProvider) Name() string {
+	return billingEntity.RateSourceFile
+}
+
+func (f *fileProvider) Rates(ctx context.Context) (*billingEntity.RateTable, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("读取汇率文件失败: %w", err)
+	}
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析汇率文件失败: %w", err)
+	}
+	base := normalize(file.Base)
+	if base == "" {
+		return nil, fmt.Errorf("汇率文件缺少基准币种")
+	}
+	table := &billingEntity.RateTable{
+		Base:   base,
+		Source: billingEntity.RateSourceFile,
+		Rates:  make(map[string]decimal.Decimal, len(file.Rates)),
+	}
+	for currency, rate := range file.Rates {
+		table.Rates[normalize(currency)] = rate
+	}
+	return table, nil
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
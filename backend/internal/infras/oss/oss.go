@@ -1,9 +1,11 @@
 package oss
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"mime/multipart"
+	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 
@@ -39,8 +41,36 @@ func (a *aliYunOssRepoImpl) UploadFile(ctx context.Context, fileName string, fil
 	if err != nil {
 		return "", err
 	}
-	imagePath := "https://" + a.bucketName + "." + a.client.Config.Endpoint + "/" + fileName
+	imagePath := a.ObjectURL(fileName)
 	fmt.Println("文件上传到：", imagePath)
 	logger.Warn(ctx, "文件上传到："+imagePath)
 	return imagePath, nil
 }
+
+func (a *aliYunOssRepoImpl) PutObject(ctx context.Context, objectKey string, data []byte, contentType string) error {
+	bucket, err := a.client.Bucket(a.bucketName)
+	if err != nil {
+		return err
+	}
+	return bucket.PutObject(objectKey, bytes.NewReader(data), oss.ContentType(contentType), oss.WithContext(ctx))
+}
+
+func (a *aliYunOssRepoImpl) SignURL(ctx context.Context, objectKey string, expires time.Duration) (string, error) {
+	bucket, err := a.client.Bucket(a.bucketName)
+	if err != nil {
+		return "", err
+	}
+	return bucket.SignURL(objectKey, oss.HTTPGet, int64(expires.Seconds()), oss.WithContext(ctx))
+}
+
+func (a *aliYunOssRepoImpl) ObjectURL(objectKey string) string {
+	return "https://" + a.bucketName + "." + a.client.Config.Endpoint + "/" + objectKey
+}
+
+func (a *aliYunOssRepoImpl) DeleteObject(ctx context.Context, objectKey string) error {
+	bucket, err := a.client.Bucket(a.bucketName)
+	if err != nil {
+		return err
+	}
+	return bucket.DeleteObject(objectKey, oss.WithContext(ctx))
+}
@@ -6,32 +6,46 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"log"
+	"net/url"
 	"strings"
 
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/infras/config"
+	"backend/pkg/logger"
 )
 
 var _ shopifyRepo.ShopifyRepository = (*shopifyRepoImpl)(nil)
 
 type shopifyRepoImpl struct {
-	webhookHost string
+	webhookHost          string
+	skipVerify           bool
+	unsignedReturnBefore int64
 }
 
 var WebhookShopifyEndpoint = "api/v1/webhook/shopify"
 var PaymentCallback = "api/v1/webhook/charge_callback"
 
+// returnSignParam 支付回调地址中签名的参数名
+const returnSignParam = "sign"
+
 func NewShopifyRepository(shopifyConf *config.Shopify) shopifyRepo.ShopifyRepository {
+	if shopifyConf.SkipVerify {
+		log.Println("警告: shopify.skip_verify 已开启，webhook 和支付回调不验证签名，只能在本地开发时使用")
+	}
 	return &shopifyRepoImpl{
-		webhookHost: shopifyConf.WebhookHost,
+		webhookHost:          shopifyConf.WebhookHost,
+		skipVerify:           shopifyConf.SkipVerify,
+		unsignedReturnBefore: shopifyConf.UnsignedReturnBefore,
 	}
 }
 func (s *shopifyRepoImpl) GetWebhookUrl(appID string) string {
 
 	return fmt.Sprintf("https://%s/%s/%s", s.webhookHost, appID, WebhookShopifyEndpoint)
 }
-func (s *shopifyRepoImpl) GetReturnUrl(appID string, userID int64) string {
-	return fmt.Sprintf("https://%s/%s/%s/%d", s.webhookHost, appID, PaymentCallback, userID)
+func (s *shopifyRepoImpl) GetReturnUrl(appID string, userID int64, appSecret string) string {
+	query := url.Values{returnSignParam: {returnSign(appSecret, appID, userID)}}
+	return fmt.Sprintf("https://%s/%s/%s/%d?%s", s.webhookHost, appID, PaymentCallback, userID, query.Encode())
 }
 
 // ExtractCurrencySymbol 从 moneyFormat 提取货币符号
@@ -44,14 +58,55 @@ func (s *shopifyRepoImpl) ExtractCurrencySymbol(moneyFormat string) string {
 	return ""
 }
 
-// VerifyWebhook 验证 webhook 签名
-func (s *shopifyRepoImpl) VerifyWebhook(ctx context.Context, appSecret string, signature string, body []byte) bool {
+// VerifyWebhook 验证 webhook 签名，轮换期间新旧 Secret 都可以通过
+func (s *shopifyRepoImpl) VerifyWebhook(ctx context.Context, appSecrets []string, signature string, body []byte) bool {
+	if s.skipVerify {
+		logger.Warn(ctx, "shopify.skip_verify 已开启，跳过 webhook 签名验证")
+		return true
+	}
+	return matchSignature(appSecrets, signature, func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	})
+}
 
-	// 计算期望的签名
-	mac := hmac.New(sha256.New, []byte(appSecret))
-	mac.Write(body)
-	expectedSignature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+// VerifyReturnUrl 验证支付回调地址中的签名，防止伪造 userID
+func (s *shopifyRepoImpl) VerifyReturnUrl(ctx context.Context, appSecrets []string, appID string, userID int64, sign string) bool {
+	if s.skipVerify {
+		logger.Warn(ctx, "shopify.skip_verify 已开启，跳过支付回调签名验证")
+		return true
+	}
+	return matchSignature(appSecrets, sign, func(secret string) string {
+		return returnSign(secret, appID, userID)
+	})
+}
 
-	// 比较签名
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+// UnsignedReturnAllowed 回调地址加签名之前创建的订阅，回调地址没有签名
+func (s *shopifyRepoImpl) UnsignedReturnAllowed(createdAt int64) bool {
+	return s.unsignedReturnBefore > 0 && createdAt > 0 && createdAt < s.unsignedReturnBefore
+}
+
+// matchSignature 逐个 Secret 计算签名并做常量时间比较，没有 Secret 或签名为空时返回 false
+func matchSignature(appSecrets []string, signature string, sign func(secret string) string) bool {
+	if signature == "" {
+		return false
+	}
+	matched := false
+	for _, secret := range appSecrets {
+		if secret == "" {
+			continue
+		}
+		if hmac.Equal([]byte(signature), []byte(sign(secret))) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// returnSign 支付回调地址的签名，绑定应用和用户
+func returnSign(appSecret string, appID string, userID int64) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(fmt.Sprintf("%s:%d", appID, userID)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
@@ -1,9 +1,15 @@
 package shopify
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"net/url"
 	"strings"
 	"testing"
+
+	"backend/pkg/logger"
 )
 
 var (
@@ -36,3 +42,77 @@ func TestShopNameParse(t *testing.T) {
 	}
 
 }
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	logger.Default(logger.WriteToFile(false))
+	ctx := context.Background()
+	repo := &shopifyRepoImpl{}
+	body := []byte(`{"id":1}`)
+
+	cases := []struct {
+		name      string
+		secrets   []string
+		signature string
+		want      bool
+	}{
+		{"current secret", []string{"new", "old"}, sign("new", body), true},
+		{"rotated secret", []string{"new", "old"}, sign("old", body), true},
+		{"unknown secret", []string{"new", "old"}, sign("other", body), false},
+		{"no secret", nil, sign("", body), false},
+		{"empty secret", []string{""}, sign("", body), false},
+		{"empty signature", []string{"new"}, "", false},
+	}
+	for _, c := range cases {
+		if got := repo.VerifyWebhook(ctx, c.secrets, c.signature, body); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	skip := &shopifyRepoImpl{skipVerify: true}
+	if !skip.VerifyWebhook(ctx, nil, "", body) {
+		t.Error("skip_verify should bypass verification")
+	}
+}
+
+func TestVerifyReturnUrl(t *testing.T) {
+	ctx := context.Background()
+	repo := &shopifyRepoImpl{webhookHost: "example.com"}
+
+	returnUrl, err := url.Parse(repo.GetReturnUrl("app", 7, "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := returnUrl.Query().Get(returnSignParam)
+
+	if !repo.VerifyReturnUrl(ctx, []string{"new", "old"}, "app", 7, signature) {
+		t.Error("signature from rotated secret should pass")
+	}
+	if repo.VerifyReturnUrl(ctx, []string{"new", "old"}, "app", 8, signature) {
+		t.Error("signature must be bound to the user")
+	}
+	if repo.VerifyReturnUrl(ctx, []string{"new"}, "app", 7, signature) {
+		t.Error("signature from removed secret should fail")
+	}
+	if repo.VerifyReturnUrl(ctx, nil, "app", 7, "") {
+		t.Error("missing signature should fail")
+	}
+}
+
+func TestUnsignedReturnAllowed(t *testing.T) {
+	repo := &shopifyRepoImpl{unsignedReturnBefore: 1700000000}
+	if !repo.UnsignedReturnAllowed(1699999999) {
+		t.Error("subscription created before cutoff should be allowed")
+	}
+	if repo.UnsignedReturnAllowed(1700000000) || repo.UnsignedReturnAllowed(0) {
+		t.Error("subscription created at or after cutoff should not be allowed")
+	}
+	if (&shopifyRepoImpl{}).UnsignedReturnAllowed(1) {
+		t.Error("unsigned callbacks should be rejected without a cutoff")
+	}
+}
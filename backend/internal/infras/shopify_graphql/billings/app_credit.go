@@ -0,0 +1,118 @@
+package billings
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shopspring/decimal"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/repo/shopifys"
+	"backend/internal/infras/shopify_graphql"
+)
+
+type appCreditGraphqlRepoImpl struct {
+	shopify_graphql.Graphql
+}
+
+var _ shopifys.AppCreditGraphqlRepository = (*appCreditGraphqlRepoImpl)(nil)
+
+func NewAppCreditGraphqlRepository() shopifys.AppCreditGraphqlRepository {
+	return &appCreditGraphqlRepoImpl{}
+}
+
+func (a *appCreditGraphqlRepoImpl) CreateAppCredit(ctx context.Context, amount decimal.Decimal, currency string, description string, test bool) (string, error) {
+	mutation := `
+        mutation appCreditCreate($amount: MoneyInput!, $description: String!, $test: Boolean) {
+            appCreditCreate(amount: $amount, description: $description, test: $test) {
+                appCredit {
+                    id
+                    description
+                    amount {
+                        amount
+                        currencyCode
+                    }
+                    test
+                    createdAt
+                }
+                userErrors {
+                    field
+                    message
+                }
+            }
+        }
+    `
+
+	variables := map[string]interface{}{
+		"amount": map[string]interface{}{
+			"amount":       amount.String(),
+			"currencyCode": currency,
+		},
+		"description": description,
+		"test":        test,
+	}
+	var response struct {
+		AppCreditCreate shopifyEntity.AppCreditCreateResponse `json:"appCreditCreate"`
+	}
+	err := a.Client(ctx).Mutate(ctx, mutation, variables, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.AppCreditCreate.UserErrors) > 0 {
+		return "", errors.New(response.AppCreditCreate.UserErrors[0].Message)
+	}
+	if response.AppCreditCreate.AppCredit == nil {
+		return "", errors.New("appCreditCreate returned empty app credit")
+	}
+
+	return response.AppCreditCreate.AppCredit.ID, nil
+}
+
+func (a *appCreditGraphqlRepoImpl) FindAppCredit(ctx context.Context, description string) (string, error) {
+	var installation struct {
+		CurrentAppInstallation struct {
+			ID string `json:"id"`
+		} `json:"currentAppInstallation"`
+	}
+	if err := a.Client(ctx).Query(ctx, `query { currentAppInstallation { id } }`, nil, &installation); err != nil {
+		return "", err
+	}
+
+	query := `
+        query appCredits($id: ID!, $first: Int!, $after: String) {
+            node(id: $id) {
+                ... on AppInstallation {
+                    connection: credits(first: $first, after: $after, sortKey: CREATED_AT, reverse: true) {
+                        edges {
+                            node {
+                                id
+                                description
+                                amount {
+                                    amount
+                                    currencyCode
+                                }
+                                test
+                                createdAt
+                            }
+                        }
+                        pageInfo {
+                            hasNextPage
+                            endCursor
+                        }
+                    }
+                }
+            }
+        }
+    `
+	credits, err := shopify_graphql.PaginateNodeConnection[shopifyEntity.AppCredit](ctx, a.Client(ctx), query, installation.CurrentAppInstallation.ID, "")
+	if err != nil {
+		return "", err
+	}
+	for _, credit := range credits {
+		if credit.Description == description {
+			return credit.ID, nil
+		}
+	}
+	return "", nil
+}
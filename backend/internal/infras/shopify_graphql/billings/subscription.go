@@ -23,13 +23,14 @@ func NewSubscriptionGraphqlRepository() shopifys.SubscriptionGraphqlRepository {
 func (s *subscriptionGraphqlRepoImpl) CreateSubscription(ctx context.Context, input shopifyEntity.AppSubscriptionCreateInput) (*shopifyEntity.AppSubscription, string, error) {
 	// GraphQL mutation
 	mutation := `
-        mutation appSubscriptionCreate($name: String!, $test: Boolean, $trialDays: Int, $lineItems: [AppSubscriptionLineItemInput!]!, $returnUrl: URL!) {
+        mutation appSubscriptionCreate($name: String!, $test: Boolean, $trialDays: Int, $lineItems: [AppSubscriptionLineItemInput!]!, $returnUrl: URL!, $replacementBehavior: AppSubscriptionReplacementBehavior) {
             appSubscriptionCreate(
                 name: $name
                 test: $test
                 trialDays: $trialDays
                 lineItems: $lineItems
                 returnUrl: $returnUrl
+                replacementBehavior: $replacementBehavior
             ) {
                 appSubscription {
                     id
@@ -85,13 +86,16 @@ func (s *subscriptionGraphqlRepoImpl) CreateSubscription(ctx context.Context, in
 	if input.TrialDays > 0 {
 		variables["trialDays"] = input.TrialDays
 	}
+	if input.ReplacementBehavior != "" {
+		variables["replacementBehavior"] = input.ReplacementBehavior
+	}
 
 	// 发送请求
 	var response struct {
 		AppSubscriptionCreate shopifyEntity.AppSubscriptionCreateResponse `json:"appSubscriptionCreate"`
 	}
 
-	err := s.Client.Mutate(ctx, mutation, variables, &response)
+	err := s.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return nil, "", err
 	}
@@ -157,7 +161,7 @@ func (s *subscriptionGraphqlRepoImpl) GetCurrentSubscription(ctx context.Context
 		} `json:"currentAppInstallation"`
 	}
 
-	err := s.Client.Query(ctx, query, nil, &result)
+	err := s.Client(ctx).Query(ctx, query, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -236,10 +240,91 @@ query GetAppSubscription($id: ID!) {
 		Node shopifyEntity.AppSubscription `json:"node"`
 	}
 	// 发送请求
-	err := s.Client.Mutate(ctx, mutation, variables, &response)
+	err := s.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return nil, err
 	}
 
 	return &response.Node, nil
 }
+
+// UpdateCappedAmount 修改用量订阅的上限金额
+func (s *subscriptionGraphqlRepoImpl) UpdateCappedAmount(ctx context.Context, lineItemID string, cappedAmount shopifyEntity.MoneyInput) (string, error) {
+	mutation := `
+        mutation appSubscriptionLineItemUpdate($id: ID!, $cappedAmount: MoneyInput!) {
+            appSubscriptionLineItemUpdate(id: $id, cappedAmount: $cappedAmount) {
+                appSubscription {
+                    id
+                    status
+                }
+                confirmationUrl
+                userErrors {
+                    field
+                    message
+                }
+            }
+        }
+    `
+
+	variables := map[string]interface{}{
+		"id":           lineItemID,
+		"cappedAmount": cappedAmount,
+	}
+
+	var response struct {
+		AppSubscriptionLineItemUpdate shopifyEntity.AppSubscriptionLineItemUpdateResponse `json:"appSubscriptionLineItemUpdate"`
+	}
+	err := s.Client(ctx).Mutate(ctx, mutation, variables, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.AppSubscriptionLineItemUpdate.UserErrors) > 0 {
+		return "", fmt.Errorf("shopify error: %s",
+			response.AppSubscriptionLineItemUpdate.UserErrors[0].Message)
+	}
+	if response.AppSubscriptionLineItemUpdate.ConfirmationURL == "" {
+		return "", fmt.Errorf("appSubscriptionLineItemUpdate returned empty confirmation url")
+	}
+
+	return response.AppSubscriptionLineItemUpdate.ConfirmationURL, nil
+}
+
+// CancelSubscription 取消订阅，prorate 为 true 时按剩余天数退还固定费用
+func (s *subscriptionGraphqlRepoImpl) CancelSubscription(ctx context.Context, id string, prorate bool) (*shopifyEntity.AppSubscription, error) {
+	mutation := `
+        mutation appSubscriptionCancel($id: ID!, $prorate: Boolean) {
+            appSubscriptionCancel(id: $id, prorate: $prorate) {
+                appSubscription {
+                    id
+                    name
+                    status
+                }
+                userErrors {
+                    field
+                    message
+                }
+            }
+        }
+    `
+
+	variables := map[string]interface{}{
+		"id":      id,
+		"prorate": prorate,
+	}
+
+	var response struct {
+		AppSubscriptionCancel shopifyEntity.AppSubscriptionCancelResponse `json:"appSubscriptionCancel"`
+	}
+	err := s.Client(ctx).Mutate(ctx, mutation, variables, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.AppSubscriptionCancel.UserErrors) > 0 {
+		return nil, fmt.Errorf("shopify error: %s",
+			response.AppSubscriptionCancel.UserErrors[0].Message)
+	}
+
+	return response.AppSubscriptionCancel.AppSubscription, nil
+}
@@ -21,12 +21,12 @@ func NewUsageChargeGraphqlRepository() shopifys.UsageChargeGraphqlRepository {
 	return &usageChargeGraphqlRepoImpl{}
 }
 
-func (u *usageChargeGraphqlRepoImpl) CreateUsageCharge(ctx context.Context, lineItemId string, amount decimal.Decimal, description string) (string, error) {
+func (u *usageChargeGraphqlRepoImpl) CreateUsageCharge(ctx context.Context, lineItemId string, amount decimal.Decimal, currency string, description string, idempotencyKey string) (string, error) {
 
 	// 构建 GraphQL 请求
 	mutation := `
-        mutation appUsageRecordCreate($description: String!, $price: MoneyInput!, $subscriptionLineItemId: ID!) {
-            appUsageRecordCreate(description: $description, price: $price, subscriptionLineItemId: $subscriptionLineItemId) {
+        mutation appUsageRecordCreate($description: String!, $price: MoneyInput!, $subscriptionLineItemId: ID!, $idempotencyKey: String) {
+            appUsageRecordCreate(description: $description, price: $price, subscriptionLineItemId: $subscriptionLineItemId, idempotencyKey: $idempotencyKey) {
                 appUsageRecord {
                     id
                     description
@@ -48,21 +48,60 @@ func (u *usageChargeGraphqlRepoImpl) CreateUsageCharge(ctx context.Context, line
 		"description": description,
 		"price": map[string]interface{}{
 			"amount":       amount.String(),
-			"currencyCode": "USD",
+			"currencyCode": currency,
 		},
 		"subscriptionLineItemId": lineItemId,
 	}
-	var response shopifyEntity.AppUsageRecordCreateResponse
+	if idempotencyKey != "" {
+		variables["idempotencyKey"] = idempotencyKey
+	}
+	var response struct {
+		AppUsageRecordCreate shopifyEntity.AppUsageRecordCreateResponse `json:"appUsageRecordCreate"`
+	}
 	// 发送请求
-	err := u.Client.Mutate(ctx, mutation, variables, &response)
+	err := u.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return "", err
 	}
 
 	// 解析响应
-	if len(response.UserErrors) > 0 {
-		return "", errors.New(response.UserErrors[0].Message)
+	if len(response.AppUsageRecordCreate.UserErrors) > 0 {
+		return "", errors.New(response.AppUsageRecordCreate.UserErrors[0].Message)
+	}
+	if response.AppUsageRecordCreate.AppUsageRecord == nil {
+		return "", errors.New("appUsageRecordCreate returned empty usage record")
 	}
 
-	return response.AppUsageRecord.ID, nil
+	return response.AppUsageRecordCreate.AppUsageRecord.ID, nil
+}
+
+func (u *usageChargeGraphqlRepoImpl) ListUsageRecords(ctx context.Context, lineItemId string) ([]shopifyEntity.AppUsageRecord, error) {
+	query := `
+		query usageRecords($id: ID!, $first: Int!, $after: String) {
+			node(id: $id) {
+				... on AppSubscriptionLineItem {
+					connection: usageRecords(first: $first, after: $after) {
+						edges {
+							node {
+								id
+								description
+								idempotencyKey
+								createdAt
+								price {
+									amount
+									currencyCode
+								}
+							}
+						}
+						pageInfo {
+							hasNextPage
+							endCursor
+						}
+					}
+				}
+			}
+		}
+	`
+
+	return shopify_graphql.PaginateNodeConnection[shopifyEntity.AppUsageRecord](ctx, u.Client(ctx), query, lineItemId, "")
 }
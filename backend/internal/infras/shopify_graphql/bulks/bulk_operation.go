@@ -0,0 +1,198 @@
+package bulks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/repo/shopifys"
+	"backend/internal/infras/shopify_graphql"
+)
+
+var _ shopifys.BulkOperationGraphqlRepository = (*bulkOperationGraphqlRepoImpl)(nil)
+
+const bulkOperationFields = `
+	id
+	status
+	errorCode
+	objectCount
+	fileSize
+	url
+	partialDataUrl
+	createdAt
+	completedAt
+`
+
+type bulkOperationGraphqlRepoImpl struct {
+	shopify_graphql.Graphql
+	httpClient *http.Client
+}
+
+func NewBulkOperationGraphqlRepository() shopifys.BulkOperationGraphqlRepository {
+	return &bulkOperationGraphqlRepoImpl{httpClient: &http.Client{}}
+}
+
+func (b *bulkOperationGraphqlRepoImpl) RunQuery(ctx context.Context, query string) (*shopifyEntity.BulkOperation, error) {
+	mutation := `
+		mutation bulkOperationRunQuery($query: String!) {
+		  bulkOperationRunQuery(query: $query) {
+			bulkOperation {` + bulkOperationFields + `}
+			userErrors {
+			  field
+			  message
+			}
+		  }
+		}
+	`
+	var response shopifyEntity.BulkOperationRunQueryResponse
+	vars := map[string]interface{}{
+		"query": query,
+	}
+	if err := b.Client(ctx).Mutate(ctx, mutation, vars, &response); err != nil {
+		return nil, err
+	}
+	if len(response.BulkOperationRunQuery.UserErrors) > 0 {
+		return nil, fmt.Errorf("shopify error: %s",
+			response.BulkOperationRunQuery.UserErrors[0].Message)
+	}
+	if response.BulkOperationRunQuery.BulkOperation == nil {
+		return nil, fmt.Errorf("bulk operation is empty")
+	}
+	return response.BulkOperationRunQuery.BulkOperation, nil
+}
+
+func (b *bulkOperationGraphqlRepoImpl) RunOrdersQuery(ctx context.Context, createdFrom int64, createdTo int64) (*shopifyEntity.BulkOperation, error) {
+	// 批量查询不支持 first 参数，嵌套的连接会拆成单独的行，用 __parentId 关联订单
+	query := fmt.Sprintf(`
+		{
+		  orders(query: "created_at:>='%s' AND created_at:<'%s'", sortKey: CREATED_AT) {
+			edges {
+			  node {
+				id
+				name
+				email
+				displayFinancialStatus
+				displayFulfillmentStatus
+				fulfillments {
+				  id
+				  status
+				  createdAt
+				}
+				processedAt
+				createdAt
+				totalPriceSet {
+				  shopMoney {
+					amount
+					currencyCode
+				  }
+				}
+				subtotalPriceSet {
+				  shopMoney {
+					amount
+					currencyCode
+				  }
+				}
+				lineItems {
+				  edges {
+					node {
+					  id
+					  variantTitle
+					  sku
+					  quantity
+					  variant {
+						id
+					  }
+					  originalUnitPriceSet {
+						shopMoney {
+						  amount
+						  currencyCode
+						}
+					  }
+					}
+				  }
+				}
+			  }
+			}
+		  }
+		}
+	`, time.Unix(createdFrom, 0).UTC().Format(time.RFC3339), time.Unix(createdTo, 0).UTC().Format(time.RFC3339))
+	return b.RunQuery(ctx, query)
+}
+
+func (b *bulkOperationGraphqlRepoImpl) Get(ctx context.Context, id string) (*shopifyEntity.BulkOperation, error) {
+	query := `
+		query($id: ID!) {
+		  node(id: $id) {
+			... on BulkOperation {` + bulkOperationFields + `}
+		  }
+		}
+	`
+	var response shopifyEntity.BulkOperationResponse
+	if err := b.GetByID(ctx, id, query, &response); err != nil {
+		return nil, err
+	}
+	if response.Node == nil || response.Node.ID == "" {
+		return nil, fmt.Errorf("bulk operation %s not found", id)
+	}
+	return response.Node, nil
+}
+
+func (b *bulkOperationGraphqlRepoImpl) Wait(ctx context.Context, id string, interval time.Duration) (*shopifyEntity.BulkOperation, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		operation, err := b.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Finished() {
+			return operation, nil
+		}
+		select {
+		case <-ctx.Done():
+			return operation, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *bulkOperationGraphqlRepoImpl) Download(ctx context.Context, url string, fn func(line []byte) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download bulk operation result failed: %s", resp.Status)
+	}
+	return readLines(resp.Body, fn)
+}
+
+// readLines 逐行读取 JSONL，单行长度不受限制，跳过空行
+func readLines(r io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package bulks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	input := "{\"id\":\"1\"}\n\n{\"id\":\"2\"}\r\n{\"id\":\"3\"}"
+	var got []string
+	err := readLines(strings.NewReader(input), func(line []byte) error {
+		got = append(got, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readLines() error = %v", err)
+	}
+	want := []string{`{"id":"1"}`, `{"id":"2"}`, `{"id":"3"}`}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("readLines() = %v, want %v", got, want)
+	}
+}
+
+func TestReadLinesStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := readLines(strings.NewReader("a\nb\nc\n"), func(line []byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("readLines() error = %v after %d calls", err, calls)
+	}
+}
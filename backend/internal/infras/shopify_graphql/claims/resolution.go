@@ -0,0 +1,407 @@
+package claims
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/domain/repo/shopifys"
+	"backend/internal/infras/shopify_graphql"
+)
+
+var _ shopifys.ClaimResolutionGraphqlRepository = (*claimResolutionGraphqlRepoImpl)(nil)
+
+// draftOrderFields 草稿订单的字段，查询和创建、完成共用
+const draftOrderFields = `
+	id
+	name
+	status
+	order {
+	  id
+	  name
+	}
+`
+
+type claimResolutionGraphqlRepoImpl struct {
+	shopify_graphql.Graphql
+}
+
+func NewClaimResolutionGraphqlRepository() shopifys.ClaimResolutionGraphqlRepository {
+	return &claimResolutionGraphqlRepoImpl{}
+}
+
+func (r *claimResolutionGraphqlRepoImpl) GetClaimOrder(ctx context.Context, orderId int64) (*shopifyEntity.ClaimOrder, error) {
+	query := `
+		query claimOrder($id: ID!) {
+		  order(id: $id) {
+			id
+			email
+			currencyCode
+			customer {
+			  id
+			}
+			shippingAddress {
+			  address1
+			  address2
+			  city
+			  company
+			  countryCodeV2
+			  firstName
+			  lastName
+			  phone
+			  provinceCode
+			  zip
+			}
+			refunds {
+			  id
+			  note
+			}
+		  }
+		}
+	`
+	var response struct {
+		Order *shopifyEntity.ClaimOrder `json:"order"`
+	}
+	if err := r.GetByID(ctx, fmt.Sprintf("gid://shopify/Order/%d", orderId), query, &response); err != nil {
+		return nil, err
+	}
+	if response.Order == nil {
+		return nil, fmt.Errorf("order %d not found", orderId)
+	}
+	return response.Order, nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) SuggestedRefund(ctx context.Context, orderGid string, lineItems []shopifyEntity.RefundLineItemInput) ([]shopifyEntity.SuggestedTransaction, error) {
+	query := `
+		query suggestedRefund($id: ID!, $refundLineItems: [RefundLineItemInput!]) {
+		  order(id: $id) {
+			suggestedRefund(refundLineItems: $refundLineItems) {
+			  suggestedTransactions {
+				gateway
+				kind
+				parentTransaction {
+				  id
+				}
+				amountSet {
+				  shopMoney {
+					amount
+					currencyCode
+				  }
+				}
+			  }
+			}
+		  }
+		}
+	`
+	variables := map[string]interface{}{
+		"id":              orderGid,
+		"refundLineItems": refundLineItemsInput(lineItems),
+	}
+	var response struct {
+		Order *struct {
+			SuggestedRefund struct {
+				SuggestedTransactions []shopifyEntity.SuggestedTransaction `json:"suggestedTransactions"`
+			} `json:"suggestedRefund"`
+		} `json:"order"`
+	}
+	if err := r.Client(ctx).Query(ctx, query, variables, &response); err != nil {
+		return nil, err
+	}
+	if response.Order == nil {
+		return nil, fmt.Errorf("order %s not found", orderGid)
+	}
+	return response.Order.SuggestedRefund.SuggestedTransactions, nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) CreateRefund(ctx context.Context, orderGid string, note string, lineItems []shopifyEntity.RefundLineItemInput, transactions []shopifyEntity.SuggestedTransaction) (string, error) {
+	mutation := `
+		mutation refundCreate($input: RefundInput!) {
+		  refundCreate(input: $input) {
+			refund {
+			  id
+			}
+			userErrors {
+			  field
+			  message
+			}
+		  }
+		}
+	`
+	transactionsInput := make([]map[string]interface{}, 0, len(transactions))
+	for _, transaction := range transactions {
+		input := map[string]interface{}{
+			"orderId": orderGid,
+			"gateway": transaction.Gateway,
+			"kind":    "REFUND",
+			"amount":  transaction.AmountSet.ShopMoney.Amount.String(),
+		}
+		if transaction.ParentTransaction != nil {
+			input["parentId"] = transaction.ParentTransaction.ID
+		}
+		transactionsInput = append(transactionsInput, input)
+	}
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"orderId":         orderGid,
+			"note":            note,
+			"notify":          true,
+			"refundLineItems": refundLineItemsInput(lineItems),
+			"transactions":    transactionsInput,
+		},
+	}
+	var response shopifyEntity.RefundCreateResponse
+	if err := r.Client(ctx).Mutate(ctx, mutation, variables, &response); err != nil {
+		return "", err
+	}
+	if len(response.RefundCreate.UserErrors) > 0 {
+		return "", fmt.Errorf("shopify error: %s", response.RefundCreate.UserErrors[0].Message)
+	}
+	if response.RefundCreate.Refund == nil {
+		return "", fmt.Errorf("refundCreate returned empty refund")
+	}
+	return response.RefundCreate.Refund.ID, nil
+}
+
+// refundLineItemsInput 理赔商品已经丢失或损坏，不退回库存
+func refundLineItemsInput(lineItems []shopifyEntity.RefundLineItemInput) []map[string]interface{} {
+	input := make([]map[string]interface{}, 0, len(lineItems))
+	for _, item := range lineItems {
+		input = append(input, map[string]interface{}{
+			"lineItemId":  item.LineItemID,
+			"quantity":    item.Quantity,
+			"restockType": "NO_RESTOCK",
+		})
+	}
+	return input
+}
+
+func (r *claimResolutionGraphqlRepoImpl) GetClaimLineItems(ctx context.Context, orderGid string) ([]shopifyEntity.ClaimLineItem, error) {
+	query := `
+		query claimLineItems($id: ID!, $first: Int!, $after: String) {
+		  node(id: $id) {
+			... on Order {
+			  connection: lineItems(first: $first, after: $after) {
+				edges {
+				  node {
+					id
+					variant {
+					  id
+					}
+					discountedUnitPriceAfterAllDiscountsSet {
+					  shopMoney {
+						amount
+					  }
+					}
+				  }
+				}
+				pageInfo {
+				  hasNextPage
+				  endCursor
+				}
+			  }
+			}
+		  }
+		}
+	`
+	return shopify_graphql.PaginateNodeConnection[shopifyEntity.ClaimLineItem](ctx, r.Client(ctx), query, orderGid, "")
+}
+
+func (r *claimResolutionGraphqlRepoImpl) FindGiftCard(ctx context.Context, note string, lastCharacters string, createdFrom time.Time) (string, error) {
+	query := `
+		query giftCards($first: Int!, $after: String, $query: String!) {
+		  connection: giftCards(first: $first, after: $after, sortKey: CREATED_AT, query: $query) {
+			edges {
+			  node {
+				id
+				note
+				lastCharacters
+			  }
+			}
+			pageInfo {
+			  hasNextPage
+			  endCursor
+			}
+		  }
+		}
+	`
+	variables := map[string]interface{}{
+		"query": fmt.Sprintf("created_at:>='%s'", createdFrom.UTC().Format(time.RFC3339)),
+	}
+	giftCards, err := shopify_graphql.PaginateConnection[struct {
+		ID             string `json:"id"`
+		Note           string `json:"note"`
+		LastCharacters string `json:"lastCharacters"`
+	}](ctx, r.Client(ctx), query, variables)
+	if err != nil {
+		return "", err
+	}
+	for _, giftCard := range giftCards {
+		if giftCard.Note == note && strings.EqualFold(giftCard.LastCharacters, lastCharacters) {
+			return giftCard.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) CreateGiftCard(ctx context.Context, amount decimal.Decimal, customerGid string, note string, code string) (string, error) {
+	mutation := `
+		mutation giftCardCreate($input: GiftCardCreateInput!) {
+		  giftCardCreate(input: $input) {
+			giftCard {
+			  id
+			}
+			userErrors {
+			  field
+			  message
+			}
+		  }
+		}
+	`
+	input := map[string]interface{}{
+		"initialValue": amount.StringFixed(2),
+		"note":         note,
+		"code":         code,
+	}
+	if customerGid != "" {
+		input["customerId"] = customerGid
+	}
+	var response shopifyEntity.GiftCardCreateResponse
+	if err := r.Client(ctx).Mutate(ctx, mutation, map[string]interface{}{"input": input}, &response); err != nil {
+		return "", err
+	}
+	if len(response.GiftCardCreate.UserErrors) > 0 {
+		return "", fmt.Errorf("shopify error: %s", response.GiftCardCreate.UserErrors[0].Message)
+	}
+	if response.GiftCardCreate.GiftCard == nil {
+		return "", fmt.Errorf("giftCardCreate returned empty gift card")
+	}
+	return response.GiftCardCreate.GiftCard.ID, nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) FindDraftOrder(ctx context.Context, tag string) (*shopifyEntity.DraftOrder, error) {
+	query := `
+		query draftOrders($query: String!) {
+		  draftOrders(first: 1, query: $query) {
+			edges {
+			  node {` + draftOrderFields + `}
+			}
+		  }
+		}
+	`
+	variables := map[string]interface{}{
+		"query": fmt.Sprintf("tag:'%s'", tag),
+	}
+	var response struct {
+		DraftOrders struct {
+			Edges []struct {
+				Node shopifyEntity.DraftOrder `json:"node"`
+			} `json:"edges"`
+		} `json:"draftOrders"`
+	}
+	if err := r.Client(ctx).Query(ctx, query, variables, &response); err != nil {
+		return nil, err
+	}
+	if len(response.DraftOrders.Edges) == 0 {
+		return nil, nil
+	}
+	return &response.DraftOrders.Edges[0].Node, nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) CreateReshipDraftOrder(ctx context.Context, order *shopifyEntity.ClaimOrder, lineItems []shopifyEntity.DraftOrderLineItemInput, note string, tag string) (*shopifyEntity.DraftOrder, error) {
+	mutation := `
+		mutation draftOrderCreate($input: DraftOrderInput!) {
+		  draftOrderCreate(input: $input) {
+			draftOrder {` + draftOrderFields + `}
+			userErrors {
+			  field
+			  message
+			}
+		  }
+		}
+	`
+	lineItemsInput := make([]map[string]interface{}, 0, len(lineItems))
+	for _, item := range lineItems {
+		lineItemsInput = append(lineItemsInput, map[string]interface{}{
+			"variantId": item.VariantID,
+			"quantity":  item.Quantity,
+			"appliedDiscount": map[string]interface{}{
+				"title":     note,
+				"valueType": "PERCENTAGE",
+				"value":     100,
+			},
+		})
+	}
+	input := map[string]interface{}{
+		"note":      note,
+		"tags":      []string{tag},
+		"lineItems": lineItemsInput,
+		"shippingLine": map[string]interface{}{
+			"title": note,
+			"priceWithCurrency": map[string]interface{}{
+				"amount":       "0.00",
+				"currencyCode": order.Currency,
+			},
+		},
+	}
+	if order.Email != "" {
+		input["email"] = order.Email
+	}
+	if customerID := order.CustomerID(); customerID != "" {
+		input["purchasingEntity"] = map[string]interface{}{"customerId": customerID}
+	}
+	if address := order.ShippingAddress; address != nil {
+		input["shippingAddress"] = map[string]interface{}{
+			"address1":     address.Address1,
+			"address2":     address.Address2,
+			"city":         address.City,
+			"company":      address.Company,
+			"countryCode":  address.CountryCode,
+			"firstName":    address.FirstName,
+			"lastName":     address.LastName,
+			"phone":        address.Phone,
+			"provinceCode": address.ProvinceCode,
+			"zip":          address.Zip,
+		}
+	}
+
+	var response shopifyEntity.DraftOrderCreateResponse
+	if err := r.Client(ctx).Mutate(ctx, mutation, map[string]interface{}{"input": input}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.DraftOrderCreate.UserErrors) > 0 {
+		return nil, fmt.Errorf("shopify error: %s", response.DraftOrderCreate.UserErrors[0].Message)
+	}
+	if response.DraftOrderCreate.DraftOrder == nil {
+		return nil, fmt.Errorf("draftOrderCreate returned empty draft order")
+	}
+	return response.DraftOrderCreate.DraftOrder, nil
+}
+
+func (r *claimResolutionGraphqlRepoImpl) CompleteDraftOrder(ctx context.Context, draftOrderGid string) (*shopifyEntity.DraftOrder, error) {
+	mutation := `
+		mutation draftOrderComplete($id: ID!) {
+		  draftOrderComplete(id: $id) {
+			draftOrder {` + draftOrderFields + `}
+			userErrors {
+			  field
+			  message
+			}
+		  }
+		}
+	`
+	var response shopifyEntity.DraftOrderCompleteResponse
+	if err := r.Client(ctx).Mutate(ctx, mutation, map[string]interface{}{"id": draftOrderGid}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.DraftOrderComplete.UserErrors) > 0 {
+		return nil, fmt.Errorf("shopify error: %s", response.DraftOrderComplete.UserErrors[0].Message)
+	}
+	if response.DraftOrderComplete.DraftOrder == nil || response.DraftOrderComplete.DraftOrder.Order == nil {
+		return nil, fmt.Errorf("draftOrderComplete returned no order")
+	}
+	return response.DraftOrderComplete.DraftOrder, nil
+}
@@ -0,0 +1,105 @@
+package claims
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/internal/infras/shopify_graphql"
+)
+
+func TestCreateRefundInput(t *testing.T) {
+	var input map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables struct {
+				Input map[string]interface{} `json:"input"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		input = body.Variables.Input
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"refundCreate":{"refund":{"id":"gid://shopify/Refund/1"},"userErrors":[]}}}`))
+	}))
+	defer server.Close()
+
+	repo := NewClaimResolutionGraphqlRepository()
+	ctx := shopify_graphql.ContextWithClient(context.Background(), shopify_graphql.NewGraphqlClient("test", "token", shopify_graphql.WithEndpoint(server.URL)))
+
+	var transaction shopifyEntity.SuggestedTransaction
+	transaction.Gateway = "shopify_payments"
+	transaction.Kind = "SUGGESTED_REFUND"
+	transaction.ParentTransaction = &struct {
+		ID string `json:"id"`
+	}{ID: "gid://shopify/OrderTransaction/9"}
+	transaction.AmountSet.ShopMoney.Amount = decimal.RequireFromString("12.50")
+
+	refundID, err := repo.CreateRefund(ctx, "gid://shopify/Order/1", "Protectify claim #3",
+		[]shopifyEntity.RefundLineItemInput{{LineItemID: "gid://shopify/LineItem/2", Quantity: 1}},
+		[]shopifyEntity.SuggestedTransaction{transaction})
+	if err != nil {
+		t.Fatalf("CreateRefund() error = %v", err)
+	}
+	if refundID != "gid://shopify/Refund/1" {
+		t.Errorf("refund id = %q", refundID)
+	}
+
+	lineItem := input["refundLineItems"].([]interface{})[0].(map[string]interface{})
+	if lineItem["restockType"] != "NO_RESTOCK" {
+		t.Errorf("restockType = %v, want NO_RESTOCK", lineItem["restockType"])
+	}
+	refund := input["transactions"].([]interface{})[0].(map[string]interface{})
+	if refund["kind"] != "REFUND" || refund["parentId"] != "gid://shopify/OrderTransaction/9" || refund["amount"] != "12.5" {
+		t.Errorf("transaction = %v", refund)
+	}
+	if input["note"] != "Protectify claim #3" {
+		t.Errorf("note = %v", input["note"])
+	}
+}
+
+func TestFindGiftCardPaginates(t *testing.T) {
+	var afters []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		afters = append(afters, body.Variables["after"])
+		w.Header().Set("Content-Type", "application/json")
+		if body.Variables["after"] == nil {
+			_, _ = w.Write([]byte(`{"data":{"connection":{"edges":[
+				{"node":{"id":"gid://shopify/GiftCard/1","note":"Protectify claim #3","lastCharacters":"AAAA"}}
+			],"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"connection":{"edges":[
+			{"node":{"id":"gid://shopify/GiftCard/2","note":"Protectify claim #3","lastCharacters":"k7q2"}}
+		],"pageInfo":{"hasNextPage":false,"endCursor":"c2"}}}}`))
+	}))
+	defer server.Close()
+
+	repo := NewClaimResolutionGraphqlRepository()
+	ctx := shopify_graphql.ContextWithClient(context.Background(), shopify_graphql.NewGraphqlClient("test", "token", shopify_graphql.WithEndpoint(server.URL)))
+
+	// 同一个理赔备注的礼品卡按代码后几位区分
+	giftCardID, err := repo.FindGiftCard(ctx, "Protectify claim #3", "K7Q2", time.Now())
+	if err != nil {
+		t.Fatalf("FindGiftCard() error = %v", err)
+	}
+	if giftCardID != "gid://shopify/GiftCard/2" {
+		t.Errorf("gift card id = %q", giftCardID)
+	}
+	if len(afters) != 2 || afters[1] != "c1" {
+		t.Errorf("after cursors = %v", afters)
+	}
+}
@@ -21,29 +21,34 @@ type GraphqlClient struct {
 	accessToken   string
 	version       string
 	apiPathPrefix string
+	// endpoint 不为空时直接使用，不再按店铺拼接地址
+	endpoint string
 }
 
 func NewGraphqlClient(shopName, accessToken string, opts ...GraphqlOption) *GraphqlClient {
-	endpoint := fmt.Sprintf("https://%s.myshopify.com/%s/%s/graphql.json", shopName, defaultApiPathPrefix, defaultVersion)
-
-	client := graphql.NewClient(endpoint)
-	client.Log = func(s string) {
-		if strings.Contains(s, "errors") {
-			utils.CallWilding(s)
-		}
-		fmt.Println(s)
-	}
 	graphqlClient := &GraphqlClient{
-		client:        client,
 		shopName:      shopName,
 		accessToken:   accessToken,
 		version:       defaultVersion,
 		apiPathPrefix: defaultApiPathPrefix,
 	}
-	// apply any options
+	// apply any options，地址要在应用选项之后拼接，版本等选项才会生效
 	for _, opt := range opts {
 		opt(graphqlClient)
 	}
+	endpoint := graphqlClient.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.myshopify.com/%s/%s/graphql.json", graphqlClient.shopName, graphqlClient.apiPathPrefix, graphqlClient.version)
+	}
+
+	client := graphql.NewClient(endpoint)
+	client.Log = func(s string) {
+		if strings.Contains(s, "errors") {
+			utils.CallWilding(s)
+		}
+		fmt.Println(s)
+	}
+	graphqlClient.client = client
 	return graphqlClient
 }
 
@@ -68,6 +73,9 @@ func (c *GraphqlClient) setHeaders(req *graphql.Request) {
 
 // Query 执行 GraphQL 查询
 func (c *GraphqlClient) Query(ctx context.Context, query string, variables map[string]interface{}, response interface{}) error {
+	if c == nil {
+		return ErrNoClient
+	}
 	req := graphql.NewRequest(query)
 
 	// 添加变量
@@ -82,6 +90,9 @@ func (c *GraphqlClient) Query(ctx context.Context, query string, variables map[s
 
 // Mutate 执行 GraphQL 变更
 func (c *GraphqlClient) Mutate(ctx context.Context, mutation string, variables map[string]interface{}, response interface{}) error {
+	if c == nil {
+		return ErrNoClient
+	}
 	req := graphql.NewRequest(mutation)
 
 	// 添加变量
@@ -24,3 +24,10 @@ func WithApiPathPrefix(apiPathPrefix string) GraphqlOption {
 		g.apiPathPrefix = apiPathPrefix
 	}
 }
+
+// WithEndpoint 指定完整的 GraphQL 地址，测试时指向本地服务
+func WithEndpoint(endpoint string) GraphqlOption {
+	return func(g *GraphqlClient) {
+		g.endpoint = endpoint
+	}
+}
@@ -11,6 +11,51 @@ import (
 
 var _ shopifys.OrderGraphqlRepository = (*orderGraphqlRepoImpl)(nil)
 
+// 首次查询每个列表的数量，Shopify 单次查询的成本上限是 1000，refunds 是普通列表，
+// 退款商品的成本会乘以退款数量，超出的部分再单独翻页查询
+const (
+	orderLineItemsFirst       = 50
+	orderFulfillmentsFirst    = 20
+	orderRefundsFirst         = 10
+	orderRefundLineItemsFirst = 10
+	// allRefundsFirst 退款超过 orderRefundsFirst 时只查询退款本身，退款商品再逐个翻页
+	allRefundsFirst = 100
+)
+
+// 订单商品和退款商品的字段，首次查询和翻页查询共用
+const (
+	lineItemFields = `
+		id
+		variantTitle
+		sku
+		quantity
+		variant {
+		  id
+		}
+		originalUnitPriceSet {
+		  shopMoney {
+			amount
+			currencyCode
+		  }
+		}
+	`
+	refundLineItemFields = `
+		lineItem {
+		  id
+		  variant {
+			id
+		  }
+		}
+		quantity
+		subtotalSet {
+		  shopMoney {
+			amount
+			currencyCode
+		  }
+		}
+	`
+)
+
 type orderGraphqlRepoImpl struct {
 	shopify_graphql.Graphql
 }
@@ -19,15 +64,54 @@ func NewOrderGraphqlRepository() shopifys.OrderGraphqlRepository {
 	return &orderGraphqlRepoImpl{}
 }
 
+// GetOrderInfo 获取订单信息，订单商品和退款商品超过一页时继续翻页，返回完整的订单
 func (o *orderGraphqlRepoImpl) GetOrderInfo(ctx context.Context, orderId int64) (*shopifyEntity.OrderResponse, error) {
 	orderGId := fmt.Sprintf("gid://shopify/Order/%d", orderId)
-	query := `
+	query := orderInfoQuery()
+
+	var response shopifyEntity.OrderResponse
+	vars := map[string]interface{}{
+		"id": orderGId,
+	}
+	err := o.Client(ctx).Query(ctx, query, vars, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Order.ID == "" {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	if err := o.completeLineItems(ctx, &response.Order); err != nil {
+		return nil, fmt.Errorf("paginate order line items: %w", err)
+	}
+	if err := o.completeRefunds(ctx, &response.Order); err != nil {
+		return nil, fmt.Errorf("query order refunds: %w", err)
+	}
+	for i := range response.Order.Refunds {
+		if err := o.completeRefundLineItems(ctx, &response.Order.Refunds[i]); err != nil {
+			return nil, fmt.Errorf("paginate refund line items: %w", err)
+		}
+	}
+
+	return &response, nil
+}
+
+// orderInfoQuery 订单首次查询，每个列表的数量控制在查询成本上限内
+func orderInfoQuery() string {
+	return fmt.Sprintf(`
 		query($id: ID!) {
 		  order(id: $id) {
 			id
 			name
 			email
 			displayFinancialStatus
+			displayFulfillmentStatus
+			fulfillments(first: %d) {
+			  id
+			  status
+			  createdAt
+			}
 			processedAt
 			createdAt
 			totalPriceSet {
@@ -36,25 +120,22 @@ func (o *orderGraphqlRepoImpl) GetOrderInfo(ctx context.Context, orderId int64)
 				currencyCode
 			  }
 			}
-			lineItems(first: 100) {
+			subtotalPriceSet {
+			  shopMoney {
+				amount
+				currencyCode
+			  }
+			}
+			lineItems(first: %d) {
 			  edges {
-				node {
-			      variantTitle
-				  sku
-				  quantity
-				  variant {
-					id
-				  }
-				  originalUnitPriceSet {
-					shopMoney {
-					  amount
-					  currencyCode
-					}
-				  }
-				}
+				node {`+lineItemFields+`}
+			  }
+			  pageInfo {
+				hasNextPage
+				endCursor
 			  }
 			}
-			refunds {
+			refunds(first: %d) {
 			  id
 			  createdAt
 			  totalRefundedSet {
@@ -63,42 +144,124 @@ func (o *orderGraphqlRepoImpl) GetOrderInfo(ctx context.Context, orderId int64)
 				  currencyCode
 				}
 			  }
-			  refundLineItems(first: 100) {
+			  refundLineItems(first: %d) {
 				edges {
-				  node {
-					lineItem {
-					  id
-					  variant {
-						id
-					  }
-					}
-					quantity
-					subtotalSet {
-					  shopMoney {
-						amount
-						currencyCode
-					  }
-					}
-				  }
+				  node {`+refundLineItemFields+`}
+				}
+				pageInfo {
+				  hasNextPage
+				  endCursor
 				}
 			  }
 			}
 		  }
 		}
-	`
+	`, orderFulfillmentsFirst, orderLineItemsFirst, orderRefundsFirst, orderRefundLineItemsFirst)
+}
 
+// completeRefunds 首次查询的退款达到上限时，单独查询全部退款，新增的退款从头读取退款商品
+func (o *orderGraphqlRepoImpl) completeRefunds(ctx context.Context, order *shopifyEntity.Order) error {
+	if len(order.Refunds) < orderRefundsFirst {
+		return nil
+	}
+	query := fmt.Sprintf(`
+		query orderRefunds($id: ID!) {
+		  order(id: $id) {
+			refunds(first: %d) {
+			  id
+			  createdAt
+			  totalRefundedSet {
+				shopMoney {
+				  amount
+				  currencyCode
+				}
+			  }
+			}
+		  }
+		}
+	`, allRefundsFirst)
 	var response shopifyEntity.OrderResponse
-	vars := map[string]interface{}{
-		"id": orderGId,
+	if err := o.Client(ctx).Query(ctx, query, map[string]interface{}{"id": order.ID}, &response); err != nil {
+		return err
 	}
-	err := o.Client.Query(ctx, query, vars, &response)
-	if err != nil {
-		return nil, err
+
+	known := make(map[string]struct{}, len(order.Refunds))
+	for _, refund := range order.Refunds {
+		known[refund.ID] = struct{}{}
+	}
+	for _, refund := range response.Order.Refunds {
+		if _, ok := known[refund.ID]; ok {
+			continue
+		}
+		// 没有游标，completeRefundLineItems 从第一页开始读取
+		refund.RefundLineItems.PageInfo = shopifyEntity.PageInfo{HasNextPage: true}
+		order.Refunds = append(order.Refunds, refund)
 	}
+	return nil
+}
 
-	if response.Order.ID == "" {
-		return nil, fmt.Errorf("order not found")
+// completeLineItems 继续读取第一页之后的订单商品
+func (o *orderGraphqlRepoImpl) completeLineItems(ctx context.Context, order *shopifyEntity.Order) error {
+	if !order.LineItems.PageInfo.HasNextPage {
+		return nil
 	}
+	query := `
+		query orderLineItems($id: ID!, $first: Int!, $after: String) {
+		  node(id: $id) {
+			... on Order {
+			  connection: lineItems(first: $first, after: $after) {
+				edges {
+				  node {` + lineItemFields + `}
+				}
+				pageInfo {
+				  hasNextPage
+				  endCursor
+				}
+			  }
+			}
+		  }
+		}
+	`
+	nodes, err := shopify_graphql.PaginateNodeConnection[shopifyEntity.OrderLineItem](ctx, o.Client(ctx), query, order.ID, order.LineItems.PageInfo.EndCursor)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		order.LineItems.Edges = append(order.LineItems.Edges, shopifyEntity.OrderLineItemEdge{Node: node})
+	}
+	order.LineItems.PageInfo = shopifyEntity.PageInfo{}
+	return nil
+}
 
-	return &response, nil
+// completeRefundLineItems 继续读取第一页之后的退款商品
+func (o *orderGraphqlRepoImpl) completeRefundLineItems(ctx context.Context, refund *shopifyEntity.Refund) error {
+	if !refund.RefundLineItems.PageInfo.HasNextPage {
+		return nil
+	}
+	query := `
+		query refundLineItems($id: ID!, $first: Int!, $after: String) {
+		  node(id: $id) {
+			... on Refund {
+			  connection: refundLineItems(first: $first, after: $after) {
+				edges {
+				  node {` + refundLineItemFields + `}
+				}
+				pageInfo {
+				  hasNextPage
+				  endCursor
+				}
+			  }
+			}
+		  }
+		}
+	`
+	nodes, err := shopify_graphql.PaginateNodeConnection[shopifyEntity.RefundLineItem](ctx, o.Client(ctx), query, refund.ID, refund.RefundLineItems.PageInfo.EndCursor)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		refund.RefundLineItems.Edges = append(refund.RefundLineItems.Edges, shopifyEntity.RefundLineItemEdge{Node: node})
+	}
+	refund.RefundLineItems.PageInfo = shopifyEntity.PageInfo{}
+	return nil
 }
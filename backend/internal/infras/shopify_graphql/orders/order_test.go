@@ -0,0 +1,202 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"backend/internal/infras/shopify_graphql"
+)
+
+// fixtureServer 按查询和游标返回录制的分页响应
+func fixtureServer(t *testing.T, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Shopify-Access-Token") != "token" {
+			t.Errorf("access token header = %q", r.Header.Get("X-Shopify-Access-Token"))
+		}
+
+		fixture := "order.json"
+		switch {
+		case strings.Contains(body.Query, "query orderLineItems"):
+			fixture = fmt.Sprintf("order_line_items_%v.json", body.Variables["after"])
+		case strings.Contains(body.Query, "query refundLineItems"):
+			fixture = fmt.Sprintf("refund_line_items_%v.json", body.Variables["after"])
+		}
+		*requests = append(*requests, fixture)
+
+		data, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Errorf("unexpected request for %s: %v", fixture, err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestGetOrderInfoFollowsPageInfo(t *testing.T) {
+	var requests []string
+	server := fixtureServer(t, &requests)
+	defer server.Close()
+
+	repo := NewOrderGraphqlRepository()
+	ctx := shopify_graphql.ContextWithClient(context.Background(), shopify_graphql.NewGraphqlClient("test", "token", shopify_graphql.WithEndpoint(server.URL)))
+
+	resp, err := repo.GetOrderInfo(ctx, 5801)
+	if err != nil {
+		t.Fatalf("GetOrderInfo() error = %v", err)
+	}
+
+	wantRequests := []string{"order.json", "order_line_items_li-2.json", "order_line_items_li-4.json", "refund_line_items_rli-1.json"}
+	if strings.Join(requests, ",") != strings.Join(wantRequests, ",") {
+		t.Errorf("requests = %v, want %v", requests, wantRequests)
+	}
+
+	lineItems := resp.Order.LineItems.Edges
+	if len(lineItems) != 5 {
+		t.Fatalf("got %d line items, want 5", len(lineItems))
+	}
+	// 保险商品在最后一页
+	if got := lineItems[4].Node.Variant.ID; got != "gid://shopify/ProductVariant/900" {
+		t.Errorf("last line item variant = %s", got)
+	}
+	if resp.Order.LineItems.PageInfo.HasNextPage {
+		t.Error("line items page info should be cleared after pagination")
+	}
+
+	if len(resp.Order.Refunds) != 1 {
+		t.Fatalf("got %d refunds, want 1", len(resp.Order.Refunds))
+	}
+	refundItems := resp.Order.Refunds[0].RefundLineItems.Edges
+	if len(refundItems) != 2 {
+		t.Fatalf("got %d refund line items, want 2", len(refundItems))
+	}
+	if got := refundItems[1].Node.SubtotalSet.ShopMoney.Amount.String(); got != "13.9" {
+		t.Errorf("refunded protection amount = %s", got)
+	}
+}
+
+// queryField 查询中的一个字段，用来估算查询成本
+type queryField struct {
+	name     string
+	first    int
+	children []*queryField
+}
+
+// parseSelection 解析 { ... } 中的字段，只保留估算成本需要的名称、first 参数和子字段
+func parseSelection(tokens []string, i int) ([]*queryField, int) {
+	var fields []*queryField
+	for i < len(tokens) && tokens[i] != "}" {
+		token := tokens[i]
+		i++
+		if token == "..." {
+			// 内联片段 ... on Type { }，子字段算作当前层
+			i += 2
+			children, next := parseSelection(tokens, i+1)
+			fields = append(fields, children...)
+			i = next + 1
+			continue
+		}
+		field := &queryField{name: token}
+		if i < len(tokens) && tokens[i] == ":" {
+			// 别名
+			field.name = tokens[i+1]
+			i += 2
+		}
+		if i < len(tokens) && tokens[i] == "(" {
+			for ; tokens[i] != ")"; i++ {
+				if tokens[i] == "first" && tokens[i+1] == ":" {
+					field.first, _ = strconv.Atoi(tokens[i+2])
+				}
+			}
+			i++
+		}
+		if i < len(tokens) && tokens[i] == "{" {
+			field.children, i = parseSelection(tokens, i+1)
+			i++
+		}
+		fields = append(fields, field)
+	}
+	return fields, i
+}
+
+// fieldsCost 按 Shopify 的规则估算成本：标量 0，对象 1，
+// 带 first 的连接是 2 + first × 节点成本，带 first 的普通列表是 first × 元素成本
+func fieldsCost(fields []*queryField) int {
+	cost := 0
+	for _, field := range fields {
+		if field.children == nil {
+			continue
+		}
+		if field.first == 0 {
+			cost += 1 + fieldsCost(field.children)
+			continue
+		}
+		item := 1 + fieldsCost(field.children)
+		for _, child := range field.children {
+			if child.name == "edges" {
+				item = 0
+				for _, edge := range child.children {
+					if edge.name == "node" {
+						item = 1 + fieldsCost(edge.children)
+					}
+				}
+			}
+		}
+		cost += 2 + field.first*item
+	}
+	return cost
+}
+
+func queryCost(query string) int {
+	replacer := strings.NewReplacer("{", " { ", "}", " } ", "(", " ( ", ")", " ) ", ":", " : ", ",", " ")
+	tokens := strings.Fields(replacer.Replace(query))
+	for i, token := range tokens {
+		if token == "{" {
+			fields, _ := parseSelection(tokens, i+1)
+			return fieldsCost(fields)
+		}
+	}
+	return 0
+}
+
+func TestOrderQueryCost(t *testing.T) {
+	// 翻页查询用变量传入数量，按 connectionPageSize 计算
+	pageSize := strconv.Itoa(100)
+	queries := map[string]string{
+		"order": orderInfoQuery(),
+		"lineItems": `query($id: ID!) { node(id: $id) { ... on Order { connection: lineItems(first: ` + pageSize + `) {
+			edges { node {` + lineItemFields + `} } pageInfo { hasNextPage endCursor } } } } }`,
+		"refundLineItems": `query($id: ID!) { node(id: $id) { ... on Refund { connection: refundLineItems(first: ` + pageSize + `) {
+			edges { node {` + refundLineItemFields + `} } pageInfo { hasNextPage endCursor } } } } }`,
+	}
+	for name, query := range queries {
+		cost := queryCost(query)
+		if cost == 0 || cost > 1000 {
+			t.Errorf("%s query cost = %d, want within Shopify's limit of 1000", name, cost)
+		}
+		t.Logf("%s query cost = %d", name, cost)
+	}
+
+	// 原来 lineItems 和 refundLineItems 各取 250 时超出上限
+	if cost := queryCost(strings.Replace(orderInfoQuery(), "lineItems(first: "+strconv.Itoa(orderLineItemsFirst), "lineItems(first: 250", 1)); cost <= 1000 {
+		t.Errorf("estimator should reject 250 line items, got cost %d", cost)
+	}
+}
@@ -0,0 +1,109 @@
+package shopify_graphql
+
+import (
+	"context"
+	"fmt"
+
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+)
+
+// connectionPageSize 连接每页的数量，Shopify 最大 250，每个节点都计入查询成本，取 100 留出余量
+const connectionPageSize = 100
+
+// connectionPage 一页连接数据
+type connectionPage[T any] struct {
+	Edges []struct {
+		Node T `json:"node"`
+	} `json:"edges"`
+	PageInfo shopifyEntity.PageInfo `json:"pageInfo"`
+}
+
+// PaginateNodeConnection 从 after 开始翻页读取 node(id) 下的嵌套连接，直到 hasNextPage 为 false，返回读到的所有节点。
+// query 接收 $id、$first、$after 三个变量，并把连接字段别名为 connection，例如：
+//
+//	query($id: ID!, $first: Int!, $after: String) {
+//	  node(id: $id) {
+//	    ... on Order {
+//	      connection: lineItems(first: $first, after: $after) {
+//	        edges { node { id } }
+//	        pageInfo { hasNextPage endCursor }
+//	      }
+//	    }
+//	  }
+//	}
+func PaginateNodeConnection[T any](ctx context.Context, client *GraphqlClient, query string, id string, after string) ([]T, error) {
+	var nodes []T
+	for {
+		variables := map[string]interface{}{
+			"id":    id,
+			"first": connectionPageSize,
+		}
+		if after != "" {
+			variables["after"] = after
+		}
+
+		var response struct {
+			Node *struct {
+				Connection *connectionPage[T] `json:"connection"`
+			} `json:"node"`
+		}
+		if err := client.Query(ctx, query, variables, &response); err != nil {
+			return nil, err
+		}
+		if response.Node == nil || response.Node.Connection == nil {
+			return nil, fmt.Errorf("node %s not found", id)
+		}
+
+		page := response.Node.Connection
+		for _, edge := range page.Edges {
+			nodes = append(nodes, edge.Node)
+		}
+		// 没有游标时继续请求会从头开始，直接结束
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return nodes, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+// PaginateConnection 翻页读取根查询的连接，直到 hasNextPage 为 false，返回读到的所有节点。
+// query 除 variables 外还接收 $first、$after 两个变量，并把连接字段别名为 connection，例如：
+//
+//	query($first: Int!, $after: String, $query: String!) {
+//	  connection: giftCards(first: $first, after: $after, query: $query) {
+//	    edges { node { id } }
+//	    pageInfo { hasNextPage endCursor }
+//	  }
+//	}
+func PaginateConnection[T any](ctx context.Context, client *GraphqlClient, query string, variables map[string]interface{}) ([]T, error) {
+	var nodes []T
+	var after string
+	for {
+		pageVariables := make(map[string]interface{}, len(variables)+2)
+		for k, v := range variables {
+			pageVariables[k] = v
+		}
+		pageVariables["first"] = connectionPageSize
+		if after != "" {
+			pageVariables["after"] = after
+		}
+
+		var response struct {
+			Connection *connectionPage[T] `json:"connection"`
+		}
+		if err := client.Query(ctx, query, pageVariables, &response); err != nil {
+			return nil, err
+		}
+		if response.Connection == nil {
+			return nodes, nil
+		}
+
+		for _, edge := range response.Connection.Edges {
+			nodes = append(nodes, edge.Node)
+		}
+		if !response.Connection.PageInfo.HasNextPage || response.Connection.PageInfo.EndCursor == "" {
+			return nodes, nil
+		}
+		after = response.Connection.PageInfo.EndCursor
+	}
+}
@@ -38,7 +38,7 @@ mutation fileCreate($files: [FileCreateInput!]!) {
 		},
 	}
 	var response productEntity.FileCreateResponse
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		logger.Error(ctx, "fileCreate error: "+err.Error(), zap.Any("response", response))
 		return nil, err
@@ -78,7 +78,7 @@ mutation stagedUploadsCreate($input: [StagedUploadInput!]!) {
 	}
 
 	var response productEntity.StagedUploadsCreateResponse
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		logger.Error(ctx, "fileCreate error: "+err.Error(), zap.Any("response", response))
 		return nil, err
@@ -180,7 +180,7 @@ mutation fileUpdate($files: [FileUpdateInput!]!) {
 		},
 	}
 	var response productEntity.FileUpdateResponse
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		logger.Error(ctx, "fileUpdate error: "+err.Error(), zap.Any("response", response))
 		return nil, err
@@ -68,7 +68,7 @@ func (c *productGraphqlRepoImpl) CreateProductWithMedia(ctx context.Context, pro
 	}
 
 	var response productEntity.ProductCreateResponse
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return nil, fmt.Errorf("创建产品失败: %w", err)
 	}
@@ -99,24 +99,20 @@ func (c *productGraphqlRepoImpl) GetProduct(ctx context.Context, productID int64
                 createdAt
                 updatedAt
                 images(first: 10) {
-                    edges {
-                        node {
-                            id
-                            url
-                            altText
-                        }
+                    nodes {
+                        id
+                        url
+                        altText
                     }
                 }
-                variants(first: 50) {
-                    edges {
-                        node {
-                            id
-                            title
-                            sku
-                            price
-                            compareAtPrice
-                            inventoryQuantity
-                        }
+                variants(first: 100) {
+                    nodes {
+                        id
+                        title
+                        sku
+                        price
+                        compareAtPrice
+                        inventoryQuantity
                     }
                 }
             }
@@ -128,7 +124,7 @@ func (c *productGraphqlRepoImpl) GetProduct(ctx context.Context, productID int64
 	}
 
 	var response productEntity.ProductResponse
-	err := c.Client.Query(ctx, query, variables, &response)
+	err := c.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return nil, fmt.Errorf("查询产品失败: %w", err)
 	}
@@ -170,7 +166,7 @@ func (c *productGraphqlRepoImpl) DeleteVariant(ctx context.Context, productID in
 		} `json:"productVariantsBulkDelete"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return err
 	}
@@ -223,7 +219,7 @@ func (c *productGraphqlRepoImpl) CreateVariants(ctx context.Context, productID i
 		} `json:"productVariantsBulkCreate"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -276,7 +272,7 @@ mutation UpdateProductWithNewMedia($product: ProductUpdateInput!, $media: [Creat
 	}
 
 	var response productEntity.ProductUpdateResponse
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -318,7 +314,7 @@ mutation UpdateProductComprehensive($product: ProductUpdateInput!) {
 			} `json:"userErrors"`
 		} `json:"productUpdate"`
 	}
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return err
 	}
@@ -365,7 +361,7 @@ func (c *productGraphqlRepoImpl) PublishProduct(ctx context.Context, productID i
 			} `json:"userErrors"`
 		} `json:"publishablePublish"`
 	}
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return err
 	}
@@ -407,7 +403,7 @@ func (c *productGraphqlRepoImpl) CollectionProduct(ctx context.Context, shopifyP
 			} `json:"userErrors"`
 		} `json:"collectionAddProductsV2"`
 	}
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return err
 	}
@@ -451,7 +447,7 @@ func (c *productGraphqlRepoImpl) UpdateVariants(ctx context.Context, productId i
 			} `json:"userErrors"`
 		} `json:"productVariantsBulkUpdate"`
 	}
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return err
 	}
@@ -520,7 +516,7 @@ func (c *productGraphqlRepoImpl) GetCollectionList(ctx context.Context) ([]struc
 				Message string `json:"message"`
 			} `json:"errors"`
 		}
-		err := c.Client.Query(ctx, query, variables, &response)
+		err := c.Client(ctx).Query(ctx, query, variables, &response)
 		if err != nil {
 			return nil, err
 		}
@@ -2,18 +2,29 @@ package shopify_graphql
 
 import (
 	"context"
+	"errors"
 
 	"go.uber.org/zap"
 
+	"backend/pkg/ctxkeys"
 	"backend/pkg/logger"
 )
 
-type Graphql struct {
-	Client *GraphqlClient
+// ErrNoClient ctx 中没有店铺的 Shopify 客户端
+var ErrNoClient = errors.New("shopify graphql client not found in context")
+
+// Graphql Shopify 仓储的公共方法。仓储是所有请求和任务共享的单例，店铺的客户端通过 ctx 传入，不保存在仓储上
+type Graphql struct{}
+
+// ContextWithClient 把店铺的 Shopify 客户端放入 ctx，之后用这个 ctx 调用 Shopify 仓储
+func ContextWithClient(ctx context.Context, client *GraphqlClient) context.Context {
+	return context.WithValue(ctx, ctxkeys.ShopifyGraphqlClient, client)
 }
 
-func (b *Graphql) WithClient(client *GraphqlClient) {
-	b.Client = client
+// Client 读取 ctx 中的 Shopify 客户端，没有时返回 nil，调用 Query/Mutate 会返回 ErrNoClient
+func (b *Graphql) Client(ctx context.Context) *GraphqlClient {
+	client, _ := ctx.Value(ctxkeys.ShopifyGraphqlClient).(*GraphqlClient)
+	return client
 }
 
 func (b *Graphql) GetByID(ctx context.Context, id string, query string, response interface{}) error {
@@ -21,7 +32,7 @@ func (b *Graphql) GetByID(ctx context.Context, id string, query string, response
 	variables := map[string]interface{}{
 		"id": id,
 	}
-	err := b.Client.Query(ctx, query, variables, response)
+	err := b.Client(ctx).Query(ctx, query, variables, response)
 	if err != nil {
 		logger.Error(ctx, "Get data by ID error: "+err.Error(), zap.String("id", id), zap.Any("response", response))
 		return err
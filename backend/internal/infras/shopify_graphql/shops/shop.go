@@ -66,6 +66,7 @@ func (c *shopGraphqlRepoImpl) GetShopInfo(ctx context.Context) (*shopifyEntity.S
 				contactEmail
 				customerAccounts
 				ianaTimezone
+				timezoneOffsetMinutes
 				metafields(first: 10) {
 					edges {
 						node {
@@ -83,7 +84,7 @@ func (c *shopGraphqlRepoImpl) GetShopInfo(ctx context.Context) (*shopifyEntity.S
 	variables := map[string]interface{}{}
 
 	var response shopifyEntity.ShopResponse
-	err := c.Client.Query(ctx, query, variables, &response)
+	err := c.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return nil, nil, fmt.Errorf("查询店铺信息失败: %w", err)
 	}
@@ -128,7 +129,7 @@ func (c *shopGraphqlRepoImpl) UpdateShopBillingAddress(ctx context.Context, inpu
 		} `json:"shopBillingAddressUpdate"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return fmt.Errorf("更新店铺账单地址失败: %w", err)
 	}
@@ -171,7 +172,7 @@ func (c *shopGraphqlRepoImpl) UpdateShopSettings(ctx context.Context, input shop
 		} `json:"shopSettingsUpdate"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return fmt.Errorf("更新店铺设置失败: %w", err)
 	}
@@ -226,7 +227,7 @@ func (c *shopGraphqlRepoImpl) GetShopPolicies(ctx context.Context) (*shopifyEnti
 	variables := map[string]interface{}{}
 
 	var response shopifyEntity.ShopPoliciesResponse
-	err := c.Client.Query(ctx, query, variables, &response)
+	err := c.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return nil, fmt.Errorf("查询店铺政策失败: %w", err)
 	}
@@ -271,7 +272,7 @@ func (c *shopGraphqlRepoImpl) GetShopLocales(ctx context.Context) (*shopifyEntit
 	variables := map[string]interface{}{}
 
 	var response shopifyEntity.ShopLocalesResponse
-	err := c.Client.Query(ctx, query, variables, &response)
+	err := c.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return nil, fmt.Errorf("查询店铺语言设置失败: %w", err)
 	}
@@ -305,7 +306,7 @@ func (c *shopGraphqlRepoImpl) CreateWebhookSubscription(ctx context.Context, top
 	`
 
 	variables := map[string]interface{}{
-		"topic": topic,
+		"topic": shopifyEntity.WebhookTopicEnum(topic),
 		"webhookSubscription": map[string]interface{}{
 			"callbackUrl": callbackUrl,
 			"format":      "JSON",
@@ -321,7 +322,7 @@ func (c *shopGraphqlRepoImpl) CreateWebhookSubscription(ctx context.Context, top
 		} `json:"webhookSubscriptionCreate"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return fmt.Errorf("创建webhook订阅失败: %w", err)
 	}
@@ -372,7 +373,7 @@ func (c *shopGraphqlRepoImpl) UpdateWebhookSubscription(ctx context.Context, id
 		} `json:"webhookSubscriptionUpdate"`
 	}
 
-	err := c.Client.Mutate(ctx, mutation, variables, &response)
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
 	if err != nil {
 		return fmt.Errorf("更新webhook订阅失败: %w", err)
 	}
@@ -384,11 +385,50 @@ func (c *shopGraphqlRepoImpl) UpdateWebhookSubscription(ctx context.Context, id
 	return nil
 }
 
+// DeleteWebhookSubscription deletes a webhook subscription
+func (c *shopGraphqlRepoImpl) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	mutation := `
+		mutation webhookSubscriptionDelete($id: ID!) {
+			webhookSubscriptionDelete(id: $id) {
+				deletedWebhookSubscriptionId
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	variables := map[string]interface{}{
+		"id": id,
+	}
+
+	var response struct {
+		WebhookSubscriptionDelete struct {
+			UserErrors []struct {
+				Field   []string `json:"field"`
+				Message string   `json:"message"`
+			} `json:"userErrors"`
+		} `json:"webhookSubscriptionDelete"`
+	}
+
+	err := c.Client(ctx).Mutate(ctx, mutation, variables, &response)
+	if err != nil {
+		return fmt.Errorf("删除webhook订阅失败: %w", err)
+	}
+
+	if len(response.WebhookSubscriptionDelete.UserErrors) > 0 {
+		return fmt.Errorf("删除webhook订阅错误: %s", response.WebhookSubscriptionDelete.UserErrors[0].Message)
+	}
+
+	return nil
+}
+
 // QueryWebhookSubscriptions gets a list of webhook subscriptions
 func (c *shopGraphqlRepoImpl) QueryWebhookSubscriptions(ctx context.Context, queryParams string) ([]shopifyEntity.WebhookSubscription, error) {
 	query := `
 		query ($query: String) {
-			webhookSubscriptions(first: 20, query: $query) {
+			webhookSubscriptions(first: 100, query: $query) {
 				nodes {
 					apiVersion {
 						displayName
@@ -424,7 +464,7 @@ func (c *shopGraphqlRepoImpl) QueryWebhookSubscriptions(ctx context.Context, que
 		} `json:"webhookSubscriptions"`
 	}
 
-	err := c.Client.Query(ctx, query, variables, &response)
+	err := c.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return nil, fmt.Errorf("查询webhook订阅列表失败: %w", err)
 	}
@@ -460,7 +500,7 @@ func (c *shopGraphqlRepoImpl) GetPublicationID(ctx context.Context) (string, err
 		} `json:"errors"`
 	}
 
-	err := c.Client.Query(ctx, query, nil, &response)
+	err := c.Client(ctx).Query(ctx, query, nil, &response)
 	if err != nil {
 		return "", err
 	}
@@ -522,7 +562,7 @@ func (c *shopGraphqlRepoImpl) MetafieldSet(ctx context.Context, ownerId string,
 		} `json:"metafieldsSet"`
 	}
 
-	mErr := c.Client.Mutate(ctx, mutation, variables, &resp)
+	mErr := c.Client(ctx).Mutate(ctx, mutation, variables, &resp)
 	if mErr != nil {
 		logger.Error(ctx, "set-cart 更新metafield失败", "Err:", mErr.Error())
 		return nil, mErr
@@ -71,7 +71,7 @@ func (t *ThemeGraphqlRepoImpl) GetMainThemeSettingJson(ctx context.Context) (str
 			Nodes []shopifyEntity.OnlineStoreTheme `json:"nodes"`
 		} `json:"themes"`
 	}
-	err := t.Client.Query(ctx, query, variables, &response)
+	err := t.Client(ctx).Query(ctx, query, variables, &response)
 	if err != nil {
 		return "", fmt.Errorf("查询店铺主题设置信息失败: %w", err)
 	}
@@ -0,0 +1,53 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+var csvHeader = []string{
+	"bill_id", "order_name", "recognized_at", "currency", "order_total_amount", "order_protectify_amount",
+	"commission_rate", "commission_amount", "deducted_amount", "charge_amount", "charge_status",
+	"charge_currency", "converted_amount", "charged_at", "shopify_usage_record_id",
+}
+
+// renderCSV 每条抽成账单一行
+func renderCSV(statement *billingEntity.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	// 带 BOM，Excel 打开时才能识别 UTF-8
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, bill := range statement.Bills {
+		record := []string{
+			strconv.FormatInt(bill.Id, 10),
+			bill.OrderName,
+			formatTime(bill.RecognizedAt, "2006-01-02 15:04:05"),
+			bill.Currency,
+			formatAmount(bill.OrderTotalAmount),
+			formatAmount(bill.OrderProtectifyAmount),
+			formatAmount(bill.CommissionRate),
+			formatAmount(bill.CommissionAmount),
+			formatAmount(bill.DeductedAmount),
+			formatAmount(bill.ChargeAmount()),
+			chargeStatusText(bill.ChargeStatus),
+			bill.ChargeCurrency,
+			formatAmount(bill.ConvertedAmount),
+			formatTime(bill.ChargedAt, "2006-01-02 15:04:05"),
+			bill.ShopifyUsageRecordId,
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
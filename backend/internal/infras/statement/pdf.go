@@ -0,0 +1,179 @@
+package statement
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+// A4 纸张尺寸，单位 pt
+const (
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	lineHeight   = 14.0
+	fontSize     = 9.0
+	headerSize   = 16.0
+	sectionSize  = 11.0
+	fontFamily   = "dejavu"
+	fontRegular  = ""
+	fontBold     = "B"
+	dateLayout   = "2006-01-02"
+	maxCellRunes = 24
+)
+
+// 账单明细和调整流水的列位置
+var (
+	billColumns       = []float64{50, 150, 230, 300, 370, 440, 510}
+	adjustmentColumns = []float64{50, 150, 230, 300, 370, 440}
+)
+
+// DejaVu Sans Condensed 覆盖拉丁、希腊、西里尔、阿拉伯等文字，店铺名称和订单编号不再被替换成 ?
+// 字体按 UTF-8 方式嵌入，只保留用到的字形
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	regularFont []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	boldFont []byte
+)
+
+// pdfDocument 只做文字排版，账单不需要图片和表格线
+type pdfDocument struct {
+	pdf *fpdf.Fpdf
+	y   float64
+}
+
+func newPDFDocument() *pdfDocument {
+	pdf := fpdf.New("P", "pt", "A4", "")
+	pdf.SetAutoPageBreak(false, pageMargin)
+	pdf.AddUTF8FontFromBytes(fontFamily, fontRegular, regularFont)
+	pdf.AddUTF8FontFromBytes(fontFamily, fontBold, boldFont)
+	d := &pdfDocument{pdf: pdf}
+	d.addPage()
+	return d
+}
+
+func (d *pdfDocument) addPage() {
+	d.pdf.AddPage()
+	d.y = pageMargin
+}
+
+// ensureSpace 剩余空间不够时换页
+func (d *pdfDocument) ensureSpace(height float64) {
+	if d.y+height > pageHeight-pageMargin {
+		d.addPage()
+	}
+}
+
+func (d *pdfDocument) text(x float64, font string, size float64, s string) {
+	d.pdf.SetFont(fontFamily, font, size)
+	d.pdf.Text(x, d.y, s)
+}
+
+func (d *pdfDocument) line(font string, size float64, s string) {
+	d.ensureSpace(lineHeight)
+	d.text(pageMargin, font, size, s)
+	d.y += lineHeight
+}
+
+func (d *pdfDocument) row(columns []float64, font string, cells ...string) {
+	d.ensureSpace(lineHeight)
+	for i, cell := range cells {
+		d.text(columns[i], font, fontSize, truncate(cell, maxCellRunes))
+	}
+	d.y += lineHeight
+}
+
+func (d *pdfDocument) gap() {
+	d.y += lineHeight / 2
+}
+
+func (d *pdfDocument) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderPDF 账单包含店铺、账期、汇总金额、抽成明细以及退款调整
+func renderPDF(statement *billingEntity.Statement) ([]byte, error) {
+	summary := statement.Summary
+	d := newPDFDocument()
+
+	d.line(fontBold, headerSize, "Commission Statement")
+	d.gap()
+	d.line(fontRegular, fontSize, fmt.Sprintf("Shop: %s (%s)", statement.ShopName, statement.ShopDomain))
+	d.line(fontRegular, fontSize, fmt.Sprintf("Billing period: %s - %s (cycle %s)",
+		formatTime(summary.BillingPeriodStart, dateLayout), formatTime(summary.BillingPeriodEnd, dateLayout), summary.BillCycle))
+	d.line(fontRegular, fontSize, fmt.Sprintf("Status: %s", summary.SummaryStatus))
+	d.line(fontRegular, fontSize, fmt.Sprintf("Generated at: %s UTC", formatTime(statement.GeneratedAt, "2006-01-02 15:04:05")))
+	d.gap()
+
+	d.line(fontBold, sectionSize, fmt.Sprintf("Totals (%s)", summary.Currency))
+	totals := [][2]string{
+		{"Orders", strconv.Itoa(int(summary.OrderCount))},
+		{"Bills", strconv.Itoa(int(summary.BillCount))},
+		{"Order amount", formatAmount(summary.TotalOrderAmount)},
+		{"Protection amount", formatAmount(summary.TotalProtectifyAmount)},
+		{"Refunded order amount", formatAmount(summary.TotalRefundAmount)},
+		{"Commission", formatAmount(summary.TotalCommissionAmount)},
+		{"Refund adjustments", formatAmount(summary.AdjustmentAmount)},
+		{"Credited back", formatAmount(summary.CreditAmount)},
+		{"Paid", formatAmount(summary.PaidAmount)},
+		{"Pending", formatAmount(summary.PendingAmount)},
+		{"Failed", formatAmount(summary.ErrorAmount)},
+	}
+	for _, total := range totals {
+		d.row([]float64{pageMargin, 200}, fontRegular, total[0], total[1])
+	}
+	d.gap()
+
+	d.line(fontBold, sectionSize, "Commission bills")
+	d.row(billColumns, fontBold, "Order", "Recognized", "Currency", "Commission", "Deducted", "Charged", "Status")
+	for _, bill := range statement.Bills {
+		d.row(billColumns, fontRegular,
+			bill.OrderName,
+			formatTime(bill.RecognizedAt, dateLayout),
+			bill.Currency,
+			formatAmount(bill.CommissionAmount),
+			formatAmount(bill.DeductedAmount),
+			formatAmount(bill.ChargeAmount()),
+			chargeStatusText(bill.ChargeStatus),
+		)
+	}
+	if len(statement.Bills) == 0 {
+		d.line(fontRegular, fontSize, "No commission bills in this period.")
+	}
+	d.gap()
+
+	d.line(fontBold, sectionSize, "Refunds and adjustments")
+	d.row(adjustmentColumns, fontBold, "Order", "Date", "Type", "Amount", "Absorbed", "Credit")
+	for _, adjustment := range statement.Adjustments {
+		d.row(adjustmentColumns, fontRegular,
+			adjustment.OrderName,
+			formatTime(adjustment.CreateTime, dateLayout),
+			adjustment.AdjustmentType+"/"+adjustmentStatusText(adjustment.Status),
+			formatAmount(adjustment.Amount),
+			formatAmount(adjustment.AbsorbedAmount),
+			formatAmount(adjustment.CreditAmount),
+		)
+	}
+	if len(statement.Adjustments) == 0 {
+		d.line(fontRegular, fontSize, "No adjustments in this period.")
+	}
+
+	return d.bytes()
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "~"
+}
@@ -0,0 +1,76 @@
+package statement
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+)
+
+const (
+	contentTypeCSV = "text/csv; charset=utf-8"
+	contentTypePDF = "application/pdf"
+)
+
+var _ billings.StatementRenderer = (*rendererImpl)(nil)
+
+type rendererImpl struct{}
+
+func NewStatementRenderer() billings.StatementRenderer {
+	return &rendererImpl{}
+}
+
+func (r *rendererImpl) Render(ctx context.Context, statement *billingEntity.Statement, format string) ([]byte, string, error) {
+	if statement == nil || statement.Summary == nil {
+		return nil, "", fmt.Errorf("账期汇总为空")
+	}
+	switch format {
+	case billingEntity.StatementFormatCSV:
+		data, err := renderCSV(statement)
+		return data, contentTypeCSV, err
+	case billingEntity.StatementFormatPDF:
+		data, err := renderPDF(statement)
+		return data, contentTypePDF, err
+	default:
+		return nil, "", fmt.Errorf("不支持的账单格式: %s", format)
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// formatTime 账期按 UTC 计算，导出时间也统一用 UTC
+func formatTime(ts int64, layout string) string {
+	if ts <= 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format(layout)
+}
+
+func chargeStatusText(status int8) string {
+	switch status {
+	case billingEntity.ChargeStatusCharged:
+		return "charged"
+	case billingEntity.ChargeStatusFailed:
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
+func adjustmentStatusText(status int8) string {
+	switch status {
+	case billingEntity.AdjustmentStatusAbsorbed:
+		return "absorbed"
+	case billingEntity.AdjustmentStatusCredited:
+		return "credited"
+	case billingEntity.AdjustmentStatusFailed:
+		return "failed"
+	default:
+		return "pending"
+	}
+}
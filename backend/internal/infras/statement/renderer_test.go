@@ -0,0 +1,145 @@
+package statement
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	billingEntity "backend/internal/domain/entity/billings"
+)
+
+func testStatement() *billingEntity.Statement {
+	return &billingEntity.Statement{
+		ShopName:   "Demo (Shop)",
+		ShopDomain: "demo.myshopify.com",
+		Summary: &billingEntity.BillingPeriodSummary{
+			BillingPeriodStart:    1700000000,
+			BillingPeriodEnd:      1702592000,
+			BillCycle:             "2023-11-14",
+			Currency:              "USD",
+			TotalCommissionAmount: 12.5,
+			AdjustmentAmount:      -2.5,
+		},
+		Bills: []*billingEntity.CommissionBill{
+			{Id: 1, OrderName: "#1001", Currency: "USD", CommissionAmount: 10, DeductedAmount: 2.5, ChargeStatus: billingEntity.ChargeStatusCharged},
+			{Id: 2, OrderName: "#1002", Currency: "USD", CommissionAmount: 2.5},
+		},
+		Adjustments: []*billingEntity.CommissionAdjustment{
+			{OrderName: "#1001", AdjustmentType: billingEntity.AdjustmentTypeRefund, Amount: -2.5, AbsorbedAmount: 2.5, Status: billingEntity.AdjustmentStatusAbsorbed},
+		},
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	data, contentType, err := NewStatementRenderer().Render(context.Background(), testStatement(), billingEntity.StatementFormatCSV)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if contentType != contentTypeCSV {
+		t.Fatalf("content type = %s", contentType)
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("rows = %d, want header + 2 bills", len(records))
+	}
+	if records[1][1] != "#1001" || records[1][9] != "7.50" || records[1][10] != "charged" {
+		t.Fatalf("unexpected first bill row: %v", records[1])
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	data, contentType, err := NewStatementRenderer().Render(context.Background(), testStatement(), billingEntity.StatementFormatPDF)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if contentType != contentTypePDF {
+		t.Fatalf("content type = %s", contentType)
+	}
+	content := string(data)
+	if !strings.HasPrefix(content, "%PDF-") || !strings.HasSuffix(content, "%%EOF\n") {
+		t.Fatalf("invalid pdf envelope")
+	}
+	if !strings.Contains(pdfStreams(t, data), pdfText("Demo (Shop)")) {
+		t.Fatalf("shop name is not escaped")
+	}
+}
+
+func TestRenderPDFUnicode(t *testing.T) {
+	statement := testStatement()
+	statement.ShopName = "Магазин Ёлка"
+	statement.Bills[0].OrderName = "#Σ1001"
+	data, _, err := NewStatementRenderer().Render(context.Background(), statement, billingEntity.StatementFormatPDF)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(string(data), "/FontFile2") {
+		t.Fatalf("unicode font is not embedded")
+	}
+	streams := pdfStreams(t, data)
+	for _, s := range []string{"Shop: Магазин Ёлка (demo.myshopify.com)", "#Σ1001"} {
+		if !strings.Contains(streams, pdfText(s)) {
+			t.Errorf("pdf does not contain %q", s)
+		}
+	}
+}
+
+func TestRenderPDFPaging(t *testing.T) {
+	statement := testStatement()
+	for i := 0; i < 200; i++ {
+		statement.Bills = append(statement.Bills, &billingEntity.CommissionBill{OrderName: "#2000", Currency: "USD"})
+	}
+	data, _, err := NewStatementRenderer().Render(context.Background(), statement, billingEntity.StatementFormatPDF)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	match := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(data)
+	if match == nil {
+		t.Fatalf("page count not found")
+	}
+	if count, _ := strconv.Atoi(string(match[1])); count < 2 {
+		t.Fatalf("pages = %d, expected multiple pages", count)
+	}
+}
+
+// pdfStreams 解压 PDF 中的所有数据流并拼接
+func pdfStreams(t *testing.T, data []byte) string {
+	t.Helper()
+	var b strings.Builder
+	for _, match := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(match[1]))
+		if err != nil {
+			b.Write(match[1])
+			continue
+		}
+		decoded, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("inflate stream: %v", err)
+		}
+		b.Write(decoded)
+	}
+	return b.String()
+}
+
+// pdfText 嵌入 UTF-8 字体后文字按 UTF-16BE 写入内容流，括号和反斜杠需要转义
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, c := range utf16.Encode([]rune(s)) {
+		for _, ch := range []byte{byte(c >> 8), byte(c)} {
+			if ch == '\\' || ch == '(' || ch == ')' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -3,6 +3,8 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 
@@ -79,18 +81,24 @@ func (a *asynqRepoImpl) ProductWebhookUpdateTask(ctx context.Context, userID int
 
 }
 
-func (a *asynqRepoImpl) OrderStatisticsTask(ctx context.Context, userID int64, start int64, end int64) (*asynq.TaskInfo, error) {
-	// 初始化任务队列
-	payload := jobs.OrderStatisticPayload{UserID: userID, Start: start, End: end}
-	data, err := json.Marshal(payload)
+func (a *asynqRepoImpl) OrderStatisticsTask(ctx context.Context, at int64, days int) (*asynq.TaskInfo, error) {
+	task, err := NewOrderStatisticsTask(at, days)
 	if err != nil {
 		logger.Error(ctx, "order_statistic_queue 构建任务失败:", err.Error())
 		return nil, err
 	}
-	task := asynq.NewTask(config.SendOrderStatistics, data)
 	return a.sendEnqueue(ctx, task)
 }
 
+// NewOrderStatisticsTask 每日订单统计任务，定时任务也用它注册，at 为 0 时按执行时间统计
+func NewOrderStatisticsTask(at int64, days int) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.OrderStatisticPayload{At: at, Days: days})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendOrderStatistics, data), nil
+}
+
 func (a *asynqRepoImpl) DelProductTask(ctx context.Context, userID int64, productId int64, delType int) (*asynq.TaskInfo, error) {
 	// 初始化任务队列
 	payload := jobs.DelProductPayload{UserID: userID, ProductId: productId, DelType: delType}
@@ -104,8 +112,214 @@ func (a *asynqRepoImpl) DelProductTask(ctx context.Context, userID int64, produc
 	return a.sendEnqueue(ctx, task)
 }
 
-func (a *asynqRepoImpl) sendEnqueue(ctx context.Context, task *asynq.Task) (*asynq.TaskInfo, error) {
-	info, err := a.client.Enqueue(task)
+func (a *asynqRepoImpl) CommissionSettleTask(ctx context.Context, billID int64) (*asynq.TaskInfo, error) {
+	payload := jobs.CommissionSettlePayload{BillID: billID}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "CommissionSettleTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在提交抽成账单扣费")
+	task := asynq.NewTask(config.SendCommissionSettle, data)
+	// 扣费失败时由 asynq 退避重试，超过次数后由补偿任务继续处理
+	return a.sendEnqueue(ctx, task, asynq.MaxRetry(3))
+}
+
+func (a *asynqRepoImpl) CommissionRetryTask(ctx context.Context, lastID int64) (*asynq.TaskInfo, error) {
+	task, err := NewCommissionRetryTask(lastID)
+	if err != nil {
+		logger.Error(ctx, "CommissionRetryTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	return a.sendEnqueue(ctx, task)
+}
+
+func (a *asynqRepoImpl) CommissionCreditTask(ctx context.Context, adjustmentID int64) (*asynq.TaskInfo, error) {
+	payload := jobs.CommissionCreditPayload{AdjustmentID: adjustmentID}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "CommissionCreditTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在返还退款冲减的抽成")
+	task := asynq.NewTask(config.SendCommissionCredit, data)
+	return a.sendEnqueue(ctx, task, asynq.MaxRetry(3))
+}
+
+func (a *asynqRepoImpl) CappedAmountTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	payload := jobs.CappedAmountPayload{UserID: userID}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "CappedAmountTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在检查订阅用量上限")
+	task := asynq.NewTask(config.SendCappedAmount, data)
+	return a.sendEnqueue(ctx, task)
+}
+
+func (a *asynqRepoImpl) BillingReconcileTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	task, err := NewBillingReconcileTask(userID)
+	if err != nil {
+		logger.Error(ctx, "BillingReconcileTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在推送账单对账任务")
+	return a.sendEnqueue(ctx, task)
+}
+
+func (a *asynqRepoImpl) OrderBackfillTask(ctx context.Context, jobId int64, delay time.Duration) (*asynq.TaskInfo, error) {
+	payload := jobs.OrderBackfillPayload{JobId: jobId}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "OrderBackfillTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在回填历史订单")
+	task := asynq.NewTask(config.SendOrderBackfill, data)
+	// 导入中断后从已处理的位置继续，大店铺导入时间较长
+	opts := []asynq.Option{asynq.MaxRetry(5), asynq.Timeout(time.Hour)}
+	if delay > 0 {
+		opts = append(opts, asynq.ProcessIn(delay))
+	}
+	return a.sendEnqueue(ctx, task, opts...)
+}
+
+func (a *asynqRepoImpl) ClaimResolveTask(ctx context.Context, claimId int64) (*asynq.TaskInfo, error) {
+	payload := jobs.ClaimResolvePayload{ClaimId: claimId}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "ClaimResolveTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在处理理赔")
+	task := asynq.NewTask(config.SendClaimResolve, data)
+	// 每一步都先查找已创建的 Shopify 记录，重试不会重复退款；同一个理赔同时只有一个任务
+	return a.sendEnqueue(ctx, task, asynq.MaxRetry(5), asynq.Timeout(5*time.Minute),
+		asynq.TaskID(fmt.Sprintf("claim-resolve-%d", claimId)))
+}
+
+func (a *asynqRepoImpl) VariantSyncTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	payload := jobs.VariantSyncPayload{UserID: userID}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "VariantSyncTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在同步保险变体")
+	task := asynq.NewTask(config.SendVariantSync, data)
+	// 执行时按最新的设置和已保存的变体计算差异，重试只处理剩下的变体
+	return a.sendEnqueue(ctx, task, asynq.MaxRetry(3), asynq.Timeout(5*time.Minute))
+}
+
+func (a *asynqRepoImpl) SummaryMigrateTask(ctx context.Context, userID int64, cursor int64) (*asynq.TaskInfo, error) {
+	payload := jobs.SummaryMigratePayload{UserID: userID, Cursor: cursor}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(ctx, "SummaryMigrateTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在按店铺时区重新计算每日统计")
+	task := asynq.NewTask(config.SendSummaryMigrate, data)
+	// 重新计算会覆盖同一天的统计，重试不会重复累加
+	return a.sendEnqueue(ctx, task, asynq.MaxRetry(3), asynq.Timeout(30*time.Minute))
+}
+
+// NewBillingReconcileTask 账单对账任务，定时任务也用它注册
+func NewBillingReconcileTask(userID int64) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.BillingReconcilePayload{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendBillingReconcile, data), nil
+}
+
+func (a *asynqRepoImpl) SubscriptionSyncTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	task, err := NewSubscriptionSyncTask(userID)
+	if err != nil {
+		logger.Error(ctx, "SubscriptionSyncTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在同步订阅状态")
+	return a.sendEnqueue(ctx, task)
+}
+
+func (a *asynqRepoImpl) ProductDriftTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	task, err := NewProductDriftTask(userID)
+	if err != nil {
+		logger.Error(ctx, "ProductDriftTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在检查保险商品变体")
+	return a.sendEnqueue(ctx, task)
+}
+
+func (a *asynqRepoImpl) WebhookTask(ctx context.Context, inbox *jobs.WebhookInbox, unique bool) (*asynq.TaskInfo, error) {
+	data, err := json.Marshal(jobs.WebhookPayload{InboxId: inbox.Id})
+	if err != nil {
+		logger.Error(ctx, "WebhookTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	opts := []asynq.Option{asynq.MaxRetry(10), asynq.Timeout(5 * time.Minute)}
+	if unique {
+		opts = append(opts, asynq.TaskID(fmt.Sprintf("webhook:%d", inbox.Id)))
+	}
+	return a.sendEnqueue(ctx, asynq.NewTask(config.WebhookTask(inbox.Topic), data), opts...)
+}
+
+func (a *asynqRepoImpl) WebhookReconcileTask(ctx context.Context, userID int64) (*asynq.TaskInfo, error) {
+	task, err := NewWebhookReconcileTask(userID)
+	if err != nil {
+		logger.Error(ctx, "WebhookReconcileTask生产失败, Error：", err.Error())
+		return nil, err
+	}
+	logger.Info(ctx, "正在核对webhook订阅")
+	return a.sendEnqueue(ctx, task)
+}
+
+// NewCommissionRetryTask 抽成账单重新结算任务，定时任务也用它注册
+func NewCommissionRetryTask(lastID int64) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.CommissionRetryPayload{LastID: lastID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendCommissionRetry, data), nil
+}
+
+// NewSubscriptionSyncTask 订阅状态同步任务，定时任务也用它注册
+func NewSubscriptionSyncTask(userID int64) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.SubscriptionSyncPayload{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendSubscriptionSync, data, asynq.Timeout(30*time.Minute)), nil
+}
+
+// NewProductDriftTask 保险商品变体检查任务，定时任务也用它注册
+func NewProductDriftTask(userID int64) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.ProductDriftPayload{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendProductDrift, data, asynq.Timeout(30*time.Minute)), nil
+}
+
+// NewWebhookReconcileTask webhook 订阅核对任务，定时任务也用它注册
+func NewWebhookReconcileTask(userID int64) (*asynq.Task, error) {
+	data, err := json.Marshal(jobs.WebhookReconcilePayload{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(config.SendWebhookReconcile, data, asynq.Timeout(30*time.Minute)), nil
+}
+
+// NewTokenReencryptTask 用最新密钥重新加密 Shopify token，定时任务也用它注册
+func NewTokenReencryptTask() (*asynq.Task, error) {
+	return asynq.NewTask(config.SendTokenReencrypt, nil, asynq.Timeout(30*time.Minute)), nil
+}
+
+func (a *asynqRepoImpl) sendEnqueue(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	info, err := a.client.Enqueue(task, opts...)
 	if err != nil {
 		logger.Error(ctx, "推送"+task.Type()+"队列失败:", err.Error())
 		return nil, err
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"backend/pkg/logger"
+)
+
+const (
+	// leaderKey 运行定时任务调度器的 job 实例
+	leaderKey = "scheduler:leader"
+	// defaultLeaderTTL leader 锁的默认有效期，每 1/3 有效期续期一次
+	defaultLeaderTTL = 30 * time.Second
+)
+
+// renewLeader 只有锁还属于当前实例时才续期
+var renewLeader = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeader 只删除当前实例持有的锁
+var releaseLeader = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Leader 多个 job 实例通过 redis 锁选出一个实例运行定时任务调度器，避免同一个定时任务被推送多次
+type Leader struct {
+	client redis.UniversalClient
+	id     string
+	ttl    time.Duration
+}
+
+func NewLeader(client redis.UniversalClient, ttl time.Duration) *Leader {
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	hostname, _ := os.Hostname()
+	return &Leader{
+		client: client,
+		id:     fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		ttl:    ttl,
+	}
+}
+
+// ID 当前实例在 leader 锁中的标识
+func (l *Leader) ID() string {
+	return l.id
+}
+
+// Run 竞选 leader，当选后调用 lead 并定时续期，续期失败或 lead 返回时退出 leader 并重新竞选。
+// lead 需要在 ctx 结束后返回。ctx 结束时释放锁并返回
+func (l *Leader) Run(ctx context.Context, lead func(ctx context.Context) error) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		ok, err := l.client.SetNX(ctx, leaderKey, l.id, l.ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			logger.Warn(ctx, "scheduler: 竞选 leader 失败", err)
+		}
+		if ok {
+			l.lead(ctx, ticker, lead)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Leader) lead(ctx context.Context, ticker *time.Ticker, lead func(ctx context.Context) error) {
+	logger.Info(ctx, fmt.Sprintf("scheduler: %s 当选 leader", l.id))
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- lead(leadCtx)
+	}()
+
+	renewed := time.Now()
+	for {
+		select {
+		case err := <-done:
+			// 调度器异常退出时释放锁，让其他实例接管
+			logger.Error(ctx, fmt.Sprintf("scheduler: %s 的调度器退出", l.id), err)
+			l.release()
+			return
+		case <-ctx.Done():
+			<-done
+			l.release()
+			return
+		case <-ticker.C:
+			ok, err := renewLeader.Run(ctx, l.client, []string{leaderKey}, l.id, l.ttl.Milliseconds()).Bool()
+			if err == nil && ok {
+				renewed = time.Now()
+				continue
+			}
+			// redis 暂时不可用时锁过期前继续调度，锁已经属于其他实例或已经过期时立即停止
+			if err != nil && time.Since(renewed) < l.ttl {
+				logger.Warn(ctx, "scheduler: leader 续期失败", err)
+				continue
+			}
+			logger.Warn(ctx, fmt.Sprintf("scheduler: %s 失去 leader", l.id))
+			cancel()
+			<-done
+			return
+		}
+	}
+}
+
+// release 主动释放锁，其他实例不用等锁过期
+func (l *Leader) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := releaseLeader.Run(ctx, l.client, []string{leaderKey}, l.id).Err(); err != nil {
+		logger.Warn(ctx, "scheduler: 释放 leader 失败", err)
+	}
+}
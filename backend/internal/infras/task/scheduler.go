@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+
+	"backend/internal/domain/entity/jobs"
+	jobRepo "backend/internal/domain/repo/jobs"
+	"backend/internal/infras/config"
+	"backend/pkg/logger"
+)
+
+// scheduleLastRunKey 每个定时任务上次推送的时间，leader 切换后也能查到
+const scheduleLastRunKey = "scheduler:last_run"
+
+// ScheduledTask 定时任务，Cron 为默认的 cron 表达式，可以在 app_conf.scheduler.tasks 中按 Name 覆盖
+type ScheduledTask struct {
+	Name    string
+	Cron    string
+	NewTask func() (*asynq.Task, error)
+}
+
+// ScheduledTasks job 进程的所有定时任务
+var ScheduledTasks = []ScheduledTask{
+	// 每小时按店铺时区统计当天和前一天的订单，前一天的退款和延迟同步的订单在第二天修正
+	{Name: "order_statistics", Cron: "5 * * * *", NewTask: func() (*asynq.Task, error) { return NewOrderStatisticsTask(0, 2) }},
+	// 补上错过的订阅 webhook 和计费周期滚动
+	{Name: "subscription_sync", Cron: "15 */6 * * *", NewTask: func() (*asynq.Task, error) { return NewSubscriptionSyncTask(0) }},
+	// 检查商家在 Shopify 后台改动的保险商品变体
+	{Name: "product_drift", Cron: "45 4 * * *", NewTask: func() (*asynq.Task, error) { return NewProductDriftTask(0) }},
+	// 补上缺少的 webhook 订阅，修正过期的回调地址
+	{Name: "webhook_reconcile", Cron: "20 5 * * *", NewTask: func() (*asynq.Task, error) { return NewWebhookReconcileTask(0) }},
+	// 重新结算待提交和提交失败的抽成账单
+	{Name: "billing_settlement", Cron: "*/15 * * * *", NewTask: func() (*asynq.Task, error) { return NewCommissionRetryTask(0) }},
+	// 核对抽成账单和 Shopify 用量记录
+	{Name: "billing_reconcile", Cron: "30 3 * * *", NewTask: func() (*asynq.Task, error) { return NewBillingReconcileTask(0) }},
+	// 轮换密钥后把 token 重新加密，顺带加密历史明文
+	{Name: "token_reencrypt", Cron: "50 2 * * *", NewTask: NewTokenReencryptTask},
+}
+
+// Schedule 合并配置后的定时任务
+type Schedule struct {
+	ScheduledTask
+	Enabled bool
+}
+
+// Schedules 按配置覆盖默认的 cron 表达式和启用状态，配置了未知的任务或无效的 cron 表达式时返回错误
+func Schedules(conf *config.Scheduler) ([]Schedule, error) {
+	known := make(map[string]bool, len(ScheduledTasks))
+	schedules := make([]Schedule, 0, len(ScheduledTasks))
+	for _, task := range ScheduledTasks {
+		known[task.Name] = true
+		schedule := Schedule{ScheduledTask: task, Enabled: true}
+		if taskConf, ok := conf.Tasks[task.Name]; ok {
+			if taskConf.Cron != "" {
+				schedule.Cron = taskConf.Cron
+			}
+			if taskConf.Enabled != nil {
+				schedule.Enabled = *taskConf.Enabled
+			}
+		}
+		if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+			return nil, fmt.Errorf("定时任务 %s 的 cron 表达式 %q 无效: %w", task.Name, schedule.Cron, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	for name := range conf.Tasks {
+		if !known[name] {
+			return nil, fmt.Errorf("未知的定时任务: %s", name)
+		}
+	}
+	return schedules, nil
+}
+
+// ScheduleLocation cron 表达式使用的时区，没有配置时使用 UTC
+func ScheduleLocation(conf *config.Scheduler) (*time.Location, error) {
+	if conf.Location == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(conf.Location)
+}
+
+// RegisterSchedules 把启用的定时任务注册到调度器
+func RegisterSchedules(scheduler *asynq.Scheduler, schedules []Schedule) error {
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+		task, err := schedule.NewTask()
+		if err != nil {
+			return fmt.Errorf("定时任务 %s 创建失败: %w", schedule.Name, err)
+		}
+		if _, err := scheduler.Register(schedule.Cron, task); err != nil {
+			return fmt.Errorf("定时任务 %s 注册失败: %w", schedule.Name, err)
+		}
+	}
+	return nil
+}
+
+// RecordScheduleRun 调度器推送任务后记录推送时间，用作 SchedulerOpts.PostEnqueueFunc
+func RecordScheduleRun(client redis.UniversalClient) func(info *asynq.TaskInfo, err error) {
+	return func(info *asynq.TaskInfo, err error) {
+		ctx := context.Background()
+		if err != nil {
+			logger.Error(ctx, "scheduler: 定时任务推送失败", err)
+			return
+		}
+		if err := client.HSet(ctx, scheduleLastRunKey, info.Type, time.Now().Unix()).Err(); err != nil {
+			logger.Warn(ctx, "scheduler: 记录定时任务执行时间失败", err)
+		}
+	}
+}
+
+var _ jobRepo.SchedulerRepository = (*schedulerRepoImpl)(nil)
+
+type schedulerRepoImpl struct {
+	client redis.UniversalClient
+	conf   *config.Scheduler
+}
+
+// NewSchedulerRepository 定时任务状态，配置和 job 进程使用同一个 app.yaml
+func NewSchedulerRepository(client redis.UniversalClient, conf *config.Scheduler) jobRepo.SchedulerRepository {
+	return &schedulerRepoImpl{client: client, conf: conf}
+}
+
+func (s *schedulerRepoImpl) Status(ctx context.Context) (*jobs.SchedulerStatus, error) {
+	schedules, err := Schedules(s.conf)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := ScheduleLocation(s.conf)
+	if err != nil {
+		return nil, err
+	}
+	leader, err := s.client.Get(ctx, leaderKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	lastRuns, err := s.client.HGetAll(ctx, scheduleLastRunKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(loc)
+	status := &jobs.SchedulerStatus{Leader: leader, Location: loc.String()}
+	for _, schedule := range schedules {
+		task, err := schedule.NewTask()
+		if err != nil {
+			return nil, err
+		}
+		item := jobs.ScheduleStatus{
+			Name:     schedule.Name,
+			TaskType: task.Type(),
+			Cron:     schedule.Cron,
+			Enabled:  schedule.Enabled,
+		}
+		if lastRun, ok := lastRuns[task.Type()]; ok {
+			_, _ = fmt.Sscan(lastRun, &item.LastRun)
+		}
+		if schedule.Enabled {
+			// Schedules 已经校验过 cron 表达式
+			spec, _ := cron.ParseStandard(schedule.Cron)
+			item.NextRun = spec.Next(now).Unix()
+		}
+		status.Tasks = append(status.Tasks, item)
+	}
+	return status, nil
+}
@@ -0,0 +1,60 @@
+package task
+
+import (
+	"testing"
+
+	"backend/internal/infras/config"
+)
+
+func TestSchedules(t *testing.T) {
+	disabled := false
+	schedules, err := Schedules(&config.Scheduler{Tasks: map[string]config.ScheduleTask{
+		"order_statistics": {Cron: "0 * * * *"},
+		"product_drift":    {Enabled: &disabled},
+	}})
+	if err != nil {
+		t.Fatalf("Schedules() error = %v", err)
+	}
+	if len(schedules) != len(ScheduledTasks) {
+		t.Fatalf("Schedules() = %d tasks, want %d", len(schedules), len(ScheduledTasks))
+	}
+	got := make(map[string]Schedule, len(schedules))
+	for _, schedule := range schedules {
+		got[schedule.Name] = schedule
+	}
+	if s := got["order_statistics"]; s.Cron != "0 * * * *" || !s.Enabled {
+		t.Errorf("order_statistics = %q enabled=%v, want overridden cron and enabled", s.Cron, s.Enabled)
+	}
+	if s := got["product_drift"]; s.Cron != "45 4 * * *" || s.Enabled {
+		t.Errorf("product_drift = %q enabled=%v, want default cron and disabled", s.Cron, s.Enabled)
+	}
+	if s := got["billing_reconcile"]; s.Cron != "30 3 * * *" || !s.Enabled {
+		t.Errorf("billing_reconcile = %q enabled=%v, want default cron and enabled", s.Cron, s.Enabled)
+	}
+	if s := got["token_reencrypt"]; s.Cron != "50 2 * * *" || !s.Enabled {
+		t.Errorf("token_reencrypt = %q enabled=%v, want default cron and enabled", s.Cron, s.Enabled)
+	}
+	for _, schedule := range schedules {
+		task, err := schedule.NewTask()
+		if err != nil || task.Type() == "" {
+			t.Errorf("%s NewTask() = %v, %v", schedule.Name, task, err)
+		}
+	}
+}
+
+func TestSchedulesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks map[string]config.ScheduleTask
+	}{
+		{"unknown task", map[string]config.ScheduleTask{"order_stats": {Cron: "5 * * * *"}}},
+		{"invalid cron", map[string]config.ScheduleTask{"billing_reconcile": {Cron: "30 3 * *"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Schedules(&config.Scheduler{Tasks: tt.tasks}); err == nil {
+				t.Error("Schedules() error = nil, want error")
+			}
+		})
+	}
+}
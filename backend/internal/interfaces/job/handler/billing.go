@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+
+	"backend/internal/application/jobs"
+	"backend/internal/application/users"
+)
+
+type BillingHandler struct {
+	commissionService   *jobs.CommissionService
+	cappedAmountService *jobs.CappedAmountService
+	reconcileService    *jobs.ReconciliationService
+	subscriptionService *users.SubscriptionService
+}
+
+func (h *BillingHandler) HandleCommissionSettle(ctx context.Context, task *asynq.Task) error {
+	return h.commissionService.HandleCommissionSettle(ctx, task)
+}
+
+func (h *BillingHandler) HandleCommissionRetry(ctx context.Context, task *asynq.Task) error {
+	return h.commissionService.HandleCommissionRetry(ctx, task)
+}
+
+func (h *BillingHandler) HandleCommissionCredit(ctx context.Context, task *asynq.Task) error {
+	return h.commissionService.HandleCommissionCredit(ctx, task)
+}
+
+func (h *BillingHandler) HandleCappedAmount(ctx context.Context, task *asynq.Task) error {
+	return h.cappedAmountService.HandleCappedAmount(ctx, task)
+}
+
+func (h *BillingHandler) HandleBillingReconcile(ctx context.Context, task *asynq.Task) error {
+	return h.reconcileService.HandleBillingReconcile(ctx, task)
+}
+
+func (h *BillingHandler) HandleSubscriptionSync(ctx context.Context, task *asynq.Task) error {
+	return h.subscriptionService.HandleSubscriptionSync(ctx, task)
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+
+	"backend/internal/application/jobs"
+)
+
+type ClaimHandler struct {
+	claimService *jobs.ClaimService
+}
+
+func (h ClaimHandler) HandleClaimResolve(ctx context.Context, task *asynq.Task) error {
+	return h.claimService.HandleClaimResolve(ctx, task)
+}
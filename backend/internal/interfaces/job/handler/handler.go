@@ -9,6 +9,9 @@ type Handlers struct {
 	ProductHandler *ProductHandler
 	UserHandler    *UserHandler
 	OrderHandler   *OrderHandler
+	BillingHandler *BillingHandler
+	ClaimHandler   *ClaimHandler
+	WebhookHandler *WebhookHandler
 }
 
 func InitHanders(services *application.Services) *Handlers {
@@ -22,5 +25,22 @@ func InitHanders(services *application.Services) *Handlers {
 		&OrderHandler{
 			orderService: services.OrderJobService,
 		},
+		&BillingHandler{
+			commissionService:   services.CommissionJobService,
+			cappedAmountService: services.CappedAmountJobService,
+			reconcileService:    services.ReconciliationJobService,
+			subscriptionService: services.SubscriptionService,
+		},
+		&ClaimHandler{
+			claimService: services.ClaimJobService,
+		},
+		&WebhookHandler{
+			webhookService:      services.WebhookJobService,
+			orderService:        services.OrderService,
+			productService:      services.ProductService,
+			userService:         services.UserService,
+			subscriptionService: services.SubscriptionService,
+			redactService:       services.RedactService,
+		},
 	}
 }
@@ -19,3 +19,11 @@ func (h OrderHandler) HandleOrder(ctx context.Context, task *asynq.Task) error {
 func (h OrderHandler) HandleOrderStatistics(ctx context.Context, task *asynq.Task) error {
 	return h.orderService.HandleOrderStatistics(ctx, task)
 }
+
+func (h OrderHandler) HandleOrderBackfill(ctx context.Context, task *asynq.Task) error {
+	return h.orderService.HandleOrderBackfill(ctx, task)
+}
+
+func (h OrderHandler) HandleSummaryMigrate(ctx context.Context, task *asynq.Task) error {
+	return h.orderService.HandleSummaryMigrate(ctx, task)
+}
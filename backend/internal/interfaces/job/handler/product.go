@@ -31,3 +31,11 @@ func (p *ProductHandler) HandleDelProduct(ctx context.Context, task *asynq.Task)
 func (p *ProductHandler) HandleShopifyProduct(ctx context.Context, task *asynq.Task) error {
 	return p.productService.HandleShopifyProduct(ctx, task)
 }
+
+func (p *ProductHandler) HandleVariantSync(ctx context.Context, task *asynq.Task) error {
+	return p.productService.HandleVariantSync(ctx, task)
+}
+
+func (p *ProductHandler) HandleProductDrift(ctx context.Context, task *asynq.Task) error {
+	return p.productService.HandleProductDrift(ctx, task)
+}
@@ -15,3 +15,11 @@ type UserHandler struct {
 func (h *UserHandler) HandleInitUser(ctx context.Context, task *asynq.Task) error {
 	return h.userService.HandleInitUser(ctx, task)
 }
+
+func (h *UserHandler) HandleWebhookReconcile(ctx context.Context, task *asynq.Task) error {
+	return h.userService.HandleWebhookReconcile(ctx, task)
+}
+
+func (h *UserHandler) HandleTokenReencrypt(ctx context.Context, task *asynq.Task) error {
+	return h.userService.HandleTokenReencrypt(ctx, task)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+
+	"backend/internal/application/jobs"
+	"backend/internal/application/orders"
+	"backend/internal/application/products"
+	"backend/internal/application/users"
+	jobEntity "backend/internal/domain/entity/jobs"
+	orderEntity "backend/internal/domain/entity/orders"
+	productEntity "backend/internal/domain/entity/products"
+	shopifyEntity "backend/internal/domain/entity/shopifys"
+	"backend/pkg/logger"
+	"backend/pkg/utils"
+)
+
+type WebhookHandler struct {
+	webhookService      *jobs.WebhookService
+	orderService        *orders.OrderService
+	productService      *products.ProductService
+	userService         *users.UserService
+	subscriptionService *users.SubscriptionService
+	redactService       *users.RedactService
+}
+
+type webhookResource struct {
+	ID int64 `json:"id"`
+}
+
+type webhookShop struct {
+	PlanDisplayName string `json:"plan_display_name"`
+}
+
+// HandleWebhook 没有单独处理的 topic，只标记为已处理
+func (h *WebhookHandler) HandleWebhook(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		logger.Info(ctx, "webhook_queue: 未处理的 topic", inbox.Topic, inbox.Shop)
+		return nil
+	})
+}
+
+func (h *WebhookHandler) HandleOrderUpdated(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data webhookResource
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.orderService.OrderSync(ctx, inbox.AppId, orderEntity.OrderWebHookReq{Shop: inbox.Shop, OrderId: data.ID, AppId: inbox.AppId})
+	})
+}
+
+func (h *WebhookHandler) HandleOrderDeleted(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data webhookResource
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.orderService.OrderDel(ctx, orderEntity.OrderWebHookReq{Shop: inbox.Shop, OrderId: data.ID, AppId: inbox.AppId})
+	})
+}
+
+func (h *WebhookHandler) HandleProductUpdated(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data webhookResource
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.productService.ProductUpdate(ctx, productEntity.ProductWebHookReq{Shop: inbox.Shop, ProductId: data.ID, AppId: inbox.AppId})
+	})
+}
+
+func (h *WebhookHandler) HandleProductDeleted(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data webhookResource
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.productService.ProductDel(ctx, productEntity.ProductWebHookReq{Shop: inbox.Shop, ProductId: data.ID, AppId: inbox.AppId})
+	})
+}
+
+// HandleSubscriptionUpdate 订阅更新和取消都按 webhook 中的状态更新
+func (h *WebhookHandler) HandleSubscriptionUpdate(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var subscription shopifyEntity.SubscriptionWebhookPayload
+		if err := decodeWebhook(inbox, &subscription); err != nil {
+			return err
+		}
+		user, err := h.userService.GetUserFromShopID(ctx, utils.GetIdFromShopifyGraphqlId(subscription.AdminGraphqlApiShopId))
+		if err != nil {
+			return err
+		}
+		return h.subscriptionService.UpdateSubscriptionStatus(ctx, user, utils.GetIdFromShopifyGraphqlId(subscription.AdminGraphqlApiId), subscription.Status)
+	})
+}
+
+func (h *WebhookHandler) HandleApproachingCappedAmount(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var approaching shopifyEntity.SubscriptionApproachingPayload
+		if err := decodeWebhook(inbox, &approaching); err != nil {
+			return err
+		}
+		return h.subscriptionService.HandleApproachingCappedAmount(ctx, utils.GetIdFromShopifyGraphqlId(approaching.AdminGraphqlApiId))
+	})
+}
+
+func (h *WebhookHandler) HandleAppUninstalled(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		return h.userService.Uninstall(ctx, inbox.AppId, inbox.Shop)
+	})
+}
+
+func (h *WebhookHandler) HandleShopUpdate(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data webhookShop
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.userService.SyncShopifyUserInfo(ctx, inbox.AppId, inbox.Shop, data.PlanDisplayName)
+	})
+}
+
+func (h *WebhookHandler) HandleDataRequest(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data shopifyEntity.CompliancePayload
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.redactService.DataRequest(ctx, inbox.AppId, inbox.Id, &data)
+	})
+}
+
+func (h *WebhookHandler) HandleCustomerRedact(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data shopifyEntity.CompliancePayload
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.redactService.RedactCustomer(ctx, inbox.AppId, inbox.Id, &data)
+	})
+}
+
+func (h *WebhookHandler) HandleShopRedact(ctx context.Context, task *asynq.Task) error {
+	return h.webhookService.Process(ctx, task, func(ctx context.Context, inbox *jobEntity.WebhookInbox) error {
+		var data shopifyEntity.CompliancePayload
+		if err := decodeWebhook(inbox, &data); err != nil {
+			return err
+		}
+		return h.redactService.RedactShop(ctx, inbox.AppId, inbox.Id, &data)
+	})
+}
+
+// decodeWebhook 解析 webhook 内容，解析失败重试也不会成功
+func decodeWebhook(inbox *jobEntity.WebhookInbox, v interface{}) error {
+	if err := json.Unmarshal([]byte(inbox.Payload), v); err != nil {
+		return fmt.Errorf("解析 %s webhook 失败: %v: %w", inbox.Topic, err, asynq.SkipRetry)
+	}
+	return nil
+}
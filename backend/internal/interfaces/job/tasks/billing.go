@@ -0,0 +1,17 @@
+package tasks
+
+import (
+	"github.com/hibiken/asynq"
+
+	"backend/internal/infras/config"
+	"backend/internal/interfaces/job/handler"
+)
+
+func RegisterBillingHandler(mux *asynq.ServeMux, handler *handler.BillingHandler) {
+	mux.HandleFunc(config.SendCommissionSettle, handler.HandleCommissionSettle)
+	mux.HandleFunc(config.SendCommissionRetry, handler.HandleCommissionRetry)
+	mux.HandleFunc(config.SendCommissionCredit, handler.HandleCommissionCredit)
+	mux.HandleFunc(config.SendCappedAmount, handler.HandleCappedAmount)
+	mux.HandleFunc(config.SendBillingReconcile, handler.HandleBillingReconcile)
+	mux.HandleFunc(config.SendSubscriptionSync, handler.HandleSubscriptionSync)
+}
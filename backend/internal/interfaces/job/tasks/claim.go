@@ -0,0 +1,14 @@
+package tasks
+
+import (
+	"github.com/hibiken/asynq"
+
+	"backend/internal/infras/config"
+	"backend/internal/interfaces/job/handler"
+)
+
+func RegisterClaimHandler(mux *asynq.ServeMux, handler *handler.ClaimHandler) {
+
+	mux.HandleFunc(config.SendClaimResolve, handler.HandleClaimResolve)
+
+}
@@ -11,5 +11,7 @@ func RegisterOrderHandler(mux *asynq.ServeMux, handler *handler.OrderHandler) {
 
 	mux.HandleFunc(config.SendOrder, handler.HandleOrder)
 	mux.HandleFunc(config.SendOrderStatistics, handler.HandleOrderStatistics)
+	mux.HandleFunc(config.SendOrderBackfill, handler.HandleOrderBackfill)
+	mux.HandleFunc(config.SendSummaryMigrate, handler.HandleSummaryMigrate)
 
 }
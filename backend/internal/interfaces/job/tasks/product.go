@@ -11,5 +11,7 @@ func RegisterProductHandler(mux *asynq.ServeMux, handler *handler.ProductHandler
 	mux.HandleFunc(config.SendProduct, handler.HandleProduct)
 	mux.HandleFunc(config.SendDelProduct, handler.HandleDelProduct)
 	mux.HandleFunc(config.SendUpdateProduct, handler.HandleShopifyProduct)
+	mux.HandleFunc(config.SendVariantSync, handler.HandleVariantSync)
+	mux.HandleFunc(config.SendProductDrift, handler.HandleProductDrift)
 
 }
@@ -10,4 +10,7 @@ func InitTask(mux *asynq.ServeMux, handlers *handler.Handlers) {
 	RegisterProductHandler(mux, handlers.ProductHandler)
 	RegisterUserHandler(mux, handlers.UserHandler)
 	RegisterOrderHandler(mux, handlers.OrderHandler)
+	RegisterBillingHandler(mux, handlers.BillingHandler)
+	RegisterClaimHandler(mux, handlers.ClaimHandler)
+	RegisterWebhookHandler(mux, handlers.WebhookHandler)
 }
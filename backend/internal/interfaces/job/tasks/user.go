@@ -9,5 +9,6 @@ import (
 
 func RegisterUserHandler(mux *asynq.ServeMux, handler *handler.UserHandler) {
 	mux.HandleFunc(config.SendInitUser, handler.HandleInitUser)
-
+	mux.HandleFunc(config.SendWebhookReconcile, handler.HandleWebhookReconcile)
+	mux.HandleFunc(config.SendTokenReencrypt, handler.HandleTokenReencrypt)
 }
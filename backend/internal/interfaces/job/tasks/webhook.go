@@ -0,0 +1,27 @@
+package tasks
+
+import (
+	"github.com/hibiken/asynq"
+
+	"backend/internal/infras/config"
+	"backend/internal/interfaces/job/handler"
+)
+
+func RegisterWebhookHandler(mux *asynq.ServeMux, handler *handler.WebhookHandler) {
+	mux.HandleFunc(config.WebhookTask("orders/updated"), handler.HandleOrderUpdated)
+	mux.HandleFunc(config.WebhookTask("orders/fulfilled"), handler.HandleOrderUpdated)
+	mux.HandleFunc(config.WebhookTask("orders/partially_fulfilled"), handler.HandleOrderUpdated)
+	mux.HandleFunc(config.WebhookTask("orders/delete"), handler.HandleOrderDeleted)
+	mux.HandleFunc(config.WebhookTask("products/update"), handler.HandleProductUpdated)
+	mux.HandleFunc(config.WebhookTask("products/delete"), handler.HandleProductDeleted)
+	mux.HandleFunc(config.WebhookTask("app_subscriptions/update"), handler.HandleSubscriptionUpdate)
+	mux.HandleFunc(config.WebhookTask("app_subscriptions/approaching_capped_amount"), handler.HandleApproachingCappedAmount)
+	mux.HandleFunc(config.WebhookTask("app/uninstalled"), handler.HandleAppUninstalled)
+	mux.HandleFunc(config.WebhookTask("shop/update"), handler.HandleShopUpdate)
+	mux.HandleFunc(config.WebhookTask("customers/data_request"), handler.HandleDataRequest)
+	mux.HandleFunc(config.WebhookTask("customers/redact"), handler.HandleCustomerRedact)
+	mux.HandleFunc(config.WebhookTask("shop/redact"), handler.HandleShopRedact)
+	// 其它 topic 按前缀兜底
+	mux.HandleFunc(config.SendWebhook, handler.HandleWebhook)
+
+}
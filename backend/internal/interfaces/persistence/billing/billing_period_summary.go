@@ -2,14 +2,22 @@ package billing
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"xorm.io/xorm"
 
 	"backend/internal/domain/entity"
 	billingEntity "backend/internal/domain/entity/billings"
 	"backend/internal/domain/repo/billings"
+	"backend/internal/interfaces/persistence"
 )
 
+// mysqlErrDuplicateEntry 唯一键冲突的错误码
+const mysqlErrDuplicateEntry = 1062
+
 var _ billings.BillingPeriodSummaryRepository = (*billingPeriodSummaryRepoImpl)(nil)
 
 type billingPeriodSummaryRepoImpl struct {
@@ -44,16 +52,80 @@ func (b *billingPeriodSummaryRepoImpl) CreateBillingPeriodSummary(ctx context.Co
 }
 
 func (b *billingPeriodSummaryRepoImpl) UpdateBillingPeriodSummary(ctx context.Context, period *billingEntity.BillingPeriodSummary) error {
-	_, err := b.db.Context(ctx).Table(new(billingEntity.BillingPeriodSummary)).ID(period.Id).Update(period)
+	version := period.Version
+	period.Version = version + 1
+	affected, err := persistence.Session(ctx, b.db).Table(new(billingEntity.BillingPeriodSummary)).
+		Where("id = ? AND version = ?", period.Id, version).
+		Update(period)
 	if err != nil {
+		period.Version = version
 		return err
 	}
+	if affected == 0 {
+		period.Version = version
+		return billingEntity.ErrSummaryVersionConflict
+	}
 	return nil
 }
 
+// EnsurePeriodSummary 先查后插，唯一键冲突说明其它任务已经创建，加锁重新读取即可
+func (b *billingPeriodSummaryRepoImpl) EnsurePeriodSummary(ctx context.Context, period *billingEntity.BillingPeriodSummary) (*billingEntity.BillingPeriodSummary, error) {
+	existing := &billingEntity.BillingPeriodSummary{}
+	has, err := whereKey(persistence.Session(ctx, b.db), period.Key()).Get(existing)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return existing, nil
+	}
+
+	if _, err := persistence.Session(ctx, b.db).Insert(period); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+			return nil, err
+		}
+		// 事务内的普通读可能读不到其它事务刚提交的数据，用当前读
+		has, err := whereKey(persistence.Session(ctx, b.db), period.Key()).ForUpdate().Get(existing)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			return nil, fmt.Errorf("账期汇总 %s 创建冲突但查询不到", period.BillCycle)
+		}
+		return existing, nil
+	}
+	return period, nil
+}
+
+func (b *billingPeriodSummaryRepoImpl) AddRecognizedBill(ctx context.Context, key billingEntity.PeriodKey, delta *billingEntity.SummaryDelta) error {
+	affected, err := whereKey(persistence.Session(ctx, b.db), key).
+		Incr("order_count", delta.OrderCount).
+		Incr("bill_count", delta.BillCount).
+		Incr("total_commission_amount", delta.TotalCommissionAmount).
+		Incr("pending_amount", delta.PendingAmount).
+		Incr("total_protectify_amount", delta.TotalProtectifyAmount).
+		Incr("total_order_amount", delta.TotalOrderAmount).
+		Incr("total_refund_amount", delta.TotalRefundAmount).
+		Incr("version").
+		Update(new(billingEntity.BillingPeriodSummary))
+	return checkAffected(affected, err, key)
+}
+
 func (b *billingPeriodSummaryRepoImpl) GetByCurrentPeriod(ctx context.Context, userID int64, periodEnd int64) (*billingEntity.BillingPeriodSummary, error) {
 	period := &billingEntity.BillingPeriodSummary{}
-	has, err := b.db.Where("user_id = ? and period_end = ?", userID, periodEnd).Get(&period)
+	has, err := persistence.Session(ctx, b.db).Where("user_id = ? and billing_period_end = ?", userID, periodEnd).Get(period)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return period, nil
+}
+
+func (b *billingPeriodSummaryRepoImpl) Get(ctx context.Context, userID int64, id int64) (*billingEntity.BillingPeriodSummary, error) {
+	period := &billingEntity.BillingPeriodSummary{}
+	has, err := persistence.Session(ctx, b.db).Where("id = ? and user_id = ?", id, userID).Get(period)
 	if err != nil {
 		return nil, err
 	}
@@ -64,9 +136,164 @@ func (b *billingPeriodSummaryRepoImpl) GetByCurrentPeriod(ctx context.Context, u
 }
 
 func (b *billingPeriodSummaryRepoImpl) UpdateBillingPeriod(ctx context.Context, period *billingEntity.BillingPeriodSummary) error {
-	_, err := b.db.Context(ctx).Table(new(billingEntity.BillingPeriodSummary)).ID(period.Id).Update(period)
+	_, err := persistence.Session(ctx, b.db).Table(new(billingEntity.BillingPeriodSummary)).ID(period.Id).Update(period)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (b *billingPeriodSummaryRepoImpl) SettleCharged(ctx context.Context, key billingEntity.PeriodKey, amount float64, fromFailed bool) error {
+	from := "pending_amount"
+	if fromFailed {
+		from = "error_amount"
+	}
+	affected, err := whereKey(persistence.Session(ctx, b.db), key).
+		Decr(from, amount).
+		Incr("paid_amount", amount).
+		Incr("version").
+		Update(new(billingEntity.BillingPeriodSummary))
+	return checkAffected(affected, err, key)
+}
+
+func (b *billingPeriodSummaryRepoImpl) SettleFailed(ctx context.Context, key billingEntity.PeriodKey, amount float64) error {
+	affected, err := whereKey(persistence.Session(ctx, b.db), key).
+		Decr("pending_amount", amount).
+		Incr("error_amount", amount).
+		Incr("version").
+		Update(new(billingEntity.BillingPeriodSummary))
+	return checkAffected(affected, err, key)
+}
+
+func (b *billingPeriodSummaryRepoImpl) ApplyAdjustment(ctx context.Context, key billingEntity.PeriodKey, adjustment float64, pendingAbsorbed float64, errorAbsorbed float64) error {
+	affected, err := whereKey(persistence.Session(ctx, b.db), key).
+		Incr("adjustment_amount", adjustment).
+		Decr("pending_amount", pendingAbsorbed).
+		Decr("error_amount", errorAbsorbed).
+		Incr("version").
+		Update(new(billingEntity.BillingPeriodSummary))
+	return checkAffected(affected, err, key)
+}
+
+func (b *billingPeriodSummaryRepoImpl) AddCreditAmount(ctx context.Context, key billingEntity.PeriodKey, amount float64) error {
+	affected, err := whereKey(persistence.Session(ctx, b.db), key).
+		Incr("credit_amount", amount).
+		Incr("version").
+		Update(new(billingEntity.BillingPeriodSummary))
+	return checkAffected(affected, err, key)
+}
+
+func (b *billingPeriodSummaryRepoImpl) CloseBillingPeriods(ctx context.Context, userID int64, before int64) error {
+	_, err := persistence.Session(ctx, b.db).Table(new(billingEntity.BillingPeriodSummary)).
+		Where("user_id = ? AND billing_period_end <= ? AND summary_status = ?", userID, before, billingEntity.SummaryStatusOpen).
+		Update(map[string]interface{}{
+			"summary_status": billingEntity.SummaryStatusClosed,
+			"update_time":    time.Now().Unix(),
+		})
+	return err
+}
+
+func (b *billingPeriodSummaryRepoImpl) Rebucket(ctx context.Context, userID int64, bills []*billingEntity.CommissionBill, adjustments []*billingEntity.CommissionAdjustment, summaries []*billingEntity.BillingPeriodSummary) error {
+	session := b.db.NewSession().Context(ctx)
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	// 1. 改写账单所在账期
+	for _, bill := range bills {
+		_, err := session.Table(new(billingEntity.CommissionBill)).
+			Where("id = ? AND user_id = ?", bill.Id, userID).
+			Update(map[string]interface{}{
+				"billing_period_start": bill.BillingPeriodStart,
+				"billing_period_end":   bill.BillingPeriodEnd,
+				"bill_cycle":           bill.BillCycle,
+				"update_time":          now,
+			})
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	// 2. 改写调整流水所在账期
+	for _, adjustment := range adjustments {
+		_, err := session.Table(new(billingEntity.CommissionAdjustment)).
+			Where("id = ? AND user_id = ?", adjustment.Id, userID).
+			Update(map[string]interface{}{
+				"billing_period_end": adjustment.BillingPeriodEnd,
+				"subscription_id":    adjustment.SubscriptionId,
+				"bill_cycle":         adjustment.BillCycle,
+				"update_time":        now,
+			})
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	// 3. 按唯一键原地更新账期汇总，保留汇总ID，新出现的账期再插入
+	keys := make(map[billingEntity.PeriodKey]struct{}, len(summaries))
+	for _, summary := range summaries {
+		keys[summary.Key()] = struct{}{}
+		affected, err := whereKey(session, summary.Key()).
+			Cols(rebucketCols...).
+			Incr("version").
+			Update(summary)
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+		if affected > 0 {
+			continue
+		}
+		if _, err := session.Insert(summary); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	// 4. 删除重新划分后已经没有账单和调整流水的旧账期
+	var existing []*billingEntity.BillingPeriodSummary
+	if err := session.Where("user_id = ?", userID).Cols("id", "user_id", "subscription_id", "bill_cycle").Find(&existing); err != nil {
+		session.Rollback()
+		return err
+	}
+	for _, summary := range existing {
+		if _, ok := keys[summary.Key()]; ok {
+			continue
+		}
+		if _, err := session.ID(summary.Id).Delete(new(billingEntity.BillingPeriodSummary)); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	return session.Commit()
+}
+
+// rebucketCols 重新划分账期时按汇总结果覆盖的列
+var rebucketCols = []string{
+	"shop_domain", "billing_period_start", "billing_period_end", "business_month", "currency",
+	"summary_status", "is_test_period", "order_count", "bill_count",
+	"total_commission_amount", "pending_amount", "paid_amount", "error_amount", "adjustment_amount", "credit_amount",
+	"total_protectify_amount", "total_order_amount", "total_refund_amount", "last_sync_time",
+}
+
+// whereKey 按唯一键 (user_id, subscription_id, bill_cycle) 定位账期汇总
+func whereKey(session *xorm.Session, key billingEntity.PeriodKey) *xorm.Session {
+	return session.Where("user_id = ? and subscription_id = ? and bill_cycle = ?", key.UserId, key.SubscriptionId, key.BillCycle)
+}
+
+// checkAffected 累加更新没有命中账期汇总时返回错误，避免金额被静默丢弃
+func checkAffected(affected int64, err error, key billingEntity.PeriodKey) error {
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("账期汇总不存在: user_id=%d subscription_id=%d bill_cycle=%s", key.UserId, key.SubscriptionId, key.BillCycle)
+	}
+	return nil
+}
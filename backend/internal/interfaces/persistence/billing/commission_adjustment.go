@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"xorm.io/xorm"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+	"backend/internal/interfaces/persistence"
+)
+
+var _ billings.CommissionAdjustmentRepository = (*commissionAdjustmentRepoImpl)(nil)
+
+type commissionAdjustmentRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewCommissionAdjustmentRepository(db *xorm.Engine) billings.CommissionAdjustmentRepository {
+	return &commissionAdjustmentRepoImpl{db: db}
+}
+
+func (c *commissionAdjustmentRepoImpl) Create(ctx context.Context, adjustment *billingEntity.CommissionAdjustment) (int64, error) {
+	_, err := persistence.Session(ctx, c.db).Insert(adjustment)
+	if err != nil {
+		return 0, err
+	}
+	return adjustment.Id, nil
+}
+
+func (c *commissionAdjustmentRepoImpl) Get(ctx context.Context, id int64) (*billingEntity.CommissionAdjustment, error) {
+	var adjustment billingEntity.CommissionAdjustment
+	has, err := persistence.Session(ctx, c.db).Where("id = ?", id).Get(&adjustment)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &adjustment, nil
+}
+
+func (c *commissionAdjustmentRepoImpl) SumByUserOrder(ctx context.Context, userID int64, userOrderID int64) (float64, error) {
+	total, err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND user_order_id = ?", userID, userOrderID).
+		Sum(new(billingEntity.CommissionAdjustment), "amount")
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (c *commissionAdjustmentRepoImpl) RetryableCredits(ctx context.Context, lastID int64, before int64, size int) ([]*billingEntity.CommissionAdjustment, error) {
+	var adjustments []*billingEntity.CommissionAdjustment
+	err := persistence.Session(ctx, c.db).
+		Where("id > ?", lastID).
+		In("status", billingEntity.AdjustmentStatusPending, billingEntity.AdjustmentStatusFailed, billingEntity.AdjustmentStatusCrediting).
+		And("credit_amount > 0").
+		And("retry_count < ?", billingEntity.MaxChargeRetry).
+		And("update_time < ?", before).
+		Asc("id").
+		Limit(size).
+		Find(&adjustments)
+	return adjustments, err
+}
+
+func (c *commissionAdjustmentRepoImpl) MarkCrediting(ctx context.Context, adjustment *billingEntity.CommissionAdjustment) (bool, error) {
+	affected, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionAdjustment)).
+		Where("id = ? AND status = ? AND update_time = ?", adjustment.Id, adjustment.Status, adjustment.UpdateTime).
+		Update(map[string]interface{}{
+			"status":      billingEntity.AdjustmentStatusCrediting,
+			"update_time": time.Now().Unix(),
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *commissionAdjustmentRepoImpl) MarkCredited(ctx context.Context, id int64, creditID string) (bool, error) {
+	affected, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionAdjustment)).
+		Where("id = ? AND status <> ?", id, billingEntity.AdjustmentStatusCredited).
+		Update(map[string]interface{}{
+			"shopify_credit_id": creditID,
+			"status":            billingEntity.AdjustmentStatusCredited,
+			"error_message":     "",
+			"update_time":       time.Now().Unix(),
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *commissionAdjustmentRepoImpl) MarkFailed(ctx context.Context, id int64, errMsg string) error {
+	_, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionAdjustment)).
+		Where("id = ? AND status <> ?", id, billingEntity.AdjustmentStatusCredited).
+		Incr("retry_count").
+		Update(map[string]interface{}{
+			"status":        billingEntity.AdjustmentStatusFailed,
+			"error_message": errMsg,
+			"update_time":   time.Now().Unix(),
+		})
+	return err
+}
+
+func (c *commissionAdjustmentRepoImpl) ListByUser(ctx context.Context, userID int64) ([]*billingEntity.CommissionAdjustment, error) {
+	var adjustments []*billingEntity.CommissionAdjustment
+	err := persistence.Session(ctx, c.db).Where("user_id = ?", userID).Asc("id").Find(&adjustments)
+	return adjustments, err
+}
+
+func (c *commissionAdjustmentRepoImpl) ListByPeriod(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionAdjustment, error) {
+	var adjustments []*billingEntity.CommissionAdjustment
+	err := persistence.Session(ctx, c.db).Where("user_id = ? AND billing_period_end = ?", userID, periodEnd).Asc("id").Find(&adjustments)
+	return adjustments, err
+}
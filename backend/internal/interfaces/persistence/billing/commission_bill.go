@@ -10,6 +10,7 @@ import (
 	"backend/internal/domain/entity"
 	billingEntity "backend/internal/domain/entity/billings"
 	"backend/internal/domain/repo/billings"
+	"backend/internal/interfaces/persistence"
 	"backend/pkg/utils"
 )
 
@@ -88,3 +89,199 @@ func (c *commissionBillRepoImpl) CommissionCount(ctx context.Context, userID int
 	}
 	return count, nil
 }
+
+func (c *commissionBillRepoImpl) CreateBill(ctx context.Context, bill *billingEntity.CommissionBill) (int64, error) {
+	_, err := persistence.Session(ctx, c.db).Insert(bill)
+	if err != nil {
+		return 0, err
+	}
+	return bill.Id, nil
+}
+
+func (c *commissionBillRepoImpl) GetByUserOrder(ctx context.Context, userID int64, userOrderID int64) (*billingEntity.CommissionBill, error) {
+	var bill billingEntity.CommissionBill
+	has, err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND user_order_id = ?", userID, userOrderID).
+		Get(&bill)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &bill, nil
+}
+
+func (c *commissionBillRepoImpl) RetryableBills(ctx context.Context, lastID int64, before int64, size int) ([]*billingEntity.CommissionBill, error) {
+	var bills []*billingEntity.CommissionBill
+	err := persistence.Session(ctx, c.db).
+		Where("id > ?", lastID).
+		In("charge_status", billingEntity.ChargeStatusPending, billingEntity.ChargeStatusFailed).
+		And("recognition_status = ?", billingEntity.RecognitionStatusRecognized).
+		And("retry_count < ?", billingEntity.MaxChargeRetry).
+		And("update_time < ?", before).
+		Asc("id").
+		Limit(size).
+		Find(&bills)
+	return bills, err
+}
+
+func (c *commissionBillRepoImpl) MarkCharged(ctx context.Context, id int64, usageRecordID string) (bool, error) {
+	now := time.Now().Unix()
+	affected, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Where("id = ? AND charge_status <> ?", id, billingEntity.ChargeStatusCharged).
+		Update(map[string]interface{}{
+			"shopify_usage_record_id": usageRecordID,
+			"charge_status":           billingEntity.ChargeStatusCharged,
+			"error_message":           "",
+			"charged_at":              now,
+			"update_time":             now,
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *commissionBillRepoImpl) MarkFailed(ctx context.Context, id int64, errMsg string) error {
+	_, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Where("id = ? AND charge_status <> ?", id, billingEntity.ChargeStatusCharged).
+		Incr("retry_count").
+		Update(map[string]interface{}{
+			"charge_status": billingEntity.ChargeStatusFailed,
+			"error_message": errMsg,
+			"update_time":   time.Now().Unix(),
+		})
+	return err
+}
+
+func (c *commissionBillRepoImpl) UnchargedBills(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionBill, error) {
+	var bills []*billingEntity.CommissionBill
+	err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND billing_period_end = ?", userID, periodEnd).
+		In("charge_status", billingEntity.ChargeStatusPending, billingEntity.ChargeStatusFailed).
+		Asc("id").
+		Find(&bills)
+	return bills, err
+}
+
+func (c *commissionBillRepoImpl) DeductCommission(ctx context.Context, id int64, amount float64) (bool, error) {
+	affected, err := persistence.Session(ctx, c.db).
+		Where("id = ? AND charge_status <> ? AND commission_amount - deducted_amount >= ?", id, billingEntity.ChargeStatusCharged, amount).
+		Incr("deducted_amount", amount).
+		Update(new(billingEntity.CommissionBill))
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *commissionBillRepoImpl) Recognize(ctx context.Context, id int64, periodStart int64, periodEnd int64, billCycle string) (bool, error) {
+	now := time.Now().Unix()
+	affected, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Where("id = ? AND recognition_status = ?", id, billingEntity.RecognitionStatusDeferred).
+		Update(map[string]interface{}{
+			"recognition_status":   billingEntity.RecognitionStatusRecognized,
+			"recognized_at":        now,
+			"billing_period_start": periodStart,
+			"billing_period_end":   periodEnd,
+			"bill_cycle":           billCycle,
+			"update_time":          now,
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *commissionBillRepoImpl) RecognitionAmounts(ctx context.Context, userID int64) ([]*billingEntity.RecognitionAmount, error) {
+	var amounts []*billingEntity.RecognitionAmount
+	err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Select("recognition_status, SUM(commission_amount - deducted_amount) AS amount").
+		Where("user_id = ?", userID).
+		GroupBy("recognition_status").
+		Find(&amounts)
+	return amounts, err
+}
+
+func (c *commissionBillRepoImpl) BilledUserIDs(ctx context.Context, lastUserID int64, size int) ([]int64, error) {
+	var userIDs []int64
+	err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Distinct("user_id").
+		Where("user_id > ?", lastUserID).
+		Asc("user_id").
+		Limit(size).
+		Find(&userIDs)
+	return userIDs, err
+}
+
+func (c *commissionBillRepoImpl) RecognizedBills(ctx context.Context, userID int64) ([]*billingEntity.CommissionBill, error) {
+	var bills []*billingEntity.CommissionBill
+	err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND recognition_status = ?", userID, billingEntity.RecognitionStatusRecognized).
+		Asc("id").
+		Find(&bills)
+	return bills, err
+}
+
+func (c *commissionBillRepoImpl) PeriodBills(ctx context.Context, userID int64, periodEnd int64) ([]*billingEntity.CommissionBill, error) {
+	var bills []*billingEntity.CommissionBill
+	err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND billing_period_end = ? AND recognition_status = ?", userID, periodEnd, billingEntity.RecognitionStatusRecognized).
+		Asc("id").
+		Find(&bills)
+	return bills, err
+}
+
+func (c *commissionBillRepoImpl) RecognizedAmountsSince(ctx context.Context, userID int64, since int64) ([]*billingEntity.CurrencyAmount, error) {
+	var amounts []*billingEntity.CurrencyAmount
+	err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Select("currency, SUM(commission_amount - deducted_amount) AS amount").
+		Where("user_id = ? AND recognition_status = ? AND recognized_at >= ?", userID, billingEntity.RecognitionStatusRecognized, since).
+		GroupBy("currency").
+		Find(&amounts)
+	return amounts, err
+}
+
+func (c *commissionBillRepoImpl) UnchargedAmounts(ctx context.Context, userID int64) ([]*billingEntity.CurrencyAmount, error) {
+	var amounts []*billingEntity.CurrencyAmount
+	err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Select("currency, SUM(commission_amount - deducted_amount) AS amount").
+		Where("user_id = ? AND recognition_status = ?", userID, billingEntity.RecognitionStatusRecognized).
+		In("charge_status", billingEntity.ChargeStatusPending, billingEntity.ChargeStatusFailed).
+		GroupBy("currency").
+		Find(&amounts)
+	return amounts, err
+}
+
+func (c *commissionBillRepoImpl) ChargedBillsSince(ctx context.Context, userID int64, since int64) ([]*billingEntity.CommissionBill, error) {
+	var bills []*billingEntity.CommissionBill
+	err := persistence.Session(ctx, c.db).
+		Where("user_id = ? AND charge_status = ? AND shopify_usage_record_id <> '' AND charged_at >= ?", userID, billingEntity.ChargeStatusCharged, since).
+		Asc("id").
+		Find(&bills)
+	return bills, err
+}
+
+func (c *commissionBillRepoImpl) SaveConversion(ctx context.Context, id int64, conversion *billingEntity.Conversion) error {
+	_, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Where("id = ? AND charge_status <> ?", id, billingEntity.ChargeStatusCharged).
+		Update(map[string]interface{}{
+			"charge_currency":  conversion.To,
+			"original_amount":  utils.DecimalToFloat(conversion.Amount),
+			"converted_amount": utils.DecimalToFloat(conversion.Result),
+			"exchange_rate":    utils.DecimalToFloat(conversion.Rate),
+			"update_time":      time.Now().Unix(),
+		})
+	return err
+}
+
+func (c *commissionBillRepoImpl) ResetRetryCount(ctx context.Context, userID int64) error {
+	_, err := persistence.Session(ctx, c.db).Table(new(billingEntity.CommissionBill)).
+		Where("user_id = ? AND charge_status = ?", userID, billingEntity.ChargeStatusFailed).
+		Update(map[string]interface{}{
+			"retry_count": 0,
+			"update_time": time.Now().Unix(),
+		})
+	return err
+}
@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"xorm.io/xorm"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+)
+
+var _ billings.ExchangeRateRepository = (*exchangeRateRepoImpl)(nil)
+
+type exchangeRateRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewExchangeRateRepository(db *xorm.Engine) billings.ExchangeRateRepository {
+	return &exchangeRateRepoImpl{db: db}
+}
+
+func (e *exchangeRateRepoImpl) Get(ctx context.Context, base string, quote string) (*billingEntity.ExchangeRate, error) {
+	var rate billingEntity.ExchangeRate
+	has, err := e.db.Context(ctx).Where("base_currency = ? AND quote_currency = ?", base, quote).Get(&rate)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &rate, nil
+}
+
+func (e *exchangeRateRepoImpl) Save(ctx context.Context, rates []*billingEntity.ExchangeRate) error {
+	session := e.db.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, rate := range rates {
+		exists, err := session.Where("base_currency = ? AND quote_currency = ?", rate.BaseCurrency, rate.QuoteCurrency).
+			Exist(new(billingEntity.ExchangeRate))
+		if err != nil {
+			_ = session.Rollback()
+			return err
+		}
+		if !exists {
+			_, err = session.Insert(rate)
+		} else {
+			_, err = session.Table(new(billingEntity.ExchangeRate)).
+				Where("base_currency = ? AND quote_currency = ?", rate.BaseCurrency, rate.QuoteCurrency).
+				Update(map[string]interface{}{
+					"rate":        rate.Rate,
+					"source":      rate.Source,
+					"update_time": now,
+				})
+		}
+		if err != nil {
+			_ = session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
@@ -0,0 +1,50 @@
+package billing
+
+import (
+	"context"
+
+	"xorm.io/xorm"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+)
+
+var _ billings.PlanRepository = (*planRepoImpl)(nil)
+
+type planRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewPlanRepository(db *xorm.Engine) billings.PlanRepository {
+	return &planRepoImpl{db: db}
+}
+
+func (p *planRepoImpl) List(ctx context.Context) ([]*billingEntity.AppPlan, error) {
+	var plans []*billingEntity.AppPlan
+	err := p.db.Context(ctx).Where("status = ?", billingEntity.PlanStatusEnabled).Asc("level", "id").Find(&plans)
+	return plans, err
+}
+
+func (p *planRepoImpl) Get(ctx context.Context, id int64) (*billingEntity.AppPlan, error) {
+	return p.first(ctx, "id = ?", id)
+}
+
+func (p *planRepoImpl) GetByCode(ctx context.Context, code string) (*billingEntity.AppPlan, error) {
+	return p.first(ctx, "code = ? AND status = ?", code, billingEntity.PlanStatusEnabled)
+}
+
+func (p *planRepoImpl) GetDefault(ctx context.Context) (*billingEntity.AppPlan, error) {
+	return p.first(ctx, "is_default = 1 AND status = ?", billingEntity.PlanStatusEnabled)
+}
+
+func (p *planRepoImpl) first(ctx context.Context, query string, args ...interface{}) (*billingEntity.AppPlan, error) {
+	plan := &billingEntity.AppPlan{}
+	has, err := p.db.Context(ctx).Where(query, args...).Get(plan)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return plan, nil
+}
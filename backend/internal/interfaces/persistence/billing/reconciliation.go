@@ -0,0 +1,64 @@
+package billing
+
+import (
+	"context"
+
+	"xorm.io/xorm"
+
+	billingEntity "backend/internal/domain/entity/billings"
+	"backend/internal/domain/repo/billings"
+)
+
+var _ billings.ReconciliationRepository = (*reconciliationRepoImpl)(nil)
+
+type reconciliationRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewReconciliationRepository(db *xorm.Engine) billings.ReconciliationRepository {
+	return &reconciliationRepoImpl{db: db}
+}
+
+func (r *reconciliationRepoImpl) ReplaceUserIssues(ctx context.Context, userID int64, issues []*billingEntity.BillingReconciliation) error {
+	session := r.db.NewSession().Context(ctx)
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if _, err := session.Where("user_id = ?", userID).Delete(new(billingEntity.BillingReconciliation)); err != nil {
+		_ = session.Rollback()
+		return err
+	}
+	if len(issues) > 0 {
+		if _, err := session.Insert(&issues); err != nil {
+			_ = session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
+
+func (r *reconciliationRepoImpl) List(ctx context.Context, req *billingEntity.ReconciliationListReq) ([]*billingEntity.BillingReconciliation, error) {
+	var issues []*billingEntity.BillingReconciliation
+	err := r.filter(ctx, req).
+		Desc("id").
+		Limit(req.Size, (req.Page-1)*req.Size).
+		Find(&issues)
+	return issues, err
+}
+
+func (r *reconciliationRepoImpl) Count(ctx context.Context, req *billingEntity.ReconciliationListReq) (int64, error) {
+	return r.filter(ctx, req).Count(new(billingEntity.BillingReconciliation))
+}
+
+func (r *reconciliationRepoImpl) filter(ctx context.Context, req *billingEntity.ReconciliationListReq) *xorm.Session {
+	session := r.db.Context(ctx).Where("1 = 1")
+	if req.UserID > 0 {
+		session.And("user_id = ?", req.UserID)
+	}
+	if req.IssueType != "" {
+		session.And("issue_type = ?", req.IssueType)
+	}
+	return session
+}
@@ -66,12 +66,39 @@ func (u *userSubscriptionRepoImpl) UpsertUserSubscription(ctx context.Context, s
 	}
 }
 
-// UpdateSubscriptionBalance 更新订阅余额
-func (u *userSubscriptionRepoImpl) UpdateSubscriptionBalance(ctx context.Context, id int64, balanceUsed float64) error {
-	_, err := u.db.ID(id).Update(&billingEntity.UserSubscription{
-		BalanceUsed: balanceUsed,
-	})
-	return err
+// ActiveUsageSubscriptions 按ID游标查询有用量扣费项目的活跃订阅
+func (u *userSubscriptionRepoImpl) ActiveUsageSubscriptions(ctx context.Context, lastID int64, size int) ([]*billingEntity.UserSubscription, error) {
+	var subscriptions []*billingEntity.UserSubscription
+	err := u.db.Context(ctx).
+		Where("id > ? AND subscription_status = ? AND subscription_line_item_id <> ''", lastID, billingEntity.SubscriptionStatusActive).
+		Asc("id").
+		Limit(size).
+		Find(&subscriptions)
+	return subscriptions, err
+}
+
+// ActiveSubscriptions 按ID游标查询所有活跃订阅
+func (u *userSubscriptionRepoImpl) ActiveSubscriptions(ctx context.Context, lastID int64, size int) ([]*billingEntity.UserSubscription, error) {
+	var subscriptions []*billingEntity.UserSubscription
+	err := u.db.Context(ctx).
+		Where("id > ? AND subscription_status = ?", lastID, billingEntity.SubscriptionStatusActive).
+		Asc("id").
+		Limit(size).
+		Find(&subscriptions)
+	return subscriptions, err
+}
+
+// IncrSubscriptionBalance 原子增加订阅已用额度，超过用量上限时不更新，释放额度时不检查上限
+func (u *userSubscriptionRepoImpl) IncrSubscriptionBalance(ctx context.Context, id int64, delta float64) (bool, error) {
+	session := u.db.Context(ctx).Where("id = ?", id)
+	if delta > 0 {
+		session = session.And("(capped_amount <= 0 OR balance_used + ? <= capped_amount)", delta)
+	}
+	affected, err := session.Incr("balance_used", delta).Update(&billingEntity.UserSubscription{})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
 // GetSubscriptionByLineItemID 根据LineItemID获取订阅
@@ -100,6 +127,13 @@ func (u *userSubscriptionRepoImpl) GetSubscriptionByChargeID(ctx context.Context
 	return subscription, nil
 }
 
+// ListByUser 查询用户的所有订阅（包含已取消的），按创建时间升序
+func (u *userSubscriptionRepoImpl) ListByUser(ctx context.Context, userID int64) ([]*billingEntity.UserSubscription, error) {
+	subscriptions := make([]*billingEntity.UserSubscription, 0)
+	err := u.db.Context(ctx).Where("user_id = ?", userID).Asc("create_time", "id").Find(&subscriptions)
+	return subscriptions, err
+}
+
 // GetExpiredSubscriptions 获取过期订阅
 func (u *userSubscriptionRepoImpl) GetExpiredSubscriptions(ctx context.Context) ([]*billingEntity.UserSubscription, error) {
 	subscriptions := make([]*billingEntity.UserSubscription, 0)
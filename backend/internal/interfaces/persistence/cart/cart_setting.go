@@ -45,7 +45,7 @@ func (s *cartSettingRepoImpl) Create(ctx context.Context, setting *entity.UserCa
 
 // Update 更新购物车设置
 func (s *cartSettingRepoImpl) Update(ctx context.Context, setting *entity.UserCartSetting) error {
-	_, err := s.db.Context(ctx).ID(setting.Id).MustCols("show_cart", "show_cart_icon", "select_button", "in_collection", "pricing_rule", "pricing_type", "fulfillment_rule").Update(setting)
+	_, err := s.db.Context(ctx).ID(setting.Id).MustCols("show_cart", "show_cart_icon", "select_button", "in_collection", "pricing_rule", "pricing_type", "fulfillment_rule", "ladder_min", "ladder_max", "ladder_step", "ladder_points").Update(setting)
 	if err != nil {
 		return err
 	}
@@ -73,3 +73,31 @@ func (s *cartSettingRepoImpl) CloseCart(ctx context.Context, userID int64) error
 	}
 	return nil
 }
+
+// PauseForCappedAmount 订阅用量达到上限时关闭购物车
+func (s *cartSettingRepoImpl) PauseForCappedAmount(ctx context.Context, userID int64) (bool, error) {
+	affected, err := s.db.Context(ctx).Table(new(entity.UserCartSetting)).
+		Where("user_id = ? and show_cart = 1", userID).
+		Update(map[string]interface{}{
+			"show_cart":     0,
+			"capped_paused": 1,
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RestoreFromCappedAmount 恢复因用量上限关闭的购物车
+func (s *cartSettingRepoImpl) RestoreFromCappedAmount(ctx context.Context, userID int64) (bool, error) {
+	affected, err := s.db.Context(ctx).Table(new(entity.UserCartSetting)).
+		Where("user_id = ? and capped_paused = 1", userID).
+		Update(map[string]interface{}{
+			"show_cart":     1,
+			"capped_paused": 0,
+		})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
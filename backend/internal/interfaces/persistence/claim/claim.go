@@ -0,0 +1,147 @@
+package claim
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"xorm.io/xorm"
+
+	claimEntity "backend/internal/domain/entity/claims"
+	"backend/internal/domain/repo/claims"
+	"backend/internal/interfaces/persistence"
+)
+
+// mysqlErrDuplicateEntry 唯一键冲突的错误码
+const mysqlErrDuplicateEntry = 1062
+
+var _ claims.ClaimRepository = (*claimRepoImpl)(nil)
+
+type claimRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewClaimRepository(db *xorm.Engine) claims.ClaimRepository {
+	return &claimRepoImpl{db: db}
+}
+
+func (r *claimRepoImpl) Create(ctx context.Context, claim *claimEntity.OrderClaim, items []*claimEntity.OrderClaimItem, evidence []*claimEntity.OrderClaimEvidence) error {
+	if _, err := persistence.Session(ctx, r.db).Insert(claim); err != nil {
+		// open_order_id 唯一键冲突说明同一订单并发提交，已经有处理中的理赔
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return claimEntity.ErrClaimExists
+		}
+		return err
+	}
+	for _, item := range items {
+		item.ClaimId = claim.Id
+	}
+	for _, e := range evidence {
+		e.ClaimId = claim.Id
+	}
+	if len(items) > 0 {
+		if _, err := persistence.Session(ctx, r.db).Insert(&items); err != nil {
+			return err
+		}
+	}
+	if len(evidence) > 0 {
+		if _, err := persistence.Session(ctx, r.db).Insert(&evidence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *claimRepoImpl) First(ctx context.Context, userID int64, id int64) (*claimEntity.OrderClaim, error) {
+	var claim claimEntity.OrderClaim
+	has, err := persistence.Session(ctx, r.db).Where("id = ? and user_id = ?", id, userID).Get(&claim)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &claim, nil
+}
+
+func (r *claimRepoImpl) Get(ctx context.Context, id int64) (*claimEntity.OrderClaim, error) {
+	var claim claimEntity.OrderClaim
+	has, err := persistence.Session(ctx, r.db).ID(id).Get(&claim)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &claim, nil
+}
+
+func (r *claimRepoImpl) List(ctx context.Context, req *claimEntity.ClaimListReq) ([]*claimEntity.OrderClaim, int64, error) {
+	var list []*claimEntity.OrderClaim
+	total, err := r.filter(ctx, req).
+		Desc("id").
+		Limit(req.Size, (req.Page-1)*req.Size).
+		FindAndCount(&list)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (r *claimRepoImpl) filter(ctx context.Context, req *claimEntity.ClaimListReq) *xorm.Session {
+	session := r.db.Context(ctx).Where("user_id = ?", req.UserID)
+	if req.Status != "" {
+		session.And("status = ?", req.Status)
+	}
+	if req.Query != "" {
+		session.And("order_name LIKE ?", "%"+req.Query+"%")
+	}
+	return session
+}
+
+func (r *claimRepoImpl) Items(ctx context.Context, claimID int64) ([]*claimEntity.OrderClaimItem, error) {
+	var items []*claimEntity.OrderClaimItem
+	err := persistence.Session(ctx, r.db).Where("claim_id = ?", claimID).Asc("id").Find(&items)
+	return items, err
+}
+
+func (r *claimRepoImpl) Evidence(ctx context.Context, claimID int64) ([]*claimEntity.OrderClaimEvidence, error) {
+	var evidence []*claimEntity.OrderClaimEvidence
+	err := persistence.Session(ctx, r.db).Where("claim_id = ?", claimID).Asc("id").Find(&evidence)
+	return evidence, err
+}
+
+func (r *claimRepoImpl) HasOpen(ctx context.Context, userID int64, userOrderId int64) (bool, error) {
+	return persistence.Session(ctx, r.db).
+		Where("user_id = ? and user_order_id = ?", userID, userOrderId).
+		NotIn("status", claimEntity.StatusDenied, claimEntity.StatusResolved).
+		Exist(&claimEntity.OrderClaim{})
+}
+
+func (r *claimRepoImpl) UpdateStatus(ctx context.Context, claim *claimEntity.OrderClaim, from string, columns ...string) (bool, error) {
+	affected, err := persistence.Session(ctx, r.db).
+		Where("id = ? and status = ?", claim.Id, from).
+		Cols(append(columns, "status")...).
+		Update(claim)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *claimRepoImpl) UpdateResolutionStatus(ctx context.Context, claim *claimEntity.OrderClaim, from string, columns ...string) (bool, error) {
+	affected, err := persistence.Session(ctx, r.db).
+		Where("id = ? and status = ? and resolution_status = ?", claim.Id, claimEntity.StatusApproved, from).
+		Cols(columns...).
+		Update(claim)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *claimRepoImpl) Update(ctx context.Context, claim *claimEntity.OrderClaim, columns ...string) error {
+	_, err := persistence.Session(ctx, r.db).ID(claim.Id).Cols(columns...).Update(claim)
+	return err
+}
@@ -0,0 +1,63 @@
+package job
+
+import (
+	"context"
+
+	"xorm.io/xorm"
+
+	"backend/internal/domain/entity/jobs"
+	jobRepo "backend/internal/domain/repo/jobs"
+)
+
+var _ jobRepo.OrderBackfillRepository = (*OrderBackfillRepoImpl)(nil)
+
+type OrderBackfillRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewOrderBackfillRepository(db *xorm.Engine) jobRepo.OrderBackfillRepository {
+	return &OrderBackfillRepoImpl{db: db}
+}
+
+func (j *OrderBackfillRepoImpl) Create(ctx context.Context, job *jobs.JobOrderBackfill) (int64, error) {
+	_, err := j.db.Context(ctx).Insert(job)
+	if err != nil {
+		return 0, err
+	}
+	return job.Id, nil
+}
+
+func (j *OrderBackfillRepoImpl) First(ctx context.Context, id int64) (*jobs.JobOrderBackfill, error) {
+	var job jobs.JobOrderBackfill
+	has, err := j.db.Context(ctx).Where("id = ?", id).Get(&job)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (j *OrderBackfillRepoImpl) Latest(ctx context.Context, userID int64) (*jobs.JobOrderBackfill, error) {
+	var job jobs.JobOrderBackfill
+	has, err := j.db.Context(ctx).Where("user_id = ?", userID).Desc("id").Get(&job)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (j *OrderBackfillRepoImpl) HasUnfinished(ctx context.Context, userID int64) (bool, error) {
+	return j.db.Context(ctx).Where("user_id = ? and status in (?, ?, ?)", userID,
+		jobs.BackfillStatusPending, jobs.BackfillStatusRunning, jobs.BackfillStatusImporting).
+		Exist(&jobs.JobOrderBackfill{})
+}
+
+func (j *OrderBackfillRepoImpl) Update(ctx context.Context, job *jobs.JobOrderBackfill, columns ...string) error {
+	_, err := j.db.Context(ctx).ID(job.Id).Cols(columns...).Update(job)
+	return err
+}
@@ -0,0 +1,38 @@
+package job
+
+import (
+	"context"
+
+	"xorm.io/xorm"
+
+	"backend/internal/domain/entity/jobs"
+	jobRepo "backend/internal/domain/repo/jobs"
+)
+
+var _ jobRepo.WebhookDriftRepository = (*WebhookDriftRepoImpl)(nil)
+
+type WebhookDriftRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewWebhookDriftRepository(db *xorm.Engine) jobRepo.WebhookDriftRepository {
+	return &WebhookDriftRepoImpl{db: db}
+}
+
+func (w *WebhookDriftRepoImpl) Create(ctx context.Context, drifts []*jobs.WebhookDrift) error {
+	if len(drifts) == 0 {
+		return nil
+	}
+	_, err := w.db.Context(ctx).Insert(&drifts)
+	return err
+}
+
+func (w *WebhookDriftRepoImpl) List(ctx context.Context, userID int64, limit int) ([]*jobs.WebhookDrift, error) {
+	list := make([]*jobs.WebhookDrift, 0)
+	session := w.db.Context(ctx)
+	if userID > 0 {
+		session.Where("user_id = ?", userID)
+	}
+	err := session.Desc("id").Limit(limit).Find(&list)
+	return list, err
+}
@@ -0,0 +1,92 @@
+package job
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"xorm.io/xorm"
+
+	"backend/internal/domain/entity/jobs"
+	jobRepo "backend/internal/domain/repo/jobs"
+)
+
+// mysqlErrDuplicateEntry 唯一键冲突的错误码
+const mysqlErrDuplicateEntry = 1062
+
+var _ jobRepo.WebhookInboxRepository = (*WebhookInboxRepoImpl)(nil)
+
+type WebhookInboxRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewWebhookInboxRepository(db *xorm.Engine) jobRepo.WebhookInboxRepository {
+	return &WebhookInboxRepoImpl{db: db}
+}
+
+func (w *WebhookInboxRepoImpl) Create(ctx context.Context, inbox *jobs.WebhookInbox) (bool, error) {
+	_, err := w.db.Context(ctx).Insert(inbox)
+	if err != nil {
+		// Shopify 重试同一个 webhook 时 webhook_id 相同，唯一键冲突说明已经保存过
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (w *WebhookInboxRepoImpl) First(ctx context.Context, id int64) (*jobs.WebhookInbox, error) {
+	var inbox jobs.WebhookInbox
+	has, err := w.db.Context(ctx).Where("id = ?", id).Get(&inbox)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &inbox, nil
+}
+
+func (w *WebhookInboxRepoImpl) FirstByWebhookId(ctx context.Context, webhookId string) (*jobs.WebhookInbox, error) {
+	var inbox jobs.WebhookInbox
+	has, err := w.db.Context(ctx).Where("webhook_id = ?", webhookId).Get(&inbox)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &inbox, nil
+}
+
+func (w *WebhookInboxRepoImpl) Update(ctx context.Context, inbox *jobs.WebhookInbox, columns ...string) error {
+	_, err := w.db.Context(ctx).ID(inbox.Id).Cols(columns...).Update(inbox)
+	return err
+}
+
+func (w *WebhookInboxRepoImpl) ClearPayload(ctx context.Context, appId string, shop string, customerID int64, email string, exceptID int64) (int64, error) {
+	email = jobs.NormalizeEmail(email)
+	var match string
+	var args []interface{}
+	switch {
+	case customerID > 0 && email != "":
+		match, args = "(customer_id = ? OR customer_email = ?)", []interface{}{customerID, email}
+	case customerID > 0:
+		match, args = "customer_id = ?", []interface{}{customerID}
+	case email != "":
+		match, args = "customer_email = ?", []interface{}{email}
+	default:
+		return 0, nil
+	}
+	return w.db.Context(ctx).
+		Where("app_id = ? AND shop = ? AND id <> ?", appId, shop, exceptID).
+		And(match, args...).
+		Cols("payload", "customer_email").
+		Update(&jobs.WebhookInbox{})
+}
+
+func (w *WebhookInboxRepoImpl) DeleteByShop(ctx context.Context, appId string, shop string, exceptID int64) (int64, error) {
+	return w.db.Context(ctx).Where("app_id = ? AND shop = ? AND id <> ?", appId, shop, exceptID).Delete(&jobs.WebhookInbox{})
+}
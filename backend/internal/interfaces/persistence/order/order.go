@@ -5,6 +5,7 @@ import (
 
 	orderEntity "backend/internal/domain/entity/orders"
 	orderRepo "backend/internal/domain/repo/orders"
+	"backend/internal/interfaces/persistence"
 
 	"xorm.io/xorm"
 )
@@ -21,7 +22,7 @@ func NewOrderRepository(db *xorm.Engine) orderRepo.OrderRepository {
 
 // DelOrder 软删除订单
 func (o *orderRepoImpl) DelOrder(ctx context.Context, userID int64, orderId int64) error {
-	_, err := o.db.Context(ctx).Where("user_id = ? and order_id = ?", userID, orderId).
+	_, err := persistence.Session(ctx, o.db).Where("user_id = ? and order_id = ?", userID, orderId).
 		Update(&orderEntity.UserOrder{IsDel: 1})
 	if err != nil {
 		return err
@@ -85,7 +86,7 @@ func (o *orderRepoImpl) List(ctx context.Context, req orderEntity.QueryOrderEnti
 
 // Create 创建订单
 func (o *orderRepoImpl) Create(ctx context.Context, order *orderEntity.UserOrder) (int64, error) {
-	_, err := o.db.Context(ctx).Insert(order)
+	_, err := persistence.Session(ctx, o.db).Insert(order)
 	if err != nil {
 		return 0, err
 	}
@@ -95,7 +96,7 @@ func (o *orderRepoImpl) Create(ctx context.Context, order *orderEntity.UserOrder
 // ExistsByOrderID 检查订单是否存在
 func (o *orderRepoImpl) ExistsByOrderID(ctx context.Context, orderId int64, userID int64) int64 {
 	var userOrder orderEntity.UserOrder
-	has, err := o.db.Context(ctx).Cols("id").Where("user_id = ? and order_id = ?", userID, orderId).Get(&userOrder)
+	has, err := persistence.Session(ctx, o.db).Cols("id").Where("user_id = ? and order_id = ?", userID, orderId).Get(&userOrder)
 
 	if err != nil || !has {
 		return 0
@@ -104,21 +105,30 @@ func (o *orderRepoImpl) ExistsByOrderID(ctx context.Context, orderId int64, user
 	return userOrder.Id
 }
 
+func (o *orderRepoImpl) IsBackfilled(ctx context.Context, id int64) (bool, error) {
+	var userOrder orderEntity.UserOrder
+	has, err := persistence.Session(ctx, o.db).Cols("id", "backfilled").ID(id).Get(&userOrder)
+	if err != nil {
+		return false, err
+	}
+	return has && userOrder.Backfilled == 1, nil
+}
+
 // UpdateShopifyOrderId 更新订单信息
 func (o *orderRepoImpl) UpdateShopifyOrderId(ctx context.Context, order *orderEntity.UserOrder) error {
-	_, err := o.db.Context(ctx).ID(order.Id).Update(order)
+	_, err := persistence.Session(ctx, o.db).ID(order.Id).Update(order)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetOrderStatistics 获取订单统计信息
+// GetOrderStatistics 获取 [start, end) 内创建的订单统计信息
 func (o *orderRepoImpl) GetOrderStatistics(ctx context.Context, start, end int64, userID int64) (*orderEntity.OrderStatistics, error) {
 	var stats orderEntity.OrderStatistics
 
 	// 在XORM中使用SQL构建统计查询
-	has, err := o.db.Context(ctx).SQL("SELECT SUM(refund_price_amount) AS total_refund, SUM(insurance_amount) AS total_insurance, COUNT(*) AS total_orders FROM user_order WHERE order_created_at BETWEEN ? AND ? AND user_id = ?", start, end, userID).Get(&stats)
+	has, err := persistence.Session(ctx, o.db).SQL("SELECT COALESCE(SUM(refund_price_amount), 0) AS total_refund, COALESCE(SUM(protectify_amount), 0) AS total_protectify, COUNT(*) AS total_orders FROM user_order WHERE order_created_at >= ? AND order_created_at < ? AND user_id = ?", start, end, userID).Get(&stats)
 
 	if err != nil {
 		return nil, err
@@ -129,3 +139,42 @@ func (o *orderRepoImpl) GetOrderStatistics(ctx context.Context, start, end int64
 
 	return &stats, nil
 }
+
+// GetByIDs 根据ID批量查询订单
+func (o *orderRepoImpl) GetByIDs(ctx context.Context, userID int64, ids []int64) ([]*orderEntity.UserOrder, error) {
+	orders := make([]*orderEntity.UserOrder, 0, len(ids))
+	if len(ids) == 0 {
+		return orders, nil
+	}
+	err := persistence.Session(ctx, o.db).Where("user_id = ?", userID).In("id", ids).Find(&orders)
+	return orders, err
+}
+
+func (o *orderRepoImpl) First(ctx context.Context, userID int64, id int64) (*orderEntity.UserOrder, error) {
+	var order orderEntity.UserOrder
+	has, err := persistence.Session(ctx, o.db).Where("id = ? AND user_id = ? AND is_del = 0", id, userID).Get(&order)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func (o *orderRepoImpl) FirstByName(ctx context.Context, userID int64, orderName string) (*orderEntity.UserOrder, error) {
+	var order orderEntity.UserOrder
+	has, err := persistence.Session(ctx, o.db).Where("user_id = ? AND order_name = ? AND is_del = 0", userID, orderName).Get(&order)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func (o *orderRepoImpl) UpdateEmail(ctx context.Context, id int64, email string) error {
+	_, err := persistence.Session(ctx, o.db).ID(id).Cols("email").Update(&orderEntity.UserOrder{Email: email})
+	return err
+}
@@ -7,6 +7,7 @@ import (
 
 	"backend/internal/domain/entity/orders"
 	orderRepo "backend/internal/domain/repo/orders"
+	"backend/internal/interfaces/persistence"
 )
 
 var _ orderRepo.OrderInfoRepository = (*infoRepoImpl)(nil)
@@ -21,7 +22,7 @@ func NewOrderInfoRepository(engine *xorm.Engine) orderRepo.OrderInfoRepository {
 }
 
 func (o *infoRepoImpl) Create(ctx context.Context, orderInfo []*orders.UserOrderInfo) error {
-	_, err := o.db.Context(ctx).Insert(orderInfo)
+	_, err := persistence.Session(ctx, o.db).Insert(orderInfo)
 	if err != nil {
 		return err
 	}
@@ -29,7 +30,7 @@ func (o *infoRepoImpl) Create(ctx context.Context, orderInfo []*orders.UserOrder
 }
 
 func (o *infoRepoImpl) UpdateShopifyVariants(ctx context.Context, userOrderId int64, variantId int64, orderInfo *orders.UserOrderInfo) error {
-	_, err := o.db.Context(ctx).
+	_, err := persistence.Session(ctx, o.db).
 		Where("user_order_id = ? and variant_id = ?", userOrderId, variantId).
 		Update(orderInfo)
 	if err != nil {
@@ -41,7 +42,7 @@ func (o *infoRepoImpl) UpdateShopifyVariants(ctx context.Context, userOrderId in
 func (o *infoRepoImpl) GetOrderDetailVariantIDs(ctx context.Context, userOrderId int64, userID int64) ([]int64, error) {
 	var variantIDs []int64
 
-	err := o.db.Context(ctx).
+	err := persistence.Session(ctx, o.db).
 		Table(new(orders.UserOrderInfo)).
 		Where("user_order_id = ? and user_id = ?", userOrderId, userID).
 		Cols("variant_id").
@@ -52,3 +53,15 @@ func (o *infoRepoImpl) GetOrderDetailVariantIDs(ctx context.Context, userOrderId
 
 	return variantIDs, nil
 }
+
+func (o *infoRepoImpl) GetByUserOrderId(ctx context.Context, userOrderId int64, userID int64) ([]*orders.UserOrderInfo, error) {
+	var infos []*orders.UserOrderInfo
+	err := persistence.Session(ctx, o.db).
+		Where("user_order_id = ? and user_id = ?", userOrderId, userID).
+		Asc("id").
+		Find(&infos)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
@@ -22,9 +22,9 @@ func NewOrderSummaryRepository(engine *xorm.Engine) orderRepo.OrderSummaryReposi
 
 func (s *summaryRepoImpl) GetByDays(ctx context.Context, userId int64, days int) ([]orders.OrderSummary, error) {
 	var summary []orders.OrderSummary
-	err := s.db.
+	err := s.db.Context(ctx).
 		Where("user_id = ? ", userId).
-		Desc("id").
+		Desc("today").
 		Limit(days).
 		Find(&summary)
 	if err != nil {
@@ -58,3 +58,24 @@ func (s *summaryRepoImpl) CrateOrderStatistics(ctx context.Context, orderSummary
 	_, err := s.db.Context(ctx).Insert(&orderSummary)
 	return err
 }
+
+func (s *summaryRepoImpl) Span(ctx context.Context, userID int64) (int64, int64, error) {
+	var span struct {
+		First int64 `xorm:"'first'"`
+		Last  int64 `xorm:"'last'"`
+	}
+	_, err := s.db.Context(ctx).SQL("SELECT COALESCE(MIN(today), 0) AS first, COALESCE(MAX(today), 0) AS last FROM order_summary WHERE user_id = ?", userID).Get(&span)
+	if err != nil {
+		return 0, 0, err
+	}
+	return span.First, span.Last, nil
+}
+
+func (s *summaryRepoImpl) DeleteExcept(ctx context.Context, userID int64, keep []int64) error {
+	session := s.db.Context(ctx).Where("user_id = ?", userID)
+	if len(keep) > 0 {
+		session = session.NotIn("today", keep)
+	}
+	_, err := session.Delete(&orders.OrderSummary{})
+	return err
+}
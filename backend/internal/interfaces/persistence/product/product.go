@@ -93,3 +93,13 @@ func (p *productRepoImpl) ExistsByProductID(ctx context.Context, userID int64, p
 
 	return userProduct.Id
 }
+
+func (p *productRepoImpl) Uploaded(ctx context.Context, lastID int64, size int) ([]*products.UserProduct, error) {
+	var list []*products.UserProduct
+	err := p.db.Context(ctx).
+		Where("id > ? AND product_id > 0", lastID).
+		Asc("id").
+		Limit(size).
+		Find(&list)
+	return list, err
+}
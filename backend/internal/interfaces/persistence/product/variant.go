@@ -22,7 +22,7 @@ func NewVariantRepository(engine *xorm.Engine) productRepo.VariantRepository {
 
 func (v *variantRepoImpl) First(ctx context.Context, userID int64) (*products.UserVariant, error) {
 	var variant products.UserVariant
-	has, err := v.db.Context(ctx).Where("user_id = ?", userID).Get(&variant)
+	has, err := v.db.Context(ctx).Where("user_id = ? AND is_del = 0", userID).Get(&variant)
 
 	if err != nil {
 		return nil, err
@@ -36,7 +36,7 @@ func (v *variantRepoImpl) First(ctx context.Context, userID int64) (*products.Us
 
 func (v *variantRepoImpl) FindID(ctx context.Context, userProductId int64) ([]*products.UserVariant, error) {
 	var variants []*products.UserVariant
-	err := v.db.Context(ctx).Where("user_product_id = ?", userProductId).Find(&variants)
+	err := v.db.Context(ctx).Where("user_product_id = ? AND is_del = 0", userProductId).Find(&variants)
 
 	if err != nil {
 		return nil, err
@@ -80,9 +80,9 @@ func (v *variantRepoImpl) GetUploadedVariantIDs(ctx context.Context, userID int6
 }
 
 func (v *variantRepoImpl) DelShopifyVariant(ctx context.Context, userID int64) error {
-	// 使用XORM的Update方法更新多个字段
+	// 使用XORM的Update方法更新多个字段，已替换的变体保留变体ID用于识别历史订单
 	_, err := v.db.Context(ctx).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND is_del = 0", userID).
 		Update(&products.UserVariant{
 			ProductId:   0,
 			VariantId:   0,
@@ -94,12 +94,22 @@ func (v *variantRepoImpl) DelShopifyVariant(ctx context.Context, userID int64) e
 	return nil
 }
 
+func (v *variantRepoImpl) DeleteVariants(ctx context.Context, userID int64, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	// 软删除，保留变体ID，替换前下单的订单仍能识别保险变体
+	_, err := v.db.Context(ctx).Where("user_id = ? AND is_del = 0", userID).In("id", ids).
+		Cols("is_del").Update(&products.UserVariant{IsDel: 1})
+	return err
+}
+
 func (v *variantRepoImpl) GetVariantConfig(ctx context.Context, userID int64) (map[string]int64, int64, error) {
 	var userVariants []products.UserVariant
 
 	// 查询所有数据
 	err := v.db.Context(ctx).
-		Where("user_id = ? AND user_product_id != ?", userID, "").
+		Where("user_id = ? AND user_product_id != ? AND is_del = 0", userID, "").
 		Cols("sku_name", "product_id", "variant_id").
 		Find(&userVariants)
 	if err != nil {
@@ -0,0 +1,46 @@
+package persistence
+
+import (
+	"context"
+
+	"xorm.io/xorm"
+
+	"backend/internal/domain/repo"
+)
+
+type txSessionKey struct{}
+
+var _ repo.TransactionRepository = (*transactionRepoImpl)(nil)
+
+type transactionRepoImpl struct {
+	db *xorm.Engine
+}
+
+func NewTransactionRepository(db *xorm.Engine) repo.TransactionRepository {
+	return &transactionRepoImpl{db: db}
+}
+
+func (t *transactionRepoImpl) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txSessionKey{}).(*xorm.Session); ok {
+		return fn(ctx)
+	}
+
+	session := t.db.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txSessionKey{}, session)); err != nil {
+		_ = session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+// Session 返回 ctx 中的事务 session，不在事务中时返回普通 session
+func Session(ctx context.Context, db *xorm.Engine) *xorm.Session {
+	if session, ok := ctx.Value(txSessionKey{}).(*xorm.Session); ok {
+		return session
+	}
+	return db.Context(ctx)
+}
@@ -2,27 +2,35 @@ package user
 
 import (
 	"context"
+	"fmt"
 
 	"xorm.io/xorm"
 
 	userEntity "backend/internal/domain/entity/apps"
 	"backend/internal/domain/repo/apps"
+	"backend/pkg/crypto"
 )
 
 var _ apps.AppAuthRepository = (*appAuthRepoImpl)(nil)
 
 type appAuthRepoImpl struct {
-	db *xorm.Engine
+	db     *xorm.Engine
+	crypto *crypto.Envelope
 }
 
-func NewAppAuthRepository(db *xorm.Engine) apps.AppAuthRepository {
+// NewAppAuthRepository auth_token 和 refresh_token 加密后落库
+func NewAppAuthRepository(db *xorm.Engine, envelope *crypto.Envelope) apps.AppAuthRepository {
 	return &appAuthRepoImpl{
-		db: db,
+		db:     db,
+		crypto: envelope,
 	}
 }
 
 func (a *appAuthRepoImpl) Create(ctx context.Context, appAuth *userEntity.UserAppAuth) (int64, error) {
-	_, err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).Insert(appAuth)
+	err := a.withEncryptedTokens(appAuth, func() error {
+		_, err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).Insert(appAuth)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -31,7 +39,10 @@ func (a *appAuthRepoImpl) Create(ctx context.Context, appAuth *userEntity.UserAp
 }
 
 func (a *appAuthRepoImpl) Update(ctx context.Context, appAuth *userEntity.UserAppAuth) error {
-	_, err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).ID(appAuth.Id).Update(appAuth)
+	err := a.withEncryptedTokens(appAuth, func() error {
+		_, err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).ID(appAuth.Id).Update(appAuth)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -48,8 +59,9 @@ func (a *appAuthRepoImpl) Get(ctx context.Context, id int64, columns ...string)
 		return nil, nil
 	}
 
-	return &appAuth, nil
+	return &appAuth, a.decryptTokens(&appAuth)
 }
+
 func (a *appAuthRepoImpl) GetByUserAndApp(ctx context.Context, userId int64, appId string, columns ...string) (*userEntity.UserAppAuth, error) {
 	var appAuth userEntity.UserAppAuth
 	has, err := a.db.Table(userEntity.UserAppAuthTable).Where("user_id = ? and app_id = ?", userId, appId).Cols(columns...).Get(&appAuth)
@@ -60,5 +72,75 @@ func (a *appAuthRepoImpl) GetByUserAndApp(ctx context.Context, userId int64, app
 		return nil, nil
 	}
 
-	return &appAuth, nil
+	return &appAuth, a.decryptTokens(&appAuth)
+}
+
+// RotateTokens 用最新密钥重新加密 token，返回本批最后一条记录的 id 和重新加密的数量，没有数据时 id 为 0
+func (a *appAuthRepoImpl) RotateTokens(ctx context.Context, cursorID int64, limit int) (int64, int, error) {
+	var list []*userEntity.UserAppAuth
+	err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).Where("id > ?", cursorID).
+		Cols("id", "auth_token", "refresh_token").Asc("id").Limit(limit).Find(&list)
+	if err != nil || len(list) == 0 {
+		return 0, 0, err
+	}
+
+	rotated := 0
+	for _, appAuth := range list {
+		if !a.crypto.NeedsRotate(appAuth.AuthToken) && !a.crypto.NeedsRotate(appAuth.RefreshToken) {
+			continue
+		}
+		oldAuth, oldRefresh := appAuth.AuthToken, appAuth.RefreshToken
+		if err := a.decryptTokens(appAuth); err != nil {
+			return 0, rotated, err
+		}
+		authToken, err := a.crypto.Encrypt(appAuth.AuthToken)
+		if err != nil {
+			return 0, rotated, err
+		}
+		refreshToken, err := a.crypto.Encrypt(appAuth.RefreshToken)
+		if err != nil {
+			return 0, rotated, err
+		}
+		// 带上旧值作为条件，期间刷新写入的新 token 不会被覆盖
+		affected, err := a.db.Context(ctx).Table(userEntity.UserAppAuthTable).
+			Where("id = ? and auth_token = ? and refresh_token = ?", appAuth.Id, oldAuth, oldRefresh).
+			Update(map[string]interface{}{"auth_token": authToken, "refresh_token": refreshToken})
+		if err != nil {
+			return 0, rotated, err
+		}
+		rotated += int(affected)
+	}
+	return list[len(list)-1].Id, rotated, nil
+}
+
+// withEncryptedTokens 加密 token 后执行写入，结束后恢复明文
+func (a *appAuthRepoImpl) withEncryptedTokens(appAuth *userEntity.UserAppAuth, write func() error) error {
+	authToken, refreshToken := appAuth.AuthToken, appAuth.RefreshToken
+	encryptedAuth, err := a.crypto.Encrypt(authToken)
+	if err != nil {
+		return err
+	}
+	encryptedRefresh, err := a.crypto.Encrypt(refreshToken)
+	if err != nil {
+		return err
+	}
+	appAuth.AuthToken, appAuth.RefreshToken = encryptedAuth, encryptedRefresh
+	defer func() {
+		appAuth.AuthToken, appAuth.RefreshToken = authToken, refreshToken
+	}()
+	return write()
+}
+
+// decryptTokens 解密查询结果中的 token
+func (a *appAuthRepoImpl) decryptTokens(appAuth *userEntity.UserAppAuth) error {
+	authToken, err := a.crypto.Decrypt(appAuth.AuthToken)
+	if err != nil {
+		return fmt.Errorf("decrypt auth_token of app auth %d: %w", appAuth.Id, err)
+	}
+	refreshToken, err := a.crypto.Decrypt(appAuth.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("decrypt refresh_token of app auth %d: %w", appAuth.Id, err)
+	}
+	appAuth.AuthToken, appAuth.RefreshToken = authToken, refreshToken
+	return nil
 }
@@ -0,0 +1,234 @@
+package user
+
+import (
+	"context"
+	"strings"
+
+	"xorm.io/xorm"
+
+	"backend/internal/domain/entity/apps"
+	"backend/internal/domain/entity/claims"
+	"backend/internal/domain/entity/jobs"
+	"backend/internal/domain/entity/orders"
+	"backend/internal/domain/entity/products"
+	"backend/internal/domain/entity/settings"
+	"backend/internal/domain/entity/users"
+	userRepo "backend/internal/domain/repo/users"
+)
+
+// claimOfUser 店铺理赔的子查询条件
+const claimOfUser = "claim_id IN (SELECT id FROM order_claim WHERE user_id = ?)"
+
+type redactRepoImpl struct {
+	db *xorm.Engine
+}
+
+var _ userRepo.RedactRepository = (*redactRepoImpl)(nil)
+
+func NewRedactRepository(db *xorm.Engine) userRepo.RedactRepository {
+	return &redactRepoImpl{db: db}
+}
+
+func (r *redactRepoImpl) Create(ctx context.Context, history *users.RedactHistory) error {
+	_, err := r.db.Context(ctx).Insert(history)
+	return err
+}
+
+func (r *redactRepoImpl) FirstByInbox(ctx context.Context, inboxID int64) (*users.RedactHistory, error) {
+	var history users.RedactHistory
+	has, err := r.db.Context(ctx).Where("inbox_id = ?", inboxID).Get(&history)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &history, nil
+}
+
+func (r *redactRepoImpl) Update(ctx context.Context, history *users.RedactHistory, columns ...string) error {
+	_, err := r.db.Context(ctx).ID(history.Id).Cols(columns...).Update(history)
+	return err
+}
+
+func (r *redactRepoImpl) DataRequests(ctx context.Context, appId string, shop string) ([]*users.RedactHistory, error) {
+	list := make([]*users.RedactHistory, 0)
+	err := r.db.Context(ctx).
+		Where("app_id = ? AND shop = ? AND topic = ? AND status = ?", appId, shop, users.RedactTopicDataRequest, users.RedactStatusCompleted).
+		Desc("id").
+		Find(&list)
+	return list, err
+}
+
+func (r *redactRepoImpl) DataRequestExports(ctx context.Context, appId string, shop string, customerID int64) ([]*users.RedactHistory, error) {
+	list := make([]*users.RedactHistory, 0)
+	session := r.db.Context(ctx).
+		Where("app_id = ? AND shop = ? AND topic = ? AND file_key <> ''", appId, shop, users.RedactTopicDataRequest)
+	if customerID > 0 {
+		session.And("customer_id = ?", customerID)
+	}
+	err := session.Find(&list)
+	return list, err
+}
+
+func (r *redactRepoImpl) FirstDataRequest(ctx context.Context, appId string, shop string, id int64) (*users.RedactHistory, error) {
+	var history users.RedactHistory
+	has, err := r.db.Context(ctx).
+		Where("id = ? AND app_id = ? AND shop = ? AND topic = ?", id, appId, shop, users.RedactTopicDataRequest).
+		Get(&history)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &history, nil
+}
+
+func (r *redactRepoImpl) CustomerOrders(ctx context.Context, userID int64, orderIDs []int64, email string) ([]*orders.UserOrder, error) {
+	list := make([]*orders.UserOrder, 0)
+	// 邮箱为空时只按订单ID匹配，避免匹配到所有没有邮箱的订单
+	if len(orderIDs) == 0 && email == "" {
+		return list, nil
+	}
+	session := r.db.Context(ctx).Where("user_id = ?", userID)
+	switch {
+	case len(orderIDs) > 0 && email != "":
+		args := make([]interface{}, 0, len(orderIDs)+1)
+		for _, id := range orderIDs {
+			args = append(args, id)
+		}
+		args = append(args, email)
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(orderIDs)), ",")
+		session.And("(order_id IN ("+placeholders+") OR email = ?)", args...)
+	case len(orderIDs) > 0:
+		session.In("order_id", orderIDs)
+	default:
+		session.And("email = ?", email)
+	}
+	err := session.Asc("id").Find(&list)
+	return list, err
+}
+
+func (r *redactRepoImpl) CustomerClaims(ctx context.Context, userID int64, userOrderIDs []int64) ([]*claims.OrderClaim, error) {
+	list := make([]*claims.OrderClaim, 0)
+	if len(userOrderIDs) == 0 {
+		return list, nil
+	}
+	err := r.db.Context(ctx).Where("user_id = ?", userID).In("user_order_id", userOrderIDs).Asc("id").Find(&list)
+	return list, err
+}
+
+func (r *redactRepoImpl) RedactCustomer(ctx context.Context, userID int64, userOrderIDs []int64) (int, []string, error) {
+	if len(userOrderIDs) == 0 {
+		return 0, nil, nil
+	}
+	session := r.db.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return 0, nil, err
+	}
+
+	var claimIDs []int64
+	if err := session.Table(&claims.OrderClaim{}).Where("user_id = ?", userID).In("user_order_id", userOrderIDs).Cols("id").Find(&claimIDs); err != nil {
+		_ = session.Rollback()
+		return 0, nil, err
+	}
+
+	var total int64
+	affected, err := session.Where("user_id = ?", userID).In("id", userOrderIDs).Cols("email").Update(&orders.UserOrder{})
+	if err != nil {
+		_ = session.Rollback()
+		return 0, nil, err
+	}
+	total += affected
+
+	var urls []string
+	if len(claimIDs) > 0 {
+		// 理赔描述和备注可能包含顾客的地址、电话等，金额和状态保留
+		affected, err = session.In("id", claimIDs).Cols("description", "review_note", "resolution_note").Update(&claims.OrderClaim{})
+		if err != nil {
+			_ = session.Rollback()
+			return 0, nil, err
+		}
+		total += affected
+
+		if err := session.Table(&claims.OrderClaimEvidence{}).In("claim_id", claimIDs).Cols("url").Find(&urls); err != nil {
+			_ = session.Rollback()
+			return 0, nil, err
+		}
+		affected, err = session.In("claim_id", claimIDs).Delete(&claims.OrderClaimEvidence{})
+		if err != nil {
+			_ = session.Rollback()
+			return 0, nil, err
+		}
+		total += affected
+	}
+
+	if err := session.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return int(total), urls, nil
+}
+
+func (r *redactRepoImpl) RedactShop(ctx context.Context, userID int64) (int, []string, error) {
+	session := r.db.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return 0, nil, err
+	}
+
+	var urls []string
+	if err := session.Table(&claims.OrderClaimEvidence{}).Where(claimOfUser, userID).Cols("url").Find(&urls); err != nil {
+		_ = session.Rollback()
+		return 0, nil, err
+	}
+
+	var total int64
+	// 理赔子表要在理赔之前删除
+	for _, bean := range []interface{}{&claims.OrderClaimEvidence{}, &claims.OrderClaimItem{}} {
+		affected, err := session.Where(claimOfUser, userID).Delete(bean)
+		if err != nil {
+			_ = session.Rollback()
+			return 0, nil, err
+		}
+		total += affected
+	}
+	// 账单、订阅、对账和统计数据需要保留，不在这里删除
+	beans := []interface{}{
+		&claims.OrderClaim{},
+		&orders.UserOrderInfo{},
+		&orders.UserOrder{},
+		&jobs.JobOrder{},
+		&jobs.JobOrderBackfill{},
+		&jobs.JobProduct{},
+		&products.UserVariant{},
+		&products.UserProduct{},
+		&settings.UserCartSetting{},
+		&users.UserSetting{},
+		&apps.UserAppAuth{},
+	}
+	for _, bean := range beans {
+		affected, err := session.Where("user_id = ?", userID).Delete(bean)
+		if err != nil {
+			_ = session.Rollback()
+			return 0, nil, err
+		}
+		total += affected
+	}
+
+	// 店铺行保留给账单关联，只清除个人信息和 token
+	affected, err := session.ID(userID).
+		Cols("name", "real_domain", "access_token", "password", "email", "phone", "city").
+		Update(&users.User{})
+	if err != nil {
+		_ = session.Rollback()
+		return 0, nil, err
+	}
+	total += affected
+
+	if err := session.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return int(total), urls, nil
+}
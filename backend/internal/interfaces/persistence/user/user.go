@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	userRepo "backend/internal/domain/repo/users"
@@ -9,17 +10,19 @@ import (
 	"xorm.io/xorm"
 
 	"backend/internal/domain/entity/users"
+	"backend/pkg/crypto"
 )
 
 var _ userRepo.UserRepository = (*userRepoImpl)(nil)
 
 type userRepoImpl struct {
-	db *xorm.Engine
+	db     *xorm.Engine
+	crypto *crypto.Envelope
 }
 
-// NewUserRepository 从数据库获取用户资源
-func NewUserRepository(engine *xorm.Engine) userRepo.UserRepository {
-	return &userRepoImpl{db: engine}
+// NewUserRepository 从数据库获取用户资源，access_token 加密后落库
+func NewUserRepository(engine *xorm.Engine, envelope *crypto.Envelope) userRepo.UserRepository {
+	return &userRepoImpl{db: engine, crypto: envelope}
 }
 
 // FirstName 根据店铺名称查找用户
@@ -33,7 +36,7 @@ func (u *userRepoImpl) FirstName(ctx context.Context, appId string, name string)
 	if !has {
 		return nil, nil
 	}
-	return &user, nil
+	return &user, u.decryptToken(&user)
 }
 
 // GetUserIDByShop 根据店铺名称获取用户ID
@@ -57,7 +60,10 @@ func (u *userRepoImpl) Get(ctx context.Context, id int64, columns ...string) (*u
 		Where("id = ?", id).
 		Limit(1).
 		Get(user)
-	return user, err
+	if err != nil {
+		return user, err
+	}
+	return user, u.decryptToken(user)
 }
 
 // GetActiveUser 根据 id 获取 users.User
@@ -67,12 +73,18 @@ func (u *userRepoImpl) GetActiveUser(ctx context.Context, id int64, columns ...s
 		Where("id = ? and is_del = 0", id).
 		Limit(1).
 		Get(user)
-	return user, err
+	if err != nil {
+		return user, err
+	}
+	return user, u.decryptToken(user)
 }
 
 // CreateUser 创建用户
 func (u *userRepoImpl) CreateUser(ctx context.Context, user *users.User) (int64, error) {
-	_, err := u.db.Context(ctx).Insert(user)
+	err := u.withEncryptedToken(user, func() error {
+		_, err := u.db.Context(ctx).Insert(user)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -83,7 +95,10 @@ func (u *userRepoImpl) CreateUser(ctx context.Context, user *users.User) (int64,
 // Update 更新用户信息
 func (u *userRepoImpl) Update(ctx context.Context, user *users.User) error {
 	user.LastLogin = time.Now().Unix()
-	_, err := u.db.Context(ctx).ID(user.ID).Update(user)
+	err := u.withEncryptedToken(user, func() error {
+		_, err := u.db.Context(ctx).ID(user.ID).Update(user)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -118,6 +133,16 @@ func (u *userRepoImpl) UpdateIsClose(ctx context.Context, userID int64, planDisp
 	return nil
 }
 
+// UpdatePlan 更新用户当前的 app 套餐
+func (u *userRepoImpl) UpdatePlan(ctx context.Context, userID int64, planID int64) error {
+	_, err := u.db.Context(ctx).Table(new(users.User)).Where("id = ?", userID).
+		Update(map[string]interface{}{
+			"plans":       planID,
+			"update_time": time.Now().Unix(),
+		})
+	return err
+}
+
 // UpdateStep 更新用户引导步骤
 func (u *userRepoImpl) UpdateStep(ctx context.Context, userID int64, steps string) error {
 	// TODO 这块实现要放到 user_setting表里去
@@ -131,7 +156,11 @@ func (u *userRepoImpl) UpdateStep(ctx context.Context, userID int64, steps strin
 
 // SetToken 设置用户令牌和密码
 func (u *userRepoImpl) SetToken(ctx context.Context, userID int64, token string, pwd string) error {
-	_, err := u.db.Context(ctx).Where("id = ?", userID).
+	token, err := u.crypto.Encrypt(token)
+	if err != nil {
+		return err
+	}
+	_, err = u.db.Context(ctx).Where("id = ?", userID).
 		Update(&users.User{AccessToken: token, Password: pwd})
 	if err != nil {
 		return err
@@ -150,7 +179,7 @@ func (u *userRepoImpl) FirstEmail(ctx context.Context, email string) (*users.Use
 	if !has {
 		return nil, nil
 	}
-	return &user, nil
+	return &user, u.decryptToken(&user)
 }
 
 // UpdatePublishCollection 更新用户发布集合信息
@@ -189,7 +218,7 @@ func (u *userRepoImpl) FirstByShop(ctx context.Context, appId string, shop strin
 	if !has {
 		return nil, nil
 	}
-	return &user, nil
+	return &user, u.decryptToken(&user)
 }
 
 // GetActiveUserByShop 获取用户正常店铺
@@ -202,7 +231,7 @@ func (u *userRepoImpl) GetActiveUserByShop(ctx context.Context, appId string, sh
 	if !has {
 		return nil, nil
 	}
-	return &user, nil
+	return &user, u.decryptToken(&user)
 }
 
 // GetActiveUserByShopID GetActiveUserByShop 获取用户正常店铺
@@ -215,12 +244,12 @@ func (u *userRepoImpl) GetActiveUserByShopID(ctx context.Context, appId string,
 	if !has {
 		return nil, nil
 	}
-	return &user, nil
+	return &user, u.decryptToken(&user)
 }
 
 func (u *userRepoImpl) GetUsers(ctx context.Context, cursorId int64, limit int) ([]*users.User, error) {
 	var usersList []*users.User
-	err := u.db.Context(ctx).Where("id > ? and is_del = 0", cursorId).Cols("id").Limit(limit).Find(&usersList)
+	err := u.db.Context(ctx).Where("id > ? and is_del = 0", cursorId).Cols("id", "iana_timezone").Asc("id").Limit(limit).Find(&usersList)
 	if err != nil {
 		return nil, err
 	}
@@ -229,3 +258,60 @@ func (u *userRepoImpl) GetUsers(ctx context.Context, cursorId int64, limit int)
 	}
 	return usersList, nil
 }
+
+// RotateTokens 用最新密钥重新加密 access_token，返回本批最后一个用户 id 和重新加密的数量，没有数据时 id 为 0
+func (u *userRepoImpl) RotateTokens(ctx context.Context, cursorID int64, limit int) (int64, int, error) {
+	var usersList []*users.User
+	err := u.db.Context(ctx).Where("id > ?", cursorID).Cols("id", "access_token").Asc("id").Limit(limit).Find(&usersList)
+	if err != nil || len(usersList) == 0 {
+		return 0, 0, err
+	}
+
+	rotated := 0
+	for _, user := range usersList {
+		if !u.crypto.NeedsRotate(user.AccessToken) {
+			continue
+		}
+		token, err := u.crypto.Decrypt(user.AccessToken)
+		if err != nil {
+			return 0, rotated, fmt.Errorf("user %d: %w", user.ID, err)
+		}
+		encrypted, err := u.crypto.Encrypt(token)
+		if err != nil {
+			return 0, rotated, err
+		}
+		// 带上旧值作为条件，期间重新授权写入的新 token 不会被覆盖
+		affected, err := u.db.Context(ctx).Table(new(users.User)).
+			Where("id = ? and access_token = ?", user.ID, user.AccessToken).
+			Update(map[string]interface{}{"access_token": encrypted})
+		if err != nil {
+			return 0, rotated, err
+		}
+		rotated += int(affected)
+	}
+	return usersList[len(usersList)-1].ID, rotated, nil
+}
+
+// withEncryptedToken 加密 access_token 后执行写入，结束后恢复明文，调用方可以继续使用 user
+func (u *userRepoImpl) withEncryptedToken(user *users.User, write func() error) error {
+	token := user.AccessToken
+	encrypted, err := u.crypto.Encrypt(token)
+	if err != nil {
+		return err
+	}
+	user.AccessToken = encrypted
+	defer func() {
+		user.AccessToken = token
+	}()
+	return write()
+}
+
+// decryptToken 解密查询结果中的 access_token
+func (u *userRepoImpl) decryptToken(user *users.User) error {
+	token, err := u.crypto.Decrypt(user.AccessToken)
+	if err != nil {
+		return fmt.Errorf("decrypt access_token of user %d: %w", user.ID, err)
+	}
+	user.AccessToken = token
+	return nil
+}
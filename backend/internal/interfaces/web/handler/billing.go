@@ -6,7 +6,10 @@ import (
 	"backend/internal/application"
 	"backend/internal/application/users"
 	"backend/internal/domain/entity"
+	billingEntity "backend/internal/domain/entity/billings"
 	"backend/pkg/response"
+	"backend/pkg/response/code"
+	"backend/pkg/response/message"
 )
 
 type BillingHandler struct {
@@ -14,6 +17,7 @@ type BillingHandler struct {
 	subscriptionService *users.SubscriptionService
 	userService         *users.UserService
 	billingService      *users.BillingService
+	planService         *users.PlanService
 }
 
 func NewBillingHandler(services *application.Services) *BillingHandler {
@@ -21,6 +25,7 @@ func NewBillingHandler(services *application.Services) *BillingHandler {
 		subscriptionService: services.SubscriptionService,
 		userService:         services.UserService,
 		billingService:      services.BillingService,
+		planService:         services.PlanService,
 	}
 }
 
@@ -77,3 +82,136 @@ func (b *BillingHandler) CurrentPeriod(c *gin.Context) {
 
 	b.Success(c, "", data)
 }
+
+// CappedAmount 用量上限使用情况和预计达到上限的时间
+func (b *BillingHandler) CappedAmount(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+
+	data, err := b.billingService.CappedAmountProjection(ctx, userID)
+	if err != nil {
+		b.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", data)
+}
+
+// IncreaseCappedAmount 提高用量上限，返回 Shopify 确认链接
+func (b *BillingHandler) IncreaseCappedAmount(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+	var req billingEntity.CappedAmountReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		b.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), nil)
+		return
+	}
+
+	confirmUrl, err := b.subscriptionService.RequestCappedAmountIncrease(ctx, userID, req.CappedAmount)
+	if err != nil {
+		b.Error(c, code.PaymentRequestFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", confirmUrl)
+}
+
+// PlanList 套餐目录和商家当前套餐
+func (b *BillingHandler) PlanList(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+
+	data, err := b.planService.List(ctx, userID)
+	if err != nil {
+		b.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", data)
+}
+
+// ChangePlan 升级或降级套餐，返回 Shopify 确认链接，切换到免费套餐时链接为空
+func (b *BillingHandler) ChangePlan(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+	var req billingEntity.PlanChangeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		b.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), nil)
+		return
+	}
+
+	confirmUrl, err := b.subscriptionService.ChangePlan(ctx, userID, req.PlanCode)
+	if err != nil {
+		b.Error(c, code.PaymentRequestFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", confirmUrl)
+}
+
+// CancelPlan 取消订阅，回到默认套餐
+func (b *BillingHandler) CancelPlan(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+
+	if err := b.subscriptionService.CancelSubscription(ctx, userID); err != nil {
+		b.Error(c, code.PaymentRequestFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", nil)
+}
+
+// Statement 导出账期账单，返回签名下载链接
+func (b *BillingHandler) Statement(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := b.userService.GetClaims(ctx).UserID
+	var req billingEntity.StatementReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		b.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), nil)
+		return
+	}
+
+	data, err := b.billingService.Statement(ctx, userID, &req)
+	if err != nil {
+		b.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", data)
+}
+
+// ReconciliationList 后台查看账单对账差异
+func (b *BillingHandler) ReconciliationList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req billingEntity.ReconciliationListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		b.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), nil)
+		return
+	}
+
+	data, err := b.billingService.ReconciliationList(ctx, &req)
+	if err != nil {
+		b.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", data)
+}
+
+// RunReconciliation 后台手动触发对账
+func (b *BillingHandler) RunReconciliation(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req billingEntity.ReconcileRunReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		b.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), nil)
+		return
+	}
+
+	if err := b.billingService.RunReconciliation(ctx, req.UserID); err != nil {
+		b.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+
+	b.Success(c, "", nil)
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/internal/application"
+	"backend/internal/application/claims"
+	"backend/internal/application/users"
+	appEntity "backend/internal/domain/entity/apps"
+	claimEntity "backend/internal/domain/entity/claims"
+	"backend/pkg/ctxkeys"
+	"backend/pkg/logger"
+	"backend/pkg/response"
+	"backend/pkg/response/code"
+	"backend/pkg/response/message"
+)
+
+type ClaimHandler struct {
+	response.BaseHandler
+	userService  *users.UserService
+	claimService *claims.ClaimService
+}
+
+func NewClaimHandler(services *application.Services) *ClaimHandler {
+	return &ClaimHandler{userService: services.UserService, claimService: services.ClaimService}
+}
+
+// ClaimList 理赔列表
+func (h *ClaimHandler) ClaimList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req claimEntity.ClaimListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+	req.UserID = h.userService.GetClaims(ctx).UserID
+
+	data, err := h.claimService.List(ctx, &req)
+	if err != nil {
+		h.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// ClaimDetail 理赔详情
+func (h *ClaimHandler) ClaimDetail(c *gin.Context) {
+	ctx := c.Request.Context()
+	claimID, err := strconv.ParseInt(c.Query("id"), 10, 64)
+	if err != nil || claimID <= 0 {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.Detail(ctx, h.userService.GetClaims(ctx).UserID, claimID)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// SubmitClaim 商家代客户提交理赔
+func (h *ClaimHandler) SubmitClaim(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req claimEntity.ClaimSubmitReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.Submit(ctx, h.userService.GetClaims(ctx).UserID, claimEntity.SourceMerchant, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// ReviewClaim 开始审核、通过或拒绝理赔
+func (h *ClaimHandler) ReviewClaim(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req claimEntity.ClaimReviewReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.Review(ctx, h.userService.GetClaims(ctx).UserID, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// ResolveClaim 标记理赔处理完成
+func (h *ClaimHandler) ResolveClaim(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req claimEntity.ClaimResolveReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.Resolve(ctx, h.userService.GetClaims(ctx).UserID, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// RetryResolution 重新发起理赔的 Shopify 处理
+func (h *ClaimHandler) RetryResolution(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req claimEntity.ClaimRetryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.RetryResolution(ctx, h.userService.GetClaims(ctx).UserID, req.ClaimId)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// PortalLookup 客户通过订单编号和邮箱查询可理赔商品
+func (h *ClaimHandler) PortalLookup(c *gin.Context) {
+	ctx := c.Request.Context()
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	var req claimEntity.PortalOrderReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.PortalLookup(ctx, appData.AppID, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// PortalUpload 客户上传理赔图片
+func (h *ClaimHandler) PortalUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	file, err := c.FormFile("file")
+	if err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	imagePath, err := h.claimService.PortalUpload(ctx, appData.AppID, c.PostForm("shop"), file)
+	if err != nil {
+		logger.Warn(ctx, "claim portal upload error", "err", err)
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", map[string]interface{}{"imagePath": imagePath})
+}
+
+// PortalSubmit 客户提交理赔
+func (h *ClaimHandler) PortalSubmit(c *gin.Context) {
+	ctx := c.Request.Context()
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	var req claimEntity.PortalSubmitReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.PortalSubmit(ctx, appData, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+// PortalStatus 客户凭查询凭证查看理赔进度
+func (h *ClaimHandler) PortalStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	var req claimEntity.PortalStatusReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := h.claimService.PortalStatus(ctx, appData, &req)
+	if err != nil {
+		h.claimError(c, err)
+		return
+	}
+	h.Success(c, "", data)
+}
+
+func (h *ClaimHandler) claimError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, claimEntity.ErrClaimNotFound), errors.Is(err, claimEntity.ErrOrderNotFound),
+		errors.Is(err, claimEntity.ErrShopNotFound), errors.Is(err, claimEntity.ErrInvalidToken):
+		h.Error(c, code.NotFound, err.Error(), "")
+	case errors.Is(err, claimEntity.ErrOrderNotClaimable), errors.Is(err, claimEntity.ErrNoClaimableItems),
+		errors.Is(err, claimEntity.ErrClaimExists), errors.Is(err, claimEntity.ErrClaimStatus), errors.Is(err, claimEntity.ErrResolutionType),
+		errors.Is(err, claimEntity.ErrInvalidEvidence):
+		h.Error(c, code.BadRequest, err.Error(), "")
+	default:
+		h.Error(c, code.ServerOperationFailed, err.Error(), "")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/google/uuid"
 
 	"backend/internal/domain/repo"
+	jobRepo "backend/internal/domain/repo/jobs"
+	"backend/pkg/logger"
 	"backend/pkg/response"
 	"backend/pkg/response/code"
 	"backend/pkg/response/message"
@@ -12,11 +14,12 @@ import (
 
 type CommonHandler struct {
 	response.BaseHandler
-	ossRepo repo.AliyunOSSRepository
+	ossRepo       repo.AliyunOSSRepository
+	schedulerRepo jobRepo.SchedulerRepository
 }
 
-func NewCommonHandler(ossRepo repo.AliyunOSSRepository) *CommonHandler {
-	return &CommonHandler{ossRepo: ossRepo}
+func NewCommonHandler(ossRepo repo.AliyunOSSRepository, schedulerRepo jobRepo.SchedulerRepository) *CommonHandler {
+	return &CommonHandler{ossRepo: ossRepo, schedulerRepo: schedulerRepo}
 }
 
 func (c *CommonHandler) Upload(ctx *gin.Context) {
@@ -36,3 +39,14 @@ func (c *CommonHandler) Upload(ctx *gin.Context) {
 
 	c.Success(ctx, "", map[string]interface{}{"imagePath": imagePath})
 }
+
+// SchedulerStatus 定时任务的配置、当前 leader 以及上次和下次执行时间
+func (c *CommonHandler) SchedulerStatus(ctx *gin.Context) {
+	status, err := c.schedulerRepo.Status(ctx.Request.Context())
+	if err != nil {
+		logger.Error(ctx, "scheduler status 查询失败", "Err:", err.Error())
+		c.Error(ctx, code.ServerOperationFailed, message.ErrorUnknow.Error(), nil)
+		return
+	}
+	c.Success(ctx, "", status)
+}
@@ -13,15 +13,17 @@ type Handlers struct {
 	SettingHandler *SettingHandler
 	WebhookHandler *WebHookHandler
 	BillingHandler *BillingHandler
+	ClaimHandler   *ClaimHandler
 }
 
 func InitHandlers(services *application.Services, repos *providers.Repositories) *Handlers {
 	orderHandler := NewOrderHandler(services.OrderService)
-	commonHandler := NewCommonHandler(repos.AliyunOssRepo)
+	commonHandler := NewCommonHandler(repos.AliyunOssRepo, repos.SchedulerRepo)
 	userHandler := NewUserHandler(services)
 	settingHandler := NewSettingHandler(services)
 	webhookHandler := NewWebHookHandler(services)
 	billingHandler := NewBillingHandler(services)
+	claimHandler := NewClaimHandler(services)
 	return &Handlers{
 		orderHandler,
 		commonHandler,
@@ -29,5 +31,6 @@ func InitHandlers(services *application.Services, repos *providers.Repositories)
 		settingHandler,
 		webhookHandler,
 		billingHandler,
+		claimHandler,
 	}
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 
@@ -13,6 +14,7 @@ import (
 	"backend/internal/application/users"
 	appEntity "backend/internal/domain/entity/apps"
 	settingEntity "backend/internal/domain/entity/settings"
+	"backend/internal/domain/pricing"
 	"backend/pkg/ctxkeys"
 	"backend/pkg/logger"
 	"backend/pkg/response"
@@ -69,8 +71,13 @@ func (s *SettingHandler) UpdateCart(ctx *gin.Context) {
 
 	settingToggleReq.UserID = uid
 
-	err = s.cartSettingService.SetCartSetting(ctxWithTrace, settingToggleReq)
+	check, err := s.cartSettingService.SetCartSetting(ctxWithTrace, settingToggleReq)
 
+	var ruleErr *pricing.RuleError
+	if errors.As(err, &ruleErr) {
+		s.Error(ctx, code.InvalidPricingRules, ruleErr.Error(), ruleErr.Check)
+		return
+	}
 	if err != nil {
 		utils.CallWilding(err.Error())
 		return
@@ -91,7 +98,7 @@ func (s *SettingHandler) UpdateCart(ctx *gin.Context) {
 		logger.Error(ctx, "Update product error: ", err.Error())
 		return
 	}
-	s.Success(ctx, "", nil)
+	s.Success(ctx, "", check)
 }
 
 func (s *SettingHandler) GetPublicCart(ctx *gin.Context) {
@@ -118,6 +125,32 @@ func (s *SettingHandler) GetPublicCart(ctx *gin.Context) {
 	s.Success(ctx, "", rsp)
 }
 
+// Quote 购物车插件查询保险报价
+func (s *SettingHandler) Quote(c *gin.Context) {
+	ctx := c.Request.Context()
+	appData := ctx.Value(ctxkeys.AppData).(*appEntity.AppData)
+	var req settingEntity.QuoteReq
+	if err := c.ShouldBindJSON(&req); err != nil || req.Subtotal.IsNegative() {
+		s.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	data, err := s.cartSettingService.Quote(ctx, appData.AppID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, settingEntity.ErrShopNotFound), errors.Is(err, settingEntity.ErrCartDisabled):
+			s.Error(c, code.NotFound, err.Error(), "")
+		case errors.Is(err, pricing.ErrNoPrice), errors.Is(err, settingEntity.ErrNoVariant):
+			s.Error(c, code.BadRequest, err.Error(), "")
+		default:
+			logger.Error(ctx, "quote 报价失败", "Err:", err.Error())
+			s.Error(c, code.ServerOperationFailed, err.Error(), "")
+		}
+		return
+	}
+	s.Success(c, "", data)
+}
+
 func (s *SettingHandler) UploadLogo(c *gin.Context) {
 	ctx := c.Request.Context()
 	image, err := c.FormFile("image")
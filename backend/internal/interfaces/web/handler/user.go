@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 
 	"backend/internal/application"
+	"backend/internal/application/orders"
 	"backend/internal/application/users"
+	"backend/internal/domain/entity/jobs"
 	userEntity "backend/internal/domain/entity/users"
 	"backend/pkg/response"
 	"backend/pkg/response/code"
@@ -15,10 +20,12 @@ type UserHandler struct {
 	response.BaseHandler
 	userService         *users.UserService
 	subscriptionService *users.SubscriptionService
+	orderService        *orders.OrderService
+	redactService       *users.RedactService
 }
 
 func NewUserHandler(services *application.Services) *UserHandler {
-	return &UserHandler{userService: services.UserService, subscriptionService: services.SubscriptionService}
+	return &UserHandler{userService: services.UserService, subscriptionService: services.SubscriptionService, orderService: services.OrderService, redactService: services.RedactService}
 }
 
 func (u *UserHandler) SetUserStep(c *gin.Context) {
@@ -81,21 +88,78 @@ func (u *UserHandler) GetSessionData(ctx *gin.Context) {
 
 func (u *UserHandler) CreateSubscribe(c *gin.Context) {
 	ctx := c.Request.Context()
-	subscription, confirmUrl, err := u.subscriptionService.CreateUsageSubscription(
-		ctx,
-		"Protectify tax",
-		200,
-		"USD",
-		"every paid order with insurance product will be taxed",
-		true,
-	)
+	userID := u.userService.GetClaims(ctx).UserID
+	// 不指定套餐时订阅默认套餐
+	confirmUrl, err := u.subscriptionService.ChangePlan(ctx, userID, c.Query("plan"))
 	if err != nil {
 		u.Error(c, code.PaymentRequestFailed, err.Error(), "")
 		return
 	}
-	if subscription == nil {
-		u.Error(c, code.PaymentRequestFailed, "subscription is nil", "")
+	u.Success(c, "", confirmUrl)
+}
+
+// StartOrderBackfill 回填安装前的历史订单
+func (u *UserHandler) StartOrderBackfill(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := u.userService.GetClaims(ctx).UserID
+	var req jobs.OrderBackfillReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		u.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
 		return
 	}
-	u.Success(c, "", confirmUrl)
+
+	job, err := u.orderService.StartBackfill(ctx, userID, req)
+	if err != nil {
+		u.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+	u.Success(c, "", job)
+}
+
+// OrderBackfillStatus 最近一次历史订单回填的进度
+func (u *UserHandler) OrderBackfillStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := u.userService.GetClaims(ctx).UserID
+
+	job, err := u.orderService.LatestBackfill(ctx, userID)
+	if err != nil {
+		u.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+	u.Success(c, "", job)
+}
+
+// DataRequests 顾客通过 Shopify 申请的数据导出记录
+func (u *UserHandler) DataRequests(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := u.userService.GetClaims(ctx).UserID
+
+	list, err := u.redactService.DataRequests(ctx, userID)
+	if err != nil {
+		u.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+	u.Success(c, "", list)
+}
+
+// DataRequestDownload 下载顾客数据导出文件，商家需要把文件提供给顾客
+func (u *UserHandler) DataRequestDownload(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := u.userService.GetClaims(ctx).UserID
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		u.Error(c, code.BadRequest, message.ErrorBadRequest.Error(), "")
+		return
+	}
+
+	resp, err := u.redactService.DataRequestDownload(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, userEntity.ErrDataRequestNotFound) {
+			u.Error(c, code.NotFound, err.Error(), "")
+			return
+		}
+		u.Error(c, code.ServerOperationFailed, err.Error(), "")
+		return
+	}
+	u.Success(c, "", resp)
 }
@@ -1,23 +1,21 @@
 package handler
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"backend/internal/application"
 	"backend/internal/application/apps"
-	"backend/internal/application/orders"
-	"backend/internal/application/products"
+	"backend/internal/application/jobs"
 	"backend/internal/application/users"
-	orderEntity "backend/internal/domain/entity/orders"
-	productEntity "backend/internal/domain/entity/products"
-	shopifyEntity "backend/internal/domain/entity/shopifys"
+	jobEntity "backend/internal/domain/entity/jobs"
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/pkg/logger"
 	"backend/pkg/response"
@@ -28,30 +26,22 @@ import (
 
 type WebHookHandler struct {
 	response.BaseHandler
-	productService      *products.ProductService
 	userService         *users.UserService
-	orderService        *orders.OrderService
 	appService          *apps.AppService
-	billingService      *users.BillingService
 	subscriptionService *users.SubscriptionService
+	webhookService      *jobs.WebhookService
 }
 
 func NewWebHookHandler(services *application.Services) *WebHookHandler {
 	return &WebHookHandler{
-		productService: services.ProductService, userService: services.UserService, orderService: services.OrderService, appService: services.AppService,
-		billingService:      services.BillingService,
+		userService:         services.UserService,
+		appService:          services.AppService,
 		subscriptionService: services.SubscriptionService,
+		webhookService:      services.WebhookJobService,
 	}
 }
 
-type WebhookData struct {
-	ID int64 `json:"id"`
-}
-
-type WebhookShopData struct {
-	PlanDisplayName string `json:"plan_display_name"`
-}
-
+// Shopify 验签后把 webhook 保存到收件箱，由 asynq 按 topic 异步处理
 func (w *WebHookHandler) Shopify(ctx *gin.Context) {
 	// 获取已注册的 webhook topics
 	registerTopics := shopifyRepo.ShopifyWebhookTopics
@@ -60,7 +50,7 @@ func (w *WebHookHandler) Shopify(ctx *gin.Context) {
 	// 获取 Shopify 签名
 	signature := ctx.GetHeader("X-Shopify-Hmac-Sha256")
 	if signature == "" {
-		fmt.Println("警告: 缺少 Shopify 签名头")
+		logger.Warn(ctxs, "webhook 缺少 Shopify 签名头", "shop", ctx.GetHeader("X-Shopify-Shop-Domain"))
 		w.Fail(ctx, http.StatusUnauthorized, message.ErrorUnauthorized.Error(), nil)
 		return
 	}
@@ -68,13 +58,11 @@ func (w *WebHookHandler) Shopify(ctx *gin.Context) {
 	// 读取请求体
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
-		fmt.Printf("读取请求体失败: %v\n", err)
+		logger.Error(ctxs, "webhook 读取请求体失败", "error", err.Error())
 		w.Fail(ctx, http.StatusBadGateway, message.ErrorBadRequest.Error(), nil)
 		return
 	}
 
-	// 重新设置请求体供后续使用
-	ctx.Request.Body = io.NopCloser(strings.NewReader(string(body)))
 	// 验证 webhook 签名
 	if !w.appService.VerifyWebhook(ctxs, signature, body) {
 		w.Fail(ctx, http.StatusUnauthorized, message.ErrorUnauthorized.Error(), nil)
@@ -87,42 +75,34 @@ func (w *WebHookHandler) Shopify(ctx *gin.Context) {
 		w.Error(ctx, code.BadRequest, "缺少 X-Shopify-Topic 头", "")
 		return
 	}
-	logger.Warn(ctxs, "webhook topic"+topic)
+	logger.Info(ctxs, "webhook received", "topic", topic, "shop", ctx.GetHeader("X-Shopify-Shop-Domain"))
 	// 验证是否为已注册的 topic
 	if !w.isRegisteredTopic(topic, registerTopics) && !w.isRegisteredTopic(topic, complianceTopics) {
 		w.Error(ctx, code.BadRequest, "未注册的 webhook topic", topic)
 		return
 	}
-	appID := w.appService.GetAppID(ctx.Request.Context())
-	// 根据已注册的 topic 处理不同类型的回调
-	switch topic {
-	case "orders/updated":
-		w.handleOrderUpdated(ctx, appID, body)
-	case "orders/delete":
-		w.handleOrderDeleted(ctx, appID, body)
-	case "app_subscriptions/update":
-		w.handleAppSubscriptionUpdate(ctx, appID, body)
-	case "app_subscriptions/approaching_capped_amount":
-		w.handleAppSubscriptionApproachingCappedAmount(ctx, appID, body)
-	case "app/uninstalled":
-		w.handleAppUninstalled(ctx, appID, body)
-	case "products/update":
-		w.handleProductUpdated(ctx, appID, body)
-	case "products/delete":
-		w.handleProductDeleted(ctx, appID, body)
-	case "shop/update":
-		w.handleShopUpdated(ctx, appID, body)
-	case "shop/redact":
-		w.handleShopUpdated(ctx, appID, body)
-	case "customers/redact":
-		w.Customers(ctx, appID, body)
-	case "customers/data_request":
-		w.Customers(ctx, appID, body)
-	default:
-		// 记录未处理的 topic（虽然通过了注册验证，但可能是新增的）
-		fmt.Printf("收到已注册但未处理的 webhook topic: %s\n", topic)
-		w.Success(ctx, "webhook received but not processed", nil)
+
+	inbox := &jobEntity.WebhookInbox{
+		AppId:       w.appService.GetAppID(ctxs),
+		WebhookId:   ctx.GetHeader("X-Shopify-Webhook-Id"),
+		Topic:       topic,
+		Shop:        ctx.GetHeader("X-Shopify-Shop-Domain"),
+		ApiVersion:  ctx.GetHeader("X-Shopify-API-Version"),
+		TriggeredAt: ctx.GetHeader("X-Shopify-Triggered-At"),
+		Payload:     string(body),
+	}
+	// 没有 webhook id 时按内容去重
+	if inbox.WebhookId == "" {
+		sum := sha256.Sum256([]byte(topic + inbox.Shop + inbox.Payload))
+		inbox.WebhookId = hex.EncodeToString(sum[:])
 	}
+	// 保存失败时返回 500，Shopify 会重新投递
+	if err := w.webhookService.Receive(ctxs, inbox); err != nil {
+		logger.Error(ctxs, "webhook 入库失败", "topic", topic, "webhook_id", inbox.WebhookId, "error", err.Error())
+		w.Fail(ctx, http.StatusInternalServerError, message.ErrorUnknow.Error(), nil)
+		return
+	}
+	w.Success(ctx, "", nil)
 }
 
 // isRegisteredTopic 检查 topic 是否为已注册的
@@ -135,184 +115,64 @@ func (w *WebHookHandler) isRegisteredTopic(topic string, registeredTopics []stri
 	return false
 }
 
-// handleOrderUpdated 处理订单更新
-func (w *WebHookHandler) handleOrderUpdated(ctx *gin.Context, appID string, body []byte) {
-	ctxWithTrace := ctx.Request.Context()
-
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-
-	bodyBytes, _ := io.ReadAll(ctx.Request.Body)
-
-	var webhookData WebhookData
-
-	if err := json.Unmarshal(bodyBytes, &webhookData); err != nil {
-		logger.Error(ctxWithTrace, "订单删除解析JSON失败", err, "body:", string(bodyBytes))
-		utils.CallWilding(err.Error())
+// Replay 管理员重新处理收件箱中的 webhook
+func (w *WebHookHandler) Replay(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		w.Error(ctx, code.BadRequest, message.ErrorBadRequest.Error(), "")
 		return
 	}
-
-	_ = w.orderService.OrderSync(ctxWithTrace, appID, orderEntity.OrderWebHookReq{
-		Shop:    shopDomain,
-		OrderId: webhookData.ID,
-	})
-
-	w.Success(ctx, "", nil)
-}
-
-// handleOrderDeleted 处理订单删除
-func (w *WebHookHandler) handleOrderDeleted(ctx *gin.Context, appID string, body []byte) {
-	ctxWithTrace := ctx.Request.Context()
-
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-	// 2. 读取 Body
-	bodyBytes, _ := io.ReadAll(ctx.Request.Body)
-
-	var webhookData WebhookData
-
-	if err := json.Unmarshal(bodyBytes, &webhookData); err != nil {
-		logger.Error(ctxWithTrace, "订单删除解析JSON失败", err, "body:", string(bodyBytes))
-		utils.CallWilding(err.Error())
+	if err := w.webhookService.Replay(ctx.Request.Context(), id); err != nil {
+		if errors.Is(err, jobEntity.ErrWebhookNotFound) {
+			w.Error(ctx, code.NotFound, err.Error(), "")
+			return
+		}
+		w.Error(ctx, code.ServerOperationFailed, err.Error(), "")
 		return
 	}
-
-	_ = w.orderService.OrderDel(ctxWithTrace, orderEntity.OrderWebHookReq{
-		Shop:    shopDomain,
-		OrderId: webhookData.ID,
-	})
-
 	w.Success(ctx, "", nil)
 }
 
-// handleAppSubscriptionUpdate 处理应用订阅更新
-func (w *WebHookHandler) handleAppSubscriptionUpdate(ctx *gin.Context, appID string, body []byte) {
-	fmt.Printf("处理应用订阅更新 webhook: %s\n", string(body))
-
-	var subscription shopifyEntity.SubscriptionWebhookPayload
-	if err := json.Unmarshal(body, &subscription); err != nil {
-		w.Error(ctx, code.BadRequest, "解析订阅数据失败", err.Error())
-		return
-	}
-	user, _ := w.userService.GetUserFromShopID(ctx, utils.GetIdFromShopifyGraphqlId(subscription.AdminGraphqlApiShopId))
-	// 更新订阅状态
-	err := w.subscriptionService.UpdateSubscriptionStatus(
-		ctx.Request.Context(),
-		user,
-		utils.GetIdFromShopifyGraphqlId(subscription.AdminGraphqlApiId),
-		subscription.Status,
-	)
-
+// Reconcile 管理员手动核对 webhook 订阅，不传 user_id 时核对所有店铺
+func (w *WebHookHandler) Reconcile(ctx *gin.Context) {
+	userID, err := parseOptionalID(ctx.Query("user_id"))
 	if err != nil {
-		w.Error(ctx, code.ServerOperationFailed, "更新订阅状态失败", err.Error())
-		return
-	}
-
-	fmt.Printf("订阅更新成功: ID=%s, Status=%s\n", subscription.AdminGraphqlApiId, subscription.Status)
-	ctx.JSON(http.StatusOK, gin.H{"message": "订阅更新处理成功"})
-}
-
-// handleAppSubscriptionApproachingCappedAmount 处理订阅接近上限金额
-func (w *WebHookHandler) handleAppSubscriptionApproachingCappedAmount(ctx *gin.Context, appID string, body []byte) {
-	fmt.Printf("处理订阅接近上限金额 webhook: %s\n", string(body))
-
-	var subscriptionApproaching shopifyEntity.SubscriptionApproachingPayload
-	if err := json.Unmarshal(body, &subscriptionApproaching); err != nil {
-		w.Error(ctx, code.BadRequest, "解析订阅数据失败", err.Error())
+		w.Error(ctx, code.BadRequest, message.ErrorBadRequest.Error(), "")
 		return
 	}
-
-	// 发送通知或处理接近上限的逻辑
-	err := w.subscriptionService.HandleApproachingCappedAmount(
-		ctx.Request.Context(),
-		utils.GetIdFromShopifyGraphqlId(subscriptionApproaching.AdminGraphqlApiId),
-	)
-
-	if err != nil {
-		w.Error(ctx, code.ServerOperationFailed, "处理接近上限金额失败", err.Error())
+	if err := w.webhookService.Reconcile(ctx.Request.Context(), userID); err != nil {
+		w.Error(ctx, code.ServerOperationFailed, err.Error(), "")
 		return
 	}
-
-	fmt.Printf("订阅接近上限处理成功: ID=%s\n", subscriptionApproaching.AdminGraphqlApiId)
-	ctx.JSON(http.StatusOK, gin.H{"message": "订阅接近上限处理成功"})
-}
-
-// handleAppUninstalled 处理应用卸载
-func (w *WebHookHandler) handleAppUninstalled(ctx *gin.Context, appID string, body []byte) {
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-	ctxWithTrace := ctx.Request.Context()
-	appId := w.appService.GetAppID(ctxWithTrace)
-	if shopDomain != "" {
-		_ = w.userService.Uninstall(ctxWithTrace, appId, shopDomain)
-	}
+	w.Success(ctx, "", nil)
 }
 
-// handleProductUpdated 处理产品更新
-func (w *WebHookHandler) handleProductUpdated(ctx *gin.Context, appID string, body []byte) {
-	ctxWithTrace := ctx.Request.Context()
-
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-
-	bodyBytes, _ := io.ReadAll(ctx.Request.Body)
-
-	var webhookData WebhookData
-
-	if err := json.Unmarshal(bodyBytes, &webhookData); err != nil {
-		logger.Error(ctxWithTrace, "产品修改解析JSON失败", err, "body:", string(bodyBytes))
-		utils.CallWilding(err.Error())
+// Drifts 管理员查看最近的 webhook 订阅差异
+func (w *WebHookHandler) Drifts(ctx *gin.Context) {
+	userID, err := parseOptionalID(ctx.Query("user_id"))
+	if err != nil {
+		w.Error(ctx, code.BadRequest, message.ErrorBadRequest.Error(), "")
 		return
 	}
-
-	_ = w.productService.ProductUpdate(ctxWithTrace, productEntity.ProductWebHookReq{
-		Shop:      shopDomain,
-		ProductId: webhookData.ID,
-	})
-
-	w.Success(ctx, "", nil)
-}
-
-// handleProductDeleted 处理产品删除
-func (w *WebHookHandler) handleProductDeleted(ctx *gin.Context, appID string, body []byte) {
-	ctxWithTrace := ctx.Request.Context()
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-
-	bodyBytes, _ := io.ReadAll(ctx.Request.Body)
-	var webhookData WebhookData
-
-	if err := json.Unmarshal(bodyBytes, &webhookData); err != nil {
-		logger.Error(ctxWithTrace, "产品删除解析JSON失败", err, "body:", string(bodyBytes))
-		utils.CallWilding(err.Error())
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	list, err := w.webhookService.Drifts(ctx.Request.Context(), userID, limit)
+	if err != nil {
+		w.Error(ctx, code.ServerOperationFailed, err.Error(), "")
 		return
 	}
-
-	_ = w.productService.ProductDel(ctxWithTrace, productEntity.ProductWebHookReq{
-		Shop:      shopDomain,
-		ProductId: webhookData.ID,
-	})
-
-	w.Success(ctx, "", nil)
+	w.Success(ctx, "", list)
 }
 
-// handleShopUpdated 处理店铺更新
-func (w *WebHookHandler) handleShopUpdated(ctx *gin.Context, appID string, body []byte) {
-	ctxWithTrace := ctx.Request.Context()
-	shopDomain := ctx.GetHeader("X-Shopify-Shop-Domain")
-
-	bodyBytes, _ := io.ReadAll(ctx.Request.Body)
-
-	var webhookShopData WebhookShopData
-
-	if err := json.Unmarshal(bodyBytes, &webhookShopData); err != nil {
-		logger.Error(ctxWithTrace, "ShopUpdate解析JSON失败", err, "body:", string(bodyBytes))
-		utils.CallWilding(err.Error())
-		return
+// parseOptionalID 解析可选的 id 参数，为空时返回 0
+func parseOptionalID(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
 	}
-
-	err := w.userService.SyncShopifyUserInfo(ctxWithTrace, appID, shopDomain, webhookShopData.PlanDisplayName)
-	if err != nil {
-		utils.CallWilding(err.Error())
-		return
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, message.ErrorBadRequest
 	}
-
-	w.Success(ctx, "", nil)
+	return id, nil
 }
 
 // ChargeCallback 处理 Shopify 订阅回调
@@ -323,10 +183,8 @@ func (w *WebHookHandler) ChargeCallback(ctx *gin.Context) {
 
 	chargeIDStr := ctx.Query("charge_id")
 	userIDStr := ctx.Param("userID")
-	// 记录原始回调数据用于调试
-	fmt.Printf("收到订阅回调,charge id is: %s, user id is: %s\n", chargeIDStr, userIDStr)
+	logger.Info(ctxWithTrace, "charge callback received", "charge_id", chargeIDStr, "user_id", userIDStr)
 	chargeID, err := strconv.ParseInt(chargeIDStr, 10, 64)
-	// 记录原始回调数据用于调试
 	userID, err1 := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil || err1 != nil {
 		w.Error(ctx, code.BadRequest, message.ErrorBadRequest.Error(), "")
@@ -337,6 +195,15 @@ func (w *WebHookHandler) ChargeCallback(ctx *gin.Context) {
 		w.Error(ctx, code.BadRequest, message.ErrorBadRequest.Error(), "")
 		return
 	}
+	// 回调地址由我们签名，路径中的 userID 不可信
+	// 加签名之前创建的订阅回调没有签名，按本地订阅记录放行，再向 Shopify 核对订阅
+	sign := ctx.Query("sign")
+	legacy := sign == "" && w.subscriptionService.LegacyChargeCallback(ctxWithTrace, userID, chargeID)
+	if !legacy && !w.appService.VerifyReturnUrl(ctxWithTrace, userID, sign) {
+		logger.Warn(ctxWithTrace, "charge callback 签名验证失败", "user_id", userID)
+		w.Error(ctx, code.Unauthorized, message.ErrInvalidAccount.Error(), "")
+		return
+	}
 	user, err := w.userService.GetLoginUserFromID(ctxWithTrace, userID)
 
 	if user == nil {
@@ -345,7 +212,12 @@ func (w *WebHookHandler) ChargeCallback(ctx *gin.Context) {
 	}
 	_, err = w.subscriptionService.VerifyPayment(ctxWithTrace, user, chargeID)
 	if err != nil {
-		logger.Error(ctxWithTrace, "charge callback verify payment error", err.Error(), "charge id is: ", chargeID, "user id is: ", userID)
+		logger.Error(ctxWithTrace, "charge callback verify payment error", "error", err.Error(), "charge_id", chargeID, "user_id", userID)
+		// 无签名的回调必须能在 Shopify 查到这个订阅
+		if legacy {
+			w.Error(ctx, code.Unauthorized, message.ErrInvalidAccount.Error(), "")
+			return
+		}
 	}
 	appConf, err := w.appService.GetAppConfig(ctxWithTrace, appID)
 	if err != nil {
@@ -357,8 +229,3 @@ func (w *WebHookHandler) ChargeCallback(ctx *gin.Context) {
 	redirectUrl := fmt.Sprintf("https://admin.shopify.com/store/%s/apps/%s/cart", shopName, appLink)
 	ctx.Redirect(http.StatusFound, redirectUrl)
 }
-
-func (w *WebHookHandler) Customers(ctx *gin.Context, appID string, body []byte) {
-	w.Success(ctx, "", nil)
-
-}
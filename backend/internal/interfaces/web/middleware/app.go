@@ -50,9 +50,10 @@ func (m *AppMiddleware) AppMust() gin.HandlerFunc {
 			return
 		}
 		appData := &appEntity.AppData{
-			AppID:     appDefinition.AppId,
-			AppKey:    appDefinition.ApiKey,
-			AppSecret: appDefinition.ApiSecret,
+			AppID:      appDefinition.AppId,
+			AppKey:     appDefinition.ApiKey,
+			AppSecret:  appDefinition.ApiSecret,
+			AppSecrets: appDefinition.ActiveSecrets(),
 		}
 		jwtManager := jwt.New(
 			appDefinition.ApiSecret,
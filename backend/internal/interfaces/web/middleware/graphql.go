@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 
 	"backend/internal/application/users"
@@ -11,7 +9,6 @@ import (
 	shopifyRepo "backend/internal/domain/repo/shopifys"
 	"backend/internal/infras/shopify_graphql"
 	"backend/internal/providers"
-	"backend/pkg/ctxkeys"
 	"backend/pkg/utils"
 )
 
@@ -48,7 +45,7 @@ func (w *ShopifyGraphqlWare) ShopifyGraphqlClient() func(c *gin.Context) {
 		}
 		shopName, _ := utils.GetShopName(claims.Dest)
 		client := shopify_graphql.NewGraphqlClient(shopName, accessToken)
-		ctx = context.WithValue(ctx, ctxkeys.ShopifyGraphqlClient, client)
+		ctx = shopify_graphql.ContextWithClient(ctx, client)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/internal/application/users"
+	"backend/pkg/ctxkeys"
+	"backend/pkg/jwt"
+	"backend/pkg/logger"
+	"backend/pkg/response/code"
+)
+
+// PlanWare 套餐权益中间件
+type PlanWare struct {
+	planService *users.PlanService
+}
+
+func NewPlanWare(planService *users.PlanService) *PlanWare {
+	return &PlanWare{planService: planService}
+}
+
+// RequireFeature 商家当前套餐不包含功能时拒绝请求，需要放在 CheckLogin 之后
+func (p *PlanWare) RequireFeature(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		claims, ok := ctx.Value(ctxkeys.BizClaims).(*jwt.BizClaims)
+		if !ok || claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": http.StatusText(http.StatusUnauthorized),
+			})
+			return
+		}
+		has, err := p.planService.HasFeature(ctx, claims.UserID, feature)
+		if err != nil {
+			logger.Warn(ctx, "check plan feature error", "err", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"code":    code.ServerOperationFailed,
+				"message": http.StatusText(http.StatusInternalServerError),
+			})
+			return
+		}
+		if !has {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    code.PlanFeatureRequired,
+				"message": "current plan does not include " + feature,
+			})
+			return
+		}
+		c.Next()
+	}
+}
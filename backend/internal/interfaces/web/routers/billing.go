@@ -3,14 +3,25 @@ package routers
 import (
 	"github.com/gin-gonic/gin"
 
+	billingEntity "backend/internal/domain/entity/billings"
 	"backend/internal/interfaces/web/handler"
-	"backend/internal/interfaces/web/middleware"
 )
 
-func RegisterBillingRouter(r *gin.RouterGroup, h *handler.BillingHandler, authWare *middleware.AuthWare) {
-	billingGroup := r.Group("billing", authWare.CheckLogin())
+func RegisterBillingRouter(r *gin.RouterGroup, h *handler.BillingHandler, m *Middleware) {
+	billingGroup := r.Group("billing", m.AuthWare.CheckLogin())
 
 	billingGroup.POST("/list", h.BillList)
 	billingGroup.POST("/details", h.BillDetails)
 	billingGroup.GET("/current", h.CurrentPeriod)
+	billingGroup.GET("/capped", h.CappedAmount)
+	billingGroup.POST("/capped", m.ShopifyGraphqlWare.ShopifyGraphqlClient(), h.IncreaseCappedAmount)
+	billingGroup.POST("/statement", m.PlanWare.RequireFeature(billingEntity.FeatureStatementExport), h.Statement)
+	billingGroup.GET("/plans", h.PlanList)
+	billingGroup.POST("/plan", m.ShopifyGraphqlWare.ShopifyGraphqlClient(), h.ChangePlan)
+	billingGroup.POST("/plan/cancel", m.ShopifyGraphqlWare.ShopifyGraphqlClient(), h.CancelPlan)
+
+	// 超管查看账单对账结果
+	adminGroup := r.Group("admin/billing", m.AuthWare.CheckLogin(), m.AuthWare.CheckAdmin())
+	adminGroup.POST("/reconciliation", h.ReconciliationList)
+	adminGroup.POST("/reconciliation/run", h.RunReconciliation)
 }
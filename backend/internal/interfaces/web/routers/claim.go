@@ -0,0 +1,18 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"backend/internal/interfaces/web/handler"
+)
+
+func RegisterClaimRouter(r *gin.RouterGroup, h *handler.ClaimHandler, m *Middleware) {
+	claimGroup := r.Group("claim", m.AuthWare.CheckLogin())
+
+	claimGroup.POST("/list", h.ClaimList)
+	claimGroup.GET("/detail", h.ClaimDetail)
+	claimGroup.POST("/submit", h.SubmitClaim)
+	claimGroup.POST("/review", h.ReviewClaim)
+	claimGroup.POST("/resolve", h.ResolveClaim)
+	claimGroup.POST("/resolution/retry", h.RetryResolution)
+}
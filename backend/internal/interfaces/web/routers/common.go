@@ -16,5 +16,6 @@ func RegisterCommonRouter(r *gin.RouterGroup, h *handler.CommonHandler, authWare
 	commonGroup.Use(authWare.CheckLogin(), authWare.CheckAdmin())
 	// 依赖注入
 	commonGroup.POST("upload", h.Upload)
+	commonGroup.GET("scheduler", h.SchedulerStatus)
 
 }
@@ -3,6 +3,7 @@ package routers
 import (
 	"github.com/gin-gonic/gin"
 
+	billingEntity "backend/internal/domain/entity/billings"
 	"backend/internal/interfaces/web/handler"
 )
 
@@ -13,5 +14,5 @@ func RegisterOrderRouter(r *gin.RouterGroup, h *handler.OrderHandler, m *Middlew
 	orderGroup := r.Group("/order", m.AuthWare.CheckLogin(), m.ShopifyGraphqlWare.ShopifyGraphqlClient())
 
 	orderGroup.POST("/list", h.OrderList)
-	orderGroup.GET("/dashboard", h.Dashboard)
+	orderGroup.GET("/dashboard", m.PlanWare.RequireFeature(billingEntity.FeatureOrderInsights), h.Dashboard)
 }
@@ -1,14 +1,24 @@
 package routers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	"backend/internal/interfaces/web/handler"
 )
 
-func RegisterPluginRouter(r *gin.RouterGroup, h *handler.SettingHandler) {
+func RegisterPluginRouter(r *gin.RouterGroup, h *handler.SettingHandler, claimHandler *handler.ClaimHandler, m *Middleware) {
 	// 对外
 	publicGroup := r.Group("plugin")
 
 	publicGroup.POST("/config", h.GetPublicCart)
+	publicGroup.POST("/quote", m.RateLimitWare.Limit("quote", 120, time.Minute), h.Quote)
+
+	// 客户理赔页面，不需要登录，按店铺和 IP 限流
+	claimGroup := publicGroup.Group("/claim")
+	claimGroup.POST("/lookup", m.RateLimitWare.Limit("claim_lookup", 10, time.Minute), claimHandler.PortalLookup)
+	claimGroup.POST("/upload", m.RateLimitWare.Limit("claim_upload", 20, time.Minute), claimHandler.PortalUpload)
+	claimGroup.POST("/submit", m.RateLimitWare.Limit("claim_submit", 5, time.Minute), claimHandler.PortalSubmit)
+	claimGroup.POST("/status", m.RateLimitWare.Limit("claim_status", 30, time.Minute), claimHandler.PortalStatus)
 }
@@ -17,6 +17,8 @@ type Middleware struct {
 	CspWare            *middleware.CspMiddleware
 	ShopifyGraphqlWare *middleware.ShopifyGraphqlWare
 	AppMiddleware      *middleware.AppMiddleware
+	PlanWare           *middleware.PlanWare
+	RateLimitWare      *middleware.RateLimitWare
 }
 
 // InitRouters 初始化router规则
@@ -38,11 +40,12 @@ func InitRouters(router *gin.Engine, handlers *handler.Handlers, middlewares *Mi
 	router.NoRoute(requestWare.NotFoundHandler())
 	api := router.Group("/:appId/api/v1") // 定义路由组
 	api.Use(middlewares.AppMiddleware.AppMust(), middlewares.CspWare.Csp())
-	RegisterPluginRouter(api, handlers.SettingHandler)
-	RegisterWebhookRouter(api, handlers.WebhookHandler)
+	RegisterPluginRouter(api, handlers.SettingHandler, handlers.ClaimHandler, middlewares)
+	RegisterWebhookRouter(api, handlers.WebhookHandler, middlewares)
 	RegisterCommonRouter(api, handlers.CommonHandler, middlewares.AuthWare)
-	RegisterBillingRouter(api, handlers.BillingHandler, middlewares.AuthWare)
+	RegisterBillingRouter(api, handlers.BillingHandler, middlewares)
 	RegisterSettingRouter(api, handlers.SettingHandler, middlewares)
 	RegisterOrderRouter(api, handlers.OrderHandler, middlewares)
 	RegisterUserRouter(api, handlers.UserHandler, middlewares)
+	RegisterClaimRouter(api, handlers.ClaimHandler, middlewares)
 }
@@ -15,5 +15,9 @@ func RegisterUserRouter(r *gin.RouterGroup, handler *handler.UserHandler, m *Mid
 	userGroup.GET("session", handler.GetSessionData)
 	userGroup.POST("setting", handler.UpdateUserSetting)
 	userGroup.GET("subscribe", handler.CreateSubscribe)
+	userGroup.POST("backfill", handler.StartOrderBackfill)
+	userGroup.GET("backfill", handler.OrderBackfillStatus)
+	userGroup.GET("data_requests", handler.DataRequests)
+	userGroup.GET("data_requests/:id", handler.DataRequestDownload)
 
 }
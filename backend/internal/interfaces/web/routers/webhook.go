@@ -6,7 +6,7 @@ import (
 	"backend/internal/interfaces/web/handler"
 )
 
-func RegisterWebhookRouter(r *gin.RouterGroup, handler *handler.WebHookHandler) {
+func RegisterWebhookRouter(r *gin.RouterGroup, handler *handler.WebHookHandler, m *Middleware) {
 	//publicRouter := parent.Group("category")
 	webhookGroup := r.Group("webhook")
 	/*webhookGroup.POST("/uninstall", handler.Uninstall)
@@ -25,4 +25,10 @@ func RegisterWebhookRouter(r *gin.RouterGroup, handler *handler.WebHookHandler)
 	webhookGroup.POST("/customer-redact", handler.Customers)*/
 	webhookGroup.POST("/shopify", handler.Shopify)
 	webhookGroup.GET("/charge_callback/:userID", handler.ChargeCallback)
+
+	// 管理员重放收件箱中的 webhook，核对订阅并查看差异
+	adminGroup := r.Group("admin/webhook", m.AuthWare.CheckLogin(), m.AuthWare.CheckAdmin())
+	adminGroup.POST(":id/replay", handler.Replay)
+	adminGroup.POST("reconcile", handler.Reconcile)
+	adminGroup.GET("drift", handler.Drifts)
 }
@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"log"
+
 	"github.com/redis/go-redis/v9"
 	"xorm.io/xorm"
 
@@ -8,6 +10,7 @@ import (
 	"backend/internal/domain/repo/apps"
 	"backend/internal/domain/repo/billings"
 	"backend/internal/domain/repo/carts"
+	"backend/internal/domain/repo/claims"
 	"backend/internal/domain/repo/jobs"
 	jwtRepo "backend/internal/domain/repo/jwtauth"
 	"backend/internal/domain/repo/orders"
@@ -17,19 +20,27 @@ import (
 	"backend/internal/infras/cache"
 	userCacheRepo "backend/internal/infras/cache/users"
 	"backend/internal/infras/config"
+	"backend/internal/infras/exchange"
 	"backend/internal/infras/jwtauth"
 	"backend/internal/infras/shopify"
 	shopifyBillingRepo "backend/internal/infras/shopify_graphql/billings"
+	shopifyBulkRepo "backend/internal/infras/shopify_graphql/bulks"
+	shopifyClaimRepo "backend/internal/infras/shopify_graphql/claims"
 	shopifyOrderRepo "backend/internal/infras/shopify_graphql/orders"
 	shopifyProductRepo "backend/internal/infras/shopify_graphql/products"
 	shopifyShopRepo "backend/internal/infras/shopify_graphql/shops"
+	"backend/internal/infras/statement"
+	"backend/internal/infras/task"
+	"backend/internal/interfaces/persistence"
 	"backend/internal/interfaces/persistence/app"
 	"backend/internal/interfaces/persistence/billing"
 	"backend/internal/interfaces/persistence/cart"
+	"backend/internal/interfaces/persistence/claim"
 	"backend/internal/interfaces/persistence/job"
 	"backend/internal/interfaces/persistence/order"
 	"backend/internal/interfaces/persistence/product"
 	"backend/internal/interfaces/persistence/user"
+	"backend/pkg/crypto"
 	"backend/pkg/crypto/bcrypt"
 	"backend/pkg/jwt"
 )
@@ -41,7 +52,8 @@ type Repositories struct {
 	TableRepos
 	CacheRepos
 	ThirdPartRepos
-	AsyncRepo jobs.AsynqRepository
+	AsyncRepo     jobs.AsynqRepository
+	SchedulerRepo jobs.SchedulerRepository
 }
 
 type TableRepos struct {
@@ -55,22 +67,38 @@ type TableRepos struct {
 	OrderRepo                orders.OrderRepository
 	JobOrderRepo             jobs.OrderRepository
 	JobProductRepo           jobs.ProductRepository
+	OrderBackfillRepo        jobs.OrderBackfillRepository
+	WebhookInboxRepo         jobs.WebhookInboxRepository
+	WebhookDriftRepo         jobs.WebhookDriftRepository
 	UserSubscriptionRepo     users.UserSubscriptionRepository
 	AppRepo                  apps.AppRepository
 	CommissionBillRepo       billings.CommissionBillRepository
 	BillingPeriodSummaryRepo billings.BillingPeriodSummaryRepository
+	CommissionAdjustmentRepo billings.CommissionAdjustmentRepository
 	UserSettingRepo          users.UserSettingRepository
+	RedactRepo               users.RedactRepository
+	ExchangeRateRepo         billings.ExchangeRateRepository
+	ReconciliationRepo       billings.ReconciliationRepository
+	TransactionRepo          repo.TransactionRepository
+	PlanRepo                 billings.PlanRepository
+	ClaimRepo                claims.ClaimRepository
 }
 
 type CacheRepos struct {
 	CacheRepo     repo.CacheRepository
 	UserCacheRepo users.UserCacheRepository
+	// RateLimitRepo 公开接口限流
+	RateLimitRepo repo.RateLimitRepository
 }
 
 type ThirdPartRepos struct {
 	AesCrypto     bcrypt.BCrypto
 	JwtRepo       jwtRepo.JWTRepository
 	AliyunOssRepo repo.AliyunOSSRepository
+	// CurrencyConverter 抽成扣费的币种换算
+	CurrencyConverter billings.CurrencyConverter
+	// StatementRenderer 账期账单导出
+	StatementRenderer billings.StatementRenderer
 }
 
 type ShopifyRepos struct {
@@ -80,20 +108,25 @@ type ShopifyRepos struct {
 	OrderGraphqlRepo        shopifys.OrderGraphqlRepository
 	SubscriptionGraphqlRepo shopifys.SubscriptionGraphqlRepository
 	UsageChargeGraphqlRepo  shopifys.UsageChargeGraphqlRepository
+	AppCreditGraphqlRepo    shopifys.AppCreditGraphqlRepository
 	ThemeGraphqlRepo        shopifys.ThemeGraphqlRepository
+	BulkOperationRepo       shopifys.BulkOperationGraphqlRepository
+	ClaimResolutionRepo     shopifys.ClaimResolutionGraphqlRepository
 }
 
 // NewRepositories 创建 Repositories
 func NewRepositories(db *xorm.Engine, redisClient redis.UniversalClient, appConf *config.AppConfig, opts ...Option) *Repositories {
-	tableRepos := NewTableRepos(db, redisClient)
-	cacheRepos := NewCacheRepos(redisClient, tableRepos.UserRepo)
-	thirdPartRepos := NewThirdPartRepos(appConf)
+	tokenCrypto := NewTokenCrypto(&appConf.Crypto.Envelope)
+	tableRepos := NewTableRepos(db, redisClient, tokenCrypto)
+	cacheRepos := NewCacheRepos(redisClient, tableRepos.UserRepo, tokenCrypto)
+	thirdPartRepos := NewThirdPartRepos(appConf, tableRepos.ExchangeRateRepo)
 	shopifyRepos := NewShopifyRepos(&appConf.Shopify)
 	r := &Repositories{
 		TableRepos:     tableRepos,
 		CacheRepos:     cacheRepos,
 		ThirdPartRepos: thirdPartRepos,
 		ShopifyRepos:   shopifyRepos,
+		SchedulerRepo:  task.NewSchedulerRepository(redisClient, &appConf.Scheduler),
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -101,37 +134,61 @@ func NewRepositories(db *xorm.Engine, redisClient redis.UniversalClient, appConf
 	return r
 }
 
-func NewCacheRepos(redisClient redis.UniversalClient, userRepo users.UserRepository) CacheRepos {
+// NewTokenCrypto Shopify token 的信封加密，密钥配置错误时不能启动，避免 token 明文落库
+func NewTokenCrypto(envelopeConf *config.CryptoEnvelope) *crypto.Envelope {
+	keyring, err := crypto.NewKeyring(envelopeConf.Primary, envelopeConf.Keys)
+	if err != nil {
+		log.Fatalln("failed to init crypto.envelope:", err)
+	}
+	return crypto.NewEnvelope(keyring)
+}
+
+func NewCacheRepos(redisClient redis.UniversalClient, userRepo users.UserRepository, tokenCrypto *crypto.Envelope) CacheRepos {
 	cacheRepo := cache.NewCacheRepository(redisClient)
-	uCacheRepo := userCacheRepo.NewUserCacheRepository(redisClient, userRepo)
+	uCacheRepo := userCacheRepo.NewUserCacheRepository(redisClient, userRepo, tokenCrypto)
+	rateLimitRepo := cache.NewRateLimitRepository(redisClient)
 	return CacheRepos{
 		CacheRepo:     cacheRepo,
 		UserCacheRepo: uCacheRepo,
+		RateLimitRepo: rateLimitRepo,
 	}
 }
 
-func NewTableRepos(db *xorm.Engine, redisClient redis.UniversalClient) TableRepos {
-	userRepo := user.NewUserRepository(db)
+func NewTableRepos(db *xorm.Engine, redisClient redis.UniversalClient, tokenCrypto *crypto.Envelope) TableRepos {
+	userRepo := user.NewUserRepository(db, tokenCrypto)
 	orderRepo := order.NewOrderRepository(db)
 	jobOrderRepo := job.NewOrderRepository(db)
 	jobProductRepo := job.NewProductRepository(db)
+	orderBackfillRepo := job.NewOrderBackfillRepository(db)
+	webhookInboxRepo := job.NewWebhookInboxRepository(db)
+	webhookDriftRepo := job.NewWebhookDriftRepository(db)
 	orderInfoRepo := order.NewOrderInfoRepository(db)
 	productRepo := product.NewProductRepository(db)
 	variantRepo := product.NewVariantRepository(db)
 	cartSettingRepo := cart.NewCartSettingRepository(db)
 	orderSummaryRepo := order.NewOrderSummaryRepository(db)
 	appRepo := app.NewAppRepository(db, redisClient)
-	appAuthRepo := user.NewAppAuthRepository(db)
+	appAuthRepo := user.NewAppAuthRepository(db, tokenCrypto)
 	userSubscriptionRepo := billing.NewUserSubscriptionRepository(db)
 	commissionBillRepo := billing.NewCommissionBillRepository(db)
 	billingPeriodSummaryRepo := billing.NewBillingPeriodSummaryRepo(db)
+	commissionAdjustmentRepo := billing.NewCommissionAdjustmentRepository(db)
 	userSettingRepo := user.NewUserSettingRepository(db)
+	redactRepo := user.NewRedactRepository(db)
+	exchangeRateRepo := billing.NewExchangeRateRepository(db)
+	reconciliationRepo := billing.NewReconciliationRepository(db)
+	transactionRepo := persistence.NewTransactionRepository(db)
+	planRepo := billing.NewPlanRepository(db)
+	claimRepo := claim.NewClaimRepository(db)
 	return TableRepos{
 		UserRepo:                 userRepo,
 		OrderRepo:                orderRepo,
 		JobOrderRepo:             jobOrderRepo,
 		OrderSummaryRepo:         orderSummaryRepo,
 		JobProductRepo:           jobProductRepo,
+		OrderBackfillRepo:        orderBackfillRepo,
+		WebhookInboxRepo:         webhookInboxRepo,
+		WebhookDriftRepo:         webhookDriftRepo,
 		OrderInfoRep:             orderInfoRepo,
 		ProductRepo:              productRepo,
 		VariantRepo:              variantRepo,
@@ -141,11 +198,18 @@ func NewTableRepos(db *xorm.Engine, redisClient redis.UniversalClient) TableRepo
 		UserSubscriptionRepo:     userSubscriptionRepo,
 		CommissionBillRepo:       commissionBillRepo,
 		BillingPeriodSummaryRepo: billingPeriodSummaryRepo,
+		CommissionAdjustmentRepo: commissionAdjustmentRepo,
 		UserSettingRepo:          userSettingRepo,
+		RedactRepo:               redactRepo,
+		ExchangeRateRepo:         exchangeRateRepo,
+		ReconciliationRepo:       reconciliationRepo,
+		TransactionRepo:          transactionRepo,
+		PlanRepo:                 planRepo,
+		ClaimRepo:                claimRepo,
 	}
 }
 
-func NewThirdPartRepos(appConf *config.AppConfig) ThirdPartRepos {
+func NewThirdPartRepos(appConf *config.AppConfig, exchangeRateRepo billings.ExchangeRateRepository) ThirdPartRepos {
 	aesCrypto := bcrypt.NewAesBCrypto(appConf.Crypto.AES.Key, appConf.Crypto.AES.IV)
 	// JwtManager
 	jwtManager := jwt.New(
@@ -154,9 +218,15 @@ func NewThirdPartRepos(appConf *config.AppConfig) ThirdPartRepos {
 		jwt.WithRefreshExpiration(appConf.JWT.RefreshExpiration),
 	)
 	jwtRepository := jwtauth.NewJWTRepository(appConf.JWT.SecretKey, jwtManager, aesCrypto)
+	// 汇率来源可以替换，汇率表作为缓存，过期时才请求汇率来源
+	rateProvider := exchange.NewRateProvider(&appConf.ExchangeRate)
+	currencyConverter := exchange.NewCurrencyConverter(&appConf.ExchangeRate, exchangeRateRepo, rateProvider)
+	statementRenderer := statement.NewStatementRenderer()
 	return ThirdPartRepos{
-		JwtRepo:   jwtRepository,
-		AesCrypto: aesCrypto,
+		JwtRepo:           jwtRepository,
+		AesCrypto:         aesCrypto,
+		CurrencyConverter: currencyConverter,
+		StatementRenderer: statementRenderer,
 	}
 }
 
@@ -167,7 +237,10 @@ func NewShopifyRepos(shopifyConf *config.Shopify) ShopifyRepos {
 	orderGraphqlRepo := shopifyOrderRepo.NewOrderGraphqlRepository()
 	subscriptionGraphqlRepo := shopifyBillingRepo.NewSubscriptionGraphqlRepository()
 	usageChargeGraphqlRepo := shopifyBillingRepo.NewUsageChargeGraphqlRepository()
+	appCreditGraphqlRepo := shopifyBillingRepo.NewAppCreditGraphqlRepository()
 	themeGraphqlRepo := shopifyShopRepo.NewThemeGraphqlRepository()
+	bulkOperationRepo := shopifyBulkRepo.NewBulkOperationGraphqlRepository()
+	claimResolutionRepo := shopifyClaimRepo.NewClaimResolutionGraphqlRepository()
 	return ShopifyRepos{
 		ShopifyRepo:             shopifyRepos,
 		ProductGraphqlRepo:      productGraphqlRepo,
@@ -175,6 +248,9 @@ func NewShopifyRepos(shopifyConf *config.Shopify) ShopifyRepos {
 		OrderGraphqlRepo:        orderGraphqlRepo,
 		SubscriptionGraphqlRepo: subscriptionGraphqlRepo,
 		UsageChargeGraphqlRepo:  usageChargeGraphqlRepo,
+		AppCreditGraphqlRepo:    appCreditGraphqlRepo,
 		ThemeGraphqlRepo:        themeGraphqlRepo,
+		BulkOperationRepo:       bulkOperationRepo,
+		ClaimResolutionRepo:     claimResolutionRepo,
 	}
 }
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// envelopePrefix 信封加密密文的前缀，格式 enc:v1:<key id>:<加密后的数据密钥>:<密文>
+const envelopePrefix = "enc:v1:"
+
+var (
+	// ErrUnknownKey 密文使用的 key id 不在密钥环中
+	ErrUnknownKey = errors.New("crypto: unknown key id")
+	// ErrInvalidCiphertext 密文格式错误或校验失败
+	ErrInvalidCiphertext = errors.New("crypto: invalid ciphertext")
+)
+
+// Keyring 按 key id 保存的主密钥，primary 用来加密新数据，其他 key 只用来解密
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring 创建密钥环，keys 的值是 base64 编码的 32 字节密钥
+// key id 不区分大小写，viper 读取配置时会把 map 的 key 转成小写
+func NewKeyring(primary string, keys map[string]string) (*Keyring, error) {
+	primary = strings.ToLower(strings.TrimSpace(primary))
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, encoded := range keys {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("crypto: invalid key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("crypto: key %s base64 decode error: %v", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("crypto: key %s must be 32 bytes, got %d", id, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("crypto: primary key %q not found", primary)
+	}
+	return k, nil
+}
+
+// Primary 当前用来加密的 key id
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Envelope AES-GCM 信封加密
+// 每次加密生成随机数据密钥加密明文，再用密钥环中的主密钥加密数据密钥，轮换主密钥只需要重新加密数据密钥
+type Envelope struct {
+	keyring *Keyring
+}
+
+// NewEnvelope 使用密钥环创建信封加密
+func NewEnvelope(keyring *Keyring) *Envelope {
+	return &Envelope{keyring: keyring}
+}
+
+// Encrypt 用主密钥加密，空字符串不加密
+func (e *Envelope) Encrypt(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	dataAead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAead, []byte(s), nil)
+	if err != nil {
+		return "", err
+	}
+
+	keyID := e.keyring.primary
+	// 用 key id 作为附加数据，防止密文被替换成其他 key 的数据密钥
+	wrappedKey, err := seal(e.keyring.keys[keyID], dataKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + keyID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt 解密，没有前缀的内容是加密前写入的明文，原样返回
+func (e *Envelope) Decrypt(payload string) (string, error) {
+	if !IsEnvelope(payload) {
+		return payload, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(payload, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", ErrInvalidCiphertext
+	}
+	keyAead, ok := e.keyring.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	dataKey, err := open(keyAead, wrappedKey, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	dataAead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAead, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotate 内容是明文或者不是用主密钥加密的，需要重新加密
+func (e *Envelope) NeedsRotate(payload string) bool {
+	if payload == "" {
+		return false
+	}
+	if !IsEnvelope(payload) {
+		return true
+	}
+	return !strings.HasPrefix(payload, envelopePrefix+e.keyring.primary+":")
+}
+
+// IsEnvelope 是否是信封加密的密文
+func IsEnvelope(payload string) bool {
+	return strings.HasPrefix(payload, envelopePrefix)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密，随机 nonce 放在密文前面
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open 解密 seal 的结果
+func open(aead cipher.AEAD, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, data := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, data, additionalData)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
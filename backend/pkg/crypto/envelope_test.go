@@ -0,0 +1,83 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestEnvelope(t *testing.T) {
+	oldRing, err := NewKeyring("k1", map[string]string{"k1": testKey('a')})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := NewEnvelope(oldRing)
+
+	token := "shpat_0123456789abcdef"
+	ciphertext, err := old.Encrypt(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(ciphertext, token) || !IsEnvelope(ciphertext) {
+		t.Fatalf("ciphertext leaks token: %s", ciphertext)
+	}
+	if again, _ := old.Encrypt(token); again == ciphertext {
+		t.Error("ciphertext should be randomized")
+	}
+	if plain, err := old.Decrypt(ciphertext); err != nil || plain != token {
+		t.Fatalf("decrypt = %q, %v", plain, err)
+	}
+	if old.NeedsRotate(ciphertext) {
+		t.Error("ciphertext from primary key should not need rotate")
+	}
+
+	// 轮换：新主密钥 k2，k1 保留用于解密
+	newRing, err := NewKeyring("K2", map[string]string{"k1": testKey('a'), "k2": testKey('b')})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := NewEnvelope(newRing)
+	if !rotated.NeedsRotate(ciphertext) {
+		t.Error("ciphertext from old key should need rotate")
+	}
+	if plain, err := rotated.Decrypt(ciphertext); err != nil || plain != token {
+		t.Fatalf("decrypt with rotated keyring = %q, %v", plain, err)
+	}
+
+	// 历史明文原样返回并需要加密
+	if plain, err := rotated.Decrypt(token); err != nil || plain != token {
+		t.Errorf("legacy plaintext = %q, %v", plain, err)
+	}
+	if !rotated.NeedsRotate(token) || rotated.NeedsRotate("") {
+		t.Error("legacy plaintext should need rotate, empty should not")
+	}
+
+	// 移除旧密钥后无法解密
+	onlyNew, _ := NewKeyring("k2", map[string]string{"k2": testKey('b')})
+	if _, err := NewEnvelope(onlyNew).Decrypt(ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("removed key err = %v", err)
+	}
+
+	// 篡改密文
+	tampered := ciphertext[:len(ciphertext)-2] + "AA"
+	if _, err := old.Decrypt(tampered); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("tampered err = %v", err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	if _, err := NewKeyring("k1", map[string]string{"k2": testKey('a')}); err == nil {
+		t.Error("missing primary key should fail")
+	}
+	if _, err := NewKeyring("k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}); err == nil {
+		t.Error("short key should fail")
+	}
+	if _, err := NewKeyring("", nil); err == nil {
+		t.Error("empty keyring should fail")
+	}
+}
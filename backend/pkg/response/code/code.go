@@ -14,4 +14,7 @@ var (
 	UploadFailed          = 1010007
 	ServerOperationFailed = 1010008
 	PaymentRequestFailed  = 1010009
+	PlanFeatureRequired   = 1010010
+	TooManyRequests       = 1010011
+	InvalidPricingRules   = 1010012
 )
@@ -17,44 +17,30 @@
         return match ? parseFloat(match[0].replace(',', '')) : 0;
     }
 
-    // 计算保险价格
-    function calculateprotectify(baseTotal, config) {
-        console.log(baseTotal)
-        if (!config) return 0;
-        if (config.pricing_type === 0 && Array.isArray(config.price_select)) {
-            for (const rule of config.price_select) {
-                if (baseTotal >= parseFloat(rule.min) && baseTotal < parseFloat(rule.max)) {
-                    return parseFloat(rule.price);
-                }
-            }
-        } else if (config.pricing_type === 1 && Array.isArray(config.tiers_select)) {
-            for (const rule of config.tiers_select) {
-                if (baseTotal >= parseFloat(rule.min) && baseTotal < parseFloat(rule.max)) {
-                    return parseFloat((baseTotal * parseFloat(rule.percentage) / 100).toFixed(2));
-                }
-            }
+    // 向服务端查询保险报价，价格规则和订单抽成一致，返回价格和要加购的变体
+    async function fetchProtectifyQuote(baseTotal) {
+        const res = await fetch('https://api.protectifyapp.com/protectify/api/v1/plugin/quote', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({shop: window.Shopify.shop, subtotal: baseTotal.toFixed(2)})
+        });
+        const resJson = await res.json();
+        if (resJson.code !== 0 || resJson.data == null) {
+            return {price: 0, variantId: 0};
         }
-
-        return parseFloat(config.other_money);
-        return 0;
+        return {price: parseFloat(resJson.data.variant_price), variantId: resJson.data.variant_id};
     }
 
-    function findClosestVariantId(protectifyAmount, priceVariantMap) {
-        // 把 key-value 对变成 [{ price: 数字, key: 字符串 }] 的数组
-        const priceList = Object.keys(priceVariantMap)
-            .map((key) => ({
-                price: parseFloat(key),
-                key: key
-            }))
-            .sort((a, b) => a.price - b.price); // 按价格升序排
-
-        for (const item of priceList) {
-            if (item.price >= protectifyAmount) {
-                return {price: parseFloat(item.price), variantId: priceVariantMap[item.key]};
+    // 购物车金额不包含保险商品
+    function cartBaseTotal(cart) {
+        const productId = window.protectifyData.protectifyProductId || (window.protectifyData.config && window.protectifyData.config.product_id);
+        let total = cart.items_subtotal_price;
+        for (const item of cart.items || []) {
+            if (productId && item.product_id === productId) {
+                total -= item.final_line_price;
             }
         }
-
-        return {price: 0, variantId: 0};
+        return total / 100;
     }
 
     // 创建保险卡片 HTML
@@ -243,8 +229,7 @@
             if (el) {
                 window.protectifyData.baseTotal = parsePriceString(el.textContent);
             }
-            let protectifyPrice = calculateprotectify(window.protectifyData.baseTotal, config);
-            const result = findClosestVariantId(protectifyPrice, config.variants || {});
+            const result = await fetchProtectifyQuote(window.protectifyData.baseTotal);
 
             if (result.variantId && result.price && config.product_id) {
                 window.protectifyData.protectifyVariantId = result.variantId;
@@ -260,11 +245,10 @@
     }
 
     // 购物车变化时处理
-    function onCartChanged(cart) {
-        const baseTotal = cart.items_subtotal_price / 100;
+    async function onCartChanged(cart) {
+        const baseTotal = cartBaseTotal(cart);
         window.protectifyData.baseTotal = baseTotal;
-        let protectifyPrice = calculateProtectify(baseTotal, window.protectifyData.config);
-        const result = findClosestVariantId(protectifyPrice, window.protectifyData.config.variants || {});
+        const result = await fetchProtectifyQuote(baseTotal);
         window.protectifyData.protectifyVariantId = result.variantId;
         window.protectifyData.protectifyPrice = result.price
 
@@ -281,7 +265,7 @@
             if (args[0] && typeof args[0] === 'string' && args[0].includes('/cart/change')) {
                 console.log('捕捉到 /cart/change 请求:', args[0]);
                 response.clone().json().then((cart) => {
-                    onCartChanged(cart);
+                    return onCartChanged(cart);
                 }).catch((err) => console.error('解析购物车失败:', err));
             }
             return response;